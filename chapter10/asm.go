@@ -0,0 +1,392 @@
+package bytecode
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"example.com/cymbol"
+)
+
+// Pattern 26:
+// Bytecode Assembler
+
+// An assembler turns a program written with the mnemonics of the instructions
+// into bytecode: it looks up the opcode of each mnemonic, encodes its
+// operands, and fills the constant pool. One instruction goes on each line,
+// a label names the address of the instruction after it, and directives
+// declare the number of globals and where each function starts:
+//
+//	.globals 1
+//	.def fact: args=1, locals=0
+//	    load 0              // if n < 2 return 1
+//	    iconst 2
+//	    ilt
+//	    brf cont
+//	    iconst 1
+//	    ret
+//	cont:
+//	    load 0              // return n * fact(n - 1)
+//	    load 0
+//	    iconst 1
+//	    isub
+//	    call fact()
+//	    imul
+//	    ret
+//	.def main: args=0, locals=0
+//	    iconst 5
+//	    call fact()
+//	    print
+//	    halt
+//
+// The tokens are Cymbol's, read by the cymbol lexer, so the literals and the
+// comments are written the same way. The assembler is a recursive-descent
+// parser of the grammar below, emitting code as it matches; since lines end
+// instructions, it tells where one ends by the line of the token after it:
+//
+// program  : line* EOF ;
+// line     : label? (directive | instr)? ;
+// label    : ID ':' ;
+// directive: '.' 'globals' INT
+//          | '.' 'def' ID ':' 'args' '=' INT ',' 'locals' '=' INT
+//          ;
+// instr    : ID (operand (',' operand)*)? ;
+// operand  : '-'? INT | '-'? FLOAT | CHAR | STRING | 'true' | 'false'
+//          | ID | ID '(' ')'
+//          ;
+//
+// Which operands an instruction takes is in its entry of the instruction
+// table of the machine the program is for, and each operand is parsed as
+// that kind: in brf cont, cont is a label, in call fact() a function, and in
+// the register machine's brf r1, cont, r1 is a register.
+//
+// Labels and functions can be used before they're defined, a forward
+// reference. The assembler writes 0 in place of a label's address and
+// remembers where, then writes the address in once the whole program is read,
+// which is called backpatching. Functions don't need it: their operand is
+// their index in the pool, known as soon as the function is first used, and
+// the pool entry gets the function's address when its .def comes.
+
+type assembler struct {
+	lex    *cymbol.Lexer
+	tok    cymbol.Token // the lookahead token
+	prog   *Program
+	labels map[string]int          // label addresses
+	fixups []fixup                 // label operands written before their label
+	funcs  map[string]int          // pool index of each function
+	refs   map[string]cymbol.Token // first use of the functions not defined yet
+	consts map[any]int             // pool index of each float and string
+}
+
+// fixup is a label operand to backpatch.
+type fixup struct {
+	addr  int // of the operand
+	label cymbol.Token
+}
+
+// Assemble assembles the program in src for the machine m. The program
+// starts at the function named main if there's one.
+func Assemble(src string, m Machine) (prog *Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*cymbol.Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	a := &assembler{
+		lex:    cymbol.NewLexer(src),
+		prog:   &Program{Machine: m},
+		labels: map[string]int{},
+		funcs:  map[string]int{},
+		refs:   map[string]cymbol.Token{},
+		consts: map[any]int{},
+	}
+	a.consume()
+	for a.tok.Type != cymbol.EOF {
+		a.line()
+	}
+	a.backpatch()
+	var undefined *cymbol.Token
+	for _, name := range a.refs {
+		if undefined == nil || name.Pos.Before(undefined.Pos) {
+			undefined = &name
+		}
+	}
+	if undefined != nil {
+		errorf(undefined.Pos, "undefined function %s", undefined.Text)
+	}
+	return a.prog, nil
+}
+
+func (a *assembler) line() {
+	line := a.tok.Pos.Line
+	if a.tok.Type != cymbol.Dot {
+		name := a.word()
+		if a.tok.Type != cymbol.Colon {
+			a.instr(name)
+			a.end(line)
+			return
+		}
+		a.match(cymbol.Colon)
+		a.label(name)
+		if a.tok.Type == cymbol.EOF || a.tok.Pos.Line != line {
+			return
+		}
+	}
+	if a.tok.Type == cymbol.Dot {
+		a.directive()
+	} else {
+		a.instr(a.word())
+	}
+	a.end(line)
+}
+
+func (a *assembler) label(name cymbol.Token) {
+	if _, ok := a.labels[name.Text]; ok {
+		errorf(name.Pos, "label %s redefined", name.Text)
+	}
+	a.labels[name.Text] = len(a.prog.Code)
+}
+
+func (a *assembler) directive() {
+	a.match(cymbol.Dot)
+	d := a.word()
+	switch d.Text {
+	case "globals":
+		a.prog.Globals = a.int()
+	case "def":
+		name := a.word()
+		a.match(cymbol.Colon)
+		a.keyword("args")
+		a.match(cymbol.Assign)
+		args := a.int()
+		a.match(cymbol.Comma)
+		a.keyword("locals")
+		a.match(cymbol.Assign)
+		locals := a.int()
+		a.define(name, args, locals)
+	default:
+		errorf(d.Pos, "unknown directive .%s", d.Text)
+	}
+}
+
+// define starts the function name at the current address.
+func (a *assembler) define(name cymbol.Token, args, locals int) {
+	f := a.function(name)
+	if _, ok := a.refs[name.Text]; !ok {
+		errorf(name.Pos, "function %s redefined", name.Text)
+	}
+	delete(a.refs, name.Text)
+	f.Args, f.Locals, f.Addr = args, locals, len(a.prog.Code)
+	if f.Name == "main" {
+		a.prog.Main = f
+	}
+}
+
+// function returns the Function name, added to the pool the first time it's
+// used, until it's defined.
+func (a *assembler) function(name cymbol.Token) *Function {
+	if i, ok := a.funcs[name.Text]; ok {
+		return a.prog.Pool[i].(*Function)
+	}
+	f := &Function{Name: name.Text}
+	a.funcs[name.Text] = len(a.prog.Pool)
+	a.refs[name.Text] = name
+	a.prog.Pool = append(a.prog.Pool, f)
+	return f
+}
+
+func (a *assembler) instr(name cymbol.Token) {
+	op, ok := opcodes[name.Text]
+	if !ok {
+		errorf(name.Pos, "unknown instruction %s", name.Text)
+	}
+	instr, ok := a.prog.Machine.instruction(op)
+	if !ok {
+		errorf(name.Pos, "the %v machine has no instruction %s", a.prog.Machine, name.Text)
+	}
+	a.prog.Code = append(a.prog.Code, byte(op))
+	for i, kind := range instr.operands {
+		if i > 0 {
+			a.match(cymbol.Comma)
+		}
+		a.operand(kind)
+	}
+}
+
+func (a *assembler) operand(kind operand) {
+	switch kind {
+	case intArg:
+		a.prog.emit(a.int())
+	case charArg:
+		tok := a.match(cymbol.Char)
+		r, _, _, err := strconv.UnquoteChar(tok.Text[1:len(tok.Text)-1], '\'')
+		if err != nil {
+			errorf(tok.Pos, "invalid char %s: %w", tok.Text, err)
+		}
+		a.prog.emit(int(r))
+	case addrArg:
+		label := a.word()
+		a.fixups = append(a.fixups, fixup{addr: len(a.prog.Code), label: label})
+		a.prog.emit(0)
+	case funcArg:
+		name := a.word()
+		a.match(cymbol.LParen)
+		a.match(cymbol.RParen)
+		a.function(name)
+		a.prog.emit(a.funcs[name.Text])
+	case poolArg:
+		a.prog.emit(a.constant())
+	case regArg:
+		a.prog.emit(a.register())
+	case boolArg:
+		switch a.tok.Type {
+		case cymbol.True:
+			a.prog.emit(1)
+		case cymbol.False:
+			a.prog.emit(0)
+		default:
+			panic(unexpected(a.tok, "boolean"))
+		}
+		a.consume()
+	}
+}
+
+// register matches a register, r followed by its number.
+func (a *assembler) register() int {
+	tok := a.word()
+	n, err := strconv.Atoi(strings.TrimPrefix(tok.Text, "r"))
+	if !strings.HasPrefix(tok.Text, "r") || err != nil || n < 0 {
+		errorf(tok.Pos, "invalid register %s", tok.Text)
+	}
+	return n
+}
+
+// constant matches a float or a string and returns its index in the pool.
+func (a *assembler) constant() int {
+	var c any
+	tok := a.tok
+	switch tok.Type {
+	case cymbol.String:
+		a.consume()
+		s, err := strconv.Unquote(tok.Text)
+		if err != nil {
+			errorf(tok.Pos, "invalid string %s: %w", tok.Text, err)
+		}
+		c = s
+	default:
+		c = a.float()
+	}
+	if i, ok := a.consts[c]; ok {
+		return i
+	}
+	a.consts[c] = len(a.prog.Pool)
+	a.prog.Pool = append(a.prog.Pool, c)
+	return a.consts[c]
+}
+
+func (a *assembler) float() float64 {
+	pos := a.tok.Pos
+	text := a.sign()
+	tok := a.tok
+	if tok.Type != cymbol.Float && tok.Type != cymbol.Int {
+		panic(unexpected(tok, "Float"))
+	}
+	a.consume()
+	f, err := strconv.ParseFloat(text+tok.Text, 64)
+	if err != nil {
+		errorf(pos, "invalid float %s: %w", text+tok.Text, err)
+	}
+	return f
+}
+
+// int matches an integer operand, which has to fit in 4 bytes.
+func (a *assembler) int() int {
+	pos := a.tok.Pos
+	text := a.sign() + a.match(cymbol.Int).Text
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || n < math.MinInt32 || n > math.MaxInt32 {
+		errorf(pos, "integer %s out of range", text)
+	}
+	return int(n)
+}
+
+// sign matches an optional minus sign.
+func (a *assembler) sign() string {
+	if a.tok.Type == cymbol.Minus {
+		a.consume()
+		return "-"
+	}
+	return ""
+}
+
+// backpatch writes the addresses of the labels used before they're defined.
+func (a *assembler) backpatch() {
+	for _, f := range a.fixups {
+		addr, ok := a.labels[f.label.Text]
+		if !ok {
+			errorf(f.label.Pos, "undefined label %s", f.label.Text)
+		}
+		a.prog.patch(f.addr, addr)
+	}
+}
+
+// end checks nothing follows on the line of what was just matched.
+func (a *assembler) end(line int) {
+	if a.tok.Type != cymbol.EOF && a.tok.Pos.Line == line {
+		panic(unexpected(a.tok, "end of line"))
+	}
+}
+
+// word matches an identifier. Keywords are words too: struct is an
+// instruction.
+func (a *assembler) word() cymbol.Token {
+	tok := a.tok
+	if tok.Text == "" || !isLetter(rune(tok.Text[0])) {
+		panic(unexpected(tok, "ID"))
+	}
+	a.consume()
+	return tok
+}
+
+// keyword matches the word text.
+func (a *assembler) keyword(text string) {
+	if a.tok.Text != text {
+		panic(unexpected(a.tok, text))
+	}
+	a.consume()
+}
+
+func (a *assembler) match(typ cymbol.TokenType) cymbol.Token {
+	tok := a.tok
+	if tok.Type != typ {
+		panic(unexpected(tok, typ.String()))
+	}
+	a.consume()
+	return tok
+}
+
+func (a *assembler) consume() {
+	tok, err := a.lex.Next()
+	if err != nil {
+		panic(err)
+	}
+	a.tok = tok
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+}
+
+func unexpected(tok cymbol.Token, expecting string) error {
+	err := fmt.Errorf("%w: expecting %s, found %v", cymbol.SyntaxError, expecting, tok.Type)
+	return &cymbol.Error{Pos: tok.Pos, Err: err}
+}
+
+func errorf(pos cymbol.Pos, format string, args ...any) {
+	panic(&cymbol.Error{Pos: pos, Err: fmt.Errorf(format, args...)})
+}
@@ -0,0 +1,219 @@
+package bytecode
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const fact = `.globals 1
+.def fact: args=1, locals=0
+    load 0              // if n < 2 return 1
+    iconst 2
+    ilt
+    brf cont
+    iconst 1
+    ret
+cont:
+    load 0              // return n * fact(n - 1)
+    load 0
+    iconst 1
+    isub
+    call fact()
+    imul
+    ret
+.def main: args=0, locals=0
+    iconst 5
+    gstore 0
+    gload 0
+    call fact()
+    print
+    halt
+`
+
+func TestAssemble(t *testing.T) {
+	prog, err := Assemble(`
+.def main: args=0, locals=1
+start: iconst -2
+    fconst 2.5
+    sconst "a\n"
+    fconst 2.5
+    cconst 'c'
+    br start
+    call f()
+    halt
+.def f: args=0, locals=0
+    ret
+`, Stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Function{Name: "f", Addr: 36}
+	main := &Function{Name: "main", Locals: 1}
+	want := &Program{
+		Code: []byte{
+			byte(Iconst), 0xff, 0xff, 0xff, 0xfe,
+			byte(Fconst), 0, 0, 0, 1,
+			byte(Sconst), 0, 0, 0, 2,
+			byte(Fconst), 0, 0, 0, 1,
+			byte(Cconst), 0, 0, 0, 'c',
+			byte(Br), 0, 0, 0, 0,
+			byte(Call), 0, 0, 0, 3,
+			byte(Halt),
+			byte(Ret),
+		},
+		Pool: []any{main, 2.5, "a\n", f},
+		Main: main,
+	}
+	if diff := cmp.Diff(want, prog); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestBackpatch(t *testing.T) {
+	prog, err := Assemble(fact, Stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// brf cont, before cont is defined
+	if op, addr := Opcode(prog.Code[11]), prog.Operand(12); op != Brf || addr != 22 {
+		t.Errorf("got %v %d, want brf 22", op, addr)
+	}
+	if op := Opcode(prog.Code[22]); op != Load {
+		t.Errorf("got %v at cont, want load", op)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	cases := []string{
+		fact,
+		`.def main: args=0, locals=0
+    sconst "tab\t\"quote\""
+    fconst -1.0
+    fconst 3
+    cconst '\''
+    struct 2
+    null
+    pop
+L12:
+    br L12
+`,
+		`iconst 1
+loop: brt loop
+    bconst true
+    bconst false
+    dup
+`,
+	}
+	for _, src := range cases {
+		prog, err := Assemble(src, Stack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := Disassemble(prog)
+		again, err := Assemble(text, Stack)
+		if err != nil {
+			t.Fatalf("%v in\n%s", err, text)
+		}
+		if diff := cmp.Diff(prog, again); diff != "" {
+			t.Errorf("%s\n%s", text, diff)
+		}
+		if text2 := Disassemble(again); text2 != text {
+			t.Error(cmp.Diff(text, text2))
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	prog, err := Assemble(fact, Stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `.globals 1
+.def fact: args=1, locals=0
+    load 0
+    iconst 2
+    ilt
+    brf L22
+    iconst 1
+    ret
+L22:
+    load 0
+    load 0
+    iconst 1
+    isub
+    call fact()
+    imul
+    ret
+.def main: args=0, locals=0
+    iconst 5
+    gstore 0
+    gload 0
+    call fact()
+    print
+    halt
+`
+	if got := Disassemble(prog); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"iconst", "1:7: syntax error: expecting Int, found EOF"},
+		{"iconst 1 2", "1:10: syntax error: expecting end of line, found Int"},
+		{"iconst 3000000000", "1:8: integer 3000000000 out of range"},
+		{"jump x", "1:1: unknown instruction jump"},
+		{"br nowhere", "1:4: undefined label nowhere"},
+		{"a: halt\na: halt", "2:1: label a redefined"},
+		{"call f()\ncall g()", "1:6: undefined function f"},
+		{".def f: args=0, locals=0\n.def f: args=0, locals=0", "2:6: function f redefined"},
+		{".def f: args=0 locals=0", "1:16: syntax error: expecting Comma, found ID"},
+		{".code", "1:2: unknown directive .code"},
+		{"fconst x", "1:8: syntax error: expecting Float, found ID"},
+		{"iconst 1 # 2", "1:10: invalid character: '#'"},
+	}
+	for _, tc := range cases {
+		_, err := Assemble(tc.src, Stack)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.src, err, tc.want)
+		}
+	}
+}
+
+func TestAssembleRegister(t *testing.T) {
+	prog, err := Assemble(registerFact, Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// iconst r2, 2 then ilt r3, r1, r2
+	want := []byte{byte(Iconst), 0, 0, 0, 2, 0, 0, 0, 2, byte(Ilt), 0, 0, 0, 3, 0, 0, 0, 1, 0, 0, 0, 2}
+	if diff := cmp.Diff(want, prog.Code[:len(want)]); diff != "" {
+		t.Error(diff)
+	}
+	again, err := Assemble(Disassemble(prog), Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(prog, again); diff != "" {
+		t.Error(diff)
+	}
+
+	for src, want := range map[string]string{
+		"load 0":        "1:1: the register machine has no instruction load",
+		"iconst x, 1":   "1:8: invalid register x",
+		"iconst r1":     "1:10: syntax error: expecting Comma, found EOF",
+		"iconst r-1, 1": "1:8: invalid register r",
+	} {
+		_, err := Assemble(src, Register)
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: got %v, want %s", src, err, want)
+		}
+	}
+	if _, err := Assemble("move r1, r2", Stack); err == nil || err.Error() != "1:1: the stack machine has no instruction move" {
+		t.Errorf("got %v, want no move on the stack machine", err)
+	}
+}
@@ -0,0 +1,323 @@
+// Package bytecode assembles and runs the bytecode of the book's chapter 10,
+// the instructions of a virtual machine that interpreters run much faster
+// than they walk trees: the code is a flat array of bytes, names are already
+// turned into addresses and indexes, and running an instruction is a switch
+// on a single byte.
+package bytecode
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Bytecode
+//
+// Each instruction is an opcode byte followed by its operands, each one a
+// 4-byte signed integer, most significant byte first:
+//
+//	iconst 3      0d 00 00 00 03
+//	iadd          01
+//
+// What an operand means depends on the instruction: a plain integer, such as
+// the value iconst pushes or the index of the local load reads, a code
+// address to branch to, or the index of an entry of the constant pool. The
+// pool holds what doesn't fit in an operand, floats, strings and the
+// Functions called, so fconst 2.5 and call f() have the pool index of 2.5 and
+// of f as operand.
+//
+// The stack machine and the register machine share their opcodes, but not
+// always their operands: the stack machine's iadd has none, it adds the
+// values on top of its stack, while the register machine's has three, the
+// register to set and the two registers to add. One table, opcodeTable, has
+// the instructions of both, with the operands each one takes on each
+// machine, and everything else goes by it.
+
+// Machine is a kind of machine running bytecode.
+type Machine int
+
+const (
+	Stack    Machine = iota // Pattern 27, see vm.go
+	Register                // Pattern 28, see regvm.go
+)
+
+func (m Machine) String() string {
+	if m == Register {
+		return "register"
+	}
+	return "stack"
+}
+
+type Opcode byte
+
+// Opcodes
+const (
+	Iadd Opcode = iota + 1 // integer arithmetic and comparisons
+	Isub
+	Imul
+	Ilt
+	Ieq
+	Fadd // float arithmetic and comparisons
+	Fsub
+	Fmul
+	Flt
+	Feq
+	Itof   // int to float
+	Call   // call f()
+	Ret    // return from the current call
+	Br     // br label, branch
+	Brt    // brt label, branch if true
+	Brf    // brf label, branch if false
+	Cconst // cconst 'c'
+	Iconst // iconst 1
+	Fconst // fconst 2.5
+	Sconst // sconst "s"
+	Load   // load i, local or parameter i
+	Gload  // gload i, global i
+	Fload  // fload i, field i of a struct
+	Store  // store i
+	Gstore // gstore i
+	Fstore // fstore i, of the struct under the value
+	Print
+	Struct // struct n, a new struct of n fields
+	Null   // a null struct
+	Pop    // throw away the top of the stack
+	Halt
+	Move // move r1, r2, copy register r2 to r1
+
+	// the stack machine's instructions for the operators the book's set
+	// leaves out, which compile.go generates
+	Idiv
+	Ineg
+	Ile
+	Igt
+	Ige
+	Ine
+	Fdiv
+	Fneg
+	Fle
+	Fgt
+	Fge
+	Fne
+	Ctoi // char to int
+	Itoc // int to char
+	Sadd // concatenation
+	Seq  // string equality
+	Sne
+	Bconst // bconst true
+	Not
+	Beq // boolean equality
+	Bne
+	Dup // push the top of the stack again
+
+	// the stack machine's lists, see heap.go
+	List    // list n, a new list of the n values on top of the stack
+	Lload   // the element at an index of a list
+	Lstore  // set the element at an index of a list to the value on top
+	Llen    // the length of a list
+	Lappend // append the value on top to the list under it
+
+	// the stack machine's superinstructions, see optimize.go
+	Iaddc // iaddc n, add n to the int on top
+	Ladd  // ladd i, j, push the sum of the locals i and j
+	Linc  // linc i, n, add n to the local i
+)
+
+// operand is the kind of an operand, which tells how it's written in
+// assembly and what it encodes.
+type operand int
+
+const (
+	intArg  operand = iota // an integer: 1, -1
+	charArg                // an integer written as a char: 'a'
+	addrArg                // a code address written as a label: loop
+	funcArg                // a pool index written as the function: f()
+	poolArg                // a pool index written as the constant: 2.5, "s"
+	regArg                 // a register: r1
+	boolArg                // 0 or 1 written as a boolean: true
+)
+
+// opcodeInfo is all there is to know about an opcode: its mnemonic, the
+// operands it takes on each machine, which tell whether the machine has it,
+// and how many values the stack machine pops and pushes running it. The
+// assembler, the disassembler, the machines, the compiler, the optimizer and
+// the verifier all go by opcodeTable, so they can't disagree.
+type opcodeInfo struct {
+	name      string
+	stack     []operand // the stack machine's operands, nil if it hasn't the instruction
+	register  []operand // the register machine's, likewise
+	pop, push int       // the stack machine's stack effect, or varies
+}
+
+// none are the operands of an instruction that takes none, which nil would
+// leave out of its machine.
+var none = []operand{}
+
+// varies is the stack effect of the instructions it depends on the operand
+// of, or the function, of: call pops the arguments of the function and
+// pushes what it returns, ret pops what the function returns, and list n
+// pops n values.
+const varies = -1
+
+// opcodeTable has the instructions of both machines. The register machine's
+// have the register of their result first, most of the time. The arguments
+// of its call are in the registers from the one given on, its result in r0.
+var opcodeTable = [...]opcodeInfo{
+	Iadd:   {"iadd", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Isub:   {"isub", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Imul:   {"imul", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Ilt:    {"ilt", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Ieq:    {"ieq", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Fadd:   {"fadd", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Fsub:   {"fsub", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Fmul:   {"fmul", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Flt:    {"flt", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Feq:    {"feq", none, []operand{regArg, regArg, regArg}, 2, 1},
+	Itof:   {"itof", none, []operand{regArg, regArg}, 1, 1},
+	Call:   {"call", []operand{funcArg}, []operand{funcArg, regArg}, varies, varies},
+	Ret:    {"ret", none, none, varies, 0},
+	Br:     {"br", []operand{addrArg}, []operand{addrArg}, 0, 0},
+	Brt:    {"brt", []operand{addrArg}, []operand{regArg, addrArg}, 1, 0},
+	Brf:    {"brf", []operand{addrArg}, []operand{regArg, addrArg}, 1, 0},
+	Cconst: {"cconst", []operand{charArg}, []operand{regArg, charArg}, 0, 1},
+	Iconst: {"iconst", []operand{intArg}, []operand{regArg, intArg}, 0, 1},
+	Fconst: {"fconst", []operand{poolArg}, []operand{regArg, poolArg}, 0, 1},
+	Sconst: {"sconst", []operand{poolArg}, []operand{regArg, poolArg}, 0, 1},
+	Load:   {"load", []operand{intArg}, nil, 0, 1},
+	Gload:  {"gload", []operand{intArg}, []operand{regArg, intArg}, 0, 1},
+	Fload:  {"fload", []operand{intArg}, []operand{regArg, regArg, intArg}, 1, 1}, // r1 = r2.fields[i]
+	Store:  {"store", []operand{intArg}, nil, 1, 0},
+	Gstore: {"gstore", []operand{intArg}, []operand{regArg, intArg}, 1, 0},
+	Fstore: {"fstore", []operand{intArg}, []operand{regArg, regArg, intArg}, 2, 0}, // r2.fields[i] = r1
+	Print:  {"print", none, []operand{regArg}, 1, 0},
+	Struct: {"struct", []operand{intArg}, []operand{regArg, intArg}, 0, 1},
+	Null:   {"null", none, []operand{regArg}, 0, 1},
+	Pop:    {"pop", none, nil, 1, 0},
+	Halt:   {"halt", none, none, 0, 0},
+	Move:   {"move", nil, []operand{regArg, regArg}, 0, 0},
+
+	Idiv:   {"idiv", none, nil, 2, 1},
+	Ineg:   {"ineg", none, nil, 1, 1},
+	Ile:    {"ile", none, nil, 2, 1},
+	Igt:    {"igt", none, nil, 2, 1},
+	Ige:    {"ige", none, nil, 2, 1},
+	Ine:    {"ine", none, nil, 2, 1},
+	Fdiv:   {"fdiv", none, nil, 2, 1},
+	Fneg:   {"fneg", none, nil, 1, 1},
+	Fle:    {"fle", none, nil, 2, 1},
+	Fgt:    {"fgt", none, nil, 2, 1},
+	Fge:    {"fge", none, nil, 2, 1},
+	Fne:    {"fne", none, nil, 2, 1},
+	Ctoi:   {"ctoi", none, nil, 1, 1},
+	Itoc:   {"itoc", none, nil, 1, 1},
+	Sadd:   {"sadd", none, nil, 2, 1},
+	Seq:    {"seq", none, nil, 2, 1},
+	Sne:    {"sne", none, nil, 2, 1},
+	Bconst: {"bconst", []operand{boolArg}, nil, 0, 1},
+	Not:    {"not", none, nil, 1, 1},
+	Beq:    {"beq", none, nil, 2, 1},
+	Bne:    {"bne", none, nil, 2, 1},
+	Dup:    {"dup", none, nil, 1, 2},
+
+	List:    {"list", []operand{intArg}, nil, varies, 1},
+	Lload:   {"lload", none, nil, 2, 1},
+	Lstore:  {"lstore", none, nil, 3, 0},
+	Llen:    {"llen", none, nil, 1, 1},
+	Lappend: {"lappend", none, nil, 2, 0},
+
+	Iaddc: {"iaddc", []operand{intArg}, nil, 1, 1},
+	Ladd:  {"ladd", []operand{intArg, intArg}, nil, 0, 1},
+	Linc:  {"linc", []operand{intArg, intArg}, nil, 0, 0},
+}
+
+// instruction is the mnemonic of an opcode and the operands it takes on a
+// machine.
+type instruction struct {
+	name     string
+	operands []operand
+}
+
+// instruction returns the instruction op of the machine m, if it has it.
+func (m Machine) instruction(op Opcode) (instruction, bool) {
+	if int(op) >= len(opcodeTable) {
+		return instruction{}, false
+	}
+	info := opcodeTable[op]
+	operands := info.stack
+	if m == Register {
+		operands = info.register
+	}
+	if operands == nil {
+		return instruction{}, false
+	}
+	return instruction{info.name, operands}, true
+}
+
+// opcodes maps mnemonics to their opcode.
+var opcodes = map[string]Opcode{}
+
+func init() {
+	for op, info := range opcodeTable {
+		if info.name != "" {
+			opcodes[info.name] = Opcode(op)
+		}
+	}
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeTable) && opcodeTable[op].name != "" {
+		return opcodeTable[op].name
+	}
+	return fmt.Sprintf("opcode(%d)", byte(op))
+}
+
+// Size returns the number of bytes of the instruction op of the machine m.
+func (m Machine) Size(op Opcode) int {
+	instr, _ := m.instruction(op)
+	return 1 + 4*len(instr.operands)
+}
+
+// Program is the code of the functions of a program, the constants they use
+// and the number of globals they share.
+type Program struct {
+	Machine Machine // the machine the code is for
+	Code    []byte
+	Pool    []any // float64, string or *Function
+	Globals int
+	Main    *Function // where the program starts, the start of Code if nil
+}
+
+// Function is a function of a program, the code of which starts at Addr.
+type Function struct {
+	Name   string
+	Args   int
+	Locals int
+	Addr   int
+}
+
+func (f *Function) String() string { return f.Name + "()" }
+
+// Operand returns the operand encoded at addr in the code.
+func (p *Program) Operand(addr int) int {
+	return int(int32(binary.BigEndian.Uint32(p.Code[addr:])))
+}
+
+// Functions returns the functions in the pool.
+func (p *Program) Functions() []*Function {
+	var funcs []*Function
+	for _, c := range p.Pool {
+		if f, ok := c.(*Function); ok {
+			funcs = append(funcs, f)
+		}
+	}
+	return funcs
+}
+
+// emit appends an operand to the code.
+func (p *Program) emit(v int) {
+	p.Code = binary.BigEndian.AppendUint32(p.Code, uint32(int32(v)))
+}
+
+// patch writes the operand at addr.
+func (p *Program) patch(addr, v int) {
+	binary.BigEndian.PutUint32(p.Code[addr:], uint32(int32(v)))
+}
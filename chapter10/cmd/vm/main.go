@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"example.com/bytecode"
+	"example.com/cymbol"
+)
+
+// example prints the factorial of 5
+const example = `.def fact: args=1, locals=0
+    load 0              // if n < 2 return 1
+    iconst 2
+    ilt
+    brf cont
+    iconst 1
+    ret
+cont:
+    load 0              // return n * fact(n - 1)
+    load 0
+    iconst 1
+    isub
+    call fact()
+    imul
+    ret
+.def main: args=0, locals=0
+    iconst 5
+    call fact()
+    print
+    halt
+`
+
+// registerExample is the example for the register machine
+const registerExample = `.def fact: args=1, locals=3
+    iconst r2, 2        // if n < 2 return 1
+    ilt r3, r1, r2
+    brf r3, cont
+    iconst r0, 1
+    ret
+cont:
+    iconst r2, 1        // return n * fact(n - 1)
+    isub r4, r1, r2
+    call fact(), r4
+    imul r0, r1, r0
+    ret
+.def main: args=0, locals=1
+    iconst r1, 5
+    call fact(), r1
+    print r0
+    halt
+`
+
+// cymbolExample is the example in Cymbol
+const cymbolExample = `int fact(int n) {
+    if (n < 2) return 1;
+    return n * fact(n - 1);
+}
+void main() { print(fact(5)); }
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+// run assembles the file named in args, or in if it's "-", or the example,
+// and runs it on the stack machine, or the register machine with -register.
+// With -cymbol the file is a Cymbol program, compiled for the stack machine,
+// and with -O a stack machine program is optimized.
+// With -d the program is disassembled instead of run, with -o it's written to
+// an object file, and with -count the number of instructions run is printed
+// once it ends, with -gc the statistics of the garbage collector, and with
+// -trace each instruction run is printed with the state it leaves the
+// machine in. A file that is an object file is loaded instead of assembled,
+// for the machine it was assembled for. With -verify the program is verified
+// before it's run, so it doesn't run at all if its code is wrong.
+func run(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("vm", flag.ContinueOnError)
+	disassemble := fs.Bool("d", false, "print the disassembly of the program")
+	register := fs.Bool("register", false, "assemble and run the program for the register machine")
+	count := fs.Bool("count", false, "print the number of instructions run")
+	gc := fs.Bool("gc", false, "print the statistics of the garbage collector")
+	compile := fs.Bool("cymbol", false, "compile the program from Cymbol")
+	optimize := fs.Bool("O", false, "optimize the stack machine program")
+	trace := fs.Bool("trace", false, "print each instruction run with the stack or registers and calls after it")
+	verify := fs.Bool("verify", false, "verify the program before running it")
+	object := fs.String("o", "", "write the program to an object `file` instead of running it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	machine, src := bytecode.Stack, example
+	switch {
+	case *compile && *register:
+		return errors.New("-cymbol and -register can't be used together: Cymbol is compiled for the stack machine")
+	case *compile:
+		src = cymbolExample
+	case *register:
+		machine, src = bytecode.Register, registerExample
+	}
+	switch name := fs.Arg(0); name {
+	case "":
+	case "-":
+		b, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		src = string(b)
+	default:
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		src = string(b)
+	}
+
+	var prog *bytecode.Program
+	var err error
+	switch {
+	case bytecode.IsObject([]byte(src)):
+		prog, err = bytecode.ReadObject(strings.NewReader(src))
+	case *compile:
+		prog, err = bytecode.Compile(src)
+	default:
+		prog, err = bytecode.Assemble(src, machine)
+	}
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	if *optimize {
+		prog = bytecode.Optimize(prog)
+	}
+	if *disassemble {
+		_, err := io.WriteString(out, bytecode.Disassemble(prog))
+		return err
+	}
+	if *object != "" {
+		return writeObject(*object, prog)
+	}
+	if *verify {
+		if err := bytecode.Verify(prog); err != nil {
+			return err
+		}
+	}
+	var n int
+	var stats bytecode.GCStats
+	if prog.Machine == bytecode.Register {
+		vm := bytecode.NewRegisterVM(prog)
+		vm.Stdout = out
+		if *trace {
+			vm.Trace = out
+		}
+		err = vm.Run()
+		n, stats = vm.Instructions, vm.GC
+	} else {
+		vm := bytecode.NewStackVM(prog)
+		vm.Stdout = out
+		if *trace {
+			vm.Trace = out
+		}
+		err = vm.Run()
+		n, stats = vm.Instructions, vm.GC
+	}
+	if *count {
+		fmt.Fprintf(out, "%d instructions\n", n)
+	}
+	if *gc {
+		fmt.Fprintf(out, "%d objects allocated, %d freed in %d collections, %d live, %d at most\n",
+			stats.Allocated, stats.Freed, stats.Collections, stats.Live, stats.Peak)
+	}
+	return err
+}
+
+func writeObject(name string, prog *bytecode.Program) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := bytecode.WriteObject(f, prog); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		args []string
+		in   string
+		want string
+	}{
+		{nil, "", "120\n"},
+		{[]string{"-"}, "sconst \"hi\"\nprint\n", "hi\n"},
+		{[]string{"-d", "-"}, "a: br a\n", "L0:\n    br L0\n"},
+		{[]string{"-register", "-count"}, "", "120\n41 instructions\n"},
+		{[]string{"-count"}, "", "120\n54 instructions\n"},
+		{[]string{"-cymbol"}, "", "120\n"},
+		{[]string{"-cymbol", "-O", "-count"}, "", "120\n50 instructions\n"},
+		{[]string{"-gc", "-"}, "struct 1\nsconst \"a\"\nsconst \"b\"\nsadd\nlist 2\nprint\n", "[{null}, \"ab\"]\n3 objects allocated, 0 freed in 0 collections, 3 live, 3 at most\n"},
+		{[]string{"-trace", "-"}, "iconst 1\nprint\n", "0000 iconst 1             stack=[1] calls=[main]\n1\n0005 print                stack=[] calls=[main]\n"},
+		{[]string{"-cymbol", "-d", "-"}, "void main() { print(1); }", ".def main: args=0, locals=0\n    iconst 1\n    print\n    ret\n"},
+	}
+	for _, tc := range cases {
+		var out strings.Builder
+		if err := run(tc.args, strings.NewReader(tc.in), &out); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != tc.want {
+			t.Error(cmp.Diff(got, tc.want))
+		}
+	}
+
+	obj := filepath.Join(t.TempDir(), "fact.o")
+	if err := run([]string{"-register", "-o", obj}, nil, &strings.Builder{}); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := run([]string{"-count", obj}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "120\n41 instructions\n"; got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	err := run([]string{"-"}, strings.NewReader("iconst 1\nprint\nprint\n"), &strings.Builder{})
+	if want := "0006 print: empty stack"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+
+	out.Reset()
+	err = run([]string{"-verify", "-"}, strings.NewReader("iconst 1\nprint\nprint\n"), &out)
+	if want := "0006 print: stack underflow: 0 values, popping 1"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("ran before verifying: %q", out.String())
+	}
+}
@@ -0,0 +1,564 @@
+package bytecode
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"example.com/types"
+)
+
+// Compiling Cymbol
+//
+// The compiler turns a checked Cymbol program into the assembly of the stack
+// machine, which the assembler turns into bytecode. Generating text keeps the
+// compiler simple, the assembler already has the labels, the constant pool
+// and the backpatching, and the code generated can be read:
+//
+//	int sq(int n) {              .def sq: args=1, locals=0
+//	    return n * n;                load 0
+//	}                                load 0
+//	                                 imul
+//	                                 ret
+//
+// An expression is compiled as its operands, then the instruction of its
+// operator, picked by the types package types computed: i * f is fmul once i
+// is promoted, which inserts an itof after the code of i. An if or a while
+// is compiled as its condition and a brf past the code it skips, a while
+// branching back to its condition at the end of its body.
+//
+// Each function gets a .def, its parameters being its first locals, then the
+// locals of all its blocks, each one in a slot of its own. Variables declared
+// without a value get their zero value, a new instance for a struct, as in
+// the interpreter of chapter 9. The globals are initialized at the start of
+// main, the function a program starts at, which is generated if the program
+// has none. print is the only builtin, compiled to the print instruction.
+//
+// The globals and functions of namespaces are compiled as the others, a
+// function getting the names of its namespaces in front of its own, joined
+// by underscores: f in namespace geo is geo_f, as the assembler has no
+// qualified names.
+//
+// Classes aren't compiled: calling a method needs the class of the object
+// at run time, which the machines don't keep.
+
+// Compile compiles the Cymbol program src to bytecode for the stack machine.
+func Compile(src string) (*Program, error) {
+	asm, err := Generate(src)
+	if err != nil {
+		return nil, err
+	}
+	return Assemble(asm, Stack)
+}
+
+// Generate returns the stack machine assembly of the Cymbol program src. The
+// error is the syntax error, the semantic errors all together, or the first
+// thing the compiler doesn't support.
+func Generate(src string) (asm string, err error) {
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return "", err
+	}
+	c, print, err := check(f)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*cymbol.Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	g := &generator{types: c, print: print, globals: map[symtab.Symbol]int{}}
+	g.file(f)
+	return g.out.String(), nil
+}
+
+// check resolves the symbols of f and checks their types, with print
+// defined. It returns print's symbol.
+func check(f *cymbol.File) (*types.ComputeTypes, *symtab.FunctionSymbol, error) {
+	table := symtab.NewSymbolTable()
+	print := symtab.NewFunctionSymbol("print", table.Globals.Resolve("void").(symtab.Type), table.Globals)
+	print.Define(symtab.NewVariableSymbol("v", types.Any))
+	table.Globals.Define(print)
+
+	defs, refs := symtab.TwoPass(table, f)
+	c := types.NewComputeTypes(table, refs.Refs)
+	c.Defs = defs.Defs
+	symtab.Walk(c, f)
+	symtab.Walk(types.NewCheckTypes(c, defs.Diagnostics), f)
+	if err := defs.Diagnostics.Err(); err != nil {
+		return nil, nil, err
+	}
+	return c, print, nil
+}
+
+type generator struct {
+	types   *types.ComputeTypes
+	print   *symtab.FunctionSymbol
+	globals map[symtab.Symbol]int
+	inits   []*cymbol.VarDecl // of the globals
+
+	out    strings.Builder
+	body   strings.Builder       // of the function being generated
+	locals map[symtab.Symbol]int // of the function being generated
+	labels int
+}
+
+func (g *generator) file(f *cymbol.File) {
+	funcs := g.decls(f.Decls, nil, map[string]*cymbol.FuncDecl{})
+	if len(g.globals) > 0 {
+		fmt.Fprintf(&g.out, ".globals %d\n", len(g.globals))
+	}
+	var main *cymbol.FuncDecl
+	for _, d := range funcs {
+		if g.funcName(d) == "main" {
+			main = d
+		}
+		g.function(d)
+	}
+	if main == nil {
+		g.locals = map[symtab.Symbol]int{}
+		g.initGlobals()
+		g.emit(Ret)
+		g.def("main", 0)
+	}
+}
+
+// decls numbers the globals among decls, and those of the namespaces among
+// them, and returns their functions, all in order. compiled has the functions
+// by the name they're compiled to.
+func (g *generator) decls(decls []cymbol.Decl, funcs []*cymbol.FuncDecl, compiled map[string]*cymbol.FuncDecl) []*cymbol.FuncDecl {
+	for _, d := range decls {
+		switch d := d.(type) {
+		case *cymbol.VarDecl:
+			g.globals[g.types.Defs[d.Name]] = len(g.globals)
+			g.inits = append(g.inits, d)
+		case *cymbol.FuncDecl:
+			name := g.funcName(d)
+			if prev, ok := compiled[name]; ok {
+				errorf(d.Name.Token.Pos, "%s compiles to %s, as %s declared at %v does", d.Name.Name, name, prev.Name.Name, prev.Name.Pos())
+			}
+			compiled[name] = d
+			funcs = append(funcs, d)
+		case *cymbol.ClassDecl:
+			errorf(d.Class, "classes aren't compiled")
+		case *cymbol.NamespaceDecl:
+			funcs = g.decls(d.Decls, funcs, compiled)
+		}
+	}
+	return funcs
+}
+
+// funcName returns the name the function d is compiled to.
+func (g *generator) funcName(d *cymbol.FuncDecl) string {
+	return compiledName(g.types.Defs[d.Name])
+}
+
+// compiledName returns the name of sym prefixed by the namespaces it's in.
+func compiledName(sym symtab.Symbol) string {
+	if ns, ok := sym.Scope().(*symtab.NamespaceSymbol); ok {
+		return compiledName(ns) + "_" + sym.Name()
+	}
+	return sym.Name()
+}
+
+func (g *generator) function(d *cymbol.FuncDecl) {
+	g.locals = map[symtab.Symbol]int{}
+	for _, p := range d.Params {
+		g.locals[g.types.Defs[p.Name]] = len(g.locals)
+	}
+	if g.funcName(d) == "main" {
+		if len(d.Params) > 0 {
+			errorf(d.Name.Token.Pos, "main can't have parameters")
+		}
+		g.initGlobals()
+	}
+	g.stmt(d.Body)
+	if fallsOff(types.NewCFG(d)) {
+		if g.types.Refs[d.Type].Name() != "void" {
+			errorf(d.Body.Rbrace, "missing return")
+		}
+		g.emit(Ret)
+	}
+	g.def(g.funcName(d), len(d.Params))
+}
+
+// def writes the function generated in body, with args parameters.
+func (g *generator) def(name string, args int) {
+	fmt.Fprintf(&g.out, ".def %s: args=%d, locals=%d\n", name, args, len(g.locals)-args)
+	g.out.WriteString(g.body.String())
+	g.body.Reset()
+}
+
+func (g *generator) initGlobals() {
+	for _, d := range g.inits {
+		g.varDecl(d)
+	}
+}
+
+// fallsOff tells whether the end of a function can be reached without
+// returning: if the exit of its graph is reached from a block that doesn't
+// end in a return.
+func fallsOff(cfg *types.CFG) bool {
+	reached := map[*types.BasicBlock]bool{}
+	var reach func(b *types.BasicBlock)
+	reach = func(b *types.BasicBlock) {
+		if reached[b] {
+			return
+		}
+		reached[b] = true
+		for _, s := range b.Succs {
+			reach(s)
+		}
+	}
+	reach(cfg.Entry)
+	for _, b := range cfg.Exit.Preds {
+		if !reached[b] {
+			continue
+		}
+		if len(b.Nodes) == 0 {
+			return true
+		}
+		if _, ok := b.Nodes[len(b.Nodes)-1].(*cymbol.ReturnStmt); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) stmt(s cymbol.Stmt) {
+	switch s := s.(type) {
+	case *cymbol.Block:
+		for _, s := range s.Stmts {
+			g.stmt(s)
+		}
+	case *cymbol.VarDecl:
+		g.locals[g.types.Defs[s.Name]] = len(g.locals)
+		g.varDecl(s)
+	case *cymbol.StructDecl:
+		// a type, nothing runs
+	case *cymbol.IfStmt:
+		els, end := g.label(), g.label()
+		g.expr(s.Cond)
+		g.emit(Brf, els)
+		g.stmt(s.Then)
+		if s.Else != nil {
+			g.emit(Br, end)
+		}
+		g.mark(els)
+		if s.Else != nil {
+			g.stmt(s.Else)
+			g.mark(end)
+		}
+	case *cymbol.WhileStmt:
+		cond, end := g.label(), g.label()
+		g.mark(cond)
+		g.expr(s.Cond)
+		g.emit(Brf, end)
+		g.stmt(s.Body)
+		g.emit(Br, cond)
+		g.mark(end)
+	case *cymbol.ReturnStmt:
+		if s.Value != nil {
+			g.expr(s.Value)
+		}
+		g.emit(Ret)
+	case *cymbol.AssignStmt:
+		switch target := s.Target.(type) {
+		case *cymbol.Ident:
+			g.expr(s.Value)
+			g.store(g.variable(target))
+		case *cymbol.MemberExpr:
+			if v, ok := g.qualified(target); ok {
+				g.expr(s.Value)
+				g.store(v)
+				break
+			}
+			g.expr(target.X)
+			g.expr(s.Value)
+			g.emit(Fstore, g.field(target))
+		default:
+			errorf(s.Target.Pos(), "cannot assign to %v", s.Target)
+		}
+	case *cymbol.ExprStmt:
+		g.expr(s.X)
+		if t := g.types.Types.Get(s.X); t != nil && t.Name() != "void" {
+			g.emit(Pop)
+		}
+	}
+}
+
+// varDecl stores the value of the variable d declares, its zero value if it
+// has none.
+func (g *generator) varDecl(d *cymbol.VarDecl) {
+	v := g.types.Defs[d.Name]
+	if d.Value != nil {
+		g.expr(d.Value)
+	} else {
+		g.zero(v.Type(), nil)
+	}
+	g.store(v)
+}
+
+// zero pushes the zero value of type t. The fields of a struct are set to
+// theirs, except for the struct types being made, which are null: the making
+// would never end otherwise.
+func (g *generator) zero(t symtab.Type, making []symtab.Type) {
+	if s, ok := t.(*symtab.StructSymbol); ok {
+		for _, m := range making {
+			if m == t {
+				g.emit(Null)
+				return
+			}
+		}
+		fields := fields(s)
+		g.emit(Struct, len(fields))
+		for i, f := range fields {
+			g.emit(Dup)
+			g.zero(f.Type(), append(making, t))
+			g.emit(Fstore, i)
+		}
+		return
+	}
+	switch t.Name() {
+	case "int":
+		g.emit(Iconst, 0)
+	case "float":
+		g.emit(Fconst, "0.0")
+	case "char":
+		g.emit(Iconst, 0)
+		g.emit(Itoc)
+	case "boolean":
+		g.emit(Bconst, false)
+	case "string":
+		g.emit(Sconst, `""`)
+	default:
+		g.emit(Null)
+	}
+}
+
+// fields returns the fields of s, leaving out the structs declared in it.
+func fields(s *symtab.StructSymbol) []symtab.Symbol {
+	var fields []symtab.Symbol
+	for _, f := range s.Fields() {
+		if _, ok := f.(*symtab.VariableSymbol); ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// field returns the index of the field x accesses.
+func (g *generator) field(x *cymbol.MemberExpr) int {
+	s, ok := g.types.Types.Get(x.X).(*symtab.StructSymbol)
+	if !ok {
+		errorf(x.Member.Token.Pos, "%v is not a struct field", x)
+	}
+	for i, f := range fields(s) {
+		if f == g.types.Refs[x] {
+			return i
+		}
+	}
+	errorf(x.Member.Token.Pos, "%v is not a struct field", x)
+	return 0
+}
+
+// variable returns the symbol of the variable id names.
+func (g *generator) variable(id *cymbol.Ident) symtab.Symbol {
+	v, ok := g.types.Refs[id].(*symtab.VariableSymbol)
+	if !ok {
+		errorf(id.Token.Pos, "%s is not a variable", id.Name)
+	}
+	return v
+}
+
+// qualified returns the variable of a namespace x names, if x is the name of
+// the variable qualified by its namespace's.
+func (g *generator) qualified(x *cymbol.MemberExpr) (symtab.Symbol, bool) {
+	if _, ok := g.types.Refs[x.X].(*symtab.NamespaceSymbol); !ok {
+		return nil, false
+	}
+	v, ok := g.types.Refs[x].(*symtab.VariableSymbol)
+	if !ok {
+		errorf(x.Member.Token.Pos, "%v is not a variable", x)
+	}
+	return v, true
+}
+
+func (g *generator) load(v symtab.Symbol) {
+	if i, ok := g.locals[v]; ok {
+		g.emit(Load, i)
+		return
+	}
+	g.emit(Gload, g.globals[v])
+}
+
+func (g *generator) store(v symtab.Symbol) {
+	if i, ok := g.locals[v]; ok {
+		g.emit(Store, i)
+		return
+	}
+	g.emit(Gstore, g.globals[v])
+}
+
+// expr pushes the value of x, converted to the type it's promoted to.
+func (g *generator) expr(x cymbol.Expr) {
+	g.value(x)
+	if to, ok := g.types.Promotions.Lookup(x); ok {
+		g.convert(g.types.Types.Get(x).Name(), to.Name())
+	}
+}
+
+// convert converts the value on top of the stack from the type named from to
+// the one named to.
+func (g *generator) convert(from, to string) {
+	if from == "char" {
+		g.emit(Ctoi)
+	}
+	if to == "float" {
+		g.emit(Itof)
+	}
+}
+
+func (g *generator) value(x cymbol.Expr) {
+	switch x := x.(type) {
+	case *cymbol.IntLit:
+		if x.Value < math.MinInt32 || x.Value > math.MaxInt32 {
+			errorf(x.Token.Pos, "integer %d too large to compile", x.Value)
+		}
+		g.emit(Iconst, x.Value)
+	case *cymbol.FloatLit:
+		g.emit(Fconst, formatFloat(x.Value))
+	case *cymbol.CharLit:
+		g.emit(Cconst, quote(string(x.Value), '\''))
+	case *cymbol.StringLit:
+		g.emit(Sconst, quote(x.Value, '"'))
+	case *cymbol.BoolLit:
+		g.emit(Bconst, x.Value)
+	case *cymbol.Ident:
+		g.load(g.variable(x))
+	case *cymbol.MemberExpr:
+		if v, ok := g.qualified(x); ok {
+			g.load(v)
+			break
+		}
+		g.expr(x.X)
+		g.emit(Fload, g.field(x))
+	case *cymbol.UnaryExpr:
+		g.expr(x.X)
+		switch {
+		case x.Op.Type == cymbol.Not:
+			g.emit(Not)
+		case g.types.Types.Get(x).Name() == "float":
+			g.emit(Fneg)
+		default:
+			g.emit(Ineg)
+		}
+	case *cymbol.BinaryExpr:
+		g.binary(x)
+	case *cymbol.CallExpr:
+		g.call(x)
+	default:
+		errorf(x.Pos(), "%v isn't compiled", x)
+	}
+}
+
+// operators are the instructions of the binary operators, by the type of their
+// operands, chars being operated on as ints
+var operators = map[string]map[cymbol.TokenType]Opcode{
+	"int": {
+		cymbol.Plus: Iadd, cymbol.Minus: Isub, cymbol.Star: Imul, cymbol.Slash: Idiv,
+		cymbol.Lt: Ilt, cymbol.Le: Ile, cymbol.Gt: Igt, cymbol.Ge: Ige,
+		cymbol.Eq: Ieq, cymbol.Ne: Ine,
+	},
+	"float": {
+		cymbol.Plus: Fadd, cymbol.Minus: Fsub, cymbol.Star: Fmul, cymbol.Slash: Fdiv,
+		cymbol.Lt: Flt, cymbol.Le: Fle, cymbol.Gt: Fgt, cymbol.Ge: Fge,
+		cymbol.Eq: Feq, cymbol.Ne: Fne,
+	},
+	"string":  {cymbol.Plus: Sadd, cymbol.Eq: Seq, cymbol.Ne: Sne},
+	"boolean": {cymbol.Eq: Beq, cymbol.Ne: Bne},
+}
+
+func (g *generator) binary(x *cymbol.BinaryExpr) {
+	operands := g.operandType(x.X)
+	g.expr(x.X)
+	if operands == "char" {
+		g.emit(Ctoi)
+	}
+	g.expr(x.Y)
+	if operands == "char" {
+		g.emit(Ctoi)
+		operands = "int"
+	}
+	instr, ok := operators[operands][x.Op.Type]
+	if !ok {
+		errorf(x.Op.Pos, "%v isn't compiled", x)
+	}
+	g.emit(instr)
+	if g.types.Types.Get(x).Name() == "char" {
+		g.emit(Itoc)
+	}
+}
+
+// operandType returns the name of the type x has once promoted.
+func (g *generator) operandType(x cymbol.Expr) string {
+	if to, ok := g.types.Promotions.Lookup(x); ok {
+		return to.Name()
+	}
+	return g.types.Types.Get(x).Name()
+}
+
+func (g *generator) call(x *cymbol.CallExpr) {
+	f, ok := g.types.Refs[x.Fun].(*symtab.FunctionSymbol)
+	if !ok {
+		errorf(x.Fun.Pos(), "cannot call %v", x.Fun)
+	}
+	if _, ok := f.Scope().(*symtab.ClassSymbol); ok {
+		errorf(x.Fun.Pos(), "methods aren't compiled")
+	}
+	for _, arg := range x.Args {
+		g.expr(arg)
+	}
+	if f == g.print {
+		g.emit(Print)
+		return
+	}
+	g.emit(Call, compiledName(f)+"()")
+}
+
+// label returns a new label.
+func (g *generator) label() string {
+	g.labels++
+	return fmt.Sprintf("L%d", g.labels)
+}
+
+// mark defines the label l at the next instruction.
+func (g *generator) mark(l string) {
+	fmt.Fprintf(&g.body, "%s:\n", l)
+}
+
+// emit writes the instruction op with its operands, written as the
+// assembler reads them.
+func (g *generator) emit(op Opcode, args ...any) {
+	instr, ok := Stack.instruction(op)
+	if !ok || len(args) != len(instr.operands) {
+		panic(fmt.Sprintf("emitting %v with operands %v", op, args))
+	}
+	g.body.WriteString("    " + instr.name)
+	for i, arg := range args {
+		if i == 0 {
+			g.body.WriteString(" ")
+		} else {
+			g.body.WriteString(", ")
+		}
+		fmt.Fprint(&g.body, arg)
+	}
+	g.body.WriteString("\n")
+}
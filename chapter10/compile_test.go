@@ -0,0 +1,226 @@
+package bytecode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"fact",
+			`int fact(int n) {
+    if (n < 2) return 1;
+    return n * fact(n - 1);
+}
+void main() { print(fact(10)); }`,
+			"3628800\n",
+		},
+		{
+			"globals",
+			`int x = 1;
+int y = x + 1;
+void inc() { x = x + y; }
+void main() { inc(); inc(); print(x); }`,
+			"5\n",
+		},
+		{
+			"namespaces",
+			`namespace counter {
+    int n = 10;
+    int next() { n = n + 1; return n; }
+    namespace reset { int to = 1; void set() { n = to; } }
+}
+import counter;
+int next() { return 0; }
+void main() { counter.next(); print(counter.next()); reset.set(); print(n + next()); counter.n = 7; print(counter.n); }`,
+			"12\n1\n7\n",
+		},
+		{
+			"no main",
+			`int x = 2; float f = x * 1.5; string s = "f=" + "3"; boolean b = f == 3;`,
+			"",
+		},
+		{
+			"while",
+			`void main() {
+    int i = 0;
+    int sum = 0;
+    while (i <= 10) {
+        if (i / 2 * 2 == i) sum = sum + i; else { }
+        i = i + 1;
+    }
+    print(sum);
+}`,
+			"30\n",
+		},
+		{
+			"operators",
+			`void main() {
+    print(7 / 2);
+    print(7.0 / 2);
+    print(-3 - -2);
+    print(-1.5);
+    print(1 > 2);
+    print(2.5 >= 2);
+    print(1 != 1.0);
+    print('a' < 'b');
+    print('a' + 1);
+    print('b' - 'a');
+    print("a" + "b");
+    print("a" != "b");
+    print(!(true == false));
+    print(true != true);
+    print('\n' == 10);
+}`,
+			"3\n3.5\n-1\n-1.5\nfalse\ntrue\nfalse\ntrue\n98\n'\\x01'\nab\ntrue\ntrue\nfalse\ntrue\n",
+		},
+		{
+			"zero values",
+			`struct node { int v; node next; struct pair { float f; char c; }; pair p; };
+void main() {
+    int i; float f; boolean b; string s; char c;
+    print(i); print(f); print(b); print(s + "."); print(c);
+    node n;
+    print(n);
+    n.next = n;
+    n.p.f = 2;
+    print(n.next.p.f);
+}`,
+			"0\n0.0\nfalse\n.\n'\\x00'\n{0, null, {0.0, '\\x00'}}\n2.0\n",
+		},
+		{
+			"promotions",
+			`float half(float x) { return x / 2; }
+float twice(int n) { return n * 2; }
+void main() {
+    float f = 'a';
+    print(f);
+    print(half(3));
+    print(twice('a'));
+}`,
+			"97.0\n1.5\n194.0\n",
+		},
+		{
+			"calls as statements",
+			`int g = 0;
+int next() { g = g + 1; return g; }
+void main() { next(); next(); 1 + 2; print(next()); }`,
+			"3\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Compile(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the optimized program runs the same
+			for _, prog := range []*Program{prog, Optimize(prog)} {
+				if err := Verify(prog); err != nil {
+					t.Fatal(err)
+				}
+				var out strings.Builder
+				vm := NewStackVM(prog)
+				vm.Stdout = &out
+				if err := vm.Run(); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+					t.Error(diff)
+				}
+				if len(vm.stack) != 0 {
+					t.Errorf("%d values left on the stack", len(vm.stack))
+				}
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	asm, err := Generate(`int x;
+int sq(int n) {
+    return n * n;
+}
+void main() {
+    while (x < 3) x = x + 1;
+    if (x == 3) print(sq(x)); else print("no");
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `.globals 1
+.def sq: args=1, locals=0
+    load 0
+    load 0
+    imul
+    ret
+.def main: args=0, locals=0
+    iconst 0
+    gstore 0
+L1:
+    gload 0
+    iconst 3
+    ilt
+    brf L2
+    gload 0
+    iconst 1
+    iadd
+    gstore 0
+    br L1
+L2:
+    gload 0
+    iconst 3
+    ieq
+    brf L3
+    gload 0
+    call sq()
+    print
+    br L4
+L3:
+    sconst "no"
+    print
+L4:
+    ret
+`
+	if diff := cmp.Diff(want, asm); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"int x = ;", "1:9: syntax error: expecting"},
+		{"int x = true;", "1:9: cannot use true (boolean) as int value in initialization"},
+		{"class A { };", "1:1: classes aren't compiled"},
+		{"int f(int n) { if (n > 0) return n; }", "1:37: missing return"},
+		{"int f() { while (true) { } }", "1:28: missing return"},
+		{"void main(int n) { }", "1:6: main can't have parameters"},
+		{"namespace a { void b_c() { } } namespace a_b { void c() { } }", "1:53: c compiles to a_b_c, as b_c declared at 1:20 does"},
+		{"void main() { print(2147483648); }", "1:21: integer 2147483648 too large to compile"},
+	}
+	for _, tc := range cases {
+		_, err := Compile(tc.src)
+		if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%q: got %v, want %s", tc.src, err, tc.want)
+		}
+	}
+
+	// a runtime error
+	prog, err := Compile("int zero = 0; void main() { print(1 / zero); }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewStackVM(prog).Run(); err == nil || err.Error() != "0020 idiv: division by zero" {
+		t.Errorf("got %v, want division by zero", err)
+	}
+}
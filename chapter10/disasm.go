@@ -0,0 +1,147 @@
+package bytecode
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Disassembler
+//
+// Disassembling goes the other way, from bytecode back to assembly, which is
+// how to look at the code a compiler generates. The disassembly of a program
+// assembles to the same program: the addresses branched to get labels named
+// after them, and the functions their .def where their code starts:
+//
+//	.def main: args=0, locals=0
+//	L0:
+//	    iconst 1
+//	    brt L0
+//
+// The comments and the label names of the source are lost.
+
+// Disassemble returns the assembly of the program p, which assembles for
+// p.Machine.
+func Disassemble(p *Program) string {
+	var b strings.Builder
+	if p.Globals > 0 {
+		fmt.Fprintf(&b, ".globals %d\n", p.Globals)
+	}
+	funcs := p.Functions()
+	slices.SortStableFunc(funcs, func(f, g *Function) int { return cmp.Compare(f.Addr, g.Addr) })
+	labels := p.labels()
+	for addr := 0; addr <= len(p.Code); {
+		for len(funcs) > 0 && funcs[0].Addr <= addr {
+			f := funcs[0]
+			fmt.Fprintf(&b, ".def %s: args=%d, locals=%d\n", f.Name, f.Args, f.Locals)
+			funcs = funcs[1:]
+		}
+		if labels[addr] {
+			fmt.Fprintf(&b, "L%d:\n", addr)
+		}
+		if addr == len(p.Code) {
+			break
+		}
+		text, next := p.Instr(addr)
+		fmt.Fprintf(&b, "    %s\n", text)
+		addr = next
+	}
+	return b.String()
+}
+
+// Instr returns the assembly of the instruction at addr, and the address of
+// the next one.
+func (p *Program) Instr(addr int) (string, int) {
+	op := Opcode(p.Code[addr])
+	instr, ok := p.Machine.instruction(op)
+	if !ok {
+		return op.String(), addr + 1
+	}
+	if p.Machine.Size(op) > len(p.Code)-addr {
+		return op.String(), len(p.Code)
+	}
+	var b strings.Builder
+	b.WriteString(op.String())
+	for i, kind := range instr.operands {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(p.operandText(kind, p.Operand(addr+1+4*i)))
+	}
+	return b.String(), addr + p.Machine.Size(op)
+}
+
+// operandText returns how the operand v of the given kind is written.
+func (p *Program) operandText(kind operand, v int) string {
+	switch kind {
+	case charArg:
+		return quote(string(rune(v)), '\'')
+	case addrArg:
+		return "L" + strconv.Itoa(v)
+	case regArg:
+		return "r" + strconv.Itoa(v)
+	case boolArg:
+		return strconv.FormatBool(v != 0)
+	case funcArg, poolArg:
+		if v < 0 || v >= len(p.Pool) {
+			break
+		}
+		switch c := p.Pool[v].(type) {
+		case *Function:
+			return c.String()
+		case string:
+			return quote(c, '"')
+		case float64:
+			return formatFloat(c)
+		}
+	}
+	return strconv.Itoa(v)
+}
+
+// labels returns the addresses branched to.
+func (p *Program) labels() map[int]bool {
+	labels := map[int]bool{}
+	for addr := 0; addr < len(p.Code); addr += p.Machine.Size(Opcode(p.Code[addr])) {
+		op := Opcode(p.Code[addr])
+		instr, ok := p.Machine.instruction(op)
+		if !ok || p.Machine.Size(op) > len(p.Code)-addr {
+			continue
+		}
+		for i, kind := range instr.operands {
+			if kind == addrArg {
+				labels[p.Operand(addr+1+4*i)] = true
+			}
+		}
+	}
+	return labels
+}
+
+// quote writes s as a literal between the quotes q, escaping what the cymbol
+// lexer expects escaped.
+func quote(s string, q rune) string {
+	var b strings.Builder
+	b.WriteRune(q)
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == q:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case !unicode.IsPrint(r):
+			quoted := strconv.QuoteRune(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune(q)
+	return b.String()
+}
@@ -0,0 +1,255 @@
+package bytecode
+
+// Dispatch
+//
+// Most of the time a bytecode interpreter spends goes into dispatching the
+// instructions: going from an opcode to the code that executes it. The
+// book's machines switch on the opcode, which Go compiles to comparisons or a
+// jump table, and each instruction ends jumping back to the top of the loop
+// to switch again.
+//
+// C interpreters use threaded code instead: each instruction jumps directly
+// to the next one's code, found in a table by its opcode, which saves the
+// jump back and gives the processor a branch per instruction to predict
+// instead of one for all of them. Go has no computed gotos, so the stack
+// machine does the closest it can by default: it calls the function of the
+// opcode in a table, stackTable. Each instruction is a method of its own,
+// execIadd and so on, which the switch calls too with SwitchDispatch, so
+// the two only differ in how they dispatch. BenchmarkDispatch compares them:
+//
+//	go test -bench Dispatch
+//
+// A call through a table can't be inlined, while the switch's calls are,
+// so which is faster depends on the compiler and the processor: measure.
+
+// Dispatch is how the stack machine dispatches instructions.
+type Dispatch int
+
+const (
+	TableDispatch  Dispatch = iota // calling the method of the opcode in a table
+	SwitchDispatch                 // switching on the opcode
+)
+
+// stackTable are the methods of the stack machine's opcodes.
+var stackTable = [256]func(*StackVM){
+	Iadd:    (*StackVM).execIadd,
+	Isub:    (*StackVM).execIsub,
+	Imul:    (*StackVM).execImul,
+	Ilt:     (*StackVM).execIlt,
+	Ieq:     (*StackVM).execIeq,
+	Fadd:    (*StackVM).execFadd,
+	Fsub:    (*StackVM).execFsub,
+	Fmul:    (*StackVM).execFmul,
+	Flt:     (*StackVM).execFlt,
+	Feq:     (*StackVM).execFeq,
+	Itof:    (*StackVM).execItof,
+	Call:    (*StackVM).execCall,
+	Ret:     (*StackVM).execRet,
+	Br:      (*StackVM).execBr,
+	Brt:     (*StackVM).execBrt,
+	Brf:     (*StackVM).execBrf,
+	Cconst:  (*StackVM).execCconst,
+	Iconst:  (*StackVM).execIconst,
+	Fconst:  (*StackVM).execFconst,
+	Sconst:  (*StackVM).execSconst,
+	Load:    (*StackVM).execLoad,
+	Gload:   (*StackVM).execGload,
+	Fload:   (*StackVM).execFload,
+	Store:   (*StackVM).execStore,
+	Gstore:  (*StackVM).execGstore,
+	Fstore:  (*StackVM).execFstore,
+	Print:   (*StackVM).execPrint,
+	Struct:  (*StackVM).execStruct,
+	Null:    (*StackVM).execNull,
+	Pop:     (*StackVM).execPop,
+	Halt:    (*StackVM).execHalt,
+	Idiv:    (*StackVM).execIdiv,
+	Ineg:    (*StackVM).execIneg,
+	Ile:     (*StackVM).execIle,
+	Igt:     (*StackVM).execIgt,
+	Ige:     (*StackVM).execIge,
+	Ine:     (*StackVM).execIne,
+	Fdiv:    (*StackVM).execFdiv,
+	Fneg:    (*StackVM).execFneg,
+	Fle:     (*StackVM).execFle,
+	Fgt:     (*StackVM).execFgt,
+	Fge:     (*StackVM).execFge,
+	Fne:     (*StackVM).execFne,
+	Ctoi:    (*StackVM).execCtoi,
+	Itoc:    (*StackVM).execItoc,
+	Sadd:    (*StackVM).execSadd,
+	Seq:     (*StackVM).execSeq,
+	Sne:     (*StackVM).execSne,
+	Bconst:  (*StackVM).execBconst,
+	Not:     (*StackVM).execNot,
+	Beq:     (*StackVM).execBeq,
+	Bne:     (*StackVM).execBne,
+	Dup:     (*StackVM).execDup,
+	List:    (*StackVM).execList,
+	Lload:   (*StackVM).execLload,
+	Lstore:  (*StackVM).execLstore,
+	Llen:    (*StackVM).execLlen,
+	Lappend: (*StackVM).execLappend,
+	Iaddc:   (*StackVM).execIaddc,
+	Ladd:    (*StackVM).execLadd,
+	Linc:    (*StackVM).execLinc,
+}
+
+func (vm *StackVM) tableLoop() {
+	code := vm.prog.Code
+	for vm.ip >= 0 && vm.ip < len(code) {
+		vm.step()
+		op := code[vm.ip]
+		vm.ip++
+		exec := stackTable[op]
+		if exec == nil {
+			vm.fail("invalid opcode %d", op)
+		}
+		exec(vm)
+		vm.next()
+	}
+}
+
+func (vm *StackVM) switchLoop() {
+	code := vm.prog.Code
+	for vm.ip >= 0 && vm.ip < len(code) {
+		vm.step()
+		op := Opcode(code[vm.ip])
+		vm.ip++
+		switch op {
+		case Iadd:
+			vm.execIadd()
+		case Isub:
+			vm.execIsub()
+		case Imul:
+			vm.execImul()
+		case Ilt:
+			vm.execIlt()
+		case Ieq:
+			vm.execIeq()
+		case Fadd:
+			vm.execFadd()
+		case Fsub:
+			vm.execFsub()
+		case Fmul:
+			vm.execFmul()
+		case Flt:
+			vm.execFlt()
+		case Feq:
+			vm.execFeq()
+		case Itof:
+			vm.execItof()
+		case Call:
+			vm.execCall()
+		case Ret:
+			vm.execRet()
+		case Br:
+			vm.execBr()
+		case Brt:
+			vm.execBrt()
+		case Brf:
+			vm.execBrf()
+		case Cconst:
+			vm.execCconst()
+		case Iconst:
+			vm.execIconst()
+		case Fconst:
+			vm.execFconst()
+		case Sconst:
+			vm.execSconst()
+		case Load:
+			vm.execLoad()
+		case Gload:
+			vm.execGload()
+		case Fload:
+			vm.execFload()
+		case Store:
+			vm.execStore()
+		case Gstore:
+			vm.execGstore()
+		case Fstore:
+			vm.execFstore()
+		case Print:
+			vm.execPrint()
+		case Struct:
+			vm.execStruct()
+		case Null:
+			vm.execNull()
+		case Pop:
+			vm.execPop()
+		case Halt:
+			vm.execHalt()
+		case Idiv:
+			vm.execIdiv()
+		case Ineg:
+			vm.execIneg()
+		case Ile:
+			vm.execIle()
+		case Igt:
+			vm.execIgt()
+		case Ige:
+			vm.execIge()
+		case Ine:
+			vm.execIne()
+		case Fdiv:
+			vm.execFdiv()
+		case Fneg:
+			vm.execFneg()
+		case Fle:
+			vm.execFle()
+		case Fgt:
+			vm.execFgt()
+		case Fge:
+			vm.execFge()
+		case Fne:
+			vm.execFne()
+		case Ctoi:
+			vm.execCtoi()
+		case Itoc:
+			vm.execItoc()
+		case Sadd:
+			vm.execSadd()
+		case Seq:
+			vm.execSeq()
+		case Sne:
+			vm.execSne()
+		case Bconst:
+			vm.execBconst()
+		case Not:
+			vm.execNot()
+		case Beq:
+			vm.execBeq()
+		case Bne:
+			vm.execBne()
+		case Dup:
+			vm.execDup()
+		case List:
+			vm.execList()
+		case Lload:
+			vm.execLload()
+		case Lstore:
+			vm.execLstore()
+		case Llen:
+			vm.execLlen()
+		case Lappend:
+			vm.execLappend()
+		case Iaddc:
+			vm.execIaddc()
+		case Ladd:
+			vm.execLadd()
+		case Linc:
+			vm.execLinc()
+		default:
+			vm.fail("invalid opcode %d", byte(op))
+		}
+		vm.next()
+	}
+}
+
+// next does what's due between two instructions.
+func (vm *StackVM) next() {
+	if vm.Trace != nil {
+		vm.trace(vm.Trace, "stack", vm.stack)
+	}
+	vm.collect(vm.stack)
+}
@@ -0,0 +1,236 @@
+package bytecode
+
+// Heap
+//
+// Strings, lists and structs are objects on the heap of the machine running
+// them, and values refer to them, so they're shared: storing a struct or a
+// list stores the reference, not a copy. The machine allocates each object
+// it makes, struct 2 or sadd, on its heap, and frees those the program can't
+// use anymore with a mark-and-sweep collector.
+//
+// The heap is the list of the objects allocated. Once it reaches a
+// threshold, between two instructions, the collector marks the objects the
+// program can reach from its roots, the globals, the operand stack and the
+// arguments, locals or registers of the calls running, then the objects the
+// marked ones hold, until there are no more. It sweeps the heap after,
+// dropping the objects left unmarked, for Go to reclaim their memory. The
+// threshold becomes twice the size of the objects left, so the collector runs
+// less often the more the program keeps, and the time it takes stays
+// proportional to the objects allocated.
+//
+// The size of an object is a cell, and a cell for each field of a struct and
+// element of a list, or for each byte of a string: what it takes of the
+// memory, roughly, and what Limits.Heap bounds. The size of an object is
+// checked before it's made, so that a string doubling in a loop fails with
+// ErrHeap before its bytes are allocated. If it's over the limit, the
+// collector runs first, with the values the instruction took off the stack
+// as roots too, and the program only fails if it still is.
+//
+// The strings of the pool aren't on the heap: the machine makes them when
+// the program starts, and they live as long as it runs.
+//
+// Lists are for the stack machine, which makes them from the values on top
+// of its stack:
+//
+//	iconst 1
+//	iconst 2
+//	list 2          // [1, 2]
+//	dup
+//	iconst 3
+//	lappend         // [1, 2, 3]
+//	iconst 0
+//	lload           // 1
+
+// String is a string on the heap.
+type String struct {
+	header
+	Value string
+}
+
+// ListSpace is a list on the heap.
+type ListSpace struct {
+	header
+	Elements []any
+}
+
+// header is what the collector keeps in each object.
+type header struct {
+	marked bool
+}
+
+func (h *header) gc() *header { return h }
+
+// object is an object on the heap.
+type object interface {
+	gc() *header
+	refs() []any // the values it holds
+}
+
+func (*String) refs() []any        { return nil }
+func (l *ListSpace) refs() []any   { return l.Elements }
+func (s *StructSpace) refs() []any { return s.Fields }
+
+// size returns the cells of an object, see above.
+func size(o object) int {
+	if s, ok := o.(*String); ok {
+		return 1 + len(s.Value)
+	}
+	return 1 + len(o.refs())
+}
+
+// GCStats are the statistics of the collector.
+type GCStats struct {
+	Allocated   int // objects allocated
+	Freed       int // objects freed
+	Collections int // times the collector ran
+	Live        int // objects on the heap when the program ended
+	Peak        int // most objects on the heap at once
+	PeakSize    int // largest size of the heap, in cells
+}
+
+// minHeap is the least size of the heap that makes the collector run.
+const minHeap = 1024
+
+// heap is the memory of the objects of a program.
+type heap struct {
+	objects []object
+	size    int // cells of the objects
+	next    int // the size of the heap that makes the collector run
+	limit   int // the most cells, none if 0
+	stats   GCStats
+}
+
+func (h *heap) reset(limit int) {
+	clear(h.objects)
+	h.objects = h.objects[:0]
+	h.size = 0
+	h.limit = limit
+	h.stats = GCStats{}
+	h.schedule()
+}
+
+// schedule sets the size of the heap that makes the collector run next,
+// twice the size left.
+func (h *heap) schedule() {
+	h.next = max(2*h.size, minHeap)
+}
+
+// allocate puts o on the heap, which reserve made room for.
+func (h *heap) allocate(o object) {
+	h.objects = append(h.objects, o)
+	h.stats.Allocated++
+	h.stats.Peak = max(h.stats.Peak, len(h.objects))
+	h.grow(size(o))
+}
+
+// grow adds n cells to the size of the heap, for an object allocated or
+// growing.
+func (h *heap) grow(n int) {
+	h.size += n
+	h.stats.PeakSize = max(h.stats.PeakSize, h.size)
+}
+
+// due tells whether the collector should run.
+func (h *heap) due() bool {
+	return h.size >= h.next
+}
+
+// fits tells whether n more cells fit under the limit.
+func (h *heap) fits(n int) bool {
+	return h.limit <= 0 || n <= h.limit-h.size
+}
+
+// collect frees the objects that can't be reached from roots.
+func (h *heap) collect(roots []any) {
+	work := roots
+	for len(work) > 0 {
+		v := work[len(work)-1]
+		work = work[:len(work)-1]
+		o, ok := v.(object)
+		if !ok || o.gc().marked {
+			continue
+		}
+		o.gc().marked = true
+		work = append(work, o.refs()...)
+	}
+	live := h.objects[:0]
+	h.size = 0
+	for _, o := range h.objects {
+		if o.gc().marked {
+			o.gc().marked = false
+			live = append(live, o)
+			h.size += size(o)
+		}
+	}
+	clear(h.objects[len(live):])
+	h.stats.Freed += len(h.objects) - len(live)
+	h.stats.Collections++
+	h.objects = live
+	h.schedule()
+}
+
+// statistics returns the statistics of the collector so far.
+func (h *heap) statistics() GCStats {
+	s := h.stats
+	s.Live = len(h.objects)
+	return s
+}
+
+// collect runs the collector if it's due, with the values of operands as
+// roots on top of the globals and the calls running.
+func (m *machine) collect(operands []any) {
+	if m.heap.due() {
+		m.heap.collect(m.roots(operands))
+	}
+}
+
+// roots returns the values the program can use: the globals, the locals of
+// the calls running and operands.
+func (m *machine) roots(operands []any) []any {
+	roots := append([]any(nil), m.globals...)
+	roots = append(roots, operands...)
+	for _, f := range m.calls {
+		roots = append(roots, f.locals...)
+	}
+	return roots
+}
+
+// reserve makes room for n more cells on the heap, collecting the garbage if
+// they don't fit under the limit, and fails with ErrHeap if they still
+// don't. The values the instruction running holds, off the operand stack,
+// are held as roots.
+func (m *machine) reserve(n int, held ...any) {
+	if m.heap.fits(n) {
+		return
+	}
+	var operands []any
+	if m.operands != nil {
+		operands = *m.operands
+	}
+	m.heap.collect(append(m.roots(operands), held...))
+	if !m.heap.fits(n) {
+		m.fail("%w", ErrHeap)
+	}
+}
+
+// newString returns x and y concatenated, on the heap.
+func (m *machine) newString(x, y string) *String {
+	m.reserve(1 + len(x) + len(y))
+	o := &String{Value: x + y}
+	m.heap.allocate(o)
+	return o
+}
+
+func (m *machine) newList(elements []any) *ListSpace {
+	m.reserve(1+len(elements), elements...)
+	o := &ListSpace{Elements: elements}
+	m.heap.allocate(o)
+	return o
+}
+
+// appendElement appends v to the list l.
+func (m *machine) appendElement(l *ListSpace, v any) {
+	m.reserve(1, l, v)
+	l.Elements = append(l.Elements, v)
+	m.heap.grow(1)
+}
@@ -0,0 +1,190 @@
+package bytecode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLists(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"list", "iconst 1\nsconst \"a\"\nlist 2\nprint", "[1, \"a\"]\n"},
+		{"empty", "list 0\ndup\nllen\nprint\nprint", "0\n[]\n"},
+		{"lload", "iconst 1\niconst 2\nlist 2\niconst 1\nlload\nprint", "2\n"},
+		{"lstore", "iconst 1\nlist 1\ndup\niconst 0\niconst 5\nlstore\nprint", "[5]\n"},
+		{"lappend", "list 0\ndup\niconst 3\nlappend\ndup\ndup\nlappend\nprint", "[3, ...]\n"},
+		{"strings", "sconst \"a\"\nsconst \"b\"\nsadd\ndup\nprint\nsconst \"ab\"\nseq\nprint", "ab\ntrue\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Assemble(tc.src, Stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			vm := NewStackVM(prog)
+			vm.Stdout = &out
+			if err := vm.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("got %q, want %q", out.String(), tc.want)
+			}
+		})
+	}
+
+	errs := []struct {
+		src  string
+		want string
+	}{
+		{"iconst 1\nlist 2", "0005 list 2: 2 elements on a stack of 1"},
+		{"list 0\niconst 0\nlload", "0010 lload: element 0 out of range"},
+		{"null\nllen", "0001 llen: expecting list, found null"},
+		{"sconst \"a\"\niconst 1\nsadd", "0010 sadd: expecting string, found int"},
+	}
+	for _, tc := range errs {
+		prog, err := Assemble(tc.src, Stack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewStackVM(prog).Run()
+		var e *Error
+		if !errors.As(err, &e) || err.Error() != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.src, err, tc.want)
+		}
+	}
+}
+
+// loop repeats body n times, in main, with the count in local 0.
+func loop(n, body string) string {
+	return `.globals 3
+.def main: args=0, locals=1
+    iconst 0
+    store 0
+loop:
+    load 0
+    iconst ` + n + `
+    ilt
+    brf end
+` + body + `
+    load 0
+    iconst 1
+    iadd
+    store 0
+    br loop
+end:
+`
+}
+
+func TestGC(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+		live int // objects the program keeps
+	}{
+		{
+			"garbage",
+			loop("100000", `
+    sconst "a"
+    sconst "b"
+    sadd
+    pop`),
+			"",
+			0,
+		},
+		{
+			// a linked list of 5000 nodes from global 0, made along with
+			// garbage, then the sum of the nodes, walked from global 1 to
+			// global 2
+			"kept",
+			loop("5000", `
+    struct 2          // head = {i, head}
+    dup
+    load 0
+    fstore 0
+    dup
+    gload 0
+    fstore 1
+    gstore 0
+    load 0
+    list 1
+    pop`) + `
+    iconst 0
+    store 0
+    gload 0
+    gstore 1
+    iconst 0
+    gstore 2
+walk:
+    load 0
+    iconst 5000
+    ilt
+    brf out
+    gload 2           // sum = sum + node.value
+    gload 1
+    fload 0
+    iadd
+    gstore 2
+    gload 1           // node = node.next
+    fload 1
+    gstore 1
+    load 0
+    iconst 1
+    iadd
+    store 0
+    br walk
+out:
+    gload 2
+    print
+`,
+			"12497500\n",
+			5000,
+		},
+		{
+			"cycles",
+			loop("10000", `
+    struct 1
+    dup
+    dup
+    fstore 0
+    pop`),
+			"",
+			0,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Assemble(tc.src, Stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			vm := NewStackVM(prog)
+			vm.Stdout = &out
+			if err := vm.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("got %q, want %q", out.String(), tc.want)
+			}
+			gc := vm.GC
+			if gc.Collections == 0 || gc.Freed == 0 {
+				t.Errorf("no collection: %+v", gc)
+			}
+			if gc.Allocated != gc.Freed+gc.Live {
+				t.Errorf("objects lost: %+v", gc)
+			}
+			if gc.Live < tc.live {
+				t.Errorf("objects freed while used: %+v", gc)
+			}
+			if gc.Peak > 2*(minHeap+tc.live) {
+				t.Errorf("heap too big: %+v", gc)
+			}
+		})
+	}
+}
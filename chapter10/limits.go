@@ -0,0 +1,83 @@
+package bytecode
+
+import (
+	"context"
+	"errors"
+)
+
+// Limits
+//
+// A server running bytecode it doesn't trust has to bound what it uses: a
+// loop may never end, a recursion never stop, and either can fill the
+// memory. The calls running are frames on a call stack of their own, not on
+// Go's, so a recursion can go as deep as the memory lets it: the call stack
+// grows as needed. The machines stop a program going over their Limits with
+// an Error wrapping one of the errors below, and RunContext stops it once
+// its context is done, with the context's error:
+//
+//	vm := bytecode.NewStackVM(prog)
+//	vm.Limits = bytecode.Limits{Instructions: 1_000_000, Depth: 1000, Stack: 10_000, Heap: 100_000}
+//	ctx, cancel := context.WithTimeout(ctx, time.Second)
+//	defer cancel()
+//	err := vm.RunContext(ctx)
+//	errors.Is(err, bytecode.ErrInstructions)
+//
+// The depth is the number of calls running at once, main included, the stack
+// the number of values on the stack machine's operand stack, and the heap the
+// size of the objects on the heap, in cells, see heap.go: the collector runs
+// to free some before the program goes over it. The context is checked every
+// checkEvery instructions, not to slow the dispatch of each one.
+
+// Limits bounds the resources a program uses, zero means no limit.
+type Limits struct {
+	Instructions int // instructions run
+	Depth        int // calls running at once
+	Stack        int // values on the operand stack at once
+	Heap         int // cells of the objects on the heap at once
+}
+
+// DefaultLimits are the limits of a new machine.
+var DefaultLimits = Limits{Depth: 100_000}
+
+var (
+	ErrInstructions = errors.New("too many instructions")
+	ErrDepth        = errors.New("too many nested calls")
+	ErrStack        = errors.New("operand stack overflow")
+	ErrHeap         = errors.New("out of memory")
+)
+
+// checkEvery is the number of instructions run between two checks of the
+// context.
+const checkEvery = 1024
+
+// step counts the instruction at ip, about to run, and checks the program
+// can run it.
+func (m *machine) step() {
+	m.addr = m.ip
+	m.instructions++
+	if max := m.limits.Instructions; max > 0 && m.instructions > max {
+		m.fail("%w", ErrInstructions)
+	}
+	if m.done != nil && m.instructions%checkEvery == 0 {
+		select {
+		case <-m.done:
+			m.fail("%w", m.ctx.Err())
+		default:
+		}
+	}
+}
+
+// enter starts running the call of a frame.
+func (m *machine) enter(f frame) {
+	if max := m.limits.Depth; max > 0 && len(m.calls) >= max {
+		m.fail("%w", ErrDepth)
+	}
+	m.calls = append(m.calls, f)
+	m.ip = f.fn.Addr
+}
+
+// run sets what limits the program about to run.
+func (m *machine) run(ctx context.Context, limits Limits) {
+	m.ctx, m.done, m.limits = ctx, ctx.Done(), limits
+	m.instructions = 0
+}
@@ -0,0 +1,84 @@
+package bytecode
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		limits Limits
+		want   error
+	}{
+		{"instructions", "a: br a", Limits{Instructions: 1000}, ErrInstructions},
+		{"depth", ".def f: args=0, locals=0\ncall f()\n.def main: args=0, locals=0\ncall f()", Limits{Depth: 100}, ErrDepth},
+		{"stack", "a: iconst 1\nbr a", Limits{Stack: 100}, ErrStack},
+		{"heap", loop("10000", "    gload 0\n    struct 1\n    list 2\n    gstore 0"), Limits{Heap: 1000}, ErrHeap},
+		// the garbage is collected before going over the limit
+		{"garbage", loop("10000", "    struct 1\n    pop"), Limits{Heap: 10}, nil},
+		{"fact", fact, Limits{Instructions: 56, Depth: 6, Stack: 6, Heap: 1}, nil},
+		// the string is too big before it's made, however few objects there are
+		{"doubling", ".globals 1\nsconst \"xxxxxxxxxxxxxxxx\"\ngstore 0\na: gload 0\ngload 0\nsadd\ngstore 0\nbr a", Limits{Instructions: 200, Depth: 1000, Stack: 10000, Heap: 100}, ErrHeap},
+		{"big struct", "struct 1000000000", Limits{Heap: 100}, ErrHeap},
+		{"appending", "list 0\na: dup\niconst 1\nlappend\nbr a", Limits{Heap: 100}, ErrHeap},
+		{"fact instructions", fact, Limits{Instructions: 55}, ErrInstructions},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Assemble(tc.src, Stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vm := NewStackVM(prog)
+			vm.Stdout, vm.Limits = io.Discard, tc.limits
+			err = vm.Run()
+			if !errors.Is(err, tc.want) {
+				t.Errorf("got %v, want %v", err, tc.want)
+			}
+			if tc.limits.Heap > 0 && vm.GC.PeakSize > tc.limits.Heap {
+				t.Errorf("%d cells on the heap, over %d", vm.GC.PeakSize, tc.limits.Heap)
+			}
+		})
+	}
+
+	prog, err := Assemble(registerFact, Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewRegisterVM(prog)
+	vm.Stdout, vm.Limits = io.Discard, Limits{Instructions: 10}
+	err = vm.Run()
+	if !errors.Is(err, ErrInstructions) || vm.Instructions != 11 {
+		t.Errorf("got %v after %d instructions, want too many instructions after 11", err, vm.Instructions)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	prog, err := Assemble("a: br a", Stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = NewStackVM(prog).RunContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline exceeded", err)
+	}
+
+	prog, err = Assemble("a: br a", Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	vm := NewRegisterVM(prog)
+	err = vm.RunContext(ctx)
+	if !errors.Is(err, context.Canceled) || vm.Instructions != checkEvery {
+		t.Errorf("got %v after %d instructions, want canceled after %d", err, vm.Instructions, checkEvery)
+	}
+}
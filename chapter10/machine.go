@@ -0,0 +1,224 @@
+package bytecode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// What the two machines share: running the code of a program, the globals
+// of the program and the calls running, and how they report errors.
+
+// machine is the state the stack and the register machines share.
+type machine struct {
+	prog    *Program
+	globals []any
+	calls   []frame // call stack, the current call last
+	ip      int     // instruction pointer
+	addr    int     // of the instruction running
+	heap    heap
+	strings []*String // the strings of the pool, by index
+
+	// the operand stack of the stack machine, roots of the collector, nil
+	// for the register machine
+	operands *[]any
+
+	limits       Limits
+	ctx          context.Context
+	done         <-chan struct{} // ctx.Done()
+	instructions int             // run so far
+}
+
+// frame is the memory of a function call.
+type frame struct {
+	fn     *Function
+	ret    int   // return address
+	locals []any // the stack machine's arguments and locals, the register machine's registers
+}
+
+// Error is an error running the instruction at Addr. The code a correct
+// compiler generates has none, only the programs written by hand do.
+type Error struct {
+	Addr  int
+	Instr string
+	Err   error
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("%04d %s: %v", e.Addr, e.Instr, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+// bailout recovers from the panic raised on the first Error and returns it in
+// err instead.
+func bailout(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(*Error)
+		if !ok {
+			panic(r)
+		}
+		*err = e
+	}
+}
+
+// start sets the globals to null and starts the call of main, which has
+// extra room in its frame on top of its arguments and locals.
+func (m *machine) start(extra int) {
+	m.globals = make([]any, m.prog.Globals)
+	m.heap.reset(m.limits.Heap)
+	m.strings = make([]*String, len(m.prog.Pool))
+	for i, c := range m.prog.Pool {
+		if s, ok := c.(string); ok {
+			m.strings[i] = &String{Value: s}
+		}
+	}
+	m.calls = m.calls[:0]
+	main := m.prog.Main
+	if main == nil {
+		main = &Function{Name: "main"}
+	}
+	m.ip = main.Addr
+	m.calls = append(m.calls, frame{fn: main, ret: -1, locals: make([]any, extra+main.Args+main.Locals)})
+}
+
+// trace writes the instruction that just ran to w, followed by the values it
+// left in the operand stack or registers, named by kind, and the calls
+// running, main first.
+func (m *machine) trace(w io.Writer, kind string, values []any) {
+	instr, _ := m.prog.Instr(m.addr)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%04d %-20s %s=[", m.addr, instr, kind)
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		literal(&b, v, nil)
+	}
+	b.WriteString("] calls=[")
+	for i, f := range m.calls {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(f.fn.Name)
+	}
+	b.WriteString("]\n")
+	io.WriteString(w, b.String())
+}
+
+// ret returns from the current call, the main one ending the program.
+func (m *machine) ret() {
+	f := m.calls[len(m.calls)-1]
+	m.calls = m.calls[:len(m.calls)-1]
+	m.ip = f.ret
+}
+
+// operand reads the operand at ip and moves past it.
+func (m *machine) operand() int {
+	if m.ip+4 > len(m.prog.Code) {
+		m.fail("truncated instruction")
+	}
+	v := m.prog.Operand(m.ip)
+	m.ip += 4
+	return v
+}
+
+// function returns the function at index i of the pool.
+func (m *machine) function(i int) *Function {
+	return m.constant(Call, i).(*Function)
+}
+
+// constant returns the entry i of the pool, checking it has the type op
+// expects, a *String for a string.
+func (m *machine) constant(op Opcode, i int) any {
+	c := m.prog.Pool[m.index(i, len(m.prog.Pool), "pool entry")]
+	var ok bool
+	var want string
+	switch op {
+	case Fconst:
+		_, ok = c.(float64)
+		want = "a float"
+	case Sconst:
+		_, ok = c.(string)
+		want = "a string"
+		c = m.strings[i]
+	case Call:
+		_, ok = c.(*Function)
+		want = "a function"
+	}
+	if !ok {
+		m.fail("pool entry %d isn't %s", i, want)
+	}
+	return c
+}
+
+// index checks i is in the range of n things of a kind.
+func (m *machine) index(i, n int, kind string) int {
+	if i < 0 || i >= n {
+		m.fail("%s %d out of range", kind, i)
+	}
+	return i
+}
+
+func (m *machine) newStruct(fields int) *StructSpace {
+	if fields < 0 {
+		m.fail("negative number of fields")
+	}
+	m.reserve(1 + fields)
+	s := &StructSpace{Fields: make([]any, fields)}
+	m.heap.allocate(s)
+	return s
+}
+
+// structure returns v as a struct, which can't be null.
+func (m *machine) structure(v any) *StructSpace {
+	s, ok := v.(*StructSpace)
+	if v == nil || ok && s == nil {
+		m.fail("null struct")
+	}
+	if !ok {
+		m.fail("expecting a struct, found %s", typeName(v))
+	}
+	return s
+}
+
+// as returns v as a value of type T.
+func as[T any](m *machine, v any) T {
+	t, ok := v.(T)
+	if !ok {
+		var want T
+		m.fail("expecting %s, found %s", typeName(want), typeName(v))
+	}
+	return t
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case rune:
+		return "char"
+	case bool:
+		return "boolean"
+	case *String:
+		return "string"
+	case *ListSpace:
+		return "list"
+	case nil:
+		return "null"
+	}
+	return "struct"
+}
+
+func (m *machine) fail(format string, args ...any) {
+	panic(m.prog.errorf(m.addr, format, args...))
+}
+
+// errorf returns the Error of the instruction at addr.
+func (p *Program) errorf(addr int, format string, args ...any) *Error {
+	var instr string
+	if addr >= 0 && addr < len(p.Code) {
+		instr, _ = p.Instr(addr)
+	}
+	return &Error{Addr: addr, Instr: instr, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,256 @@
+package bytecode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Object files
+//
+// A compiled program can be saved and run later without compiling it again,
+// in an object file. Numbers in it are big-endian, like the operands in the
+// code, and strings are their length followed by their bytes:
+//
+//	magic      "CYBC"
+//	version    uint16, 1
+//	machine    uint8, 0 for the stack machine, 1 for the register machine
+//	globals    uint32
+//	main       int32, the pool index of main, -1 if there's none
+//	pool       uint32 count, then the entries
+//	code       uint32 length, then the bytes
+//
+// Each pool entry starts with a byte telling what it is, followed by the
+// value: 'f' and the float's 8 bytes, 's' and a string, or 'F' and a
+// function's name, arguments, locals and address, each a uint32. The
+// functions being in the pool, it's also the function table: a call's
+// operand is the index of its entry.
+//
+// Reading checks what it can without running the code: the entries and the
+// addresses of the functions are valid, main is a function, and there are no
+// more than maxSlots globals, nor arguments and locals of a function, for a
+// file of a few bytes not to make the machine running it allocate gigabytes.
+
+// ObjectVersion is the version of the object files written.
+const ObjectVersion = 1
+
+var magic = []byte("CYBC")
+
+// ErrObject is the error reading something that isn't a valid object file.
+var ErrObject = errors.New("invalid object file")
+
+// maxSlots is the most globals a program has, and arguments and locals a
+// function has, far more than the compiler ever makes.
+const maxSlots = 1 << 20
+
+// IsObject tells whether data starts like an object file.
+func IsObject(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// WriteObject writes the program p to w as an object file.
+func WriteObject(w io.Writer, p *Program) error {
+	bw := bufio.NewWriter(w)
+	ow := &objectWriter{w: bw}
+	ow.bytes(magic)
+	ow.int(uint16(ObjectVersion))
+	ow.int(uint8(p.Machine))
+	ow.int(uint32(p.Globals))
+	main := int32(-1)
+	for i, c := range p.Pool {
+		if f, ok := c.(*Function); ok && f == p.Main {
+			main = int32(i)
+		}
+	}
+	if p.Main != nil && main < 0 {
+		return fmt.Errorf("main function %s isn't in the pool", p.Main.Name)
+	}
+	ow.int(main)
+	ow.int(uint32(len(p.Pool)))
+	for _, c := range p.Pool {
+		switch c := c.(type) {
+		case float64:
+			ow.int(uint8('f'))
+			ow.int(math.Float64bits(c))
+		case string:
+			ow.int(uint8('s'))
+			ow.string(c)
+		case *Function:
+			ow.int(uint8('F'))
+			ow.string(c.Name)
+			ow.int(uint32(c.Args))
+			ow.int(uint32(c.Locals))
+			ow.int(uint32(c.Addr))
+		default:
+			return fmt.Errorf("cannot write pool entry %v (%T)", c, c)
+		}
+	}
+	ow.int(uint32(len(p.Code)))
+	ow.bytes(p.Code)
+	if ow.err != nil {
+		return ow.err
+	}
+	return bw.Flush()
+}
+
+type objectWriter struct {
+	w   io.Writer
+	err error // the first error writing
+}
+
+func (ow *objectWriter) int(v any) {
+	if ow.err == nil {
+		ow.err = binary.Write(ow.w, binary.BigEndian, v)
+	}
+}
+
+func (ow *objectWriter) bytes(b []byte) {
+	if ow.err == nil {
+		_, ow.err = ow.w.Write(b)
+	}
+}
+
+func (ow *objectWriter) string(s string) {
+	ow.int(uint32(len(s)))
+	ow.bytes([]byte(s))
+}
+
+// ReadObject reads an object file from r.
+func ReadObject(r io.Reader) (*Program, error) {
+	or := &objectReader{r: bufio.NewReader(r)}
+	p := or.program()
+	if or.err != nil {
+		return nil, or.err
+	}
+	return p, nil
+}
+
+type objectReader struct {
+	r   io.Reader
+	err error // the first error reading
+}
+
+func (or *objectReader) program() *Program {
+	if !bytes.Equal(or.bytes(len(magic)), magic) {
+		or.fail("bad magic number")
+		return nil
+	}
+	if v := or.uint16(); or.err == nil && v != ObjectVersion {
+		or.fail("version %d, want %d", v, ObjectVersion)
+		return nil
+	}
+	p := &Program{}
+	switch m := Machine(or.uint8()); m {
+	case Stack, Register:
+		p.Machine = m
+	default:
+		or.fail("unknown machine %d", m)
+	}
+	p.Globals = int(or.uint32())
+	if p.Globals > maxSlots {
+		or.fail("%d globals, more than %d", p.Globals, maxSlots)
+	}
+	main := int(int32(or.uint32()))
+	n := or.uint32()
+	for i := uint32(0); i < n && or.err == nil; i++ {
+		switch tag := or.uint8(); tag {
+		case 'f':
+			p.Pool = append(p.Pool, math.Float64frombits(or.uint64()))
+		case 's':
+			p.Pool = append(p.Pool, or.string())
+		case 'F':
+			f := &Function{Name: or.string()}
+			f.Args, f.Locals, f.Addr = int(or.uint32()), int(or.uint32()), int(or.uint32())
+			if f.Args+f.Locals > maxSlots {
+				or.fail("function %s has %d arguments and locals, more than %d", f.Name, f.Args+f.Locals, maxSlots)
+			}
+			p.Pool = append(p.Pool, f)
+		default:
+			or.fail("unknown pool entry %q", tag)
+		}
+	}
+	p.Code = or.bytes(int(or.uint32()))
+	if or.err != nil {
+		return nil
+	}
+	if main >= 0 {
+		var f *Function
+		if main < len(p.Pool) {
+			f, _ = p.Pool[main].(*Function)
+		}
+		if f == nil {
+			or.fail("main %d isn't a function", main)
+			return nil
+		}
+		p.Main = f
+	}
+	for _, f := range p.Functions() {
+		if f.Addr > len(p.Code) {
+			or.fail("function %s at %d, past the end of the code", f.Name, f.Addr)
+		}
+	}
+	return p
+}
+
+func (or *objectReader) fail(format string, args ...any) {
+	if or.err == nil {
+		or.err = fmt.Errorf("%w: %s", ErrObject, fmt.Sprintf(format, args...))
+	}
+}
+
+// bytes reads the next n bytes.
+func (or *objectReader) bytes(n int) []byte {
+	if or.err != nil {
+		return nil
+	}
+	// read what's there rather than trusting n to allocate
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, or.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		or.err = fmt.Errorf("%w: %w", ErrObject, err)
+		return nil
+	}
+	return b.Bytes()
+}
+
+func (or *objectReader) uint8() uint8 {
+	b := or.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (or *objectReader) uint16() uint16 {
+	b := or.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (or *objectReader) uint32() uint32 {
+	b := or.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (or *objectReader) uint64() uint64 {
+	b := or.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (or *objectReader) string() string {
+	return string(or.bytes(int(or.uint32())))
+}
@@ -0,0 +1,107 @@
+package bytecode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestObject(t *testing.T) {
+	compiled, err := Compile(`float half(int n) { return n / 2.0; }
+void main() { print(half(3)); print("done"); }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		prog *Program
+		want string
+	}{
+		{"stack", mustAssemble(t, fact, Stack), "120\n"},
+		{"register", mustAssemble(t, registerFact, Register), "120\n"},
+		{"compiled", compiled, "1.5\ndone\n"},
+		{"no main", mustAssemble(t, "sconst \"hi\"\nprint\n", Stack), "hi\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteObject(&b, tc.prog); err != nil {
+				t.Fatal(err)
+			}
+			if !IsObject(b.Bytes()) {
+				t.Error("not an object file")
+			}
+			got, err := ReadObject(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.prog, got); diff != "" {
+				t.Error(diff)
+			}
+			var out strings.Builder
+			if got.Machine == Register {
+				vm := NewRegisterVM(got)
+				vm.Stdout = &out
+				err = vm.Run()
+			} else {
+				vm := NewStackVM(got)
+				vm.Stdout = &out
+				err = vm.Run()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.want {
+				t.Error(cmp.Diff(out.String(), tc.want))
+			}
+		})
+	}
+}
+
+func TestObjectErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteObject(&b, mustAssemble(t, fact, Stack)); err != nil {
+		t.Fatal(err)
+	}
+	valid := b.Bytes()
+	edit := func(at int, replace ...byte) []byte {
+		data := bytes.Clone(valid)
+		copy(data[at:], replace)
+		return data
+	}
+	cases := []struct {
+		data []byte
+		want string
+	}{
+		{[]byte("CYB"), "invalid object file: unexpected EOF"},
+		{edit(0, 'X'), "invalid object file: bad magic number"},
+		{edit(4, 0, 2), "invalid object file: version 2, want 1"},
+		{edit(6, 7), "invalid object file: unknown machine 7"},
+		{edit(7, 0xff, 0xff, 0xff, 0xf0), "invalid object file: 4294967280 globals, more than 1048576"},
+		{edit(11, 0, 0, 0, 9), "invalid object file: main 9 isn't a function"},
+		{edit(32, 0xff, 0xff, 0xff, 0xff), "invalid object file: function fact has 4294967296 arguments and locals, more than 1048576"},
+		{edit(19, '?'), "invalid object file: unknown pool entry '?'"},
+		{valid[:len(valid)-1], "invalid object file: unexpected EOF"},
+	}
+	for _, tc := range cases {
+		_, err := ReadObject(bytes.NewReader(tc.data))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("want: %s, got: %v", tc.want, err)
+		}
+		if !errors.Is(err, ErrObject) {
+			t.Errorf("%v isn't ErrObject", err)
+		}
+	}
+}
+
+func mustAssemble(t *testing.T, src string, m Machine) *Program {
+	t.Helper()
+	prog, err := Assemble(src, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
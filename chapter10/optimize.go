@@ -0,0 +1,234 @@
+package bytecode
+
+import (
+	"math"
+	"slices"
+)
+
+// Peephole optimization
+//
+// The compiler generates the code of each expression and statement on its
+// own, which leaves patterns a look through a small window of instructions,
+// a peephole, can improve: i = i + 1 is
+//
+//	load 0
+//	iconst 1
+//	iadd
+//	store 0
+//
+// four instructions to dispatch, when a superinstruction, linc 0, 1, adding
+// to a local in place, does the same with one. Since dispatching costs more
+// than executing most instructions, fusing the frequent sequences into one
+// instruction saves time even if the machine has more instructions. The
+// optimizer fuses
+//
+//	iconst n; iadd                 into  iaddc n
+//	iconst n; isub                 into  iaddc -n
+//	load i; load j; iadd           into  ladd i, j
+//	load i; iconst n; iadd; store i into linc i, n
+//
+// and drops what does nothing: a load i; store i, the same with gload and
+// gstore, and a branch to the next instruction. It goes over the code again
+// while it changes something, since dropping a branch can bring together a
+// new pattern.
+//
+// A sequence can't be fused if something branches to one of its
+// instructions but the first, or a function starts there: the code branching
+// there would have nowhere to go. The code is decoded into a list of
+// instructions, the branches to the index of their target, the optimizer
+// rewrites the list, then encodes it again with the addresses of the
+// targets where they moved. BenchmarkOptimize measures the speedup.
+
+// op is a decoded instruction. The operand of a branch is the index of the
+// instruction it branches to.
+type op struct {
+	code Opcode
+	args []int
+}
+
+// Optimize returns the program p with the peephole optimizations above
+// applied. p is left as it is, and returned if it's not for the stack
+// machine or its code can't be decoded, as a program written by hand may
+// not.
+func Optimize(p *Program) *Program {
+	if p.Machine != Stack {
+		return p
+	}
+	ops, addrs, err := decode(p)
+	if err != nil {
+		return p
+	}
+	// the functions are moved in copies, for p to stay as it is
+	pool := slices.Clone(p.Pool)
+	entries := map[*Function]int{} // function to the index it starts at
+	var main *Function
+	for i, c := range pool {
+		f, ok := c.(*Function)
+		if !ok {
+			continue
+		}
+		entry, ok := slices.BinarySearch(addrs, f.Addr)
+		if !ok {
+			return p
+		}
+		g := *f
+		pool[i] = &g
+		if f == p.Main {
+			main = &g
+		}
+		entries[&g] = entry
+	}
+	for {
+		var changed bool
+		ops, changed = peephole(ops, entries)
+		if !changed {
+			break
+		}
+	}
+	q := &Program{Machine: Stack, Pool: pool, Globals: p.Globals, Main: main}
+	addrs = encode(q, ops)
+	for f, i := range entries {
+		f.Addr = addrs[i]
+	}
+	return q
+}
+
+// decode returns the instructions of p and their addresses, followed by the
+// address of the end of the code. The error is an *Error on the first
+// invalid opcode, truncated instruction or branch into the middle of an
+// instruction.
+func decode(p *Program) ([]op, []int, error) {
+	var ops []op
+	var addrs []int
+	for addr := 0; addr < len(p.Code); {
+		code := Opcode(p.Code[addr])
+		instr, ok := p.Machine.instruction(code)
+		if !ok {
+			return nil, nil, p.errorf(addr, "invalid opcode %d", byte(code))
+		}
+		if p.Machine.Size(code) > len(p.Code)-addr {
+			return nil, nil, p.errorf(addr, "truncated instruction")
+		}
+		o := op{code: code}
+		for i := range instr.operands {
+			o.args = append(o.args, p.Operand(addr+1+4*i))
+		}
+		ops = append(ops, o)
+		addrs = append(addrs, addr)
+		addr += p.Machine.Size(code)
+	}
+	addrs = append(addrs, len(p.Code))
+	for k, o := range ops {
+		instr, _ := p.Machine.instruction(o.code)
+		for i, kind := range instr.operands {
+			if kind != addrArg {
+				continue
+			}
+			target, ok := slices.BinarySearch(addrs, o.args[i])
+			if !ok {
+				return nil, nil, p.errorf(addrs[k], "branch to %d, not an instruction", o.args[i])
+			}
+			o.args[i] = target
+		}
+	}
+	return ops, addrs, nil
+}
+
+func isBranch(code Opcode) bool {
+	return code == Br || code == Brt || code == Brf
+}
+
+// peephole rewrites ops once, returning whether it changed something.
+func peephole(ops []op, entries map[*Function]int) ([]op, bool) {
+	targets := map[int]bool{}
+	for _, o := range ops {
+		if isBranch(o.code) {
+			targets[o.args[0]] = true
+		}
+	}
+	for _, i := range entries {
+		targets[i] = true
+	}
+	var out []op
+	moved := make([]int, len(ops)+1) // old index to new
+	changed := false
+	for i := 0; i < len(ops); {
+		replace, n := rewrite(ops[i:], i, targets)
+		if n == 0 {
+			replace, n = ops[i:i+1], 1
+		} else {
+			changed = true
+		}
+		for k := range n {
+			moved[i+k] = len(out)
+		}
+		out = append(out, replace...)
+		i += n
+	}
+	moved[len(ops)] = len(out)
+	for _, o := range out {
+		if isBranch(o.code) {
+			o.args[0] = moved[o.args[0]]
+		}
+	}
+	for f, i := range entries {
+		entries[f] = moved[i]
+	}
+	return out, changed
+}
+
+// rewrite returns what replaces the first instructions of ops, at index at,
+// and how many it replaces, none if no pattern matches.
+func rewrite(ops []op, at int, targets map[int]bool) ([]op, int) {
+	// match tells whether ops starts with codes, with nothing branching
+	// inside
+	match := func(codes ...Opcode) bool {
+		if len(ops) < len(codes) {
+			return false
+		}
+		for i, code := range codes {
+			if ops[i].code != code || i > 0 && targets[at+i] {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case match(Load, Iconst, Iadd, Store) && ops[0].args[0] == ops[3].args[0]:
+		return []op{{Linc, []int{ops[0].args[0], ops[1].args[0]}}}, 4
+	case match(Load, Load, Iadd):
+		return []op{{Ladd, []int{ops[0].args[0], ops[1].args[0]}}}, 3
+	case match(Iconst, Iadd):
+		return []op{{Iaddc, []int{ops[0].args[0]}}}, 2
+	case match(Iconst, Isub) && ops[0].args[0] != math.MinInt32:
+		return []op{{Iaddc, []int{-ops[0].args[0]}}}, 2
+	case match(Load, Store) && ops[0].args[0] == ops[1].args[0],
+		match(Gload, Gstore) && ops[0].args[0] == ops[1].args[0]:
+		return nil, 2
+	case match(Br) && ops[0].args[0] == at+1:
+		return nil, 1
+	}
+	return nil, 0
+}
+
+// encode sets the code of p to ops, returning the address of each one, and
+// of the end of the code last.
+func encode(p *Program, ops []op) []int {
+	addrs := make([]int, 0, len(ops)+1)
+	at := 0
+	for _, o := range ops {
+		addrs = append(addrs, at)
+		at += Stack.Size(o.code)
+	}
+	addrs = append(addrs, at)
+	for _, o := range ops {
+		p.Code = append(p.Code, byte(o.code))
+		for i, arg := range o.args {
+			if i == 0 && isBranch(o.code) {
+				arg = addrs[arg]
+			}
+			p.emit(arg)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,136 @@
+package bytecode
+
+import (
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOptimize(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"linc",
+			".def main: args=0, locals=1\nload 0\niconst 1\niadd\nstore 0",
+			".def main: args=0, locals=1\n    linc 0, 1\n",
+		},
+		{
+			"ladd",
+			".def main: args=0, locals=2\nload 0\nload 1\niadd\nstore 0",
+			".def main: args=0, locals=2\n    ladd 0, 1\n    store 0\n",
+		},
+		{
+			"iaddc",
+			".def main: args=0, locals=1\nload 0\niconst -2\niadd\nprint",
+			".def main: args=0, locals=1\n    load 0\n    iaddc -2\n    print\n",
+		},
+		{
+			"isub",
+			".def main: args=0, locals=1\nload 0\niconst 2\nisub\nprint\nload 0\niconst -2147483648\nisub",
+			".def main: args=0, locals=1\n    load 0\n    iaddc -2\n    print\n    load 0\n    iconst -2147483648\n    isub\n",
+		},
+		{
+			"redundant",
+			".globals 1\n.def main: args=0, locals=1\nload 0\nstore 0\ngload 0\ngstore 0\nload 0\nstore 1",
+			".globals 1\n.def main: args=0, locals=1\n    load 0\n    store 1\n",
+		},
+		{
+			"branch to the next instruction",
+			"br a\na: iconst 1\niadd",
+			"    iaddc 1\n",
+		},
+		{
+			"branch inside",
+			"iconst 1\na: iadd\nbr a",
+			"    iconst 1\nL5:\n    iadd\n    br L5\n",
+		},
+		{
+			// the loop moves with the code before it
+			"loop",
+			".def main: args=0, locals=1\nload 0\niconst 1\niadd\nstore 0\nloop: load 0\niconst 3\nilt\nbrf end\nload 0\nload 0\niadd\nstore 0\nbr loop\nend: halt",
+			".def main: args=0, locals=1\n    linc 0, 1\nL9:\n    load 0\n    iconst 3\n    ilt\n    brf L44\n    ladd 0, 0\n    store 0\n    br L9\nL44:\n    halt\n",
+		},
+		{
+			"functions",
+			".def f: args=1, locals=0\nload 0\niconst 1\niadd\nret\n.def main: args=0, locals=0\niconst 1\niconst 2\niadd\ncall f()\nprint",
+			".def f: args=1, locals=0\n    load 0\n    iaddc 1\n    ret\n.def main: args=0, locals=0\n    iconst 1\n    iaddc 2\n    call f()\n    print\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Assemble(tc.src, Stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := Disassemble(prog)
+			got := Disassemble(Optimize(prog))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+			if Disassemble(prog) != before {
+				t.Error("the program optimized changed")
+			}
+		})
+	}
+
+	// programs the optimizer can't decode are left as they are
+	prog := &Program{Code: []byte{200}}
+	if Optimize(prog) != prog {
+		t.Error("invalid code optimized")
+	}
+	prog, err := Assemble(registerFact, Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Optimize(prog) != prog {
+		t.Error("register machine code optimized")
+	}
+}
+
+// BenchmarkOptimize runs compiled programs before and after optimizing them.
+func BenchmarkOptimize(b *testing.B) {
+	programs := []struct {
+		name string
+		src  string
+	}{
+		{"loop", `void main() {
+    int i = 0;
+    int sum = 0;
+    while (i < 10000) {
+        sum = sum + i;
+        i = i + 1;
+    }
+    print(sum);
+}`},
+		{"fib", `int fib(int n) {
+    if (n < 2) return n;
+    return fib(n - 1) + fib(n - 2);
+}
+void main() { print(fib(15)); }`},
+	}
+	for _, p := range programs {
+		prog, err := Compile(p.src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, v := range []struct {
+			name string
+			prog *Program
+		}{{"plain", prog}, {"optimized", Optimize(prog)}} {
+			b.Run(p.name+"/"+v.name, func(b *testing.B) {
+				vm := NewStackVM(v.prog)
+				vm.Stdout = io.Discard
+				for range b.N {
+					if err := vm.Run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(vm.Instructions), "instrs/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package bytecode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Pattern 28:
+// Register-Based Bytecode Interpreter
+
+// A register machine names where the operands of its instructions are and
+// where their result goes: iadd r1, r2, r3 sets register r1 to r2 + r3. Each
+// call has registers of its own, in its frame: r0 for the value it returns,
+// then one for each argument and each local, and the compiler picks which
+// hold the temporary values of expressions. The factorial of the stack
+// machine, see vm.go, becomes
+//
+//	.def fact: args=1, locals=3     // n is r1
+//	    iconst r2, 2
+//	    ilt r3, r1, r2
+//	    brf r3, cont
+//	    iconst r0, 1
+//	    ret
+//	cont:
+//	    iconst r2, 1
+//	    isub r4, r1, r2
+//	    call fact(), r4             // r0 = fact(r4)
+//	    imul r0, r1, r0
+//	    ret
+//
+// A call copies as many registers as the function has arguments, from the
+// one it names on, to r1 and the next ones of the new frame, and ret copies
+// r0 back to the caller's r0.
+//
+// The instructions are bigger than a stack machine's, with their operands,
+// but there are fewer of them: values are used where they are instead of
+// being pushed first, and a stored value doesn't need to be loaded again.
+// Running fewer, bigger instructions is usually faster, since it's the
+// fetching and decoding of each one that costs the most. Both machines run
+// the same programs, assembled for each of them, and count the instructions
+// they run to compare them.
+
+// RegisterVM runs a Program in registers.
+type RegisterVM struct {
+	Stdout       io.Writer // where print writes, os.Stdout by default
+	Instructions int       // the number of instructions the last Run ran
+	Trace        io.Writer // if not nil, where each instruction run is traced
+	Limits       Limits    // DefaultLimits by default
+	GC           GCStats   // the collector's statistics of the last Run
+	machine
+}
+
+func NewRegisterVM(p *Program) *RegisterVM {
+	return &RegisterVM{Stdout: os.Stdout, Limits: DefaultLimits, machine: machine{prog: p}}
+}
+
+// Run runs the program from its start, with its globals set to null.
+func (vm *RegisterVM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext runs the program as Run does, stopping it with an Error once
+// ctx is done.
+func (vm *RegisterVM) RunContext(ctx context.Context) (err error) {
+	defer bailout(&err)
+	defer func() { vm.Instructions, vm.GC = vm.instructions, vm.heap.statistics() }()
+	vm.run(ctx, vm.Limits)
+	vm.start(1)
+	vm.exec()
+	return nil
+}
+
+func (vm *RegisterVM) exec() {
+	code := vm.prog.Code
+	for vm.ip >= 0 && vm.ip < len(code) {
+		vm.step()
+		op := Opcode(code[vm.ip])
+		vm.ip++
+		switch op {
+		case Iadd:
+			r, x, y := vm.operand(), vm.int(), vm.int()
+			vm.set(r, x+y)
+		case Isub:
+			r, x, y := vm.operand(), vm.int(), vm.int()
+			vm.set(r, x-y)
+		case Imul:
+			r, x, y := vm.operand(), vm.int(), vm.int()
+			vm.set(r, x*y)
+		case Ilt:
+			r, x, y := vm.operand(), vm.int(), vm.int()
+			vm.set(r, x < y)
+		case Ieq:
+			r, x, y := vm.operand(), vm.int(), vm.int()
+			vm.set(r, x == y)
+		case Fadd:
+			r, x, y := vm.operand(), vm.float(), vm.float()
+			vm.set(r, x+y)
+		case Fsub:
+			r, x, y := vm.operand(), vm.float(), vm.float()
+			vm.set(r, x-y)
+		case Fmul:
+			r, x, y := vm.operand(), vm.float(), vm.float()
+			vm.set(r, x*y)
+		case Flt:
+			r, x, y := vm.operand(), vm.float(), vm.float()
+			vm.set(r, x < y)
+		case Feq:
+			r, x, y := vm.operand(), vm.float(), vm.float()
+			vm.set(r, x == y)
+		case Itof:
+			r, x := vm.operand(), vm.int()
+			vm.set(r, float64(x))
+		case Call:
+			f, first := vm.function(vm.operand()), vm.operand()
+			vm.call(f, first)
+		case Ret:
+			r0 := vm.registers()[0]
+			vm.ret()
+			if len(vm.calls) > 0 {
+				vm.registers()[0] = r0
+			}
+		case Br:
+			vm.ip = vm.operand()
+		case Brt:
+			b, addr := vm.bool(), vm.operand()
+			if b {
+				vm.ip = addr
+			}
+		case Brf:
+			b, addr := vm.bool(), vm.operand()
+			if !b {
+				vm.ip = addr
+			}
+		case Cconst:
+			r, c := vm.operand(), vm.operand()
+			vm.set(r, rune(c))
+		case Iconst:
+			r, n := vm.operand(), vm.operand()
+			vm.set(r, n)
+		case Fconst, Sconst:
+			r, i := vm.operand(), vm.operand()
+			vm.set(r, vm.constant(op, i))
+		case Gload:
+			r, i := vm.operand(), vm.operand()
+			vm.set(r, vm.globals[vm.index(i, len(vm.globals), "global")])
+		case Gstore:
+			v, i := vm.reg(), vm.operand()
+			vm.globals[vm.index(i, len(vm.globals), "global")] = v
+		case Fload:
+			r, s, i := vm.operand(), vm.structure(vm.reg()), vm.operand()
+			vm.set(r, s.Fields[vm.index(i, len(s.Fields), "field")])
+		case Fstore:
+			v, s, i := vm.reg(), vm.structure(vm.reg()), vm.operand()
+			s.Fields[vm.index(i, len(s.Fields), "field")] = v
+		case Move:
+			r, v := vm.operand(), vm.reg()
+			vm.set(r, v)
+		case Print:
+			fmt.Fprintln(vm.Stdout, format(vm.reg()))
+		case Struct:
+			r, n := vm.operand(), vm.operand()
+			vm.set(r, vm.newStruct(n))
+		case Null:
+			vm.set(vm.operand(), nil)
+		case Halt:
+			vm.ip = -1
+		default:
+			vm.fail("invalid opcode %d", byte(op))
+		}
+		if vm.Trace != nil {
+			vm.trace(vm.Trace, "regs", vm.traced())
+		}
+		vm.collect(nil)
+	}
+}
+
+// call starts running f, with its arguments in the registers from first on.
+func (vm *RegisterVM) call(f *Function, first int) {
+	regs := vm.registers()
+	if first < 0 || first+f.Args > len(regs) {
+		vm.fail("registers r%d to r%d out of range", first, first+f.Args-1)
+	}
+	locals := make([]any, 1+f.Args+f.Locals)
+	copy(locals[1:], regs[first:first+f.Args])
+	vm.enter(frame{fn: f, ret: vm.ip, locals: locals})
+}
+
+func (vm *RegisterVM) registers() []any {
+	return vm.calls[len(vm.calls)-1].locals
+}
+
+// reg reads the register operand at ip and returns its value.
+func (vm *RegisterVM) reg() any {
+	regs := vm.registers()
+	return regs[vm.register(vm.operand(), len(regs))]
+}
+
+// set sets the register r to v.
+func (vm *RegisterVM) set(r int, v any) {
+	regs := vm.registers()
+	regs[vm.register(r, len(regs))] = v
+}
+
+func (vm *RegisterVM) register(r, n int) int {
+	if r < 0 || r >= n {
+		vm.fail("register r%d out of range", r)
+	}
+	return r
+}
+
+func (vm *RegisterVM) int() int       { return as[int](&vm.machine, vm.reg()) }
+func (vm *RegisterVM) float() float64 { return as[float64](&vm.machine, vm.reg()) }
+func (vm *RegisterVM) bool() bool     { return as[bool](&vm.machine, vm.reg()) }
+
+// traced returns the registers of the current call to trace, none once the
+// program ended.
+func (vm *RegisterVM) traced() []any {
+	if len(vm.calls) == 0 {
+		return nil
+	}
+	return vm.registers()
+}
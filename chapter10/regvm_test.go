@@ -0,0 +1,199 @@
+package bytecode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const registerFact = `.globals 1
+.def fact: args=1, locals=3
+    iconst r2, 2        // if n < 2 return 1
+    ilt r3, r1, r2
+    brf r3, cont
+    iconst r0, 1
+    ret
+cont:
+    iconst r2, 1        // return n * fact(n - 1)
+    isub r4, r1, r2
+    call fact(), r4
+    imul r0, r1, r0
+    ret
+.def main: args=0, locals=1
+    iconst r1, 5
+    gstore r1, 0
+    gload r1, 0
+    call fact(), r1
+    print r0
+    halt
+`
+
+func TestRegisterVM(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"fact", registerFact, "120\n"},
+		{
+			"arithmetic",
+			`.def main: args=0, locals=3
+    iconst r1, 7
+    iconst r2, 2
+    isub r3, r1, r2
+    imul r3, r3, r3
+    print r3
+    fconst r1, 1.5
+    itof r2, r2
+    fmul r1, r1, r2
+    print r1
+    flt r3, r2, r1
+    print r3
+    sconst r1, "hi"
+    print r1
+    cconst r1, 'c'
+    move r2, r1
+    print r2`,
+			"25\n3.0\ntrue\nhi\n'c'\n",
+		},
+		{
+			"struct",
+			`.def main: args=0, locals=2
+    struct r1, 2
+    iconst r2, 1
+    fstore r2, r1, 0
+    null r2
+    fstore r2, r1, 1
+    fload r2, r1, 0
+    print r2
+    print r1`,
+			"1\n{1, null}\n",
+		},
+		{
+			"loop",
+			`.def main: args=0, locals=3
+    iconst r1, 0
+    iconst r2, 3
+loop:
+    ilt r3, r1, r2
+    brf r3, end
+    print r1
+    iconst r3, 1
+    iadd r1, r1, r3
+    br loop
+end:`,
+			"0\n1\n2\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Assemble(tc.src, Register)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			vm := NewRegisterVM(prog)
+			vm.Stdout = &out
+			if err := vm.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("got %q, want %q", out.String(), tc.want)
+			}
+		})
+	}
+}
+
+// TestCompareMachines runs the same program on both machines, the register
+// machine running fewer instructions.
+func TestCompareMachines(t *testing.T) {
+	sprog, err := Assemble(fact, Stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rprog, err := Assemble(registerFact, Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sout, rout strings.Builder
+	svm, rvm := NewStackVM(sprog), NewRegisterVM(rprog)
+	svm.Stdout, rvm.Stdout = &sout, &rout
+	if err := svm.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rvm.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if sout.String() != rout.String() {
+		t.Errorf("stack machine printed %q, register machine %q", sout.String(), rout.String())
+	}
+	if svm.Instructions != 56 || rvm.Instructions != 43 {
+		t.Errorf("got %d instructions on the stack machine and %d on the register machine, want 56 and 43",
+			svm.Instructions, rvm.Instructions)
+	}
+}
+
+func TestRegisterVMErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"print r1", "0000 print r1: register r1 out of range"},
+		{"iconst r0, 1\nbrt r0, end\nend:", "0009 brt r0, L18: expecting boolean, found int"},
+		{"null r0\nfload r0, r0, 0", "0005 fload r0, r0, 0: null struct"},
+		{".def f: args=2, locals=0\n.def main: args=0, locals=0\ncall f(), r0", "0000 call f(), r0: registers r0 to r1 out of range"},
+	}
+	for _, tc := range cases {
+		prog, err := Assemble(tc.src, Register)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = NewRegisterVM(prog).Run()
+		var e *Error
+		if !errors.As(err, &e) || err.Error() != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.src, err, tc.want)
+		}
+	}
+
+	prog, err := Assemble(registerFact, Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := NewRegisterVM(prog)
+	vm.Limits.Depth = 5 // main and fact(5) to fact(2)
+	err = vm.Run()
+	if want := "0063 call fact(), r4: too many nested calls"; !errors.Is(err, ErrDepth) || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
+
+func TestRegisterVMTrace(t *testing.T) {
+	prog, err := Assemble(`.def sq: args=1, locals=0
+    imul r0, r1, r1
+    ret
+.def main: args=0, locals=1
+    iconst r1, 3
+    call sq(), r1
+    print r0
+`, Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace strings.Builder
+	vm := NewRegisterVM(prog)
+	vm.Stdout, vm.Trace = &strings.Builder{}, &trace
+	if err := vm.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := `0014 iconst r1, 3         regs=[null, 3] calls=[main]
+0023 call sq(), r1        regs=[null, 3] calls=[main sq]
+0000 imul r0, r1, r1      regs=[9, 3] calls=[main sq]
+0013 ret                  regs=[9, 3] calls=[main]
+0032 print r0             regs=[9, 3] calls=[main]
+`
+	if diff := cmp.Diff(want, trace.String()); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,85 @@
+package bytecode
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Values
+//
+// The machines hold Go values: int, float64, rune for chars and bool, and
+// references to the objects on their heap, see heap.go: *String for strings,
+// *ListSpace for lists and *StructSpace for structs, nil being the null
+// struct. The code decides what type each value has, the machines only check
+// an instruction gets the values it expects, which a correct compiler always
+// gives it.
+
+// StructSpace is an instance of a struct, its fields are numbered in the order
+// they're declared.
+type StructSpace struct {
+	header
+	Fields []any
+}
+
+// format returns how print writes v: strings as they are, the rest as Cymbol
+// literals are written, the fields of a struct between braces.
+func format(v any) string {
+	if s, ok := v.(*String); ok {
+		return s.Value
+	}
+	var b strings.Builder
+	literal(&b, v, nil)
+	return b.String()
+}
+
+func literal(b *strings.Builder, v any, seen []any) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case float64:
+		b.WriteString(formatFloat(v))
+	case rune:
+		b.WriteString(quote(string(v), '\''))
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case *String:
+		b.WriteString(quote(v.Value, '"'))
+	case *ListSpace:
+		elements(b, "[", v.Elements, "]", v, seen)
+	case *StructSpace:
+		if v == nil {
+			b.WriteString("null")
+			return
+		}
+		elements(b, "{", v.Fields, "}", v, seen)
+	}
+}
+
+// elements writes the values an object o holds, between open and close, or
+// ... if o holds itself.
+func elements(b *strings.Builder, open string, values []any, close string, o any, seen []any) {
+	if slices.Contains(seen, o) {
+		b.WriteString("...")
+		return
+	}
+	b.WriteString(open)
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		literal(b, v, append(seen, o))
+	}
+	b.WriteString(close)
+}
+
+// formatFloat writes f with at least one decimal, so it reads as a float.
+func formatFloat(f float64) string {
+	text := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.ContainsAny(text, ".IN") {
+		text += ".0"
+	}
+	return text
+}
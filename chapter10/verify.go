@@ -0,0 +1,282 @@
+package bytecode
+
+import "slices"
+
+// Verifying bytecode
+//
+// The machines check each instruction as they run it, and stop at the first
+// one that goes wrong, which may be after a long time, once the program has
+// printed half of what it prints. Like the JVM checks a class file before
+// running it, Verify checks a program once, without running it, for what
+// the opcode table tells is wrong, on every path the code can take:
+//
+//   - the program has no more than maxSlots globals, and each function no
+//     more than maxSlots arguments and locals, see object.go
+//   - each instruction has a valid opcode and all its operands, and the
+//     branches go to an instruction of the same function
+//   - the constants are of the type the instruction loads, the functions
+//     called are functions, and the locals, globals and registers exist
+//   - on the stack machine, no instruction pops more values than there are
+//     on the stack, the stack has the same height whatever the path taken to
+//     an instruction, each function returns as many values on every ret,
+//     and doesn't run on into the code of the next one
+//
+// The stack effect of each instruction is in the table, but for call, which
+// pushes what the function returns: it's found first, following the code of
+// each function to its rets while the functions it calls are known, until
+// no more are found. The code after calling a function that never returns
+// never runs, and isn't checked.
+//
+// A program that verifies can still fail running, dividing by zero or
+// loading a field of null, but not because of what its code is.
+
+// Verify checks the code of p, returning an *Error on the first problem.
+func Verify(p *Program) (err error) {
+	defer bailout(&err)
+	v := &verifier{prog: p}
+	v.slots()
+	v.ops, v.addrs, err = decode(p)
+	if err != nil {
+		return err
+	}
+	v.functions()
+	v.operands()
+	if p.Machine == Stack {
+		v.stack()
+	}
+	return nil
+}
+
+type verifier struct {
+	prog  *Program
+	ops   []op
+	addrs []int       // of the ops, then the end of the code
+	funcs []*Function // the function of each op, nil before the first
+	entry []int       // the index of the first op of the function of each op
+}
+
+func (v *verifier) fail(i int, format string, args ...any) {
+	panic(v.prog.errorf(v.addrs[i], format, args...))
+}
+
+// slots checks the globals and the frames of the functions are small enough
+// for the machines to make.
+func (v *verifier) slots() {
+	p := v.prog
+	if p.Globals < 0 || p.Globals > maxSlots {
+		panic(p.errorf(0, "%d globals, not between 0 and %d", p.Globals, maxSlots))
+	}
+	for _, f := range p.Functions() {
+		if f.Args < 0 || f.Locals < 0 || f.Args+f.Locals > maxSlots {
+			panic(p.errorf(f.Addr, "function %s has %d arguments and %d locals, not between 0 and %d", f.Name, f.Args, f.Locals, maxSlots))
+		}
+	}
+}
+
+// functions sets the function of each instruction: the one starting at it
+// or before, the first one at the start of the code if there's no main.
+func (v *verifier) functions() {
+	starts := map[int]*Function{}
+	for _, f := range v.prog.Functions() {
+		i, ok := slices.BinarySearch(v.addrs, f.Addr)
+		if !ok {
+			panic(v.prog.errorf(f.Addr, "function %s starts inside an instruction", f.Name))
+		}
+		starts[i] = f
+	}
+	if _, ok := starts[0]; !ok && v.prog.Main == nil {
+		starts[0] = &Function{Name: "main"}
+	}
+	v.funcs = make([]*Function, len(v.ops)+1)
+	v.entry = make([]int, len(v.ops)+1)
+	var f *Function
+	entry := -1
+	for i := range v.ops {
+		if g, ok := starts[i]; ok {
+			f, entry = g, i
+		}
+		v.funcs[i], v.entry[i] = f, entry
+	}
+	// the end of the code belongs to the last function
+	if n := len(v.ops); n > 0 {
+		v.funcs[n], v.entry[n] = v.funcs[n-1], v.entry[n-1]
+	}
+	if f, ok := starts[len(v.ops)]; ok {
+		v.funcs[len(v.ops)], v.entry[len(v.ops)] = f, len(v.ops)
+	}
+}
+
+// operands checks the operands of each instruction.
+func (v *verifier) operands() {
+	p := v.prog
+	for i, o := range v.ops {
+		f := v.funcs[i]
+		if f == nil {
+			continue // never runs
+		}
+		frame := f.Args + f.Locals
+		if p.Machine == Register {
+			frame++ // r0
+		}
+		instr, _ := p.Machine.instruction(o.code)
+		for k, kind := range instr.operands {
+			arg := o.args[k]
+			switch kind {
+			case addrArg:
+				if v.entry[arg] != v.entry[i] {
+					v.fail(i, "branch out of %s", f.Name)
+				}
+			case funcArg:
+				if arg < 0 || arg >= len(p.Pool) {
+					v.fail(i, "pool entry %d out of range", arg)
+				}
+				if _, ok := p.Pool[arg].(*Function); !ok {
+					v.fail(i, "pool entry %d isn't a function", arg)
+				}
+			case poolArg:
+				if arg < 0 || arg >= len(p.Pool) {
+					v.fail(i, "pool entry %d out of range", arg)
+				}
+				c := p.Pool[arg]
+				if _, ok := c.(float64); o.code == Fconst && !ok {
+					v.fail(i, "pool entry %d isn't a float", arg)
+				}
+				if _, ok := c.(string); o.code == Sconst && !ok {
+					v.fail(i, "pool entry %d isn't a string", arg)
+				}
+			case regArg:
+				if arg < 0 || arg >= frame {
+					v.fail(i, "register r%d out of range", arg)
+				}
+				if o.code == Call {
+					callee := p.Pool[o.args[0]].(*Function)
+					if arg+callee.Args > frame {
+						v.fail(i, "registers r%d to r%d out of range", arg, arg+callee.Args-1)
+					}
+				}
+			case intArg:
+				switch {
+				case o.code == Load || o.code == Store || o.code == Ladd || o.code == Linc && k == 0:
+					if arg < 0 || arg >= frame {
+						v.fail(i, "local %d out of range", arg)
+					}
+				case o.code == Gload || o.code == Gstore:
+					if arg < 0 || arg >= p.Globals {
+						v.fail(i, "global %d out of range", arg)
+					}
+				case o.code == Struct || o.code == List || o.code == Fload || o.code == Fstore:
+					if arg < 0 {
+						v.fail(i, "negative operand %d", arg)
+					}
+				}
+			}
+		}
+	}
+}
+
+// stack checks the heights of the stack machine's operand stack.
+func (v *verifier) stack() {
+	results := map[*Function]int{}
+	for changed := true; changed; {
+		changed = false
+		for i, f := range v.funcs[:len(v.ops)] {
+			if f == nil || v.entry[i] != i {
+				continue
+			}
+			if _, ok := results[f]; ok {
+				continue
+			}
+			if n, ok := v.flow(i, results, false); ok {
+				results[f] = n
+				changed = true
+			}
+		}
+	}
+	for i, f := range v.funcs[:len(v.ops)] {
+		if f != nil && v.entry[i] == i {
+			v.flow(i, results, true)
+		}
+	}
+}
+
+// flow follows the code of the function starting at entry, from an empty
+// stack, returning the number of values it returns, or false if it never
+// returns. Calling a function not in results ends a path, and so does a
+// problem, unless check is set: then a problem fails.
+func (v *verifier) flow(entry int, results map[*Function]int, check bool) (int, bool) {
+	heights := map[int]int{entry: 0}
+	work := []int{entry}
+	returns := -1
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+		if i == len(v.ops) {
+			continue // the end of the program
+		}
+		if v.entry[i] != entry {
+			if !check {
+				return 0, false
+			}
+			v.fail(i-1, "runs on into %s", v.funcs[i].Name)
+		}
+		o := v.ops[i]
+		info := opcodeTable[o.code]
+		pop, push := info.pop, info.push
+		h := heights[i]
+		switch o.code {
+		case Call:
+			callee := v.prog.Pool[o.args[0]].(*Function)
+			n, ok := results[callee]
+			if !ok {
+				continue
+			}
+			pop, push = callee.Args, n
+		case Ret:
+			pop = h
+		case List:
+			pop = o.args[0]
+		}
+		if h < pop {
+			if !check {
+				return 0, false
+			}
+			v.fail(i, "stack underflow: %d values, popping %d", h, pop)
+		}
+		if o.code == Ret {
+			if returns >= 0 && h != returns {
+				if !check {
+					return 0, false
+				}
+				v.fail(i, "returning %d values, %d on another ret", h, returns)
+			}
+			returns = h
+		}
+		h += push - pop
+		for _, next := range v.next(i) {
+			if old, ok := heights[next]; !ok {
+				heights[next] = h
+				work = append(work, next)
+			} else if old != h {
+				if !check {
+					return 0, false
+				}
+				v.fail(next, "%d values on the stack from a path, %d from another", h, old)
+			}
+		}
+	}
+	return returns, returns >= 0
+}
+
+// next returns the instructions that can run after the instruction i.
+func (v *verifier) next(i int) []int {
+	o := v.ops[i]
+	switch o.code {
+	case Ret, Halt:
+		return nil
+	case Br:
+		return []int{o.args[0]}
+	case Brt, Brf:
+		return []int{i + 1, o.args[0]}
+	}
+	return []int{i + 1}
+}
@@ -0,0 +1,114 @@
+package bytecode
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	valid := []struct {
+		name string
+		src  string
+		m    Machine
+	}{
+		{"fact", fact, Stack},
+		{"register fact", registerFact, Register},
+		{"loop", loop("10", "    sconst \"a\"\n    print"), Stack},
+		{"halt", "iconst 1\nbrt end\nhalt\nend:", Stack},
+		{
+			// f never returns, the code after calling it never runs
+			"no return",
+			".def f: args=0, locals=0\nloop:\n    br loop\n.def main: args=0, locals=0\n    call f()\n    print\n    halt",
+			Stack,
+		},
+	}
+	for _, tc := range valid {
+		if err := Verify(mustAssemble(t, tc.src, tc.m)); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+
+	cases := []struct {
+		src  string
+		m    Machine
+		want string
+	}{
+		{"iadd", Stack, "0000 iadd: stack underflow: 0 values, popping 2"},
+		{"iconst 1\nlist 2", Stack, "0005 list 2: stack underflow: 1 values, popping 2"},
+		{"load 0", Stack, "0000 load 0: local 0 out of range"},
+		{".globals 1\ngload 1", Stack, "0000 gload 1: global 1 out of range"},
+		{"struct -1", Stack, "0000 struct -1: negative operand -1"},
+		{
+			"iconst 0\nbconst true\nbrt skip\npop\nskip:\nhalt",
+			Stack,
+			"0016 halt: 0 values on the stack from a path, 1 from another",
+		},
+		{
+			".def f: args=0, locals=0\n    iconst 1\n    brt one\n    ret\none:\n    iconst 1\n    ret",
+			Stack,
+			"0010 ret: returning 0 values, 1 on another ret",
+		},
+		{
+			".def f: args=0, locals=0\n    iconst 1\n    pop\n.def g: args=0, locals=0\n    ret",
+			Stack,
+			"0005 pop: runs on into g",
+		},
+		{
+			".def f: args=0, locals=0\n    br g\n.def g: args=0, locals=0\ng:\n    ret",
+			Stack,
+			"0000 br L5: branch out of f",
+		},
+		{
+			".def f: args=1, locals=0\n    load 0\n    ret\n.def main: args=0, locals=0\n    call f()\n    halt",
+			Stack,
+			"0006 call f(): stack underflow: 0 values, popping 1",
+		},
+		{"iconst r1, 1", Register, "0000 iconst r1, 1: register r1 out of range"},
+		{
+			".def f: args=2, locals=0\n    ret\n.def main: args=0, locals=1\n    call f(), r1\n    halt",
+			Register,
+			"0001 call f(), r1: registers r1 to r2 out of range",
+		},
+	}
+	for _, tc := range cases {
+		err := Verify(mustAssemble(t, tc.src, tc.m))
+		var e *Error
+		if !errors.As(err, &e) || err.Error() != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.src, err, tc.want)
+		}
+	}
+
+	progs := []struct {
+		prog *Program
+		want string
+	}{
+		{&Program{Code: []byte{200}}, "0000 opcode(200): invalid opcode 200"},
+		{&Program{Code: []byte{byte(Iconst), 0, 0}}, "0000 iconst: truncated instruction"},
+		{&Program{Code: []byte{byte(Br), 0, 0, 0, 1}}, "0000 br L1: branch to 1, not an instruction"},
+		{&Program{Code: []byte{byte(Fconst), 0, 0, 0, 0}, Pool: []any{"s"}}, "0000 fconst \"s\": pool entry 0 isn't a float"},
+		{&Program{Code: []byte{byte(Call), 0, 0, 0, 1}, Pool: []any{"s"}}, "0000 call 1: pool entry 1 out of range"},
+		// the header of a corrupt object file, which the machines would make
+		// gigabytes of slots for
+		{&Program{Globals: 1<<32 - 16}, "0000 : 4294967280 globals, not between 0 and 1048576"},
+		{
+			&Program{Code: []byte{byte(Halt)}, Pool: []any{&Function{Name: "f", Args: 1, Locals: 1 << 32}}},
+			"0000 halt: function f has 1 arguments and 4294967296 locals, not between 0 and 1048576",
+		},
+	}
+	for _, tc := range progs {
+		if err := Verify(tc.prog); err == nil || err.Error() != tc.want {
+			t.Errorf("got %v, want %s", err, tc.want)
+		}
+	}
+}
+
+// TestOpcodeTable checks the stack machine runs exactly the instructions
+// the table gives it.
+func TestOpcodeTable(t *testing.T) {
+	for op := range stackTable {
+		_, ok := Stack.instruction(Opcode(op))
+		if ok != (stackTable[op] != nil) {
+			t.Errorf("%v: in the table %v, run by the machine %v", Opcode(op), ok, stackTable[op] != nil)
+		}
+	}
+}
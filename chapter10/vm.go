@@ -0,0 +1,429 @@
+package bytecode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// Pattern 27:
+// Stack-Based Bytecode Interpreter
+
+// A stack machine takes the operands of its instructions from a stack and
+// pushes their results back: iadd pops two ints and pushes their sum, so
+// x = 1 + y becomes
+//
+//	iconst 1
+//	gload 1       // y
+//	iadd
+//	gstore 0      // x
+//
+// Instructions need no operand to say where their values are, which keeps
+// the code small and easy to generate: compiling an expression is emitting
+// the code of its operands, then the instruction of its operator.
+//
+// The machine runs a fetch-decode-execute loop: it reads the opcode at the
+// instruction pointer ip, then the operands that follow, and executes the
+// instruction, calling the method of the opcode, see dispatch.go. Branches and
+// calls set ip, the other instructions leave it pointing at the next one.
+//
+// A call pops its arguments from the operand stack into a new frame on the
+// call stack, which also has room for the locals and the address to return
+// to. Load and store read and write the arguments and locals of the frame on
+// top of it, the current call. Ret pops the frame and goes back to the return
+// address, leaving the value returned, if any, on the operand stack.
+//
+// A program starts at its main function, in a frame of its own, or at the
+// start of its code if it has none, and ends at a halt, at the end of its
+// code, or when main returns.
+//
+// To see how it runs, set Trace: after each instruction the machine writes
+// it with what it left on the stack and the calls running,
+//
+//	0000 load 0               stack=[5] calls=[main fact]
+//	0005 iconst 2             stack=[5, 2] calls=[main fact]
+//	0010 ilt                  stack=[false] calls=[main fact]
+//
+// the register machine writing the registers of the current call instead.
+
+// StackVM runs a Program on an operand stack.
+type StackVM struct {
+	Stdout       io.Writer // where print writes, os.Stdout by default
+	Instructions int       // the number of instructions the last Run ran
+	Trace        io.Writer // if not nil, where each instruction run is traced
+	Limits       Limits    // DefaultLimits by default
+	GC           GCStats   // the collector's statistics of the last Run
+	Dispatch     Dispatch  // TableDispatch by default, see dispatch.go
+	machine
+	stack []any // operand stack
+}
+
+func NewStackVM(p *Program) *StackVM {
+	return &StackVM{Stdout: os.Stdout, Limits: DefaultLimits, machine: machine{prog: p}}
+}
+
+// Run runs the program from its start, with its globals set to null.
+func (vm *StackVM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext runs the program as Run does, stopping it with an Error once
+// ctx is done.
+func (vm *StackVM) RunContext(ctx context.Context) (err error) {
+	defer bailout(&err)
+	defer func() { vm.Instructions, vm.GC = vm.instructions, vm.heap.statistics() }()
+	vm.stack = vm.stack[:0]
+	vm.operands = &vm.stack
+	vm.run(ctx, vm.Limits)
+	vm.start(0)
+	vm.exec()
+	return nil
+}
+
+func (vm *StackVM) exec() {
+	if vm.Dispatch == SwitchDispatch {
+		vm.switchLoop()
+	} else {
+		vm.tableLoop()
+	}
+}
+
+func (vm *StackVM) execIadd() {
+	y, x := vm.int(), vm.int()
+	vm.push(x + y)
+}
+
+func (vm *StackVM) execIsub() {
+	y, x := vm.int(), vm.int()
+	vm.push(x - y)
+}
+
+func (vm *StackVM) execImul() {
+	y, x := vm.int(), vm.int()
+	vm.push(x * y)
+}
+
+func (vm *StackVM) execIlt() {
+	y, x := vm.int(), vm.int()
+	vm.push(x < y)
+}
+
+func (vm *StackVM) execIeq() {
+	y, x := vm.int(), vm.int()
+	vm.push(x == y)
+}
+
+func (vm *StackVM) execFadd() {
+	y, x := vm.float(), vm.float()
+	vm.push(x + y)
+}
+
+func (vm *StackVM) execFsub() {
+	y, x := vm.float(), vm.float()
+	vm.push(x - y)
+}
+
+func (vm *StackVM) execFmul() {
+	y, x := vm.float(), vm.float()
+	vm.push(x * y)
+}
+
+func (vm *StackVM) execFlt() {
+	y, x := vm.float(), vm.float()
+	vm.push(x < y)
+}
+
+func (vm *StackVM) execFeq() {
+	y, x := vm.float(), vm.float()
+	vm.push(x == y)
+}
+
+func (vm *StackVM) execItof() {
+	vm.push(float64(vm.int()))
+}
+
+func (vm *StackVM) execCall() {
+	vm.call(vm.function(vm.operand()))
+}
+
+func (vm *StackVM) execRet() {
+	vm.ret()
+}
+
+func (vm *StackVM) execBr() {
+	vm.ip = vm.operand()
+}
+
+func (vm *StackVM) execBrt() {
+	addr := vm.operand()
+	if vm.bool() {
+		vm.ip = addr
+	}
+}
+
+func (vm *StackVM) execBrf() {
+	addr := vm.operand()
+	if !vm.bool() {
+		vm.ip = addr
+	}
+}
+
+func (vm *StackVM) execCconst() {
+	vm.push(rune(vm.operand()))
+}
+
+func (vm *StackVM) execIconst() {
+	vm.push(vm.operand())
+}
+
+func (vm *StackVM) execFconst() {
+	vm.push(vm.constant(Fconst, vm.operand()))
+}
+
+func (vm *StackVM) execSconst() {
+	vm.push(vm.constant(Sconst, vm.operand()))
+}
+
+func (vm *StackVM) execLoad() {
+	locals := vm.locals()
+	vm.push(locals[vm.index(vm.operand(), len(locals), "local")])
+}
+
+func (vm *StackVM) execGload() {
+	vm.push(vm.globals[vm.index(vm.operand(), len(vm.globals), "global")])
+}
+
+func (vm *StackVM) execFload() {
+	i, s := vm.operand(), vm.structure(vm.pop())
+	vm.push(s.Fields[vm.index(i, len(s.Fields), "field")])
+}
+
+func (vm *StackVM) execStore() {
+	locals := vm.locals()
+	locals[vm.index(vm.operand(), len(locals), "local")] = vm.pop()
+}
+
+func (vm *StackVM) execGstore() {
+	vm.globals[vm.index(vm.operand(), len(vm.globals), "global")] = vm.pop()
+}
+
+func (vm *StackVM) execFstore() {
+	i, v, s := vm.operand(), vm.pop(), vm.structure(vm.pop())
+	s.Fields[vm.index(i, len(s.Fields), "field")] = v
+}
+
+func (vm *StackVM) execPrint() {
+	fmt.Fprintln(vm.Stdout, format(vm.pop()))
+}
+
+func (vm *StackVM) execStruct() {
+	vm.push(vm.newStruct(vm.operand()))
+}
+
+func (vm *StackVM) execNull() {
+	vm.push(nil)
+}
+
+func (vm *StackVM) execPop() {
+	vm.pop()
+}
+
+func (vm *StackVM) execHalt() {
+	vm.ip = -1
+}
+
+func (vm *StackVM) execIdiv() {
+	y, x := vm.int(), vm.int()
+	if y == 0 {
+		vm.fail("division by zero")
+	}
+	vm.push(x / y)
+}
+
+func (vm *StackVM) execIneg() {
+	vm.push(-vm.int())
+}
+
+func (vm *StackVM) execIle() {
+	y, x := vm.int(), vm.int()
+	vm.push(x <= y)
+}
+
+func (vm *StackVM) execIgt() {
+	y, x := vm.int(), vm.int()
+	vm.push(x > y)
+}
+
+func (vm *StackVM) execIge() {
+	y, x := vm.int(), vm.int()
+	vm.push(x >= y)
+}
+
+func (vm *StackVM) execIne() {
+	y, x := vm.int(), vm.int()
+	vm.push(x != y)
+}
+
+func (vm *StackVM) execFdiv() {
+	y, x := vm.float(), vm.float()
+	vm.push(x / y)
+}
+
+func (vm *StackVM) execFneg() {
+	vm.push(-vm.float())
+}
+
+func (vm *StackVM) execFle() {
+	y, x := vm.float(), vm.float()
+	vm.push(x <= y)
+}
+
+func (vm *StackVM) execFgt() {
+	y, x := vm.float(), vm.float()
+	vm.push(x > y)
+}
+
+func (vm *StackVM) execFge() {
+	y, x := vm.float(), vm.float()
+	vm.push(x >= y)
+}
+
+func (vm *StackVM) execFne() {
+	y, x := vm.float(), vm.float()
+	vm.push(x != y)
+}
+
+func (vm *StackVM) execCtoi() {
+	vm.push(int(as[rune](&vm.machine, vm.pop())))
+}
+
+func (vm *StackVM) execItoc() {
+	vm.push(rune(vm.int()))
+}
+
+func (vm *StackVM) execSadd() {
+	y, x := vm.string(), vm.string()
+	vm.push(vm.newString(x, y))
+}
+
+func (vm *StackVM) execSeq() {
+	y, x := vm.string(), vm.string()
+	vm.push(x == y)
+}
+
+func (vm *StackVM) execSne() {
+	y, x := vm.string(), vm.string()
+	vm.push(x != y)
+}
+
+func (vm *StackVM) execBconst() {
+	vm.push(vm.operand() != 0)
+}
+
+func (vm *StackVM) execNot() {
+	vm.push(!vm.bool())
+}
+
+func (vm *StackVM) execBeq() {
+	y, x := vm.bool(), vm.bool()
+	vm.push(x == y)
+}
+
+func (vm *StackVM) execBne() {
+	y, x := vm.bool(), vm.bool()
+	vm.push(x != y)
+}
+
+func (vm *StackVM) execDup() {
+	v := vm.pop()
+	vm.push(v)
+	vm.push(v)
+}
+
+func (vm *StackVM) execList() {
+	n := vm.operand()
+	if n < 0 || n > len(vm.stack) {
+		vm.fail("%d elements on a stack of %d", n, len(vm.stack))
+	}
+	elements := slices.Clone(vm.stack[len(vm.stack)-n:])
+	vm.stack = vm.stack[:len(vm.stack)-n]
+	vm.push(vm.newList(elements))
+}
+
+func (vm *StackVM) execLload() {
+	i, l := vm.int(), vm.list()
+	vm.push(l.Elements[vm.index(i, len(l.Elements), "element")])
+}
+
+func (vm *StackVM) execLstore() {
+	v, i, l := vm.pop(), vm.int(), vm.list()
+	l.Elements[vm.index(i, len(l.Elements), "element")] = v
+}
+
+func (vm *StackVM) execLlen() {
+	vm.push(len(vm.list().Elements))
+}
+
+func (vm *StackVM) execLappend() {
+	v, l := vm.pop(), vm.list()
+	vm.appendElement(l, v)
+}
+
+func (vm *StackVM) execIaddc() {
+	n := vm.operand()
+	vm.push(vm.int() + n)
+}
+
+func (vm *StackVM) execLadd() {
+	x, y := vm.local(vm.operand()), vm.local(vm.operand())
+	vm.push(x + y)
+}
+
+func (vm *StackVM) execLinc() {
+	locals := vm.locals()
+	i, n := vm.index(vm.operand(), len(locals), "local"), vm.operand()
+	locals[i] = as[int](&vm.machine, locals[i]) + n
+}
+
+// call starts running f, with the arguments on top of the stack.
+func (vm *StackVM) call(f *Function) {
+	locals := make([]any, f.Args+f.Locals)
+	for i := f.Args - 1; i >= 0; i-- {
+		locals[i] = vm.pop()
+	}
+	vm.enter(frame{fn: f, ret: vm.ip, locals: locals})
+}
+
+func (vm *StackVM) locals() []any {
+	return vm.calls[len(vm.calls)-1].locals
+}
+
+// local returns the int in the local i.
+func (vm *StackVM) local(i int) int {
+	locals := vm.locals()
+	return as[int](&vm.machine, locals[vm.index(i, len(locals), "local")])
+}
+
+func (vm *StackVM) push(v any) {
+	if max := vm.limits.Stack; max > 0 && len(vm.stack) >= max {
+		vm.fail("%w", ErrStack)
+	}
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *StackVM) pop() any {
+	if len(vm.stack) == 0 {
+		vm.fail("empty stack")
+	}
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v
+}
+
+func (vm *StackVM) int() int         { return as[int](&vm.machine, vm.pop()) }
+func (vm *StackVM) float() float64   { return as[float64](&vm.machine, vm.pop()) }
+func (vm *StackVM) bool() bool       { return as[bool](&vm.machine, vm.pop()) }
+func (vm *StackVM) string() string   { return as[*String](&vm.machine, vm.pop()).Value }
+func (vm *StackVM) list() *ListSpace { return as[*ListSpace](&vm.machine, vm.pop()) }
@@ -0,0 +1,369 @@
+package bytecode
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStackVM(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"fact", fact, "120\n"},
+		{
+			"arithmetic",
+			`iconst 7
+    iconst 2
+    isub
+    iconst 3
+    imul
+    print
+    fconst 1.5
+    iconst 2
+    itof
+    fmul
+    print
+    iconst 1
+    iconst 2
+    ilt
+    print
+    fconst 1.5
+    fconst 1.5
+    feq
+    print`,
+			"15\n3.0\ntrue\ntrue\n",
+		},
+		{
+			"constants",
+			`sconst "hi"
+    print
+    cconst 'c'
+    print
+    null
+    print`,
+			"hi\n'c'\nnull\n",
+		},
+		{
+			"loop",
+			`.globals 1
+    iconst 0
+    gstore 0
+loop:
+    gload 0
+    iconst 3
+    ilt
+    brf end
+    gload 0
+    print
+    gload 0
+    iconst 1
+    iadd
+    gstore 0
+    br loop
+end:
+    halt
+    sconst "not printed"
+    print`,
+			"0\n1\n2\n",
+		},
+		{
+			"locals",
+			`.def add: args=2, locals=1
+    load 0
+    load 1
+    iadd
+    store 2
+    load 2
+    ret
+.def main: args=0, locals=0
+    iconst 1
+    iconst 2
+    call add()
+    print
+    ret
+    iconst 3
+    print`,
+			"3\n",
+		},
+		{
+			"struct",
+			`struct 2
+    gstore 0
+    gload 0
+    iconst 1
+    fstore 0
+    gload 0
+    sconst "s"
+    fstore 1
+    gload 0
+    fload 0
+    print
+    gload 0
+    print
+.globals 1`,
+			"1\n{1, \"s\"}\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Assemble(tc.src, Stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range []Dispatch{TableDispatch, SwitchDispatch} {
+				var out strings.Builder
+				vm := NewStackVM(prog)
+				vm.Stdout, vm.Dispatch = &out, d
+				if err := vm.Run(); err != nil {
+					t.Fatal(err)
+				}
+				if out.String() != tc.want {
+					t.Errorf("dispatch %d: got %q, want %q", d, out.String(), tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStackVMErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"iadd", "0000 iadd: empty stack"},
+		{"fconst 1.0\niconst 1\niadd", "0010 iadd: expecting int, found float"},
+		{"iconst 1\nbrt end\nend:", "0005 brt L10: expecting boolean, found int"},
+		{"load 0", "0000 load 0: local 0 out of range"},
+		{".globals 1\ngload 1", "0000 gload 1: global 1 out of range"},
+		{"null\nfload 0", "0001 fload 0: null struct"},
+		{"struct 1\nfload 1", "0005 fload 1: field 1 out of range"},
+	}
+	for _, tc := range cases {
+		prog, err := Assemble(tc.src, Stack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range []Dispatch{TableDispatch, SwitchDispatch} {
+			vm := NewStackVM(prog)
+			vm.Dispatch = d
+			err = vm.Run()
+			var e *Error
+			if !errors.As(err, &e) || err.Error() != tc.want {
+				t.Errorf("%q, dispatch %d: got %v, want %s", tc.src, d, err, tc.want)
+			}
+		}
+	}
+
+	prog := &Program{Code: []byte{byte(Iconst), 0, 0}}
+	if err := NewStackVM(prog).Run(); err == nil || err.Error() != "0000 iconst: truncated instruction" {
+		t.Errorf("got %v, want a truncated instruction", err)
+	}
+	prog = &Program{Code: []byte{200}}
+	for _, d := range []Dispatch{TableDispatch, SwitchDispatch} {
+		vm := NewStackVM(prog)
+		vm.Dispatch = d
+		if err := vm.Run(); err == nil || err.Error() != "0000 opcode(200): invalid opcode 200" {
+			t.Errorf("dispatch %d: got %v, want an invalid opcode", d, err)
+		}
+	}
+}
+
+func TestStackVMTrace(t *testing.T) {
+	prog, err := Assemble(`.def sq: args=1, locals=0
+    load 0
+    load 0
+    imul
+    ret
+.def main: args=0, locals=0
+    sconst "sq"
+    print
+    iconst 3
+    call sq()
+    print
+    halt
+`, Stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, trace strings.Builder
+	vm := NewStackVM(prog)
+	vm.Stdout, vm.Trace = &out, &trace
+	if err := vm.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := `0012 sconst "sq"          stack=["sq"] calls=[main]
+0017 print                stack=[] calls=[main]
+0018 iconst 3             stack=[3] calls=[main]
+0023 call sq()            stack=[] calls=[main sq]
+0000 load 0               stack=[3] calls=[main sq]
+0005 load 0               stack=[3, 3] calls=[main sq]
+0010 imul                 stack=[9] calls=[main sq]
+0011 ret                  stack=[9] calls=[main]
+0028 print                stack=[] calls=[main]
+0029 halt                 stack=[] calls=[main]
+`
+	if diff := cmp.Diff(want, trace.String()); diff != "" {
+		t.Error(diff)
+	}
+	if out.String() != "sq\n9\n" {
+		t.Errorf("got %q, want the output apart from the trace", out.String())
+	}
+}
+
+func TestRecursion(t *testing.T) {
+	const fib = `.def fib: args=1, locals=1
+    load 0              // if n < 2 return n
+    iconst 2
+    ilt
+    brf rec
+    load 0
+    ret
+rec:
+    load 0              // r = fib(n - 1)
+    iconst 1
+    isub
+    call fib()
+    store 1
+    load 0              // return r + fib(n - 2)
+    iconst 2
+    isub
+    call fib()
+    load 1
+    iadd
+    ret
+.def main: args=0, locals=0
+    iconst 20
+    call fib()
+    print
+`
+	// sum(n) adds 1 to n without a loop, calling itself n times
+	const sum = `.def sum: args=1, locals=0
+    load 0
+    iconst 0
+    ieq
+    brf rec
+    iconst 0
+    ret
+rec:
+    load 0
+    load 0
+    iconst 1
+    isub
+    call sum()
+    iadd
+    ret
+.def main: args=0, locals=0
+    iconst 50000
+    call sum()
+    print
+`
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"fact", fact, "120\n"},
+		{"fib", fib, "6765\n"},
+		{"deep", sum, "1250025000\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Assemble(tc.src, Stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			vm := NewStackVM(prog)
+			vm.Stdout = &out
+			if err := vm.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("got %q, want %q", out.String(), tc.want)
+			}
+			if len(vm.stack) != 0 {
+				t.Errorf("left %v on the stack", vm.stack)
+			}
+		})
+	}
+
+	prog, err := Compile(`int fib(int n) {
+    if (n < 2) return n;
+    return fib(n - 1) + fib(n - 2);
+}
+int fact(int n) {
+    if (n < 2) return 1;
+    return n * fact(n - 1);
+}
+void main() { print(fib(15)); print(fact(12)); }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	vm := NewStackVM(prog)
+	vm.Stdout = &out
+	if err := vm.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "610\n479001600\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	prog, err = Assemble(sum, Stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm = NewStackVM(prog)
+	vm.Stdout = io.Discard
+	vm.Limits.Depth = 1000
+	err = vm.Run()
+	if !errors.Is(err, ErrDepth) || err.Error() != "0038 call sum(): too many nested calls" {
+		t.Errorf("got %v, want too many nested calls", err)
+	}
+	vm.Limits.Depth = 0
+	if err := vm.Run(); err != nil {
+		t.Errorf("got %v without a limit", err)
+	}
+}
+
+// BenchmarkDispatch compares the dispatch of the stack machine on a loop
+// and on recursive calls.
+func BenchmarkDispatch(b *testing.B) {
+	programs := []struct {
+		name string
+		src  string
+	}{
+		{"loop", loop("10000", `
+    load 0
+    iconst 2
+    imul
+    pop`)},
+		{"fact", fact},
+	}
+	for _, p := range programs {
+		prog, err := Assemble(p.src, Stack)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, d := range []struct {
+			name     string
+			dispatch Dispatch
+		}{{"table", TableDispatch}, {"switch", SwitchDispatch}} {
+			b.Run(p.name+"/"+d.name, func(b *testing.B) {
+				vm := NewStackVM(prog)
+				vm.Stdout, vm.Dispatch = io.Discard, d.dispatch
+				for range b.N {
+					if err := vm.Run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/translate"
+)
+
+// example has a nested struct, and a struct used before its declaration
+const example = `struct point { int x; float y; };
+struct line {
+    struct end { point p; char label; };
+    end from;
+    end to;
+    style look;
+};
+struct style { boolean dashed; string color; };
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+// run translates the structs of the Cymbol program given as arguments, or of
+// the example, into Go struct declarations and prints them:
+//
+//	gen [-package name] [-target go|python | -template file] 'struct point { int x; int y; };'
+//
+// With -target the structs are translated by the template of a built-in
+// target, and with -template by the template in a file, see template.go.
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	pkg := fs.String("package", "main", "the `name` of the package of the output")
+	target := fs.String("target", "", "translate with the template of a built-in target: "+strings.Join(translate.Targets(), " or "))
+	file := fs.String("template", "", "translate with the template in a `file`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src := example
+	if fs.NArg() > 0 {
+		src = strings.Join(fs.Args(), " ")
+	}
+	if *target != "" && *file != "" {
+		return errors.New("-target and -template can't be used together")
+	}
+	if *target != "" || *file != "" {
+		return cymbol.WithSource(src, execute(src, *pkg, *target, *file, out))
+	}
+	f, err := translate.GoStructs(src, *pkg)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	b, err := f.Render()
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(b)
+	return err
+}
+
+// execute translates the structs of src with the template of the target, or
+// the one in file.
+func execute(src, pkg, target, file string, out io.Writer) error {
+	var tmpl *translate.Template
+	var err error
+	if target != "" {
+		tmpl, err = translate.Target(target)
+	} else {
+		var text []byte
+		if text, err = os.ReadFile(file); err == nil {
+			tmpl, err = translate.NewTemplate(filepath.Base(file), string(text))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m, err := translate.NewModel(src, pkg)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(out, m)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	var out strings.Builder
+	if err := run([]string{"-package", "shapes", "struct point { int x; int y; };"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated from Cymbol structs. DO NOT EDIT.
+package shapes
+
+// point is struct point at 1:1.
+type point struct {
+	x int
+	y int
+}
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Error(diff)
+	}
+
+	out.Reset()
+	if err := run(nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "type line_end struct {") {
+		t.Errorf("the example has no line_end:\n%s", out.String())
+	}
+
+	if err := run([]string{"struct a { b x; };"}, &out); err == nil {
+		t.Error("want: error, got: nil")
+	}
+
+	out.Reset()
+	if err := run([]string{"-target", "python", "struct point { int x; };"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "class point:\n") {
+		t.Errorf("no class point:\n%s", out.String())
+	}
+
+	file := filepath.Join(t.TempDir(), "names.tmpl")
+	if err := os.WriteFile(file, []byte(`{{range .Structs}}{{.Name}}{{"\n"}}{{end}}`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := run([]string{"-template", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "point\nline\nline_end\nstyle\n"; out.String() != want {
+		t.Errorf("want: %q, got: %q", want, out.String())
+	}
+
+	for _, args := range [][]string{{"-target", "cobol"}, {"-target", "go", "-template", file}, {"-template", "none.tmpl"}} {
+		if err := run(args, &strings.Builder{}); err == nil {
+			t.Errorf("%q: want: error, got: nil", args)
+		}
+	}
+}
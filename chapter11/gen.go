@@ -0,0 +1,114 @@
+// Package translate has the translators of the book's chapter 11, which turn
+// Cymbol programs into text in another language.
+package translate
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// Pattern 31:
+// Target-Specific Generator Classes
+
+// A translator printing the output as it walks the input has to print it in
+// the order of the output, which is rarely the order of the input: a Go file
+// starts with its package clause and imports, which it only knows once it
+// has seen everything. Instead the translator builds a model of the output,
+// an object for each construct of the target language, the generator
+// classes, in whatever order it finds them, and renders the whole model once
+// it's done. Each class renders its own construct, the way templates would.
+//
+// The model here is for Go: a GoFile holding GoStructs, with GoFields of a
+// GoType, enough for GoStructs to translate Cymbol structs into Go struct
+// declarations. The model is rendered as text and then formatted by gofmt,
+// which aligns the fields and their types.
+
+// GoFile is a Go source file.
+type GoFile struct {
+	Package string
+	Comment string // the comment on the package clause, none if empty
+	Decls   []GoDecl
+}
+
+// GoDecl is a top level declaration of a Go file.
+type GoDecl interface {
+	fmt.Stringer
+	goDecl()
+}
+
+// GoStruct is the declaration of a struct type.
+type GoStruct struct {
+	Name    string
+	Comment string
+	Fields  []*GoField
+}
+
+// GoField is a field of a GoStruct.
+type GoField struct {
+	Name string
+	Type GoType
+}
+
+// GoType is a Go type.
+type GoType interface {
+	fmt.Stringer
+	goType()
+}
+
+// GoName is a type named by an identifier, int or point.
+type GoName string
+
+// GoPointer is a pointer type, *point.
+type GoPointer struct {
+	Elem GoType
+}
+
+func (*GoStruct) goDecl()  {}
+func (GoName) goType()     {}
+func (*GoPointer) goType() {}
+
+// Render returns the source of the file, formatted by gofmt.
+func (f *GoFile) Render() ([]byte, error) {
+	return format.Source([]byte(f.String()))
+}
+
+// String returns the source of the file, unformatted.
+func (f *GoFile) String() string {
+	var s strings.Builder
+	s.WriteString(comment(f.Comment))
+	fmt.Fprintf(&s, "package %s\n", f.Package)
+	for _, d := range f.Decls {
+		fmt.Fprintf(&s, "\n%v\n", d)
+	}
+	return s.String()
+}
+
+func (d *GoStruct) String() string {
+	var s strings.Builder
+	s.WriteString(comment(d.Comment))
+	fmt.Fprintf(&s, "type %s struct {\n", d.Name)
+	for _, f := range d.Fields {
+		fmt.Fprintf(&s, "%v\n", f)
+	}
+	s.WriteString("}")
+	return s.String()
+}
+
+func (f *GoField) String() string { return f.Name + " " + f.Type.String() }
+
+func (n GoName) String() string { return string(n) }
+
+func (p *GoPointer) String() string { return "*" + p.Elem.String() }
+
+// comment returns text as the lines of a // comment, nothing if it's empty.
+func comment(text string) string {
+	if text == "" {
+		return ""
+	}
+	var s strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		s.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+	}
+	return s.String()
+}
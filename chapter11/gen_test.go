@@ -0,0 +1,108 @@
+package translate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGoFile(t *testing.T) {
+	f := &GoFile{
+		Package: "p",
+		Comment: "Package p has\ntwo lines.",
+		Decls: []GoDecl{
+			&GoStruct{Name: "empty"},
+			&GoStruct{
+				Name:    "node",
+				Comment: "node is a node.",
+				Fields: []*GoField{
+					{Name: "value", Type: GoName("float64")},
+					{Name: "next", Type: &GoPointer{Elem: GoName("node")}},
+				},
+			},
+		},
+	}
+	got, err := f.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// Package p has
+// two lines.
+package p
+
+type empty struct {
+}
+
+// node is a node.
+type node struct {
+	value float64
+	next  *node
+}
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGoStructs(t *testing.T) {
+	f, err := GoStructs(`
+struct line {
+    struct end { point p; char label; };
+    end from;
+    end to;
+    boolean range;
+};
+struct point { int x; float y; string name; };
+`, "shapes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated from Cymbol structs. DO NOT EDIT.
+package shapes
+
+// line is struct line at 2:1.
+type line struct {
+	from   *line_end
+	to     *line_end
+	range_ bool
+}
+
+// line_end is struct end at 3:5.
+type line_end struct {
+	p     *point
+	label rune
+}
+
+// point is struct point at 8:1.
+type point struct {
+	x    int
+	y    float64
+	name string
+}
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGoStructsErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"struct a { int x; ", "1:19: syntax error: expecting ID, found EOF"},
+		{"struct a { b x; };", "1:12: undefined: b"},
+		{"class c { }; struct a { c x; };", "1:27: field x: no data of type c"},
+		{"struct a { void x; };", "1:17: field x: no data of type void"},
+	}
+	for _, tc := range cases {
+		_, err := GoStructs(tc.src, "p")
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s: want: %s, got: %v", tc.src, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+module example.com/translate
+
+go 1.23.4
+
+require (
+	example.com/cymbol v0.0.0
+	example.com/symtab v0.0.0
+	github.com/google/go-cmp v0.6.0
+)
+
+replace (
+	example.com/cymbol => ../cymbol
+	example.com/symtab => ../chapter6
+)
@@ -0,0 +1,69 @@
+package translate
+
+import (
+	"fmt"
+	"go/token"
+)
+
+// Cymbol structs to Go
+//
+// GoStructs adds a GoStruct to the model of a Go file for each struct of the
+// Model of a Cymbol program, see model.go:
+//
+//	struct point { int x; float y; };    type point struct {
+//	struct line {                            x int
+//	    struct end { point p; };             y float64
+//	    end from;                        }
+//	    end to;                          ...
+//	};                                   type line_end struct {
+//	                                         p *point
+//	                                     }
+//
+// Cymbol's structs are references, a field of type point holds a point or
+// null, so the Go field is a pointer. Names that are keywords in Go, but not
+// in Cymbol, get an underscore at the end, range becomes range_.
+
+// GoStructs translates the structs of the Cymbol program src into a Go file of
+// the package pkg. The error is the one of NewModel.
+func GoStructs(src, pkg string) (*GoFile, error) {
+	m, err := NewModel(src, pkg)
+	if err != nil {
+		return nil, err
+	}
+	f := &GoFile{Package: pkg, Comment: "Code generated from Cymbol structs. DO NOT EDIT."}
+	for _, s := range m.Structs {
+		g := &GoStruct{
+			Name:    goIdent(s.Name),
+			Comment: fmt.Sprintf("%s is struct %s at %v.", goIdent(s.Name), s.Cymbol, s.Pos),
+		}
+		for _, field := range s.Fields {
+			g.Fields = append(g.Fields, &GoField{Name: goIdent(field.Name), Type: goType(field)})
+		}
+		f.Decls = append(f.Decls, g)
+	}
+	return f, nil
+}
+
+// goTypes are the Go types of Cymbol's built-in types.
+var goTypes = map[string]GoType{
+	"int":     GoName("int"),
+	"float":   GoName("float64"),
+	"char":    GoName("rune"),
+	"boolean": GoName("bool"),
+	"string":  GoName("string"),
+}
+
+func goType(f *Field) GoType {
+	if f.Struct {
+		return &GoPointer{Elem: GoName(goIdent(f.Type))}
+	}
+	return goTypes[f.Type]
+}
+
+// goIdent returns name, with an underscore at the end if it's a Go keyword.
+func goIdent(name string) string {
+	if token.IsKeyword(name) {
+		return name + "_"
+	}
+	return name
+}
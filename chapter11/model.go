@@ -0,0 +1,123 @@
+package translate
+
+import (
+	"fmt"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// The structs of a program
+//
+// The translators of structs don't walk the tree themselves: NewModel walks
+// the structs of a Cymbol program, the ones at the top level and the ones
+// nested in them, and makes a Model of them, with what the symbol table tells
+// about their fields, which the translators then turn into their own
+// language. A struct nested in another is named after both, since few
+// languages have nested types:
+//
+//	struct line {                  Struct line
+//	    struct end { point p; };       Field from line_end
+//	    end from;                  Struct line_end
+//	};                                 Field p point
+//
+// The fields can only be of the types that hold data: the built-in types but
+// void, and structs.
+
+// Model is the structs of a Cymbol program, in the order of their
+// declaration, each struct followed by the ones nested in it.
+type Model struct {
+	Package string // the package, or module, of the translation
+	Structs []*Struct
+}
+
+// Struct is a struct of a Cymbol program.
+type Struct struct {
+	Name   string // the name of the struct, after the structs it's nested in
+	Cymbol string // the name in the program
+	Pos    cymbol.Pos
+	Fields []*Field
+}
+
+// Field is a field of a Struct.
+type Field struct {
+	Name   string
+	Type   string // a built-in type, or the Name of a Struct
+	Struct bool   // whether Type is a Struct
+}
+
+func (s *Struct) String() string { return s.Name }
+
+// builtins are the built-in types of the fields.
+var builtins = map[string]bool{"int": true, "float": true, "char": true, "boolean": true, "string": true}
+
+// NewModel returns the model of the structs of the Cymbol program src, for
+// the package pkg. The error is the syntax error, the names that don't
+// resolve all together, or the first field of a type that doesn't hold
+// data, such as void or a class.
+func NewModel(src, pkg string) (*Model, error) {
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return nil, err
+	}
+	defs, _ := symtab.TwoPass(symtab.NewSymbolTable(), f)
+	if err := defs.Diagnostics.Err(); err != nil {
+		return nil, err
+	}
+	b := &modelBuilder{defs: defs.Defs, model: &Model{Package: pkg}}
+	for _, d := range f.Decls {
+		if s, ok := d.(*cymbol.StructDecl); ok {
+			if err := b.structDecl(s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.model, nil
+}
+
+type modelBuilder struct {
+	defs  map[*cymbol.Ident]symtab.Symbol
+	model *Model
+}
+
+// structDecl adds the struct d to the model, followed by the structs nested
+// in it.
+func (b *modelBuilder) structDecl(d *cymbol.StructDecl) error {
+	s := &Struct{Name: structName(b.defs[d.Name].(*symtab.StructSymbol)), Cymbol: d.Name.Name, Pos: d.Pos()}
+	b.model.Structs = append(b.model.Structs, s)
+	var nested []*cymbol.StructDecl
+	for _, field := range d.Fields {
+		switch field := field.(type) {
+		case *cymbol.VarDecl:
+			v := b.defs[field.Name]
+			f := &Field{Name: v.Name()}
+			switch t := v.Type().(type) {
+			case *symtab.StructSymbol:
+				f.Type, f.Struct = structName(t), true
+			case *symtab.BuiltInTypeSymbol:
+				f.Type = t.Name()
+			}
+			if !f.Struct && !builtins[f.Type] {
+				return &cymbol.Error{Pos: field.Name.Pos(), Err: fmt.Errorf("field %s: no data of type %s", v.Name(), v.Type().Name())}
+			}
+			s.Fields = append(s.Fields, f)
+		case *cymbol.StructDecl:
+			nested = append(nested, field)
+		}
+	}
+	for _, d := range nested {
+		if err := b.structDecl(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structName returns the name of s, after the names of the structs it's
+// nested in.
+func structName(s *symtab.StructSymbol) string {
+	if outer, ok := s.Scope().(*symtab.StructSymbol); ok {
+		return structName(outer) + "_" + s.Name()
+	}
+	return s.Name()
+}
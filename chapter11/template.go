@@ -0,0 +1,136 @@
+package translate
+
+import (
+	"embed"
+	"fmt"
+	"go/format"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Templates
+//
+// Generator classes put the output in Go code; a template puts it in a file
+// of the target language with holes, which someone who knows the target but
+// not the translator can change. Template executes the Model of a program,
+// see model.go, with text/template: a target is a template file, and a new
+// target is a new file, the translator staying the same. The targets of
+// templates/ are built in:
+//
+//	go        the structs as Go structs, like GoStructs
+//	python    the structs as Python dataclasses
+//
+// On top of the functions of text/template, the templates have:
+//
+//	include name data   the output of the template name, to pipe into the
+//	                    other functions, where template can't be
+//	indent prefix s     s with prefix at the start of each line but the
+//	                    empty ones
+//	join sep list       the elements of list, printed, separated by sep
+//	quote s             s as a double-quoted string
+//	in s words          whether s is one of the space-separated words
+//
+// The mapping of Cymbol's types to the types of the target is in the template,
+// since it's about the target; so are its keywords, which in tells apart.
+
+//go:embed templates/*.tmpl
+var targets embed.FS
+
+// Template is a template rendering the Model of a program.
+type Template struct {
+	tmpl   *template.Template
+	format func([]byte) ([]byte, error) // formats the output, if not nil
+}
+
+// NewTemplate parses the template text, named name.
+func NewTemplate(name, text string) (*Template, error) {
+	t := template.New(name)
+	t.Funcs(template.FuncMap{
+		"include": func(name string, data any) (string, error) {
+			var s strings.Builder
+			err := t.ExecuteTemplate(&s, name, data)
+			return s.String(), err
+		},
+		"indent": indent,
+		"join":   join,
+		"quote":  strconv.Quote,
+		"in": func(s, words string) bool {
+			return slices.Contains(strings.Fields(words), s)
+		},
+	})
+	if _, err := t.Parse(text); err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: t}, nil
+}
+
+// Target returns the built-in template of the target. The output of the Go
+// target is formatted by gofmt.
+func Target(target string) (*Template, error) {
+	text, err := targets.ReadFile("templates/" + target + ".tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("unknown target %q, want %s", target, strings.Join(Targets(), " or "))
+	}
+	t, err := NewTemplate(target, string(text))
+	if err != nil {
+		return nil, err
+	}
+	if target == "go" {
+		t.format = format.Source
+	}
+	return t, nil
+}
+
+// Targets returns the names of the built-in targets, sorted.
+func Targets() []string {
+	entries, _ := targets.ReadDir("templates")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".tmpl"))
+	}
+	return names
+}
+
+// Execute writes the output of the template for m to w.
+func (t *Template) Execute(w io.Writer, m *Model) error {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, m); err != nil {
+		return err
+	}
+	out := []byte(b.String())
+	if t.format != nil {
+		var err error
+		if out, err = t.format(out); err != nil {
+			return fmt.Errorf("%s: %w", t.tmpl.Name(), err)
+		}
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+func indent(prefix, s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// join formats the elements of the slice list with %v.
+func join(sep string, list any) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: %T isn't a list", list)
+	}
+	elems := make([]string, v.Len())
+	for i := range elems {
+		elems[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(elems, sep), nil
+}
@@ -0,0 +1,134 @@
+package translate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const shapes = `
+struct point { int x; float y; };
+struct line {
+    struct end { point p; char from; };
+    end a;
+    boolean range;
+    string label;
+};
+`
+
+func TestTargets(t *testing.T) {
+	if diff := cmp.Diff([]string{"go", "python"}, Targets()); diff != "" {
+		t.Error(diff)
+	}
+	m, err := NewModel(shapes, "shapes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the go target is the same as the generator classes
+	f, err := GoStructs(shapes, "shapes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := f.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), execute(t, "go", m)); diff != "" {
+		t.Error(diff)
+	}
+
+	if diff := cmp.Diff(`# Code generated from Cymbol structs. DO NOT EDIT.
+"""The structs of shapes: point, line, line_end."""
+
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Optional
+
+__all__ = ["point", "line", "line_end"]
+
+
+@dataclass
+class point:
+    """struct point at 2:1"""
+
+    x: int = 0
+    y: float = 0.0
+
+
+@dataclass
+class line:
+    """struct line at 3:1"""
+
+    a: Optional[line_end] = None
+    range: bool = False
+    label: str = ""
+
+
+@dataclass
+class line_end:
+    """struct end at 4:5"""
+
+    p: Optional[point] = None
+    from_: str = "\0"
+`, execute(t, "python", m)); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, err := Target("cobol"); err == nil || err.Error() != `unknown target "cobol", want go or python` {
+		t.Errorf("want: an unknown target, got: %v", err)
+	}
+}
+
+func execute(t *testing.T, target string, m *Model) string {
+	t.Helper()
+	tmpl, err := Target(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s strings.Builder
+	if err := tmpl.Execute(&s, m); err != nil {
+		t.Fatal(err)
+	}
+	return s.String()
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	m := &Model{Package: "p", Structs: []*Struct{{Name: "a"}, {Name: "b"}}}
+	cases := []struct {
+		text string
+		want string
+	}{
+		{`{{join ", " .Structs}}`, "a, b"},
+		{`{{quote .Package}}`, `"p"`},
+		{`{{"x\n\ny\n" | indent "  "}}`, "  x\n\n  y\n"},
+		{`{{define "s"}}[{{.Name}}]{{end}}{{range .Structs}}{{include "s" . | printf "%q"}}{{end}}`, `"[a]""[b]"`},
+		{`{{if in "b" "a b c"}}in{{end}}{{if in "d" "a b c"}}out{{end}}`, "in"},
+	}
+	for _, tc := range cases {
+		tmpl, err := NewTemplate("t", tc.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s strings.Builder
+		if err := tmpl.Execute(&s, m); err != nil {
+			t.Errorf("%s: %v", tc.text, err)
+		} else if s.String() != tc.want {
+			t.Errorf("%s: want: %q, got: %q", tc.text, tc.want, s.String())
+		}
+	}
+
+	tmpl, err := NewTemplate("t", `{{join ", " .Package}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tmpl.Execute(&strings.Builder{}, m)
+	if want := "join: string isn't a list"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+	if _, err := NewTemplate("t", "{{if}}"); err == nil {
+		t.Error("want: a syntax error, got: nil")
+	}
+}
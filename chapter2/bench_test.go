@@ -0,0 +1,63 @@
+package llparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// nestedList is a list of n elements, assignments to lists nesting depth
+// lists such as `a=[b,[b,[b]]]`.
+func nestedList(n, depth int) string {
+	elem := "a=" + strings.Repeat("[b,", depth-1) + "[b" + strings.Repeat("]", depth)
+	elems := make([]string, n)
+	for i := range elems {
+		elems[i] = elem
+	}
+	return "[" + strings.Join(elems, ",") + "]"
+}
+
+// BenchmarkParsers runs the LL(1) parser and the LL(k) one with a few k over
+// longer lists and deeper ones, along with the lexer alone for what's left
+// of the parsing:
+//
+//	go test -bench Parsers -run XXX
+//
+// Both parsers are linear in the input whatever the depth, and allocate
+// nothing but what the lexer does and the lookahead buffer. They share the
+// buffer of package parsing, so LL(1) is LL(k) with a k of 1, and k hardly
+// matters: the buffer costs the same however far it looks. What LL(1) gets
+// out of left factoring `NAME ('=' element)?` is a grammar for which a single
+// token is enough.
+func BenchmarkParsers(b *testing.B) {
+	for _, n := range []int{10, 1000} {
+		for _, depth := range []int{1, 10, 100} {
+			input := nestedList(n, depth)
+			run := func(name string, parse func() error) {
+				b.Run(fmt.Sprintf("elements=%d/depth=%d/%s", n, depth, name), func(b *testing.B) {
+					b.ReportAllocs()
+					b.SetBytes(int64(len(input)))
+					for range b.N {
+						if err := parse(); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+			run("lexer", func() error {
+				l := NewLexer(input)
+				for {
+					tok, err := l.Next()
+					if err != nil || tok.Type == EOF {
+						return err
+					}
+				}
+			})
+			run("ll1", func() error { return ParseLL1(input) })
+			run("table", func() error { return ParseLL1Table(input) })
+			for _, k := range []int{2, 4} {
+				run(fmt.Sprintf("llk/k=%d", k), func() error { return ParseLLk(input, k) })
+			}
+		}
+	}
+}
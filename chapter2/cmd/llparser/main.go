@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"example.com/llparser"
+)
+
+// Command llparser lexes or parses a list given as arguments, or read from the
+// standard input if there are none:
+//
+//	llparser [-parser ll1|llk|table] [-k 2] [-output tokens|errors|events]
+//	         [-trace] [-comments] [-unicode] '[a, b=c]'
+//	echo '[a, b=c]' | llparser -output errors
+//
+// The tokens are printed one per line. The parsers of this chapter recognize
+// lists without building a tree, so -output errors prints the syntax error, if
+// any, and nothing for a valid list, after the rules the parser entered with
+// -trace. -output events prints the events of the parse as it goes instead,
+// see events.go, indented by how deep the list is. The trees are in chapter 3.
+// -comments skips '#' comments and -unicode accepts names of any letters.
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// parsers parse a whole list with each of the parsers and return the syntax
+// error if there's one.
+var parsers = map[string]func(l *llparser.Lexer, opts ...llparser.ParserOption) error{
+	"ll1": func(l *llparser.Lexer, opts ...llparser.ParserOption) error {
+		return llparser.NewLL1Parser(l, opts...).Parse()
+	},
+	"llk": func(l *llparser.Lexer, opts ...llparser.ParserOption) error {
+		return llparser.NewLLkParser(l, opts...).Parse()
+	},
+	"table": func(l *llparser.Lexer, opts ...llparser.ParserOption) error {
+		return llparser.NewLL1TableParser(l, opts...).Parse()
+	},
+}
+
+func run(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("llparser", flag.ContinueOnError)
+	parser := fs.String("parser", "ll1", "the parser: ll1, llk or table")
+	k := fs.Int("k", 2, "the lookahead of the llk parser")
+	output := fs.String("output", "tokens", "what to print: tokens, errors or events")
+	trace := fs.Bool("trace", false, "print the rules the parser enters")
+	comments := fs.Bool("comments", false, "skip comments, from '#' to the end of the line")
+	unicode := fs.Bool("unicode", false, "accept names of any Unicode letters")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	parse, ok := parsers[*parser]
+	if !ok {
+		return fmt.Errorf("unknown parser %q, want ll1, llk or table", *parser)
+	}
+	if *k < 1 {
+		return fmt.Errorf("-k must be at least 1, not %d", *k)
+	}
+	var lexOpts []llparser.LexerOption
+	if *comments {
+		lexOpts = append(lexOpts, llparser.WithComments())
+	}
+	if *unicode {
+		lexOpts = append(lexOpts, llparser.WithUnicodeNames())
+	}
+	parseOpts := []llparser.ParserOption{llparser.WithLookahead(*k)}
+	if *trace {
+		parseOpts = append(parseOpts, llparser.WithTrace(out))
+	}
+
+	src := strings.Join(fs.Args(), " ")
+	if fs.NArg() == 0 {
+		b, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		src = string(b)
+	}
+
+	switch *output {
+	case "tokens":
+		l := llparser.NewLexer(src, lexOpts...)
+		for {
+			tok, err := l.Next()
+			if err != nil {
+				return err
+			}
+			if tok.Type == llparser.EOF {
+				return nil
+			}
+			if _, err := fmt.Fprintln(out, tok.Type, tok.Text); err != nil {
+				return err
+			}
+		}
+	case "errors", "events":
+		if *output == "events" {
+			parseOpts = append(parseOpts, llparser.WithHandler(&printer{w: out}))
+		}
+		err := parse(llparser.NewLexer(src, lexOpts...), parseOpts...)
+		if err == nil {
+			return nil
+		}
+		if _, werr := fmt.Fprintln(out, err); werr != nil {
+			return werr
+		}
+		return fmt.Errorf("1 syntax error found")
+	default:
+		return fmt.Errorf("unknown output %q, want tokens, errors or events", *output)
+	}
+}
+
+// printer prints the events of a parse, one per line. Errors writing them
+// are ignored, as the ones writing the trace are.
+type printer struct {
+	w     io.Writer
+	depth int
+}
+
+func (p *printer) StartList() {
+	p.print("StartList")
+	p.depth++
+}
+
+func (p *printer) EndList() {
+	p.depth--
+	p.print("EndList")
+}
+
+func (p *printer) Element(name string) { p.print("Element " + name) }
+
+func (p *printer) StartAssign(name string) {
+	p.print("StartAssign " + name)
+	p.depth++
+}
+
+func (p *printer) EndAssign() {
+	p.depth--
+	p.print("EndAssign")
+}
+
+func (p *printer) print(event string) {
+	fmt.Fprintf(p.w, "%s%s\n", strings.Repeat("  ", p.depth), event)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "tokens of the arguments",
+			args: []string{"[a,", "b]"},
+			want: "LBrack [\nName a\nComma ,\nName b\nRBrack ]\n",
+		},
+		{
+			name: "tokens of the input",
+			in:   "[a=b]\n",
+			want: "LBrack [\nName a\nEquals =\nName b\nRBrack ]\n",
+		},
+		{
+			name:    "invalid character",
+			in:      "[a#]",
+			want:    "LBrack [\nName a\n",
+			wantErr: "invalid character: #",
+		},
+		{
+			name: "valid list",
+			args: []string{"-output", "errors", "[a,[b,c]]"},
+		},
+		{
+			name:    "syntax error",
+			args:    []string{"-output", "errors", "[a=]"},
+			want:    "syntax error: expecting name or list, found {Type:RBrack Text:]}\n",
+			wantErr: "1 syntax error found",
+		},
+		{
+			name: "llk",
+			args: []string{"-parser", "llk", "-output", "errors", "[a=b]"},
+		},
+		{
+			name:    "not with one token of lookahead",
+			args:    []string{"-parser", "llk", "-k", "1", "-output", "errors", "[a=b]"},
+			want:    "syntax error: expecting RBrack, got Equals\n",
+			wantErr: "1 syntax error found",
+		},
+		{
+			name:    "table",
+			args:    []string{"-parser", "table", "-output", "errors", "[a b]"},
+			want:    "syntax error: expecting RBrack, Comma or Equals, found {Type:Name Text:b}\n",
+			wantErr: "1 syntax error found",
+		},
+		{
+			name: "events",
+			args: []string{"-output", "events", "[a,b=[c]]"},
+			want: "StartList\n  Element a\n  StartAssign b\n    StartList\n      Element c\n    EndList\n  EndAssign\nEndList\n",
+		},
+		{
+			name:    "events up to the error",
+			args:    []string{"-parser", "table", "-output", "events", "[a,]"},
+			want:    "StartList\n  Element a\nsyntax error: expecting LBrack or Name, found {Type:RBrack Text:]}\n",
+			wantErr: "1 syntax error found",
+		},
+		{
+			name:    "input after the list",
+			args:    []string{"-output", "errors", "[a] b"},
+			want:    "syntax error: expecting EOF, got Name\n",
+			wantErr: "1 syntax error found",
+		},
+		{
+			name: "trace",
+			args: []string{"-output", "errors", "-trace", "[a,[b]]"},
+			want: "list, looking at [\n  elements, looking at a\n    element, looking at a\n    element, looking at [\n      list, looking at [\n        elements, looking at b\n          element, looking at b\n",
+		},
+		{
+			name: "comments and unicode names",
+			args: []string{"-comments", "-unicode"},
+			in:   "# the list\n[año]",
+			want: "LBrack [\nName año\nRBrack ]\n",
+		},
+		{
+			name:    "unknown parser",
+			args:    []string{"-parser", "backtrack"},
+			wantErr: `unknown parser "backtrack", want ll1, llk or table`,
+		},
+		{
+			name:    "unknown output",
+			args:    []string{"-output", "tree", "[a]"},
+			wantErr: `unknown output "tree", want tokens, errors or events`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var s strings.Builder
+			err := run(tc.args, strings.NewReader(tc.in), &s)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("got error %v, want %s", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != tc.want {
+				t.Error(cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
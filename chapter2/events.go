@@ -0,0 +1,80 @@
+package llparser
+
+// Parse Events
+//
+// The parsers of this chapter recognize a list without building a tree, and
+// only say whether it was one. A Handler gets what they recognized as they
+// go, the way a SAX parser hands XML to its handler instead of building a
+// DOM: an event at each bracket, name and assignment, in the order of the
+// input. For `[a, b=[c]]`:
+//
+//	StartList
+//	Element a
+//	StartAssign b
+//	StartList
+//	Element c
+//	EndList
+//	EndAssign
+//	EndList
+//
+// The handler keeps what it needs of a list, counting names or writing it
+// out somewhere else, and the parser nothing: parsing takes memory in
+// proportion to how deep the list is, not how long. The recursive-descent
+// parsers hold the depth on Go's stack, the table-driven one on its own.
+//
+// Events stop at the first syntax error, Parse then failing, so that a
+// handler doesn't get what the parser made of the input after it. The events
+// so far are those of a list that's valid up to the error: they may leave
+// lists and assignments open, or close a list the error is that something
+// follows.
+
+// Handler handles the events of a parse, see WithHandler.
+type Handler interface {
+	StartList()              // '['
+	EndList()                // ']'
+	Element(name string)     // a name, not assigned
+	StartAssign(name string) // name '=', the events of the element follow
+	EndAssign()              // the element assigned ended
+}
+
+// emitter sends the events of a parse to the Handler of WithHandler, until
+// the parse fails.
+type emitter struct {
+	h      Handler
+	failed bool
+}
+
+// on tells whether there's a handler to send events to.
+func (e *emitter) on() bool {
+	return e.h != nil && !e.failed
+}
+
+func (e *emitter) emitStartList() {
+	if e.on() {
+		e.h.StartList()
+	}
+}
+
+func (e *emitter) emitEndList() {
+	if e.on() {
+		e.h.EndList()
+	}
+}
+
+func (e *emitter) emitElement(name string) {
+	if e.on() {
+		e.h.Element(name)
+	}
+}
+
+func (e *emitter) emitStartAssign(name string) {
+	if e.on() {
+		e.h.StartAssign(name)
+	}
+}
+
+func (e *emitter) emitEndAssign() {
+	if e.on() {
+		e.h.EndAssign()
+	}
+}
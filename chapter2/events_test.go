@@ -0,0 +1,92 @@
+package llparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recorder records the events of a parse, one per line.
+type recorder struct{ strings.Builder }
+
+func (r *recorder) StartList()              { r.WriteString("StartList\n") }
+func (r *recorder) EndList()                { r.WriteString("EndList\n") }
+func (r *recorder) Element(name string)     { r.WriteString("Element " + name + "\n") }
+func (r *recorder) StartAssign(name string) { r.WriteString("StartAssign " + name + "\n") }
+func (r *recorder) EndAssign()              { r.WriteString("EndAssign\n") }
+
+func TestWithHandler(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+		fail  bool
+	}{
+		{name: "empty list", input: "[]", want: "StartList\nEndList\n"},
+		{
+			name:  "names and lists",
+			input: "[a,[b,[]],c]",
+			want:  "StartList\nElement a\nStartList\nElement b\nStartList\nEndList\nEndList\nElement c\nEndList\n",
+		},
+		{
+			name:  "assignments",
+			input: "[a=b=[c],'d e']",
+			want:  "StartList\nStartAssign a\nStartAssign b\nStartList\nElement c\nEndList\nEndAssign\nEndAssign\nElement d e\nEndList\n",
+		},
+		{
+			name:  "up to the error",
+			input: "[a,[b=],c]",
+			want:  "StartList\nElement a\nStartList\nStartAssign b\n",
+			fail:  true,
+		},
+		{
+			name:  "input after the list",
+			input: "[a] b",
+			want:  "StartList\nElement a\nEndList\n",
+			fail:  true,
+		},
+	}
+
+	parsers := map[string]func(l *Lexer, opts ...ParserOption) error{
+		"ll1":   func(l *Lexer, opts ...ParserOption) error { return NewLL1Parser(l, opts...).Parse() },
+		"llk":   func(l *Lexer, opts ...ParserOption) error { return NewLLkParser(l, opts...).Parse() },
+		"table": func(l *Lexer, opts ...ParserOption) error { return NewLL1TableParser(l, opts...).Parse() },
+	}
+	for name, parse := range parsers {
+		for _, tc := range cases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				var r recorder
+				err := parse(NewLexer(tc.input), WithHandler(&r))
+				if got := errors.Is(err, SyntaxError); got != tc.fail {
+					t.Errorf("want syntax error: %v, got: %v", tc.fail, err)
+				}
+				if diff := cmp.Diff(tc.want, r.String()); diff != "" {
+					t.Errorf("mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+// counter counts the names of a list, keeping nothing else.
+type counter struct{ names, depth, maxDepth int }
+
+func (c *counter) StartList()         { c.depth++; c.maxDepth = max(c.maxDepth, c.depth) }
+func (c *counter) EndList()           { c.depth-- }
+func (c *counter) Element(string)     { c.names++ }
+func (c *counter) StartAssign(string) { c.names++ }
+func (c *counter) EndAssign()         {}
+
+func TestHandlerOfDeepList(t *testing.T) {
+	const depth = 100_000
+	src := strings.Repeat("[a=", depth) + "b" + strings.Repeat("]", depth)
+	var c counter
+	if err := NewLL1TableParser(NewLexer(src), WithHandler(&c)).Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (counter{names: depth + 1, maxDepth: depth}); c != want {
+		t.Errorf("want: %+v, got: %+v", want, c)
+	}
+}
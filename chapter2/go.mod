@@ -2,4 +2,9 @@ module example.com/llparser
 
 go 1.23.4
 
-require github.com/google/go-cmp v0.6.0 // indirect
+require (
+	example.com/parsing v0.0.0
+	github.com/google/go-cmp v0.6.0
+)
+
+replace example.com/parsing => ../parsing
@@ -1,8 +1,13 @@
-package main
+// Package llparser has the lexer and the recursive-descent parsers of lists
+// of the book's chapter 2: LL(1) and LL(k). They recognize lists, without
+// building a tree.
+package llparser
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // page 31, Pattern 2:
@@ -39,13 +44,18 @@ import (
 // Grammar to be matched (ANTLR syntax):
 //
 // grammar NestedNameList;
-// list     : '[' elements ']' ;       // match bracketed list
+// list     : '[' elements? ']' ;      // match bracketed list, possibly empty
 // elements : element (',' element)* ; // match comma-separated list
 // element  : NAME | list ;            // element is name or nested list
-// NAME     : ('a'..'z'|'A'..'Z')+ ;   // NAME is sequence of >=1 lette
+// NAME     : ('a'..'z'|'A'..'Z')+      // NAME is sequence of >=1 lette
+//          | '\'' (ESC | ~('\''|'\\'|'\n'))+ '\''  // or any quoted string
+//          ;
+// ESC      : '\\' ('\''|'\\'|'n'|'t') | '\\u' HEX{4} | '\\U' HEX{8} ;
 //
 // example strings that should be matched
+// []
 // [a,b,c]
+// ['weird name','it\'s']
 // [a,[b,c],d]
 
 type Token struct {
@@ -53,6 +63,11 @@ type Token struct {
 	Text string
 }
 
+// Kind returns the type of the token, for package parsing to match it.
+func (t Token) Kind() TokenType {
+	return t.Type
+}
+
 type TokenType int
 
 // Token types
@@ -87,23 +102,31 @@ func (t TokenType) String() string {
 // Lexer goes through the input rune by rune and produces Tokens. Lexers are
 // also called "scanners" or "tokenizers".
 type Lexer struct {
-	input string // entire input
+	input []rune // entire input
 	p     int    // current position
 	cur   rune   // current rune
+
+	unicodeNames bool // names can have any Unicode letter, see WithUnicodeNames
+	comments     bool // '#' starts a comment, see WithComments
 }
 
-func NewLexer(input string) *Lexer {
-	p := 0 // just for clarity, zero-value of int is already 0
-	if input == "" {
-		return &Lexer{input: input, p: p, cur: inputEOF}
+// NewLexer returns a lexer of input, configured by the options, see
+// options.go.
+func NewLexer(input string, opts ...LexerOption) *Lexer {
+	l := &Lexer{cur: inputEOF}
+	if input != "" {
+		l.input = []rune(input) // convert string to a rune slice so that indexing is rune-based and not byte-based
+		l.cur = l.input[l.p]
+	}
+	for _, opt := range opts {
+		opt(l)
 	}
-	cur := []rune(input)[p] // convert string to a rune slice so that indexing is rune-based and not byte-based
-	return &Lexer{input: input, p: p, cur: cur}
+	return l
 }
 
 // isLetter is a helper function, only recognizes ASCII letters
 func isLetter(r rune) bool {
-	return r >= 'a' && r < 'z' || r >= 'A' && r <= 'Z'
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
 }
 
 // marks the end of input, if input was a Reader we wouldn't need this as we
@@ -130,8 +153,16 @@ func (l *Lexer) Next() (Token, error) {
 		case '=':
 			l.consume()
 			return Token{Type: Equals, Text: "="}, nil
+		case '\'':
+			return l.quotedName()
+		case '#':
+			if l.comments {
+				l.comment()
+				continue
+			}
+			return Token{}, fmt.Errorf("invalid character: %c", l.cur)
 		default:
-			if isLetter(l.cur) {
+			if l.isNameLetter(l.cur) {
 				return l.name()
 			}
 			return Token{}, fmt.Errorf("invalid character: %c", l.cur)
@@ -144,7 +175,7 @@ func (l *Lexer) Next() (Token, error) {
 // into a token.
 func (l *Lexer) name() (Token, error) {
 	var s strings.Builder
-	for isLetter(l.cur) {
+	for l.isNameLetter(l.cur) {
 		s.WriteRune(l.cur)
 		l.consume()
 	}
@@ -152,6 +183,72 @@ func (l *Lexer) name() (Token, error) {
 	return Token{Type: Name, Text: s.String()}, nil
 }
 
+// Lexical rule NAME for quoted names, which let any string be a name: `'weird
+// name'`. The token text is the name itself, without the quotes and with the
+// escape sequences interpreted, so `'a'` and `a` are the same name.
+func (l *Lexer) quotedName() (Token, error) {
+	var s strings.Builder
+	l.consume() // opening quote
+	for l.cur != '\'' {
+		switch l.cur {
+		case inputEOF, '\n':
+			return Token{}, fmt.Errorf("unterminated quoted name: '%s", s.String())
+		case '\\':
+			r, err := l.escape()
+			if err != nil {
+				return Token{}, err
+			}
+			s.WriteRune(r)
+		default:
+			s.WriteRune(l.cur)
+			l.consume()
+		}
+	}
+	l.consume() // closing quote
+	if s.Len() == 0 {
+		return Token{}, fmt.Errorf("empty quoted name")
+	}
+	return Token{Type: Name, Text: s.String()}, nil
+}
+
+// escape interprets an escape sequence starting at the current backslash
+func (l *Lexer) escape() (rune, error) {
+	l.consume() // backslash
+	switch r := l.cur; r {
+	case '\'', '\\':
+		l.consume()
+		return r, nil
+	case 'n':
+		l.consume()
+		return '\n', nil
+	case 't':
+		l.consume()
+		return '\t', nil
+	case 'u':
+		return l.hexEscape(4)
+	case 'U':
+		return l.hexEscape(8)
+	default:
+		return 0, fmt.Errorf("invalid escape sequence in name: \\%c", r)
+	}
+}
+
+// hexEscape reads the n hexadecimal digits of a \u or \U escape, which must
+// make a valid Unicode code point.
+func (l *Lexer) hexEscape(n int) (rune, error) {
+	l.consume() // u or U
+	var s strings.Builder
+	for range n {
+		s.WriteRune(l.cur)
+		l.consume()
+	}
+	r, err := strconv.ParseUint(s.String(), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(r)) {
+		return 0, fmt.Errorf("invalid unicode escape in name: %s", s.String())
+	}
+	return rune(r), nil
+}
+
 // Consume moves the current position forward by one and saves the next current
 // rune. The check for input length wouldn't be this way if we were using a
 // Reader-based iteration, we could just check for io.EOF instead.
@@ -162,6 +259,6 @@ func (l *Lexer) consume() {
 		l.cur = inputEOF
 	} else {
 		// saves the next rune
-		l.cur = []rune(l.input)[l.p]
+		l.cur = l.input[l.p]
 	}
 }
@@ -1,4 +1,4 @@
-package main
+package llparser
 
 import (
 	"testing"
@@ -52,6 +52,21 @@ func TestLexer(t *testing.T) {
 				{Type: RBrack, Text: "]"},
 			},
 		},
+		{
+			name:  "quoted names",
+			input: `['weird name','it\'s',b,'\u00fcn\t']`,
+			want: []Token{
+				{Type: LBrack, Text: "["},
+				{Type: Name, Text: "weird name"},
+				{Type: Comma, Text: ","},
+				{Type: Name, Text: "it's"},
+				{Type: Comma, Text: ","},
+				{Type: Name, Text: "b"},
+				{Type: Comma, Text: ","},
+				{Type: Name, Text: "ün\t"},
+				{Type: RBrack, Text: "]"},
+			},
+		},
 		{
 			name:  "list within a list",
 			input: "[[a,b]]",
@@ -83,3 +98,27 @@ func TestLexer(t *testing.T) {
 		})
 	}
 }
+
+func TestLexerBadQuotedName(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{name: "unterminated", input: "['abc]"},
+		{name: "newline", input: "['a\nb']"},
+		{name: "empty", input: "['']"},
+		{name: "invalid escape", input: `['\x']`},
+		{name: "short unicode escape", input: `['\u12']`},
+		{name: "invalid code point", input: `['\UFFFFFFFF']`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer(tc.input)
+			l.Next() // [
+			if _, err := l.Next(); err == nil {
+				t.Error("want: error, got: nil")
+			}
+		})
+	}
+}
@@ -1,8 +1,9 @@
-package main
+package llparser
 
 import (
-	"errors"
 	"fmt"
+
+	"example.com/parsing"
 )
 
 // page 36, Pattern 3:
@@ -11,75 +12,107 @@ import (
 // Grammar to be parsed (ANTLR syntax):
 //
 // grammar NestedNameList;
-// list     : '[' elements ']' ;       // match bracketed list
+// list     : '[' elements? ']' ;      // match bracketed list, possibly empty
 // elements : element (',' element)* ; // match comma-separated list
-// element  : NAME | list ;            // element is name or nested list
+// element  : NAME ('=' element)?      // match name or assignment such as a=b
+//          | list                     // or a nested list
+//          ;
 // NAME     : ('a'..'z'|'A'..'Z')+ ;   // NAME is sequence of >=1 lette
 
 // We need two state variables to keep track of the parse state: an input token
-// stream and a lookahead buffer. In this case we can use a single lookahead
-// variable instead of a buffer. To report parse errors we could panic, but here
-// we'll just use a variable to track it, though this isn't the optimal solution
-// (it only reports the last error and does not stop the parser).
+// stream and a lookahead buffer, which package parsing has, holding a single
+// token here. To report parse errors we could panic, but here we'll just use a
+// variable to track it, though this isn't the optimal solution (it only
+// reports the last error and does not stop the parser).
 type LL1Parser struct {
-	input     *Lexer
-	lookahead Token
-	err       error
+	*parsing.Parser[Token, TokenType]
+	tracer
+	emitter
+	err error
+}
+
+// ParseLL1 parses the list in src with an LL(1) parser of its own, so that
+// goroutines can parse at the same time.
+func ParseLL1(src string) error {
+	return NewLL1Parser(NewLexer(src)).Parse()
 }
 
-func NewLL1Parser(l *Lexer) *LL1Parser {
-	p := &LL1Parser{input: l}
-	// initialize the parser with the first token, otherwise it'll be the
-	// zero-value for Token which is EOF
-	p.lookahead, p.err = p.input.Next()
+// NewLL1Parser returns a parser looking a single token ahead, configured by
+// the options, see options.go.
+func NewLL1Parser(l *Lexer, opts ...ParserOption) *LL1Parser {
+	c := configure(opts)
+	p := &LL1Parser{Parser: parsing.New[Token, TokenType](l, 1), tracer: tracer{w: c.trace}, emitter: emitter{h: c.h}}
+	p.Fail = p.record
 	return p
 }
 
+// Parse parses a whole list, which nothing may follow, and returns the syntax
+// error if there's one. What follows the list is only checked if it parsed,
+// the parser keeps the last error. A parser parses a single list, Parse fails
+// with parsing.ErrReused after that.
+func (p *LL1Parser) Parse() error {
+	if err := p.Begin(); err != nil {
+		return err
+	}
+	p.list()
+	if p.err == nil {
+		p.Match(EOF)
+	}
+	return p.err
+}
+
 func (p *LL1Parser) list() {
-	p.match(LBrack)
-	p.elements()
-	p.match(RBrack)
+	defer p.enter("list", p.Peek(1))()
+	p.Match(LBrack)
+	p.emitStartList()
+	// elements is optional: an empty list `[]` goes straight to the closing
+	// bracket, so we only parse elements if that's not the next token
+	if p.Peek(1).Type != RBrack {
+		p.elements()
+	}
+	p.Match(RBrack)
+	p.emitEndList()
 }
 
 func (p *LL1Parser) elements() {
+	defer p.enter("elements", p.Peek(1))()
 	p.element()
-	for p.lookahead.Type == Comma {
-		p.match(Comma)
+	for p.Peek(1).Type == Comma {
+		p.Match(Comma)
 		p.element()
 	}
 }
 
-var SyntaxError = errors.New("syntax error")
+// SyntaxError is what syntax errors wrap, the errors of Match included.
+var SyntaxError = parsing.SyntaxError
 
 func (p *LL1Parser) element() {
-	switch p.lookahead.Type {
+	defer p.enter("element", p.Peek(1))()
+	switch p.Peek(1).Type {
 	case Name:
-		p.match(Name)
+		// the assignment NAME '=' element is left-factored into the NAME
+		// alternative: both start with NAME so we couldn't choose between them
+		// with a single lookahead token. After matching NAME the next token
+		// tells us whether it was an assignment.
+		name := p.Match(Name)
+		if p.Peek(1).Type == Equals {
+			p.emitStartAssign(name.Text)
+			p.Match(Equals)
+			p.element()
+			p.emitEndAssign()
+		} else {
+			p.emitElement(name.Text)
+		}
 	case LBrack: // we've found a sublist
 		p.list()
 	default:
-		p.err = fmt.Errorf("%w: expecting name or list, found %+v", SyntaxError, p.lookahead)
-	}
-}
-
-// match checks if the current lookahead token if of the type we're looking for.
-// Goes to the next token if it is or reports an error if it isn't.
-func (p *LL1Parser) match(typ TokenType) {
-	if p.lookahead.Type == typ {
-		// go to next token
-		p.consume()
-	} else {
-		p.err = fmt.Errorf("%w: expecting %v, got %v", SyntaxError, typ, p.lookahead.Type)
+		p.record(fmt.Errorf("%w: expecting name or list, found %+v", SyntaxError, p.Peek(1)))
 	}
 }
 
-func (p *LL1Parser) consume() {
-	tok, err := p.input.Next()
-	// if at the end of token input stream, stop consuming. Cannot assign to
-	// err otherwise we overwrite the last error
-	if tok.Type == EOF {
-		return
-	}
-
-	p.lookahead, p.err = tok, err
+// record keeps err as the error of the parse. Only the last one is kept,
+// the parser goes on as if nothing happened, only without sending events.
+func (p *LL1Parser) record(err error) {
+	p.err = err
+	p.failed = true
 }
@@ -1,8 +1,12 @@
-package main
+package llparser
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
+
+	"example.com/parsing"
 )
 
 func TestParseList(t *testing.T) {
@@ -11,11 +15,21 @@ func TestParseList(t *testing.T) {
 		input string
 		err   error
 	}{
+		{name: "empty list", input: "[]", err: nil},
 		{name: "simple list", input: "[a]", err: nil},
 		{name: "long list", input: "[a,b,c,d]", err: nil},
 		{name: "list within a list", input: "[a,[b],c]", err: nil},
+		{name: "empty list within a list", input: "[a,[],c]", err: nil},
+		{name: "assignment", input: "[a=b]", err: nil},
+		{name: "list assignment", input: "[a=[b,c]]", err: nil},
+		{name: "chained assignment", input: "[a=b=c,d]", err: nil},
+		{name: "quoted names", input: "['weird name',a='b c']", err: nil},
+		{name: "incomplete assignment", input: "[a=]", err: SyntaxError},
 		{name: "incomplete list", input: "[a, ]", err: SyntaxError},
 		{name: "incomplete list", input: "[[a, ]", err: SyntaxError},
+		{name: "empty element", input: "[,]", err: SyntaxError},
+		{name: "error before more elements", input: "[a=,b]", err: SyntaxError},
+		{name: "unclosed list", input: "[a", err: SyntaxError},
 	}
 
 	for _, tc := range cases {
@@ -29,3 +43,39 @@ func TestParseList(t *testing.T) {
 		})
 	}
 }
+
+// TestParseConcurrently parses lists in many goroutines at once, for the race
+// detector to check that they share nothing: go test -race
+func TestParseConcurrently(t *testing.T) {
+	srcs := []string{"[a,[b=c],d]", "[a=]", "['x y',[[z]]]", "[a"}
+	want1, want2 := make([]string, len(srcs)), make([]string, len(srcs))
+	for i, src := range srcs {
+		want1[i], want2[i] = fmt.Sprint(ParseLL1(src)), fmt.Sprint(ParseLLk(src, 2))
+	}
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, src := range srcs {
+				if got := fmt.Sprint(ParseLL1(src)); got != want1[i] {
+					t.Errorf("goroutine %d, LL(1) %q: want: %s, got: %s", g, src, want1[i], got)
+				}
+				if got := fmt.Sprint(ParseLLk(src, 2)); got != want2[i] {
+					t.Errorf("goroutine %d, LL(2) %q: want: %s, got: %s", g, src, want2[i], got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParseOnce(t *testing.T) {
+	p := NewLL1Parser(NewLexer("[a]"))
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Parse(); !errors.Is(err, parsing.ErrReused) {
+		t.Errorf("parsing again: want: %v, got: %v", parsing.ErrReused, err)
+	}
+}
@@ -0,0 +1,227 @@
+package llparser
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"example.com/parsing"
+)
+
+// Table-Driven LL(1) Parser
+//
+// The recursive-descent parsers keep their place in the grammar on Go's call
+// stack, a call for each rule being matched, so a list nested a million deep
+// is a million calls deep. Go grows the stack of a goroutine as it needs, but
+// only up to a limit, past which the program dies: no error a caller could
+// recover from. A table-driven parser keeps its place on a stack of its own,
+// a slice on the heap, and calls nothing: it pops the symbol on top, matches
+// it if it's a token, and if it's a rule pushes what the rule expands to,
+// which a table gives for each rule and next token. The depth of a list is
+// then only bounded by the memory the stack takes, a symbol or two a level.
+//
+// A table has a single expansion for a rule and a token, so each choice is
+// made with one token, and the repetitions and options of the grammar become
+// rules of their own that expand to nothing when they're done, what the next
+// token is being in their FOLLOW set:
+//
+//	list         : '[' optElements ']' ;
+//	optElements  : elements | ;           // on ']'
+//	elements     : element moreElements ;
+//	moreElements : ',' element moreElements | ;  // on ']'
+//	element      : NAME assign | list ;
+//	assign       : '=' element | ;        // on ',' or ']'
+//
+// The grammar is the left-factored one of the LL(1) recursive-descent parser,
+// and WithTrace shows the rules of the grammar entered, not the ones made up
+// for the table, so that both parsers trace a list the same way. The events
+// of WithHandler are symbols of the expansions too, sent when they're on top
+// of the stack, after the tokens before them are matched. Unlike that parser
+// this one stops at the first syntax error.
+
+// rule is a rule of the table, one of the grammar's or a part of one.
+type rule int
+
+const (
+	noRule rule = iota // a token
+	listRule
+	optElements
+	elementsRule
+	moreElements
+	elementRule
+	assignRule
+)
+
+// ruleNames are the names of the rules of the grammar, the parts of a rule
+// have none.
+var ruleNames = map[rule]string{
+	listRule:     "list",
+	elementsRule: "elements",
+	elementRule:  "element",
+}
+
+// event is an event of the Handler, see events.go.
+type event int
+
+const (
+	noEvent event = iota
+	startList
+	endList
+	element     // of the name matched last
+	startAssign // to the name matched last
+	endAssign
+)
+
+// symbol is a symbol on the stack: a rule to expand, a token to match, an
+// event to send, or with end the end of a rule, where the trace goes back to
+// its caller.
+type symbol struct {
+	rule  rule
+	tok   TokenType
+	event event
+	end   bool
+}
+
+// ll1Table has what a rule expands to for each next token, nil for nothing
+// but events.
+// A token without an expansion is a syntax error.
+var ll1Table = map[rule]map[TokenType][]symbol{
+	listRule: {
+		LBrack: {{tok: LBrack}, {event: startList}, {rule: optElements}, {tok: RBrack}, {event: endList}},
+	},
+	optElements: {
+		Name:   {{rule: elementsRule}},
+		LBrack: {{rule: elementsRule}},
+		RBrack: nil,
+	},
+	elementsRule: {
+		Name:   {{rule: elementRule}, {rule: moreElements}},
+		LBrack: {{rule: elementRule}, {rule: moreElements}},
+	},
+	moreElements: {
+		Comma:  {{tok: Comma}, {rule: elementRule}, {rule: moreElements}},
+		RBrack: nil,
+	},
+	elementRule: {
+		Name:   {{tok: Name}, {rule: assignRule}},
+		LBrack: {{rule: listRule}},
+	},
+	assignRule: {
+		Equals: {{event: startAssign}, {tok: Equals}, {rule: elementRule}, {event: endAssign}},
+		Comma:  {{event: element}},
+		RBrack: {{event: element}},
+	},
+}
+
+// LL1TableParser parses with ll1Table, on the lookahead buffer of package
+// parsing holding a single token.
+type LL1TableParser struct {
+	*parsing.Parser[Token, TokenType]
+	tracer
+	emitter
+	stack []symbol // the symbols left to match, the next one last
+	name  string   // the name matched last, for its event
+	err   error
+}
+
+// ParseLL1Table parses the list in src with a table-driven parser of its
+// own, as ParseLL1 does.
+func ParseLL1Table(src string) error {
+	return NewLL1TableParser(NewLexer(src)).Parse()
+}
+
+// NewLL1TableParser returns a table-driven parser configured by the options,
+// see options.go. It looks a single token ahead whatever WithLookahead says.
+func NewLL1TableParser(l *Lexer, opts ...ParserOption) *LL1TableParser {
+	c := configure(opts)
+	p := &LL1TableParser{Parser: parsing.New[Token, TokenType](l, 1), tracer: tracer{w: c.trace}, emitter: emitter{h: c.h}}
+	p.Fail = p.record
+	return p
+}
+
+// Parse parses a whole list, which nothing may follow, and returns the first
+// syntax error if there's one. A parser parses a single list, Parse fails
+// with parsing.ErrReused after that.
+func (p *LL1TableParser) Parse() error {
+	if err := p.Begin(); err != nil {
+		return err
+	}
+	p.stack = append(p.stack, symbol{tok: EOF}, symbol{rule: listRule})
+	for len(p.stack) > 0 && p.err == nil {
+		top := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		switch {
+		case top.end:
+			p.depth--
+		case top.event != noEvent:
+			p.emit(top.event)
+		case top.rule == noRule:
+			if tok := p.Match(top.tok); tok.Type == Name {
+				p.name = tok.Text
+			}
+		default:
+			p.expand(top.rule)
+		}
+	}
+	return p.err
+}
+
+// expand pushes what r expands to for the next token, the first symbol on
+// top, under the end of r if it's traced.
+func (p *LL1TableParser) expand(r rule) {
+	tok := p.Peek(1)
+	if p.err != nil {
+		return // the lexer failed
+	}
+	row := ll1Table[r]
+	syms, ok := row[tok.Type]
+	if !ok {
+		p.err = fmt.Errorf("%w: expecting %s, found %+v", SyntaxError, expected(row), tok)
+		return
+	}
+	if name, ok := ruleNames[r]; ok && p.w != nil {
+		p.enter(name, tok)
+		p.stack = append(p.stack, symbol{end: true})
+	}
+	for _, s := range slices.Backward(syms) {
+		p.stack = append(p.stack, s)
+	}
+}
+
+// emit sends e to the handler.
+func (p *LL1TableParser) emit(e event) {
+	switch e {
+	case startList:
+		p.emitStartList()
+	case endList:
+		p.emitEndList()
+	case element:
+		p.emitElement(p.name)
+	case startAssign:
+		p.emitStartAssign(p.name)
+	case endAssign:
+		p.emitEndAssign()
+	}
+}
+
+// expected returns the tokens a row of the table has an expansion for, as
+// in "Comma or RBrack".
+func expected(row map[TokenType][]symbol) string {
+	var names []string
+	for _, typ := range slices.Sorted(maps.Keys(row)) {
+		names = append(names, typ.String())
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// record keeps err as the error of the parse, which stops it. Only the first
+// error is kept.
+func (p *LL1TableParser) record(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
@@ -0,0 +1,73 @@
+package llparser
+
+import (
+	"errors"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLL1Table(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "empty list", input: "[]"},
+		{name: "nested lists", input: "[a,[b,[]],c]"},
+		{name: "chained assignment", input: "[a=b=[c],d]"},
+		{name: "quoted names", input: "['weird name',a='b c']"},
+		{name: "not a list", input: "a", err: "syntax error: expecting LBrack, found {Type:Name Text:a}"},
+		{name: "incomplete assignment", input: "[a=]", err: "syntax error: expecting LBrack or Name, found {Type:RBrack Text:]}"},
+		{name: "incomplete list", input: "[a, ]", err: "syntax error: expecting LBrack or Name, found {Type:RBrack Text:]}"},
+		{name: "empty element", input: "[,]", err: "syntax error: expecting LBrack, RBrack or Name, found {Type:Comma Text:,}"},
+		{name: "missing comma", input: "[a b]", err: "syntax error: expecting RBrack, Comma or Equals, found {Type:Name Text:b}"},
+		{name: "unclosed list", input: "[a", err: "syntax error: expecting RBrack, Comma or Equals, found {Type:EOF Text:}"},
+		{name: "after the list", input: "[a]]", err: "syntax error: expecting EOF, got RBrack"},
+		{name: "lexer error", input: "[a, 'b", err: "unterminated quoted name: 'b"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ParseLL1Table(tc.input)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.err, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestParseLL1TableAsLL1 checks that the table accepts the lists the
+// recursive-descent parser accepts, and only those.
+func TestParseLL1TableAsLL1(t *testing.T) {
+	srcs := []string{
+		"[]", "[a]", "[a,b,c,d]", "[a,[b],c]", "[a,[],c]", "[a=b]", "[a=[b,c]]", "[a=b=c,d]",
+		"[a=]", "[a, ]", "[[a, ]", "[,]", "[a=,b]", "[a", "[a]b", "[a=b,]", "[[]", "[]]", "",
+	}
+	for _, src := range srcs {
+		want, got := ParseLL1(src), ParseLL1Table(src)
+		if errors.Is(got, SyntaxError) != errors.Is(want, SyntaxError) {
+			t.Errorf("%q: want: %v, got: %v", src, want, got)
+		}
+	}
+}
+
+// TestParseDeepList parses lists nested deeper than the stack of a goroutine
+// allows the recursive-descent parsers to.
+func TestParseDeepList(t *testing.T) {
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	const depth = 1_000_000
+	src := strings.Repeat("[", depth) + "a" + strings.Repeat("]", depth)
+	if err := ParseLL1Table(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := ParseLL1Table(src[:len(src)-1]); !errors.Is(err, SyntaxError) {
+		t.Errorf("unclosed: want: %v, got: %v", SyntaxError, err)
+	}
+}
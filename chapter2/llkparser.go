@@ -1,7 +1,9 @@
-package main
+package llparser
 
 import (
 	"fmt"
+
+	"example.com/parsing"
 )
 
 // page 41, Pattern 3:
@@ -10,48 +12,74 @@ import (
 // Grammar to be parsed (ANTLR syntax):
 //
 // grammar NestedNameList;
-// list     : '[' elements ']' ;       // match bracketed list
+// list     : '[' elements? ']' ;      // match bracketed list, possibly empty
 // elements : element (',' element)* ; // match comma-separated list
-// element  : NAME '=' NAME            // match assignment such as a=b
+// element  : NAME '=' element         // match assignment such as a=b or a=[b]
 //			| NAME
 //			| list
 //			;
 // NAME     : ('a'..'z'|'A'..'Z')+ ;   // NAME is sequence of >=1 letter
 
 // We need two state variables to keep track of the parse state: an input token
-// stream and a lookahead circular buffer. To report parse errors we could
-// panic, but here we'll just use a variable to track it, though this isn't the
-// optimal solution (it only reports the last error and does not stop the
-// parser).
+// stream and a lookahead buffer of k tokens, which package parsing has. To
+// report parse errors we could panic, but here we'll just use a variable to
+// track it, though this isn't the optimal solution (it only reports the last
+// error and does not stop the parser).
 type LLkParser struct {
-	input *Lexer
-	buf   []Token // circular lookahead buffer
-	k     int     // how many lookahead symbols (length of the buffer)
-	pos   int     // circular index of next token position to fill
-	err   error
+	*parsing.Parser[Token, TokenType]
+	tracer
+	emitter
+	err error
 }
 
-func NewLLkParser(l *Lexer, k int) *LLkParser {
-	buf := make([]Token, k)
-	p := &LLkParser{input: l, buf: buf, k: k}
+// ParseLLk parses the list in src with an LL(k) parser of its own, as
+// ParseLL1 does.
+func ParseLLk(src string, k int) error {
+	return NewLLkParser(NewLexer(src), WithLookahead(k)).Parse()
+}
 
-	// initialize the buffer with first k tokens
-	for range k {
-		p.consume()
-	}
+// NewLLkParser returns a parser configured by the options, see options.go,
+// looking as many tokens ahead as WithLookahead says. The grammar needs 2,
+// the default: with fewer the parser doesn't see the '=' of an assignment.
+func NewLLkParser(l *Lexer, opts ...ParserOption) *LLkParser {
+	c := configure(opts)
+	p := &LLkParser{Parser: parsing.New[Token, TokenType](l, c.k), tracer: tracer{w: c.trace}, emitter: emitter{h: c.h}}
+	p.Fail = p.record
 	return p
 }
 
+// Parse parses a whole list, which nothing may follow, and returns the syntax
+// error if there's one. What follows the list is only checked if it parsed,
+// the parser keeps the last error. A parser parses a single list, Parse fails
+// with parsing.ErrReused after that.
+func (p *LLkParser) Parse() error {
+	if err := p.Begin(); err != nil {
+		return err
+	}
+	p.list()
+	if p.err == nil {
+		p.Match(EOF)
+	}
+	return p.err
+}
+
 func (p *LLkParser) list() {
-	p.match(LBrack)
-	p.elements()
-	p.match(RBrack)
+	defer p.enter("list", p.Peek(1))()
+	p.Match(LBrack)
+	p.emitStartList()
+	// elements is optional, an empty list `[]` has nothing between brackets
+	if p.Peek(1).Type != RBrack {
+		p.elements()
+	}
+	p.Match(RBrack)
+	p.emitEndList()
 }
 
 func (p *LLkParser) elements() {
+	defer p.enter("elements", p.Peek(1))()
 	p.element()
-	for p.lookahead(1).Type == Comma {
-		p.match(Comma)
+	for p.Peek(1).Type == Comma {
+		p.Match(Comma)
 		p.element()
 	}
 }
@@ -59,49 +87,25 @@ func (p *LLkParser) elements() {
 // element needs 2 lookahead tokens to make a decision on whether it's an
 // assignment or not.
 func (p *LLkParser) element() {
-	first, second := p.lookahead(1), p.lookahead(2)
+	defer p.enter("element", p.Peek(1))()
+	first, second := p.Peek(1), p.Peek(2)
 
 	if first.Type == Name && second.Type == Equals {
-		p.match(Name)
-		p.match(Equals)
-		p.match(Name)
+		p.emitStartAssign(p.Match(Name).Text)
+		p.Match(Equals)
+		p.element()
+		p.emitEndAssign()
 	} else if first.Type == Name {
-		p.match(Name)
+		p.emitElement(p.Match(Name).Text)
 	} else if first.Type == LBrack {
 		p.list()
 	} else {
-		p.err = fmt.Errorf("%w: expecting name or list, found %+v", SyntaxError, p.lookahead(1).Type)
+		p.record(fmt.Errorf("%w: expecting name or list, found %+v", SyntaxError, p.Peek(1).Type))
 	}
 }
 
-// lookahead returns the nth next Token in the buffer. This kind of method is
-// often called `peek()`
-func (p *LLkParser) lookahead(n int) Token {
-	index := (p.pos + n - 1) % p.k
-	return p.buf[index]
-}
-
-// match checks if the current lookahead token if of the type we're looking for.
-// Goes to the next token if it is or reports an error if it isn't.
-func (p *LLkParser) match(typ TokenType) {
-	if p.lookahead(1).Type == typ {
-		// go to next token
-		p.consume()
-	} else {
-		p.err = fmt.Errorf("%w: expecting %v, got %v", SyntaxError, typ, p.lookahead(1).Type)
-	}
-}
-
-func (p *LLkParser) consume() {
-	tok, err := p.input.Next()
-
-	p.buf[p.pos] = tok
-	// add 1 until we reach k, then wraps around to 0
-	p.pos = (p.pos + 1) % p.k
-
-	// if at the end of token input stream don't assign to err otherwise we
-	// overwrite the last error
-	if tok.Type != EOF {
-		p.err = err
-	}
+// record keeps err as the error of the parse, as the LL(1) parser does.
+func (p *LLkParser) record(err error) {
+	p.err = err
+	p.failed = true
 }
@@ -1,4 +1,4 @@
-package main
+package llparser
 
 import (
 	"errors"
@@ -12,6 +12,12 @@ func TestParseAssignment(t *testing.T) {
 		err   error
 	}{
 		{name: "simple assignment", input: "[a,b=c,[d,e]]", err: nil},
+		{name: "assignment with empty list", input: "[a=b,[]]", err: nil},
+		{name: "list assignment", input: "[a=[b,c],d]", err: nil},
+		{name: "chained assignment", input: "[a=b=c]", err: nil},
+		{name: "quoted names", input: "['a b'='c d',e]", err: nil},
+		{name: "incomplete assignment", input: "[a=]", err: SyntaxError},
+		{name: "error before more elements", input: "[a=,b]", err: SyntaxError},
 	}
 
 	for _, tc := range cases {
@@ -19,7 +25,7 @@ func TestParseAssignment(t *testing.T) {
 			l := NewLexer(tc.input)
 			// change this to 1 as an exercise and see that the input is no
 			// longer parsed correctly
-			p := NewLLkParser(l, 2)
+			p := NewLLkParser(l)
 			p.list()
 			if !errors.Is(p.err, tc.err) {
 				t.Errorf("expected %v, got: %v", tc.err, p.err)
@@ -0,0 +1,114 @@
+package llparser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Options
+//
+// The lexer and the parsers take options rather than a parameter for each
+// thing that can change how they work, which the constructors apply in
+// order:
+//
+//	l := NewLexer(src, WithComments())
+//	p := NewLLkParser(l, WithLookahead(3), WithTrace(os.Stderr))
+
+// LexerOption configures a Lexer.
+type LexerOption func(*Lexer)
+
+// WithUnicodeNames makes the lexer accept any Unicode letter in names, not
+// only ASCII ones: `[año, 名前]`.
+func WithUnicodeNames() LexerOption {
+	return func(l *Lexer) { l.unicodeNames = true }
+}
+
+// WithComments makes the lexer skip comments, from '#' to the end of the
+// line.
+func WithComments() LexerOption {
+	return func(l *Lexer) { l.comments = true }
+}
+
+// ParserOption configures an LL1Parser, an LLkParser or an LL1TableParser.
+type ParserOption func(*parserConfig)
+
+type parserConfig struct {
+	k     int       // lookahead of the LL(k) parser
+	trace io.Writer // where to trace the rules, nil not to
+	h     Handler   // what to send the events of the parse to, nil not to
+}
+
+// WithLookahead makes the LL(k) parser look k tokens ahead, 2 if it's not
+// given, which is what the grammar needs. The LL(1) parser looks at a single
+// token whatever k is.
+func WithLookahead(k int) ParserOption {
+	return func(c *parserConfig) { c.k = k }
+}
+
+// WithTrace makes the parser write each rule it enters to w, one per line,
+// indented by how deep the rule is and with the token it's looking at:
+//
+//	list, looking at [
+//	  elements, looking at a
+//	    element, looking at a
+//
+// It's what ANTLR's -trace prints, to follow the parser as it goes. Errors
+// writing to w are ignored, the trace is no more than a log.
+func WithTrace(w io.Writer) ParserOption {
+	return func(c *parserConfig) { c.trace = w }
+}
+
+// WithHandler makes the parser send what it recognizes to h as it goes, see
+// events.go.
+func WithHandler(h Handler) ParserOption {
+	return func(c *parserConfig) { c.h = h }
+}
+
+// configure applies the options to the defaults.
+func configure(opts []ParserOption) parserConfig {
+	c := parserConfig{k: 2}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// isNameLetter tells whether r can be in a name.
+func (l *Lexer) isNameLetter(r rune) bool {
+	if l.unicodeNames {
+		return unicode.IsLetter(r)
+	}
+	return isLetter(r)
+}
+
+// comment skips a comment, up to the end of the line.
+func (l *Lexer) comment() {
+	for l.cur != '\n' && l.cur != inputEOF {
+		l.consume()
+	}
+}
+
+// tracer writes the rules a parser enters, for WithTrace.
+type tracer struct {
+	w     io.Writer
+	depth int
+}
+
+// enter writes rule looking at tok, and the returned function, which must be
+// called when the rule returns, goes back to the depth of the caller:
+//
+//	defer p.enter("list", p.Peek(1))()
+func (t *tracer) enter(rule string, tok Token) func() {
+	if t.w == nil {
+		return func() {}
+	}
+	text := tok.Text
+	if tok.Type == EOF {
+		text = "<EOF>"
+	}
+	fmt.Fprintf(t.w, "%s%s, looking at %s\n", strings.Repeat("  ", t.depth), rule, text)
+	t.depth++
+	return func() { t.depth-- }
+}
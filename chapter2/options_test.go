@@ -0,0 +1,110 @@
+package llparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLexerOptions(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		opts  []LexerOption
+		want  []string // token texts, the error last if there's one
+	}{
+		{
+			name:  "ASCII names",
+			input: "[año]",
+			want:  []string{"[", "a", "invalid character: ñ"},
+		},
+		{
+			name:  "unicode names",
+			input: "[año, 名前]",
+			opts:  []LexerOption{WithUnicodeNames()},
+			want:  []string{"[", "año", ",", "名前", "]", ""},
+		},
+		{
+			name:  "no comments",
+			input: "[a] # b",
+			want:  []string{"[", "a", "]", "invalid character: #"},
+		},
+		{
+			name:  "comments",
+			input: "# a list\n[a, # the first\n b]#",
+			opts:  []LexerOption{WithComments()},
+			want:  []string{"[", "a", ",", "b", "]", ""},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer(tc.input, tc.opts...)
+			var got []string
+			for {
+				tok, err := l.Next()
+				if err != nil {
+					got = append(got, err.Error())
+					break
+				}
+				got = append(got, tok.Text)
+				if tok.Type == EOF {
+					break
+				}
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestWithLookahead(t *testing.T) {
+	cases := []struct {
+		name string
+		p    interface{ Parse() error }
+		fail bool
+	}{
+		{name: "llk default", p: NewLLkParser(NewLexer("[a=b]"))},
+		{name: "llk 1", p: NewLLkParser(NewLexer("[a=b]"), WithLookahead(1)), fail: true},
+		{name: "llk 3", p: NewLLkParser(NewLexer("[a=b]"), WithLookahead(3))},
+		{name: "ll1 ignores it", p: NewLL1Parser(NewLexer("[a=b]"), WithLookahead(5))},
+	}
+
+	for _, tc := range cases {
+		err := tc.p.Parse()
+		if got := errors.Is(err, SyntaxError); got != tc.fail {
+			t.Errorf("%s: want syntax error: %v, got: %v", tc.name, tc.fail, err)
+		}
+	}
+}
+
+func TestWithTrace(t *testing.T) {
+	var ll1, llk, table strings.Builder
+	if err := NewLL1Parser(NewLexer("[a=[]]"), WithTrace(&ll1)).Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewLLkParser(NewLexer("[a=[]]"), WithTrace(&llk)).Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewLL1TableParser(NewLexer("[a=[]]"), WithTrace(&table)).Parse(); err != nil {
+		t.Fatal(err)
+	}
+	want := `list, looking at [
+  elements, looking at a
+    element, looking at a
+      element, looking at [
+        list, looking at [
+`
+	if got := ll1.String(); got != want {
+		t.Errorf("ll1: %s", cmp.Diff(got, want))
+	}
+	if got := llk.String(); got != want {
+		t.Errorf("llk: %s", cmp.Diff(got, want))
+	}
+	if got := table.String(); got != want {
+		t.Errorf("table: %s", cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,245 @@
+package backtracking
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Rewriting trees
+//
+// Passes that change the tree (desugaring, folding constants, the rewrite
+// rules of treematch.go) all need to replace or remove a node while walking
+// over it, which means knowing where the node is held: a list element, the
+// left side of an assignment, a map pair... Apply walks the tree and hands
+// out a Cursor for each node that knows exactly that, so the pass only says
+// what to do with the node. It's modeled after astutil.Apply for Go's ASTs.
+//
+// Changes are checked against the shape the parser builds: a statement has to
+// stay a list or a parallel assignment, the left side of an element
+// assignment and map keys stay names, and only elements of programs, lists
+// and maps can be deleted or have nodes inserted next to them. Anything else
+// panics, just like setting a field to the wrong type would.
+
+// ApplyFunc is called for each node with a Cursor pointing at it.
+type ApplyFunc func(c *Cursor) bool
+
+// Cursor describes a node found during Apply and lets it be changed.
+type Cursor struct {
+	node    Node
+	parent  Node
+	index   int             // index in the parent's list, -1 if not in one
+	fits    func(Node) bool // can a node take this one's place
+	replace func(Node)      // puts a node in this one's place
+	list    *listEdit       // edits to the parent's list, nil if not in one
+	deleted bool
+}
+
+// listEdit holds the operations on the list the cursor's node is in.
+type listEdit struct {
+	delete       func()
+	insertBefore func(Node)
+	insertAfter  func(Node)
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node, nil for the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Index returns the index of the current node in its parent's statements,
+// elements or pairs, or -1 if it's not in a list.
+func (c *Cursor) Index() int { return c.index }
+
+// CanReplace tells whether n can take the current node's place.
+func (c *Cursor) CanReplace(n Node) bool {
+	return n != nil && c.fits(n)
+}
+
+// Replace puts n in place of the current node. The children of n aren't
+// visited, Apply already went past them or will visit the ones of the old
+// node.
+func (c *Cursor) Replace(n Node) {
+	if c.deleted {
+		panic("replace: node was deleted")
+	}
+	if !c.CanReplace(n) {
+		panic(fmt.Sprintf("replace: %s can't take the place of %s", describe(n), describe(c.node)))
+	}
+	c.replace(n)
+	c.node = n
+}
+
+// Delete removes the current node from its parent's list. Its children
+// aren't visited.
+func (c *Cursor) Delete() {
+	if c.list == nil {
+		panic(fmt.Sprintf("delete: %s isn't in a list", describe(c.node)))
+	}
+	if c.deleted {
+		panic("delete: node was already deleted")
+	}
+	c.list.delete()
+	c.deleted = true
+}
+
+// InsertBefore inserts n before the current node in its parent's list. It
+// isn't visited by Apply.
+func (c *Cursor) InsertBefore(n Node) {
+	c.checkInsert(n)
+	c.list.insertBefore(n)
+	c.index++
+}
+
+// InsertAfter inserts n after the current node in its parent's list. It
+// isn't visited by Apply.
+func (c *Cursor) InsertAfter(n Node) {
+	c.checkInsert(n)
+	c.list.insertAfter(n)
+}
+
+func (c *Cursor) checkInsert(n Node) {
+	if c.list == nil {
+		panic(fmt.Sprintf("insert: %s isn't in a list", describe(c.node)))
+	}
+	if !c.CanReplace(n) {
+		panic(fmt.Sprintf("insert: %s can't go next to %s", describe(n), describe(c.node)))
+	}
+}
+
+func describe(n Node) string {
+	if n == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%T %s", n, n)
+}
+
+// abort is panicked by Apply when post returns false
+type abort struct{}
+
+// Apply walks the tree rooted at root calling pre before the children of a
+// node are visited and post after, either can be nil. If pre returns false the
+// children and post are skipped for that node, if post returns false the walk
+// stops. The tree is changed in place, Apply returns the root which is only a
+// new node if the root itself was replaced.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(abort); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	result = root
+	a := &application{pre: pre, post: post}
+	a.apply(&Cursor{
+		node:    root,
+		index:   -1,
+		fits:    func(Node) bool { return true },
+		replace: func(n Node) { result = n },
+	})
+	return result
+}
+
+type application struct {
+	pre, post ApplyFunc
+}
+
+func (a *application) apply(c *Cursor) {
+	if a.pre != nil && !a.pre(c) {
+		return
+	}
+	if c.deleted {
+		return
+	}
+	a.applyChildren(c.node)
+	if a.post != nil && !a.post(c) {
+		panic(abort{})
+	}
+}
+
+func (a *application) applyChildren(n Node) {
+	switch n := n.(type) {
+	case *ProgramNode:
+		applyList(a, n, &n.Stats, isStat)
+	case *ListNode:
+		applyList(a, n, &n.Elements, isElement)
+	case *AssignNode:
+		// the sides of a parallel assignment are lists, the left side of an
+		// element assignment is a name
+		leftFits, rightFits := isName, isElement
+		if _, parallel := n.Left.(*ListNode); parallel {
+			leftFits, rightFits = isList, isList
+		}
+		a.applyField(n, n.Left, leftFits, func(c Node) { n.Left = c })
+		a.applyField(n, n.Right, rightFits, func(c Node) { n.Right = c })
+	case *MapNode:
+		applyList(a, n, &n.Pairs, isPair)
+	case *PairNode:
+		a.applyField(n, n.Key, isName, func(c Node) { n.Key = c.(*NameNode) })
+		a.applyField(n, n.Value, isElement, func(c Node) { n.Value = c })
+	}
+}
+
+func (a *application) applyField(parent, n Node, fits func(Node) bool, set func(Node)) {
+	a.apply(&Cursor{node: n, parent: parent, index: -1, fits: fits, replace: set})
+}
+
+// applyList visits the nodes of a list that can change while it's visited.
+// It's a function because Go methods can't have type parameters, and the
+// pairs of a map are a []*PairNode rather than a []Node.
+func applyList[T Node](a *application, parent Node, list *[]T, fits func(Node) bool) {
+	for i := 0; i < len(*list); {
+		next := i + 1
+		c := &Cursor{node: (*list)[i], parent: parent, index: i, fits: fits}
+		c.replace = func(n Node) { (*list)[c.index] = n.(T) }
+		c.list = &listEdit{
+			delete: func() {
+				*list = slices.Delete(*list, c.index, c.index+1)
+				next--
+			},
+			insertBefore: func(n Node) {
+				*list = slices.Insert(*list, c.index, n.(T))
+				next++
+			},
+			insertAfter: func(n Node) {
+				*list = slices.Insert(*list, c.index+1, n.(T))
+				next++
+			},
+		}
+		a.apply(c)
+		i = next
+	}
+}
+
+// what can go in each place of the tree
+
+func isStat(n Node) bool {
+	if a, ok := n.(*AssignNode); ok {
+		return isList(a.Left) && isList(a.Right)
+	}
+	return isList(n)
+}
+
+func isElement(n Node) bool {
+	if a, ok := n.(*AssignNode); ok {
+		return isName(a.Left) && isElement(a.Right)
+	}
+	return n != nil && head(n) != "program" && head(n) != "pair"
+}
+
+func isList(n Node) bool {
+	_, ok := n.(*ListNode)
+	return ok
+}
+
+func isName(n Node) bool {
+	_, ok := n.(*NameNode)
+	return ok
+}
+
+func isPair(n Node) bool {
+	_, ok := n.(*PairNode)
+	return ok
+}
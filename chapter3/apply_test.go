@@ -0,0 +1,169 @@
+package backtracking
+
+import (
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		pre   ApplyFunc
+		post  ApplyFunc
+		want  string
+	}{
+		{
+			name:  "replace names",
+			input: "[a,b=c]=[{a: a}]",
+			pre: func(c *Cursor) bool {
+				if n, ok := c.Node().(*NameNode); ok && n.Token.Text == "a" {
+					c.Replace(name("z"))
+				}
+				return true
+			},
+			want: "[z,b=c]=[{z: z}]",
+		},
+		{
+			name:  "delete elements",
+			input: "[a,1,b,2,3];[4]",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Node().(*IntNode); ok {
+					c.Delete()
+				}
+				return true
+			},
+			want: "[a,b]\n[]",
+		},
+		{
+			name:  "insert around elements",
+			input: "[a,b]",
+			pre: func(c *Cursor) bool {
+				if n, ok := c.Node().(*NameNode); ok {
+					c.InsertBefore(name("before" + n.Token.Text))
+					c.InsertAfter(name("after" + n.Token.Text))
+				}
+				return true
+			},
+			want: "[beforea,a,aftera,beforeb,b,afterb]",
+		},
+		{
+			name:  "delete statements",
+			input: "[a];[b]=[c];[d]",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Node().(*AssignNode); ok && c.Index() >= 0 {
+					c.Delete()
+				}
+				return true
+			},
+			want: "[a]\n[d]",
+		},
+		{
+			name:  "desugar element assignments in post",
+			input: "[a=[b=c]]",
+			post: func(c *Cursor) bool {
+				if n, ok := c.Node().(*AssignNode); ok && c.CanReplace(&ListNode{}) {
+					c.Replace(&ListNode{Elements: []Node{n.Left, n.Right}})
+				}
+				return true
+			},
+			want: "[[a,[[b,c]]]]",
+		},
+		{
+			name:  "skip children",
+			input: "[a,[a],{a: a}]",
+			pre: func(c *Cursor) bool {
+				if n, ok := c.Node().(*NameNode); ok {
+					c.Replace(name(n.Token.Text + "x"))
+				}
+				_, isMap := c.Node().(*MapNode)
+				return !isMap
+			},
+			want: "[ax,[ax],{a: a}]",
+		},
+		{
+			name:  "stop",
+			input: "[a,b,c]",
+			post: func(c *Cursor) bool {
+				c.Replace(name("x"))
+				return c.Node().String() != "x" || c.Index() != 1
+			},
+			want: "[x,x,c]",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			got := Apply(parser.program(), tc.pre, tc.post).String()
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	tree := Apply(name("a"), func(c *Cursor) bool {
+		c.Replace(name("b"))
+		return true
+	}, nil)
+	if tree.String() != "b" {
+		t.Errorf("want: %q, got: %q", "b", tree)
+	}
+}
+
+func TestApplyInvalidChanges(t *testing.T) {
+	cases := []struct {
+		name string
+		pre  ApplyFunc
+	}{
+		{
+			name: "statement replaced by name",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Parent().(*ProgramNode); ok {
+					c.Replace(name("a"))
+				}
+				return true
+			},
+		},
+		{
+			name: "map key replaced by list",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Parent().(*PairNode); ok && c.Node().String() == "k" {
+					c.Replace(&ListNode{})
+				}
+				return true
+			},
+		},
+		{
+			name: "assignment side deleted",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Parent().(*AssignNode); ok {
+					c.Delete()
+				}
+				return true
+			},
+		},
+		{
+			name: "name inserted among pairs",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Node().(*PairNode); ok {
+					c.InsertAfter(name("a"))
+				}
+				return true
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("want: panic, got: nil")
+				}
+			}()
+			parser := NewBacktrackingParser(NewLexer("[a=b,{k: v}]"))
+			Apply(parser.program(), tc.pre, nil)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package backtracking
+
+import "slices"
+
+// Arena allocation
+//
+// The parser allocates one small object per node, so a large input turns
+// into millions of objects for the garbage collector to track. An Arena
+// allocates nodes in big blocks instead, one block holding thousands of nodes
+// of the same type, and the lists of children are copied into big blocks of
+// []Node too. The GC then sees a few large objects, and the tree is laid out
+// in memory in about the order it's walked.
+//
+// The price is that memory is only given back when nothing points into a
+// block anymore: keeping a single node of a tree alive keeps its whole block.
+// Nodes built while speculating are never used but take up room in the arena
+// just the same. An arena is for parsing big inputs whose trees are used as a
+// whole and then dropped.
+//
+// Set the parser's Arena field to use one, the trees built are the same. Any
+// number of parsers can share an arena, one after the other: it isn't safe
+// for goroutines parsing at the same time, each of which needs its own.
+
+// arenaBlock is how many nodes or children are allocated at once
+const arenaBlock = 1024
+
+// Arena hands out nodes from blocks. The zero value is ready to use, a nil
+// *Arena allocates every node on its own.
+type Arena struct {
+	lists   []ListNode
+	names   []NameNode
+	assigns []AssignNode
+	maps    []MapNode
+	pairs   []PairNode
+	ints    []IntNode
+	floats  []FloatNode
+	strings []StringNode
+	bools   []BoolNode
+
+	nodes     []Node      // children of lists
+	pairLists []*PairNode // pairs of maps
+}
+
+// alloc returns the next free element of the block in s, starting a new block
+// when it's full.
+func alloc[T any](s *[]T) *T {
+	if len(*s) == cap(*s) {
+		*s = make([]T, 0, arenaBlock)
+	}
+	*s = (*s)[:len(*s)+1]
+	return &(*s)[len(*s)-1]
+}
+
+// store copies elems into a block, the full slice expression keeps appends to
+// the result from overwriting the next list in the block.
+func store[T any](s *[]T, elems []T) []T {
+	if len(elems) == 0 {
+		return nil
+	}
+	if len(elems) > arenaBlock/4 {
+		// too big to share a block
+		return clone(elems)
+	}
+	if cap(*s)-len(*s) < len(elems) {
+		*s = make([]T, 0, arenaBlock)
+	}
+	start := len(*s)
+	*s = append(*s, elems...)
+	return (*s)[start:len(*s):len(*s)]
+}
+
+// clone copies elems into a slice of their own, nil if there are none
+func clone[T any](elems []T) []T {
+	if len(elems) == 0 {
+		return nil
+	}
+	return slices.Clone(elems)
+}
+
+func (a *Arena) newList(lbrack Pos, elems []Node) *ListNode {
+	if a == nil {
+		return &ListNode{Lbrack: lbrack, Elements: clone(elems)}
+	}
+	n := alloc(&a.lists)
+	*n = ListNode{Lbrack: lbrack, Elements: store(&a.nodes, elems)}
+	return n
+}
+
+func (a *Arena) newName(tok Token) *NameNode {
+	if a == nil {
+		return &NameNode{Token: tok}
+	}
+	n := alloc(&a.names)
+	*n = NameNode{Token: tok}
+	return n
+}
+
+func (a *Arena) newAssign(left, right Node) *AssignNode {
+	if a == nil {
+		return &AssignNode{Left: left, Right: right}
+	}
+	n := alloc(&a.assigns)
+	*n = AssignNode{Left: left, Right: right}
+	return n
+}
+
+func (a *Arena) newMap(lbrace Pos, pairs []*PairNode) *MapNode {
+	if a == nil {
+		return &MapNode{Lbrace: lbrace, Pairs: clone(pairs)}
+	}
+	n := alloc(&a.maps)
+	*n = MapNode{Lbrace: lbrace, Pairs: store(&a.pairLists, pairs)}
+	return n
+}
+
+func (a *Arena) newPair(key *NameNode, value Node) *PairNode {
+	if a == nil {
+		return &PairNode{Key: key, Value: value}
+	}
+	n := alloc(&a.pairs)
+	*n = PairNode{Key: key, Value: value}
+	return n
+}
+
+func (a *Arena) newInt(tok Token, v int64) *IntNode {
+	if a == nil {
+		return &IntNode{Token: tok, Value: v}
+	}
+	n := alloc(&a.ints)
+	*n = IntNode{Token: tok, Value: v}
+	return n
+}
+
+func (a *Arena) newFloat(tok Token, v float64) *FloatNode {
+	if a == nil {
+		return &FloatNode{Token: tok, Value: v}
+	}
+	n := alloc(&a.floats)
+	*n = FloatNode{Token: tok, Value: v}
+	return n
+}
+
+func (a *Arena) newString(tok Token, v string) *StringNode {
+	if a == nil {
+		return &StringNode{Token: tok, Value: v}
+	}
+	n := alloc(&a.strings)
+	*n = StringNode{Token: tok, Value: v}
+	return n
+}
+
+func (a *Arena) newBool(tok Token, v bool) *BoolNode {
+	if a == nil {
+		return &BoolNode{Token: tok, Value: v}
+	}
+	n := alloc(&a.bools)
+	*n = BoolNode{Token: tok, Value: v}
+	return n
+}
@@ -0,0 +1,94 @@
+package backtracking
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+func TestArenaBuildsSameTree(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/good.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs []string
+	for _, file := range ar.Files {
+		for _, line := range bytes.Split(file.Data, []byte("\n")) {
+			inputs = append(inputs, string(line))
+		}
+	}
+	// a list too long to share a block
+	inputs = append(inputs, "["+strings.Repeat("a,", arenaBlock)+"b]")
+
+	arena := &Arena{}
+	for _, input := range inputs {
+		want, err := NewBacktrackingParser(NewLexer(input)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewBacktrackingParser(NewLexer(input))
+		p.Arena = arena
+		got, err := p.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(got, want) {
+			t.Errorf("%q: %s", input, cmp.Diff(got, want))
+		}
+	}
+}
+
+func TestArenaListsDontOverlap(t *testing.T) {
+	p := NewBacktrackingParser(NewLexer("[[a],[b]]"))
+	p.Arena = &Arena{}
+	prog, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer := prog.Stats[0].(*ListNode)
+	first := outer.Elements[0].(*ListNode)
+	first.Elements = append(first.Elements, name("x"))
+	if got := outer.String(); got != "[[a,x],[b]]" {
+		t.Errorf("want: %q, got: %q", "[[a,x],[b]]", got)
+	}
+}
+
+// bigInput is a program of n statements such as
+// `[a,b=[0,"s"],{k: [true, 1.5]}]=[[c],d]`
+func bigInput(n int) string {
+	var s strings.Builder
+	for i := range n {
+		fmt.Fprintf(&s, "[a,b=[%d,\"s\"],{k: [true, 1.5]}]=[[c],d]\n", i)
+	}
+	return s.String()
+}
+
+// The arena makes a lot fewer allocations, and so fewer GC cycles:
+//
+//	go test -bench Parse -run XXX
+func BenchmarkParse(b *testing.B) {
+	input := bigInput(10000)
+	for _, arena := range []bool{false, true} {
+		b.Run(fmt.Sprintf("arena=%v", arena), func(b *testing.B) {
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			for range b.N {
+				p := NewBacktrackingParser(NewLexer(input))
+				if arena {
+					p.Arena = &Arena{}
+				}
+				if _, err := p.Parse(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gcs/op")
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package backtracking
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Assignment graph
+//
+// Used as a configuration language, a program defines names: `a=b` says a is
+// whatever b is, `[a,b]=[c,[d]]` says a is c and b is [d]. Taken together
+// the assignments of all the statements make a graph with an edge from each
+// name to every name in its value, and a few things can be wrong with it that
+// no single statement shows:
+//
+//	[a,b]=[c]            the sides don't have as many elements
+//	[1]=[a]              only names can be assigned to
+//	a=b; a=c             a is assigned two different values, a conflict
+//	a=[b]; b=c; c=a      a depends on itself through b and c, a cycle
+//
+// Assigning the same value twice is not a conflict. Parallel assignments pair
+// up nested lists too, so `[[a,b]]=[[c,d]]` assigns c to a and d to b.
+
+// Assignment is a name given a value by the program.
+type Assignment struct {
+	Target *NameNode
+	Value  Node
+}
+
+func (a Assignment) String() string {
+	return a.Target.String() + "=" + a.Value.String()
+}
+
+// Conflict is a name assigned a value different from the one it had.
+type Conflict struct {
+	First, Second Assignment
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%v: %s conflicts with %s at %v", c.Second.Target.Pos(), c.Second, c.First, c.First.Target.Pos())
+}
+
+// AssignGraph has the assignments of a program and the names each name
+// depends on.
+type AssignGraph struct {
+	Assignments []Assignment // in the order they're found
+	Errors      []error      // assignments that can't be paired up
+
+	deps  map[string][]*NameNode // names in the values assigned to a name
+	names []string               // names assigned, in the order first seen
+}
+
+// NewAssignGraph builds the graph for the assignments in the tree rooted at
+// n, usually a program. Error nodes are skipped.
+func NewAssignGraph(n Node) *AssignGraph {
+	g := &AssignGraph{deps: map[string][]*NameNode{}}
+	g.walk(n)
+	return g
+}
+
+func (g *AssignGraph) walk(n Node) {
+	if assign, ok := n.(*AssignNode); ok {
+		g.pair(assign.Left, assign.Right)
+	}
+	for _, c := range children(n) {
+		g.walk(c)
+	}
+}
+
+// pair assigns value to target, element by element if both are lists.
+func (g *AssignGraph) pair(target, value Node) {
+	switch t := target.(type) {
+	case *NameNode:
+		g.add(Assignment{Target: t, Value: value})
+	case *ListNode:
+		v, ok := value.(*ListNode)
+		switch {
+		case isError(value):
+		case !ok:
+			g.errorf(value.Pos(), "can't assign %s to the %d targets of %s", value, len(t.Elements), t)
+		case len(v.Elements) != len(t.Elements):
+			g.errorf(t.Pos(), "%d targets, %d values in %s=%s", len(t.Elements), len(v.Elements), t, v)
+		default:
+			for i, el := range t.Elements {
+				g.pair(el, v.Elements[i])
+			}
+		}
+	case *ErrorNode:
+	default:
+		g.errorf(target.Pos(), "can't assign to %s", target)
+	}
+}
+
+func isError(n Node) bool {
+	_, ok := n.(*ErrorNode)
+	return ok
+}
+
+func (g *AssignGraph) add(a Assignment) {
+	name := a.Target.Token.Text
+	if _, ok := g.deps[name]; !ok {
+		g.names = append(g.names, name)
+		g.deps[name] = nil
+	}
+	g.Assignments = append(g.Assignments, a)
+	g.deps[name] = append(g.deps[name], namesIn(a.Value)...)
+}
+
+// namesIn returns the names in the tree rooted at n, leaving out map keys
+// which aren't references to names.
+func namesIn(n Node) []*NameNode {
+	switch n := n.(type) {
+	case *NameNode:
+		return []*NameNode{n}
+	case *PairNode:
+		return namesIn(n.Value)
+	}
+	var names []*NameNode
+	for _, c := range children(n) {
+		names = append(names, namesIn(c)...)
+	}
+	return names
+}
+
+func (g *AssignGraph) errorf(pos Pos, format string, args ...any) {
+	g.Errors = append(g.Errors, fmt.Errorf("%v: %s", pos, fmt.Sprintf(format, args...)))
+}
+
+// Conflicts returns the assignments of a different value to a name assigned
+// before, in the order they're found.
+func (g *AssignGraph) Conflicts() []Conflict {
+	var conflicts []Conflict
+	first := map[string]Assignment{}
+	for _, a := range g.Assignments {
+		name := a.Target.Token.Text
+		prev, ok := first[name]
+		if !ok {
+			first[name] = a
+			continue
+		}
+		if !Equal(prev.Value, a.Value) {
+			conflicts = append(conflicts, Conflict{First: prev, Second: a})
+		}
+	}
+	return conflicts
+}
+
+// Cycles returns the cycles in the graph, each one as the names on it in the
+// order they depend on each other, starting and ending with the same name.
+// They're found with a depth-first search, one for each edge that closes a
+// cycle, so cycles sharing names may not all be reported.
+func (g *AssignGraph) Cycles() [][]string {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := map[string]int{}
+	var path []string
+	var cycles [][]string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = onPath
+		path = append(path, name)
+		seen := map[string]bool{}
+		for _, dep := range g.deps[name] {
+			d := dep.Token.Text
+			if seen[d] {
+				continue
+			}
+			seen[d] = true
+			switch state[d] {
+			case onPath:
+				// back edge, the cycle is the path from d on
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == d {
+						cycles = append(cycles, append(append([]string(nil), path[i:]...), d))
+						break
+					}
+				}
+			case unvisited:
+				if _, assigned := g.deps[d]; assigned {
+					visit(d)
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+	}
+	for _, name := range g.names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return cycles
+}
+
+// Check returns all the problems found in the graph: the assignments that
+// can't be paired up, the conflicts and the cycles.
+func (g *AssignGraph) Check() []error {
+	errs := append([]error(nil), g.Errors...)
+	for _, c := range g.Conflicts() {
+		errs = append(errs, errors.New(c.String()))
+	}
+	for _, cycle := range g.Cycles() {
+		errs = append(errs, fmt.Errorf("%v: cycle: %s", g.firstAssigned(cycle[0]), strings.Join(cycle, " -> ")))
+	}
+	return errs
+}
+
+// firstAssigned returns the position of the first assignment to name.
+func (g *AssignGraph) firstAssigned(name string) Pos {
+	for _, a := range g.Assignments {
+		if a.Target.Token.Text == name {
+			return a.Target.Pos()
+		}
+	}
+	return Pos{}
+}
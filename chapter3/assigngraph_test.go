@@ -0,0 +1,78 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAssignGraph(t *testing.T) {
+	cases := []struct {
+		name     string
+		src      string
+		assigned []string
+		problems []string
+	}{
+		{
+			name:     "parallel",
+			src:      "[a,[b,c]]=[d,[e,[f]]]",
+			assigned: []string{"a=d", "b=e", "c=[f]"},
+		},
+		{
+			name:     "elements",
+			src:      "[a=b, {k: c=d}]",
+			assigned: []string{"a=b", "c=d"},
+		},
+		{
+			name:     "shapes",
+			src:      "[a,b]=[c]; [1]=[a]; [[e,f]]=[g]",
+			problems: []string{"1:1: 2 targets, 1 values in [a,b]=[c]", "1:13: can't assign to 1", "1:30: can't assign g to the 2 targets of [e,f]"},
+		},
+		{
+			name:     "conflicts",
+			src:      "[a]=[b]; [a=b, a=[c], a=[c]]",
+			assigned: []string{"a=b", "a=b", "a=[c]", "a=[c]"},
+			problems: []string{"1:16: a=[c] conflicts with a=b at 1:2", "1:23: a=[c] conflicts with a=b at 1:2"},
+		},
+		{
+			name:     "cycles",
+			src:      "[x=[a], a={k: b}, b=c, c=a, d=d, e=x]",
+			assigned: []string{"x=[a]", "a={k: b}", "b=c", "c=a", "d=d", "e=x"},
+			problems: []string{"1:9: cycle: a -> b -> c -> a", "1:29: cycle: d -> d"},
+		},
+		{
+			name:     "swap",
+			src:      "[a,b]=[b,a]",
+			assigned: []string{"a=b", "b=a"},
+			problems: []string{"1:2: cycle: a -> b -> a"},
+		},
+		{
+			name:     "map keys aren't names",
+			src:      "[a={a: b}]",
+			assigned: []string{"a={a: b}"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := NewBacktrackingParser(NewLexer(tc.src)).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := NewAssignGraph(prog)
+			var assigned, problems []string
+			for _, a := range g.Assignments {
+				assigned = append(assigned, a.String())
+			}
+			for _, err := range g.Check() {
+				problems = append(problems, err.Error())
+			}
+			if !cmp.Equal(assigned, tc.assigned) {
+				t.Errorf("assignments: %s", cmp.Diff(assigned, tc.assigned))
+			}
+			if !cmp.Equal(problems, tc.problems) {
+				t.Errorf("problems: %s", cmp.Diff(problems, tc.problems))
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package backtracking
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Abstract Syntax Tree (AST)
+//
+// The parser records what it recognized as a tree of nodes. Each kind of node
+// has its own type (the tree is heterogeneous) holding only what matters about
+// the input: the brackets, commas and colons are gone since the shape of the
+// tree already tells us where lists and pairs begin and end.
+//
+// For the statement `[a,b=[c]]=[{d: e}]` the tree is:
+//
+//	AssignNode
+//	├── ListNode
+//	│   ├── NameNode a
+//	│   └── AssignNode
+//	│       ├── NameNode b
+//	│       └── ListNode
+//	│           └── NameNode c
+//	└── ListNode
+//	    └── MapNode
+//	        └── PairNode
+//	            ├── NameNode d
+//	            └── NameNode e
+
+// Node is implemented by every node in the tree. String renders the node back
+// to source text and Pos is the position of the node's first token.
+type Node interface {
+	Pos() Pos
+	String() string
+}
+
+// ProgramNode is the root of the tree, holding every statement in the input
+// in order. Each statement is either a ListNode or a parallel AssignNode.
+type ProgramNode struct {
+	Stats []Node
+}
+
+// ListNode is a bracketed list such as `[a,b]`.
+type ListNode struct {
+	Lbrack   Pos // position of '['
+	Elements []Node
+}
+
+// NameNode is a NAME such as `a`.
+type NameNode struct {
+	Token Token
+}
+
+// IntNode is an integer literal such as `42`.
+type IntNode struct {
+	Token Token
+	Value int64
+}
+
+// FloatNode is a floating point literal such as `4.2`.
+type FloatNode struct {
+	Token Token
+	Value float64
+}
+
+// StringNode is a string literal such as `"a b"`. Value has the escape
+// sequences already interpreted.
+type StringNode struct {
+	Token Token
+	Value string
+}
+
+// BoolNode is one of the keywords `true` or `false`.
+type BoolNode struct {
+	Token Token
+	Value bool
+}
+
+// AssignNode is either an element assignment such as `a=b` or a parallel
+// assignment of lists such as `[a,b]=[c,d]`.
+type AssignNode struct {
+	Left  Node
+	Right Node
+}
+
+// MapNode is a record such as `{a: b, c: [d]}`.
+type MapNode struct {
+	Lbrace Pos // position of '{'
+	Pairs  []*PairNode
+}
+
+// PairNode is a single `key: value` entry of a MapNode.
+type PairNode struct {
+	Key   *NameNode
+	Value Node
+}
+
+// ErrorNode stands for input the parser couldn't make sense of, it's only in
+// the tree when the parser recovers from errors. Tokens are the bad input,
+// from the start of the element or statement with the error up to where the
+// parser could go on.
+type ErrorNode struct {
+	From   Pos // where the bad input starts
+	Tokens []Token
+	Err    error
+}
+
+// Pos of an empty program is the start of the input
+func (n *ProgramNode) Pos() Pos {
+	if len(n.Stats) == 0 {
+		return Pos{Line: 1, Col: 1}
+	}
+	return n.Stats[0].Pos()
+}
+
+func (n *ListNode) Pos() Pos   { return n.Lbrack }
+func (n *NameNode) Pos() Pos   { return n.Token.Pos }
+func (n *IntNode) Pos() Pos    { return n.Token.Pos }
+func (n *FloatNode) Pos() Pos  { return n.Token.Pos }
+func (n *StringNode) Pos() Pos { return n.Token.Pos }
+func (n *BoolNode) Pos() Pos   { return n.Token.Pos }
+func (n *AssignNode) Pos() Pos { return n.Left.Pos() }
+func (n *MapNode) Pos() Pos    { return n.Lbrace }
+func (n *PairNode) Pos() Pos   { return n.Key.Pos() }
+func (n *ErrorNode) Pos() Pos  { return n.From }
+
+func (n *ProgramNode) String() string {
+	return join(n.Stats, "\n")
+}
+
+func (n *ListNode) String() string {
+	return "[" + join(n.Elements, ",") + "]"
+}
+
+// String renders the name bare when it can be lexed back as a NAME, otherwise
+// it quotes it: `weird name` is rendered as `'weird name'` and the name true,
+// which would be read as a keyword, as `'true'`.
+func (n *NameNode) String() string {
+	if isBareName(n.Token.Text) {
+		return n.Token.Text
+	}
+	return quoteName(n.Token.Text)
+}
+
+func isBareName(text string) bool {
+	if text == "" {
+		return false
+	}
+	if _, ok := keywords[text]; ok {
+		return false
+	}
+	for _, r := range text {
+		if !isLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteName quotes a name using the escape sequences the lexer accepts.
+// Printable characters are kept as they are, so `'ünï'` stays readable.
+func quoteName(text string) string {
+	var s strings.Builder
+	s.WriteRune('\'')
+	for _, r := range text {
+		switch {
+		case r == '\'' || r == '\\':
+			s.WriteRune('\\')
+			s.WriteRune(r)
+		case r == '\n':
+			s.WriteString(`\n`)
+		case r == '\t':
+			s.WriteString(`\t`)
+		case unicode.IsPrint(r):
+			s.WriteRune(r)
+		case r <= 0xFFFF:
+			fmt.Fprintf(&s, `\u%04x`, r)
+		default:
+			fmt.Fprintf(&s, `\U%08x`, r)
+		}
+	}
+	s.WriteRune('\'')
+	return s.String()
+}
+
+func (n *IntNode) String() string {
+	return n.Token.Text
+}
+
+func (n *FloatNode) String() string {
+	return n.Token.Text
+}
+
+func (n *StringNode) String() string {
+	return n.Token.Text
+}
+
+func (n *BoolNode) String() string {
+	return n.Token.Text
+}
+
+func (n *AssignNode) String() string {
+	return n.Left.String() + "=" + n.Right.String()
+}
+
+func (n *MapNode) String() string {
+	var s strings.Builder
+	s.WriteString("{")
+	for i, pair := range n.Pairs {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(pair.String())
+	}
+	s.WriteString("}")
+	return s.String()
+}
+
+func (n *PairNode) String() string {
+	return n.Key.String() + ": " + n.Value.String()
+}
+
+// String renders the bad tokens separated by spaces, the original spacing is
+// lost.
+func (n *ErrorNode) String() string {
+	text := make([]string, len(n.Tokens))
+	for i, tok := range n.Tokens {
+		text[i] = tok.Text
+		if tok.Type == Name {
+			text[i] = (&NameNode{Token: tok}).String()
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+// join renders each node and joins them with sep in between
+func join(nodes []Node, sep string) string {
+	var s strings.Builder
+	for i, n := range nodes {
+		if i > 0 {
+			s.WriteString(sep)
+		}
+		s.WriteString(n.String())
+	}
+	return s.String()
+}
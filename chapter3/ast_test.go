@@ -0,0 +1,112 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// ignorePos makes cmp compare tokens and nodes regardless of where they are
+// in the input
+var ignorePos = cmpopts.IgnoreTypes(Pos{})
+
+func name(text string) *NameNode {
+	return &NameNode{Token: Token{Type: Name, Text: text}}
+}
+
+func TestParserAST(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  Node
+	}{
+		{
+			name:  "empty list",
+			input: "[]",
+			want:  &ListNode{},
+		},
+		{
+			name:  "simple list",
+			input: "[a,b]",
+			want:  &ListNode{Elements: []Node{name("a"), name("b")}},
+		},
+		{
+			name:  "embedded assignment",
+			input: "[a=[b],c]",
+			want: &ListNode{Elements: []Node{
+				&AssignNode{Left: name("a"), Right: &ListNode{Elements: []Node{name("b")}}},
+				name("c"),
+			}},
+		},
+		{
+			name:  "parallel assignment",
+			input: "[a]=[b]",
+			want: &AssignNode{
+				Left:  &ListNode{Elements: []Node{name("a")}},
+				Right: &ListNode{Elements: []Node{name("b")}},
+			},
+		},
+		{
+			name:  "map",
+			input: "[{a: b, c: {}}]",
+			want: &ListNode{Elements: []Node{
+				&MapNode{Pairs: []*PairNode{
+					{Key: name("a"), Value: name("b")},
+					{Key: name("c"), Value: &MapNode{}},
+				}},
+			}},
+		},
+		{
+			name:  "literals",
+			input: `[1,2.5,"a\tb",true,false]`,
+			want: &ListNode{Elements: []Node{
+				&IntNode{Token: Token{Type: Int, Text: "1"}, Value: 1},
+				&FloatNode{Token: Token{Type: Float, Text: "2.5"}, Value: 2.5},
+				&StringNode{Token: Token{Type: String, Text: `"a\tb"`}, Value: "a\tb"},
+				&BoolNode{Token: Token{Type: True, Text: "true"}, Value: true},
+				&BoolNode{Token: Token{Type: False, Text: "false"}, Value: false},
+			}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			got := parser.program()
+			want := &ProgramNode{Stats: []Node{tc.want}}
+			if !cmp.Equal(got, want, ignorePos) {
+				t.Error(cmp.Diff(got, want, ignorePos))
+			}
+		})
+	}
+}
+
+func TestNodeString(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: "[]", want: "[]"},
+		{input: "[ a , b ]", want: "[a,b]"},
+		{input: "[a = b = [c], d]", want: "[a=b=[c],d]"},
+		{input: "[a,b] = [c,d]", want: "[a,b]=[c,d]"},
+		{input: "[{a:b,c:{d:[e]}}]", want: "[{a: b, c: {d: [e]}}]"},
+		{input: "[a];[b]=[c]", want: "[a]\n[b]=[c]"},
+		{input: `[a = 1, {b: "c\n"}, 0.5, true]`, want: `[a=1,{b: "c\n"},0.5,true]`},
+		{input: "['abc']", want: "[abc]"},
+		{input: "['a b',c]", want: "['a b',c]"},
+		{input: `['it\'s','back\\slash','tab\t','true']`, want: `['it\'s','back\\slash','tab\t','true']`},
+		{input: `[{'ünï': '\u0007'}]`, want: `[{'ünï': '\u0007'}]`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			got := parser.program().String()
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
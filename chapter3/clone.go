@@ -0,0 +1,129 @@
+package backtracking
+
+import "slices"
+
+// Copying and comparing trees
+//
+// Nodes are pointers, so a tree copied with = is the same tree: rewriting one
+// copy rewrites the other too. Clone makes a deep copy that shares no node
+// with the original, to change a tree while keeping the old one, or to put the
+// same subtree in two places.
+//
+// Equal compares trees by structure: two trees are equal if their nodes are
+// of the same types in the same shape and their tokens have the same type and
+// text. Where they were found doesn't matter, `[a]` on the first line equals
+// `[a]` on the tenth.
+
+// Clone returns a deep copy of the tree rooted at n.
+func Clone[T Node](n T) T {
+	c, _ := cloneNode(n).(T)
+	return c
+}
+
+func cloneNode(n Node) Node {
+	switch n := n.(type) {
+	case *ProgramNode:
+		return &ProgramNode{Stats: cloneAll(n.Stats)}
+	case *ListNode:
+		return &ListNode{Lbrack: n.Lbrack, Elements: cloneAll(n.Elements)}
+	case *AssignNode:
+		return &AssignNode{Left: Clone(n.Left), Right: Clone(n.Right)}
+	case *MapNode:
+		return &MapNode{Lbrace: n.Lbrace, Pairs: cloneAll(n.Pairs)}
+	case *PairNode:
+		return &PairNode{Key: Clone(n.Key), Value: Clone(n.Value)}
+	case *NameNode:
+		return copyOf(n)
+	case *IntNode:
+		return copyOf(n)
+	case *FloatNode:
+		return copyOf(n)
+	case *StringNode:
+		return copyOf(n)
+	case *BoolNode:
+		return copyOf(n)
+	case *ErrorNode:
+		c := copyOf(n)
+		c.Tokens = clone(n.Tokens)
+		return c
+	default:
+		return n
+	}
+}
+
+func cloneAll[T Node](nodes []T) []T {
+	if nodes == nil {
+		return nil
+	}
+	c := make([]T, len(nodes))
+	for i, n := range nodes {
+		c[i] = Clone(n)
+	}
+	return c
+}
+
+// copyOf copies a leaf, which has no pointers to other nodes
+func copyOf[T any](n *T) *T {
+	c := *n
+	return &c
+}
+
+// Equal tells whether the trees rooted at a and b have the same structure.
+func Equal(a, b Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	switch a := a.(type) {
+	case *ProgramNode:
+		b, ok := b.(*ProgramNode)
+		return ok && equalAll(a.Stats, b.Stats)
+	case *ListNode:
+		b, ok := b.(*ListNode)
+		return ok && equalAll(a.Elements, b.Elements)
+	case *AssignNode:
+		b, ok := b.(*AssignNode)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Right, b.Right)
+	case *MapNode:
+		b, ok := b.(*MapNode)
+		return ok && equalAll(a.Pairs, b.Pairs)
+	case *PairNode:
+		b, ok := b.(*PairNode)
+		return ok && Equal(a.Key, b.Key) && Equal(a.Value, b.Value)
+	case *NameNode:
+		b, ok := b.(*NameNode)
+		return ok && sameToken(a.Token, b.Token)
+	case *IntNode:
+		b, ok := b.(*IntNode)
+		return ok && sameToken(a.Token, b.Token)
+	case *FloatNode:
+		b, ok := b.(*FloatNode)
+		return ok && sameToken(a.Token, b.Token)
+	case *StringNode:
+		b, ok := b.(*StringNode)
+		return ok && sameToken(a.Token, b.Token)
+	case *BoolNode:
+		b, ok := b.(*BoolNode)
+		return ok && sameToken(a.Token, b.Token)
+	case *ErrorNode:
+		b, ok := b.(*ErrorNode)
+		return ok && slices.EqualFunc(a.Tokens, b.Tokens, sameToken)
+	default:
+		return false
+	}
+}
+
+func equalAll[T Node](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameToken(a, b Token) bool {
+	return a.Type == b.Type && a.Text == b.Text
+}
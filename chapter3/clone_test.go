@@ -0,0 +1,78 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClone(t *testing.T) {
+	prog, err := NewBacktrackingParser(NewLexer("[a,b=[c,1.5],{d: 'e f'}]=[true]; [\"g\"]")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Clone(prog)
+	if !cmp.Equal(c, prog) {
+		t.Fatal(cmp.Diff(c, prog))
+	}
+
+	// no node is shared with the original
+	seen := map[Node]bool{}
+	for n := range Preorder(prog) {
+		seen[n] = true
+	}
+	for n := range Preorder(c) {
+		if seen[n] {
+			t.Errorf("%T %s is shared", n, n)
+		}
+	}
+
+	c.Stats[0].(*AssignNode).Right.(*ListNode).Elements[0] = name("x")
+	if got := prog.String(); got != "[a,b=[c,1.5],{d: 'e f'}]=[true]\n[\"g\"]" {
+		t.Errorf("original changed to %s", got)
+	}
+
+	if got := Clone[Node](nil); got != nil {
+		t.Errorf("want: nil, got: %v", got)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "[a]", b: "[a]", want: true},
+		{a: "[a,b=[c]]", b: "\n\n[ a , b = [ c ] ]", want: true},
+		{a: "[{a: b}]=[1]", b: "[{a: b}]=[1]", want: true},
+		{a: "[a]", b: "[b]", want: false},
+		{a: "[a]", b: "['a b']", want: false},
+		{a: "[a]", b: "[a,a]", want: false},
+		{a: "[a]", b: "[[a]]", want: false},
+		{a: "[1]", b: "[1.0]", want: false},
+		{a: "[true]", b: "[false]", want: false},
+		{a: "[a]=[b]", b: "[a];[b]", want: false},
+		{a: "[{a: b}]", b: "[{a: c}]", want: false},
+	}
+
+	for _, tc := range cases {
+		a, err := NewBacktrackingParser(NewLexer(tc.a)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewBacktrackingParser(NewLexer(tc.b)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Equal(a, b); got != tc.want {
+			t.Errorf("Equal(%q, %q): want: %v, got: %v", tc.a, tc.b, tc.want, got)
+		}
+		if got := Equal(b, a); got != tc.want {
+			t.Errorf("Equal(%q, %q): want: %v, got: %v", tc.b, tc.a, tc.want, got)
+		}
+	}
+
+	if Equal(name("a"), nil) || !Equal(nil, nil) {
+		t.Error("nil is only equal to nil")
+	}
+}
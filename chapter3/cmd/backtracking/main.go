@@ -0,0 +1,551 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"example.com/backtracking"
+	"golang.org/x/tools/txtar"
+)
+
+// Command backtracking lexes, parses, lints, formats, checks, completes,
+// translates and converts programs of lists with the backtracking parser of
+// package backtracking, each command given by name as the first argument.
+
+// commands run by name as the first argument
+var commands = map[string]func(args []string, out io.Writer) error{
+	"parse":     parseCmd,
+	"diff":      diffCmd,
+	"fmt":       fmtCmd,
+	"check":     checkCmd,
+	"complete":  completeCmd,
+	"translate": translateCmd,
+	"json":      jsonCmd,
+	"yaml":      yamlCmd,
+	"cover":     coverCmd,
+	"trace":     traceCmd,
+	"lint":      lintCmd,
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command named by the first argument, or inputCmd if there's
+// none with that name.
+func run(args []string, in io.Reader, out io.Writer) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd(args[1:], out)
+		}
+	}
+	return inputCmd(args, in, out)
+}
+
+// inputCmd lexes or parses the program given as arguments, or read from the
+// standard input if there are none:
+//
+//	backtracking [-output tokens|errors|tree] [-trailing] [-comments] [-unicode] '[a,b]=[c,d]'
+//	backtracking -output errors [-max-errors n] [-json] < prog.txt
+//
+// The tokens are printed one per line, the tree as parseCmd does. With
+// -output errors the parser recovers from syntax errors to print all of them,
+// or the first n with -max-errors, along with the warnings about trailing
+// commas if -trailing accepts them and the infos, one per line sorted by
+// position, or as JSON with -json. -comments skips '#' comments and -unicode
+// accepts names of any letters.
+func inputCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("backtracking", flag.ContinueOnError)
+	output := fs.String("output", "tokens", "what to print: tokens, errors or tree")
+	trailing := fs.Bool("trailing", false, "accept trailing commas, with a warning")
+	comments := fs.Bool("comments", false, "skip comments, from '#' to the end of the line")
+	unicode := fs.Bool("unicode", false, "accept names of any Unicode letters")
+	maxErrors := fs.Int("max-errors", 0, "stop after this many syntax errors, 0 never stops")
+	asJSON := fs.Bool("json", false, "print the errors, warnings and infos as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var opts []backtracking.LexerOption
+	if *comments {
+		opts = append(opts, backtracking.WithComments())
+	}
+	if *unicode {
+		opts = append(opts, backtracking.WithUnicodeNames())
+	}
+	src := strings.Join(fs.Args(), " ")
+	if fs.NArg() == 0 {
+		b, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		src = string(b)
+	}
+
+	switch *output {
+	case "tokens":
+		l := backtracking.NewLexer(src, opts...)
+		for l.Scan() {
+			tok, err := l.Next()
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(out, tok.Type, tok.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "errors":
+		p := backtracking.NewBacktrackingParser(backtracking.NewLexer(src, opts...), backtracking.WithMaxErrors(*maxErrors))
+		p.AllowTrailingComma = *trailing
+		_, err := p.Parse()
+		ds := p.Diagnostics()
+		if *asJSON {
+			if err := backtracking.WriteDiagnosticsJSON(out, ds); err != nil {
+				return err
+			}
+		} else {
+			if err := backtracking.WriteDiagnostics(out, ds); err != nil {
+				return err
+			}
+			if errors.Is(err, backtracking.ErrTooManyErrors) {
+				if _, err := fmt.Fprintln(out, backtracking.ErrTooManyErrors); err != nil {
+					return err
+				}
+			}
+		}
+		errs := 0
+		for _, d := range ds {
+			if d.Severity == backtracking.SeverityError {
+				errs++
+			}
+		}
+		if errs > 0 {
+			return fmt.Errorf("%d syntax errors found", errs)
+		}
+		return nil
+	case "tree":
+		p := backtracking.NewBacktrackingParser(backtracking.NewLexer(src, opts...))
+		p.AllowTrailingComma = *trailing
+		prog, err := p.Parse()
+		if err != nil {
+			return err
+		}
+		return backtracking.PrintTree(out, prog)
+	default:
+		return fmt.Errorf("unknown output %q, want tokens, errors or tree", *output)
+	}
+}
+
+// parseCmd parses the program given as arguments and prints the tree:
+//
+//	backtracking parse [-dot] [-recover] [-tree ast|homogeneous|parse] [-memoize] [-memo-limit n] [-memo-stats] [-memo-dump] '[a,b]=[c,d]'
+//
+// With -recover the tree is printed even if there are syntax errors, with
+// error nodes in place of the bad input. With -memoize the parser memoizes
+// the lists it speculates, at most n at a time with -memo-limit, see
+// memo.go. Before the tree -memo-dump prints what the memo held each time
+// it was cleared, and -memo-stats how the memo of each rule was used.
+func parseCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	dot := fs.Bool("dot", false, "write the tree as a Graphviz DOT graph")
+	recoverErrors := fs.Bool("recover", false, "recover from syntax errors")
+	kind := fs.String("tree", "ast", "which tree to print: ast, homogeneous or parse")
+	memoize := fs.Bool("memoize", false, "memoize the lists speculated")
+	memoLimit := fs.Int("memo-limit", 0, "memoize `n` lists at most, 0 for no limit")
+	memoStats := fs.Bool("memo-stats", false, "print how the memo was used")
+	memoDump := fs.Bool("memo-dump", false, "print the memo each time it's cleared")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: parse [-dot] [-recover] [-tree ast|homogeneous|parse] [-memoize] [-memo-limit n] [-memo-stats] [-memo-dump] program")
+	}
+
+	var opts []backtracking.ParserOption
+	if *memoLimit > 0 {
+		opts = append(opts, backtracking.WithMemoLimit(*memoLimit))
+	}
+	if *memoDump {
+		opts = append(opts, backtracking.WithMemoDump(out))
+	}
+	p := backtracking.NewBacktrackingParser(backtracking.NewLexer(strings.Join(fs.Args(), " ")), opts...)
+	p.BuildParseTree = *kind == "parse"
+	p.RecoverErrors = *recoverErrors
+	p.Memoize = p.Memoize || *memoize || *memoStats
+	prog, parseErr := p.Parse()
+	if *memoStats {
+		for _, rule := range slices.Sorted(maps.Keys(p.MemoStats)) {
+			fmt.Fprintf(out, "memo of %s: %v\n", rule, p.MemoStats[rule])
+		}
+	}
+	if prog == nil {
+		return parseErr
+	}
+
+	var tree any
+	switch *kind {
+	case "ast":
+		tree = prog
+	case "homogeneous":
+		tree = backtracking.ToAST(prog)
+	case "parse":
+		tree = p.ParseTree
+	default:
+		return fmt.Errorf("unknown tree %q, want ast, homogeneous or parse", *kind)
+	}
+
+	if err := printTree(out, tree, *dot); err != nil {
+		return err
+	}
+	return parseErr
+}
+
+func printTree(out io.Writer, tree any, dot bool) error {
+	if dot {
+		return backtracking.WriteDOT(out, tree)
+	}
+	if prog, ok := tree.(*backtracking.ProgramNode); ok {
+		return backtracking.PrintTree(out, prog)
+	}
+	_, err := fmt.Fprintln(out, tree)
+	return err
+}
+
+// diffCmd prints what changed between two programs, one change per line:
+//
+//	backtracking diff '[a,b,c]' '[c,a,b]'
+func diffCmd(args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: diff old new")
+	}
+	var progs [2]*backtracking.ProgramNode
+	for i, src := range args {
+		prog, err := backtracking.Parse(src)
+		if err != nil {
+			return err
+		}
+		progs[i] = prog
+	}
+	for _, c := range backtracking.Diff(progs[0], progs[1]) {
+		if _, err := fmt.Fprintln(out, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fmtCmd parses the program given as arguments and prints it back formatted:
+//
+//	backtracking fmt [-spaces] [-width 80] [-indent '  '] '[a,b]=[c,d]'
+func fmtCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	var f backtracking.Formatter
+	fs.BoolVar(&f.Spaces, "spaces", false, "put a space after commas and around '='")
+	fs.IntVar(&f.MaxWidth, "width", 0, "split lists wider than this one element per line, 0 never splits")
+	fs.StringVar(&f.Indent, "indent", "  ", "indentation of split elements")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: fmt [-spaces] [-width n] [-indent s] program")
+	}
+
+	prog, err := backtracking.Parse(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, f.Format(prog))
+	return err
+}
+
+// checkCmd parses the program given as arguments and prints the problems in
+// its assignments, one per line, see assigngraph.go:
+//
+//	backtracking check 'a=b; [b,c]=[c,a]'
+func checkCmd(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: check program")
+	}
+	prog, err := backtracking.Parse(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	errs := backtracking.NewAssignGraph(prog).Check()
+	for _, err := range errs {
+		if _, err := fmt.Fprintln(out, err); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d problems found", len(errs))
+	}
+	return nil
+}
+
+// completeCmd prints what can be typed at a byte offset of the program
+// given as arguments, the end by default, one completion per line with its
+// token type, see complete.go:
+//
+//	backtracking complete [-at n] [-trailing] '[a, b]; [b, tr'
+func completeCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("complete", flag.ContinueOnError)
+	at := fs.Int("at", -1, "complete at this byte offset, -1 for the end")
+	trailing := fs.Bool("trailing", false, "accept trailing commas")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: complete [-at n] [-trailing] program")
+	}
+	src := strings.Join(fs.Args(), " ")
+	if *at < 0 {
+		*at = len(src)
+	}
+	cs, err := backtracking.Complete(src, *at, *trailing)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if _, err := fmt.Fprintln(out, strings.TrimSpace(c.Type.String()+" "+c.Text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translators are the languages translateCmd translates to, see translate.go
+var translators = map[string]*backtracking.Translator{
+	"python": backtracking.Python,
+}
+
+// translateCmd parses the program given as arguments and prints its
+// translation to another language:
+//
+//	backtracking translate [-to python] '[a,b]=[c,{d: true}]'
+func translateCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("translate", flag.ContinueOnError)
+	to := fs.String("to", "python", "the language to translate to: python")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: translate [-to python] program")
+	}
+	t, ok := translators[*to]
+	if !ok {
+		return fmt.Errorf("unknown language %q, want python", *to)
+	}
+
+	prog, err := backtracking.Parse(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	text, err := t.Translate(prog)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, text)
+	return err
+}
+
+// jsonCmd parses the program given as arguments and prints it as JSON, one
+// value per statement, see convert.go:
+//
+//	backtracking json [-indent '  '] '[a,[b,c],d=e]'
+func jsonCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("json", flag.ContinueOnError)
+	indent := fs.String("indent", "", "indent each element on its own line, compact if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: json [-indent s] program")
+	}
+	prog, err := backtracking.Parse(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	text, err := backtracking.ToJSON(prog, *indent)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, text)
+	return err
+}
+
+// yamlCmd parses the program given as arguments and prints it as YAML, one
+// document per statement, see convert.go:
+//
+//	backtracking yaml '[a,[b,c],d=e]'
+func yamlCmd(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: yaml program")
+	}
+	prog, err := backtracking.Parse(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	text, err := backtracking.ToYAML(prog)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, text)
+	return err
+}
+
+// coverCmd parses the programs of a test corpus and prints the branches of
+// the grammar none of them takes, see cover.go:
+//
+//	backtracking cover [-trailing] testdata/good.txt
+//
+// Each line of the files named is a program. A txtar archive, such as the
+// corpora of the tests, has its programs in its files.
+func coverCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("cover", flag.ContinueOnError)
+	trailing := fs.Bool("trailing", false, "accept trailing commas")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: cover [-trailing] file...")
+	}
+
+	var progs []string
+	for _, name := range fs.Args() {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		ar := txtar.Parse(b)
+		if len(ar.Files) == 0 {
+			ar.Files = []txtar.File{{Name: name, Data: b}}
+		}
+		for _, file := range ar.Files {
+			for _, line := range strings.Split(string(file.Data), "\n") {
+				if line != "" {
+					progs = append(progs, line)
+				}
+			}
+		}
+	}
+	c, err := backtracking.Cover(progs, *trailing)
+	if err != nil {
+		return err
+	}
+	return c.Report(out)
+}
+
+// lintCmd parses the files named, a statement at a time on as many
+// goroutines as -jobs says, see parallel.go, and prints their errors,
+// warnings and infos as inputCmd does, each after the name of its file:
+//
+//	backtracking lint [-jobs n] [-max-errors n] [-trailing] [-comments] [-unicode] file...
+//
+// The files are printed in the order they're named, the diagnostics of each
+// sorted by position, however many jobs there are. -max-errors stops a file
+// after n errors, not all of them.
+func lintCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	jobs := fs.Int("jobs", 0, "parse on `n` goroutines, 0 for as many as there are processors")
+	maxErrors := fs.Int("max-errors", 0, "stop a file after this many syntax errors, 0 never stops")
+	trailing := fs.Bool("trailing", false, "accept trailing commas, with a warning")
+	comments := fs.Bool("comments", false, "skip comments, from '#' to the end of the line")
+	unicode := fs.Bool("unicode", false, "accept names of any Unicode letters")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: lint [-jobs n] [-max-errors n] [-trailing] [-comments] [-unicode] file...")
+	}
+	var lexOpts []backtracking.LexerOption
+	if *comments {
+		lexOpts = append(lexOpts, backtracking.WithComments())
+	}
+	if *unicode {
+		lexOpts = append(lexOpts, backtracking.WithUnicodeNames())
+	}
+
+	var files []backtracking.File
+	for _, name := range fs.Args() {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		files = append(files, backtracking.File{Name: name, Src: string(b)})
+	}
+	opts := []backtracking.ParserOption{
+		backtracking.WithMaxErrors(*maxErrors),
+		func(p *backtracking.BacktrackingParser) { p.AllowTrailingComma = *trailing },
+	}
+	errs := 0
+	for _, f := range backtracking.ParseFiles(files, *jobs, lexOpts, opts...) {
+		for _, d := range f.Diagnostics {
+			if _, err := fmt.Fprintf(out, "%s:%v\n", f.Name, d); err != nil {
+				return err
+			}
+			if d.Severity == backtracking.SeverityError {
+				errs++
+			}
+		}
+		if errors.Is(f.Err, backtracking.ErrTooManyErrors) {
+			if _, err := fmt.Fprintf(out, "%s: %v\n", f.Name, backtracking.ErrTooManyErrors); err != nil {
+				return err
+			}
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d syntax errors found", errs)
+	}
+	return nil
+}
+
+// traceWriters write the decisions of the parser in each format of traceCmd
+var traceWriters = map[string]func(io.Writer, []*backtracking.Decision) error{
+	"text": backtracking.WriteTrace,
+	"dot":  backtracking.WriteTraceDOT,
+	"html": backtracking.WriteTraceHTML,
+}
+
+// traceCmd parses the program given as arguments and prints the decisions
+// the parser made, the speculations and the backtracking, see trace.go:
+//
+//	backtracking trace [-format text|dot|html] [-memoize] [-trailing] '[a,b]=[c,d]'
+//
+// The trace is printed even if there's a syntax error, which is returned
+// after it. With -memoize the lists speculated again are skipped, see
+// memo.go, and their decisions aren't made twice.
+func traceCmd(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
+	format := fs.String("format", "text", "how to print the trace: text, dot or html")
+	memoize := fs.Bool("memoize", false, "memoize the lists speculated")
+	trailing := fs.Bool("trailing", false, "accept trailing commas")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: trace [-format text|dot|html] [-memoize] [-trailing] program")
+	}
+	write, ok := traceWriters[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %q, want text, dot or html", *format)
+	}
+
+	p := backtracking.NewBacktrackingParser(backtracking.NewLexer(strings.Join(fs.Args(), " ")))
+	p.TraceDecisions = true
+	p.Memoize = *memoize
+	p.AllowTrailingComma = *trailing
+	_, parseErr := p.Parse()
+	if err := write(out, p.Decisions); err != nil {
+		return err
+	}
+	return parseErr
+}
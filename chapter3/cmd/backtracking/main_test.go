@@ -0,0 +1,343 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/backtracking"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCmd(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"[a]"}, want: "ProgramNode\n└── ListNode\n    └── NameNode a\n"},
+		{args: []string{"-tree", "homogeneous", "[a]=[b]"}, want: "(PROGRAM (= (LIST a) (LIST b)))\n"},
+		{args: []string{"-tree", "parse", "[a]"}, want: "(program (stat (list [ (elements (element a)) ])) <EOF>)\n"},
+		{args: []string{"-memo-stats", "-tree", "homogeneous", "[[a]]=[b]"}, want: "memo of list: 1 hits, 3 misses, 0 evictions, 0 memoized, 3 at most\n(PROGRAM (= (LIST (LIST a)) (LIST b)))\n"},
+		{args: []string{"-memo-dump", "-memo-limit", "1", "-tree", "homogeneous", "[[a]]=[b]"}, want: "memo cleared at 1:1 [\n  list 1:7 skips 3 tokens\n(PROGRAM (= (LIST (LIST a)) (LIST b)))\n"},
+		{args: []string{"--dot", "[]"}, want: "digraph tree {\n\tnode [shape=box, fontname=\"monospace\"];\n\tn0 [label=\"ProgramNode\"];\n\tn1 [label=\"ListNode\"];\n\tn0 -> n1;\n}\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var s strings.Builder
+			if err := parseCmd(tc.args, &s); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != tc.want {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+
+	for _, args := range [][]string{nil, {"[a"}, {"-tree", "other", "[a]"}} {
+		if err := parseCmd(args, &strings.Builder{}); err == nil {
+			t.Errorf("%q: want: error, got: nil", args)
+		}
+	}
+
+	// with -recover the tree is printed, and the errors returned
+	var s strings.Builder
+	err := parseCmd([]string{"-recover", "-tree", "homogeneous", "[a,,b]"}, &s)
+	if want := "(PROGRAM (LIST a (ERROR) b))\n"; s.String() != want {
+		t.Errorf("want: %q, got: %q", want, s.String())
+	}
+	if !errors.Is(err, backtracking.SyntaxError) {
+		t.Errorf("want: syntax error, got: %v", err)
+	}
+}
+
+func TestDiffCmd(t *testing.T) {
+	var s strings.Builder
+	if err := diffCmd([]string{"[a,b,c,d]", "[d,a,x,c]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := "1:4: changed b to x\n1:8: moved d to 1:2\n"
+	if got := s.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+	if err := diffCmd([]string{"[a]"}, &s); err == nil {
+		t.Error("want: error, got: nil")
+	}
+}
+
+func TestFmtCmd(t *testing.T) {
+	var s strings.Builder
+	if err := fmtCmd([]string{"-spaces", "-width", "12", "[a,b=[c,d]]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := "[\n  a,\n  b = [c, d]\n]\n"
+	if got := s.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+	for _, args := range [][]string{nil, {"[a"}} {
+		if err := fmtCmd(args, &strings.Builder{}); err == nil {
+			t.Errorf("%q: want: error, got: nil", args)
+		}
+	}
+}
+
+func TestTranslateCmd(t *testing.T) {
+	var s strings.Builder
+	if err := translateCmd([]string{"-to", "python", "[a,b]=[c,{d: true}]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a, b = c, {\"d\": True}\n"; s.String() != want {
+		t.Errorf("want: %q, got: %q", want, s.String())
+	}
+	for _, args := range [][]string{nil, {"[a"}, {"-to", "cobol", "[a]"}} {
+		if err := translateCmd(args, &strings.Builder{}); err == nil {
+			t.Errorf("%q: want: error, got: nil", args)
+		}
+	}
+}
+
+func TestConvertCmds(t *testing.T) {
+	var s strings.Builder
+	if err := jsonCmd([]string{"-indent", " ", "[a,d=e]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[\n \"a\",\n {\n  \"d\": \"e\"\n }\n]\n"; s.String() != want {
+		t.Errorf("want: %q, got: %q", want, s.String())
+	}
+	s.Reset()
+	if err := yamlCmd([]string{"[a,d=e]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := "- a\n- d: e\n"; s.String() != want {
+		t.Errorf("want: %q, got: %q", want, s.String())
+	}
+	for _, args := range [][]string{nil, {"[a"}, {"[a]=[]"}} {
+		if err := jsonCmd(args, &strings.Builder{}); err == nil {
+			t.Errorf("json %q: want: error, got: nil", args)
+		}
+		if err := yamlCmd(args, &strings.Builder{}); err == nil {
+			t.Errorf("yaml %q: want: error, got: nil", args)
+		}
+	}
+}
+
+func TestCompleteCmd(t *testing.T) {
+	var s strings.Builder
+	if err := completeCmd([]string{"[a, b]; [b,", "tr"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := "True true\n"; s.String() != want {
+		t.Errorf("want: %q, got: %q", want, s.String())
+	}
+
+	s.Reset()
+	if err := completeCmd([]string{"-at", "3", "-trailing", "[a,b]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := "Name a\nName b\nLBrack [\nLBrace {\nInt\nFloat\nString\nTrue true\nFalse false\nRBrack ]\n"
+	if s.String() != want {
+		t.Errorf("want: %q, got: %q", want, s.String())
+	}
+
+	err := completeCmd([]string{"[a] ["}, &strings.Builder{})
+	if want := "1:5: syntax error: LBrack can't come there"; fmt.Sprint(err) != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+}
+
+func TestCheckCmd(t *testing.T) {
+	var s strings.Builder
+	if err := checkCmd([]string{"[a,b]=[c,d]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("want no output, got: %q", s.String())
+	}
+
+	err := checkCmd([]string{"[a=b, b=a]"}, &s)
+	if want := "1 problems found"; fmt.Sprint(err) != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+	if want := "1:2: cycle: a -> b -> a\n"; s.String() != want {
+		t.Errorf("want: %q, got: %q", want, s.String())
+	}
+}
+
+func TestCoverCmd(t *testing.T) {
+	// the corpus of the parser tests has one program per line, so no
+	// newlines between statements, and no trailing commas
+	var s strings.Builder
+	if err := coverCmd([]string{"../../testdata/good.txt"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := "4:1: sep: NEWLINE\n8:1: elements: ','? matching one\n13:1: pairs: ','? matching one\n36 of 39 branches covered\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+	if err := coverCmd(nil, &s); err == nil {
+		t.Error("want: error, got: nil")
+	}
+}
+
+func TestLintCmd(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(a, []byte("[a]\n[b,,c]; ;\n[d,]"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("[x,]\n[y z]\n[]"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, jobs := range []string{"1", "3"} {
+		var s strings.Builder
+		err := lintCmd([]string{"-jobs", jobs, "-trailing", a, b}, &s)
+		if want := "2 syntax errors found"; err == nil || err.Error() != want {
+			t.Errorf("jobs %s: want: %s, got: %v", jobs, want, err)
+		}
+		want := a + ":2:4: error E001: syntax error: expecting name, list, map or literal, found Comma\n" +
+			a + ":2:9: info I001: empty statement, found Semicolon \";\"\n" +
+			a + ":3:3: warning W001: trailing comma in list, found Comma \",\"\n" +
+			b + ":1:3: warning W001: trailing comma in list, found Comma \",\"\n" +
+			b + ":2:4: error E001: match: syntax error: expecting RBrack, got Name\n"
+		if got := s.String(); got != want {
+			t.Errorf("jobs %s: %s", jobs, cmp.Diff(want, got))
+		}
+	}
+
+	var s strings.Builder
+	if err := lintCmd([]string{"-max-errors", "1", b, a}, &s); err == nil {
+		t.Error("want: error, got: nil")
+	}
+	if want := b + ": too many errors\n"; !strings.Contains(s.String(), want) {
+		t.Errorf("want: %q in %q", want, s.String())
+	}
+	if err := lintCmd(nil, &s); err == nil {
+		t.Error("want: error, got: nil")
+	}
+}
+
+func TestTraceCmd(t *testing.T) {
+	var s strings.Builder
+	if err := traceCmd([]string{"-format", "dot", "[a]"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s.String(), "digraph trace {") {
+		t.Errorf("want: a DOT graph, got: %q", s.String())
+	}
+
+	// the trace up to a syntax error is printed, and the error returned
+	s.Reset()
+	err := traceCmd([]string{"[a"}, &s)
+	if !errors.Is(err, backtracking.SyntaxError) {
+		t.Errorf("want: syntax error, got: %v", err)
+	}
+	if want := "1:1 stat, speculating: no alternative\n"; !strings.Contains(s.String(), want) {
+		t.Errorf("want: %q in %q", want, s.String())
+	}
+
+	for _, args := range [][]string{nil, {"-format", "svg", "[a]"}} {
+		if err := traceCmd(args, &strings.Builder{}); err == nil {
+			t.Errorf("%q: want: error, got: nil", args)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "tokens of the arguments",
+			args: []string{"[a,", "b=1]"},
+			want: "LBrack [\nName a\nComma ,\nName b\nEquals =\nInt 1\nRBrack ]\nEOF \n",
+		},
+		{
+			name: "tokens of the input",
+			in:   "[a]\n",
+			want: "LBrack [\nName a\nRBrack ]\nNewline \n\nEOF \n",
+		},
+		{
+			name: "tree",
+			args: []string{"-output", "tree"},
+			in:   "[a]=[b]",
+			want: "ProgramNode\n└── AssignNode\n    ├── ListNode\n    │   └── NameNode a\n    └── ListNode\n        └── NameNode b\n",
+		},
+		{
+			name: "no errors",
+			args: []string{"-output", "errors", "[a,{b: c}]"},
+		},
+		{
+			name:    "all the errors",
+			args:    []string{"-output", "errors", "-trailing"},
+			in:      "[a,,b]; [c d]\n[e,]",
+			want:    "1:4: error E001: syntax error: expecting name, list, map or literal, found Comma\n1:12: error E001: match: syntax error: expecting RBrack, got Name\n2:3: warning W001: trailing comma in list, found Comma \",\"\n",
+			wantErr: "2 syntax errors found",
+		},
+		{
+			name:    "lexer error",
+			args:    []string{"-output", "errors", "[a#]"},
+			want:    "1:3: error E002: non-letter character: #\n",
+			wantErr: "1 syntax errors found",
+		},
+		{
+			name:    "json",
+			args:    []string{"-output", "errors", "-json", "-trailing"},
+			in:      "[a,]; ; [b c]",
+			want:    `[{"line":1,"col":3,"severity":"warning","code":"W001","message":"trailing comma in list, found Comma \",\"","fixes":[{"title":"remove \",\"","from":{"line":1,"col":3},"to":{"line":1,"col":4},"text":""}]},{"line":1,"col":7,"severity":"info","code":"I001","message":"empty statement, found Semicolon \";\""},{"line":1,"col":12,"severity":"error","code":"E001","message":"match: syntax error: expecting RBrack, got Name"}]` + "\n",
+			wantErr: "1 syntax errors found",
+		},
+		{
+			name: "json without diagnostics",
+			args: []string{"-output", "errors", "-json", "[a]"},
+			want: "[]\n",
+		},
+		{
+			name:    "too many errors",
+			args:    []string{"-output", "errors", "-max-errors", "1"},
+			in:      "[a,,b]; [c d]",
+			want:    "1:4: error E001: syntax error: expecting name, list, map or literal, found Comma\ntoo many errors\n",
+			wantErr: "1 syntax errors found",
+		},
+		{
+			name: "comments and unicode names",
+			args: []string{"-output", "tree", "-comments", "-unicode"},
+			in:   "# the list\n[año]",
+			want: "ProgramNode\n└── ListNode\n    └── NameNode 'año'\n",
+		},
+		{
+			name: "command",
+			args: []string{"fmt", "-spaces", "[a,b]"},
+			want: "[a, b]\n",
+		},
+		{
+			name:    "unknown output",
+			args:    []string{"-output", "json", "[a]"},
+			wantErr: `unknown output "json", want tokens, errors or tree`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var s strings.Builder
+			err := run(tc.args, strings.NewReader(tc.in), &s)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("got error %v, want %s", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != tc.want {
+				t.Error(cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package backtracking
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+
+	"example.com/grammar"
+)
+
+// Completion
+//
+// An editor completes what's being typed at the cursor with what can come
+// there: after `[a, ` any element, after `[{k ` a colon. The parser can't tell,
+// it knows what it expects at the first error, and on the way it backtracks
+// out of the statements it can't finish yet, which a program being typed
+// always has. The grammar can: grammar.g, the one the parser is checked
+// against for coverage, gives the tokens that can follow the ones before the
+// cursor, see Follow in package grammar.
+//
+// A word that the cursor is at the end of is being typed: what can follow is
+// what can come in its place, filtered down to the keywords and names that
+// start with it. Names are the ones the program uses elsewhere, a program of
+// lists declares none.
+//
+//	[a, b]; [b, |      names a and b, '[', '{', the literals
+//	[a, b]; [b, tr|    keyword true
+//	[{k|               names starting with k: none, k is being typed
+//	[{k |              ':'
+
+// Completion is something that can be typed at the cursor: a token that can
+// come next, or the rest of the word being typed. Text is the punctuation,
+// the keyword or the name, literals can be anything and have none.
+type Completion struct {
+	Type TokenType
+	Text string
+}
+
+// Complete returns what can be typed at offset, a byte offset in src, in the
+// order of the grammar, the names sorted. trailing accepts trailing commas,
+// which the grammar allows: without it ']' doesn't follow ','. It fails with
+// the error of the lexer, or a syntax error if the tokens before the cursor
+// don't start a program.
+func Complete(src string, offset int, trailing bool) ([]Completion, error) {
+	if offset < 0 || offset > len(src) {
+		return nil, errors.New("complete: offset out of range")
+	}
+	before, after := src[:offset], src[offset:]
+	word := before[len(strings.TrimRightFunc(before, isLetter)):]
+	before = before[:len(before)-len(word)]
+	if word != "" {
+		// the rest of the word being typed
+		after = strings.TrimLeftFunc(after, isLetter)
+	}
+
+	toks, err := tokens(before)
+	if err != nil {
+		return nil, err
+	}
+	rest, _ := tokens(after) // only for its names, the rest can be a mess
+	names := map[string]bool{}
+	for _, tok := range slices.Concat(toks, rest) {
+		if tok.Type == Name && strings.HasPrefix(tok.Text, word) {
+			names[tok.Text] = true
+		}
+	}
+
+	g, err := grammar.Parse(grammarSrc)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*grammar.Node, len(toks))
+	for i, tok := range toks {
+		nodes[i] = &grammar.Node{Token: grammarToken(tok.Type), Text: tok.Text}
+	}
+	terms, err := g.Follow(nodes)
+	var ferr *grammar.FollowError
+	if errors.As(err, &ferr) {
+		tok := toks[ferr.Index]
+		return nil, syntaxError(tok.Pos, "%v can't come there", tok.Type)
+	} else if err != nil {
+		return nil, err
+	}
+
+	afterComma := len(toks) > 0 && toks[len(toks)-1].Type == Comma
+	var cs []Completion
+	for _, term := range terms {
+		switch term := term.(type) {
+		case *grammar.Lit:
+			tok, err := NewLexer(term.Text).Next()
+			if err != nil || !strings.HasPrefix(term.Text, word) {
+				continue
+			}
+			if afterComma && !trailing && (tok.Type == RBrack || tok.Type == RBrace) {
+				continue
+			}
+			cs = append(cs, Completion{Type: tok.Type, Text: term.Text})
+		case *grammar.Ref:
+			switch typ := tokenType(term.Name); typ {
+			case Name:
+				for _, name := range slices.Sorted(maps.Keys(names)) {
+					cs = append(cs, Completion{Type: Name, Text: name})
+				}
+			case Int, Float, String:
+				if word == "" {
+					cs = append(cs, Completion{Type: typ})
+				}
+			}
+			// nothing to type for the end of a statement or the input but
+			// a newline, or nothing
+		}
+	}
+	return cs, nil
+}
+
+// tokens returns the tokens of src without the EOF, the ones before the
+// error of the lexer if there's one.
+func tokens(src string) ([]Token, error) {
+	var toks []Token
+	lex := NewLexer(src)
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return toks, err
+		}
+		if tok.Type == EOF {
+			return toks, nil
+		}
+		toks = append(toks, tok)
+	}
+}
+
+// grammarToken is the name of a token type in grammar.g.
+func grammarToken(typ TokenType) string {
+	return strings.ToUpper(typ.String())
+}
+
+// tokenType is the token type named name in grammar.g, EOF if there's none.
+func tokenType(name string) TokenType {
+	for typ := EOF; typ <= RParen; typ++ {
+		if grammarToken(typ) == name {
+			return typ
+		}
+	}
+	return EOF
+}
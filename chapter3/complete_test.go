@@ -0,0 +1,111 @@
+package backtracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComplete(t *testing.T) {
+	elements := []string{"LBrack [", "LBrace {", "Int", "Float", "String", "True true", "False false"}
+	cases := []struct {
+		name     string
+		src      string // the cursor at |, or the end
+		trailing bool
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "empty",
+			src:  "",
+			want: []string{"LBrack [", "Semicolon ;"},
+		},
+		{
+			name: "element",
+			src:  "[a, b]; [b, ",
+			want: append([]string{"Name a", "Name b"}, elements...),
+		},
+		{
+			name: "after a name",
+			src:  "[a ",
+			want: []string{"Equals =", "Comma ,", "RBrack ]"},
+		},
+		{
+			name: "keyword being typed",
+			src:  "[a, b]; [b, tr",
+			want: []string{"True true"},
+		},
+		{
+			name: "name being typed",
+			src:  "[alpha, beta]; [al|pha]",
+			want: []string{"Name alpha"},
+		},
+		{
+			name: "name being typed, used nowhere else",
+			src:  "[{k",
+		},
+		{
+			name: "names after the cursor",
+			src:  "[|x, [y]]",
+			want: append([]string{"Name x", "Name y"}, append(elements, "RBrack ]")...),
+		},
+		{
+			name: "pair",
+			src:  "[{k ",
+			want: []string{"Colon :"},
+		},
+		{
+			name: "end of statement",
+			src:  "[a]",
+			want: []string{"Semicolon ;", "Equals ="},
+		},
+		{
+			name: "no trailing comma",
+			src:  "[{k: v, ",
+			want: []string{"Name k", "Name v"},
+		},
+		{
+			name:     "trailing comma",
+			src:      "[{k: v, ",
+			trailing: true,
+			want:     []string{"Name k", "Name v", "RBrace }"},
+		},
+		{
+			name:    "syntax error",
+			src:     "[a] [",
+			wantErr: "1:5: syntax error: LBrack can't come there",
+		},
+		{
+			name:    "lexer error",
+			src:     "[a%",
+			wantErr: "1:3: non-letter character: %",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, offset := tc.src, len(tc.src)
+			if i := strings.Index(src, "|"); i >= 0 {
+				src, offset = src[:i]+src[i+1:], i
+			}
+			cs, err := Complete(src, offset, tc.trailing)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range cs {
+				got = append(got, strings.TrimSpace(c.Type.String()+" "+c.Text))
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
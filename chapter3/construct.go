@@ -0,0 +1,241 @@
+package backtracking
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tree construction
+//
+// ANTLR lets a grammar say which tree a rule builds with a rewrite such as
+//
+//	assign : list '=' list -> ^(ASSIGN list list) ;
+//
+// instead of code creating nodes. There's no parser generator in this
+// repository to take such rules, but the notation is just as useful to
+// hand-written parsers and tree passes: a Construction is compiled once from
+// the notation and then builds homogeneous trees out of the labeled subtrees
+// it's given, so the code only says what goes where.
+//
+//	^(ROOT child...)  a tree with an imaginary root and these children, roots
+//	                  are PROGRAM, LIST, ASSIGN (or =), MAP, PAIR and ERROR
+//	ROOT              the imaginary token alone, such as an empty LIST
+//	label             the single subtree given for label
+//	label?            the subtree given for label, if any
+//	label*            all the subtrees given for label, in order
+//	label+            same, but there has to be at least one
+//
+// For example the tree of a map is built with
+//
+//	MustParseConstruction("^(MAP pair*)").MustBuild(map[string][]*AST{"pair": pairs})
+//
+// An imaginary root takes the position of its first child, having no token of
+// its own in the input.
+
+// Grammar of the notation (ANTLR syntax):
+//
+// construction : '^(' ROOT element* ')' | ROOT ;
+// element      : construction | LABEL ('?' | '*' | '+')? ;
+// ROOT         : ('A'..'Z')+ | '=' ;
+// LABEL        : ('a'..'z') ('a'..'z'|'A'..'Z'|'0'..'9')* ;
+
+// Construction is a compiled tree construction.
+type Construction struct {
+	root     *Token          // imaginary token of a tree, nil for a label
+	label    string          // label the subtrees come from
+	min, max int             // how many subtrees the label takes, max < 0 for any
+	children []*Construction // children of a tree
+}
+
+// roots of constructions, ASSIGN and = are the same
+var roots = map[string]Token{
+	"PROGRAM": programToken,
+	"LIST":    listToken,
+	"ASSIGN":  equalsToken,
+	"=":       equalsToken,
+	"MAP":     mapToken,
+	"PAIR":    pairToken,
+	"ERROR":   errorToken,
+}
+
+// ParseConstruction compiles the tree construction in src.
+func ParseConstruction(src string) (*Construction, error) {
+	r := strings.NewReplacer("^(", " ^( ", ")", " ) ")
+	cp := &constructionParser{src: src, words: strings.Fields(r.Replace(src))}
+	c, err := cp.construction()
+	if err != nil {
+		return nil, err
+	}
+	if cp.pos < len(cp.words) {
+		return nil, fmt.Errorf("construction %q: unexpected %q after the tree", src, cp.words[cp.pos])
+	}
+	return c, nil
+}
+
+// MustParseConstruction is like ParseConstruction but panics on errors, for
+// constructions known at compile time.
+func MustParseConstruction(src string) *Construction {
+	c, err := ParseConstruction(src)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// constructionParser is a recursive-descent parser over the words of a
+// construction, just like patternParser.
+type constructionParser struct {
+	src   string
+	words []string
+	pos   int
+}
+
+func (cp *constructionParser) next() string {
+	if cp.pos >= len(cp.words) {
+		return ""
+	}
+	w := cp.words[cp.pos]
+	cp.pos++
+	return w
+}
+
+func (cp *constructionParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("construction %q: %s", cp.src, fmt.Sprintf(format, args...))
+}
+
+func (cp *constructionParser) construction() (*Construction, error) {
+	w := cp.next()
+	if w == "^(" {
+		return cp.tree()
+	}
+	if tok, ok := roots[w]; ok {
+		return &Construction{root: &tok}, nil
+	}
+	if w == "" {
+		return nil, cp.errorf("unexpected end")
+	}
+	return nil, cp.errorf("want a tree, found %q", w)
+}
+
+func (cp *constructionParser) tree() (*Construction, error) {
+	w := cp.next()
+	tok, ok := roots[w]
+	if !ok {
+		return nil, cp.errorf("unknown root %q", w)
+	}
+	c := &Construction{root: &tok}
+	for {
+		w := cp.next()
+		switch w {
+		case "":
+			return nil, cp.errorf("missing )")
+		case ")":
+			return c, nil
+		}
+
+		var child *Construction
+		var err error
+		if _, isRoot := roots[w]; isRoot || w == "^(" {
+			cp.pos--
+			child, err = cp.construction()
+		} else {
+			child, err = cp.label(w)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.children = append(c.children, child)
+	}
+}
+
+// label reads a label with its optional suffix
+func (cp *constructionParser) label(w string) (*Construction, error) {
+	c := &Construction{min: 1, max: 1}
+	switch w[len(w)-1] {
+	case '?':
+		c.min, c.max = 0, 1
+	case '*':
+		c.min, c.max = 0, -1
+	case '+':
+		c.min, c.max = 1, -1
+	}
+	if c.min != 1 || c.max != 1 {
+		w = w[:len(w)-1]
+	}
+	if !isLabel(w) {
+		return nil, cp.errorf("invalid label %q", w)
+	}
+	c.label = w
+	return c, nil
+}
+
+func isLabel(w string) bool {
+	if w == "" || w[0] < 'a' || w[0] > 'z' {
+		return false
+	}
+	for _, r := range w {
+		if !isLetter(r) && !isDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Build makes the tree out of the subtrees in labels. It returns an error if
+// a label doesn't have as many subtrees as the construction takes.
+func (c *Construction) Build(labels map[string][]*AST) (*AST, error) {
+	trees, err := c.build(labels)
+	if err != nil {
+		return nil, err
+	}
+	if len(trees) != 1 {
+		return nil, fmt.Errorf("build: want a single tree, got %d", len(trees))
+	}
+	return trees[0], nil
+}
+
+// MustBuild is like Build but panics on errors.
+func (c *Construction) MustBuild(labels map[string][]*AST) *AST {
+	t, err := c.Build(labels)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// build returns the trees c stands for, a label can stand for any number of
+// them.
+func (c *Construction) build(labels map[string][]*AST) ([]*AST, error) {
+	if c.root == nil {
+		trees := labels[c.label]
+		if len(trees) < c.min || c.max >= 0 && len(trees) > c.max {
+			return nil, fmt.Errorf("build: %s: want %s, got %d", c.label, c.count(), len(trees))
+		}
+		return trees, nil
+	}
+
+	t := &AST{Token: *c.root}
+	for _, child := range c.children {
+		trees, err := child.build(labels)
+		if err != nil {
+			return nil, err
+		}
+		t.Children = append(t.Children, trees...)
+	}
+	if len(t.Children) > 0 {
+		t.Token.Pos = t.Children[0].Token.Pos
+	}
+	return []*AST{t}, nil
+}
+
+// count describes how many subtrees a label takes
+func (c *Construction) count() string {
+	switch {
+	case c.max < 0:
+		return fmt.Sprintf("at least %d trees", c.min)
+	case c.min == 0:
+		return "at most 1 tree"
+	default:
+		return "1 tree"
+	}
+}
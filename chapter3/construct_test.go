@@ -0,0 +1,99 @@
+package backtracking
+
+import "testing"
+
+func leaf(typ TokenType, text string) *AST {
+	return &AST{Token: Token{Type: typ, Text: text}}
+}
+
+func TestConstruction(t *testing.T) {
+	a, b, c := leaf(Name, "a"), leaf(Name, "b"), leaf(Name, "c")
+	cases := []struct {
+		src    string
+		labels map[string][]*AST
+		want   string
+	}{
+		{src: "LIST", want: "(LIST)"},
+		{src: "^(LIST)", want: "(LIST)"},
+		{src: "^(LIST elements*)", labels: map[string][]*AST{"elements": {a, b, c}}, want: "(LIST a b c)"},
+		{src: "^(LIST elements*)", want: "(LIST)"},
+		{src: "^(ASSIGN lhs rhs)", labels: map[string][]*AST{"lhs": {a}, "rhs": {b}}, want: "(= a b)"},
+		{src: "^(= lhs rhs)", labels: map[string][]*AST{"lhs": {a}, "rhs": {b}}, want: "(= a b)"},
+		{
+			src:    "^(ASSIGN ^(LIST left+) ^(LIST right+))",
+			labels: map[string][]*AST{"left": {a, b}, "right": {c}},
+			want:   "(= (LIST a b) (LIST c))",
+		},
+		{
+			src:    "^(LIST ^(MAP ^(PAIR key value)) extra?)",
+			labels: map[string][]*AST{"key": {a}, "value": {b}},
+			want:   "(LIST (MAP (PAIR a b)))",
+		},
+		{src: "^(PROGRAM ^(LIST x) LIST)", labels: map[string][]*AST{"x": {a}}, want: "(PROGRAM (LIST a) (LIST))"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.src, func(t *testing.T) {
+			got, err := MustParseConstruction(tc.src).Build(tc.labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// Constructions build the same trees as ToAST.
+func TestConstructionLikeToAST(t *testing.T) {
+	prog, err := NewBacktrackingParser(NewLexer("[a,b=c]=[{d: e}]")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ToAST(prog)
+
+	stat := prog.Stats[0].(*AssignNode)
+	left, right := stat.Left.(*ListNode), stat.Right.(*ListNode)
+	el := left.Elements[1].(*AssignNode)
+	pair := right.Elements[0].(*MapNode).Pairs[0]
+
+	got := MustParseConstruction("^(PROGRAM ^(ASSIGN ^(LIST a ^(= b c)) ^(LIST ^(MAP ^(PAIR d e)))))").MustBuild(map[string][]*AST{
+		"a": {ToAST(left.Elements[0])},
+		"b": {ToAST(el.Left)},
+		"c": {ToAST(el.Right)},
+		"d": {ToAST(pair.Key)},
+		"e": {ToAST(pair.Value)},
+	})
+	if got.String() != want.String() {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+	// the root is where its first leaf is
+	if got.Token.Pos != (Pos{Line: 1, Col: 2}) {
+		t.Errorf("want: root at 1:2, got: %v", got.Token.Pos)
+	}
+}
+
+func TestConstructionErrors(t *testing.T) {
+	for _, src := range []string{"", "a", "^(", "^(LIST", "^(list a)", "^(LIST A)", "^(LIST a) b", "^(LIST 1a)", "^(LIST *)", "^(LIST (a))"} {
+		if _, err := ParseConstruction(src); err == nil {
+			t.Errorf("%q: want: error, got: nil", src)
+		}
+	}
+
+	a := leaf(Name, "a")
+	cases := []struct {
+		src    string
+		labels map[string][]*AST
+	}{
+		{src: "^(LIST x)", labels: nil},
+		{src: "^(LIST x)", labels: map[string][]*AST{"x": {a, a}}},
+		{src: "^(LIST x?)", labels: map[string][]*AST{"x": {a, a}}},
+		{src: "^(LIST x+)", labels: map[string][]*AST{"x": {}}},
+	}
+	for _, tc := range cases {
+		if _, err := MustParseConstruction(tc.src).Build(tc.labels); err == nil {
+			t.Errorf("%q with %v: want: error, got: nil", tc.src, tc.labels)
+		}
+	}
+}
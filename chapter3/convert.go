@@ -0,0 +1,260 @@
+package backtracking
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Converting to JSON and YAML
+//
+// Used as a configuration language, a program is data the rest of the world
+// reads as JSON or YAML. Each statement converts to a value:
+//
+//	[a,[b,c],d=e]          ["a",["b","c"],{"d":"e"}]
+//	[{k: 1.5, s: "x"}]     [{"k":1.5,"s":"x"}]
+//	[a,b]=[c,[true]]       {"a":"c","b":[true]}
+//
+// Names are strings, lists arrays and maps objects. An element assignment is
+// an object of a single key, and a parallel assignment an object of the
+// names it assigns, paired up like the assignment graph does, so `[a,b]=[c]`
+// can't be converted. The keys stay in the order of the program, which
+// encoding/json doesn't do with maps.
+//
+// A program of several statements converts to several values: JSON one per
+// line, as in JSON Lines, or one after the other if indented, and YAML one
+// document per statement, separated by ---.
+
+// object is a JSON object, its members in order.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+// value returns the value of the tree rooted at n: a string, int64, float64,
+// bool, []any or object.
+func value(n Node) (any, error) {
+	switch n := n.(type) {
+	case *NameNode:
+		return n.Token.Text, nil
+	case *IntNode:
+		return n.Value, nil
+	case *FloatNode:
+		return n.Value, nil
+	case *StringNode:
+		return n.Value, nil
+	case *BoolNode:
+		return n.Value, nil
+	case *ListNode:
+		list := []any{}
+		for _, el := range n.Elements {
+			v, err := value(el)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case *MapNode:
+		obj := object{}
+		for _, pair := range n.Pairs {
+			v, err := value(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member{pair.Key.Token.Text, v})
+		}
+		return obj, nil
+	case *AssignNode:
+		g := &AssignGraph{deps: map[string][]*NameNode{}}
+		g.pair(n.Left, n.Right)
+		if len(g.Errors) > 0 {
+			return nil, g.Errors[0]
+		}
+		obj := object{}
+		for _, a := range g.Assignments {
+			v, err := value(a.Value)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member{a.Target.Token.Text, v})
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("%v: can't convert %s", n.Pos(), n)
+}
+
+// values returns the values of the statements of a program, or the value of
+// any other node.
+func values(n Node) ([]any, error) {
+	nodes := []Node{n}
+	if prog, ok := n.(*ProgramNode); ok {
+		nodes = prog.Stats
+	}
+	var vs []any
+	for _, n := range nodes {
+		v, err := value(n)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// ToJSON converts the tree rooted at n to JSON, compact if indent is empty,
+// otherwise with each element on its own line, indented by indent.
+func ToJSON(n Node, indent string) (string, error) {
+	vs, err := values(n)
+	if err != nil {
+		return "", err
+	}
+	var s strings.Builder
+	for _, v := range vs {
+		writeJSON(&s, v, indent, "\n")
+		s.WriteString("\n")
+	}
+	return s.String(), nil
+}
+
+// writeJSON writes v, starting each of its lines with newline, which is the
+// newline and indentation of where v is.
+func writeJSON(s *strings.Builder, v any, indent, newline string) {
+	colon, inner := ": ", newline+indent
+	if indent == "" {
+		colon, newline, inner = ":", "", ""
+	}
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 0 {
+			s.WriteString("[]")
+			return
+		}
+		s.WriteString("[")
+		for i, el := range v {
+			if i > 0 {
+				s.WriteString(",")
+			}
+			s.WriteString(inner)
+			writeJSON(s, el, indent, inner)
+		}
+		s.WriteString(newline + "]")
+	case object:
+		if len(v) == 0 {
+			s.WriteString("{}")
+			return
+		}
+		s.WriteString("{")
+		for i, m := range v {
+			if i > 0 {
+				s.WriteString(",")
+			}
+			s.WriteString(inner)
+			writeJSON(s, m.key, indent, inner)
+			s.WriteString(colon)
+			writeJSON(s, m.value, indent, inner)
+		}
+		s.WriteString(newline + "}")
+	default:
+		b, _ := json.Marshal(v) // strings, numbers and booleans can't fail
+		s.Write(b)
+	}
+}
+
+// ToYAML converts the tree rooted at n to YAML, in block style.
+func ToYAML(n Node) (string, error) {
+	vs, err := values(n)
+	if err != nil {
+		return "", err
+	}
+	var s strings.Builder
+	for i, v := range vs {
+		if i > 0 {
+			s.WriteString("---\n")
+		}
+		writeYAML(&s, v, "")
+		s.WriteString("\n")
+	}
+	return s.String(), nil
+}
+
+// writeYAML writes v at the end of a line, its lines after the first
+// indented by indent. Lists and objects start on the line they're on, so
+// they go on the line of a "- " or on a line of their own.
+func writeYAML(s *strings.Builder, v any, indent string) {
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 0 {
+			s.WriteString("[]")
+			return
+		}
+		for i, el := range v {
+			if i > 0 {
+				s.WriteString("\n" + indent)
+			}
+			s.WriteString("- ")
+			writeYAML(s, el, indent+"  ")
+		}
+	case object:
+		if len(v) == 0 {
+			s.WriteString("{}")
+			return
+		}
+		for i, m := range v {
+			if i > 0 {
+				s.WriteString("\n" + indent)
+			}
+			s.WriteString(yamlString(m.key) + ":")
+			if block(m.value) {
+				s.WriteString("\n" + indent + "  ")
+			} else {
+				s.WriteString(" ")
+			}
+			writeYAML(s, m.value, indent+"  ")
+		}
+	case string:
+		s.WriteString(yamlString(v))
+	case float64:
+		s.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	default:
+		fmt.Fprint(s, v)
+	}
+}
+
+// block tells whether v is written on lines of its own, a list or object
+// that isn't empty.
+func block(v any) bool {
+	switch v := v.(type) {
+	case []any:
+		return len(v) > 0
+	case object:
+		return len(v) > 0
+	}
+	return false
+}
+
+// yamlWords are the plain scalars YAML reads as something else than a
+// string, in any case.
+var yamlWords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true,
+}
+
+// yamlString returns s as a plain scalar if it's a name YAML reads as that
+// string, and double-quoted otherwise.
+func yamlString(s string) string {
+	plain := s != "" && !yamlWords[strings.ToLower(s)]
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			plain = false
+		}
+	}
+	if plain {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,90 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestToJSON(t *testing.T) {
+	cases := []struct {
+		input  string
+		indent string
+		want   string
+	}{
+		{input: "[a,[b,c],d=e]", want: `["a",["b","c"],{"d":"e"}]` + "\n"},
+		{input: `[{k: 1.5, s: "x\"y"}, 42, true, {}]`, want: `[{"k":1.5,"s":"x\"y"},42,true,{}]` + "\n"},
+		{input: "[a,b]=[c,[true]]; [[d]]=[[e]]", want: `{"a":"c","b":[true]}` + "\n" + `{"d":"e"}` + "\n"},
+		{input: "[b=1,a=2]; []", want: `[{"b":1},{"a":2}]` + "\n[]\n"},
+		{input: "[a,[b],{c: d}]", indent: "  ", want: `[
+  "a",
+  [
+    "b"
+  ],
+  {
+    "c": "d"
+  }
+]
+`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			prog, err := NewBacktrackingParser(NewLexer(tc.input)).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ToJSON(prog, tc.indent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: "[a,[b,c],d=e]", want: "- a\n- - b\n  - c\n- d: e\n"},
+		{input: "[{k: [1, 2.5], m: {p: []}, e: {}}]", want: "- k:\n    - 1\n    - 2.5\n  m:\n    p: []\n  e: {}\n"},
+		{input: `[a,b]=["yes",'no']; []`, want: "a: \"yes\"\nb: \"no\"\n---\n[]\n"},
+		{input: `['a b', "", "x\ny", ab, '1a', true]`, want: "- \"a b\"\n- \"\"\n- \"x\\ny\"\n- ab\n- \"1a\"\n- true\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			prog, err := NewBacktrackingParser(NewLexer(tc.input)).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ToYAML(prog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	for input, want := range map[string]string{
+		"[a,b]=[c]": "1:1: 2 targets, 1 values in [a,b]=[c]",
+		"[1]=[a]":   "1:2: can't assign to 1",
+	} {
+		prog, err := NewBacktrackingParser(NewLexer(input)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ToJSON(prog, ""); err == nil || err.Error() != want {
+			t.Errorf("%s: want: %s, got: %v", input, want, err)
+		}
+		if _, err := ToYAML(prog); err == nil || err.Error() != want {
+			t.Errorf("%s: want: %s, got: %v", input, want, err)
+		}
+	}
+}
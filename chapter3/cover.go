@@ -0,0 +1,54 @@
+package backtracking
+
+import (
+	_ "embed"
+
+	"example.com/grammar"
+)
+
+// Grammar coverage
+//
+// Do the tests exercise all of the grammar? Package grammar tells from the
+// parse trees of a corpus which alternatives of the rules, and which
+// repetitions, no program took: the tests missing. The parser records the
+// parse trees, see parsetree.go, and grammar.g is the grammar above it in
+// parser.go, read by package grammar. In a parse tree the types of the
+// tokens are named as in the grammar, uppercase: Name is NAME.
+
+//go:embed grammar.g
+var grammarSrc string
+
+// Cover parses each program with a parse tree and adds the trees to the
+// coverage of the grammar. trailing accepts trailing commas, which the
+// grammar allows.
+func Cover(progs []string, trailing bool) (*grammar.Coverage, error) {
+	g, err := grammar.Parse(grammarSrc)
+	if err != nil {
+		return nil, err
+	}
+	c := grammar.NewCoverage(g)
+	for _, src := range progs {
+		p := NewBacktrackingParser(NewLexer(src))
+		p.BuildParseTree = true
+		p.AllowTrailingComma = trailing
+		if _, err := p.Parse(); err != nil {
+			return nil, err
+		}
+		if err := c.Add(coverTree(p.ParseTree)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// coverTree converts a parse tree to the one package grammar takes.
+func coverTree(t *ParseTree) *grammar.Node {
+	if !t.IsRule() {
+		return &grammar.Node{Token: grammarToken(t.Token.Type), Text: t.Token.Text}
+	}
+	n := &grammar.Node{Rule: t.Rule}
+	for _, c := range t.Children {
+		n.Children = append(n.Children, coverTree(c))
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package backtracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCover(t *testing.T) {
+	cases := []struct {
+		name     string
+		progs    []string
+		trailing bool
+		want     string
+	}{
+		{
+			name:  "list",
+			progs: []string{"[a]"},
+			want: `3:1: program: stat? matching none
+3:1: program: (sep stat?)* matching some
+3:1: program: stat? matching none in (sep stat?)*
+3:1: program: stat? matching one in (sep stat?)*
+4:1: sep: ';'
+4:1: sep: NEWLINE
+5:1: stat: assign
+6:1: assign: list '=' list
+7:1: list: elements? matching none
+8:1: elements: (',' element)* matching some
+8:1: elements: ','? matching one
+9:1: element: NAME '=' element
+9:1: element: list
+9:1: element: map
+9:1: element: literal
+12:1: map: '{' pairs? '}'
+12:1: map: pairs? matching none
+12:1: map: pairs? matching one
+13:1: pairs: pair (',' pair)* ','?
+13:1: pairs: (',' pair)* matching none
+13:1: pairs: (',' pair)* matching some
+13:1: pairs: ','? matching none
+13:1: pairs: ','? matching one
+14:1: pair: NAME ':' element
+15:1: literal: INT
+15:1: literal: FLOAT
+15:1: literal: STRING
+15:1: literal: 'true'
+15:1: literal: 'false'
+10 of 39 branches covered
+`,
+		},
+		{
+			name: "all",
+			progs: []string{
+				"", "[]; [a,b=[c],];\n[x]=[1,2.5,\"s\",true,false]",
+				"[{a: b}, {c: d, e: f,}, {}]",
+			},
+			trailing: true,
+			want:     "39 of 39 branches covered\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Cover(tc.progs, tc.trailing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s strings.Builder
+			if err := c.Report(&s); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != tc.want {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+
+	if _, err := Cover([]string{"[a,]"}, false); err == nil {
+		t.Error("want: syntax error, got: nil")
+	}
+}
@@ -0,0 +1,209 @@
+package backtracking
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Diagnostics
+//
+// Everything the parser has to say about a program is a Diagnostic: the
+// syntax errors it recovered from, or the one it stopped at, the lexer error
+// it stopped at, the warnings about what it tolerates, such as trailing
+// commas, and the infos about what's harmless but pointless, such as empty
+// statements. Each has a severity and a code, which stays the same when the
+// message is reworded, for tools to tell them apart:
+//
+//	E001 error    syntax error
+//	E002 error    invalid input, an error of the lexer
+//	W001 warning  trailing comma in a list or map, see AllowTrailingComma
+//	I001 info     empty statement, a ';' with no statement before it
+//
+// The parser's Diagnostics returns them sorted by position, WriteDiagnostics
+// prints them one per line, and WriteDiagnosticsJSON writes them as a JSON
+// array for an editor to underline them:
+//
+//	1:4: error E001: syntax error: expecting name, list, map or literal, found Comma
+//	2:3: warning W001: trailing comma in list, found Comma ","
+//
+// The JSON has the fixes the parser suggests too, see repair.go.
+
+// Error is an error found at a position of the input, as syntax errors and
+// the lexer's errors are.
+type Error struct {
+	Pos Pos
+	Err error
+
+	want  []TokenType // what was expected instead, for repair
+	fixes []Fix       // see repair.go
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("%v: %v", e.Pos, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+// syntaxError returns a syntax error at pos.
+func syntaxError(pos Pos, format string, args ...any) *Error {
+	return &Error{Pos: pos, Err: fmt.Errorf("%w: "+format, append([]any{SyntaxError}, args...)...)}
+}
+
+// Severity is how bad a diagnostic is.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText makes the severity its name in JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// The codes of the diagnostics, see above.
+const (
+	CodeSyntaxError    = "E001"
+	CodeInvalidInput   = "E002"
+	CodeTrailingComma  = "W001"
+	CodeEmptyStatement = "I001"
+)
+
+// Diagnostic is something the parser has to say about the input at Pos,
+// with the fixes it suggests if it has any.
+type Diagnostic struct {
+	Pos      Pos
+	Severity Severity
+	Code     string
+	Message  string
+	Fixes    []Fix
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v %s: %s", d.Pos, d.Severity, d.Code, d.Message)
+}
+
+// diagnose returns the diagnostic of tok, with the token in the message.
+func diagnose(tok Token, severity Severity, code, msg string) Diagnostic {
+	return Diagnostic{
+		Pos:      tok.Pos,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf("%s, found %v %q", msg, tok.Type, tok.Text),
+	}
+}
+
+// errorDiagnostic returns the diagnostic of an error of Parse.
+func errorDiagnostic(err error) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Code: CodeInvalidInput, Message: err.Error()}
+	if errors.Is(err, SyntaxError) {
+		d.Code = CodeSyntaxError
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		d.Pos, d.Message, d.Fixes = e.Pos, e.Err.Error(), e.fixes
+	}
+	return d
+}
+
+// Diagnostics returns the diagnostics of the parse, errors, warnings and
+// infos, sorted by position.
+func (p *BacktrackingParser) Diagnostics() []Diagnostic {
+	var ds []Diagnostic
+	for _, err := range p.Errors {
+		ds = append(ds, errorDiagnostic(err))
+	}
+	if p.err != nil {
+		ds = append(ds, errorDiagnostic(p.err))
+	}
+	ds = append(ds, p.Warnings...)
+	ds = append(ds, p.infos...)
+	sortDiagnostics(ds)
+	return ds
+}
+
+// sortDiagnostics sorts ds by position, the ones at the same position by
+// severity, worst first.
+func sortDiagnostics(ds []Diagnostic) {
+	slices.SortStableFunc(ds, func(a, b Diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(a.Pos.Line, b.Pos.Line),
+			cmp.Compare(a.Pos.Col, b.Pos.Col),
+			cmp.Compare(a.Severity, b.Severity),
+		)
+	})
+}
+
+// WriteDiagnostics writes the diagnostics one per line, sorted by position.
+func WriteDiagnostics(w io.Writer, ds []Diagnostic) error {
+	ds = slices.Clone(ds)
+	sortDiagnostics(ds)
+	for _, d := range ds {
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonDiagnostic is how a diagnostic looks in JSON.
+type jsonDiagnostic struct {
+	Line     int       `json:"line"`
+	Col      int       `json:"col"`
+	Severity Severity  `json:"severity"`
+	Code     string    `json:"code"`
+	Message  string    `json:"message"`
+	Fixes    []jsonFix `json:"fixes,omitempty"`
+}
+
+type jsonFix struct {
+	Title string  `json:"title"`
+	From  jsonPos `json:"from"`
+	To    jsonPos `json:"to"`
+	Text  string  `json:"text"`
+}
+
+type jsonPos struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// WriteDiagnosticsJSON writes the diagnostics as a JSON array sorted by
+// position, empty if there are none, with the fixes of the ones that have
+// some:
+//
+//	[{"line":1,"col":4,"severity":"error","code":"E001","message":"syntax error: ...",
+//	  "fixes":[{"title":"remove \",\"","from":{"line":1,"col":4},"to":{"line":1,"col":5},"text":""}]}]
+func WriteDiagnosticsJSON(w io.Writer, ds []Diagnostic) error {
+	ds = slices.Clone(ds)
+	sortDiagnostics(ds)
+	js := make([]jsonDiagnostic, len(ds))
+	for i, d := range ds {
+		js[i] = jsonDiagnostic{Line: d.Pos.Line, Col: d.Pos.Col, Severity: d.Severity, Code: d.Code, Message: d.Message}
+		for _, f := range d.Fixes {
+			js[i].Fixes = append(js[i].Fixes, jsonFix{
+				Title: f.Title,
+				From:  jsonPos{f.From.Line, f.From.Col},
+				To:    jsonPos{f.To.Line, f.To.Col},
+				Text:  f.Text,
+			})
+		}
+	}
+	return json.NewEncoder(w).Encode(js)
+}
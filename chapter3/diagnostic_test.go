@@ -0,0 +1,124 @@
+package backtracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiagnostics(t *testing.T) {
+	cases := []struct {
+		name     string
+		src      string
+		recover  bool
+		trailing bool
+		want     []string
+	}{
+		{
+			name: "none",
+			src:  "[a]; [b]\n\n[c]",
+		},
+		{
+			name: "stopping at the first error",
+			src:  "[a,,b]; [c d]",
+			want: []string{"1:1: error E001: syntax error: expecting list or assign, found LBrack"},
+		},
+		{
+			name:    "recovering",
+			src:     "[a,,b]; [c d]",
+			recover: true,
+			want: []string{
+				"1:4: error E001: syntax error: expecting name, list, map or literal, found Comma",
+				"1:12: error E001: match: syntax error: expecting RBrack, got Name",
+			},
+		},
+		{
+			name:    "recovering up to a lexer error",
+			src:     "[a,,b]; [c%]",
+			recover: true,
+			want: []string{
+				"1:4: error E001: syntax error: expecting name, list, map or literal, found Comma",
+				"1:11: error E002: non-letter character: %",
+			},
+		},
+		{
+			name:     "warnings",
+			src:      "[a,]\n[{k: v,}]",
+			trailing: true,
+			want: []string{
+				`1:3: warning W001: trailing comma in list, found Comma ","`,
+				`2:7: warning W001: trailing comma in map, found Comma ","`,
+			},
+		},
+		{
+			name: "infos",
+			src:  ";[a];;\n;",
+			want: []string{
+				`1:1: info I001: empty statement, found Semicolon ";"`,
+				`1:6: info I001: empty statement, found Semicolon ";"`,
+				`2:1: info I001: empty statement, found Semicolon ";"`,
+			},
+		},
+		{
+			name:     "sorted",
+			src:      "[a,]; ; [b c,]",
+			recover:  true,
+			trailing: true,
+			want: []string{
+				`1:3: warning W001: trailing comma in list, found Comma ","`,
+				`1:7: info I001: empty statement, found Semicolon ";"`,
+				"1:12: error E001: match: syntax error: expecting RBrack, got Name",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewBacktrackingParser(NewLexer(tc.src))
+			p.RecoverErrors = tc.recover
+			p.AllowTrailingComma = tc.trailing
+			p.Parse()
+			var got []string
+			for _, d := range p.Diagnostics() {
+				got = append(got, d.String())
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	ds := []Diagnostic{
+		{Pos: Pos{2, 1}, Severity: SeverityInfo, Code: CodeEmptyStatement, Message: "c"},
+		{Pos: Pos{1, 5}, Severity: SeverityWarning, Code: CodeTrailingComma, Message: "b"},
+		{Pos: Pos{1, 5}, Severity: SeverityError, Code: CodeSyntaxError, Message: "a"},
+	}
+
+	var s strings.Builder
+	if err := WriteDiagnostics(&s, ds); err != nil {
+		t.Fatal(err)
+	}
+	want := "1:5: error E001: a\n1:5: warning W001: b\n2:1: info I001: c\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	if err := WriteDiagnosticsJSON(&s, ds); err != nil {
+		t.Fatal(err)
+	}
+	want = `[{"line":1,"col":5,"severity":"error","code":"E001","message":"a"},` +
+		`{"line":1,"col":5,"severity":"warning","code":"W001","message":"b"},` +
+		`{"line":2,"col":1,"severity":"info","code":"I001","message":"c"}]` + "\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	// the diagnostics given aren't sorted in place
+	if ds[0].Code != CodeEmptyStatement {
+		t.Errorf("diagnostics sorted in place: %v", ds)
+	}
+}
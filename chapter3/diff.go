@@ -0,0 +1,230 @@
+package backtracking
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Structural diff
+//
+// Diff compares two trees and reports how to get from the first to the
+// second, in terms of subtrees rather than lines of text: `[a,b,c]` and
+// `[c,a,b]` differ in that c moved, not in every position of the list.
+//
+// Both trees are walked together from the root. Nodes of a fixed shape
+// (assignments and pairs) are compared side by side. For nodes with a list of
+// children (programs, lists and maps) the longest common subsequence of equal
+// children is kept as is, and what's left between those is compared in order:
+// each old child is paired with the next new one of the same kind, two leaves
+// are a change and two other nodes are compared in turn, unpaired children
+// were deleted or inserted. Finally a subtree that was both deleted and
+// inserted is reported as moved. Positions don't matter when comparing, so the
+// same subtree on another line is still equal.
+
+// ChangeKind tells what happened to a subtree.
+type ChangeKind int
+
+const (
+	Inserted ChangeKind = iota
+	Deleted
+	Moved
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Inserted:
+		return "inserted"
+	case Deleted:
+		return "deleted"
+	case Moved:
+		return "moved"
+	case Changed:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a difference between two trees. Old is nil for insertions and New
+// is nil for deletions.
+type Change struct {
+	Kind ChangeKind
+	Old  Node
+	New  Node
+}
+
+// String describes the change at the position where it's found, in the old
+// tree unless the subtree was inserted.
+func (c Change) String() string {
+	switch c.Kind {
+	case Inserted:
+		return fmt.Sprintf("%v: inserted %s", c.New.Pos(), c.New)
+	case Deleted:
+		return fmt.Sprintf("%v: deleted %s", c.Old.Pos(), c.Old)
+	case Moved:
+		return fmt.Sprintf("%v: moved %s to %v", c.Old.Pos(), c.Old, c.New.Pos())
+	default:
+		return fmt.Sprintf("%v: changed %s to %s", c.Old.Pos(), c.Old, c.New)
+	}
+}
+
+// Diff returns the changes that turn the tree old into the tree new, in the
+// order they're found. No changes means the trees are equal.
+func Diff(old, new Node) []Change {
+	d := &differ{keys: map[Node]string{}}
+	d.key(old)
+	d.key(new)
+	d.diff(old, new)
+	return d.moves()
+}
+
+type differ struct {
+	keys    map[Node]string // S-expression of each subtree, to compare them
+	changes []Change
+}
+
+// key returns the S-expression for n, computing the ones of all its subtrees
+// on the way.
+func (d *differ) key(n Node) string {
+	if k, ok := d.keys[n]; ok {
+		return k
+	}
+	k := n.String()
+	if h := head(n); h != "" {
+		var s strings.Builder
+		s.WriteString("(" + h)
+		for _, c := range children(n) {
+			s.WriteString(" " + d.key(c))
+		}
+		s.WriteString(")")
+		k = s.String()
+	}
+	d.keys[n] = k
+	return k
+}
+
+func (d *differ) add(kind ChangeKind, old, new Node) {
+	d.changes = append(d.changes, Change{Kind: kind, Old: old, New: new})
+}
+
+func (d *differ) diff(old, new Node) {
+	if d.key(old) == d.key(new) {
+		return
+	}
+	h := head(old)
+	if h == "" || h != head(new) {
+		d.add(Changed, old, new)
+		return
+	}
+
+	oldKids, newKids := children(old), children(new)
+	if h == "assign" || h == "pair" {
+		d.diff(oldKids[0], newKids[0])
+		d.diff(oldKids[1], newKids[1])
+		return
+	}
+	d.diffLists(oldKids, newKids)
+}
+
+// diffLists compares two lists of children, keeping their longest common
+// subsequence and comparing the gaps between its elements.
+func (d *differ) diffLists(old, new []Node) {
+	i, j := 0, 0
+	for _, m := range d.lcs(old, new) {
+		d.diffGap(old[i:m[0]], new[j:m[1]])
+		i, j = m[0]+1, m[1]+1
+	}
+	d.diffGap(old[i:], new[j:])
+}
+
+// diffGap compares children that aren't in the common subsequence. Each old
+// child is compared with the next new child of the same kind, the new
+// children skipped over were inserted, and old ones without a match were
+// deleted.
+func (d *differ) diffGap(old, new []Node) {
+	j := 0
+	for _, o := range old {
+		k := j
+		for k < len(new) && head(new[k]) != head(o) {
+			k++
+		}
+		if k == len(new) {
+			d.add(Deleted, o, nil)
+			continue
+		}
+		for _, nw := range new[j:k] {
+			d.add(Inserted, nil, nw)
+		}
+		d.diff(o, new[k])
+		j = k + 1
+	}
+	for _, nw := range new[j:] {
+		d.add(Inserted, nil, nw)
+	}
+}
+
+// lcs returns the index pairs of the longest common subsequence of equal
+// subtrees, with the usual dynamic programming table.
+func (d *differ) lcs(old, new []Node) [][2]int {
+	table := make([][]int, len(old)+1)
+	for i := range table {
+		table[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if d.key(old[i]) == d.key(new[j]) {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(old) && j < len(new); {
+		switch {
+		case d.key(old[i]) == d.key(new[j]):
+			pairs = append(pairs, [2]int{i, j})
+			i, j = i+1, j+1
+		case table[i+1][j] >= table[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// moves turns each deletion with a matching insertion into a move, reported
+// where the deletion was.
+func (d *differ) moves() []Change {
+	inserted := map[string][]int{} // key -> indexes of insertions
+	for i, c := range d.changes {
+		if c.Kind == Inserted {
+			k := d.key(c.New)
+			inserted[k] = append(inserted[k], i)
+		}
+	}
+
+	moved := map[int]bool{} // insertions that are part of a move
+	for i, c := range d.changes {
+		if c.Kind != Deleted {
+			continue
+		}
+		k := d.key(c.Old)
+		if ins := inserted[k]; len(ins) > 0 {
+			d.changes[i] = Change{Kind: Moved, Old: c.Old, New: d.changes[ins[0]].New}
+			moved[ins[0]] = true
+			inserted[k] = ins[1:]
+		}
+	}
+
+	var changes []Change
+	for i, c := range d.changes {
+		if !moved[i] {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
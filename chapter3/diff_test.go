@@ -0,0 +1,56 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name string
+		old  string
+		new  string
+		want []string
+	}{
+		{name: "equal", old: "[a,[b]]", new: "[a,[b]]", want: nil},
+		{name: "equal on other lines", old: "[a,b]", new: "\n\n[a,\n b]", want: nil},
+		{name: "inserted", old: "[a,c]", new: "[a,b,c]", want: []string{"1:4: inserted b"}},
+		{name: "deleted", old: "[a,b,c]", new: "[a,c]", want: []string{"1:4: deleted b"}},
+		{name: "changed", old: "[a,b,c]", new: "[a,x,c]", want: []string{"1:4: changed b to x"}},
+		{name: "moved", old: "[a,b,c]", new: "[c,a,b]", want: []string{"1:6: moved c to 1:2"}},
+		{name: "moved into sublist", old: "[a,[b],c]", new: "[[b,a],c]", want: []string{"1:2: moved a to 1:5"}},
+		{
+			name: "nested",
+			old:  "[a=[b,c]]=[{k: 1}]",
+			new:  "[a=[b,d]]=[{k: 2, j: 3}]",
+			want: []string{"1:7: changed c to d", "1:16: changed 1 to 2", "1:19: inserted j: 3"},
+		},
+		{
+			name: "different kinds",
+			old:  "[a,[b]]",
+			new:  "[a,{b: c}]",
+			want: []string{"1:4: deleted [b]", "1:4: inserted {b: c}"},
+		},
+		{
+			name: "statements",
+			old:  "[a]\n[b]=[c]",
+			new:  "[b]=[c]\n[d]",
+			want: []string{"1:1: deleted [a]", "2:1: inserted [d]"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			old := NewBacktrackingParser(NewLexer(tc.old)).program()
+			new := NewBacktrackingParser(NewLexer(tc.new)).program()
+			var got []string
+			for _, c := range Diff(old, new) {
+				got = append(got, c.String())
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
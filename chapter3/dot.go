@@ -0,0 +1,114 @@
+package backtracking
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Trees are easier to understand drawn than printed. WriteDOT writes any of
+// the trees the parser produces in the DOT language of Graphviz, which can
+// then be rendered with e.g. `dot -Tsvg tree.dot > tree.svg`. Nodes are
+// numbered in preorder and edges keep the order of the children.
+
+// WriteDOT writes the DOT graph for tree, which can be a heterogeneous AST
+// (any Node), a homogeneous *AST or a *ParseTree.
+func WriteDOT(w io.Writer, tree any) error {
+	if _, _, ok := dotNode(tree); !ok {
+		return fmt.Errorf("dot: unsupported tree type %T", tree)
+	}
+	var g dotGraph
+	g.add(tree)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph tree {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=\"monospace\"];")
+	for i, label := range g.labels {
+		fmt.Fprintf(bw, "\tn%d [label=\"%s\"];\n", i, dotEscape(label))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "\tn%d -> n%d;\n", e[0], e[1])
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotGraph collects the nodes and edges of a tree
+type dotGraph struct {
+	labels []string
+	edges  [][2]int
+}
+
+// add adds a node and its subtree, returning the node's number.
+func (g *dotGraph) add(tree any) int {
+	label, kids, _ := dotNode(tree)
+	id := len(g.labels)
+	g.labels = append(g.labels, label)
+	for _, c := range kids {
+		g.edges = append(g.edges, [2]int{id, len(g.labels)})
+		g.add(c)
+	}
+	return id
+}
+
+// dotNode returns the label and children of a node of any of the trees, the
+// three kinds only differ in that.
+func dotNode(tree any) (label string, kids []any, ok bool) {
+	switch t := tree.(type) {
+	case *AST:
+		for _, c := range t.Children {
+			kids = append(kids, c)
+		}
+		return t.text(), kids, true
+	case *ParseTree:
+		for _, c := range t.Children {
+			kids = append(kids, c)
+		}
+		if t.IsRule() {
+			return t.Rule, kids, true
+		}
+		return t.text(), kids, true
+	case Node:
+		for _, c := range children(t) {
+			kids = append(kids, c)
+		}
+		return nodeLabel(t), kids, true
+	default:
+		return "", nil, false
+	}
+}
+
+// nodeLabel is the node type, followed by the text for leaves.
+func nodeLabel(n Node) string {
+	switch n := n.(type) {
+	case *ProgramNode:
+		return "ProgramNode"
+	case *ListNode:
+		return "ListNode"
+	case *AssignNode:
+		return "AssignNode"
+	case *MapNode:
+		return "MapNode"
+	case *PairNode:
+		return "PairNode"
+	case *NameNode:
+		return "NameNode\n" + n.String()
+	case *IntNode:
+		return "IntNode\n" + n.String()
+	case *FloatNode:
+		return "FloatNode\n" + n.String()
+	case *StringNode:
+		return "StringNode\n" + n.String()
+	case *BoolNode:
+		return "BoolNode\n" + n.String()
+	case *ErrorNode:
+		return "ErrorNode\n" + n.String()
+	default:
+		return fmt.Sprintf("%T", n)
+	}
+}
+
+// dotEscape escapes a label for a double quoted DOT string, a newline becomes
+// a line break in the box
+var dotEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
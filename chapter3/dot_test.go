@@ -0,0 +1,127 @@
+package backtracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteDOT(t *testing.T) {
+	parser := NewBacktrackingParser(NewLexer(`[a="b"]`))
+	parser.BuildParseTree = true
+	prog, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		tree any
+		want string
+	}{
+		{
+			name: "heterogeneous",
+			tree: prog,
+			want: `digraph tree {
+	node [shape=box, fontname="monospace"];
+	n0 [label="ProgramNode"];
+	n1 [label="ListNode"];
+	n2 [label="AssignNode"];
+	n3 [label="NameNode\na"];
+	n4 [label="StringNode\n\"b\""];
+	n0 -> n1;
+	n1 -> n2;
+	n2 -> n3;
+	n2 -> n4;
+}
+`,
+		},
+		{
+			name: "homogeneous",
+			tree: ToAST(prog),
+			want: `digraph tree {
+	node [shape=box, fontname="monospace"];
+	n0 [label="PROGRAM"];
+	n1 [label="LIST"];
+	n2 [label="="];
+	n3 [label="a"];
+	n4 [label="\"b\""];
+	n0 -> n1;
+	n1 -> n2;
+	n2 -> n3;
+	n2 -> n4;
+}
+`,
+		},
+		{
+			name: "parse tree",
+			tree: parser.ParseTree,
+			want: `digraph tree {
+	node [shape=box, fontname="monospace"];
+	n0 [label="program"];
+	n1 [label="stat"];
+	n2 [label="list"];
+	n3 [label="["];
+	n4 [label="elements"];
+	n5 [label="element"];
+	n6 [label="a"];
+	n7 [label="="];
+	n8 [label="element"];
+	n9 [label="literal"];
+	n10 [label="\"b\""];
+	n11 [label="]"];
+	n12 [label="<EOF>"];
+	n0 -> n1;
+	n1 -> n2;
+	n2 -> n3;
+	n2 -> n4;
+	n4 -> n5;
+	n5 -> n6;
+	n5 -> n7;
+	n5 -> n8;
+	n8 -> n9;
+	n9 -> n10;
+	n2 -> n11;
+	n0 -> n12;
+}
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var s strings.Builder
+			if err := WriteDOT(&s, tc.tree); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != tc.want {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+
+	if err := WriteDOT(&strings.Builder{}, 42); err == nil {
+		t.Error("want: error, got: nil")
+	}
+}
+
+func TestTreeStrings(t *testing.T) {
+	parser := NewBacktrackingParser(NewLexer("[a,b=[c]]=[{d: e}];[]"))
+	parser.BuildParseTree = true
+	prog, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "(PROGRAM (= (LIST a (= b (LIST c))) (LIST (MAP (PAIR d e)))) (LIST))"
+	if got := ToAST(prog).String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+
+	want = "(program (stat (assign (list [ (elements (element a) , (element b = (element (list [ (elements (element c)) ])))) ]) =" +
+		" (list [ (elements (element (map { (pairs (pair d : (element e))) }))) ]))) (sep ;) (stat (list [ ])) <EOF>)"
+	if got := parser.ParseTree.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
@@ -0,0 +1,146 @@
+package backtracking
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Formatter
+//
+// String renders a tree back to source in the most compact way, which is fine
+// for short lists but not for a configuration file with long ones. Formatter
+// renders trees as canonical source: the same tree always gives the same
+// text, whatever the spacing of the input was, and lists and maps that don't
+// fit in MaxWidth are split with one element per line:
+//
+//	[
+//	  a,
+//	  b=[c, d],
+//	  {
+//	    key: value,
+//	    other: [e]
+//	  }
+//	]
+//
+// Formatting a program and parsing the result again gives back the same tree.
+
+// Formatter holds the formatting options. The zero value renders everything
+// just like String does.
+type Formatter struct {
+	Spaces   bool   // put a space after commas in lists and around '='
+	MaxWidth int    // split lists and maps wider than this, 0 never splits
+	Indent   string // indentation of split elements, two spaces if empty
+}
+
+// Format renders the tree rooted at n.
+func (f *Formatter) Format(n Node) string {
+	return f.node(n, "", 0)
+}
+
+// node renders n with col runes already on its first line, splitting it if it
+// doesn't fit. indent is the indentation of the line n starts on.
+func (f *Formatter) node(n Node, indent string, col int) string {
+	if prog, ok := n.(*ProgramNode); ok {
+		stats := make([]string, len(prog.Stats))
+		for i, stat := range prog.Stats {
+			stats[i] = f.node(stat, "", 0)
+		}
+		return strings.Join(stats, "\n")
+	}
+
+	flat := f.flat(n)
+	if f.MaxWidth <= 0 || col+utf8.RuneCountInString(flat) <= f.MaxWidth {
+		return flat
+	}
+
+	switch n := n.(type) {
+	case *ListNode:
+		return f.split("[", "]", n.Elements, indent)
+	case *MapNode:
+		pairs := make([]Node, len(n.Pairs))
+		for i, pair := range n.Pairs {
+			pairs[i] = pair
+		}
+		return f.split("{", "}", pairs, indent)
+	case *AssignNode:
+		left := f.node(n.Left, indent, col) + f.equals()
+		return left + f.node(n.Right, indent, lastLineWidth(left, col))
+	case *PairNode:
+		key := n.Key.String() + ": "
+		return key + f.node(n.Value, indent, col+utf8.RuneCountInString(key))
+	default:
+		return flat
+	}
+}
+
+// split renders elements one per line between open and close, indented one
+// level deeper than indent. Empty lists and maps are never split.
+func (f *Formatter) split(open, close string, elems []Node, indent string) string {
+	if len(elems) == 0 {
+		return open + close
+	}
+	inner := indent + f.indent()
+	var s strings.Builder
+	s.WriteString(open + "\n")
+	for i, el := range elems {
+		s.WriteString(inner)
+		s.WriteString(f.node(el, inner, utf8.RuneCountInString(inner)))
+		if i < len(elems)-1 {
+			s.WriteString(",")
+		}
+		s.WriteString("\n")
+	}
+	s.WriteString(indent + close)
+	return s.String()
+}
+
+// flat renders n on a single line.
+func (f *Formatter) flat(n Node) string {
+	switch n := n.(type) {
+	case *ListNode:
+		comma := ","
+		if f.Spaces {
+			comma = ", "
+		}
+		elems := make([]string, len(n.Elements))
+		for i, el := range n.Elements {
+			elems[i] = f.flat(el)
+		}
+		return "[" + strings.Join(elems, comma) + "]"
+	case *MapNode:
+		pairs := make([]string, len(n.Pairs))
+		for i, pair := range n.Pairs {
+			pairs[i] = f.flat(pair)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *AssignNode:
+		return f.flat(n.Left) + f.equals() + f.flat(n.Right)
+	case *PairNode:
+		return n.Key.String() + ": " + f.flat(n.Value)
+	default:
+		return n.String()
+	}
+}
+
+func (f *Formatter) equals() string {
+	if f.Spaces {
+		return " = "
+	}
+	return "="
+}
+
+func (f *Formatter) indent() string {
+	if f.Indent == "" {
+		return "  "
+	}
+	return f.Indent
+}
+
+// lastLineWidth returns how many runes the last line of s takes, col being
+// where s started.
+func lastLineWidth(s string, col int) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return utf8.RuneCountInString(s[i+1:])
+	}
+	return col + utf8.RuneCountInString(s)
+}
@@ -0,0 +1,100 @@
+package backtracking
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		name string
+		f    Formatter
+		in   string
+		want string
+	}{
+		{
+			name: "compact",
+			in:   "[ a , b = c ,{d:e}] = [ f ]; [g]",
+			want: "[a,b=c,{d: e}]=[f]\n[g]",
+		},
+		{
+			name: "spaces",
+			f:    Formatter{Spaces: true},
+			in:   "[a,b=c,{d:e}]=[f]",
+			want: "[a, b = c, {d: e}] = [f]",
+		},
+		{
+			name: "fits",
+			f:    Formatter{MaxWidth: 7},
+			in:   "[a,b,c]",
+			want: "[a,b,c]",
+		},
+		{
+			name: "split",
+			f:    Formatter{MaxWidth: 6},
+			in:   "[a,b,c]",
+			want: "[\n  a,\n  b,\n  c\n]",
+		},
+		{
+			name: "split nested",
+			f:    Formatter{Spaces: true, MaxWidth: 12},
+			in:   "[a,b=[c,d],{key: [e,f,g]},[]]",
+			want: "[\n  a,\n  b = [c, d],\n  {\n    key: [\n      e,\n      f,\n      g\n    ]\n  },\n  []\n]",
+		},
+		{
+			name: "split parallel assignment",
+			f:    Formatter{MaxWidth: 8, Indent: "\t"},
+			in:   "[a,b]=[c,d]",
+			want: "[a,b]=[\n\tc,\n\td\n]",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := NewBacktrackingParser(NewLexer(tc.in)).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tc.f.Format(prog); got != tc.want {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+// Every program in testdata/good.txt must parse to the same tree after being
+// formatted, whether its lists are split or not.
+func TestFormatRoundTrip(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/good.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formatters := []Formatter{{}, {Spaces: true}, {MaxWidth: 1}, {Spaces: true, MaxWidth: 10}}
+	for _, file := range ar.Files {
+		for _, line := range bytes.Split(file.Data, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			t.Run(file.Name, func(t *testing.T) {
+				prog, err := NewBacktrackingParser(NewLexer(string(line))).Parse()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, f := range formatters {
+					src := f.Format(prog)
+					got, err := NewBacktrackingParser(NewLexer(src)).Parse()
+					if err != nil {
+						t.Fatalf("%q: %v", src, err)
+					}
+					if !cmp.Equal(got, prog, ignorePos) {
+						t.Errorf("%q: %s", src, cmp.Diff(got, prog, ignorePos))
+					}
+				}
+			})
+		}
+	}
+}
@@ -3,6 +3,13 @@ module example.com/backtracking
 go 1.23.4
 
 require (
+	example.com/grammar v0.0.0
+	example.com/parsing v0.0.0
 	github.com/google/go-cmp v0.6.0
 	golang.org/x/tools v0.28.0
 )
+
+replace (
+	example.com/grammar => ../grammar
+	example.com/parsing => ../parsing
+)
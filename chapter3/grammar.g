@@ -0,0 +1,16 @@
+// The grammar of parser.go, for grammar coverage: see cover.go.
+grammar NestedNameListWithParallelAssign;
+program  : stat? (sep stat?)* EOF ;
+sep      : ';' | NEWLINE ;
+stat     : list | assign ;
+assign   : list '=' list ;
+list     : '[' elements? ']' ;
+elements : element (',' element)* ','? ;
+element  : NAME '=' element
+         | NAME | list | map | literal
+         ;
+map      : '{' pairs? '}' ;
+pairs    : pair (',' pair)* ','? ;
+pair     : NAME ':' element ;
+literal  : INT | FLOAT | STRING | 'true' | 'false' ;
+NAME     : ('a'..'z'|'A'..'Z')+ ;
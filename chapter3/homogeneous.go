@@ -0,0 +1,198 @@
+package backtracking
+
+import "strings"
+
+// Pattern 9:
+// Homogeneous AST
+
+// A homogeneous AST has a single node type for the whole tree: each node is a
+// token and a list of children. It's the simplest tree to build and walk, at
+// the cost of knowing what a node is only by its token type. Constructs
+// without a token of their own get an imaginary one (ListRoot, MapRoot...),
+// an assignment is rooted at its '=' token.
+//
+// For the statement `[a,b=[c]]=[{d: e}]` the tree is:
+//
+//	(= (LIST a (= b (LIST c))) (LIST (MAP (PAIR d e))))
+
+// AST is a node of the homogeneous tree.
+type AST struct {
+	Token    Token
+	Children []*AST
+}
+
+// imaginary tokens used as roots, their text is what String shows
+var (
+	programToken = Token{Type: ProgramRoot, Text: "PROGRAM"}
+	listToken    = Token{Type: ListRoot, Text: "LIST"}
+	mapToken     = Token{Type: MapRoot, Text: "MAP"}
+	pairToken    = Token{Type: PairRoot, Text: "PAIR"}
+	errorToken   = Token{Type: ErrorRoot, Text: "ERROR"}
+	equalsToken  = Token{Type: Equals, Text: "="}
+)
+
+// ToAST converts a heterogeneous tree into a homogeneous one. Imaginary
+// tokens are at the position of the node they stand for.
+func ToAST(n Node) *AST {
+	switch n := n.(type) {
+	case *ProgramNode:
+		return newAST(at(programToken, n), n.Stats...)
+	case *ListNode:
+		return newAST(at(listToken, n), n.Elements...)
+	case *AssignNode:
+		return newAST(at(equalsToken, n), n.Left, n.Right)
+	case *MapNode:
+		t := &AST{Token: at(mapToken, n)}
+		for _, pair := range n.Pairs {
+			t.Children = append(t.Children, ToAST(pair))
+		}
+		return t
+	case *PairNode:
+		return newAST(at(pairToken, n), n.Key, n.Value)
+	case *NameNode:
+		return &AST{Token: n.Token}
+	case *IntNode:
+		return &AST{Token: n.Token}
+	case *FloatNode:
+		return &AST{Token: n.Token}
+	case *StringNode:
+		return &AST{Token: n.Token}
+	case *BoolNode:
+		return &AST{Token: n.Token}
+	case *ErrorNode:
+		// the bad tokens, without making sense of them
+		t := &AST{Token: at(errorToken, n)}
+		for _, tok := range n.Tokens {
+			t.Children = append(t.Children, &AST{Token: tok})
+		}
+		return t
+	default:
+		return nil
+	}
+}
+
+func at(tok Token, n Node) Token {
+	tok.Pos = n.Pos()
+	return tok
+}
+
+func newAST(tok Token, children ...Node) *AST {
+	t := &AST{Token: tok}
+	for _, c := range children {
+		t.Children = append(t.Children, ToAST(c))
+	}
+	return t
+}
+
+// IsLeaf tells whether the node is a leaf, which only tokens from the input
+// are. An empty list still has an imaginary root, so it's not a leaf.
+func (t *AST) IsLeaf() bool {
+	return t.Token.Type < ProgramRoot && len(t.Children) == 0
+}
+
+// String renders the tree as an S-expression, a leaf is just its token text.
+func (t *AST) String() string {
+	if t.IsLeaf() {
+		return t.text()
+	}
+	var s strings.Builder
+	s.WriteString("(")
+	s.WriteString(t.text())
+	for _, c := range t.Children {
+		s.WriteString(" ")
+		s.WriteString(c.String())
+	}
+	s.WriteString(")")
+	return s.String()
+}
+
+// text is the token text, names are quoted when needed just like NameNode does
+func (t *AST) text() string {
+	if t.Token.Type == Name {
+		return (&NameNode{Token: t.Token}).String()
+	}
+	return t.Token.Text
+}
+
+// Typed views
+//
+// Code walking the homogeneous tree has to know that the left side of an
+// assignment is its first child and the value of a pair its second. The view
+// types below put that knowledge in one place: each wraps an *AST of one kind
+// and has accessors named after the grammar, which is about what the
+// heterogeneous nodes offer, while the tree itself stays uniform. The As
+// methods check the token type before handing out a view:
+//
+//	if a, ok := t.AsAssign(); ok {
+//		walk(a.Lhs())
+//	}
+
+// ProgramAST is a PROGRAM node.
+type ProgramAST struct{ *AST }
+
+// ListAST is a LIST node.
+type ListAST struct{ *AST }
+
+// AssignAST is a '=' node, either an element or a parallel assignment.
+type AssignAST struct{ *AST }
+
+// MapAST is a MAP node.
+type MapAST struct{ *AST }
+
+// PairAST is a PAIR node.
+type PairAST struct{ *AST }
+
+// ErrorAST is an ERROR node, holding the tokens of the bad input.
+type ErrorAST struct{ *AST }
+
+// AsProgram and the other As methods return a view of t, along with whether
+// t is of that kind. A nil tree is of no kind.
+func (t *AST) AsProgram() (ProgramAST, bool) { return ProgramAST{t}, t.is(ProgramRoot) }
+func (t *AST) AsList() (ListAST, bool)       { return ListAST{t}, t.is(ListRoot) }
+func (t *AST) AsAssign() (AssignAST, bool)   { return AssignAST{t}, t.is(Equals) }
+func (t *AST) AsMap() (MapAST, bool)         { return MapAST{t}, t.is(MapRoot) }
+func (t *AST) AsPair() (PairAST, bool)       { return PairAST{t}, t.is(PairRoot) }
+func (t *AST) AsError() (ErrorAST, bool)     { return ErrorAST{t}, t.is(ErrorRoot) }
+
+func (t *AST) is(typ TokenType) bool {
+	return t != nil && t.Token.Type == typ
+}
+
+// Stats returns the statements of the program.
+func (t ProgramAST) Stats() []*AST { return t.Children }
+
+// Elements returns the elements of the list.
+func (t ListAST) Elements() []*AST { return t.Children }
+
+// Lhs returns the left side of the assignment, a name or a list.
+func (t AssignAST) Lhs() *AST { return t.Children[0] }
+
+// Rhs returns the right side of the assignment.
+func (t AssignAST) Rhs() *AST { return t.Children[1] }
+
+// IsParallel tells whether both sides are lists, as in `[a,b]=[c,d]`.
+func (t AssignAST) IsParallel() bool { return t.Lhs().is(ListRoot) }
+
+// Pairs returns the pairs of the map.
+func (t MapAST) Pairs() []PairAST {
+	pairs := make([]PairAST, len(t.Children))
+	for i, c := range t.Children {
+		pairs[i] = PairAST{c}
+	}
+	return pairs
+}
+
+// Key returns the name the pair is for.
+func (t PairAST) Key() *AST { return t.Children[0] }
+
+// Value returns the value of the pair.
+func (t PairAST) Value() *AST { return t.Children[1] }
+
+// Tokens returns the tokens of the bad input.
+func (t ErrorAST) Tokens() []Token {
+	tokens := make([]Token, len(t.Children))
+	for i, c := range t.Children {
+		tokens[i] = c.Token
+	}
+	return tokens
+}
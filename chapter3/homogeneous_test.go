@@ -0,0 +1,71 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTypedAST(t *testing.T) {
+	prog, err := NewBacktrackingParser(NewLexer("[a,b=[c]]=[{d: e}]")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, ok := ToAST(prog).AsProgram()
+	if !ok {
+		t.Fatal("root isn't a program")
+	}
+
+	stat, ok := root.Stats()[0].AsAssign()
+	if !ok || !stat.IsParallel() {
+		t.Fatalf("%v isn't a parallel assignment", root.Stats()[0])
+	}
+	left, ok := stat.Lhs().AsList()
+	if !ok || len(left.Elements()) != 2 {
+		t.Fatalf("%v isn't a list of 2 elements", stat.Lhs())
+	}
+	el, ok := left.Elements()[1].AsAssign()
+	if !ok || el.IsParallel() {
+		t.Fatalf("%v isn't an element assignment", left.Elements()[1])
+	}
+	if got := el.Lhs().String() + " " + el.Rhs().String(); got != "b (LIST c)" {
+		t.Errorf("want: b (LIST c), got: %s", got)
+	}
+
+	right, _ := stat.Rhs().AsList()
+	m, ok := right.Elements()[0].AsMap()
+	if !ok {
+		t.Fatalf("%v isn't a map", right.Elements()[0])
+	}
+	pair := m.Pairs()[0]
+	if got := pair.Key().String() + " " + pair.Value().String(); got != "d e" {
+		t.Errorf("want: d e, got: %s", got)
+	}
+
+	for _, n := range []*AST{stat.Lhs(), pair.Key(), nil} {
+		if _, ok := n.AsMap(); ok {
+			t.Errorf("%v is not a map", n)
+		}
+		if _, ok := n.AsPair(); ok {
+			t.Errorf("%v is not a pair", n)
+		}
+	}
+}
+
+func TestTypedErrorAST(t *testing.T) {
+	p := NewBacktrackingParser(NewLexer("[a, [b c]]"))
+	p.RecoverErrors = true
+	prog, _ := p.Parse()
+	list, _ := ToAST(prog).Children[0].AsList()
+	bad, ok := list.Elements()[1].AsError()
+	if !ok {
+		t.Fatalf("%v isn't an error", list.Elements()[1])
+	}
+	var got []string
+	for _, tok := range bad.Tokens() {
+		got = append(got, tok.Text)
+	}
+	if want := []string{"[", "b", "c", "]"}; !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
@@ -1,8 +1,10 @@
-package main
+package backtracking
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // page 31, Pattern 2:
@@ -39,18 +41,40 @@ import (
 // Grammar to be matched (ANTLR syntax):
 //
 // grammar NestedNameList;
-// list     : '[' elements ']' ;       // match bracketed list
+// list     : '[' elements? ']' ;      // match bracketed list, possibly empty
 // elements : element (',' element)* ; // match comma-separated list
 // element  : NAME | list ;            // element is name or nested list
-// NAME     : ('a'..'z'|'A'..'Z')+ ;   // NAME is sequence of >=1 lette
+// NAME     : ('a'..'z'|'A'..'Z')+      // NAME is sequence of >=1 lette
+//          | '\'' (ESC | ~('\''|'\\'|'\n'))+ '\''  // or any quoted string
+//          ;
+// ESC      : '\\' ('\''|'\\'|'n'|'t') | '\\u' HEX{4} | '\\U' HEX{8} ;
 //
 // example strings that should be matched
+// []
 // [a,b,c]
+// ['weird name','it\'s']
 // [a,[b,c],d]
 
 type Token struct {
 	Type TokenType
 	Text string
+	Pos  Pos // where the token starts
+}
+
+// Pos is a position in the input, both line and column start at 1 and columns
+// count runes, not bytes.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Kind returns the type of the token, for package parsing to match it.
+func (t Token) Kind() TokenType {
+	return t.Type
 }
 
 type TokenType int
@@ -63,6 +87,26 @@ const (
 	Name
 	Comma
 	Equals
+	LBrace
+	RBrace
+	Colon
+	Semicolon
+	Newline
+	Int
+	Float
+	String
+	True
+	False
+	LParen
+	RParen
+
+	// imaginary tokens, never produced by the lexer: they're the roots of
+	// homogeneous AST nodes for constructs without a token of their own
+	ProgramRoot
+	ListRoot
+	MapRoot
+	PairRoot
+	ErrorRoot
 )
 
 func (t TokenType) String() string {
@@ -79,6 +123,40 @@ func (t TokenType) String() string {
 		return "Comma"
 	case Equals:
 		return "Equals"
+	case LBrace:
+		return "LBrace"
+	case RBrace:
+		return "RBrace"
+	case Colon:
+		return "Colon"
+	case Semicolon:
+		return "Semicolon"
+	case Newline:
+		return "Newline"
+	case Int:
+		return "Int"
+	case Float:
+		return "Float"
+	case String:
+		return "String"
+	case True:
+		return "True"
+	case False:
+		return "False"
+	case LParen:
+		return "LParen"
+	case RParen:
+		return "RParen"
+	case ProgramRoot:
+		return "ProgramRoot"
+	case ListRoot:
+		return "ListRoot"
+	case MapRoot:
+		return "MapRoot"
+	case PairRoot:
+		return "PairRoot"
+	case ErrorRoot:
+		return "ErrorRoot"
 	default:
 		return "Unknown"
 	}
@@ -87,80 +165,326 @@ func (t TokenType) String() string {
 // Lexer goes through the input rune by rune and produces Tokens. Lexers are
 // also called "scanners" or "tokenizers".
 type Lexer struct {
-	input   string // entire input
+	input   []rune // entire input
 	pos     int    // current position index in the input
 	current rune   // current rune
 	stopped bool   // is the lexer stopped
+	err     *Error // the error it stopped at, which Next returns again
+	depth   int    // how many brackets and braces are open
+	line    int    // line of the current rune
+	col     int    // column of the current rune
+	start   Pos    // where the token being lexed starts
+
+	unicodeNames bool // names can have any Unicode letter, see WithUnicodeNames
+	comments     bool // '#' starts a comment, see WithComments
 }
 
 // marks the end of input
 var eof = rune(-1)
 
-func NewLexer(input string) *Lexer {
-	if input == "" {
-		return &Lexer{current: eof}
+// NewLexer returns a lexer of input, configured by the options, see
+// options.go.
+func NewLexer(input string, opts ...LexerOption) *Lexer {
+	lex := &Lexer{current: eof, line: 1, col: 1}
+	if input != "" {
+		// convert string to a rune slice once so that indexing is rune-based
+		// and not byte-based, then start at first rune
+		lex.input = []rune(input)
+		lex.current = lex.input[0]
+	}
+	for _, opt := range opts {
+		opt(lex)
 	}
-	// start at first rune
-	// convert string to a rune slice so that indexing is rune-based and not byte-based
-	current := []rune(input)[0]
-	return &Lexer{input: input, current: current}
+	return lex
 }
 
 // isLetter is a helper function, only recognizes ASCII letters
 func isLetter(r rune) bool {
-	return r >= 'a' && r < 'z' || r >= 'A' && r <= 'Z'
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// isDigit is a helper function, only recognizes ASCII digits
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// keywords look like names, the NAME rule checks for them after matching
+var keywords = map[string]TokenType{
+	"true":  True,
+	"false": False,
 }
 
 func (lex *Lexer) Scan() bool {
 	return !lex.stopped
 }
 
-// returns the Token at the current position
+// returns the Token at the current position. After an error the lexer is
+// stuck on it: the bad text is consumed, and reading on would skip it.
 func (lex *Lexer) Next() (Token, error) {
+	if lex.err != nil {
+		return Token{Pos: lex.err.Pos}, lex.err
+	}
+	tok, err := lex.next()
+	tok.Pos = lex.start
+	if err != nil {
+		lex.err = &Error{Pos: lex.start, Err: err}
+		return tok, lex.err
+	}
+	return tok, nil
+}
+
+// next does the work for Next, which adds the position to the token so that
+// lexical rules don't have to
+func (lex *Lexer) next() (Token, error) {
 	for lex.current != eof {
+		lex.start = Pos{Line: lex.line, Col: lex.col}
 		switch lex.current {
-		case ' ', '\t', '\n', '\r':
+		case ' ', '\t', '\r':
 			lex.consume()
 			continue
+		case '\n':
+			lex.consume()
+			// newlines separate statements, but a statement can span several
+			// lines as long as a list or map is still open (Python does the
+			// same with brackets), in which case it's just whitespace
+			if lex.depth > 0 {
+				continue
+			}
+			return Token{Type: Newline, Text: "\n"}, nil
+		case ';':
+			lex.consume()
+			return Token{Type: Semicolon, Text: ";"}, nil
 		case ',':
 			lex.consume()
 			return Token{Type: Comma, Text: ","}, nil
 		case '[':
 			lex.consume()
+			lex.open()
 			return Token{Type: LBrack, Text: "["}, nil
 		case ']':
 			lex.consume()
+			lex.close()
 			return Token{Type: RBrack, Text: "]"}, nil
 		case '=':
 			lex.consume()
 			return Token{Type: Equals, Text: "="}, nil
+		case '{':
+			lex.consume()
+			lex.open()
+			return Token{Type: LBrace, Text: "{"}, nil
+		case '}':
+			lex.consume()
+			lex.close()
+			return Token{Type: RBrace, Text: "}"}, nil
+		case ':':
+			lex.consume()
+			return Token{Type: Colon, Text: ":"}, nil
+		case '(':
+			// parentheses aren't part of the list language, they're only
+			// used by the S-expression notation for trees, see sexpr.go
+			lex.consume()
+			lex.open()
+			return Token{Type: LParen, Text: "("}, nil
+		case ')':
+			lex.consume()
+			lex.close()
+			return Token{Type: RParen, Text: ")"}, nil
+		case '"':
+			return lex.str()
+		case '\'':
+			return lex.quotedName()
+		case '#':
+			if lex.comments {
+				lex.comment()
+				continue
+			}
+			lex.stopped = true
+			return Token{}, fmt.Errorf("non-letter character: %c", lex.current)
 		default:
-			if isLetter(lex.current) {
+			if lex.isNameLetter(lex.current) {
 				return lex.name()
 			}
+			if isDigit(lex.current) {
+				return lex.number()
+			}
 			lex.stopped = true
 			return Token{}, fmt.Errorf("non-letter character: %c", lex.current)
 		}
 	}
+	lex.start = Pos{Line: lex.line, Col: lex.col}
 	lex.stopped = true
 	return Token{Type: EOF}, nil
 }
 
+// open and close keep track of the nesting depth of brackets and braces. It's
+// up to the parser to check they're balanced, an unbalanced closing one just
+// doesn't go below zero.
+func (lex *Lexer) open() {
+	lex.depth++
+}
+
+func (lex *Lexer) close() {
+	if lex.depth > 0 {
+		lex.depth--
+	}
+}
+
 // Lexical rule NAME. The string builder accumulates all the consecutive letters
 // into a token.
 func (lex *Lexer) name() (Token, error) {
 	var s strings.Builder
-	for isLetter(lex.current) {
+	for lex.isNameLetter(lex.current) {
 		s.WriteRune(lex.current)
 		lex.consume()
 	}
 
+	text := s.String()
+	if typ, ok := keywords[text]; ok {
+		return Token{Type: typ, Text: text}, nil
+	}
+	return Token{Type: Name, Text: text}, nil
+}
+
+// Lexical rule NAME for quoted names, which let any string be a name: `'weird
+// name'`. The token text is the name itself, without the quotes and with the
+// escape sequences interpreted, so `'a'` and `a` are the same name. A quoted
+// name is never a keyword, `'true'` is the name true.
+func (lex *Lexer) quotedName() (Token, error) {
+	var s strings.Builder
+	lex.consume() // opening quote
+	for lex.current != '\'' {
+		switch lex.current {
+		case eof, '\n':
+			lex.stopped = true
+			return Token{}, fmt.Errorf("unterminated quoted name: '%s", s.String())
+		case '\\':
+			r, err := lex.escape()
+			if err != nil {
+				lex.stopped = true
+				return Token{}, err
+			}
+			s.WriteRune(r)
+		default:
+			s.WriteRune(lex.current)
+			lex.consume()
+		}
+	}
+	lex.consume() // closing quote
+	if s.Len() == 0 {
+		lex.stopped = true
+		return Token{}, fmt.Errorf("empty quoted name")
+	}
 	return Token{Type: Name, Text: s.String()}, nil
 }
 
-// Consume moves the current position forward by one and saves the next current
-// rune.
+// escape interprets an escape sequence starting at the current backslash
+func (lex *Lexer) escape() (rune, error) {
+	lex.consume() // backslash
+	switch r := lex.current; r {
+	case '\'', '\\':
+		lex.consume()
+		return r, nil
+	case 'n':
+		lex.consume()
+		return '\n', nil
+	case 't':
+		lex.consume()
+		return '\t', nil
+	case 'u':
+		return lex.hexEscape(4)
+	case 'U':
+		return lex.hexEscape(8)
+	default:
+		return 0, fmt.Errorf("invalid escape sequence in name: \\%c", r)
+	}
+}
+
+// hexEscape reads the n hexadecimal digits of a \u or \U escape, which must
+// make a valid Unicode code point.
+func (lex *Lexer) hexEscape(n int) (rune, error) {
+	lex.consume() // u or U
+	var s strings.Builder
+	for range n {
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+	r, err := strconv.ParseUint(s.String(), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(r)) {
+		return 0, fmt.Errorf("invalid unicode escape in name: %s", s.String())
+	}
+	return rune(r), nil
+}
+
+// Lexical rules INT and FLOAT, which share the integer part so we decide which
+// one it is after it's consumed:
+//
+// INT   : ('0'..'9')+ ;
+// FLOAT : ('0'..'9')+ '.' ('0'..'9')+ ;
+func (lex *Lexer) number() (Token, error) {
+	var s strings.Builder
+	lex.digits(&s)
+	if lex.current != '.' {
+		return Token{Type: Int, Text: s.String()}, nil
+	}
+	s.WriteRune(lex.current)
+	lex.consume()
+	if !isDigit(lex.current) {
+		lex.stopped = true
+		return Token{}, fmt.Errorf("malformed number: %s", s.String())
+	}
+	lex.digits(&s)
+	return Token{Type: Float, Text: s.String()}, nil
+}
+
+func (lex *Lexer) digits(s *strings.Builder) {
+	for isDigit(lex.current) {
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+}
+
+// Lexical rule STRING. The token text keeps the quotes and escape sequences as
+// they are in the input, it's up to the parser to get the string's value out
+// of it.
+//
+// STRING : '"' (ESC | ~('"'|'\\'|'\n'))* '"' ;
+// ESC    : '\\' ('"'|'\\'|'n'|'t') ;
+func (lex *Lexer) str() (Token, error) {
+	var s strings.Builder
+	s.WriteRune(lex.current) // opening quote
+	lex.consume()
+	for lex.current != '"' {
+		switch lex.current {
+		case eof, '\n':
+			lex.stopped = true
+			return Token{}, fmt.Errorf("unterminated string: %s", s.String())
+		case '\\':
+			s.WriteRune(lex.current)
+			lex.consume()
+			switch lex.current {
+			case '"', '\\', 'n', 't':
+			default:
+				lex.stopped = true
+				return Token{}, fmt.Errorf("invalid escape sequence in string: \\%c", lex.current)
+			}
+		}
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+	s.WriteRune(lex.current) // closing quote
+	lex.consume()
+	return Token{Type: String, Text: s.String()}, nil
+}
+
+// Consume moves the current position forward by one, keeping track of lines
+// and columns, and saves the next current rune.
 func (lex *Lexer) consume() {
+	if lex.current == '\n' {
+		lex.line++
+		lex.col = 1
+	} else {
+		lex.col++
+	}
 	lex.pos++
 
 	if lex.pos >= len(lex.input) {
@@ -168,6 +492,6 @@ func (lex *Lexer) consume() {
 		lex.current = eof
 	} else {
 		// saves the next rune
-		lex.current = []rune(lex.input)[lex.pos]
+		lex.current = lex.input[lex.pos]
 	}
 }
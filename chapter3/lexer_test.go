@@ -1,6 +1,7 @@
-package main
+package backtracking
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -59,6 +60,81 @@ func TestLexerGoodInput(t *testing.T) {
 				{Type: EOF, Text: ""},
 			},
 		},
+		{
+			name:  "map",
+			input: "[{a: b}]",
+			want: []Token{
+				{Type: LBrack, Text: "["},
+				{Type: LBrace, Text: "{"},
+				{Type: Name, Text: "a"},
+				{Type: Colon, Text: ":"},
+				{Type: Name, Text: "b"},
+				{Type: RBrace, Text: "}"},
+				{Type: RBrack, Text: "]"},
+				{Type: EOF, Text: ""},
+			},
+		},
+		{
+			name:  "statements",
+			input: "[a];[\nb\n]\n",
+			want: []Token{
+				{Type: LBrack, Text: "["},
+				{Type: Name, Text: "a"},
+				{Type: RBrack, Text: "]"},
+				{Type: Semicolon, Text: ";"},
+				{Type: LBrack, Text: "["},
+				{Type: Name, Text: "b"},
+				{Type: RBrack, Text: "]"},
+				{Type: Newline, Text: "\n"},
+				{Type: EOF, Text: ""},
+			},
+		},
+		{
+			name:  "literals",
+			input: `[1,2.5,"a \"b\"",true,false]`,
+			want: []Token{
+				{Type: LBrack, Text: "["},
+				{Type: Int, Text: "1"},
+				{Type: Comma, Text: ","},
+				{Type: Float, Text: "2.5"},
+				{Type: Comma, Text: ","},
+				{Type: String, Text: `"a \"b\""`},
+				{Type: Comma, Text: ","},
+				{Type: True, Text: "true"},
+				{Type: Comma, Text: ","},
+				{Type: False, Text: "false"},
+				{Type: RBrack, Text: "]"},
+				{Type: EOF, Text: ""},
+			},
+		},
+		{
+			name:  "quoted names",
+			input: `['weird name','it\'s','true','\u00fcn\t']`,
+			want: []Token{
+				{Type: LBrack, Text: "["},
+				{Type: Name, Text: "weird name"},
+				{Type: Comma, Text: ","},
+				{Type: Name, Text: "it's"},
+				{Type: Comma, Text: ","},
+				{Type: Name, Text: "true"},
+				{Type: Comma, Text: ","},
+				{Type: Name, Text: "ün\t"},
+				{Type: RBrack, Text: "]"},
+				{Type: EOF, Text: ""},
+			},
+		},
+		{
+			name:  "s-expression",
+			input: "(list a\n1)",
+			want: []Token{
+				{Type: LParen, Text: "("},
+				{Type: Name, Text: "list"},
+				{Type: Name, Text: "a"},
+				{Type: Int, Text: "1"},
+				{Type: RParen, Text: ")"},
+				{Type: EOF, Text: ""},
+			},
+		},
 		{
 			name:  "list within a list",
 			input: "[[a,b]]",
@@ -86,8 +162,8 @@ func TestLexerGoodInput(t *testing.T) {
 				}
 				tokens = append(tokens, token)
 			}
-			if !cmp.Equal(tokens, tc.want) {
-				t.Error(cmp.Diff(tokens, tc.want))
+			if !cmp.Equal(tokens, tc.want, ignorePos) {
+				t.Error(cmp.Diff(tokens, tc.want, ignorePos))
 			}
 		})
 	}
@@ -104,7 +180,35 @@ func TestLexerBadInput(t *testing.T) {
 		},
 		{
 			name:  "non letters",
-			input: "[1,2,3]",
+			input: "[a,%,b]",
+		},
+		{
+			name:  "malformed float",
+			input: "[1.]",
+		},
+		{
+			name:  "unterminated string",
+			input: `["abc]`,
+		},
+		{
+			name:  "invalid escape",
+			input: `["\x"]`,
+		},
+		{
+			name:  "unterminated quoted name",
+			input: "['abc]",
+		},
+		{
+			name:  "empty quoted name",
+			input: "['']",
+		},
+		{
+			name:  "invalid escape in quoted name",
+			input: `['\x']`,
+		},
+		{
+			name:  "invalid unicode escape",
+			input: `['\uZZZZ']`,
 		},
 		{
 			name:  "unrecognizable text",
@@ -126,3 +230,31 @@ func TestLexerBadInput(t *testing.T) {
 		})
 	}
 }
+
+func TestLexerPositions(t *testing.T) {
+	l := NewLexer("[a, 'ü b',\n  1.5]\n[\"x\"]")
+	var got []string
+	for l.Scan() {
+		tok, err := l.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok.Pos.String()+" "+tok.Type.String())
+	}
+	want := []string{
+		"1:1 LBrack", "1:2 Name", "1:3 Comma", "1:5 Name", "1:10 Comma",
+		"2:3 Float", "2:6 RBrack", "2:7 Newline", "3:1 LBrack", "3:2 String",
+		"3:5 RBrack", "3:6 EOF",
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	var err error
+	for l := NewLexer("[a,\n %]"); l.Scan(); {
+		_, err = l.Next()
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "2:2: ") {
+		t.Errorf("want error at 2:2, got: %v", err)
+	}
+}
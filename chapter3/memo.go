@@ -0,0 +1,149 @@
+package backtracking
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// page 59, Pattern 6:
+// Memoizing Parser
+//
+// Backtracking parses the lists of an assignment three times: speculating
+// that `[a]=[b]` is a list parses `[a]` and fails at '=', speculating that
+// it's an assignment parses `[a]` again, and then the assignment is parsed for
+// real. With Memoize the parser remembers, while speculating, where each list
+// ended or the error it failed with, by the index of its '['. Speculating a
+// list from there again skips to its end, or fails right away, without
+// parsing it: `[a]` is parsed twice rather than three times.
+//
+// Only speculating uses the memo, the real parse builds the tree. Speculating
+// only starts again with the next statement, past the lists in the memo, so
+// it's cleared as soon as the parser consumes a token for real.
+// BenchmarkMemoize shows what it saves.
+//
+// MemoStats counts, for each rule memoized, the speculations the memo skipped
+// and the ones it didn't, and how big it got. The memo of a statement holds
+// a list for each '[' in it, which for a huge statement is a lot of them:
+// WithMemoLimit bounds it, forgetting the lists memoized first to make room.
+// Those are the innermost, speculating parses them as parts of the ones
+// after them, so the outermost lists, the ones the next speculation starts
+// with, stay. WithMemoDump writes the memo each time it's cleared, and at
+// the end of the parse if it isn't empty, to see what it holds, here once
+// `[[a]]=[b]` is speculated:
+//
+//	memo cleared at 1:1 [
+//	  list 1:1 skips 5 tokens
+//	  list 1:2 skips 3 tokens
+//	  list 1:7 skips 3 tokens
+
+// MemoStats counts how the memo of a rule was used.
+type MemoStats struct {
+	Hits      int // speculations skipped, the memo having their outcome
+	Misses    int // speculations parsed, their outcome then memoized
+	Evictions int // outcomes forgotten to stay under the limit
+	Size      int // outcomes memoized now
+	Peak      int // outcomes memoized at most
+}
+
+func (s MemoStats) String() string {
+	return fmt.Sprintf("%d hits, %d misses, %d evictions, %d memoized, %d at most", s.Hits, s.Misses, s.Evictions, s.Size, s.Peak)
+}
+
+// listMemo is how speculating a list went: where it stopped, or its error.
+type listMemo struct {
+	from Pos // of its '['
+	stop int
+	err  any
+}
+
+// listStats returns the stats of the memo of lists.
+func (p *BacktrackingParser) listStats() *MemoStats {
+	if p.MemoStats == nil {
+		p.MemoStats = map[string]*MemoStats{}
+	}
+	s, ok := p.MemoStats["list"]
+	if !ok {
+		s = &MemoStats{}
+		p.MemoStats["list"] = s
+	}
+	return s
+}
+
+// parsedList tells whether the list at the current position was already
+// speculated, skipping it if it succeeded and panicking with its error if it
+// didn't.
+func (p *BacktrackingParser) parsedList() bool {
+	m, ok := p.lists[p.Index()]
+	if !ok {
+		return false
+	}
+	p.listStats().Hits++
+	if m.err != nil {
+		panic(m.err)
+	}
+	p.Seek(m.stop)
+	return true
+}
+
+// memoizeList records how speculating the list from start, at from, went, r
+// being what it panicked with, which is panicked with again. Past the limit
+// of WithMemoLimit the first list memoized is forgotten.
+func (p *BacktrackingParser) memoizeList(start int, from Pos, r any) {
+	if p.lists == nil {
+		p.lists = map[int]listMemo{}
+	}
+	s := p.listStats()
+	s.Misses++
+	if p.memoLimit > 0 && len(p.lists) >= p.memoLimit {
+		delete(p.lists, p.memoOrder[0])
+		p.memoOrder = p.memoOrder[1:]
+		s.Evictions++
+	}
+	p.lists[start] = listMemo{from: from, stop: p.Index(), err: r}
+	p.memoOrder = append(p.memoOrder, start)
+	s.Size = len(p.lists)
+	s.Peak = max(s.Peak, s.Size)
+	if r != nil {
+		panic(r)
+	}
+}
+
+// clearMemo forgets the lists speculated, dumping them first for
+// WithMemoDump, tok being the token consumed.
+func (p *BacktrackingParser) clearMemo(tok Token) {
+	if len(p.lists) == 0 {
+		return
+	}
+	p.dumpMemo(fmt.Sprintf("memo cleared at %v %s", tok.Pos, tok.Text))
+	clear(p.lists)
+	p.memoOrder = p.memoOrder[:0]
+	p.listStats().Size = 0
+}
+
+// dumpMemo adds the lists in the memo to the dump of WithMemoDump, under
+// title, in the order of the input.
+func (p *BacktrackingParser) dumpMemo(title string) {
+	if p.memoDump == nil || len(p.lists) == 0 {
+		return
+	}
+	fmt.Fprintln(&p.memoDumps, title)
+	for _, start := range slices.Sorted(maps.Keys(p.lists)) {
+		m := p.lists[start]
+		if m.err != nil {
+			fmt.Fprintf(&p.memoDumps, "  list %v fails: %v\n", m.from, m.err)
+		} else {
+			fmt.Fprintf(&p.memoDumps, "  list %v skips %d tokens\n", m.from, m.stop-start)
+		}
+	}
+}
+
+// writeMemoDump writes the memos dumped to the writer of WithMemoDump, the
+// error writing them being the error of Parse if it hasn't got one.
+func (p *BacktrackingParser) writeMemoDump(err *error) {
+	p.dumpMemo("memo at the end")
+	if _, werr := io.WriteString(p.memoDump, p.memoDumps.String()); *err == nil {
+		*err = werr
+	}
+}
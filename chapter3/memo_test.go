@@ -0,0 +1,220 @@
+package backtracking
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+// corpus returns the programs of a txtar file of the testdata, one per line,
+// without the errors they want.
+func corpus(t *testing.T, file string) []string {
+	t.Helper()
+	ar, err := txtar.ParseFile("testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progs []string
+	for _, f := range ar.Files {
+		for _, line := range bytes.Split(f.Data, []byte("\n")) {
+			if len(line) > 0 {
+				src, _ := splitWant(string(line))
+				progs = append(progs, src)
+			}
+		}
+	}
+	return progs
+}
+
+// splitWant splits a line of a corpus into the program and, after a tab,
+// the error it wants, if any.
+func splitWant(line string) (src, want string) {
+	src, want, _ = strings.Cut(line, "\t")
+	return src, want
+}
+
+func TestMemoizeParsesTheSame(t *testing.T) {
+	progs := append(corpus(t, "good.txt"), corpus(t, "bad.txt")...)
+	// statements after the first one, once the memo has been cleared
+	progs = append(progs, "[a]=[b]\n[a]=[c]\n[[d]]=[e]", "[a]=[b]; [a c]=[d]")
+	for _, src := range progs {
+		want, wantErr := NewBacktrackingParser(NewLexer(src)).Parse()
+		p := NewBacktrackingParser(NewLexer(src))
+		p.Memoize = true
+		got, err := p.Parse()
+		if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+			t.Errorf("%q: want error %v, got %v", src, wantErr, err)
+		}
+		if !cmp.Equal(got, want) {
+			t.Errorf("%q: %s", src, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestMemoizeSkipsLists(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{
+			// the left list is skipped when speculating the assignment
+			src: "[[a]]=[b]",
+			want: `1:1 program: stat, looking at [
+1:1 stat, speculating: assign
+  list 1:1 to 1:6: 1:6: syntax error: expecting end of statement, found Equals
+    1:2 list: elements, looking at [
+    1:2 element: list, looking at [ a
+    1:3 list: elements, looking at a
+    1:3 element: NAME, looking at a ]
+    1:4 elements: end, looking at ]
+    1:5 elements: end, looking at ]
+  assign 1:1 to 1:10
+    1:8 list: elements, looking at b
+    1:8 element: NAME, looking at b ]
+    1:9 elements: end, looking at ]
+1:2 list: elements, looking at [
+1:2 element: list, looking at [ a
+1:3 list: elements, looking at a
+1:3 element: NAME, looking at a ]
+1:4 elements: end, looking at ]
+1:5 elements: end, looking at ]
+1:8 list: elements, looking at b
+1:8 element: NAME, looking at b ]
+1:9 elements: end, looking at ]
+1:10 program: EOF, looking at <EOF>
+`,
+		},
+		{
+			// the left list fails again without being parsed
+			src: "[a b]=[c]",
+			want: `1:1 program: stat, looking at [
+1:1 stat, speculating: no alternative
+  list 1:1 to 1:4: 1:4: match: syntax error: expecting RBrack, got Name
+    1:2 list: elements, looking at a
+    1:2 element: NAME, looking at a b
+    1:4 elements: end, looking at b
+  assign 1:1 to 1:1: 1:4: match: syntax error: expecting RBrack, got Name
+`,
+		},
+	}
+	for _, tc := range cases {
+		p := NewBacktrackingParser(NewLexer(tc.src))
+		p.TraceDecisions = true
+		p.Memoize = true
+		p.Parse()
+		var s strings.Builder
+		if err := WriteTrace(&s, p.Decisions); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tc.want, s.String()); diff != "" {
+			t.Errorf("%q: trace mismatch (-want +got):\n%s", tc.src, diff)
+		}
+	}
+}
+
+func TestMemoStats(t *testing.T) {
+	src := "[[a]]=[b]\n[a b]=[c]"
+	cases := []struct {
+		name  string
+		limit int
+		want  MemoStats
+		dump  string
+	}{
+		{
+			name: "no limit",
+			want: MemoStats{Hits: 2, Misses: 4, Size: 1, Peak: 3},
+			dump: `memo cleared at 1:1 [
+  list 1:1 skips 5 tokens
+  list 1:2 skips 3 tokens
+  list 1:7 skips 3 tokens
+memo at the end
+  list 2:1 fails: 2:4: match: syntax error: expecting RBrack, got Name
+`,
+		},
+		{
+			// the inner list is forgotten for the outer one, which the
+			// assignment then skips, and the outer one for the right list
+			name:  "limit",
+			limit: 1,
+			want:  MemoStats{Hits: 2, Misses: 4, Evictions: 2, Size: 1, Peak: 1},
+			dump: `memo cleared at 1:1 [
+  list 1:7 skips 3 tokens
+memo at the end
+  list 2:1 fails: 2:4: match: syntax error: expecting RBrack, got Name
+`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var dump strings.Builder
+			p := NewBacktrackingParser(NewLexer(src), WithMemoLimit(tc.limit), WithMemoDump(&dump))
+			_, err := p.Parse()
+			if want := "2:1: syntax error: expecting list or assign, found LBrack"; err == nil || err.Error() != want {
+				t.Errorf("got error %v, want %s", err, want)
+			}
+			if got := *p.MemoStats["list"]; got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+			if diff := cmp.Diff(tc.dump, dump.String()); diff != "" {
+				t.Errorf("dump mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	p := NewBacktrackingParser(NewLexer(src))
+	p.Parse()
+	if p.MemoStats != nil {
+		t.Errorf("got stats %v without memoizing", p.MemoStats)
+	}
+}
+
+// nestedInput is a program of n statements, lists and assignments in turn,
+// each list nesting depth lists such as `[a,[a,[a]]]`.
+func nestedInput(n, depth int) string {
+	list := strings.Repeat("[a,", depth-1) + "[a" + strings.Repeat("]", depth)
+	var s strings.Builder
+	for i := range n {
+		if i%2 == 0 {
+			fmt.Fprintln(&s, list)
+		} else {
+			fmt.Fprintf(&s, "%s=%s\n", list, list)
+		}
+	}
+	return s.String()
+}
+
+// BenchmarkMemoize compares backtracking with and without memoizing, over
+// programs of more statements and of deeper lists:
+//
+//	go test -bench Memoize -run XXX
+//
+// Memoizing saves parsing the left list of each assignment a second time:
+// one of the seven times the lists of a list and an assignment are parsed.
+// The memo is a map entry for each list speculated, cheap next to the nodes
+// that speculating builds and throws away, so it saves about that much time
+// and memory whatever the depth. Backtracking stays linear, each statement is
+// speculated at most twice.
+func BenchmarkMemoize(b *testing.B) {
+	for _, n := range []int{10, 1000} {
+		for _, depth := range []int{1, 10, 100} {
+			input := nestedInput(n, depth)
+			for _, memoize := range []bool{false, true} {
+				b.Run(fmt.Sprintf("stats=%d/depth=%d/memoize=%v", n, depth, memoize), func(b *testing.B) {
+					b.ReportAllocs()
+					b.SetBytes(int64(len(input)))
+					for range b.N {
+						p := NewBacktrackingParser(NewLexer(input))
+						p.Memoize = memoize
+						if _, err := p.Parse(); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package backtracking
+
+import (
+	"errors"
+	"io"
+	"unicode"
+)
+
+// Options
+//
+// The lexer and the parser take options, which NewLexer and
+// NewBacktrackingParser apply in order, rather than a parameter for each of
+// the features that changes how they work:
+//
+//	l := NewLexer(src, WithComments())
+//	p := NewBacktrackingParser(l, WithMaxErrors(10), WithTrace(os.Stderr))
+//
+// The options don't replace the fields of the parser: WithMaxErrors,
+// WithTrace, WithMemoLimit and WithMemoDump set RecoverErrors,
+// TraceDecisions and Memoize, adding the limits and the writers that the
+// fields don't have.
+
+// LexerOption configures a Lexer.
+type LexerOption func(*Lexer)
+
+// WithUnicodeNames makes the lexer accept any Unicode letter in names, so
+// that `[año, 名前]` lexes, where only ASCII letters do otherwise. Quoted
+// names can have any character either way.
+func WithUnicodeNames() LexerOption {
+	return func(l *Lexer) { l.unicodeNames = true }
+}
+
+// WithComments makes the lexer skip comments, which start with '#' and go to
+// the end of the line. The newline isn't part of the comment, it still ends
+// the statement.
+func WithComments() LexerOption {
+	return func(l *Lexer) { l.comments = true }
+}
+
+// ParserOption configures a BacktrackingParser.
+type ParserOption func(*BacktrackingParser)
+
+// ErrTooManyErrors is the error Parse adds to the syntax errors when it gives
+// up, past the limit of WithMaxErrors.
+var ErrTooManyErrors = errors.New("too many errors")
+
+// WithMaxErrors makes the parser recover from syntax errors, as
+// RecoverErrors does, but only from n of them: the parse stops at the nth,
+// Parse returning no tree and the errors along with ErrTooManyErrors. With n
+// of 0 there's no limit.
+func WithMaxErrors(n int) ParserOption {
+	return func(p *BacktrackingParser) {
+		p.RecoverErrors = true
+		p.maxErrors = n
+	}
+}
+
+// WithTrace makes the parser trace its decisions, as TraceDecisions does, and
+// write the trace to w once Parse is done, successful or not, see
+// WriteTrace.
+func WithTrace(w io.Writer) ParserOption {
+	return func(p *BacktrackingParser) {
+		p.TraceDecisions = true
+		p.trace = w
+	}
+}
+
+// WithMemoLimit makes the parser memoize the lists it speculates, as Memoize
+// does, but only n of them at a time: memoizing one more forgets the first
+// one memoized, see memo.go. With n of 0 there's no limit.
+func WithMemoLimit(n int) ParserOption {
+	return func(p *BacktrackingParser) {
+		p.Memoize = true
+		p.memoLimit = n
+	}
+}
+
+// WithMemoDump makes the parser memoize the lists it speculates, as Memoize
+// does, and write the lists in the memo each time it's cleared to w, once
+// Parse is done, successful or not.
+func WithMemoDump(w io.Writer) ParserOption {
+	return func(p *BacktrackingParser) {
+		p.Memoize = true
+		p.memoDump = w
+	}
+}
+
+// isNameLetter tells whether r can be in a name.
+func (lex *Lexer) isNameLetter(r rune) bool {
+	if lex.unicodeNames {
+		return unicode.IsLetter(r)
+	}
+	return isLetter(r)
+}
+
+// comment skips a comment, up to the newline ending it.
+func (lex *Lexer) comment() {
+	for lex.current != '\n' && lex.current != eof {
+		lex.consume()
+	}
+}
+
+// tooManyErrors stops the parse if it's reached the limit of WithMaxErrors.
+func (p *BacktrackingParser) tooManyErrors() {
+	if p.maxErrors > 0 && len(p.Errors) >= p.maxErrors {
+		panic(ErrTooManyErrors)
+	}
+}
+
+// writeTrace writes the decisions to the writer of WithTrace, the error
+// writing them being the error of Parse if it hasn't got one.
+func (p *BacktrackingParser) writeTrace(err *error) {
+	if werr := WriteTrace(p.trace, p.Decisions); *err == nil {
+		*err = werr
+	}
+}
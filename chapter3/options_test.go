@@ -0,0 +1,135 @@
+package backtracking
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLexerOptions(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		opts  []LexerOption
+		want  []string // token texts, the error last if there's one
+	}{
+		{
+			name:  "ASCII names",
+			input: "[año]",
+			want:  []string{"[", "a", "1:3: non-letter character: ñ"},
+		},
+		{
+			name:  "unicode names",
+			input: "[año, 名前]",
+			opts:  []LexerOption{WithUnicodeNames()},
+			want:  []string{"[", "año", ",", "名前", "]", ""},
+		},
+		{
+			name:  "no comments",
+			input: "[a] # b",
+			want:  []string{"[", "a", "]", "1:5: non-letter character: #"},
+		},
+		{
+			name:  "comments",
+			input: "# lists\n[a] # a list\n[b]#",
+			opts:  []LexerOption{WithComments()},
+			want:  []string{"\n", "[", "a", "]", "\n", "[", "b", "]", ""},
+		},
+		{
+			name:  "comments in a list",
+			input: "[a, # the first\n b]",
+			opts:  []LexerOption{WithComments()},
+			want:  []string{"[", "a", ",", "b", "]", ""},
+		},
+		{
+			name:  "both",
+			input: "[é] # è",
+			opts:  []LexerOption{WithComments(), WithUnicodeNames()},
+			want:  []string{"[", "é", "]", ""},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer(tc.input, tc.opts...)
+			var got []string
+			for l.Scan() {
+				tok, err := l.Next()
+				if err != nil {
+					got = append(got, err.Error())
+					break
+				}
+				got = append(got, tok.Text)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestWithMaxErrors(t *testing.T) {
+	src := "[a,,b]; [c d]; [e f]"
+	cases := []struct {
+		max     int
+		tree    bool
+		errs    int
+		tooMany bool
+	}{
+		{max: 0, tree: true, errs: 3},
+		{max: 3, tree: false, errs: 3, tooMany: true},
+		{max: 2, tree: false, errs: 2, tooMany: true},
+		{max: 4, tree: true, errs: 3},
+	}
+
+	for _, tc := range cases {
+		p := NewBacktrackingParser(NewLexer(src), WithMaxErrors(tc.max))
+		prog, err := p.Parse()
+		if got := prog != nil; got != tc.tree {
+			t.Errorf("max %d: want tree: %v, got: %v", tc.max, tc.tree, got)
+		}
+		if got := len(p.Errors); got != tc.errs {
+			t.Errorf("max %d: want %d errors, got %d: %v", tc.max, tc.errs, got, err)
+		}
+		if got := errors.Is(err, ErrTooManyErrors); got != tc.tooMany {
+			t.Errorf("max %d: want too many errors: %v, got: %v", tc.max, tc.tooMany, err)
+		}
+		if !errors.Is(err, SyntaxError) {
+			t.Errorf("max %d: want: syntax errors, got: %v", tc.max, err)
+		}
+	}
+}
+
+func TestWithTrace(t *testing.T) {
+	var s strings.Builder
+	p := NewBacktrackingParser(NewLexer("[a]"), WithTrace(&s))
+	if _, err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	want := `1:1 program: stat, looking at [
+1:1 stat, speculating: list
+  list 1:1 to 1:4
+    1:2 list: elements, looking at a
+    1:2 element: NAME, looking at a ]
+    1:3 elements: end, looking at ]
+1:2 list: elements, looking at a
+1:2 element: NAME, looking at a ]
+1:3 elements: end, looking at ]
+1:4 program: EOF, looking at <EOF>
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	// the trace is written up to the error
+	s.Reset()
+	p = NewBacktrackingParser(NewLexer("[a,,b]"), WithTrace(&s))
+	if _, err := p.Parse(); err == nil {
+		t.Fatal("want: syntax error, got: nil")
+	}
+	if got, want := s.String(), "1:1 stat, speculating: no alternative\n"; !strings.Contains(got, want) {
+		t.Errorf("missing %q in:\n%s", want, got)
+	}
+}
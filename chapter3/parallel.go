@@ -0,0 +1,188 @@
+package backtracking
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// Parallel parsing
+//
+// The statements of a program don't depend on each other: a statement ends
+// at a ';' or a newline outside of brackets and braces, and the parser
+// starts afresh after it, its speculations and its memo included. So they
+// can be parsed each on its own, on as many goroutines as there are
+// processors, for programs and sets of files too large to wait for a single
+// parser to go through.
+//
+// ParseFiles lexes each file once to find where its statements end, which
+// is the part that isn't parallel, and hands each statement, separator
+// included, to a pool of workers, each parsing with a parser and a lexer of
+// its own that start at the position of the statement. The results are put
+// back together in the order of the input, whichever worker finishes first:
+// the statements of the program, and the diagnostics sorted by position. A
+// file parses to what Parse returns for it, with the same options:
+//
+//   - a statement the parse stops at, a syntax error without RecoverErrors or
+//     an error of the lexer, is the error of the file, and the statements
+//     after it aren't part of it, as Parse doesn't get to them
+//   - the errors recovered from are joined in the order of the statements,
+//     and WithMaxErrors counts them over the whole file, not each statement
+//
+// The parsers only share the options, so the ones writing to a writer, such
+// as WithTrace, can't be given; the trees and decisions recorded by a parser
+// are left in it too.
+
+// File is a program to parse with ParseFiles, and the name it has in the
+// diagnostics.
+type File struct {
+	Name string
+	Src  string
+}
+
+// ParsedFile is what ParseFiles found in a file: the program and the error
+// Parse would have returned, and the diagnostics of its parser.
+type ParsedFile struct {
+	Name        string
+	Program     *ProgramNode
+	Diagnostics []Diagnostic
+	Err         error
+}
+
+// statement is the source of a statement of a file, from where it starts up
+// to its separator included.
+type statement struct {
+	file int
+	src  string
+	from Pos
+}
+
+// parsedStatement is what the parser of a statement found.
+type parsedStatement struct {
+	prog        *ProgramNode
+	err         error
+	errs        []error // recovered from
+	diagnostics []Diagnostic
+	maxErrors   int
+}
+
+// ParseFiles parses the files on workers goroutines, the number of
+// processors if it's 0 or less, a statement at a time. The lexers and the
+// parsers of the statements are configured by the options, and the files
+// parsed are in the order they're given.
+func ParseFiles(files []File, workers int, lexOpts []LexerOption, opts ...ParserOption) []ParsedFile {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var stats []statement
+	for i, f := range files {
+		stats = append(stats, splitStatements(i, f.Src, lexOpts)...)
+	}
+	parsed := make([]parsedStatement, len(stats))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(stats)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				parsed[i] = parseStatement(stats[i], lexOpts, opts)
+			}
+		}()
+	}
+	for i := range stats {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	results := make([]ParsedFile, len(files))
+	for i, f := range files {
+		results[i] = ParsedFile{Name: f.Name, Program: &ProgramNode{}}
+	}
+	errs := make([][]error, len(files)) // recovered from, by file
+	done := make([]bool, len(files))    // the parse of the file stopped
+	for i, s := range stats {
+		if !done[s.file] {
+			done[s.file] = merge(&results[s.file], &errs[s.file], parsed[i])
+		}
+	}
+	for i := range results {
+		if !done[i] {
+			results[i].Err = errors.Join(errs[i]...)
+		}
+	}
+	return results
+}
+
+// merge adds a statement to the file it's in, errs being the errors the file
+// recovered from so far, and tells whether the parse of the file stops at
+// the statement.
+func merge(f *ParsedFile, errs *[]error, s parsedStatement) bool {
+	*errs = append(*errs, s.errs...)
+	if n := s.maxErrors; n > 0 && len(*errs) >= n {
+		// the parse of the whole file would stop at the nth error, before the
+		// diagnostics of the statement after it
+		*errs = (*errs)[:n]
+		last := errorDiagnostic((*errs)[n-1]).Pos
+		for _, d := range s.diagnostics {
+			if !last.before(d.Pos) {
+				f.Diagnostics = append(f.Diagnostics, d)
+			}
+		}
+		f.Program, f.Err = nil, errors.Join(append(*errs, ErrTooManyErrors)...)
+		return true
+	}
+	f.Diagnostics = append(f.Diagnostics, s.diagnostics...)
+	if s.prog == nil {
+		f.Program, f.Err = nil, s.err
+		return true
+	}
+	f.Program.Stats = append(f.Program.Stats, s.prog.Stats...)
+	return false
+}
+
+// before tells whether p is before q in the input.
+func (p Pos) before(q Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Col < q.Col
+}
+
+// splitStatements returns the statements of the file src, numbered file. The
+// statement with an error of the lexer goes to the end of src, where the
+// lexer stops.
+func splitStatements(file int, src string, opts []LexerOption) []statement {
+	lex := NewLexer(src, opts...)
+	var stats []statement
+	start, from := 0, Pos{Line: 1, Col: 1}
+	for lex.Scan() {
+		tok, err := lex.Next()
+		if err != nil {
+			break
+		}
+		// a newline inside brackets isn't a token, but a ';' is, which is a
+		// syntax error of the statement it's in
+		if tok.Type == Newline || tok.Type == Semicolon && lex.depth == 0 {
+			stats = append(stats, statement{file, string(lex.input[start:lex.pos]), from})
+			start, from = lex.pos, Pos{Line: lex.line, Col: lex.col}
+		}
+	}
+	if start < len(lex.input) {
+		stats = append(stats, statement{file, string(lex.input[start:]), from})
+	}
+	return stats
+}
+
+// parseStatement parses s with a parser of its own.
+func parseStatement(s statement, lexOpts []LexerOption, opts []ParserOption) parsedStatement {
+	lex := NewLexer(s.src, lexOpts...)
+	lex.line, lex.col = s.from.Line, s.from.Col
+	p := NewBacktrackingParser(lex, opts...)
+	prog, err := p.Parse()
+	return parsedStatement{
+		prog:        prog,
+		err:         err,
+		errs:        p.Errors,
+		diagnostics: p.Diagnostics(),
+		maxErrors:   p.maxErrors,
+	}
+}
@@ -0,0 +1,87 @@
+package backtracking
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestParseFiles checks that the files parse in parallel to what they parse
+// to with Parse, whatever the options and however many workers there are.
+func TestParseFiles(t *testing.T) {
+	good, bad := corpus(t, "good.txt"), corpus(t, "bad.txt")
+	files := []File{
+		{Name: "good", Src: strings.Join(good, "\n")},
+		{Name: "bad", Src: strings.Join(bad, "\n")},
+		{Name: "statements", Src: "[a]; ; [b]=[c]\n\n[d,\n e]; {f: g}\n[h]=[i]"},
+		{Name: "semicolon in a list", Src: "[a; b]; [c]"},
+		{Name: "lexer error", Src: "[a]\n[b,,c]\n[#]\n[d]"},
+		{Name: "bad at the end", Src: "[a]\n[b,]\n[c"},
+		{Name: "empty"},
+	}
+	for _, line := range good {
+		files = append(files, File{Name: line, Src: line})
+	}
+	options := map[string][]ParserOption{
+		"no options":   nil,
+		"recover":      {func(p *BacktrackingParser) { p.RecoverErrors = true }},
+		"max errors 3": {WithMaxErrors(3)},
+		"trailing":     {func(p *BacktrackingParser) { p.AllowTrailingComma = true }},
+		"memoize":      {WithMemoLimit(2), WithMaxErrors(1)},
+	}
+	errorText := cmp.Comparer(func(a, b error) bool { return fmt.Sprint(a) == fmt.Sprint(b) })
+
+	for name, opts := range options {
+		want := make([]ParsedFile, len(files))
+		for i, f := range files {
+			p := NewBacktrackingParser(NewLexer(f.Src, WithComments()), opts...)
+			prog, err := p.Parse()
+			want[i] = ParsedFile{Name: f.Name, Program: prog, Diagnostics: p.Diagnostics(), Err: err}
+		}
+		for _, workers := range []int{1, 4, 0} {
+			t.Run(fmt.Sprintf("%s/workers=%d", name, workers), func(t *testing.T) {
+				got := ParseFiles(files, workers, []LexerOption{WithComments()}, opts...)
+				if diff := cmp.Diff(want, got, errorText); diff != "" {
+					t.Errorf("mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkParseFiles compares parsing files whole to parsing their
+// statements on more and more goroutines:
+//
+//	go test -bench ParseFiles -run XXX
+func BenchmarkParseFiles(b *testing.B) {
+	files := make([]File, 8)
+	size := 0
+	for i := range files {
+		files[i] = File{Name: fmt.Sprint(i), Src: bigInput(2000)}
+		size += len(files[i].Src)
+	}
+	b.Run("whole", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		for range b.N {
+			for _, f := range files {
+				if _, err := Parse(f.Src); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for range b.N {
+				for _, f := range ParseFiles(files, workers, nil) {
+					if f.Err != nil {
+						b.Fatal(f.Err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,19 @@
-package main
+// Package backtracking parses programs of lists, maps and assignments with
+// the backtracking parser of the book's chapter 3, memoizing or not, and has
+// the trees it builds and what's done with them in the following chapters:
+// walking, matching, rewriting, formatting, diffing, checking, translating
+// and converting them.
+package backtracking
 
 import (
 	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"example.com/parsing"
 )
 
 // page 53, Pattern 5:
@@ -11,190 +22,574 @@ import (
 // Grammar to be parsed (ANTLR syntax):
 //
 // grammar NestedNameListWithParallelAssign;
-// stat 	: list EOF | assign EOF ;
+// program	: stat? (sep stat?)* EOF ;		// match statements, empty ones are skipped
+// sep		: ';' | NEWLINE ;
+// stat 	: list | assign ;
 // assign	: list '=' list ;
-// list     : '[' elements ']' ;       		// match bracketed list
-// elements : element (',' element)* ;		// match comma-separated list
-// element  : NAME '=' NAME | NAME | list ;	// match assignment such as a=b
+// list     : '[' elements? ']' ;      		// match bracketed list, possibly empty
+// elements : element (',' element)* ','? ;	// match comma-separated list, trailing ',' if allowed
+// element  : NAME '=' element				// match assignment such as a=b or a=[b]
+//			| NAME | list | map | literal
+//			;
+// map      : '{' pairs? '}' ;				// match record such as {a: b}, possibly empty
+// pairs    : pair (',' pair)* ','? ;		// match comma-separated pairs, trailing ',' if allowed
+// pair     : NAME ':' element ;
+// literal  : INT | FLOAT | STRING | 'true' | 'false' ;
 // NAME     : ('a'..'z'|'A'..'Z')+ ;   		// NAME is sequence of >=1 letter
+//
+// The grammar is also in grammar.g, read to tell how much of it the tests
+// cover, see cover.go.
+//
+// Each rule method returns the AST node for what it matched, see ast.go. While
+// speculating the nodes are built and thrown away, which is wasteful but keeps
+// the rule methods free of "are we speculating?" checks.
 
-var SyntaxError = errors.New("syntax error")
+// SyntaxError is what syntax errors wrap, the errors of match included.
+var SyntaxError = parsing.SyntaxError
 
+// BacktrackingParser has the lookahead buffer of package parsing, which
+// grows as far as a speculation goes and takes it back on release.
 type BacktrackingParser struct {
-	input     *Lexer
-	lookahead []Token // circular lookahead buffer
-	pos       int     // position into lookahead buffer
-	markers   []int   // stack of positions into lookahead buffer
+	*parsing.Parser[Token, TokenType]
+
+	// AllowTrailingComma makes the parser accept lists such as `[a,b,]`,
+	// which is handy when the list language is used for configuration. A
+	// trailing comma is then reported in Warnings instead of a SyntaxError.
+	AllowTrailingComma bool
+	Warnings           []Diagnostic
+	infos              []Diagnostic // see Diagnostics
+
+	// BuildParseTree makes the parser record a parse tree in ParseTree as it
+	// goes, see parsetree.go.
+	BuildParseTree bool
+	ParseTree      *ParseTree
+	current        *ParseTree // rule being parsed
+
+	// TraceDecisions makes the parser record each decision it makes in
+	// Decisions, speculations included, see trace.go.
+	TraceDecisions bool
+	Decisions      []*Decision
+	tries          []*Speculation // speculations going on, innermost last
+	trace          io.Writer      // where to write the decisions, see WithTrace
+
+	// Memoize makes the parser remember the lists it speculated, so that
+	// speculating them again doesn't parse them again, see memo.go.
+	// MemoStats counts how the memo was used, by rule.
+	Memoize   bool
+	MemoStats map[string]*MemoStats
+	lists     map[int]listMemo // lists speculated, by the index of '['
+	memoOrder []int            // their indexes, in the order they were memoized
+	memoLimit int              // lists to memoize at most, see WithMemoLimit
+	memoDump  io.Writer        // where to dump the memo, see WithMemoDump
+	memoDumps strings.Builder  // the memos dumped so far
+
+	// Arena makes the parser allocate nodes in blocks, see arena.go. Nil
+	// allocates each node on its own.
+	Arena *Arena
+
+	// RecoverErrors makes the parser go on after a syntax error: the bad
+	// input is skipped up to the end of the element or statement it's in,
+	// an ErrorNode takes its place in the tree and the error is added to
+	// Errors. Parse then returns the tree for the whole input along with
+	// the errors.
+	RecoverErrors bool
+	Errors        []error
+	err           error   // the error the parse stopped at, if it did
+	maxErrors     int     // errors to give up at, see WithMaxErrors
+	last          Token   // the last token consumed, for repair
+	nesting       int     // brackets and braces open at the current token
+	consumed      []Token // tokens of the current statement, for error nodes
+
+	// elements of lists and pairs of maps being parsed, nested lists and maps
+	// push theirs on top so the same slices are reused for all of them
+	elems    []Node
+	pairList []*PairNode
 }
 
-// Returns a new Backtracking Parser with k lookahead symbols (length of the buffer)
-func NewBacktrackingParser(l *Lexer) *BacktrackingParser {
-	p := &BacktrackingParser{input: l}
-
-	p.sync(1)
+// NewBacktrackingParser returns a parser of the tokens of l, looking as far
+// ahead as it takes, configured by the options, see options.go.
+func NewBacktrackingParser(l *Lexer, opts ...ParserOption) *BacktrackingParser {
+	p := &BacktrackingParser{Parser: parsing.New[Token, TokenType](l, 0)}
+	p.Mismatch = mismatch
+	p.Fail = fail
+	p.Consumed = p.track
+	for _, opt := range opts {
+		opt(p)
+	}
 	return p
 }
 
-func (p *BacktrackingParser) stat() {
-	if p.speculateList() {
-		p.list()
-		p.match(EOF)
-	} else if p.speculateAssign() {
-		p.assign()
-		p.match(EOF)
+// Parse parses the program in src with a parser of its own, without any of
+// the options. Goroutines can call it at the same time: they share nothing.
+func Parse(src string) (*ProgramNode, error) {
+	return NewBacktrackingParser(NewLexer(src)).Parse()
+}
+
+// Parse parses a whole program. The rule methods panic on the first error,
+// Parse recovers and returns it instead. With RecoverErrors the program is
+// returned even if there are syntax errors, which are all joined in err.
+//
+// A parser keeps the state of its parse, errors and trees included, so it
+// parses a single program, in a single goroutine: Parse fails with
+// parsing.ErrReused if it's called again.
+func (p *BacktrackingParser) Parse() (prog *ProgramNode, err error) {
+	if err := p.Begin(); err != nil {
+		return nil, err
+	}
+	if p.trace != nil {
+		defer p.writeTrace(&err)
+	}
+	if p.memoDump != nil {
+		defer p.writeMemoDump(&err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			if e == ErrTooManyErrors {
+				e = errors.Join(append(p.Errors, e)...)
+			} else {
+				p.err = e
+				p.repairFatal(e)
+			}
+			err = e
+		}
+	}()
+	prog = p.program()
+	return prog, errors.Join(p.Errors...)
+}
+
+func (p *BacktrackingParser) program() *ProgramNode {
+	defer p.enter("program")()
+	n := &ProgramNode{}
+	for p.Peek(1).Type != EOF {
+		if !isSeparator(p.Peek(1).Type) {
+			p.predict("program", "stat", 1)
+			n.Stats = append(n.Stats, p.recoverStat())
+		} else {
+			p.predict("program", "empty", 1)
+			if tok := p.Peek(1); tok.Type == Semicolon {
+				p.infos = append(p.infos, diagnose(tok, SeverityInfo, CodeEmptyStatement, "empty statement"))
+			}
+		}
+		if p.Peek(1).Type == EOF {
+			break
+		}
+		p.sep()
+	}
+	p.predict("program", "EOF", 1)
+	p.match(EOF)
+	return n
+}
+
+func (p *BacktrackingParser) sep() {
+	defer p.enter("sep")()
+	tok := p.Peek(1)
+	if !isSeparator(tok.Type) {
+		err := syntaxError(tok.Pos, "expecting ';' or newline, found %v", tok.Type)
+		err.want = []TokenType{Semicolon}
+		panic(err)
+	}
+	p.match(tok.Type)
+}
+
+func isSeparator(typ TokenType) bool {
+	return typ == Semicolon || typ == Newline
+}
+
+func (p *BacktrackingParser) stat() Node {
+	defer p.enter("stat")()
+	var n Node
+	d := p.speculative("stat")
+	if p.speculateList(d) {
+		p.took(d, "list")
+		n = p.list()
+	} else if p.speculateAssign(d) {
+		p.took(d, "assign")
+		n = p.assign()
+	} else if p.RecoverErrors && p.Peek(1).Type == LBrack {
+		// parse it for real to recover from the errors inside the lists
+		p.took(d, "list, recovering")
+		n = p.list()
+		if p.Peek(1).Type == Equals {
+			p.match(Equals)
+			n = p.Arena.newAssign(n, p.list())
+		}
+		p.endOfStat()
 	} else {
-		tok := p.peek(1)
-		err := fmt.Errorf("%w: expecting list or assign, found %v", SyntaxError, tok.Type)
+		tok := p.Peek(1)
+		err := syntaxError(tok.Pos, "expecting list or assign, found %v", tok.Type)
+		panic(err)
+	}
+	return n
+}
+
+// endOfStat checks that a statement is followed by a separator or the end of
+// input, without consuming anything. Speculating only a list would otherwise
+// succeed on the left hand side of `[a]=[b]`.
+func (p *BacktrackingParser) endOfStat() {
+	tok := p.Peek(1)
+	if !isSeparator(tok.Type) && tok.Type != EOF {
+		err := syntaxError(tok.Pos, "expecting end of statement, found %v", tok.Type)
+		err.want = []TokenType{Semicolon}
 		panic(err)
 	}
 }
 
-func (p *BacktrackingParser) speculateList() bool {
+// speculateList tells whether the statement is a list, recording the
+// speculation in d if decisions are traced.
+func (p *BacktrackingParser) speculateList(d *Decision) bool {
 	success := true
-	p.mark()
+	p.Mark()
+	p.try(d, "list")
 
 	defer func() {
-		if r := recover(); r != nil {
+		r := recover()
+		if r != nil && !isSyntaxError(r) {
+			p.Release()
+			panic(r)
+		}
+		p.tried(d, r)
+		if r != nil {
 			success = false
-			p.release()
 		}
+		p.Release()
 	}()
 
 	p.list()
-	p.match(EOF)
-
-	p.release()
+	p.endOfStat()
 	return success
-
 }
 
-func (p *BacktrackingParser) speculateAssign() bool {
+// speculateAssign tells whether the statement is an assignment, recording the
+// speculation in d if decisions are traced.
+func (p *BacktrackingParser) speculateAssign(d *Decision) bool {
 	success := true
-	p.mark()
+	p.Mark()
+	p.try(d, "assign")
 
 	defer func() {
-		if r := recover(); r != nil {
+		r := recover()
+		if r != nil && !isSyntaxError(r) {
+			p.Release()
+			panic(r)
+		}
+		p.tried(d, r)
+		if r != nil {
 			success = false
-			p.release()
 		}
+		p.Release()
 	}()
 
 	p.assign()
-	p.match(EOF)
-
-	p.release()
+	p.endOfStat()
 	return success
+}
 
+// isSyntaxError tells whether a speculation panicked with a syntax error, the
+// alternative not being the statement. Anything else, an error of the lexer
+// in particular, stops the parse whichever the alternative.
+func isSyntaxError(r any) bool {
+	err, ok := r.(error)
+	return ok && errors.Is(err, SyntaxError)
 }
 
-func (p *BacktrackingParser) assign() {
-	p.list()
+func (p *BacktrackingParser) assign() *AssignNode {
+	defer p.enter("assign")()
+	left := p.list()
 	p.match(Equals)
-	p.list()
+	right := p.list()
+	return p.Arena.newAssign(left, right)
 }
 
-func (p *BacktrackingParser) list() {
-	p.match(LBrack)
-	p.elements()
+func (p *BacktrackingParser) list() *ListNode {
+	if p.Memoize && p.Speculating() {
+		// the nodes built speculating are thrown away, there's no need for
+		// one
+		if p.parsedList() {
+			return nil
+		}
+		start, from := p.Index(), p.Peek(1).Pos
+		defer func() { p.memoizeList(start, from, recover()) }()
+	}
+	defer p.enter("list")()
+	lbrack := p.match(LBrack).Pos
+	start := len(p.elems)
+	defer func() { p.elems = p.elems[:start] }()
+	// elements is optional, an empty list `[]` has nothing between brackets
+	if p.Peek(1).Type != RBrack {
+		p.predict("list", "elements", 1)
+		p.elements()
+	} else {
+		p.predict("list", "empty", 1)
+	}
 	p.match(RBrack)
+	return p.Arena.newList(lbrack, p.elems[start:])
 }
 
+// elements pushes each element it matches on p.elems, it's up to the list to
+// take them off
 func (p *BacktrackingParser) elements() {
-	p.element()
-	for p.peek(1).Type == Comma {
-		p.match(Comma)
-		p.element()
+	defer p.enter("elements")()
+	p.elems = append(p.elems, p.recoverElement())
+	for p.Peek(1).Type == Comma {
+		p.predict("elements", "','", 1)
+		comma := p.match(Comma)
+		if p.AllowTrailingComma && p.Peek(1).Type == RBrack {
+			p.predict("elements", "trailing ','", 1)
+			p.warn(comma, CodeTrailingComma, "trailing comma in list")
+			break
+		}
+		p.elems = append(p.elems, p.recoverElement())
 	}
+	p.predict("elements", "end", 1)
 }
 
 // element needs 2 lookahead tokens to make a decision on whether it's an
 // assignment or not.
-func (p *BacktrackingParser) element() {
-	first, second := p.peek(1), p.peek(2)
+func (p *BacktrackingParser) element() Node {
+	defer p.enter("element")()
+	first, second := p.Peek(1), p.Peek(2)
 
 	if first.Type == Name && second.Type == Equals {
-		p.match(Name)
+		p.predict("element", "NAME '=' element", 2)
+		name := p.match(Name)
 		p.match(Equals)
-		p.match(Name)
+		value := p.recoverElement()
+		return p.Arena.newAssign(p.Arena.newName(name), value)
 	} else if first.Type == Name {
-		p.match(Name)
+		p.predict("element", "NAME", 2)
+		return p.Arena.newName(p.match(Name))
 	} else if first.Type == LBrack && second.Type != EOF {
-		p.list()
+		p.predict("element", "list", 2)
+		return p.list()
+	} else if first.Type == LBrace {
+		p.predict("element", "map", 2)
+		return p.mapping()
+	} else if isLiteral(first.Type) {
+		p.predict("element", "literal", 2)
+		return p.literal()
 	} else {
-		err := fmt.Errorf("%w: expecting name or list, found %+v", SyntaxError, first.Type)
+		p.predict("element", "", 2)
+		err := syntaxError(first.Pos, "expecting name, list, map or literal, found %+v", first.Type)
+		err.want = elementStarts
 		panic(err)
 	}
 }
 
-// mark pushes the currenct position into the stack so we can backtrack to it
-// later
-func (p *BacktrackingParser) mark() {
-	p.markers = append(p.markers, p.pos)
+func isLiteral(typ TokenType) bool {
+	switch typ {
+	case Int, Float, String, True, False:
+		return true
+	}
+	return false
 }
 
-// release pops the last marker from the stack and backtracks to it
-func (p *BacktrackingParser) release() {
-	position := p.markers[len(p.markers)-1]
-	p.markers = p.markers[:len(p.markers)-1] // pop
-	p.seek(position)
+func (p *BacktrackingParser) literal() Node {
+	defer p.enter("literal")()
+	tok := p.Peek(1)
+	if !isLiteral(tok.Type) {
+		err := syntaxError(tok.Pos, "expecting literal, found %+v", tok.Type)
+		panic(err)
+	}
+	p.match(tok.Type)
+	n, err := literalNode(p.Arena, tok)
+	if err != nil {
+		panic(err)
+	}
+	return n
 }
 
-func (p *BacktrackingParser) seek(position int) {
-	p.pos = position
+// literalNode converts the token text to the value it represents. The lexer
+// has already checked the syntax of each literal, so conversions only fail for
+// numbers that don't fit in 64 bits. The arena can be nil.
+func literalNode(a *Arena, tok Token) (Node, error) {
+	switch tok.Type {
+	case Int:
+		v, err := strconv.ParseInt(tok.Text, 10, 64)
+		if err != nil {
+			return nil, syntaxError(tok.Pos, "invalid integer %s: %w", tok.Text, err)
+		}
+		return a.newInt(tok, v), nil
+	case Float:
+		v, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			return nil, syntaxError(tok.Pos, "invalid float %s: %w", tok.Text, err)
+		}
+		return a.newFloat(tok, v), nil
+	case String:
+		// the escape sequences allowed by the lexer are a subset of Go's
+		v, err := strconv.Unquote(tok.Text)
+		if err != nil {
+			return nil, syntaxError(tok.Pos, "invalid string %s: %w", tok.Text, err)
+		}
+		return a.newString(tok, v), nil
+	case True, False:
+		return a.newBool(tok, tok.Type == True), nil
+	default:
+		return nil, syntaxError(tok.Pos, "expecting literal, found %+v", tok.Type)
+	}
 }
 
-func (p *BacktrackingParser) isSpeculating() bool {
-	return len(p.markers) > 0
+// mapping is the rule `map`, which is a reserved word in Go.
+func (p *BacktrackingParser) mapping() *MapNode {
+	defer p.enter("map")()
+	lbrace := p.match(LBrace).Pos
+	start := len(p.pairList)
+	defer func() { p.pairList = p.pairList[:start] }()
+	// pairs are optional just like list elements, `{}` is an empty map
+	if p.Peek(1).Type != RBrace {
+		p.predict("map", "pairs", 1)
+		p.pairs()
+	} else {
+		p.predict("map", "empty", 1)
+	}
+	p.match(RBrace)
+	return p.Arena.newMap(lbrace, p.pairList[start:])
 }
 
-func (p *BacktrackingParser) sync(i int) {
-	if p.pos+i-1 > (len(p.lookahead) - 1) {
-		n := p.pos + i - 1 - (len(p.lookahead) - 1)
-		p.fill(n)
+// pairs pushes each pair it matches on p.pairList, just like elements
+func (p *BacktrackingParser) pairs() {
+	defer p.enter("pairs")()
+	p.pairList = append(p.pairList, p.pair())
+	for p.Peek(1).Type == Comma {
+		p.predict("pairs", "','", 1)
+		comma := p.match(Comma)
+		if p.AllowTrailingComma && p.Peek(1).Type == RBrace {
+			p.predict("pairs", "trailing ','", 1)
+			p.warn(comma, CodeTrailingComma, "trailing comma in map")
+			break
+		}
+		p.pairList = append(p.pairList, p.pair())
 	}
+	p.predict("pairs", "end", 1)
 }
 
-func (p *BacktrackingParser) fill(n int) {
-	for range n {
-		tok, err := p.input.Next()
-		if err != nil {
-			panic(fmt.Errorf("fill: error reading next token: %w", err))
+func (p *BacktrackingParser) pair() *PairNode {
+	defer p.enter("pair")()
+	key := p.match(Name)
+	p.match(Colon)
+	value := p.recoverElement()
+	return p.Arena.newPair(p.Arena.newName(key), value)
+}
+
+// recoverStat parses a statement. With RecoverErrors a syntax error makes the
+// whole statement an ErrorNode, unless it was already recovered from inside
+// one of its elements.
+func (p *BacktrackingParser) recoverStat() Node {
+	p.consumed = p.consumed[:0]
+	if !p.RecoverErrors {
+		return p.stat()
+	}
+	n := p.recoverFrom(p.stat, 0)
+	p.nesting = 0
+	return n
+}
+
+// recoverElement parses an element. With RecoverErrors a syntax error makes
+// it an ErrorNode that goes up to the next ',' or closing bracket or brace of
+// the list or map it's in. Nothing is recovered while speculating, a failed
+// speculation has to fail.
+func (p *BacktrackingParser) recoverElement() Node {
+	if !p.RecoverErrors || p.Speculating() {
+		return p.element()
+	}
+	return p.recoverFrom(p.element, p.nesting, Comma, RBrack, RBrace)
+}
+
+// recoverFrom calls rule, turning a syntax error into an ErrorNode holding the
+// tokens the rule consumed and the ones skipped after the error. Lexer errors
+// aren't recovered from since the lexer stops after them.
+func (p *BacktrackingParser) recoverFrom(rule func() Node, nesting int, stop ...TokenType) (n Node) {
+	from, start := p.Peek(1).Pos, len(p.consumed)
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		err, ok := r.(error)
+		if !ok || !errors.Is(err, SyntaxError) {
+			panic(r)
+		}
+		p.Errors = append(p.Errors, err)
+		p.repair(err)
+		p.tooManyErrors()
+		p.skip(nesting, stop...)
+		n = &ErrorNode{From: from, Tokens: slices.Clone(p.consumed[start:]), Err: err}
+	}()
+	return rule()
+}
+
+// skip consumes tokens up to the end of the statement, or up to one of the
+// stop tokens that isn't nested deeper than nesting.
+func (p *BacktrackingParser) skip(nesting int, stop ...TokenType) {
+	for {
+		tok := p.Peek(1)
+		if tok.Type == EOF || isSeparator(tok.Type) {
+			return
+		}
+		if p.nesting <= nesting && slices.Contains(stop, tok.Type) {
+			return
 		}
-		p.lookahead = append(p.lookahead, tok)
+		p.Consume()
 	}
 }
 
-// peek returns the nth next Token in the lookahead buffer.
-func (p *BacktrackingParser) peek(n int) Token {
-	p.sync(n)
-	index := p.pos + n - 1
-	if index == len(p.lookahead) {
-		index = 0
+// warn records a warning diagnostic with its code. Nothing is recorded while
+// speculating: a successful speculation is always followed by parsing the
+// same tokens again for real, so we'd report every warning twice.
+func (p *BacktrackingParser) warn(tok Token, code, msg string) {
+	if p.Speculating() {
+		return
+	}
+	d := diagnose(tok, SeverityWarning, code, msg)
+	if code == CodeTrailingComma {
+		d.Fixes = []Fix{remove(tok)}
 	}
-	return p.lookahead[index]
+	p.Warnings = append(p.Warnings, d)
 }
 
-// match checks if the current lookahead token if of the type we're looking for.
-// Goes to the next token if it is or reports an error if it isn't.
-func (p *BacktrackingParser) match(typ TokenType) {
-	// log.Printf("lookahead buf: %v, position: %d, want to match: %s", p.lookahead, p.pos, typ)
-	tok := p.peek(1)
-	// log.Printf("peeked: %v", tok)
-	if tok.Type == typ {
-		// go to next token
-		p.consume()
-	} else {
-		err := fmt.Errorf("match: %w: expecting %v, got %v", SyntaxError, typ, tok.Type)
-		panic(err)
+// mismatch is the error of match when the next token isn't of type want.
+func mismatch(want TokenType, got Token) error {
+	return &Error{
+		Pos:  got.Pos,
+		Err:  fmt.Errorf("match: %w: expecting %v, got %v", SyntaxError, want, got.Type),
+		want: []TokenType{want},
 	}
 }
 
-func (p *BacktrackingParser) consume() {
-	p.pos++
-	// we're not speculating and we've hit the end of the lookahead buffer?
-	if !p.isSpeculating() && p.pos == len(p.lookahead) {
-		p.pos = 0
-		p.lookahead = p.lookahead[:0] // reset lookahead buffer
+// fail stops the parse with err, panicking: a syntax error, or an error of
+// the lexer reading the next token.
+func fail(err error) {
+	if !errors.Is(err, SyntaxError) {
+		err = fmt.Errorf("fill: error reading next token: %w", err)
+	}
+	panic(err)
+}
+
+// match matches the next token and returns it, so that rules can keep it in
+// the tree.
+func (p *BacktrackingParser) match(typ TokenType) Token {
+	tok := p.Match(typ)
+	p.leaf(tok)
+	return tok
+}
+
+// track keeps track of the tokens consumed for real, for error recovery and
+// repair, and forgets the memo, speculating only starts again past the lists
+// in it.
+func (p *BacktrackingParser) track(tok Token) {
+	switch tok.Type {
+	case LBrack, LBrace:
+		p.nesting++
+	case RBrack, RBrace:
+		p.nesting--
+	}
+	if p.RecoverErrors {
+		p.consumed = append(p.consumed, tok)
 	}
-	p.sync(1)
+	p.last = tok
+	p.clearMemo(tok)
 }
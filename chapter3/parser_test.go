@@ -1,9 +1,14 @@
-package main
+package backtracking
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
+	"example.com/parsing"
+	"github.com/google/go-cmp/cmp"
 	"golang.org/x/tools/txtar"
 )
 
@@ -33,7 +38,7 @@ func TestParserGoodInput(t *testing.T) {
 						t.Errorf("got error on parse string: %q, error: %q", testcase, err)
 					}
 				}()
-				parser.stat()
+				parser.program()
 			})
 		}
 	}
@@ -54,7 +59,7 @@ func TestParserBadInput(t *testing.T) {
 			}
 
 			t.Run(file.Name, func(t *testing.T) {
-				testcase := string(line)
+				testcase, want := splitWant(string(line))
 				t.Logf("parse string: %q\n", testcase)
 
 				lexer := NewLexer(testcase)
@@ -63,10 +68,228 @@ func TestParserBadInput(t *testing.T) {
 					err := recover()
 					if err == nil {
 						t.Errorf("want error on parse string: %q, got none", testcase)
+					} else if want != "" && fmt.Sprint(err) != want {
+						t.Errorf("parse string: %q, want: %s, got: %v", testcase, want, err)
 					}
 				}()
-				parser.stat()
+				parser.program()
 			})
 		}
 	}
 }
+
+func TestParserTrailingComma(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		warnings int
+	}{
+		{name: "no trailing comma", input: "[a,b]", warnings: 0},
+		{name: "trailing comma", input: "[a,b,]", warnings: 1},
+		{name: "trailing comma in sublists", input: "[a,[b,],[c,],]", warnings: 3},
+		{name: "trailing comma in assignment", input: "[a,b,]=[c,d,]", warnings: 2},
+		{name: "trailing comma in map", input: "[{a: b, c: d,}]", warnings: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lexer := NewLexer(tc.input)
+			parser := NewBacktrackingParser(lexer)
+			parser.AllowTrailingComma = true
+			defer func() {
+				err := recover()
+				if err != nil {
+					t.Fatalf("got error on parse string: %q, error: %q", tc.input, err)
+				}
+				if len(parser.Warnings) != tc.warnings {
+					t.Errorf("want %d warnings, got: %v", tc.warnings, parser.Warnings)
+				}
+			}()
+			parser.program()
+		})
+	}
+
+	t.Run("not allowed by default", func(t *testing.T) {
+		parser := NewBacktrackingParser(NewLexer("[a,b,]"))
+		defer func() {
+			err := recover()
+			if err == nil {
+				t.Error("want error on trailing comma, got none")
+			}
+		}()
+		parser.program()
+	})
+
+	t.Run("still requires an element", func(t *testing.T) {
+		parser := NewBacktrackingParser(NewLexer("[,]"))
+		parser.AllowTrailingComma = true
+		defer func() {
+			err := recover()
+			if err == nil {
+				t.Error("want error on lone comma, got none")
+			}
+		}()
+		parser.program()
+	})
+}
+
+func TestParserProgram(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		stats int
+	}{
+		{name: "empty input", input: "", stats: 0},
+		{name: "blank lines", input: "\n\n", stats: 0},
+		{name: "one per line", input: "[a]\n[b]=[c]\n", stats: 2},
+		{name: "blank lines between", input: "[a]\n\n\n[b]", stats: 2},
+		{name: "list spanning lines", input: "[a,\n  b,\n  c\n]\n[d]", stats: 2},
+		{name: "map spanning lines", input: "[{\n  a: b,\n  c: d\n}]", stats: 1},
+		{name: "lines and semicolons", input: "[a]; [b]\n[c]", stats: 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			defer func() {
+				err := recover()
+				if err != nil {
+					t.Errorf("got error on parse string: %q, error: %q", tc.input, err)
+				}
+			}()
+			program := parser.program()
+			if len(program.Stats) != tc.stats {
+				t.Errorf("want %d statements, got: %d", tc.stats, len(program.Stats))
+			}
+		})
+	}
+}
+
+func TestParserRecoverErrors(t *testing.T) {
+	cases := []struct {
+		input  string
+		want   string // homogeneous tree
+		errors int
+	}{
+		{input: "[a,b]=[c]", want: "(PROGRAM (= (LIST a b) (LIST c)))", errors: 0},
+		{input: "[a,,b]", want: "(PROGRAM (LIST a (ERROR) b))", errors: 1},
+		{input: "[a,]", want: "(PROGRAM (LIST a (ERROR)))", errors: 1},
+		{input: "[a, [b c], d]", want: "(PROGRAM (LIST a (ERROR [ b c ]) d))", errors: 1},
+		{input: "[a=, b=[c:]]", want: "(PROGRAM (LIST (= a (ERROR)) (= b (ERROR [ c : ]))))", errors: 2},
+		{input: "[{a: }, b]", want: "(PROGRAM (LIST (MAP (PAIR a (ERROR))) b))", errors: 1},
+		{input: "[{a b}, c]", want: "(PROGRAM (LIST (ERROR { a b }) c))", errors: 1},
+		{input: "[a]=[b,]", want: "(PROGRAM (= (LIST a) (LIST b (ERROR))))", errors: 1},
+		{input: "[a]=]\n[b]", want: "(PROGRAM (ERROR [ a ] = ]) (LIST b))", errors: 1},
+		{input: "[a]; b; [c]", want: "(PROGRAM (LIST a) (ERROR b) (LIST c))", errors: 1},
+		{input: "[a b; [c,]", want: "(PROGRAM (ERROR [ a b) (LIST c (ERROR)))", errors: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			p := NewBacktrackingParser(NewLexer(tc.input))
+			p.RecoverErrors = true
+			prog, err := p.Parse()
+			if prog == nil {
+				t.Fatalf("want: tree, got: nil and error %v", err)
+			}
+			if got := ToAST(prog).String(); got != tc.want {
+				t.Errorf("want: %s, got: %s", tc.want, got)
+			}
+			if len(p.Errors) != tc.errors {
+				t.Errorf("want: %d errors, got: %q", tc.errors, p.Errors)
+			}
+			if tc.errors > 0 && !errors.Is(err, SyntaxError) {
+				t.Errorf("want: syntax error, got: %v", err)
+			}
+			if tc.errors == 0 && err != nil {
+				t.Errorf("want: no error, got: %v", err)
+			}
+		})
+	}
+
+	t.Run("lexer errors", func(t *testing.T) {
+		// recovering is from syntax errors, a lexer error stops the parse
+		// even inside a list that's recovered from
+		cases := []struct {
+			src  string
+			opts []ParserOption
+			want string
+		}{
+			{"[a,$]", nil, "fill: error reading next token: 1:4: non-letter character: $"},
+			{"[1.]", nil, "fill: error reading next token: 1:2: malformed number: 1."},
+			{"[a,b]=[1.]", nil, "fill: error reading next token: 1:8: malformed number: 1."},
+			{"[a,,b]=[1.]", nil, "fill: error reading next token: 1:9: malformed number: 1."},
+			{`[a]; ["\q"]`, []ParserOption{WithMaxErrors(3)}, "fill: error reading next token: 1:7: invalid escape sequence in string: \\q"},
+		}
+		for _, tc := range cases {
+			p := NewBacktrackingParser(NewLexer(tc.src), tc.opts...)
+			p.RecoverErrors = true
+			prog, err := p.Parse()
+			if prog != nil || err == nil || err.Error() != tc.want {
+				t.Errorf("%q: want: %s and no tree, got: %v, %v", tc.src, tc.want, prog, err)
+			}
+		}
+	})
+
+	t.Run("error node", func(t *testing.T) {
+		p := NewBacktrackingParser(NewLexer("[a, [b 'c d']]"))
+		p.RecoverErrors = true
+		prog, _ := p.Parse()
+		n := prog.Stats[0].(*ListNode).Elements[1].(*ErrorNode)
+		if got, want := n.String(), "[ b 'c d' ]"; got != want {
+			t.Errorf("want: %s, got: %s", want, got)
+		}
+		if got, want := n.Pos(), (Pos{Line: 1, Col: 5}); got != want {
+			t.Errorf("want: %v, got: %v", want, got)
+		}
+		if got, want := n.Err.Error(), "1:8: match: syntax error: expecting RBrack, got Name"; got != want {
+			t.Errorf("want: %s, got: %s", want, got)
+		}
+	})
+}
+
+// TestParseConcurrently parses the corpora in many goroutines at once, with
+// the options that keep state along the way, for the race detector to check
+// that the parsers share nothing: go test -race
+func TestParseConcurrently(t *testing.T) {
+	progs := append(corpus(t, "good.txt"), corpus(t, "bad.txt")...)
+	type result struct {
+		prog *ProgramNode
+		err  string
+	}
+	want := make([]result, len(progs))
+	for i, src := range progs {
+		prog, err := Parse(src)
+		want[i] = result{prog, fmt.Sprint(err)}
+	}
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			arena := &Arena{}
+			for i, src := range progs {
+				p := NewBacktrackingParser(NewLexer(src))
+				p.Memoize = g%2 == 0
+				p.Arena = arena
+				p.BuildParseTree = true
+				prog, err := p.Parse()
+				if got := (result{prog, fmt.Sprint(err)}); !cmp.Equal(got, want[i], cmp.AllowUnexported(result{})) {
+					t.Errorf("goroutine %d, %q: %s", g, src, cmp.Diff(want[i], got, cmp.AllowUnexported(result{})))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParseOnce(t *testing.T) {
+	p := NewBacktrackingParser(NewLexer("[a]"))
+	if _, err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Parse(); !errors.Is(err, parsing.ErrReused) {
+		t.Errorf("parsing again: want: %v, got: %v", parsing.ErrReused, err)
+	}
+}
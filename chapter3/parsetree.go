@@ -0,0 +1,88 @@
+package backtracking
+
+import "strings"
+
+// Pattern 8:
+// Parse Tree
+
+// A parse tree records how the parser recognized the input: one interior node
+// per rule invocation and one leaf per matched token, punctuation included.
+// It's a trace of the derivation more than a useful data structure, which is
+// why the parser builds ASTs, but it's handy to see what the grammar did.
+//
+// For `[a]` the tree is:
+//
+//	(program (stat (list [ (elements (element a)) ])) <EOF>)
+
+// ParseTree is a node of a parse tree. Rule nodes have a Rule and children,
+// token nodes only have a Token.
+type ParseTree struct {
+	Rule     string
+	Token    Token
+	Children []*ParseTree
+}
+
+// IsRule tells whether the node is a rule invocation rather than a token.
+func (t *ParseTree) IsRule() bool {
+	return t.Rule != ""
+}
+
+// String renders the tree as an S-expression.
+func (t *ParseTree) String() string {
+	if !t.IsRule() {
+		return t.text()
+	}
+	var s strings.Builder
+	s.WriteString("(")
+	s.WriteString(t.Rule)
+	for _, c := range t.Children {
+		s.WriteString(" ")
+		s.WriteString(c.String())
+	}
+	s.WriteString(")")
+	return s.String()
+}
+
+// text is how a token node shows up, tokens without text get a name
+func (t *ParseTree) text() string {
+	switch t.Token.Type {
+	case EOF:
+		return "<EOF>"
+	case Newline:
+		return "<NEWLINE>"
+	case Name:
+		return (&NameNode{Token: t.Token}).String()
+	default:
+		return t.Token.Text
+	}
+}
+
+// enter records the invocation of a rule in the parse tree, the returned
+// function must be called when the rule returns:
+//
+//	defer p.enter("list")()
+//
+// Nothing is recorded while speculating, the rule is invoked again once the
+// parser knows which alternative to take.
+func (p *BacktrackingParser) enter(rule string) func() {
+	if !p.BuildParseTree || p.Speculating() {
+		return func() {}
+	}
+	t := &ParseTree{Rule: rule}
+	parent := p.current
+	if parent == nil {
+		p.ParseTree = t
+	} else {
+		parent.Children = append(parent.Children, t)
+	}
+	p.current = t
+	return func() { p.current = parent }
+}
+
+// leaf records a matched token in the parse tree.
+func (p *BacktrackingParser) leaf(tok Token) {
+	if !p.BuildParseTree || p.Speculating() || p.current == nil {
+		return
+	}
+	p.current.Children = append(p.current.Children, &ParseTree{Token: tok})
+}
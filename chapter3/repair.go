@@ -0,0 +1,106 @@
+package backtracking
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Quick fixes
+//
+// Recovering from a syntax error skips the bad input, which gets the parser
+// going but tells nobody how to fix the program. Most syntax errors are a
+// token too many or a token missing though, which the tokens around the
+// error tell, as ANTLR's single-token deletion and insertion do:
+//
+//   - a token is in the way if the one after it is what's expected: remove
+//     ',' in `[a,,b]`
+//   - a token is missing if what's expected is a single punctuation token,
+//     and the token found can follow it: insert ']' in `[a,b`, insert ':' in
+//     `{k v}`
+//   - a comma before a closing bracket or brace is one too many, a trailing
+//     comma: remove ',' in `[a,]`
+//
+// The fixes are suggestions: each makes the error go away, not necessarily
+// the program right, as inserting ']' in `[a, b c]` does. They're carried by
+// the diagnostics, warnings about trailing commas included, for an editor to
+// offer them, see WriteDiagnosticsJSON.
+
+// Fix is an edit of the input suggested to fix a diagnostic: the text from
+// From up to To is replaced by Text, which inserts it if From and To are the
+// same and removes what's there if Text is empty.
+type Fix struct {
+	Title    string // what the fix does, such as "insert ']'"
+	From, To Pos
+	Text     string
+}
+
+// punctuation is the text of the tokens that are always the same, the ones a
+// fix can insert or remove.
+var punctuation = map[TokenType]string{
+	LBrack:    "[",
+	RBrack:    "]",
+	Comma:     ",",
+	Equals:    "=",
+	LBrace:    "{",
+	RBrace:    "}",
+	Colon:     ":",
+	Semicolon: ";",
+}
+
+// elementStarts are the tokens an element can start with.
+var elementStarts = []TokenType{Name, LBrack, LBrace, Int, Float, String, True, False}
+
+// follows has, for the tokens a fix can insert, the tokens that can follow
+// them: inserting one is only suggested before one of those.
+var follows = map[TokenType][]TokenType{
+	RBrack:    {Comma, RBrack, RBrace, Equals, Semicolon, Newline, EOF},
+	RBrace:    {Comma, RBrack, RBrace, Semicolon, Newline, EOF},
+	Colon:     elementStarts,
+	Equals:    elementStarts,
+	Comma:     elementStarts,
+	Semicolon: {LBrack},
+}
+
+// insert returns the fix inserting a token of type typ at pos.
+func insert(typ TokenType, pos Pos) Fix {
+	text := punctuation[typ]
+	return Fix{Title: fmt.Sprintf("insert %q", text), From: pos, To: pos, Text: text}
+}
+
+// remove returns the fix removing tok, which is punctuation.
+func remove(tok Token) Fix {
+	to := tok.Pos
+	to.Col += len(punctuation[tok.Type])
+	return Fix{Title: fmt.Sprintf("remove %q", tok.Text), From: tok.Pos, To: to}
+}
+
+// repair suggests the fixes for err, a syntax error found at the next token,
+// as the parser stands when it recovers from it: removing the token if that
+// works, the surer fix, and inserting one if not. Looking at the token after
+// the next one can get to an error of the lexer, which panics as it does
+// when the parser gets to it.
+func (p *BacktrackingParser) repair(err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		return
+	}
+	tok := p.Peek(1)
+	if tok.Pos != e.Pos {
+		return
+	}
+	switch _, punct := punctuation[tok.Type]; {
+	case (tok.Type == RBrack || tok.Type == RBrace) && p.last.Type == Comma:
+		e.fixes = []Fix{remove(p.last)}
+	case punct && slices.Contains(e.want, p.Peek(2).Type):
+		e.fixes = []Fix{remove(tok)}
+	case len(e.want) == 1 && slices.Contains(follows[e.want[0]], tok.Type):
+		e.fixes = []Fix{insert(e.want[0], tok.Pos)}
+	}
+}
+
+// repairFatal suggests the fixes for the error the parse stopped at, as
+// repair does, giving up on an error of the lexer: the parse is over.
+func (p *BacktrackingParser) repairFatal(err error) {
+	defer func() { recover() }()
+	p.repair(err)
+}
@@ -0,0 +1,54 @@
+package backtracking
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRepair(t *testing.T) {
+	cases := []struct {
+		src      string
+		recover  bool
+		trailing bool
+		want     []string // the fixes of each diagnostic with some
+	}{
+		{src: "[a,,b]", recover: true, want: []string{`1:4: remove "," 1:4-1:5 ""`}},
+		{src: "[,a]", recover: true, want: []string{`1:2: remove "," 1:2-1:3 ""`}},
+		{src: "[a,b", recover: true, want: []string{`1:5: insert "]" 1:5-1:5 "]"`}},
+		{src: "[{k v}]", recover: true, want: []string{`1:5: insert ":" 1:5-1:5 ":"`}},
+		{src: "[a;]", recover: true, want: []string{`1:3: remove ";" 1:3-1:4 ""`}},
+		{src: "[a,]", recover: true, want: []string{`1:4: remove "," 1:3-1:4 ""`}},
+		{src: "[{k: v,}]", recover: true, want: []string{`1:8: remove "," 1:7-1:8 ""`}},
+		{src: "[a] [b]", recover: true, want: []string{`1:5: insert ";" 1:5-1:5 ";"`}},
+		// the statement isn't parsed for real without recovering, the
+		// error is where it starts
+		{src: "[a,,b]"},
+		{src: "[a]=[b] [c]"},
+		// nothing to insert or remove
+		{src: "[c d]", recover: true},
+		{src: "[a [b]]", recover: true},
+		{src: "[a,,%]", recover: true},
+		// the warnings have fixes too
+		{src: "[a, {k: v,},]", trailing: true, want: []string{`1:10: remove "," 1:10-1:11 ""`, `1:12: remove "," 1:12-1:13 ""`}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.src, func(t *testing.T) {
+			p := NewBacktrackingParser(NewLexer(tc.src))
+			p.RecoverErrors = tc.recover
+			p.AllowTrailingComma = tc.trailing
+			p.Parse()
+			var got []string
+			for _, d := range p.Diagnostics() {
+				for _, f := range d.Fixes {
+					got = append(got, fmt.Sprintf("%v: %s %v-%v %q", d.Pos, f.Title, f.From, f.To, f.Text))
+				}
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Errorf("%v\n%s", p.Diagnostics(), cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package backtracking
+
+import (
+	"fmt"
+	"strings"
+)
+
+// S-expressions
+//
+// Trees are written down as S-expressions all over the book: a node is its
+// kind followed by its children, in parentheses, and a leaf is just its text.
+// The statement `[a,b=c]=[{d: 1}]` is the tree
+//
+//	(assign (list a (assign b c)) (list (map (pair d 1))))
+//
+// The kinds are program, list, assign, map and pair, the same heads used by
+// tree patterns (see treematch.go). Leaves are written as in the list
+// language, so names are quoted when needed and strings keep their escapes.
+// FormatSExpr writes a tree this way and ParseSExpr reads it back, which
+// makes expected trees in tests much shorter than building them by hand.
+
+// Grammar of S-expressions (ANTLR syntax):
+//
+// sexpr : '(' HEAD sexpr* ')'
+//       | NAME | INT | FLOAT | STRING | 'true' | 'false'
+//       ;
+// HEAD  : 'program' | 'list' | 'assign' | 'map' | 'pair' ;
+
+// FormatSExpr renders the tree rooted at n as an S-expression.
+func FormatSExpr(n Node) string {
+	var s strings.Builder
+	writeSExpr(&s, n)
+	return s.String()
+}
+
+func writeSExpr(s *strings.Builder, n Node) {
+	h := head(n)
+	if h == "" {
+		s.WriteString(n.String())
+		return
+	}
+	s.WriteString("(")
+	s.WriteString(h)
+	for _, c := range children(n) {
+		s.WriteString(" ")
+		writeSExpr(s, c)
+	}
+	s.WriteString(")")
+}
+
+// ParseSExpr reads a tree written as an S-expression. The tree must have the
+// same shape the parser builds: an assign has two children, a map only has
+// pairs and a pair's key is a name.
+func ParseSExpr(src string) (n Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	r := &sexprReader{input: NewLexer(src)}
+	r.consume()
+	n = r.sexpr()
+	r.match(EOF)
+	return n, nil
+}
+
+// sexprReader is an LL(1) recursive-descent parser for S-expressions. It uses
+// the lexer of the list language, so atoms are lexed exactly like the leaves
+// of the tree. Like the BacktrackingParser it panics on the first error.
+type sexprReader struct {
+	input     *Lexer
+	lookahead Token
+}
+
+func (r *sexprReader) sexpr() Node {
+	tok := r.lookahead
+	switch {
+	case tok.Type == LParen:
+		return r.tree()
+	case tok.Type == Name:
+		r.consume()
+		return &NameNode{Token: tok}
+	case isLiteral(tok.Type):
+		r.consume()
+		n, err := literalNode(nil, tok)
+		if err != nil {
+			panic(err)
+		}
+		return n
+	default:
+		panic(fmt.Errorf("%w: expecting '(' or a leaf, found %v", SyntaxError, tok.Type))
+	}
+}
+
+func (r *sexprReader) tree() Node {
+	lparen := r.match(LParen)
+	h := r.match(Name)
+	var kids []Node
+	for r.lookahead.Type != RParen && r.lookahead.Type != EOF {
+		kids = append(kids, r.sexpr())
+	}
+	r.match(RParen)
+
+	switch heads[h.Text] {
+	case "program":
+		return &ProgramNode{Stats: kids}
+	case "list":
+		return &ListNode{Lbrack: lparen.Pos, Elements: kids}
+	case "assign":
+		if len(kids) != 2 {
+			panic(fmt.Errorf("%w: assign needs 2 children, found %d", SyntaxError, len(kids)))
+		}
+		return &AssignNode{Left: kids[0], Right: kids[1]}
+	case "map":
+		n := &MapNode{Lbrace: lparen.Pos}
+		for _, k := range kids {
+			pair, ok := k.(*PairNode)
+			if !ok {
+				panic(fmt.Errorf("%w: map can only have pairs, found %s", SyntaxError, k))
+			}
+			n.Pairs = append(n.Pairs, pair)
+		}
+		return n
+	case "pair":
+		if len(kids) != 2 {
+			panic(fmt.Errorf("%w: pair needs 2 children, found %d", SyntaxError, len(kids)))
+		}
+		key, ok := kids[0].(*NameNode)
+		if !ok {
+			panic(fmt.Errorf("%w: pair key must be a name, found %s", SyntaxError, kids[0]))
+		}
+		return &PairNode{Key: key, Value: kids[1]}
+	default:
+		panic(fmt.Errorf("%w: unknown node kind %q", SyntaxError, h.Text))
+	}
+}
+
+func (r *sexprReader) match(typ TokenType) Token {
+	tok := r.lookahead
+	if tok.Type != typ {
+		panic(fmt.Errorf("%w: expecting %v, found %v", SyntaxError, typ, tok.Type))
+	}
+	r.consume()
+	return tok
+}
+
+// consume goes to the next token, skipping newlines: the lexer only emits
+// them outside of parentheses, where they're just whitespace to us.
+func (r *sexprReader) consume() {
+	for {
+		tok, err := r.input.Next()
+		if err != nil {
+			panic(err)
+		}
+		if tok.Type != Newline {
+			r.lookahead = tok
+			return
+		}
+	}
+}
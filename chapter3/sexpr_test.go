@@ -0,0 +1,101 @@
+package backtracking
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+func TestFormatSExpr(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: "[]", want: "(program (list))"},
+		{input: "[a,b=c]=[{d: 1}]", want: "(program (assign (list a (assign b c)) (list (map (pair d 1)))))"},
+		{input: `['a b', "c d", 2.5, true]`, want: `(program (list 'a b' "c d" 2.5 true))`},
+		{input: "[a];[b]", want: "(program (list a) (list b))"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			got := FormatSExpr(parser.program())
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSExpr(t *testing.T) {
+	got, err := ParseSExpr("(list a\n  (assign b c)\n  (map (pair d \"e\")))\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ListNode{Elements: []Node{
+		name("a"),
+		&AssignNode{Left: name("b"), Right: name("c")},
+		&MapNode{Pairs: []*PairNode{
+			{Key: name("d"), Value: &StringNode{Token: Token{Type: String, Text: `"e"`}, Value: "e"}},
+		}},
+	}}
+	if !cmp.Equal(got, want, ignorePos) {
+		t.Error(cmp.Diff(got, want, ignorePos))
+	}
+}
+
+// Every program in testdata/good.txt must come back the same after being
+// written as an S-expression and read again.
+func TestSExprRoundTrip(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/good.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range ar.Files {
+		for _, line := range bytes.Split(file.Data, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			t.Run(file.Name, func(t *testing.T) {
+				prog, err := NewBacktrackingParser(NewLexer(string(line))).Parse()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ParseSExpr(FormatSExpr(prog))
+				if err != nil {
+					t.Fatalf("%s: %v", FormatSExpr(prog), err)
+				}
+				if !cmp.Equal(got, Node(prog), ignorePos) {
+					t.Error(cmp.Diff(got, Node(prog), ignorePos))
+				}
+			})
+		}
+	}
+}
+
+func TestParseSExprErrors(t *testing.T) {
+	cases := []string{
+		"",
+		"(",
+		"(list a",
+		"(tuple a)",
+		"(assign a)",
+		"(map a)",
+		"(pair [a] b)",
+		"(pair 1 b)",
+		"(list) (list)",
+		"[a]",
+	}
+
+	for _, src := range cases {
+		t.Run(src, func(t *testing.T) {
+			if _, err := ParseSExpr(src); err == nil {
+				t.Error("want: error, got: nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package backtracking
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Decision trace
+//
+// The parser's control flow is hard to follow from the outside: it looks
+// ahead to pick an alternative, and where looking ahead isn't enough it
+// speculates, parsing an alternative only to backtrack. With TraceDecisions
+// the parser records each decision it makes as it goes, which alternative it
+// took and, for the ones made speculating, which alternatives it tried, how
+// far each went and why it failed. The decisions made while speculating are
+// kept with the speculation, so the trace is a tree: for `[a]=[b]`
+//
+//	1:1 program: stat, looking at [
+//	1:1 stat, speculating: assign
+//	  list 1:1 to 1:4: 1:4: syntax error: expecting end of statement, found Equals
+//	    1:2 list: elements, looking at a
+//	    1:2 element: NAME, looking at a ]
+//	    1:3 elements: end, looking at ]
+//	  assign 1:1 to 1:8
+//	    1:2 list: elements, looking at a
+//	    ...
+//	1:2 list: elements, looking at a
+//	...
+//
+// The speculation of a list fails once it's seen all of `[a]`, the
+// assignment is speculated, and then parsed again for real: that's the cost
+// of backtracking, each list of the statement parsed three times.
+// WriteTrace writes the trace as above, WriteTraceDOT as a Graphviz graph
+// and WriteTraceHTML as a page where the speculations fold.
+
+// Decision is a decision made by a rule. A prediction looks at the tokens
+// in Lookahead, a speculative decision tries the alternatives in Tries. Alt is
+// the alternative taken, empty if none could be: a syntax error.
+type Decision struct {
+	Rule      string
+	Pos       Pos
+	Lookahead []Token
+	Tries     []*Speculation
+	Alt       string
+}
+
+// Speculation is an alternative parsed speculatively from From. To is where
+// it got, Err why it failed or nil if it didn't, and Decisions the decisions
+// made on the way.
+type Speculation struct {
+	Alt       string
+	From, To  Pos
+	Err       error
+	Decisions []*Decision
+}
+
+// predict records that rule took alt looking at the next k tokens.
+func (p *BacktrackingParser) predict(rule, alt string, k int) {
+	if !p.TraceDecisions {
+		return
+	}
+	d := &Decision{Rule: rule, Pos: p.Peek(1).Pos, Alt: alt}
+	for i := 1; i <= k; i++ {
+		d.Lookahead = append(d.Lookahead, p.Peek(i))
+	}
+	p.record(d)
+}
+
+// speculative records a decision of rule made by speculating, returning it
+// for try, tried and took to fill in. It's nil if decisions aren't traced.
+func (p *BacktrackingParser) speculative(rule string) *Decision {
+	if !p.TraceDecisions {
+		return nil
+	}
+	d := &Decision{Rule: rule, Pos: p.Peek(1).Pos}
+	p.record(d)
+	return d
+}
+
+// record adds d to the speculation going on, or to Decisions if there's
+// none.
+func (p *BacktrackingParser) record(d *Decision) {
+	if n := len(p.tries); n > 0 {
+		p.tries[n-1].Decisions = append(p.tries[n-1].Decisions, d)
+		return
+	}
+	p.Decisions = append(p.Decisions, d)
+}
+
+// try records the speculation of alt for d, until tried.
+func (p *BacktrackingParser) try(d *Decision, alt string) {
+	if d == nil {
+		return
+	}
+	s := &Speculation{Alt: alt, From: p.Peek(1).Pos}
+	d.Tries = append(d.Tries, s)
+	p.tries = append(p.tries, s)
+}
+
+// tried records the end of the last speculation, with what it panicked
+// with. It has to be called before backtracking, to know where it got.
+func (p *BacktrackingParser) tried(d *Decision, r any) {
+	if d == nil {
+		return
+	}
+	s := p.tries[len(p.tries)-1]
+	p.tries = p.tries[:len(p.tries)-1]
+	s.To = p.Peek(1).Pos
+	if r != nil {
+		err, ok := r.(error)
+		if !ok {
+			err = fmt.Errorf("%v", r)
+		}
+		s.Err = err
+	}
+}
+
+// took records the alternative d took.
+func (p *BacktrackingParser) took(d *Decision, alt string) {
+	if d != nil {
+		d.Alt = alt
+	}
+}
+
+// String describes the decision on one line, without the speculations.
+func (d *Decision) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%v %s", d.Pos, d.Rule)
+	if d.Tries != nil {
+		s.WriteString(", speculating")
+	}
+	s.WriteString(": ")
+	if d.Alt == "" {
+		s.WriteString("no alternative")
+	} else {
+		s.WriteString(d.Alt)
+	}
+	if d.Lookahead != nil {
+		s.WriteString(", looking at ")
+		for i, tok := range d.Lookahead {
+			if i > 0 {
+				s.WriteString(" ")
+			}
+			s.WriteString(tokenText(tok))
+		}
+	}
+	return s.String()
+}
+
+// String describes the speculation on one line, without its decisions.
+func (s *Speculation) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("%s %v to %v: %v", s.Alt, s.From, s.To, s.Err)
+	}
+	return fmt.Sprintf("%s %v to %v", s.Alt, s.From, s.To)
+}
+
+// tokenText is how a token shows up in a trace, as in a parse tree.
+func tokenText(tok Token) string {
+	return (&ParseTree{Token: tok}).text()
+}
+
+// WriteTrace writes the decisions one per line, the speculations indented
+// under their decision and the decisions made speculating under them.
+func WriteTrace(w io.Writer, decisions []*Decision) error {
+	bw := bufio.NewWriter(w)
+	var write func(ds []*Decision, indent string)
+	write = func(ds []*Decision, indent string) {
+		for _, d := range ds {
+			fmt.Fprintf(bw, "%s%v\n", indent, d)
+			for _, s := range d.Tries {
+				fmt.Fprintf(bw, "%s  %v\n", indent, s)
+				write(s.Decisions, indent+"    ")
+			}
+		}
+	}
+	write(decisions, "")
+	return bw.Flush()
+}
+
+// WriteTraceDOT writes the decisions as a Graphviz graph. The decisions are
+// boxes chained in the order they're made, each speculative one pointing to
+// the speculations it tried, green if they succeeded and red if they
+// failed, which point to the chain of the decisions made speculating.
+func WriteTraceDOT(w io.Writer, decisions []*Decision) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph trace {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=\"monospace\"];")
+	n := 0
+	// chain writes the decisions, returning the number of the first one
+	var chain func(ds []*Decision) int
+	chain = func(ds []*Decision) int {
+		first, prev := n, -1
+		for _, d := range ds {
+			id := n
+			n++
+			fmt.Fprintf(bw, "\tn%d [label=\"%s\"];\n", id, dotEscape(d.String()))
+			if prev >= 0 {
+				fmt.Fprintf(bw, "\tn%d -> n%d [style=dashed];\n", prev, id)
+			}
+			prev = id
+			for _, s := range d.Tries {
+				sid := n
+				n++
+				color := "green"
+				if s.Err != nil {
+					color = "red"
+				}
+				fmt.Fprintf(bw, "\tn%d [label=\"%s\", shape=ellipse, color=%s];\n", sid, dotEscape(s.String()), color)
+				fmt.Fprintf(bw, "\tn%d -> n%d;\n", id, sid)
+				if len(s.Decisions) > 0 {
+					fmt.Fprintf(bw, "\tn%d -> n%d;\n", sid, chain(s.Decisions))
+				}
+			}
+		}
+		return first
+	}
+	chain(decisions)
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// traceHTML shows the decisions as nested lists, the speculations folded
+// under their decision, failed ones in red.
+var traceHTML = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Parse decisions</title>
+<style>
+body { font-family: monospace; }
+ul { list-style: none; padding-left: 1.5em; }
+.failed { color: #b00; }
+.ok { color: #080; }
+</style>
+</head>
+<body>
+{{template "decisions" .}}
+</body>
+</html>
+{{define "decisions"}}<ul>
+{{range .}}<li>{{if .Tries}}<details open><summary>{{.}}</summary>
+<ul>
+{{range .Tries}}<li><details><summary class="{{if .Err}}failed{{else}}ok{{end}}">{{.}}</summary>
+{{template "decisions" .Decisions}}</details></li>
+{{end}}</ul>
+</details>{{else}}{{.}}{{end}}</li>
+{{end}}</ul>
+{{end}}`))
+
+// WriteTraceHTML writes the decisions as an HTML page, the speculations
+// folded under their decision.
+func WriteTraceHTML(w io.Writer, decisions []*Decision) error {
+	return traceHTML.Execute(w, decisions)
+}
@@ -0,0 +1,175 @@
+package backtracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// trace parses src tracing the decisions.
+func trace(t *testing.T, src string, trailing bool) *BacktrackingParser {
+	t.Helper()
+	p := NewBacktrackingParser(NewLexer(src))
+	p.TraceDecisions = true
+	p.AllowTrailingComma = trailing
+	if _, err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestTrace(t *testing.T) {
+	cases := []struct {
+		src      string
+		trailing bool
+		want     string
+	}{
+		{
+			src: "[a]=[b]",
+			want: `1:1 program: stat, looking at [
+1:1 stat, speculating: assign
+  list 1:1 to 1:4: 1:4: syntax error: expecting end of statement, found Equals
+    1:2 list: elements, looking at a
+    1:2 element: NAME, looking at a ]
+    1:3 elements: end, looking at ]
+  assign 1:1 to 1:8
+    1:2 list: elements, looking at a
+    1:2 element: NAME, looking at a ]
+    1:3 elements: end, looking at ]
+    1:6 list: elements, looking at b
+    1:6 element: NAME, looking at b ]
+    1:7 elements: end, looking at ]
+1:2 list: elements, looking at a
+1:2 element: NAME, looking at a ]
+1:3 elements: end, looking at ]
+1:6 list: elements, looking at b
+1:6 element: NAME, looking at b ]
+1:7 elements: end, looking at ]
+1:8 program: EOF, looking at <EOF>
+`,
+		},
+		{
+			src:      "[{k: 1,}];\n[]",
+			trailing: true,
+			want: `1:1 program: stat, looking at [
+1:1 stat, speculating: list
+  list 1:1 to 1:10
+    1:2 list: elements, looking at {
+    1:2 element: map, looking at { k
+    1:3 map: pairs, looking at k
+    1:6 element: literal, looking at 1 ,
+    1:7 pairs: ',', looking at ,
+    1:8 pairs: trailing ',', looking at }
+    1:8 pairs: end, looking at }
+    1:9 elements: end, looking at ]
+1:2 list: elements, looking at {
+1:2 element: map, looking at { k
+1:3 map: pairs, looking at k
+1:6 element: literal, looking at 1 ,
+1:7 pairs: ',', looking at ,
+1:8 pairs: trailing ',', looking at }
+1:8 pairs: end, looking at }
+1:9 elements: end, looking at ]
+1:11 program: empty, looking at <NEWLINE>
+2:1 program: stat, looking at [
+2:1 stat, speculating: list
+  list 2:1 to 2:3
+    2:2 list: empty, looking at ]
+2:2 list: empty, looking at ]
+2:3 program: EOF, looking at <EOF>
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.src, func(t *testing.T) {
+			p := trace(t, tc.src, tc.trailing)
+			var s strings.Builder
+			if err := WriteTrace(&s, p.Decisions); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != tc.want {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestTraceDOT(t *testing.T) {
+	p := trace(t, "[a]", false)
+	var s strings.Builder
+	if err := WriteTraceDOT(&s, p.Decisions); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph trace {
+	node [shape=box, fontname="monospace"];
+	n0 [label="1:1 program: stat, looking at ["];
+	n1 [label="1:1 stat, speculating: list"];
+	n0 -> n1 [style=dashed];
+	n2 [label="list 1:1 to 1:4", shape=ellipse, color=green];
+	n1 -> n2;
+	n3 [label="1:2 list: elements, looking at a"];
+	n4 [label="1:2 element: NAME, looking at a ]"];
+	n3 -> n4 [style=dashed];
+	n5 [label="1:3 elements: end, looking at ]"];
+	n4 -> n5 [style=dashed];
+	n2 -> n3;
+	n6 [label="1:2 list: elements, looking at a"];
+	n1 -> n6 [style=dashed];
+	n7 [label="1:2 element: NAME, looking at a ]"];
+	n6 -> n7 [style=dashed];
+	n8 [label="1:3 elements: end, looking at ]"];
+	n7 -> n8 [style=dashed];
+	n9 [label="1:4 program: EOF, looking at <EOF>"];
+	n8 -> n9 [style=dashed];
+}
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestTraceHTML(t *testing.T) {
+	p := trace(t, "[a]=[b]", false)
+	var s strings.Builder
+	if err := WriteTraceHTML(&s, p.Decisions); err != nil {
+		t.Fatal(err)
+	}
+	got := s.String()
+	for _, want := range []string{
+		`<li><details open><summary>1:1 stat, speculating: assign</summary>`,
+		`<summary class="failed">list 1:1 to 1:4: 1:4: syntax error: expecting end of statement, found Equals</summary>`,
+		`<summary class="ok">assign 1:1 to 1:8</summary>`,
+		`<li>1:8 program: EOF, looking at &lt;EOF&gt;</li>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in:\n%s", want, got)
+		}
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	// the decisions are recorded up to the error, the last one taking no
+	// alternative
+	p := NewBacktrackingParser(NewLexer("[a,,b]"))
+	p.TraceDecisions = true
+	if _, err := p.Parse(); err == nil {
+		t.Fatal("want: syntax error, got: nil")
+	}
+	d := p.Decisions[len(p.Decisions)-1]
+	if got, want := d.String(), "1:1 stat, speculating: no alternative"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+	for _, s := range d.Tries {
+		last := s.Decisions[len(s.Decisions)-1]
+		if got, want := last.String(), "1:4 element: no alternative, looking at , b"; got != want {
+			t.Errorf("%s: want: %s, got: %s", s.Alt, want, got)
+		}
+	}
+
+	// every speculation ended, failing
+	if len(p.tries) != 0 {
+		t.Errorf("speculations left going on: %v", p.tries)
+	}
+}
@@ -0,0 +1,235 @@
+package backtracking
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pattern 30:
+// Rule-Based Translator
+
+// Instead of writing a walker that prints the translation of each kind of
+// node, we write down what each shape of tree turns into and let an engine do
+// the walking. A TranslationRule pairs a tree pattern, in the language of
+// treematch.go, with an output template. The translator tries the rules on a
+// node in order, and the first whose pattern matches gives the text of the
+// node, the captures of the pattern standing for the translations of the
+// nodes they matched:
+//
+//	(assign %l %r)   =>   (%l := %r)
+//
+// The template language:
+//
+//	%x          the translation of the node captured as x
+//	%"x         the text of the node captured as x, a name or the value of
+//	            a string, as a double-quoted string
+//	%*x         the translations of the children of x, separated by ", "
+//	%*x{sep}    same, separated by sep
+//	%* %*{sep}  same, for the children of the node matched
+//	%%          a %
+//
+// anything else is copied as it is. A leaf no rule matches is translated to
+// its source text, a tree no rule matches is an error: the rules must tell
+// what each construct becomes, but the leaves are mostly the same in most
+// languages.
+//
+// Python translates the list language to Python literals and assignments:
+// `[a,b]=[c,{d: true}]` becomes `a, b = c, {"d": True}`.
+
+// TranslationRule translates the subtrees matching Pattern with a template.
+type TranslationRule struct {
+	Pattern  *Pattern
+	template []templatePart
+}
+
+// templatePart is a piece of a template, one of the forms above.
+type templatePart struct {
+	kind    templateKind
+	text    string // the literal text, or the name of a capture
+	sep     string // the separator of children
+	capture bool   // the children are the ones of a capture
+}
+
+type templateKind int
+
+const (
+	literalPart templateKind = iota
+	translatePart
+	quotePart
+	childrenPart
+)
+
+// NewTranslationRule compiles a translation rule. The template can only use
+// the captures of the pattern.
+func NewTranslationRule(pattern, template string) (TranslationRule, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return TranslationRule{}, err
+	}
+	parts, err := parseTemplate(template, captureNames(p, map[string]bool{}))
+	if err != nil {
+		return TranslationRule{}, fmt.Errorf("rule %s => %q: %w", p, template, err)
+	}
+	return TranslationRule{Pattern: p, template: parts}, nil
+}
+
+// MustTranslationRule is like NewTranslationRule but panics on errors, for
+// rules known at compile time.
+func MustTranslationRule(pattern, template string) TranslationRule {
+	r, err := NewTranslationRule(pattern, template)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func parseTemplate(src string, captures map[string]bool) ([]templatePart, error) {
+	var parts []templatePart
+	var text strings.Builder
+	literal := func() {
+		if text.Len() > 0 {
+			parts = append(parts, templatePart{kind: literalPart, text: text.String()})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(src); {
+		if src[i] != '%' {
+			text.WriteByte(src[i])
+			i++
+			continue
+		}
+		i++
+		if i < len(src) && src[i] == '%' {
+			text.WriteByte('%')
+			i++
+			continue
+		}
+		part := templatePart{kind: translatePart}
+		if i < len(src) && (src[i] == '"' || src[i] == '*') {
+			part.kind = quotePart
+			if src[i] == '*' {
+				part.kind = childrenPart
+			}
+			i++
+		}
+		name := i
+		for i < len(src) && isPatternNameByte(src[i]) {
+			i++
+		}
+		part.text = src[name:i]
+		switch {
+		case part.text == "" && part.kind != childrenPart:
+			return nil, fmt.Errorf("%% without a capture at %d", name)
+		case part.text != "" && !captures[part.text]:
+			return nil, fmt.Errorf("%%%s isn't captured by the pattern", part.text)
+		}
+		if part.kind == childrenPart {
+			part.capture = part.text != ""
+			part.sep = ", "
+			if i < len(src) && src[i] == '{' {
+				end := strings.IndexByte(src[i:], '}')
+				if end < 0 {
+					return nil, fmt.Errorf("missing '}' at %d", i)
+				}
+				part.sep = src[i+1 : i+end]
+				i += end + 1
+			}
+		}
+		literal()
+		parts = append(parts, part)
+	}
+	literal()
+	return parts, nil
+}
+
+// isPatternNameByte tells whether b can be in the name of a capture.
+func isPatternNameByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+// Translator translates trees with its rules, the first that matches a node
+// translating it.
+type Translator struct {
+	Rules []TranslationRule
+}
+
+// Translate returns the translation of n.
+func (t *Translator) Translate(n Node) (string, error) {
+	var s strings.Builder
+	if err := t.translate(&s, n); err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+func (t *Translator) translate(s *strings.Builder, n Node) error {
+	for _, rule := range t.Rules {
+		captures, ok := rule.Pattern.Match(n)
+		if !ok {
+			continue
+		}
+		for _, part := range rule.template {
+			if err := t.expand(s, n, part, captures); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if leafKind(n) == "" {
+		return fmt.Errorf("%v: no rule translates %s", n.Pos(), n)
+	}
+	s.WriteString(n.String())
+	return nil
+}
+
+// expand writes a part of the template of the rule that matched n.
+func (t *Translator) expand(s *strings.Builder, n Node, part templatePart, captures map[string]Node) error {
+	switch part.kind {
+	case literalPart:
+		s.WriteString(part.text)
+	case translatePart:
+		return t.translate(s, captures[part.text])
+	case quotePart:
+		s.WriteString(strconv.Quote(nodeText(captures[part.text])))
+	case childrenPart:
+		if part.capture {
+			n = captures[part.text]
+		}
+		for i, c := range children(n) {
+			if i > 0 {
+				s.WriteString(part.sep)
+			}
+			if err := t.translate(s, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nodeText returns the name of a name, the value of a string and the source
+// text of other nodes.
+func nodeText(n Node) string {
+	if s, ok := n.(*StringNode); ok {
+		return s.Value
+	}
+	return leafText(n)
+}
+
+// Python translates programs to Python. The parallel assignments become
+// tuple assignments, but the empty ones which Python only has with lists,
+// the element assignments become assignment expressions, and the keys of
+// maps strings.
+var Python = &Translator{Rules: []TranslationRule{
+	MustTranslationRule("(program ...)", "%*{\n}"),
+	MustTranslationRule("(assign (list) (list))", "[] = []"),
+	MustTranslationRule("(assign %l:(list ...) %r:(list ...))", "%*l = %*r"),
+	MustTranslationRule("(assign %l %r)", "(%l := %r)"),
+	MustTranslationRule("(list ...)", "[%*]"),
+	MustTranslationRule("(map ...)", "{%*}"),
+	MustTranslationRule("(pair %k %v)", `%"k: %v`),
+	MustTranslationRule("%s:STRING", `%"s`),
+	MustTranslationRule("true", "True"),
+	MustTranslationRule("false", "False"),
+}}
@@ -0,0 +1,82 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPython(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: "[a,b]=[c,d]", want: "a, b = c, d"},
+		{input: "[]=[]", want: "[] = []"},
+		{input: "[[a,b]]=[[c]]", want: "[a, b] = [c]"},
+		{input: "[a=[b],c]", want: "[(a := [b]), c]"},
+		{input: `[{k: true, 'a b': "x\ty"}, false, 1, 2.5]`, want: `[{"k": True, "a b": "x\ty"}, False, 1, 2.5]`},
+		{input: "[a];[]", want: "[a]\n[]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			prog, err := NewBacktrackingParser(NewLexer(tc.input)).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Python.Translate(prog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestTranslator(t *testing.T) {
+	// the rules of a translator to S-expressions, but for maps
+	tr := &Translator{Rules: []TranslationRule{
+		MustTranslationRule("(program ...)", "%*{; }"),
+		MustTranslationRule("(list ...)", "(list %*{ })"),
+		MustTranslationRule("(= %l %r)", "(set %l %r)"),
+		MustTranslationRule("%n:NAME", `'%"n`),
+		MustTranslationRule("42", "%%42"),
+	}}
+	prog, err := NewBacktrackingParser(NewLexer("[a=[b,42]];[c]")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Translate(prog)
+	if want := `(list (set '"a" (list '"b" %42))); (list '"c")`; err != nil || got != want {
+		t.Errorf("want: %q, got: %q, %v", want, got, err)
+	}
+
+	prog, err = NewBacktrackingParser(NewLexer("[{a: b}]")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Translate(prog)
+	if want := "1:2: no rule translates {a: b}"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+}
+
+func TestTranslationRuleErrors(t *testing.T) {
+	cases := []struct {
+		pattern, template string
+		want              string
+	}{
+		{"(list %x)", "%y", `rule (list %x) => "%y": %y isn't captured by the pattern`},
+		{"(list %x)", "% x", `rule (list %x) => "% x": % without a capture at 1`},
+		{"(list ...)", "%*{, ", `rule (list ...) => "%*{, ": missing '}' at 2`},
+		{"(list", "", "pattern: missing ')'"},
+	}
+	for _, tc := range cases {
+		_, err := NewTranslationRule(tc.pattern, tc.template)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s => %s: want: %s, got: %v", tc.pattern, tc.template, tc.want, err)
+		}
+	}
+}
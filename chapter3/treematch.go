@@ -0,0 +1,449 @@
+package backtracking
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pattern 15:
+// Tree Pattern Matcher
+
+// Instead of walking the tree by hand looking for a particular shape, we
+// describe the shape as a tree pattern and let the matcher find it. Patterns
+// are written the same way trees are usually written down, as S-expressions
+// with the kind of node first and then its children:
+//
+//	(= NAME NAME)          an assignment such as a=b
+//	(list _ (list) ...)    a list whose second element is an empty list
+//	(map (pair key _))     a map with the single key named key
+//
+// The pattern language:
+//
+//	(head child...)   matches a node of that kind with exactly these children,
+//	                  heads are program, list, assign (or =), map and pair
+//	(head child... ...)
+//	                  same, but any number of children may follow
+//	_                 matches any node
+//	NAME INT FLOAT STRING BOOL
+//	                  match a leaf of that kind
+//	%x                matches any node and captures it as x
+//	%x:pattern        matches pattern and captures the node as x
+//	anything else     matches a leaf with that text, such as a or 42
+//
+// The children of an assignment are its left and right sides, the children of
+// a map are its pairs and the children of a pair are its key and value.
+//
+// A Rule pairs a pattern with a replacement written in the same notation, in
+// which captures stand for the nodes they matched. Rewrite applies rules to a
+// whole tree, bottom-up, e.g. FlattenLists turns `[a,[b],[[c]]]` into `[a,b,c]`
+// with the single rule `(list %x)` => `%x`.
+
+// Pattern is a compiled tree pattern.
+type Pattern struct {
+	kind     patternKind
+	text     string     // head of a tree, leaf text or capture name
+	sub      *Pattern   // what a capture must match, nil for anything
+	children []*Pattern // children of a tree
+	rest     bool       // tree ends with ...
+}
+
+type patternKind int
+
+const (
+	anyPattern patternKind = iota
+	treePattern
+	leafKindPattern
+	leafTextPattern
+	capturePattern
+)
+
+// heads of tree patterns, `=` is an alias for assign
+var heads = map[string]string{
+	"program": "program",
+	"list":    "list",
+	"assign":  "assign",
+	"=":       "assign",
+	"map":     "map",
+	"pair":    "pair",
+}
+
+// ParsePattern compiles the pattern in src.
+func ParsePattern(src string) (*Pattern, error) {
+	r := strings.NewReplacer("(", " ( ", ")", " ) ")
+	pp := &patternParser{words: strings.Fields(r.Replace(src))}
+	p, err := pp.pattern()
+	if err != nil {
+		return nil, err
+	}
+	if pp.pos < len(pp.words) {
+		return nil, fmt.Errorf("pattern %q: unexpected %q after the pattern", src, pp.words[pp.pos])
+	}
+	return p, nil
+}
+
+// MustParsePattern is like ParsePattern but panics on errors, for patterns
+// known at compile time.
+func MustParsePattern(src string) *Pattern {
+	p, err := ParsePattern(src)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// patternParser is a recursive-descent parser over the words of a pattern.
+type patternParser struct {
+	words []string
+	pos   int
+}
+
+func (pp *patternParser) next() string {
+	if pp.pos >= len(pp.words) {
+		return ""
+	}
+	w := pp.words[pp.pos]
+	pp.pos++
+	return w
+}
+
+func (pp *patternParser) pattern() (*Pattern, error) {
+	w := pp.next()
+	switch {
+	case w == "":
+		return nil, fmt.Errorf("pattern: unexpected end of pattern")
+	case w == "(":
+		return pp.tree()
+	case w == ")" || w == "...":
+		return nil, fmt.Errorf("pattern: unexpected %q", w)
+	case w == "_":
+		return &Pattern{kind: anyPattern}, nil
+	case w == "NAME" || w == "INT" || w == "FLOAT" || w == "STRING" || w == "BOOL":
+		return &Pattern{kind: leafKindPattern, text: w}, nil
+	case strings.HasPrefix(w, "%"):
+		return pp.capture(w[1:])
+	default:
+		return &Pattern{kind: leafTextPattern, text: w}, nil
+	}
+}
+
+// capture parses `%x` and `%x:pattern`, the word after `%` is already split
+// off. The subpattern is whatever follows the colon, possibly a tree.
+func (pp *patternParser) capture(w string) (*Pattern, error) {
+	name, sub, hasSub := strings.Cut(w, ":")
+	if name == "" {
+		return nil, fmt.Errorf("pattern: capture without a name")
+	}
+	p := &Pattern{kind: capturePattern, text: name}
+	if !hasSub {
+		return p, nil
+	}
+	if sub != "" {
+		// %x:NAME, the subpattern is in the same word
+		pp.pos--
+		pp.words[pp.pos] = sub
+	}
+	var err error
+	p.sub, err = pp.pattern()
+	return p, err
+}
+
+func (pp *patternParser) tree() (*Pattern, error) {
+	w := pp.next()
+	head, ok := heads[w]
+	if !ok {
+		return nil, fmt.Errorf("pattern: unknown node kind %q", w)
+	}
+	p := &Pattern{kind: treePattern, text: head}
+	for {
+		switch pp.next() {
+		case "":
+			return nil, fmt.Errorf("pattern: missing ')'")
+		case ")":
+			return p, nil
+		case "...":
+			if pp.next() != ")" {
+				return nil, fmt.Errorf("pattern: '...' must be the last child")
+			}
+			p.rest = true
+			return p, nil
+		}
+		pp.pos--
+		child, err := pp.pattern()
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+	}
+}
+
+// String renders the pattern back in the pattern language.
+func (p *Pattern) String() string {
+	switch p.kind {
+	case anyPattern:
+		return "_"
+	case capturePattern:
+		if p.sub == nil {
+			return "%" + p.text
+		}
+		return "%" + p.text + ":" + p.sub.String()
+	case treePattern:
+		var s strings.Builder
+		s.WriteString("(" + p.text)
+		for _, c := range p.children {
+			s.WriteString(" " + c.String())
+		}
+		if p.rest {
+			s.WriteString(" ...")
+		}
+		s.WriteString(")")
+		return s.String()
+	default:
+		return p.text
+	}
+}
+
+// Match tells whether n matches the pattern and returns what was captured.
+func (p *Pattern) Match(n Node) (map[string]Node, bool) {
+	captures := map[string]Node{}
+	if !p.match(n, captures) {
+		return nil, false
+	}
+	return captures, true
+}
+
+func (p *Pattern) match(n Node, captures map[string]Node) bool {
+	switch p.kind {
+	case anyPattern:
+		return true
+	case capturePattern:
+		if p.sub != nil && !p.sub.match(n, captures) {
+			return false
+		}
+		captures[p.text] = n
+		return true
+	case leafKindPattern:
+		return leafKind(n) == p.text
+	case leafTextPattern:
+		return leafKind(n) != "" && leafText(n) == p.text
+	}
+
+	if head(n) != p.text {
+		return false
+	}
+	kids := children(n)
+	if len(kids) < len(p.children) || !p.rest && len(kids) != len(p.children) {
+		return false
+	}
+	for i, c := range p.children {
+		if !c.match(kids[i], captures) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match is a subtree found by FindAll, with its captures.
+type Match struct {
+	Node     Node
+	Captures map[string]Node
+}
+
+// FindAll returns every subtree of n matching p, in preorder.
+func (p *Pattern) FindAll(n Node) []Match {
+	var matches []Match
+	var find func(n Node)
+	find = func(n Node) {
+		if captures, ok := p.Match(n); ok {
+			matches = append(matches, Match{Node: n, Captures: captures})
+		}
+		for _, c := range children(n) {
+			find(c)
+		}
+	}
+	find(n)
+	return matches
+}
+
+// head returns the node kind used in tree patterns, empty for leaves.
+func head(n Node) string {
+	switch n.(type) {
+	case *ProgramNode:
+		return "program"
+	case *ListNode:
+		return "list"
+	case *AssignNode:
+		return "assign"
+	case *MapNode:
+		return "map"
+	case *PairNode:
+		return "pair"
+	default:
+		return ""
+	}
+}
+
+// leafKind returns the leaf kind used in patterns, empty for trees.
+func leafKind(n Node) string {
+	switch n.(type) {
+	case *NameNode:
+		return "NAME"
+	case *IntNode:
+		return "INT"
+	case *FloatNode:
+		return "FLOAT"
+	case *StringNode:
+		return "STRING"
+	case *BoolNode:
+		return "BOOL"
+	default:
+		return ""
+	}
+}
+
+// leafText returns the text of a leaf, names aren't quoted.
+func leafText(n Node) string {
+	if name, ok := n.(*NameNode); ok {
+		return name.Token.Text
+	}
+	return n.String()
+}
+
+// Rule rewrites the subtrees matching Pattern into Replacement.
+type Rule struct {
+	Pattern     *Pattern
+	Replacement *Pattern
+}
+
+// NewRule compiles a rewrite rule. The replacement can only use captures from
+// the pattern, names, and trees made of those.
+func NewRule(pattern, replacement string) (Rule, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return Rule{}, err
+	}
+	r, err := ParsePattern(replacement)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := checkReplacement(r, captureNames(p, map[string]bool{})); err != nil {
+		return Rule{}, fmt.Errorf("rule %s => %s: %w", p, r, err)
+	}
+	return Rule{Pattern: p, Replacement: r}, nil
+}
+
+func captureNames(p *Pattern, names map[string]bool) map[string]bool {
+	if p.kind == capturePattern {
+		names[p.text] = true
+	}
+	if p.sub != nil {
+		captureNames(p.sub, names)
+	}
+	for _, c := range p.children {
+		captureNames(c, names)
+	}
+	return names
+}
+
+func checkReplacement(r *Pattern, captures map[string]bool) error {
+	switch r.kind {
+	case anyPattern, leafKindPattern:
+		return fmt.Errorf("%s can't be built", r)
+	case capturePattern:
+		if r.sub != nil || !captures[r.text] {
+			return fmt.Errorf("%s isn't captured by the pattern", r)
+		}
+	case leafTextPattern:
+		if !isBareName(r.text) {
+			return fmt.Errorf("%s isn't a name", r.text)
+		}
+	case treePattern:
+		if r.rest {
+			return fmt.Errorf("%s can't end with ...", r)
+		}
+		for _, c := range r.children {
+			if err := checkReplacement(c, captures); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// build makes the node for a replacement, which has been checked already.
+// Captured subtrees are copied, a variable used twice mustn't put the same
+// nodes in two places of the tree.
+func build(r *Pattern, captures map[string]Node) Node {
+	switch r.kind {
+	case capturePattern:
+		return Clone(captures[r.text])
+	case leafTextPattern:
+		return &NameNode{Token: Token{Type: Name, Text: r.text}}
+	}
+
+	kids := make([]Node, len(r.children))
+	for i, c := range r.children {
+		kids[i] = build(c, captures)
+	}
+	switch r.text {
+	case "program":
+		return &ProgramNode{Stats: kids}
+	case "list":
+		return &ListNode{Elements: kids}
+	case "map":
+		n := &MapNode{}
+		for _, k := range kids {
+			pair, ok := k.(*PairNode)
+			if !ok {
+				return nil
+			}
+			n.Pairs = append(n.Pairs, pair)
+		}
+		return n
+	}
+	if len(kids) != 2 {
+		return nil
+	}
+	if r.text == "assign" {
+		return &AssignNode{Left: kids[0], Right: kids[1]}
+	}
+	key, ok := kids[0].(*NameNode)
+	if !ok {
+		return nil
+	}
+	return &PairNode{Key: key, Value: kids[1]}
+}
+
+// Rewrite applies the rules to every subtree of n, children before their
+// parents, and returns the new tree. The first rule that matches a node
+// replaces it, unless the replacement can't go where the node is: statements
+// and the sides of a parallel assignment must stay lists, and the left side of
+// an element assignment and map keys must stay names. The tree is modified in
+// place.
+func Rewrite(n Node, rules ...Rule) Node {
+	return Apply(n, nil, func(c *Cursor) bool {
+		for _, rule := range rules {
+			captures, ok := rule.Pattern.Match(c.Node())
+			if !ok {
+				continue
+			}
+			if r := build(rule.Replacement, captures); c.CanReplace(r) {
+				c.Replace(r)
+				break
+			}
+		}
+		return true
+	})
+}
+
+// flattenRule replaces a list holding a single element by the element itself.
+var flattenRule = Rule{
+	Pattern:     MustParsePattern("(list %x)"),
+	Replacement: MustParsePattern("%x"),
+}
+
+// FlattenLists replaces every list of a single element by that element where
+// an element can go, so `[a,[b],[[c]]]` becomes `[a,b,c]`. Statements stay
+// lists, `[[a]]` becomes `[a]` and not `a`.
+func FlattenLists(n Node) Node {
+	return Rewrite(n, flattenRule)
+}
@@ -0,0 +1,124 @@
+package backtracking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPatternMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		input   string
+		want    []string // matched subtrees, in preorder
+	}{
+		{pattern: "(= NAME NAME)", input: "[a=b,c=[d],e=f]", want: []string{"a=b", "e=f"}},
+		{pattern: "(assign (list ...) _)", input: "[a]=[b];[c]", want: []string{"[a]=[b]"}},
+		{pattern: "(list)", input: "[a,[],[[]]]", want: []string{"[]", "[]"}},
+		{pattern: "(list _ (list) ...)", input: "[a,[],b];[a,[b]]", want: []string{"[a,[],b]"}},
+		{pattern: "(list NAME NAME)", input: "[[a,b],[a,1],[a,b,c]]", want: []string{"[a,b]"}},
+		{pattern: "(map (pair key _))", input: "[{key: 1},{key: 1, b: 2},{k: 1}]", want: []string{"{key: 1}"}},
+		{pattern: "(list INT FLOAT STRING BOOL)", input: `[1,2.5,"s",true]`, want: []string{`[1,2.5,"s",true]`}},
+		{pattern: "(= a 42)", input: "[a=42,a=41,b=42]", want: []string{"a=42"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			p, err := ParsePattern(tc.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			var got []string
+			for _, m := range p.FindAll(parser.program()) {
+				got = append(got, m.Node.String())
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestPatternCaptures(t *testing.T) {
+	p := MustParsePattern("(= %lhs:NAME %rhs:(list _ ...))")
+	parser := NewBacktrackingParser(NewLexer("[a=[b,c]]"))
+	matches := p.FindAll(parser.program())
+	if len(matches) != 1 {
+		t.Fatalf("want: 1 match, got: %d", len(matches))
+	}
+	got := map[string]string{}
+	for name, n := range matches[0].Captures {
+		got[name] = n.String()
+	}
+	want := map[string]string{"lhs": "a", "rhs": "[b,c]"}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	for _, src := range []string{"", "(", "(list", "(tuple a)", "(list ... a)", ")", "a b", "%", "%:NAME"} {
+		t.Run(src, func(t *testing.T) {
+			if _, err := ParsePattern(src); err == nil {
+				t.Error("want: error, got: nil")
+			}
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	cases := []struct {
+		pattern     string
+		replacement string
+		input       string
+		want        string
+	}{
+		{pattern: "(list %x)", replacement: "%x", input: "[a,[b],[[c]]]", want: "[a,b,c]"},
+		{pattern: "(list %x)", replacement: "%x", input: "[[a]];[[b]]=[c]", want: "[a]\n[b]=[c]"},
+		{pattern: "(list %x)", replacement: "%x", input: "[a=[b],{k: [c]}]", want: "[a=b,{k: c}]"},
+		{pattern: "(= %a %b)", replacement: "(= %b %a)", input: "[a=b,c=[d]]", want: "[b=a,c=[d]]"},
+		{pattern: "(= %a %b)", replacement: "(list %a %b)", input: "[a=b,c]", want: "[[a,b],c]"},
+		{pattern: "(pair %k %v)", replacement: "(pair %k (list %v))", input: "[{a: b}]", want: "[{a: [b]}]"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.pattern+" => "+tc.replacement+" "+tc.input, func(t *testing.T) {
+			rule, err := NewRule(tc.pattern, tc.replacement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			got := Rewrite(parser.program(), rule).String()
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewRuleErrors(t *testing.T) {
+	cases := []struct{ pattern, replacement string }{
+		{pattern: "(list %x)", replacement: "%y"},
+		{pattern: "(list %x)", replacement: "_"},
+		{pattern: "(list %x)", replacement: "NAME"},
+		{pattern: "(list %x)", replacement: "(list %x ...)"},
+		{pattern: "(list %x)", replacement: "42"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.pattern+" => "+tc.replacement, func(t *testing.T) {
+			if _, err := NewRule(tc.pattern, tc.replacement); err == nil {
+				t.Error("want: error, got: nil")
+			}
+		})
+	}
+}
+
+func TestFlattenLists(t *testing.T) {
+	parser := NewBacktrackingParser(NewLexer("[a,[b],[[c,[d]]]]"))
+	got := FlattenLists(parser.program()).String()
+	if want := "[a,b,[c,d]]"; got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
@@ -0,0 +1,220 @@
+package backtracking
+
+import (
+	"fmt"
+	"io"
+)
+
+// Pattern 13:
+// External Tree Visitor
+
+// A visitor keeps an operation on the tree out of the node types: instead of
+// adding a method to every node for each new operation (printing, counting,
+// checking), the operation is a type with one method per kind of node. Go has
+// no method overloading, so picking the method for a node is done in one place
+// by Visit with a switch on the node's type. That's the "switch on node type"
+// version of the pattern, double dispatch would need an Accept method on every
+// node which is what we're trying to avoid.
+//
+// Each method decides whether and in which order to visit the children of its
+// node, VisitChildren does it in the usual order for the ones that don't care.
+
+// Visitor has one method per kind of node.
+type Visitor interface {
+	VisitProgram(n *ProgramNode)
+	VisitList(n *ListNode)
+	VisitAssign(n *AssignNode)
+	VisitName(n *NameNode)
+	VisitMap(n *MapNode)
+	VisitPair(n *PairNode)
+	VisitInt(n *IntNode)
+	VisitFloat(n *FloatNode)
+	VisitString(n *StringNode)
+	VisitBool(n *BoolNode)
+	VisitError(n *ErrorNode)
+}
+
+// Visit calls the method of v for the type of n.
+func Visit(v Visitor, n Node) {
+	switch n := n.(type) {
+	case *ProgramNode:
+		v.VisitProgram(n)
+	case *ListNode:
+		v.VisitList(n)
+	case *AssignNode:
+		v.VisitAssign(n)
+	case *NameNode:
+		v.VisitName(n)
+	case *MapNode:
+		v.VisitMap(n)
+	case *PairNode:
+		v.VisitPair(n)
+	case *IntNode:
+		v.VisitInt(n)
+	case *FloatNode:
+		v.VisitFloat(n)
+	case *StringNode:
+		v.VisitString(n)
+	case *BoolNode:
+		v.VisitBool(n)
+	case *ErrorNode:
+		v.VisitError(n)
+	default:
+		panic(fmt.Sprintf("visit: unexpected node type %T", n))
+	}
+}
+
+// VisitChildren visits each child of n in order.
+func VisitChildren(v Visitor, n Node) {
+	for _, child := range children(n) {
+		Visit(v, child)
+	}
+}
+
+// children returns the child nodes of n in source order, leaves have none.
+func children(n Node) []Node {
+	switch n := n.(type) {
+	case *ProgramNode:
+		return n.Stats
+	case *ListNode:
+		return n.Elements
+	case *AssignNode:
+		return []Node{n.Left, n.Right}
+	case *MapNode:
+		nodes := make([]Node, len(n.Pairs))
+		for i, pair := range n.Pairs {
+			nodes[i] = pair
+		}
+		return nodes
+	case *PairNode:
+		return []Node{n.Key, n.Value}
+	default:
+		return nil
+	}
+}
+
+// nameCounter counts how many times each name shows up.
+type nameCounter struct {
+	counts map[string]int
+}
+
+// CountNames returns how many times each name is used in the tree rooted at
+// n, keys of maps included.
+func CountNames(n Node) map[string]int {
+	v := &nameCounter{counts: map[string]int{}}
+	Visit(v, n)
+	return v.counts
+}
+
+func (v *nameCounter) VisitProgram(n *ProgramNode) { VisitChildren(v, n) }
+func (v *nameCounter) VisitList(n *ListNode)       { VisitChildren(v, n) }
+func (v *nameCounter) VisitAssign(n *AssignNode)   { VisitChildren(v, n) }
+func (v *nameCounter) VisitName(n *NameNode)       { v.counts[n.Token.Text]++ }
+func (v *nameCounter) VisitMap(n *MapNode)         { VisitChildren(v, n) }
+func (v *nameCounter) VisitPair(n *PairNode)       { VisitChildren(v, n) }
+func (v *nameCounter) VisitInt(n *IntNode)         {}
+func (v *nameCounter) VisitFloat(n *FloatNode)     {}
+func (v *nameCounter) VisitString(n *StringNode)   {}
+func (v *nameCounter) VisitBool(n *BoolNode)       {}
+func (v *nameCounter) VisitError(n *ErrorNode)     {}
+
+// depthChecker tracks how deeply lists and maps are nested.
+type depthChecker struct {
+	depth int // lists and maps currently open
+	max   int // deepest nesting seen so far
+}
+
+// Depth returns the deepest nesting of lists and maps in the tree rooted at
+// n: `[a]` has depth 1 and `[a,[{b: c}]]` has depth 3.
+func Depth(n Node) int {
+	v := &depthChecker{}
+	Visit(v, n)
+	return v.max
+}
+
+// CheckDepth returns an error if lists and maps are nested deeper than limit.
+func CheckDepth(n Node, limit int) error {
+	if d := Depth(n); d > limit {
+		return fmt.Errorf("nesting too deep: %d levels, at most %d allowed", d, limit)
+	}
+	return nil
+}
+
+func (v *depthChecker) nested(n Node) {
+	v.depth++
+	v.max = max(v.max, v.depth)
+	VisitChildren(v, n)
+	v.depth--
+}
+
+func (v *depthChecker) VisitProgram(n *ProgramNode) { VisitChildren(v, n) }
+func (v *depthChecker) VisitList(n *ListNode)       { v.nested(n) }
+func (v *depthChecker) VisitAssign(n *AssignNode)   { VisitChildren(v, n) }
+func (v *depthChecker) VisitName(n *NameNode)       {}
+func (v *depthChecker) VisitMap(n *MapNode)         { v.nested(n) }
+func (v *depthChecker) VisitPair(n *PairNode)       { VisitChildren(v, n) }
+func (v *depthChecker) VisitInt(n *IntNode)         {}
+func (v *depthChecker) VisitFloat(n *FloatNode)     {}
+func (v *depthChecker) VisitString(n *StringNode)   {}
+func (v *depthChecker) VisitBool(n *BoolNode)       {}
+func (v *depthChecker) VisitError(n *ErrorNode)     {}
+
+// treePrinter prints the tree one node per line, with the same box drawing
+// used in the comment at the top of ast.go.
+type treePrinter struct {
+	w      io.Writer
+	prefix string // drawn before the node's own connector
+	last   bool   // is the node the last child of its parent
+	root   bool   // is the node the root, which has no connector
+	err    error  // first write error
+}
+
+// PrintTree writes the tree rooted at n to w:
+//
+//	AssignNode
+//	├── ListNode
+//	│   └── NameNode a
+//	└── ListNode
+//	    └── NameNode b
+func PrintTree(w io.Writer, n Node) error {
+	v := &treePrinter{w: w, root: true}
+	Visit(v, n)
+	return v.err
+}
+
+// node prints the line for a node and then visits its children one level
+// deeper.
+func (v *treePrinter) node(n Node, label string) {
+	line, childPrefix := label, ""
+	if !v.root {
+		connector, indent := "├── ", "│   "
+		if v.last {
+			connector, indent = "└── ", "    "
+		}
+		line = v.prefix + connector + label
+		childPrefix = v.prefix + indent
+	}
+	if v.err == nil {
+		_, v.err = fmt.Fprintln(v.w, line)
+	}
+
+	prefix, last, root := v.prefix, v.last, v.root
+	kids := children(n)
+	for i, child := range kids {
+		v.prefix, v.last, v.root = childPrefix, i == len(kids)-1, false
+		Visit(v, child)
+	}
+	v.prefix, v.last, v.root = prefix, last, root
+}
+
+func (v *treePrinter) VisitProgram(n *ProgramNode) { v.node(n, "ProgramNode") }
+func (v *treePrinter) VisitList(n *ListNode)       { v.node(n, "ListNode") }
+func (v *treePrinter) VisitAssign(n *AssignNode)   { v.node(n, "AssignNode") }
+func (v *treePrinter) VisitName(n *NameNode)       { v.node(n, "NameNode "+n.String()) }
+func (v *treePrinter) VisitMap(n *MapNode)         { v.node(n, "MapNode") }
+func (v *treePrinter) VisitPair(n *PairNode)       { v.node(n, "PairNode") }
+func (v *treePrinter) VisitInt(n *IntNode)         { v.node(n, "IntNode "+n.String()) }
+func (v *treePrinter) VisitFloat(n *FloatNode)     { v.node(n, "FloatNode "+n.String()) }
+func (v *treePrinter) VisitString(n *StringNode)   { v.node(n, "StringNode "+n.String()) }
+func (v *treePrinter) VisitBool(n *BoolNode)       { v.node(n, "BoolNode "+n.String()) }
+func (v *treePrinter) VisitError(n *ErrorNode)     { v.node(n, fmt.Sprintf("ErrorNode %q", n.String())) }
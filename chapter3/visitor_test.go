@@ -0,0 +1,88 @@
+package backtracking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCountNames(t *testing.T) {
+	cases := []struct {
+		input string
+		want  map[string]int
+	}{
+		{input: "[]", want: map[string]int{}},
+		{input: "[a,b,a]", want: map[string]int{"a": 2, "b": 1}},
+		{input: "[a=b,[c]]=[{a: b}]", want: map[string]int{"a": 2, "b": 2, "c": 1}},
+		{input: `[1, "a", true]`, want: map[string]int{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			got := CountNames(parser.program())
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestDepth(t *testing.T) {
+	cases := []struct {
+		input string
+		want  int
+	}{
+		{input: "", want: 0},
+		{input: "[]", want: 1},
+		{input: "[a,[b],c]", want: 2},
+		{input: "[a,[{b: c}]]", want: 3},
+		{input: "[a]=[[[b]]]", want: 3},
+		{input: "[[a]];[b]", want: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			parser := NewBacktrackingParser(NewLexer(tc.input))
+			got := Depth(parser.program())
+			if got != tc.want {
+				t.Errorf("want: %d, got: %d", tc.want, got)
+			}
+		})
+	}
+
+	parser := NewBacktrackingParser(NewLexer("[[[a]]]"))
+	tree := parser.program()
+	if err := CheckDepth(tree, 3); err != nil {
+		t.Errorf("want: nil, got: %v", err)
+	}
+	if err := CheckDepth(tree, 2); err == nil {
+		t.Error("want: error, got: nil")
+	}
+}
+
+func TestPrintTree(t *testing.T) {
+	parser := NewBacktrackingParser(NewLexer("[a,b=[c]]=[{d: 1}]"))
+	var s strings.Builder
+	if err := PrintTree(&s, parser.program()); err != nil {
+		t.Fatal(err)
+	}
+	want := `ProgramNode
+└── AssignNode
+    ├── ListNode
+    │   ├── NameNode a
+    │   └── AssignNode
+    │       ├── NameNode b
+    │       └── ListNode
+    │           └── NameNode c
+    └── ListNode
+        └── MapNode
+            └── PairNode
+                ├── NameNode d
+                └── IntNode 1
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,104 @@
+package backtracking
+
+import "iter"
+
+// Tree traversal
+//
+// A Visitor is the way to go for operations that treat each kind of node
+// differently, but it's a lot of methods for a simple question such as "which
+// names are used?". These helpers walk the tree in the usual orders and hand
+// out the nodes one by one:
+//
+//	for n := range NodesOf[*NameNode](prog) {
+//		fmt.Println(n.Token.Text)
+//	}
+
+// Walk calls f for n and then its descendants in preorder. If f returns false
+// the children of that node are skipped.
+func Walk(n Node, f func(Node) bool) {
+	if !f(n) {
+		return
+	}
+	for _, c := range children(n) {
+		Walk(c, f)
+	}
+}
+
+// Preorder returns an iterator over n and its descendants, each node before
+// its children.
+func Preorder(n Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		preorder(n, yield)
+	}
+}
+
+func preorder(n Node, yield func(Node) bool) bool {
+	if !yield(n) {
+		return false
+	}
+	for _, c := range children(n) {
+		if !preorder(c, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Postorder returns an iterator over n and its descendants, each node after
+// its children.
+func Postorder(n Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		postorder(n, yield)
+	}
+}
+
+func postorder(n Node, yield func(Node) bool) bool {
+	for _, c := range children(n) {
+		if !postorder(c, yield) {
+			return false
+		}
+	}
+	return yield(n)
+}
+
+// NodesOf returns an iterator over the nodes of type T in the tree rooted at
+// n, in preorder.
+func NodesOf[T Node](n Node) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := range Preorder(n) {
+			if t, ok := n.(T); ok && !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Ancestors returns an iterator over the ancestors of target in the tree
+// rooted at root, from its parent up to the root. Nodes don't point to their
+// parents, so the path is found by searching from the root first. Nothing is
+// yielded if target isn't in the tree or is the root itself.
+func Ancestors(root, target Node) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		path := pathTo(root, target, nil)
+		for i := len(path) - 2; i >= 0; i-- {
+			if !yield(path[i]) {
+				return
+			}
+		}
+	}
+}
+
+// pathTo returns the nodes from n down to target, both included, or nil if
+// target isn't under n.
+func pathTo(n, target Node, path []Node) []Node {
+	path = append(path, n)
+	if n == target {
+		return path
+	}
+	for _, c := range children(n) {
+		if p := pathTo(c, target, path); p != nil {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package backtracking
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// strs renders each node so they're easy to compare
+func strs(nodes []Node) []string {
+	var s []string
+	for _, n := range nodes {
+		s = append(s, n.String())
+	}
+	return s
+}
+
+func TestTraversalOrders(t *testing.T) {
+	tree, err := ParseSExpr("(list a (assign b (list c)) d)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("preorder", func(t *testing.T) {
+		got := strs(slices.Collect(Preorder(tree)))
+		want := []string{"[a,b=[c],d]", "a", "b=[c]", "b", "[c]", "c", "d"}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+
+	t.Run("postorder", func(t *testing.T) {
+		got := strs(slices.Collect(Postorder(tree)))
+		want := []string{"a", "b", "c", "[c]", "b=[c]", "d", "[a,b=[c],d]"}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+
+	t.Run("walk skipping assignments", func(t *testing.T) {
+		var got []string
+		Walk(tree, func(n Node) bool {
+			got = append(got, n.String())
+			_, ok := n.(*AssignNode)
+			return !ok
+		})
+		want := []string{"[a,b=[c],d]", "a", "b=[c]", "d"}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+
+	t.Run("stop early", func(t *testing.T) {
+		var got []string
+		for n := range Preorder(tree) {
+			if n.String() == "b" {
+				break
+			}
+			got = append(got, n.String())
+		}
+		want := []string{"[a,b=[c],d]", "a", "b=[c]"}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
+
+func TestNodesOf(t *testing.T) {
+	parser := NewBacktrackingParser(NewLexer("[a,b=[c]]=[{d: 1}, 2]"))
+	prog := parser.program()
+
+	var names []string
+	for n := range NodesOf[*NameNode](prog) {
+		names = append(names, n.Token.Text)
+	}
+	if want := []string{"a", "b", "c", "d"}; !cmp.Equal(names, want) {
+		t.Error(cmp.Diff(names, want))
+	}
+
+	var sum int64
+	for n := range NodesOf[*IntNode](prog) {
+		sum += n.Value
+	}
+	if sum != 3 {
+		t.Errorf("want: 3, got: %d", sum)
+	}
+}
+
+func TestAncestors(t *testing.T) {
+	tree, err := ParseSExpr("(list a (assign b (list c)))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Node
+	for n := range NodesOf[*NameNode](tree) {
+		if n.Token.Text == "c" {
+			c = n
+		}
+	}
+
+	got := strs(slices.Collect(Ancestors(tree, c)))
+	want := []string{"[c]", "b=[c]", "[a,b=[c]]"}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	if got := slices.Collect(Ancestors(tree, tree)); len(got) != 0 {
+		t.Errorf("root: want no ancestors, got: %v", got)
+	}
+	if got := slices.Collect(Ancestors(tree, name("c"))); len(got) != 0 {
+		t.Errorf("node not in tree: want no ancestors, got: %v", got)
+	}
+}
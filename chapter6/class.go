@@ -0,0 +1,75 @@
+package symtab
+
+// Pattern 19:
+// Symbol Table for Classes
+
+// A class is a struct that can also have methods and a superclass. It's a
+// type and the scope of its members, nested in the scope the class is
+// declared in. The difference is in how names resolve inside it: a class
+// inherits the members of its superclass, so after its own members come the
+// members of the superclass, then those of the superclass' superclass and so
+// on, and only then the enclosing scopes:
+//
+//	int x;
+//	class A { int x; void f() { } };
+//	class B : A {
+//	    int y;
+//	    void g() { f(); x = y; }  // A's f and x, not the global x
+//	};
+//
+// Inside a method `this` is the object the method is called on, of the
+// method's class, and `super` is the same object seen as an instance of the
+// superclass: `super.f()` calls A's f even if B defines its own.
+//
+// Members can be used before they are declared. In
+//
+//	class A { void f() { x = 1; } int x; };
+//
+// the x in f is the field declared after f, as in C++ or Java. To allow that,
+// DefRef defines all the members of a class when it enters the class, before
+// walking any method. Everywhere else a name must be declared before it's
+// used, as in C, and a variable isn't visible in its own initializer.
+
+// ClassSymbol is a class type, and the scope of its fields and methods.
+type ClassSymbol struct {
+	symbol
+	baseScope
+	Superclass *ClassSymbol // nil if the class has none
+}
+
+func NewClassSymbol(name string, superclass *ClassSymbol, enclosing Scope) *ClassSymbol {
+	c := &ClassSymbol{symbol: symbol{name: name}, Superclass: superclass}
+	c.baseScope = newBaseScope(c, enclosing)
+	return c
+}
+
+func (*ClassSymbol) isType() {}
+
+func (c *ClassSymbol) ScopeName() string { return c.name }
+
+// Resolve looks for name among the members of the class, the inherited ones
+// included, and then in the enclosing scopes.
+func (c *ClassSymbol) Resolve(name string) Symbol {
+	if sym := c.ResolveMember(name); sym != nil {
+		return sym
+	}
+	if c.enclosing != nil {
+		return c.enclosing.Resolve(name)
+	}
+	return nil
+}
+
+// ResolveMember returns the member called name, looking in the class and then
+// up its chain of superclasses. It's nil if there's no such member.
+func (c *ClassSymbol) ResolveMember(name string) Symbol {
+	for k := c; k != nil; k = k.Superclass {
+		if sym, ok := k.symbols[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// Members returns the fields and methods declared in the class itself, in
+// order.
+func (c *ClassSymbol) Members() []Symbol { return c.Symbols() }
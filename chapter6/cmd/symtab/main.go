@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// example has a global, a function and a nested block, each one a scope
+const example = `int x = 9;
+float f(float x) {
+    int y = 2;
+    { float z = x * y; }
+    return x;
+}
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+// run defines and resolves the symbols of the Cymbol program given as
+// arguments, or of the example, printing each definition and reference and
+// the symbols of each scope once it's done:
+//
+//	symtab [-two-pass] [-dump|-dot|-at line:col|-rename old=new] 'int i = 9; float j; int k = i + 2;'
+//
+// With -two-pass all the symbols are defined first, and then the references
+// resolved, so that functions and types can be used before their declaration.
+// With -dump the tree of scopes is printed instead of the events, and with
+// -dot it's written as a Graphviz DOT graph along with the references. With
+// -at, which resolves in two passes, what's at a position is printed instead:
+// the innermost scope, the symbols visible and the symbol of the name there,
+// with the names that declare it or refer to it.
+// With -rename the program is printed with a symbol renamed, old being its
+// name or the line:col of one of its names.
+//
+// The syntax error, or the undefined names and other semantic errors, are
+// returned as a single error with one diagnostic per line.
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("symtab", flag.ContinueOnError)
+	twoPass := fs.Bool("two-pass", false, "define all the symbols before resolving references")
+	dump := fs.Bool("dump", false, "print the tree of scopes and their symbols")
+	dot := fs.Bool("dot", false, "write the scopes, symbols and references as a Graphviz DOT graph")
+	at := fs.String("at", "", "print the scope, visible symbols and symbol at `line:col`")
+	rename := fs.String("rename", "", "print the program with a symbol renamed, `old=new` where old is a name or line:col")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var pos cymbol.Pos
+	if *at != "" {
+		if _, err := fmt.Sscanf(*at, "%d:%d", &pos.Line, &pos.Col); err != nil {
+			return fmt.Errorf("invalid position %q, want line:col", *at)
+		}
+		*twoPass = true
+	}
+	src := example
+	if fs.NArg() > 0 {
+		src = strings.Join(fs.Args(), " ")
+	}
+	if *rename != "" {
+		old, name, ok := strings.Cut(*rename, "=")
+		if !ok {
+			return fmt.Errorf("invalid renaming %q, want old=new", *rename)
+		}
+		renamed, err := symtab.Rename(src, old, name)
+		if err != nil {
+			return cymbol.WithSource(src, err)
+		}
+		_, err = io.WriteString(out, renamed)
+		return err
+	}
+	var diags cymbol.Diagnostics
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		diags.AddError(err)
+		return cymbol.WithSource(src, diags.Err())
+	}
+
+	table := symtab.NewSymbolTable()
+	var events []string
+	var scopes []symtab.Scope
+	var refs map[cymbol.Expr]symtab.Symbol
+	var index *symtab.Index
+	if *twoPass {
+		defs := symtab.NewDefPhase(table)
+		defs.Diagnostics = &diags
+		symtab.Walk(defs, f)
+		r := symtab.NewRefPhase(defs)
+		symtab.Walk(r, f)
+		events, scopes, refs = append(defs.Events, r.Events...), defs.Scopes, r.Refs
+		index = symtab.NewIndex(f, defs, r)
+	} else {
+		d := symtab.NewDefRef(table)
+		d.Diagnostics = &diags
+		symtab.Walk(d, f)
+		events, scopes, refs = d.Events, d.Scopes, d.Refs
+	}
+
+	switch {
+	case *at != "":
+		err = printAt(out, index, pos)
+	case *dot:
+		err = symtab.WriteDOT(out, table.Globals, scopes, refs)
+	case *dump:
+		err = symtab.Dump(out, table.Globals, scopes)
+	default:
+		for _, e := range events {
+			if _, err = fmt.Fprintln(out, e); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return cymbol.WithSource(src, diags.Err())
+}
+
+// printAt prints the scope pos is in, the symbols visible there and the
+// symbol of the name at pos, if there's one, with where it's declared and
+// its references.
+func printAt(out io.Writer, index *symtab.Index, pos cymbol.Pos) error {
+	var names []string
+	for _, sym := range index.Visible(pos) {
+		names = append(names, sym.Name())
+	}
+	fmt.Fprintf(out, "scope: %s\n", symtab.ScopeString(index.ScopeAt(pos)))
+	fmt.Fprintf(out, "visible: %s\n", strings.Join(names, ", "))
+	sym, span := index.SymbolAt(pos)
+	if sym == nil {
+		return nil
+	}
+	if def := index.Definition(sym); def != nil {
+		fmt.Fprintf(out, "symbol: %v at %v, declared at %v\n", sym, span.From, def.Pos())
+	} else {
+		fmt.Fprintf(out, "symbol: %v at %v\n", sym, span.From)
+	}
+	var refs []string
+	for _, s := range index.References(sym) {
+		refs = append(refs, s.From.String())
+	}
+	_, err := fmt.Fprintf(out, "references: %s\n", strings.Join(refs, ", "))
+	return err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	var s strings.Builder
+	if err := run(nil, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := `1:1: ref int
+1:5: def <x:int>
+2:1: ref float
+2:7: def <f:float>
+2:9: ref float
+2:15: def <x:float>
+3:5: ref int
+3:9: def <y:int>
+4:7: ref float
+4:17: ref <x:float>
+4:21: ref <y:int>
+4:13: def <z:float>
+local: [<z:float>]
+5:12: ref <x:float>
+local: [<y:int>]
+f: [<x:float>]
+global: [int, float, char, boolean, string, void, <x:int>, <f:float>]
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	if err := run([]string{"-two-pass", "void f() { g(); }", "void g() { }"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want = `1:6: def f
+1:24: def g
+1:1: ref void
+1:19: ref void
+1:12: ref <g:void>
+local: []
+f: []
+local: []
+g: []
+global: [int, float, char, boolean, string, void, <f:void>, <g:void>]
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	if err := run([]string{"-at", "4:17"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want = `scope: local: [<z:float>]
+visible: y, x, int, float, char, boolean, string, void, f
+symbol: <x:float> at 4:17, declared at 2:15
+references: 2:15, 4:17, 5:12
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	if err := run([]string{"-rename", "2:15=a"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want = `int x = 9;
+float f(float a) {
+    int y = 2;
+    { float z = a * y; }
+    return a;
+}
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	err := run([]string{"-at", "4"}, &s)
+	if want := `invalid position "4", want line:col`; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+	err = run([]string{"-rename", "x"}, &s)
+	if want := `invalid renaming "x", want old=new`; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+	err = run([]string{"-rename", "y=x", "int x; void f() { int y; x = y; }"}, &s)
+	if want := "1:26: renaming y to x: x would be the renamed y instead of <x:int>"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+	err = run([]string{"int x"}, &s)
+	if want := "1:6: syntax error: expecting Semicolon, found EOF"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+	err = run([]string{"int x = y; float x;"}, &s)
+	if want := "1:9: undefined: y\n1:18: x redeclared in this scope"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+}
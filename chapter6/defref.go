@@ -0,0 +1,341 @@
+package symtab
+
+import (
+	"fmt"
+
+	"example.com/cymbol"
+)
+
+// DefRef is the listener that fills a symbol table: declarations define
+// symbols in the current scope and every name used resolves to one. Functions,
+// namespaces and blocks push a new scope when entered and pop it when left. Each
+// definition and reference is recorded in Events in the order it happens, as
+// well as the symbols of each scope once it's popped:
+//
+//	1:1: ref int
+//	1:5: def <x:int>
+//	global: [int, float, char, boolean, string, void, <x:int>]
+//
+// A variable is defined after its initializer, so in `int x = x;` the second
+// x refers to some other x defined before, possibly in an enclosing scope.
+// The members of a class are all defined as soon as the class is entered, so
+// that its methods can use the ones declared after them.
+//
+// The symbol each name, member access, this and super resolves to is kept in
+// Refs, and the one each declared name defines in Defs.
+type DefRef struct {
+	resolver
+	Table    *SymbolTable
+	declared map[cymbol.Decl]Symbol // class members defined ahead of the walk
+}
+
+func NewDefRef(table *SymbolTable) *DefRef {
+	return &DefRef{
+		resolver: newResolver(table.Globals),
+		Table:    table,
+		declared: map[cymbol.Decl]Symbol{},
+	}
+}
+
+func (d *DefRef) Enter(n cymbol.Node) {
+	switch n := n.(type) {
+	case *cymbol.VarDecl:
+		if d.declared[n] == nil {
+			d.refType(n.Type)
+		}
+	case *cymbol.Param:
+		d.define(n.Name, NewVariableSymbol(n.Name.Name, d.refType(n.Type)))
+	case *cymbol.FuncDecl:
+		if f, ok := d.declared[n].(*FunctionSymbol); ok {
+			d.push(f)
+			return
+		}
+		// defined before its body so that it can call itself
+		f := NewFunctionSymbol(n.Name.Name, d.refType(n.Type), d.current)
+		d.define(n.Name, f)
+		d.push(f)
+	case *cymbol.StructDecl:
+		s := NewStructSymbol(n.Name.Name, d.current)
+		d.define(n.Name, s)
+		d.push(s)
+	case *cymbol.ClassDecl:
+		c := NewClassSymbol(n.Name.Name, nil, d.current)
+		c.Superclass = d.superclass(c, n.Super)
+		d.define(n.Name, c)
+		d.push(c)
+		d.declare(n)
+	case *cymbol.NamespaceDecl:
+		ns := NewNamespaceSymbol(n.Name.Name, d.current)
+		d.define(n.Name, ns)
+		d.push(ns)
+	case *cymbol.ImportDecl:
+		d.use(n.Name, d.current)
+	case *cymbol.Block:
+		d.push(NewLocalScope(d.current))
+	case *cymbol.Ident:
+		d.ref(n)
+	case *cymbol.ThisExpr:
+		d.self(n, n.Token, false)
+	case *cymbol.SuperExpr:
+		d.self(n, n.Token, true)
+	}
+}
+
+func (d *DefRef) Exit(n cymbol.Node) {
+	switch n := n.(type) {
+	case *cymbol.VarDecl:
+		if d.declared[n] != nil {
+			return
+		}
+		typ, _ := d.current.Resolve(n.Type.Name).(Type)
+		d.define(n.Name, NewVariableSymbol(n.Name.Name, typ))
+	case *cymbol.FuncDecl, *cymbol.StructDecl, *cymbol.ClassDecl, *cymbol.NamespaceDecl, *cymbol.Block:
+		d.pop()
+	case *cymbol.MemberExpr:
+		d.member(n)
+	case *cymbol.File:
+		d.Events = append(d.Events, ScopeString(d.Table.Globals))
+	}
+}
+
+// declare defines the fields and methods of the class c is the declaration
+// of, which is the current scope. The walk then finds them defined already.
+func (d *DefRef) declare(c *cymbol.ClassDecl) {
+	for _, m := range c.Members {
+		var sym Symbol
+		switch m := m.(type) {
+		case *cymbol.VarDecl:
+			sym = NewVariableSymbol(m.Name.Name, d.refType(m.Type))
+			d.define(m.Name, sym)
+		case *cymbol.FuncDecl:
+			sym = NewFunctionSymbol(m.Name.Name, d.refType(m.Type), d.current)
+			d.define(m.Name, sym)
+		}
+		d.declared[m] = sym
+	}
+}
+
+// resolver keeps track of the current scope and resolves the names used in
+// it, recording what it does in Events and the problems it finds, such as
+// undefined names, in Diagnostics. It has what DefRef, DefPhase and RefPhase
+// have in common.
+type resolver struct {
+	Events      []string
+	Refs        map[cymbol.Expr]Symbol
+	Defs        map[*cymbol.Ident]Symbol
+	Diagnostics *cymbol.Diagnostics // set it to a shared one before walking
+	Scopes      []Scope             // every scope entered, in order
+	current     Scope
+
+	// hidden reports whether sym, found for id, isn't visible yet where id
+	// is, in which case it's looked up further out. Nil if every symbol
+	// found is visible.
+	hidden func(sym Symbol, id *cymbol.Ident) bool
+}
+
+func newResolver(globals Scope) resolver {
+	return resolver{
+		Refs:        map[cymbol.Expr]Symbol{},
+		Defs:        map[*cymbol.Ident]Symbol{},
+		Diagnostics: &cymbol.Diagnostics{},
+		current:     globals,
+	}
+}
+
+func (r *resolver) push(s Scope) {
+	r.Scopes = append(r.Scopes, s)
+	r.current = s
+}
+
+// pop goes back to the enclosing scope, recording the symbols of the one left.
+func (r *resolver) pop() {
+	r.Events = append(r.Events, ScopeString(r.current))
+	r.current = r.current.EnclosingScope()
+}
+
+// define defines sym in the current scope, reporting it if the scope has a
+// symbol with the same name already.
+func (r *resolver) define(id *cymbol.Ident, sym Symbol) {
+	for _, s := range r.current.Symbols() {
+		if s.Name() == sym.Name() {
+			r.report(id.Token, "%s redeclared in this scope", id.Name)
+			break
+		}
+	}
+	r.current.Define(sym)
+	r.Defs[id] = sym
+	r.event(id, "def %v", sym)
+}
+
+// self resolves this to a variable whose type is the class of the method it's
+// in, and super to one whose type is the superclass of that class.
+func (r *resolver) self(x cymbol.Expr, tok cymbol.Token, super bool) {
+	var c *ClassSymbol
+	for s := r.current; s != nil && c == nil; s = s.EnclosingScope() {
+		c, _ = s.(*ClassSymbol)
+	}
+	if c == nil {
+		r.event(x, "ref %v: not in a class", x)
+		r.report(tok, "%v outside of a class", x)
+		return
+	}
+	if super {
+		if c.Superclass == nil {
+			r.event(x, "ref %v: class %s has no superclass", x, c.Name())
+			r.report(tok, "class %s has no superclass", c.Name())
+			return
+		}
+		c = c.Superclass
+	}
+	sym := NewVariableSymbol(x.String(), c)
+	r.event(x, "ref %v", sym)
+	r.Refs[x] = sym
+}
+
+// superclass resolves the name of the superclass of c, nil if there's none
+// or it would make c inherit from itself.
+func (r *resolver) superclass(c *ClassSymbol, id *cymbol.Ident) *ClassSymbol {
+	if id == nil {
+		return nil
+	}
+	sym := r.ref(id)
+	if sym == nil {
+		return nil
+	}
+	super, ok := sym.(*ClassSymbol)
+	if !ok {
+		r.event(id, "%s is not a class", id.Name)
+		r.report(id.Token, "%s is not a class", id.Name)
+		return nil
+	}
+	for k := super; k != nil; k = k.Superclass {
+		if k == c {
+			r.event(id, "class %s inherits from itself", c.Name())
+			r.report(id.Token, "class %s inherits from itself", c.Name())
+			return nil
+		}
+	}
+	return super
+}
+
+// ref resolves a name used in an expression.
+func (r *resolver) ref(id *cymbol.Ident) Symbol {
+	sym, hidden := r.resolve(id)
+	if sym == nil && hidden {
+		r.event(id, "ref %s: used before its declaration", id.Name)
+		r.report(id.Token, "%s used before its declaration", id.Name)
+		return nil
+	}
+	if sym == nil {
+		r.event(id, "ref %s: undefined", id.Name)
+		r.report(id.Token, "undefined: %s", id.Name)
+		return nil
+	}
+	r.event(id, "ref %v", sym)
+	r.Refs[id] = sym
+	return sym
+}
+
+// resolve looks id up from the current scope, skipping the symbols that are
+// hidden where id is. hidden tells whether any was.
+func (r *resolver) resolve(id *cymbol.Ident) (sym Symbol, hidden bool) {
+	sym = r.current.Resolve(id.Name)
+	for sym != nil && r.hidden != nil && r.hidden(sym, id) {
+		hidden = true
+		outer := sym.Scope().EnclosingScope()
+		if outer == nil {
+			return nil, true
+		}
+		sym = outer.Resolve(id.Name)
+	}
+	return sym, hidden
+}
+
+// use imports the namespace id names into scope.
+func (r *resolver) use(id *cymbol.Ident, scope Scope) {
+	sym := r.ref(id)
+	if sym == nil {
+		return
+	}
+	ns, ok := sym.(*NamespaceSymbol)
+	if !ok {
+		r.event(id, "%s is not a namespace", id.Name)
+		r.report(id.Token, "%s is not a namespace", id.Name)
+		return
+	}
+	scope.(importer).Import(ns)
+}
+
+// aggregate is a type with members, a struct or a class.
+type aggregate interface {
+	Type
+	ResolveMember(name string) Symbol
+}
+
+// member resolves the member in the struct or class that is the type of the
+// expression before the dot, which has been resolved already, or in the
+// namespace it names.
+func (r *resolver) member(x *cymbol.MemberExpr) {
+	sym := r.Refs[x.X]
+	if sym == nil {
+		// not resolved, which was reported already, or not a name
+		return
+	}
+	if ns, ok := sym.(*NamespaceSymbol); ok {
+		r.qualified(x, ns)
+		return
+	}
+	s, ok := sym.Type().(aggregate)
+	if !ok {
+		r.event(x.Member, "ref %v: %s is not a struct or class", x, x.X)
+		r.report(x.Member.Token, "%v is not a struct or class", x.X)
+		return
+	}
+	field := s.ResolveMember(x.Member.Name)
+	if field == nil {
+		kind := "struct"
+		if _, ok := s.(*ClassSymbol); ok {
+			kind = "class"
+		}
+		r.event(x.Member, "ref %v: no member %s in %s %s", x, x.Member.Name, kind, s.Name())
+		r.report(x.Member.Token, "no member %s in %s %s", x.Member.Name, kind, s.Name())
+		return
+	}
+	r.event(x.Member, "ref %v", field)
+	r.Refs[x] = field
+}
+
+// qualified resolves the member of x in the namespace ns.
+func (r *resolver) qualified(x *cymbol.MemberExpr, ns *NamespaceSymbol) {
+	sym := ns.ResolveMember(x.Member.Name)
+	if sym == nil {
+		r.event(x.Member, "ref %v: undefined in namespace %s", x, ns.Name())
+		r.report(x.Member.Token, "undefined: %v", x)
+		return
+	}
+	r.event(x.Member, "ref %v", sym)
+	r.Refs[x] = sym
+}
+
+// refType resolves a name used as a type, nil if it's not one.
+func (r *resolver) refType(id *cymbol.Ident) Type {
+	sym := r.ref(id)
+	if sym == nil {
+		return nil
+	}
+	typ, ok := sym.(Type)
+	if !ok {
+		r.event(id, "%s is not a type", id.Name)
+		r.report(id.Token, "%s is not a type", id.Name)
+	}
+	return typ
+}
+
+func (r *resolver) event(n cymbol.Node, format string, args ...any) {
+	r.Events = append(r.Events, fmt.Sprintf("%v: ", n.Pos())+fmt.Sprintf(format, args...))
+}
+
+// report records a diagnostic about the source of tok.
+func (r *resolver) report(tok cymbol.Token, format string, args ...any) {
+	r.Diagnostics.Add(tok.Span(), format, args...)
+}
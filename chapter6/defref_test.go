@@ -0,0 +1,369 @@
+package symtab
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+// globals renders the global scope with the built-in types and then syms
+func globals(syms string) string {
+	if syms == "" {
+		return "global: [int, float, char, boolean, string, void]"
+	}
+	return "global: [int, float, char, boolean, string, void, " + syms + "]"
+}
+
+func TestDefRef(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		events []string
+	}{
+		{
+			name:   "globals",
+			src:    "int x; float y = 1.5;",
+			events: []string{"1:1: ref int", "1:5: def <x:int>", "1:8: ref float", "1:14: def <y:float>", globals("<x:int>, <y:float>")},
+		},
+		{
+			name:   "defined after its initializer",
+			src:    "int x = x;",
+			events: []string{"1:1: ref int", "1:9: ref x: undefined", "1:5: def <x:int>", globals("<x:int>")},
+		},
+		{
+			name: "function",
+			src:  "int f(int a) { return f(a); }",
+			events: []string{
+				"1:1: ref int", "1:5: def <f:int>",
+				"1:7: ref int", "1:11: def <a:int>",
+				"1:23: ref <f:int>", "1:25: ref <a:int>",
+				"local: []",
+				"f: [<a:int>]",
+				globals("<f:int>"),
+			},
+		},
+		{
+			name: "locals aren't visible outside",
+			src:  "void f() { int a; } void g() { a = 1; }",
+			events: []string{
+				"1:1: ref void", "1:6: def <f:void>",
+				"1:12: ref int", "1:16: def <a:int>",
+				"local: [<a:int>]",
+				"f: []",
+				"1:21: ref void", "1:26: def <g:void>",
+				"1:32: ref a: undefined",
+				"local: []",
+				"g: []",
+				globals("<f:void>, <g:void>"),
+			},
+		},
+		{
+			name: "shadowing",
+			src:  "int x; void f(float x) { x = 1; { char x = x; x = 'a'; } x = 2; } int y = x;",
+			events: []string{
+				"1:1: ref int", "1:5: def <x:int>",
+				"1:8: ref void", "1:13: def <f:void>",
+				"1:15: ref float", "1:21: def <x:float>",
+				"1:26: ref <x:float>",
+				"1:35: ref char", "1:44: ref <x:float>", "1:40: def <x:char>",
+				"1:47: ref <x:char>",
+				"local: [<x:char>]",
+				"1:58: ref <x:float>",
+				"local: []",
+				"f: [<x:float>]",
+				"1:67: ref int", "1:75: ref <x:int>", "1:71: def <y:int>",
+				globals("<x:int>, <f:void>, <y:int>"),
+			},
+		},
+		{
+			name: "structs",
+			src:  "struct A { int x; struct B { int y; }; B b; }; A a; int y = a.b.y + a.x;",
+			events: []string{
+				"1:8: def A",
+				"1:12: ref int", "1:16: def <x:int>",
+				"1:26: def B",
+				"1:30: ref int", "1:34: def <y:int>",
+				"B: [<y:int>]",
+				"1:40: ref B", "1:42: def <b:B>",
+				"A: [<x:int>, B, <b:B>]",
+				"1:48: ref A", "1:50: def <a:A>",
+				"1:53: ref int",
+				"1:61: ref <a:A>", "1:63: ref <b:B>", "1:65: ref <y:int>",
+				"1:69: ref <a:A>", "1:71: ref <x:int>",
+				"1:57: def <y:int>",
+				globals("A, <a:A>, <y:int>"),
+			},
+		},
+		{
+			name: "unknown members",
+			src:  "int x; struct A { int y; }; A a; int z = a.x + a.y.z + x.y;",
+			events: []string{
+				"1:1: ref int", "1:5: def <x:int>",
+				"1:15: def A",
+				"1:19: ref int", "1:23: def <y:int>",
+				"A: [<y:int>]",
+				"1:29: ref A", "1:31: def <a:A>",
+				"1:34: ref int",
+				"1:42: ref <a:A>", "1:44: ref a.x: no member x in struct A", // not the global x
+				"1:48: ref <a:A>", "1:50: ref <y:int>", "1:52: ref a.y.z: a.y is not a struct or class",
+				"1:56: ref <x:int>", "1:58: ref x.y: x is not a struct or class",
+				"1:38: def <z:int>",
+				globals("<x:int>, A, <a:A>, <z:int>"),
+			},
+		},
+		{
+			name: "local struct",
+			src:  "void f() { struct P { int x; }; P p; p.x = 1; }",
+			events: []string{
+				"1:1: ref void", "1:6: def <f:void>",
+				"1:19: def P",
+				"1:23: ref int", "1:27: def <x:int>",
+				"P: [<x:int>]",
+				"1:33: ref P", "1:35: def <p:P>",
+				"1:38: ref <p:P>", "1:40: ref <x:int>",
+				"local: [P, <p:P>]",
+				"f: []",
+				globals("<f:void>"),
+			},
+		},
+		{
+			name: "inheritance",
+			src:  "int x; class A { int x; void f() { } }; class B : A { void g() { f(); x = 1; } };",
+			events: []string{
+				"1:1: ref int", "1:5: def <x:int>",
+				"1:14: def A",
+				"1:18: ref int", "1:22: def <x:int>",
+				"1:25: ref void", "1:30: def <f:void>",
+				"local: []",
+				"f: []",
+				"A: [<x:int>, <f:void>]",
+				"1:51: ref A", "1:47: def B",
+				"1:55: ref void", "1:60: def <g:void>",
+				"1:66: ref <f:void>", "1:71: ref <x:int>", // A's x
+				"local: []",
+				"g: []",
+				"B: [<g:void>]",
+				globals("<x:int>, A, B"),
+			},
+		},
+		{
+			name: "members used before their declaration",
+			src:  "class A { void f() { x = 1; g(); } int x; void g() { } };",
+			events: []string{
+				"1:7: def A",
+				"1:11: ref void", "1:16: def <f:void>",
+				"1:36: ref int", "1:40: def <x:int>",
+				"1:43: ref void", "1:48: def <g:void>",
+				"1:22: ref <x:int>", "1:29: ref <g:void>",
+				"local: []",
+				"f: []",
+				"local: []",
+				"g: []",
+				"A: [<f:void>, <x:int>, <g:void>]",
+				globals("A"),
+			},
+		},
+		{
+			name: "this and super",
+			src:  "class A { int x; }; class B : A { void f() { this.x = super.x; this.y = 1; } };",
+			events: []string{
+				"1:7: def A",
+				"1:11: ref int", "1:15: def <x:int>",
+				"A: [<x:int>]",
+				"1:31: ref A", "1:27: def B",
+				"1:35: ref void", "1:40: def <f:void>",
+				"1:46: ref <this:B>", "1:51: ref <x:int>",
+				"1:55: ref <super:A>", "1:61: ref <x:int>",
+				"1:64: ref <this:B>", "1:69: ref this.y: no member y in class B",
+				"local: []",
+				"f: []",
+				"B: [<f:void>]",
+				globals("A, B"),
+			},
+		},
+		{
+			name: "bad this and super",
+			src:  "class A { void f() { super.f(); } }; int y = this.x; int b; class B : b { };",
+			events: []string{
+				"1:7: def A",
+				"1:11: ref void", "1:16: def <f:void>",
+				"1:22: ref super: class A has no superclass",
+				"local: []",
+				"f: []",
+				"A: [<f:void>]",
+				"1:38: ref int", "1:46: ref this: not in a class", "1:42: def <y:int>",
+				"1:54: ref int", "1:58: def <b:int>",
+				"1:71: ref <b:int>", "1:71: b is not a class", "1:67: def B",
+				"B: []",
+				globals("A, <y:int>, <b:int>, B"),
+			},
+		},
+		{
+			name: "namespaces",
+			src:  "namespace geo { int x; int f() { return x; } } import geo; int y = geo.f() + x;",
+			events: []string{
+				"1:11: def geo",
+				"1:17: ref int", "1:21: def <x:int>",
+				"1:24: ref int", "1:28: def <f:int>",
+				"1:41: ref <x:int>",
+				"local: []",
+				"f: []",
+				"geo: [<x:int>, <f:int>]",
+				"1:55: ref geo",
+				"1:60: ref int", "1:68: ref geo", "1:72: ref <f:int>", "1:78: ref <x:int>", "1:64: def <y:int>",
+				globals("geo, <y:int>"),
+			},
+		},
+		{
+			name:   "undefined",
+			src:    "point p = q;",
+			events: []string{"1:1: ref point: undefined", "1:11: ref q: undefined", "1:7: def p", globals("p")},
+		},
+		{
+			name:   "not a type",
+			src:    "int x; x y;",
+			events: []string{"1:1: ref int", "1:5: def <x:int>", "1:8: ref <x:int>", "1:8: x is not a type", "1:10: def y", globals("<x:int>, y")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := cymbol.ParseFile(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := NewDefRef(NewSymbolTable())
+			Walk(d, f)
+			if !cmp.Equal(d.Events, tc.events) {
+				t.Error(cmp.Diff(d.Events, tc.events))
+			}
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	cases := []struct {
+		name    string
+		src     string
+		twoPass bool
+		want    []string
+	}{
+		{
+			name: "undefined",
+			src:  "int x = y; void f() { g(); }",
+			want: []string{"1:9: undefined: y", "1:23: undefined: g"},
+		},
+		{
+			name:    "defined later",
+			src:     "int x = y; void f() { g(); } void g() { } int y;",
+			twoPass: true,
+			want:    []string{"1:9: y used before its declaration"},
+		},
+		{
+			name: "redeclared",
+			src:  "int x; float x; void f(int a, int a) { int b; { int b; } char b; } struct A { int x; int x; };",
+			want: []string{"1:14: x redeclared in this scope", "1:35: a redeclared in this scope", "1:63: b redeclared in this scope", "1:90: x redeclared in this scope"},
+		},
+		{
+			name:    "redeclared in two passes",
+			src:     "class A { void f() { } int f; }; void A() { }",
+			twoPass: true,
+			want:    []string{"1:28: f redeclared in this scope", "1:39: A redeclared in this scope"},
+		},
+		{
+			name: "types and members",
+			src:  "int x; x y; struct S { int a; }; S s; int z = s.b + x.c; class B : S { void f() { super.f(); } }; int w = this.a;",
+			want: []string{
+				"1:8: x is not a type",
+				"1:49: no member b in struct S",
+				"1:55: x is not a struct or class",
+				"1:68: S is not a class",
+				"1:83: class B has no superclass",
+				"1:107: this outside of a class",
+			},
+		},
+		{
+			name: "namespaces",
+			src:  "int x; import x; namespace n { int y; } int z = n.y + n.z + y; import n;",
+			want: []string{"1:15: x is not a namespace", "1:57: undefined: n.z", "1:61: undefined: y"},
+		},
+		{
+			name:    "namespaces in two passes",
+			src:     "int x; import x; namespace n { int y; } int z = n.y + n.z + y; import n;",
+			twoPass: true,
+			want:    []string{"1:15: x is not a namespace", "1:57: undefined: n.z"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := cymbol.ParseFile(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var diags *cymbol.Diagnostics
+			if tc.twoPass {
+				defs, _ := TwoPass(NewSymbolTable(), f)
+				diags = defs.Diagnostics
+			} else {
+				d := NewDefRef(NewSymbolTable())
+				Walk(d, f)
+				diags = d.Diagnostics
+			}
+			var got []string
+			for _, d := range diags.List() {
+				got = append(got, d.Error())
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestDefs(t *testing.T) {
+	src := "int x = 1; void f(int x) { x = x + 1; } class A { void g() { n = 2; } int n; };"
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDefRef(NewSymbolTable())
+	Walk(d, f)
+	defs, refs := TwoPass(NewSymbolTable(), f)
+
+	cases := []struct {
+		name string
+		defs map[*cymbol.Ident]Symbol
+		refs map[cymbol.Expr]Symbol
+	}{
+		{name: "DefRef", defs: d.Defs, refs: d.Refs},
+		{name: "TwoPass", defs: defs.Defs, refs: refs.Refs},
+	}
+	want := []string{
+		"1:28: <x:int> defined at 1:23",
+		"1:32: <x:int> defined at 1:23",
+		"1:62: <n:int> defined at 1:75",
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			declared := map[Symbol]cymbol.Pos{}
+			for id, sym := range tc.defs {
+				declared[sym] = id.Pos()
+			}
+			var got []string
+			for x, sym := range tc.refs {
+				if _, ok := sym.(*VariableSymbol); ok {
+					got = append(got, fmt.Sprintf("%v: %v defined at %v", x.Pos(), sym, declared[sym]))
+				}
+			}
+			slices.Sort(got)
+			if !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package symtab
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"example.com/cymbol"
+)
+
+// Dumping the symbol table
+//
+// The events tell how the symbol table is filled, a dump shows the result:
+// the tree of scopes, each one with the symbols defined in it. Dump writes it
+// as text, each scope indented under the one enclosing it:
+//
+//	global: [int, float, char, boolean, string, void, <x:int>, <f:void>]
+//	  f: [<a:int>]
+//	    local: [<y:int>]
+//
+// WriteDOT writes it in the DOT language of Graphviz instead, to be rendered
+// with e.g. `dot -Tsvg symtab.dot > symtab.svg`: each scope is a cluster
+// drawn inside the one enclosing it, with a node for each of its symbols, and
+// each reference is a node with a dashed edge to the symbol it resolves to.
+
+// Dump writes the tree of scopes rooted at globals. scopes are the scopes
+// nested in it, as a listener's Scopes.
+func Dump(w io.Writer, globals Scope, scopes []Scope) error {
+	kids := nested(scopes)
+	bw := bufio.NewWriter(w)
+	var dump func(s Scope, indent string)
+	dump = func(s Scope, indent string) {
+		fmt.Fprintln(bw, indent+ScopeString(s))
+		for _, k := range kids[s] {
+			dump(k, indent+"  ")
+		}
+	}
+	dump(globals, "")
+	return bw.Flush()
+}
+
+// WriteDOT writes the tree of scopes rooted at globals as a DOT graph, with
+// an edge from each reference in refs to its symbol. References to symbols
+// that aren't in any scope, such as this, are left out.
+func WriteDOT(w io.Writer, globals Scope, scopes []Scope, refs map[cymbol.Expr]Symbol) error {
+	kids := nested(scopes)
+	ids := map[Symbol]int{}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph symtab {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=\"monospace\"];")
+
+	clusters := 0
+	var cluster func(s Scope, indent string)
+	cluster = func(s Scope, indent string) {
+		fmt.Fprintf(bw, "%ssubgraph cluster%d {\n", indent, clusters)
+		fmt.Fprintf(bw, "%s\tlabel=\"%s\";\n", indent, dotEscape(s.ScopeName()))
+		clusters++
+		for _, sym := range s.Symbols() {
+			ids[sym] = len(ids)
+			fmt.Fprintf(bw, "%s\ts%d [label=\"%s\"];\n", indent, ids[sym], dotEscape(sym.String()))
+		}
+		for _, k := range kids[s] {
+			cluster(k, indent+"\t")
+		}
+		fmt.Fprintln(bw, indent+"}")
+	}
+	cluster(globals, "\t")
+
+	exprs := make([]cymbol.Expr, 0, len(refs))
+	for x, sym := range refs {
+		if _, ok := ids[sym]; ok {
+			exprs = append(exprs, x)
+		}
+	}
+	slices.SortFunc(exprs, func(a, b cymbol.Expr) int {
+		pa, pb := a.Pos(), b.Pos()
+		return cmp.Or(cmp.Compare(pa.Line, pb.Line), cmp.Compare(pa.Col, pb.Col), cmp.Compare(a.String(), b.String()))
+	})
+	for i, x := range exprs {
+		fmt.Fprintf(bw, "\tr%d [label=\"%s %v\", shape=plaintext];\n", i, dotEscape(x.String()), x.Pos())
+		fmt.Fprintf(bw, "\tr%d -> s%d [style=dashed];\n", i, ids[refs[x]])
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// nested returns the scopes nested directly in each scope, in the order of
+// scopes without repetitions.
+func nested(scopes []Scope) map[Scope][]Scope {
+	kids := map[Scope][]Scope{}
+	seen := map[Scope]bool{}
+	for _, s := range scopes {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		if outer := s.EnclosingScope(); outer != nil {
+			kids[outer] = append(kids[outer], s)
+		}
+	}
+	return kids
+}
+
+// dotEscape escapes a label for a double quoted DOT string
+var dotEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
@@ -0,0 +1,76 @@
+package symtab
+
+import (
+	"strings"
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDump(t *testing.T) {
+	f, err := cymbol.ParseFile("struct A { int x; }; void f(A a) { { int y = a.x; } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDefRef(NewSymbolTable())
+	Walk(d, f)
+
+	var s strings.Builder
+	if err := Dump(&s, d.Table.Globals, d.Scopes); err != nil {
+		t.Fatal(err)
+	}
+	want := `global: [int, float, char, boolean, string, void, A, <f:void>]
+  A: [<x:int>]
+  f: [<a:A>]
+    local: []
+      local: [<y:int>]
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	f, err := cymbol.ParseFile("int x; void f() { x = 1; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDefRef(NewSymbolTable())
+	Walk(d, f)
+
+	var s strings.Builder
+	if err := WriteDOT(&s, d.Table.Globals, d.Scopes, d.Refs); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph symtab {
+	node [shape=box, fontname="monospace"];
+	subgraph cluster0 {
+		label="global";
+		s0 [label="int"];
+		s1 [label="float"];
+		s2 [label="char"];
+		s3 [label="boolean"];
+		s4 [label="string"];
+		s5 [label="void"];
+		s6 [label="<x:int>"];
+		s7 [label="<f:void>"];
+		subgraph cluster1 {
+			label="f";
+			subgraph cluster2 {
+				label="local";
+			}
+		}
+	}
+	r0 [label="int 1:1", shape=plaintext];
+	r0 -> s0 [style=dashed];
+	r1 [label="void 1:8", shape=plaintext];
+	r1 -> s5 [style=dashed];
+	r2 [label="x 1:19", shape=plaintext];
+	r2 -> s6 [style=dashed];
+}
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+}
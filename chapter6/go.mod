@@ -0,0 +1,10 @@
+module example.com/symtab
+
+go 1.23.4
+
+require (
+	example.com/cymbol v0.0.0
+	github.com/google/go-cmp v0.6.0
+)
+
+replace example.com/cymbol => ../cymbol
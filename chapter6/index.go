@@ -0,0 +1,266 @@
+package symtab
+
+import (
+	"slices"
+
+	"example.com/cymbol"
+)
+
+// Position queries
+//
+// Editors ask about the place the cursor is at: which scope it's in, which
+// names can be used there, which symbol the name under it is, where that
+// symbol is declared and where else it's used. An Index answers them for a
+// program resolved by TwoPass, from the part of the source each scope spans
+// and the position of each name:
+//
+//	int x;
+//	float f(float a) {          ScopeAt(3:17) is the body of f
+//	    int y = 2; x = y;       Visible(3:17) is y, a, the types, x and f
+//	}                           SymbolAt(3:20) is <y:int>, declared at 3:9
+//	                            ReferencesAt(3:20) are 3:9 and 3:20
+//
+// A function's scope starts after its name, so its parameters are in it, and
+// ends with its body. A struct or class scope starts after its name, or its
+// superclass, and ends with its closing brace, and so does the scope of a
+// namespace. A block spans its braces.
+// As for RefPhase, variables that aren't fields are only visible after their
+// declaration, here after the last token of their initializer.
+
+// Index answers questions about positions in a program: the scope, the
+// visible symbols and the symbol of the name found there.
+type Index struct {
+	globals     Scope
+	scopes      []extent // in the order they're opened, outer ones first
+	names       []name   // defined or referenced, sorted by position
+	decls       map[Symbol]*cymbol.Ident
+	visibleFrom map[Symbol]cymbol.Pos // variables that aren't fields
+}
+
+// extent is the part of the source a scope spans.
+type extent struct {
+	span  cymbol.Span
+	scope Scope
+}
+
+// name is a name in the source and the symbol it defines or refers to.
+type name struct {
+	span cymbol.Span
+	sym  Symbol
+}
+
+// NewIndex indexes f, whose symbols defs and refs defined and resolved.
+func NewIndex(f *cymbol.File, defs *DefPhase, refs *RefPhase) *Index {
+	ix := &Index{
+		globals:     defs.Table.Globals,
+		decls:       map[Symbol]*cymbol.Ident{},
+		visibleFrom: map[Symbol]cymbol.Pos{},
+	}
+	for id, sym := range defs.Defs {
+		ix.decls[sym] = id
+		ix.names = append(ix.names, name{id.Token.Span(), sym})
+	}
+	for x, sym := range refs.Refs {
+		switch x := x.(type) {
+		case *cymbol.Ident:
+			ix.names = append(ix.names, name{x.Token.Span(), sym})
+		case *cymbol.MemberExpr:
+			ix.names = append(ix.names, name{x.Member.Token.Span(), sym})
+		case *cymbol.ThisExpr:
+			ix.names = append(ix.names, name{x.Token.Span(), sym})
+		case *cymbol.SuperExpr:
+			ix.names = append(ix.names, name{x.Token.Span(), sym})
+		}
+	}
+	slices.SortFunc(ix.names, func(a, b name) int {
+		switch {
+		case a.span.From.Before(b.span.From):
+			return -1
+		case b.span.From.Before(a.span.From):
+			return 1
+		}
+		return 0
+	})
+	Walk(&indexer{ix: ix, defs: defs}, f)
+	return ix
+}
+
+// indexer is the listener finding the extents of the scopes and where the
+// variables become visible.
+type indexer struct {
+	ix   *Index
+	defs *DefPhase
+}
+
+func (b *indexer) Enter(n cymbol.Node) {
+	var span cymbol.Span
+	switch n := n.(type) {
+	case *cymbol.FuncDecl:
+		span = cymbol.Span{From: n.Name.Token.Span().To, To: after(n.Body.Rbrace)}
+	case *cymbol.StructDecl:
+		span = cymbol.Span{From: n.Name.Token.Span().To, To: after(n.Rbrace)}
+	case *cymbol.ClassDecl:
+		from := n.Name
+		if n.Super != nil {
+			from = n.Super
+		}
+		span = cymbol.Span{From: from.Token.Span().To, To: after(n.Rbrace)}
+	case *cymbol.NamespaceDecl:
+		span = cymbol.Span{From: n.Name.Token.Span().To, To: after(n.Rbrace)}
+	case *cymbol.Block:
+		span = cymbol.Span{From: n.Lbrace, To: after(n.Rbrace)}
+	default:
+		return
+	}
+	b.ix.scopes = append(b.ix.scopes, extent{span, b.defs.scopes.Get(n)})
+}
+
+func (b *indexer) Exit(n cymbol.Node) {
+	d, ok := n.(*cymbol.VarDecl)
+	if !ok {
+		return
+	}
+	sym := b.defs.Defs[d.Name]
+	if _, ok := b.defs.visibleAt[sym]; !ok {
+		return
+	}
+	from := d.Name.Token.Span().To
+	if d.Value != nil {
+		from = end(d.Value)
+	}
+	b.ix.visibleFrom[sym] = from
+}
+
+// after returns the position right after the single character at p.
+func after(p cymbol.Pos) cymbol.Pos {
+	p.Col++
+	return p
+}
+
+// end returns the position right after the last token of x in the tree. The
+// closing parenthesis of a call isn't in it.
+func end(x cymbol.Expr) cymbol.Pos {
+	switch x := x.(type) {
+	case *cymbol.BinaryExpr:
+		return end(x.Y)
+	case *cymbol.UnaryExpr:
+		return end(x.X)
+	case *cymbol.CallExpr:
+		if len(x.Args) > 0 {
+			return end(x.Args[len(x.Args)-1])
+		}
+		return end(x.Fun)
+	case *cymbol.MemberExpr:
+		return x.Member.Token.Span().To
+	case *cymbol.Ident:
+		return x.Token.Span().To
+	case *cymbol.IntLit:
+		return x.Token.Span().To
+	case *cymbol.FloatLit:
+		return x.Token.Span().To
+	case *cymbol.CharLit:
+		return x.Token.Span().To
+	case *cymbol.StringLit:
+		return x.Token.Span().To
+	case *cymbol.BoolLit:
+		return x.Token.Span().To
+	case *cymbol.ThisExpr:
+		return x.Token.Span().To
+	case *cymbol.SuperExpr:
+		return x.Token.Span().To
+	}
+	return x.Pos()
+}
+
+// ScopeAt returns the innermost scope pos is in, the global scope if it's in
+// no other.
+func (ix *Index) ScopeAt(pos cymbol.Pos) Scope {
+	s := ix.globals
+	for _, e := range ix.scopes {
+		// the scopes that contain pos come one inside the other
+		if e.span.Contains(pos) {
+			s = e.scope
+		}
+	}
+	return s
+}
+
+// Visible returns the symbols that can be used at pos, from the innermost
+// scope out, each scope's in the order they're defined. The symbols hidden by
+// one with the same name further in are left out, and so are the variables
+// declared after pos. In a class, the members it inherits are visible too, and
+// in a scope with imports the symbols of the namespaces it imports.
+func (ix *Index) Visible(pos cymbol.Pos) []Symbol {
+	var visible []Symbol
+	seen := map[string]bool{}
+	for s := ix.ScopeAt(pos); s != nil; s = s.EnclosingScope() {
+		syms := slices.Clone(s.Symbols())
+		if c, ok := s.(*ClassSymbol); ok {
+			for k := c.Superclass; k != nil; k = k.Superclass {
+				syms = append(syms, k.Symbols()...)
+			}
+		}
+		if im, ok := s.(importer); ok {
+			for _, ns := range im.Imports() {
+				syms = append(syms, ns.Symbols()...)
+			}
+		}
+		for _, sym := range syms {
+			if from, ok := ix.visibleFrom[sym]; ok && pos.Before(from) {
+				continue
+			}
+			if !seen[sym.Name()] {
+				seen[sym.Name()] = true
+				visible = append(visible, sym)
+			}
+		}
+	}
+	return visible
+}
+
+// SymbolAt returns the symbol the name at pos defines or refers to, and the
+// span of the name. The symbol is nil if there's no name at pos, or it
+// couldn't be resolved.
+func (ix *Index) SymbolAt(pos cymbol.Pos) (Symbol, cymbol.Span) {
+	for _, n := range ix.names {
+		if n.span.Contains(pos) {
+			return n.sym, n.span
+		}
+	}
+	return nil, cymbol.Span{}
+}
+
+// Definition returns the name sym is declared with, nil for the built-in
+// types and for this and super, which aren't declared.
+func (ix *Index) Definition(sym Symbol) *cymbol.Ident {
+	return ix.decls[sym]
+}
+
+// DefinitionAt returns the name the symbol at pos is declared with, nil if
+// there's no such symbol or it isn't declared.
+func (ix *Index) DefinitionAt(pos cymbol.Pos) *cymbol.Ident {
+	sym, _ := ix.SymbolAt(pos)
+	return ix.Definition(sym)
+}
+
+// References returns the spans of the names that declare sym or refer to it,
+// in the order they're in the source.
+func (ix *Index) References(sym Symbol) []cymbol.Span {
+	if sym == nil {
+		return nil
+	}
+	var spans []cymbol.Span
+	for _, n := range ix.names {
+		if n.sym == sym {
+			spans = append(spans, n.span)
+		}
+	}
+	return spans
+}
+
+// ReferencesAt returns the References of the symbol of the name at pos, nil
+// if there's no such symbol.
+func (ix *Index) ReferencesAt(pos cymbol.Pos) []cymbol.Span {
+	sym, _ := ix.SymbolAt(pos)
+	return ix.References(sym)
+}
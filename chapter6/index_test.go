@@ -0,0 +1,167 @@
+package symtab
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+const indexSrc = `int x;
+float f(float a) {
+    int y = 2; x = y;
+    { int x = y + x; }
+}
+class A { int n; };
+class B : A { int m() { return this.n; } };`
+
+func newIndex(t *testing.T, src string) *Index {
+	t.Helper()
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs, refs := TwoPass(NewSymbolTable(), f)
+	if err := defs.Diagnostics.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return NewIndex(f, defs, refs)
+}
+
+func TestScopeAt(t *testing.T) {
+	ix := newIndex(t, indexSrc)
+	all := globals("<x:int>, <f:float>, A, B")
+	cases := []struct {
+		pos  cymbol.Pos
+		want string
+	}{
+		{cymbol.Pos{Line: 1, Col: 1}, all},
+		{cymbol.Pos{Line: 2, Col: 7}, all}, // the name of f is outside of it
+		{cymbol.Pos{Line: 2, Col: 9}, "f: [<a:float>]"},
+		{cymbol.Pos{Line: 3, Col: 5}, "local: [<y:int>]"},
+		{cymbol.Pos{Line: 4, Col: 11}, "local: [<x:int>]"},
+		{cymbol.Pos{Line: 4, Col: 22}, "local: [<x:int>]"},
+		{cymbol.Pos{Line: 5, Col: 1}, "local: [<y:int>]"},
+		{cymbol.Pos{Line: 5, Col: 2}, all},
+		{cymbol.Pos{Line: 6, Col: 15}, "A: [<n:int>]"},
+		{cymbol.Pos{Line: 7, Col: 11}, all}, // the superclass is outside too
+		{cymbol.Pos{Line: 7, Col: 15}, "B: [<m:int>]"},
+		{cymbol.Pos{Line: 7, Col: 32}, "local: []"},
+	}
+	for _, c := range cases {
+		if got := ScopeString(ix.ScopeAt(c.pos)); got != c.want {
+			t.Errorf("%v: want: %s, got: %s", c.pos, c.want, got)
+		}
+	}
+}
+
+func TestVisible(t *testing.T) {
+	ix := newIndex(t, indexSrc)
+	types := []string{"int", "float", "char", "boolean", "string", "void"}
+	cases := []struct {
+		pos  cymbol.Pos
+		want []string
+	}{
+		// globals too, x only once declared
+		{cymbol.Pos{Line: 1, Col: 1}, append(types, "f", "A", "B")},
+		// y is visible after its initializer
+		{cymbol.Pos{Line: 3, Col: 11}, append([]string{"a"}, append(types, "x", "f", "A", "B")...)},
+		{cymbol.Pos{Line: 3, Col: 16}, append([]string{"y", "a"}, append(types, "x", "f", "A", "B")...)},
+		// the x being declared isn't, the global one is
+		{cymbol.Pos{Line: 4, Col: 19}, append([]string{"y", "a"}, append(types, "x", "f", "A", "B")...)},
+		// inherited members
+		{cymbol.Pos{Line: 7, Col: 32}, append([]string{"m", "n"}, append(types, "x", "f", "A", "B")...)},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, sym := range ix.Visible(c.pos) {
+			got = append(got, sym.Name())
+		}
+		if diff := cmp.Diff(c.want, got); diff != "" {
+			t.Errorf("%v: (-want +got)\n%s", c.pos, diff)
+		}
+	}
+}
+
+func TestSymbolAt(t *testing.T) {
+	ix := newIndex(t, indexSrc)
+	cases := []struct {
+		pos  cymbol.Pos
+		sym  string // empty if there's no symbol
+		from string // where the name at pos starts
+		def  string // where the symbol is declared, empty if it isn't
+	}{
+		{cymbol.Pos{Line: 1, Col: 5}, "<x:int>", "1:5", "1:5"},
+		{cymbol.Pos{Line: 2, Col: 1}, "float", "2:1", ""},
+		{cymbol.Pos{Line: 3, Col: 1}, "", "", ""},
+		{cymbol.Pos{Line: 3, Col: 20}, "<y:int>", "3:20", "3:9"},
+		{cymbol.Pos{Line: 4, Col: 11}, "<x:int>", "4:11", "4:11"},
+		{cymbol.Pos{Line: 4, Col: 19}, "<x:int>", "4:19", "1:5"},
+		{cymbol.Pos{Line: 7, Col: 11}, "A", "7:11", "6:7"},
+		{cymbol.Pos{Line: 7, Col: 34}, "<this:B>", "7:32", ""},
+		{cymbol.Pos{Line: 7, Col: 37}, "<n:int>", "7:37", "6:15"},
+	}
+	for _, c := range cases {
+		sym, span := ix.SymbolAt(c.pos)
+		var got, from, def string
+		if sym != nil {
+			got, from = sym.String(), span.From.String()
+		}
+		if id := ix.DefinitionAt(c.pos); id != nil {
+			def = id.Pos().String()
+		}
+		if got != c.sym || from != c.from || def != c.def {
+			t.Errorf("%v: want: %q at %q declared at %q, got: %q at %q declared at %q", c.pos, c.sym, c.from, c.def, got, from, def)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	ix := newIndex(t, indexSrc)
+	cases := []struct {
+		pos  cymbol.Pos
+		want []string // where the references start
+	}{
+		{cymbol.Pos{Line: 1, Col: 5}, []string{"1:5", "3:16", "4:19"}},
+		{cymbol.Pos{Line: 3, Col: 20}, []string{"3:9", "3:20", "4:15"}},
+		{cymbol.Pos{Line: 4, Col: 11}, []string{"4:11"}}, // hides the global x
+		{cymbol.Pos{Line: 7, Col: 37}, []string{"6:15", "7:37"}},
+		{cymbol.Pos{Line: 7, Col: 11}, []string{"6:7", "7:11"}},
+		{cymbol.Pos{Line: 7, Col: 32}, []string{"7:32"}}, // this isn't declared
+		{cymbol.Pos{Line: 3, Col: 1}, nil},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, span := range ix.ReferencesAt(c.pos) {
+			got = append(got, span.From.String())
+		}
+		if !cmp.Equal(got, c.want) {
+			t.Errorf("%v: %s", c.pos, cmp.Diff(c.want, got))
+		}
+	}
+}
+
+func TestNamespaceIndex(t *testing.T) {
+	ix := newIndex(t, "namespace geo { int x; }\nimport geo;\nint y = x + geo.x;")
+	if got, want := ScopeString(ix.ScopeAt(cymbol.Pos{Line: 1, Col: 20})), "geo: [<x:int>]"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+
+	var visible []string
+	for _, sym := range ix.Visible(cymbol.Pos{Line: 3, Col: 9}) {
+		visible = append(visible, sym.Name())
+	}
+	// the imported x after the globals, y only after its initializer
+	want := []string{"int", "float", "char", "boolean", "string", "void", "geo", "x"}
+	if diff := cmp.Diff(want, visible); diff != "" {
+		t.Errorf("visible (-want +got)\n%s", diff)
+	}
+
+	var refs []string
+	for _, span := range ix.ReferencesAt(cymbol.Pos{Line: 3, Col: 9}) {
+		refs = append(refs, span.From.String())
+	}
+	if want := []string{"1:21", "3:9", "3:17"}; !cmp.Equal(refs, want) {
+		t.Errorf("references of x: %s", cmp.Diff(want, refs))
+	}
+}
@@ -0,0 +1,52 @@
+package symtab
+
+// Namespaces
+//
+// A namespace is a scope with a name, like a struct whose members are
+// declarations of any kind. It's nested in the scope it's declared in, so the
+// names used inside it resolve lexically, its own declarations first:
+//
+//	int size = 10;
+//	namespace geo {
+//	    struct point { int x; int y; };
+//	    int dist(point a, point b) { return size; }  // geo's point, global size
+//	}
+//
+// From outside, its declarations are used qualified by its name, `geo.dist`,
+// which resolves dist in geo only, the way a member resolves in a struct. An
+// import makes them usable without it: after `import geo;` the scope the
+// import is in resolves a name in its own symbols, then in the ones of each
+// namespace it imports, in order, and then in the enclosing scopes. So a
+// declaration of the scope hides an imported one, and two imports declaring
+// the same name leave the first one's. Names of types are only used
+// unqualified, so the types of a namespace are used by importing it.
+//
+// DefRef imports a namespace from the import on, while for RefPhase imports
+// are resolved first, like types, and hold in all of their scope. Imports
+// aren't transitive: importing geo doesn't import what geo imports.
+
+// NamespaceSymbol is a namespace, and the scope of its declarations.
+type NamespaceSymbol struct {
+	symbol
+	baseScope
+}
+
+func NewNamespaceSymbol(name string, enclosing Scope) *NamespaceSymbol {
+	n := &NamespaceSymbol{symbol: symbol{name: name}}
+	n.baseScope = newBaseScope(n, enclosing)
+	return n
+}
+
+func (n *NamespaceSymbol) ScopeName() string { return n.name }
+
+// ResolveMember returns the declaration called name, looking only in the
+// namespace itself. It's nil if there's no such declaration.
+func (n *NamespaceSymbol) ResolveMember(name string) Symbol {
+	return n.symbols[name]
+}
+
+// importer is a scope a namespace can be imported into.
+type importer interface {
+	Import(ns *NamespaceSymbol)
+	Imports() []*NamespaceSymbol
+}
@@ -0,0 +1,259 @@
+package symtab
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"example.com/cymbol"
+)
+
+// Renaming
+//
+// Renaming a symbol is the refactoring every editor has, and the symbol table
+// is what makes it more than a search and replace: the names to change are
+// the declaration of the symbol and the names resolved to it, not the other
+// names spelled the same. cymbol's Rewriter changes those in the source,
+// leaving the rest of the text, comments included, as it is.
+//
+// The program has to mean the same after the renaming, so it's refused when
+// the new name would change what a name resolves to:
+//
+//	int x;
+//	void f() {
+//	    int y = 1;
+//	    x = y;       // renaming x to y: this x would be the local y,
+//	}                // shadowed by it
+//
+// or the other way around, if a y used somewhere would be the renamed symbol
+// rather than the y it was, captured by it. Renaming to a name declared in the
+// same scope, or a member of the class or its superclasses, is refused as
+// well, it would be declared twice.
+
+// Rename renames a symbol of the Cymbol program src to name, and every use
+// of it, returning the new source. old is the name the symbol is declared
+// with, if the program declares only one symbol with that name, or the
+// line:col position of the declaration or of a use. The error is the syntax
+// error or the diagnostics of the program, or why the symbol can't be
+// renamed.
+func Rename(src, old, name string) (string, error) {
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return "", err
+	}
+	defs, refs := TwoPass(NewSymbolTable(), f)
+	if err := defs.Diagnostics.Err(); err != nil {
+		return "", err
+	}
+	r := &renamer{ix: NewIndex(f, defs, refs), defs: defs.Defs, refs: refs.Refs, name: name}
+	if r.sym, err = r.find(old); err != nil {
+		return "", err
+	}
+	if err := r.check(); err != nil {
+		return "", err
+	}
+	rw, err := cymbol.NewRewriter(src)
+	if err != nil {
+		return "", err
+	}
+	for id, sym := range r.defs {
+		if sym == r.sym {
+			rw.Rename(id, name)
+		}
+	}
+	for x, sym := range r.refs {
+		if sym != r.sym {
+			continue
+		}
+		switch x := x.(type) {
+		case *cymbol.Ident:
+			rw.Rename(x, name)
+		case *cymbol.MemberExpr:
+			rw.Rename(x.Member, name)
+		}
+	}
+	return rw.String(), nil
+}
+
+// renamer checks that sym can be renamed to name.
+type renamer struct {
+	ix   *Index
+	defs map[*cymbol.Ident]Symbol
+	refs map[cymbol.Expr]Symbol
+	sym  Symbol
+	name string
+}
+
+// find returns the symbol old stands for.
+func (r *renamer) find(old string) (Symbol, error) {
+	var pos cymbol.Pos
+	if _, err := fmt.Sscanf(old, "%d:%d", &pos.Line, &pos.Col); err == nil {
+		sym, _ := r.ix.SymbolAt(pos)
+		if sym == nil {
+			return nil, fmt.Errorf("no name at %v", pos)
+		}
+		if r.ix.Definition(sym) == nil {
+			return nil, fmt.Errorf("%s isn't declared in the program", sym.Name())
+		}
+		return sym, nil
+	}
+	var found []*cymbol.Ident
+	for id := range r.defs {
+		if id.Name == old {
+			found = append(found, id)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%s isn't declared in the program", old)
+	case 1:
+		return r.defs[found[0]], nil
+	}
+	slices.SortFunc(found, func(a, b *cymbol.Ident) int { return comparePos(a.Pos(), b.Pos()) })
+	var at []string
+	for _, id := range found {
+		at = append(at, id.Pos().String())
+	}
+	return nil, fmt.Errorf("%s is declared %d times, at %s: give the position of one", old, len(found), strings.Join(at, ", "))
+}
+
+// check reports the first name whose meaning would change.
+func (r *renamer) check() error {
+	if !isIdent(r.name) {
+		return fmt.Errorf("%q isn't a valid name", r.name)
+	}
+	decl := r.ix.Definition(r.sym)
+	if r.name == r.sym.Name() {
+		return nil
+	}
+	for _, other := range r.symbols(r.sym.Scope()) {
+		if other != r.sym && other.Name() == r.name {
+			return errorAt(decl.Pos(), "renaming %s to %s: %v is declared at %v in the same scope", r.sym.Name(), r.name, other, r.declared(other))
+		}
+	}
+	// in the order of the source, to report the first name
+	uses := slices.Collect(maps.Keys(r.refs))
+	slices.SortFunc(uses, func(a, b cymbol.Expr) int { return comparePos(namePos(a), namePos(b)) })
+	for _, x := range uses {
+		sym := r.refs[x]
+		var after Symbol
+		var pos cymbol.Pos
+		switch x := x.(type) {
+		case *cymbol.Ident:
+			if sym != r.sym && x.Name != r.name {
+				continue
+			}
+			pos, after = x.Pos(), r.resolve(x.Pos())
+		case *cymbol.MemberExpr:
+			if sym != r.sym && x.Member.Name != r.name {
+				continue
+			}
+			var in Scope
+			if ns, ok := r.refs[x.X].(*NamespaceSymbol); ok {
+				in = ns
+			} else if in, ok = r.refs[x.X].Type().(Scope); !ok {
+				continue
+			}
+			pos, after = x.Member.Pos(), r.member(in, r.name)
+		default:
+			continue
+		}
+		switch {
+		case sym == r.sym && after != sym:
+			return errorAt(pos, "renaming %s to %s: %s would be %v declared at %v", r.sym.Name(), r.name, r.sym.Name(), after, r.declared(after))
+		case sym != r.sym && after == r.sym:
+			return errorAt(pos, "renaming %s to %s: %s would be the renamed %s instead of %v", r.sym.Name(), r.name, r.name, r.sym.Name(), sym)
+		}
+	}
+	return nil
+}
+
+// resolve returns the symbol the name would resolve to at pos once renamed.
+func (r *renamer) resolve(pos cymbol.Pos) Symbol {
+	for s := r.ix.ScopeAt(pos); s != nil; s = s.EnclosingScope() {
+		syms := r.symbols(s)
+		if im, ok := s.(importer); ok {
+			for _, ns := range im.Imports() {
+				syms = append(syms[:len(syms):len(syms)], ns.Symbols()...)
+			}
+		}
+		for _, sym := range syms {
+			if from, ok := r.ix.visibleFrom[sym]; ok && pos.Before(from) {
+				continue
+			}
+			if r.renamed(sym) == r.name {
+				return sym
+			}
+		}
+	}
+	return nil
+}
+
+// member returns the symbol named name once renamed in the scope s, or among
+// the inherited members if s is a class. Nil if there's none.
+func (r *renamer) member(s Scope, name string) Symbol {
+	for _, sym := range r.symbols(s) {
+		if r.renamed(sym) == name {
+			return sym
+		}
+	}
+	return nil
+}
+
+// symbols returns the symbols defined in s, followed by the inherited ones if
+// it's a class.
+func (r *renamer) symbols(s Scope) []Symbol {
+	syms := s.Symbols()
+	if c, ok := s.(*ClassSymbol); ok {
+		for k := c.Superclass; k != nil; k = k.Superclass {
+			syms = append(syms[:len(syms):len(syms)], k.Symbols()...)
+		}
+	}
+	return syms
+}
+
+// renamed returns the name of sym once renamed.
+func (r *renamer) renamed(sym Symbol) string {
+	if sym == r.sym {
+		return r.name
+	}
+	return sym.Name()
+}
+
+// declared returns where sym is declared, or that it's built in.
+func (r *renamer) declared(sym Symbol) string {
+	if id := r.ix.Definition(sym); id != nil {
+		return id.Pos().String()
+	}
+	return "built-in"
+}
+
+// namePos returns the position of the name x is resolved by: the member of a
+// member access.
+func namePos(x cymbol.Expr) cymbol.Pos {
+	if m, ok := x.(*cymbol.MemberExpr); ok {
+		return m.Member.Pos()
+	}
+	return x.Pos()
+}
+
+func comparePos(a, b cymbol.Pos) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case b.Before(a):
+		return 1
+	}
+	return 0
+}
+
+// isIdent tells whether s is an identifier of Cymbol, and not a keyword.
+func isIdent(s string) bool {
+	toks, err := cymbol.NewTokenStream(s)
+	return err == nil && len(toks.Tokens) == 2 && toks.Tokens[0].Type == cymbol.ID && toks.Tokens[0].Text == s
+}
+
+func errorAt(pos cymbol.Pos, format string, args ...any) error {
+	return &cymbol.Error{Pos: pos, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,170 @@
+package symtab
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRename(t *testing.T) {
+	cases := []struct {
+		name    string
+		src     string
+		old     string
+		to      string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "variable and its uses",
+			src:  "int x = 1; // the x\nvoid f() {\n\tx = x + 1;\n}\n",
+			old:  "x",
+			to:   "count",
+			want: "int count = 1; // the x\nvoid f() {\n\tcount = count + 1;\n}\n",
+		},
+		{
+			name: "only the symbol at a position",
+			src:  "int x; void f(int x) { x = 1; } void g() { x = 2; }",
+			old:  "1:24",
+			to:   "y",
+			want: "int x; void f(int y) { y = 1; } void g() { x = 2; }",
+		},
+		{
+			name: "types and members",
+			src:  "struct P { int x; }; class A { P p; }; class B : A { void f() { p.x = this.p.x; } }; P q;",
+			old:  "P",
+			to:   "Point",
+			want: "struct Point { int x; }; class A { Point p; }; class B : A { void f() { p.x = this.p.x; } }; Point q;",
+		},
+		{
+			name: "field",
+			src:  "struct P { int x; }; P p; int x = p.x;",
+			old:  "1:16",
+			to:   "y",
+			want: "struct P { int y; }; P p; int x = p.y;",
+		},
+		{
+			name: "function used before its declaration",
+			src:  "void f() { g(); } void g() { g(); }",
+			old:  "g",
+			to:   "h",
+			want: "void f() { h(); } void h() { h(); }",
+		},
+		{
+			name: "namespace member",
+			src:  "namespace geo { int x; void f() { x = 1; } } import geo; int y = geo.x + x;",
+			old:  "1:21",
+			to:   "size",
+			want: "namespace geo { int size; void f() { size = 1; } } import geo; int y = geo.size + size;",
+		},
+		{
+			name: "same name",
+			src:  "int x;",
+			old:  "x",
+			to:   "x",
+			want: "int x;",
+		},
+		{
+			name: "shadowing a later variable is fine",
+			src:  "int x; void f() { x = 1; int y; }",
+			old:  "x",
+			to:   "y",
+			want: "int y; void f() { y = 1; int y; }",
+		},
+		{
+			name:    "ambiguous",
+			src:     "int x; void f() { float x; }",
+			old:     "x",
+			to:      "y",
+			wantErr: "x is declared 2 times, at 1:5, 1:25: give the position of one",
+		},
+		{
+			name:    "not declared",
+			src:     "int x;",
+			old:     "int",
+			to:      "integer",
+			wantErr: "int isn't declared in the program",
+		},
+		{
+			name:    "no name at position",
+			src:     "int x;",
+			old:     "1:4",
+			to:      "y",
+			wantErr: "no name at 1:4",
+		},
+		{
+			name:    "invalid name",
+			src:     "int x;",
+			old:     "x",
+			to:      "while",
+			wantErr: `"while" isn't a valid name`,
+		},
+		{
+			name:    "declared in the same scope",
+			src:     "int x; float y;",
+			old:     "x",
+			to:      "y",
+			wantErr: "1:5: renaming x to y: <y:float> is declared at 1:14 in the same scope",
+		},
+		{
+			name:    "inherited member",
+			src:     "class A { int y; }; class B : A { int x; };",
+			old:     "x",
+			to:      "y",
+			wantErr: "1:39: renaming x to y: <y:int> is declared at 1:15 in the same scope",
+		},
+		{
+			name:    "shadowed",
+			src:     "int x;\nvoid f() {\n\tint y = 1;\n\tx = y;\n}",
+			old:     "x",
+			to:      "y",
+			wantErr: "4:2: renaming x to y: x would be <y:int> declared at 3:6",
+		},
+		{
+			name:    "captured",
+			src:     "int y; void f(int x) { y = x; }",
+			old:     "x",
+			to:      "y",
+			wantErr: "1:24: renaming x to y: y would be the renamed x instead of <y:int>",
+		},
+		{
+			name:    "hiding an import",
+			src:     "namespace n { int y; } import n; int x; void f() { y = 1; }",
+			old:     "x",
+			to:      "y",
+			wantErr: "1:52: renaming x to y: y would be the renamed x instead of <y:int>",
+		},
+		{
+			name:    "member shadowed by a subclass",
+			src:     "class A { int x; }; class B : A { int y; }; B b; int z = b.x;",
+			old:     "1:15",
+			to:      "y",
+			wantErr: "1:60: renaming x to y: x would be <y:int> declared at 1:39",
+		},
+		{
+			name:    "errors in the program",
+			src:     "int x = z;",
+			old:     "x",
+			to:      "y",
+			wantErr: "1:9: undefined: z",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Rename(tc.src, tc.old, tc.to)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Error(cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package symtab
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Pattern 17:
+// Symbol Table for Nested Scopes
+
+// Most languages have more than one scope: the names declared inside a
+// function or a block are only visible there, and may hide names declared
+// outside. The scopes nest, each one has an enclosing scope up to the global
+// scope, and resolving a name looks for it in the current scope first and
+// then in each enclosing scope in turn, so the innermost definition wins.
+//
+// A function is both a symbol, defined in the scope around it, and a scope
+// holding its parameters. The body of the function is a local scope nested
+// in the function's, and each block in it nests another one:
+//
+//	int x;              // global: [..., <x:int>, <f:void>]
+//	void f(int a) {     // f: [<a:int>]
+//	    float x;        // local: [<x:float>]
+//	    { int y; }      // local: [<y:int>]
+//	}
+
+// Scope is implemented by every kind of scope.
+type Scope interface {
+	ScopeName() string
+	EnclosingScope() Scope // nil for the global scope
+	Define(sym Symbol)
+	Resolve(name string) Symbol // looks in the enclosing scopes too
+	Symbols() []Symbol          // defined in this scope, in order
+}
+
+// baseScope keeps the symbols of a scope, it's embedded in the concrete
+// scopes.
+type baseScope struct {
+	self      Scope // the scope embedding this one
+	enclosing Scope
+	symbols   map[string]Symbol
+	order     []Symbol
+	imports   []*NamespaceSymbol
+}
+
+func newBaseScope(self, enclosing Scope) baseScope {
+	return baseScope{self: self, enclosing: enclosing, symbols: map[string]Symbol{}}
+}
+
+func (s *baseScope) EnclosingScope() Scope { return s.enclosing }
+
+// Define adds sym to the scope. A symbol with the same name in the same
+// scope is replaced, keeping its place in the order.
+func (s *baseScope) Define(sym Symbol) {
+	if old, ok := s.symbols[sym.Name()]; ok {
+		s.order[slices.Index(s.order, old)] = sym
+	} else {
+		s.order = append(s.order, sym)
+	}
+	s.symbols[sym.Name()] = sym
+	sym.setScope(s.self)
+}
+
+// Resolve returns the symbol defined with name in the closest scope, nil if
+// there's none. A scope's imports come after its own symbols.
+func (s *baseScope) Resolve(name string) Symbol {
+	if sym, ok := s.symbols[name]; ok {
+		return sym
+	}
+	for _, ns := range s.imports {
+		if sym, ok := ns.symbols[name]; ok {
+			return sym
+		}
+	}
+	if s.enclosing != nil {
+		return s.enclosing.Resolve(name)
+	}
+	return nil
+}
+
+func (s *baseScope) Symbols() []Symbol { return s.order }
+
+// Import makes the symbols of ns resolvable in the scope, see namespace.go.
+// Importing a namespace twice imports it once.
+func (s *baseScope) Import(ns *NamespaceSymbol) {
+	if !slices.Contains(s.imports, ns) {
+		s.imports = append(s.imports, ns)
+	}
+}
+
+// Imports returns the namespaces imported into the scope, in order.
+func (s *baseScope) Imports() []*NamespaceSymbol { return s.imports }
+
+// GlobalScope is the outermost scope, where the built-in types, global
+// variables and functions are.
+type GlobalScope struct {
+	baseScope
+}
+
+func NewGlobalScope() *GlobalScope {
+	s := &GlobalScope{}
+	s.baseScope = newBaseScope(s, nil)
+	return s
+}
+
+func (s *GlobalScope) ScopeName() string { return "global" }
+
+// Snapshot returns a function that puts the symbols of the scope back the way
+// they are now, undoing the definitions made in between. A REPL undoes the
+// ones of an input that has errors, so that it can be entered again fixed.
+func (s *GlobalScope) Snapshot() (restore func()) {
+	symbols, order, imports := maps.Clone(s.symbols), slices.Clone(s.order), slices.Clone(s.imports)
+	return func() { s.symbols, s.order, s.imports = symbols, order, imports }
+}
+
+// LocalScope is the scope of a block.
+type LocalScope struct {
+	baseScope
+}
+
+func NewLocalScope(enclosing Scope) *LocalScope {
+	s := &LocalScope{}
+	s.baseScope = newBaseScope(s, enclosing)
+	return s
+}
+
+func (s *LocalScope) ScopeName() string { return "local" }
+
+// ScopeString renders the symbols of a scope as the book does,
+// `global: [int, ..., <x:int>]`.
+func ScopeString(s Scope) string {
+	names := make([]string, len(s.Symbols()))
+	for i, sym := range s.Symbols() {
+		names[i] = sym.String()
+	}
+	return s.ScopeName() + ": [" + strings.Join(names, ", ") + "]"
+}
@@ -0,0 +1,212 @@
+package symtab
+
+import "testing"
+
+func TestNestedScopes(t *testing.T) {
+	table := NewSymbolTable()
+	intType := table.Globals.Resolve("int").(Type)
+	floatType := table.Globals.Resolve("float").(Type)
+
+	x := NewVariableSymbol("x", intType)
+	table.Globals.Define(x)
+	f := NewFunctionSymbol("f", intType, table.Globals)
+	table.Globals.Define(f)
+	a := NewVariableSymbol("a", intType)
+	f.Define(a)
+	body := NewLocalScope(f)
+	innerX := NewVariableSymbol("x", floatType)
+	body.Define(innerX)
+
+	cases := []struct {
+		scope Scope
+		name  string
+		want  Symbol
+	}{
+		{scope: body, name: "x", want: innerX}, // shadows the global x
+		{scope: body, name: "a", want: a},
+		{scope: body, name: "f", want: f},
+		{scope: body, name: "int", want: intType.(Symbol)},
+		{scope: f, name: "x", want: x},
+		{scope: table.Globals, name: "x", want: x},
+		{scope: table.Globals, name: "a", want: nil},
+		{scope: body, name: "y", want: nil},
+	}
+	for _, tc := range cases {
+		if got := tc.scope.Resolve(tc.name); got != tc.want {
+			t.Errorf("%s in %s: want: %v, got: %v", tc.name, tc.scope.ScopeName(), tc.want, got)
+		}
+	}
+
+	for sym, scope := range map[Symbol]Scope{x: table.Globals, f: table.Globals, a: f, innerX: body} {
+		if sym.Scope() != scope {
+			t.Errorf("%v: want: defined in %s, got: %v", sym, scope.ScopeName(), sym.Scope())
+		}
+	}
+	if got := f.Params(); len(got) != 1 || got[0] != a {
+		t.Errorf("want: params [%v], got: %v", a, got)
+	}
+	if body.EnclosingScope() != f || f.EnclosingScope() != table.Globals || table.Globals.EnclosingScope() != nil {
+		t.Error("wrong enclosing scopes")
+	}
+	if got, want := ScopeString(body), "local: [<x:float>]"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+	if got, want := ScopeString(f), "f: [<a:int>]"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
+
+func TestStructScope(t *testing.T) {
+	table := NewSymbolTable()
+	intType := table.Globals.Resolve("int").(Type)
+	x := NewVariableSymbol("x", intType)
+	table.Globals.Define(x)
+	s := NewStructSymbol("A", table.Globals)
+	table.Globals.Define(s)
+	y := NewVariableSymbol("y", intType)
+	s.Define(y)
+
+	if got := s.ResolveMember("y"); got != y {
+		t.Errorf("want: %v, got: %v", y, got)
+	}
+	// members are only looked up in the struct, names in enclosing scopes too
+	if got := s.ResolveMember("x"); got != nil {
+		t.Errorf("want: nil, got: %v", got)
+	}
+	if got := s.Resolve("x"); got != x {
+		t.Errorf("want: %v, got: %v", x, got)
+	}
+	if _, ok := Symbol(s).(Type); !ok {
+		t.Error("a struct is a type")
+	}
+	if got, want := ScopeString(s), "A: [<y:int>]"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
+
+func TestClassScope(t *testing.T) {
+	table := NewSymbolTable()
+	intType := table.Globals.Resolve("int").(Type)
+	gx := NewVariableSymbol("x", intType)
+	gz := NewVariableSymbol("z", intType)
+	table.Globals.Define(gx)
+	table.Globals.Define(gz)
+
+	a := NewClassSymbol("A", nil, table.Globals)
+	table.Globals.Define(a)
+	ax := NewVariableSymbol("x", intType)
+	a.Define(ax)
+	b := NewClassSymbol("B", a, table.Globals)
+	table.Globals.Define(b)
+	by := NewVariableSymbol("y", intType)
+	b.Define(by)
+	m := NewFunctionSymbol("m", intType, b)
+	b.Define(m)
+	local := NewLocalScope(m)
+
+	cases := []struct {
+		name string
+		want Symbol
+	}{
+		{"y", by},
+		{"x", ax}, // inherited before the global x
+		{"z", gz},
+		{"m", m},
+		{"w", nil},
+	}
+	for _, tc := range cases {
+		if got := local.Resolve(tc.name); got != tc.want {
+			t.Errorf("resolve %s: want: %v, got: %v", tc.name, tc.want, got)
+		}
+	}
+
+	if got := b.ResolveMember("x"); got != ax {
+		t.Errorf("want: %v, got: %v", ax, got)
+	}
+	if got := b.ResolveMember("z"); got != nil {
+		t.Errorf("want: nil, got: %v", got)
+	}
+	if got := a.ResolveMember("y"); got != nil {
+		t.Errorf("a superclass doesn't see the members of its subclasses, got: %v", got)
+	}
+	if got, want := ScopeString(b), "B: [<y:int>, <m:int>]"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
+
+func TestNamespaceScope(t *testing.T) {
+	table := NewSymbolTable()
+	intType := table.Globals.Resolve("int").(Type)
+	gx := NewVariableSymbol("x", intType)
+	table.Globals.Define(gx)
+
+	geo := NewNamespaceSymbol("geo", table.Globals)
+	table.Globals.Define(geo)
+	geoX := NewVariableSymbol("x", intType)
+	geoY := NewVariableSymbol("y", intType)
+	geo.Define(geoX)
+	geo.Define(geoY)
+	unit := NewNamespaceSymbol("unit", table.Globals)
+	table.Globals.Define(unit)
+	unitY := NewVariableSymbol("y", intType)
+	unitZ := NewVariableSymbol("z", intType)
+	unit.Define(unitY)
+	unit.Define(unitZ)
+
+	table.Globals.Import(geo)
+	table.Globals.Import(unit)
+	table.Globals.Import(geo)
+	local := NewLocalScope(NewFunctionSymbol("f", nil, table.Globals))
+
+	cases := []struct {
+		name string
+		want Symbol
+	}{
+		{"x", gx},    // the global's own before the imported one
+		{"y", geoY},  // the first import's
+		{"z", unitZ}, // through the enclosing scopes
+		{"w", nil},
+	}
+	for _, tc := range cases {
+		if got := local.Resolve(tc.name); got != tc.want {
+			t.Errorf("resolve %s: want: %v, got: %v", tc.name, tc.want, got)
+		}
+	}
+
+	if got := geo.ResolveMember("x"); got != geoX {
+		t.Errorf("want: %v, got: %v", geoX, got)
+	}
+	if got := geo.ResolveMember("int"); got != nil {
+		t.Errorf("a member isn't looked up outside of the namespace, got: %v", got)
+	}
+	if got := geo.Resolve("int"); got != intType.(Symbol) {
+		t.Errorf("a name is, got: %v", got)
+	}
+	if got := len(table.Globals.Imports()); got != 2 {
+		t.Errorf("want geo imported once, got %d imports", got)
+	}
+	if _, ok := Symbol(geo).(Type); ok {
+		t.Error("a namespace isn't a type")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	globals := NewGlobalScope()
+	x := NewVariableSymbol("x", nil)
+	globals.Define(x)
+	restore := globals.Snapshot()
+	globals.Define(NewVariableSymbol("x", nil))
+	globals.Define(NewVariableSymbol("y", nil))
+	globals.Import(NewNamespaceSymbol("n", globals))
+
+	restore()
+	if got, want := ScopeString(globals), "global: [x]"; got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+	if got := globals.Resolve("x"); got != x {
+		t.Errorf("want the first x, got: %v", got)
+	}
+	if got := globals.Imports(); len(got) != 0 {
+		t.Errorf("want no imports, got: %v", got)
+	}
+}
@@ -0,0 +1,89 @@
+package symtab
+
+import "example.com/cymbol"
+
+// Semantic tokens
+//
+// A highlighter working from the lexer, like cymbol.Highlight, can only tell
+// a name from a keyword. Editors also color names by what they are: where a
+// variable is declared rather than used, a type rather than a variable, a
+// parameter rather than a global. That takes the symbol table: the Index
+// knows the symbol of each name and where it's declared, so Classify gives
+// each token of a program its category, and the names their symbol:
+//
+//	struct point { int x; };     point: definition, int: type, x: definition
+//	point p;                     point: type, p: definition
+//	void f() { p.x = 1; }        p, x: reference, 1: literal
+//
+// The built-in type names are identifiers to the lexer, they're types here,
+// and true and false are literals rather than keywords. A name the program
+// doesn't declare is a reference without a symbol. Punctuation and operators
+// have no category, editors leave them as they are.
+
+// Category is what a token is to an editor.
+type Category int
+
+const (
+	CategoryKeyword    Category = iota
+	CategoryType                // a type name, where it's used
+	CategoryDefinition          // a name where it's declared, types included
+	CategoryReference           // a name that isn't a type, where it's used
+	CategoryLiteral
+)
+
+var categoryNames = map[Category]string{
+	CategoryKeyword:    "keyword",
+	CategoryType:       "type",
+	CategoryDefinition: "definition",
+	CategoryReference:  "reference",
+	CategoryLiteral:    "literal",
+}
+
+func (c Category) String() string { return categoryNames[c] }
+
+// SemanticToken is a token and its category. Symbol is the one a name
+// declares or refers to, nil for the other tokens and the names that don't
+// resolve.
+type SemanticToken struct {
+	Token    cymbol.Token
+	Category Category
+	Symbol   Symbol
+}
+
+// Classify returns the tokens of the indexed program that have a category,
+// in order. toks are the tokens of its source, as in a cymbol.TokenStream.
+func (ix *Index) Classify(toks []cymbol.Token) []SemanticToken {
+	syms := map[cymbol.Pos]Symbol{}
+	for _, n := range ix.names {
+		syms[n.span.From] = n.sym
+	}
+	var sts []SemanticToken
+	for _, tok := range toks {
+		st := SemanticToken{Token: tok}
+		switch tok.Type {
+		case cymbol.ID:
+			st.Symbol = syms[tok.Pos]
+			st.Category = ix.category(st.Symbol, tok)
+		case cymbol.Int, cymbol.Float, cymbol.Char, cymbol.String, cymbol.True, cymbol.False:
+			st.Category = CategoryLiteral
+		case cymbol.If, cymbol.Else, cymbol.While, cymbol.Return, cymbol.Struct, cymbol.Class, cymbol.This, cymbol.Super,
+			cymbol.Namespace, cymbol.Import:
+			st.Category = CategoryKeyword
+		default:
+			continue
+		}
+		sts = append(sts, st)
+	}
+	return sts
+}
+
+// category is the category of the name tok, whose symbol is sym.
+func (ix *Index) category(sym Symbol, tok cymbol.Token) Category {
+	if id := ix.Definition(sym); id != nil && id.Token.Pos == tok.Pos {
+		return CategoryDefinition
+	}
+	if _, ok := sym.(Type); ok {
+		return CategoryType
+	}
+	return CategoryReference
+}
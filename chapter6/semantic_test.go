@@ -0,0 +1,47 @@
+package symtab
+
+import (
+	"fmt"
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClassify(t *testing.T) {
+	src := `struct point { int x; };
+point p;
+class A { boolean f() { return this.f() == true; } };
+void g(float a) { p.x = 'c'; y = a; if (a > 1.5) g("s"); }`
+	want := []string{
+		"struct keyword", "point definition point", "int type int", "x definition <x:int>",
+		"point type point", "p definition <p:point>",
+		"class keyword", "A definition A", "boolean type boolean", "f definition <f:boolean>",
+		"return keyword", "this keyword", "f reference <f:boolean>", "true literal",
+		"void type void", "g definition <g:void>", "float type float", "a definition <a:float>",
+		"p reference <p:point>", "x reference <x:int>", "'c' literal", "y reference", "a reference <a:float>",
+		"if keyword", "a reference <a:float>", "1.5 literal", "g reference <g:void>", `"s" literal`,
+	}
+
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := cymbol.NewTokenStream(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs, refs := TwoPass(NewSymbolTable(), f)
+	ix := NewIndex(f, defs, refs)
+	var got []string
+	for _, st := range ix.Classify(ts.Tokens) {
+		s := fmt.Sprintf("%s %v", st.Token.Text, st.Category)
+		if st.Symbol != nil {
+			s += " " + st.Symbol.String()
+		}
+		got = append(got, s)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
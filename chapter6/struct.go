@@ -0,0 +1,43 @@
+package symtab
+
+// Pattern 18:
+// Symbol Table for Data Aggregates
+
+// A struct is both a type and a scope: its fields are symbols defined inside
+// it. The struct's scope is nested in the scope the struct is declared in,
+// like any other, so the types of its fields resolve lexically:
+//
+//	struct A {
+//	    struct B { int y; };
+//	    B b;                  // B resolves in A, then in the global scope
+//	};
+//
+// Member access works differently. In `a.b.y` only a is resolved lexically;
+// b is looked up in the struct that is the type of a, and y in the struct
+// that is the type of a.b, without going to any enclosing scope: `a.x` must
+// not find a global x just because A has no field x.
+
+// StructSymbol is a struct type, and the scope of its fields.
+type StructSymbol struct {
+	symbol
+	baseScope
+}
+
+func NewStructSymbol(name string, enclosing Scope) *StructSymbol {
+	s := &StructSymbol{symbol: symbol{name: name}}
+	s.baseScope = newBaseScope(s, enclosing)
+	return s
+}
+
+func (*StructSymbol) isType() {}
+
+func (s *StructSymbol) ScopeName() string { return s.name }
+
+// ResolveMember returns the field called name, looking only in the struct
+// itself. It's nil if there's no such field.
+func (s *StructSymbol) ResolveMember(name string) Symbol {
+	return s.symbols[name]
+}
+
+// Fields returns the fields in order, nested structs included.
+func (s *StructSymbol) Fields() []Symbol { return s.Symbols() }
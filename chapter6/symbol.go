@@ -0,0 +1,92 @@
+package symtab
+
+// Pattern 16:
+// Symbol Table for Monolithic Scope
+
+// A symbol is a name the program defines: a variable, a function or a type.
+// The symbol table remembers each definition so that later uses of the name,
+// the references, can be resolved to what they mean. In the simplest
+// languages every name lives in one single scope, the monolithic scope: it
+// doesn't matter where a variable is declared, it's visible everywhere after
+// that.
+//
+// Types are symbols too, which is why the built-in types are defined in the
+// table before anything else. `int x;` is then a reference to the symbol int
+// followed by the definition of the variable x, whose type is int.
+
+// Symbol is implemented by every kind of symbol.
+type Symbol interface {
+	Name() string
+	Type() Type   // nil for symbols that have no type, such as types themselves
+	Scope() Scope // where the symbol is defined
+	String() string
+	setScope(Scope)
+	setType(Type)
+}
+
+// Type is implemented by the symbols that are types.
+type Type interface {
+	Name() string
+	isType()
+}
+
+// symbol has what every symbol has, it's embedded in the concrete symbols.
+type symbol struct {
+	name  string
+	typ   Type
+	scope Scope
+}
+
+func (s *symbol) Name() string         { return s.name }
+func (s *symbol) Type() Type           { return s.typ }
+func (s *symbol) Scope() Scope         { return s.scope }
+func (s *symbol) setScope(scope Scope) { s.scope = scope }
+func (s *symbol) setType(typ Type)     { s.typ = typ }
+
+// String renders a symbol as `<name:type>`, just its name if it has no type.
+func (s *symbol) String() string {
+	if s.typ == nil {
+		return s.name
+	}
+	return "<" + s.name + ":" + s.typ.Name() + ">"
+}
+
+// BuiltInTypeSymbol is a type the language has without being declared, such
+// as int.
+type BuiltInTypeSymbol struct {
+	symbol
+}
+
+func NewBuiltInTypeSymbol(name string) *BuiltInTypeSymbol {
+	return &BuiltInTypeSymbol{symbol{name: name}}
+}
+
+func (*BuiltInTypeSymbol) isType() {}
+
+// VariableSymbol is a variable or a function parameter.
+type VariableSymbol struct {
+	symbol
+}
+
+func NewVariableSymbol(name string, typ Type) *VariableSymbol {
+	return &VariableSymbol{symbol{name: name, typ: typ}}
+}
+
+// FunctionSymbol is a function, its type is the type it returns. It's also
+// the scope of its parameters, enclosed by the scope the function is defined
+// in.
+type FunctionSymbol struct {
+	symbol
+	baseScope
+}
+
+func NewFunctionSymbol(name string, ret Type, enclosing Scope) *FunctionSymbol {
+	f := &FunctionSymbol{symbol: symbol{name: name, typ: ret}}
+	f.baseScope = newBaseScope(f, enclosing)
+	return f
+}
+
+func (f *FunctionSymbol) ScopeName() string { return f.name }
+
+// Params returns the parameters in order.
+func (f *FunctionSymbol) Params() []Symbol { return f.Symbols() }
@@ -0,0 +1,28 @@
+// Package symtab has the symbol tables of the book's chapters 6 and 7 for
+// Cymbol: symbols, nested scopes, structs and classes, and the listeners that
+// define and resolve the symbols of a program. The later chapters build on it.
+package symtab
+
+// SymbolTable holds the global scope, the root of all the others.
+type SymbolTable struct {
+	Globals *GlobalScope
+}
+
+// NewSymbolTable returns a table with the built-in types already defined.
+func NewSymbolTable() *SymbolTable {
+	t := &SymbolTable{Globals: NewGlobalScope()}
+	t.initTypeSystem()
+	return t
+}
+
+// initTypeSystem defines the types of Cymbol, the ones the book uses plus
+// the types of the literals.
+func (t *SymbolTable) initTypeSystem() {
+	for _, name := range []string{"int", "float", "char", "boolean", "string", "void"} {
+		t.Globals.Define(NewBuiltInTypeSymbol(name))
+	}
+}
+
+func (t *SymbolTable) String() string {
+	return ScopeString(t.Globals)
+}
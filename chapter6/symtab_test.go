@@ -0,0 +1,34 @@
+package symtab
+
+import "testing"
+
+func TestSymbolTable(t *testing.T) {
+	table := NewSymbolTable()
+	for _, name := range []string{"int", "float", "char", "boolean", "string", "void"} {
+		if _, ok := table.Globals.Resolve(name).(*BuiltInTypeSymbol); !ok {
+			t.Errorf("%s: want: built-in type, got: %v", name, table.Globals.Resolve(name))
+		}
+	}
+	if sym := table.Globals.Resolve("x"); sym != nil {
+		t.Errorf("want: nil, got: %v", sym)
+	}
+
+	intType := table.Globals.Resolve("int").(Type)
+	x := NewVariableSymbol("x", intType)
+	table.Globals.Define(x)
+	if got := table.Globals.Resolve("x"); got != x {
+		t.Errorf("want: %v, got: %v", x, got)
+	}
+
+	// a new definition replaces the old one, keeping its place
+	table.Globals.Define(NewFunctionSymbol("f", nil, table.Globals))
+	x2 := NewVariableSymbol("x", table.Globals.Resolve("float").(Type))
+	table.Globals.Define(x2)
+	if got := table.Globals.Resolve("x"); got != x2 {
+		t.Errorf("want: %v, got: %v", x2, got)
+	}
+	want := "global: [int, float, char, boolean, string, void, <x:float>, f]"
+	if got := table.String(); got != want {
+		t.Errorf("want: %s, got: %s", want, got)
+	}
+}
@@ -0,0 +1,214 @@
+package symtab
+
+import "example.com/cymbol"
+
+// Two-pass resolution
+//
+// DefRef resolves each name as soon as it walks past it, so anything used has
+// to be declared before, which is why it defines the members of a class ahead
+// of time. Resolving in two passes lifts that restriction: DefPhase walks the
+// tree defining every symbol in its scope, then RefPhase walks it again and
+// resolves the names, with all the symbols there already. So
+//
+//	void f() { g(); }      // g is declared below
+//	void g() { }
+//	struct A { B b; };     // and so is B
+//	struct B { int x; };
+//
+// resolve fine, and a name is reported undefined only when it's declared
+// nowhere it could be seen from. Before resolving any expression RefPhase
+// resolves the imports, the superclasses of classes and then the types of all
+// the declarations, so that members can be looked up in the type of any
+// variable.
+//
+// Variables that aren't fields are the exception, as in C they can only be
+// used after their declaration. If x is used in a block before `int x;`, the
+// x declared further out is the one used, and if there's none the use is
+// reported. Functions, structs, classes, fields and methods can be used
+// anywhere in their scope.
+//
+// The passes don't need a whole file: a REPL walks each declaration and
+// statement of an input in turn, with the same DefPhase and then the same
+// RefPhase, defining and resolving them in the global scope.
+
+// DefPhase is the listener of the first pass, it defines the symbols of the
+// program in their scopes, without resolving their types.
+type DefPhase struct {
+	resolver
+	Table *SymbolTable
+
+	scopes  cymbol.Attr[Scope] // opened by functions, structs, classes, namespaces and blocks
+	typings []typing           // the types and superclasses to resolve
+	imports []typing           // the namespaces to import, in the scope of sym
+
+	// Events are numbered in the order they happen in the walk, to compare
+	// where a variable becomes visible with where a name is used.
+	seq       int
+	visibleAt map[Symbol]int
+	usedAt    map[*cymbol.Ident]int
+}
+
+// typing is the name of the type of sym, or of its superclass if sym is a
+// class, to be resolved in scope. An import is a typing without sym, for the
+// name of a namespace.
+type typing struct {
+	sym   Symbol
+	name  *cymbol.Ident
+	scope Scope
+}
+
+func NewDefPhase(table *SymbolTable) *DefPhase {
+	return &DefPhase{
+		resolver:  newResolver(table.Globals),
+		Table:     table,
+		visibleAt: map[Symbol]int{},
+		usedAt:    map[*cymbol.Ident]int{},
+	}
+}
+
+func (d *DefPhase) Enter(n cymbol.Node) {
+	d.seq++
+	switch n := n.(type) {
+	case *cymbol.VarDecl:
+		d.usedAt[n.Type] = d.seq
+	case *cymbol.Param:
+		d.usedAt[n.Type] = d.seq
+		d.defineTyped(n.Name, NewVariableSymbol(n.Name.Name, nil), n.Type)
+	case *cymbol.FuncDecl:
+		d.usedAt[n.Type] = d.seq
+		f := NewFunctionSymbol(n.Name.Name, nil, d.current)
+		d.defineTyped(n.Name, f, n.Type)
+		d.open(n, f)
+	case *cymbol.StructDecl:
+		s := NewStructSymbol(n.Name.Name, d.current)
+		d.define(n.Name, s)
+		d.open(n, s)
+	case *cymbol.ClassDecl:
+		c := NewClassSymbol(n.Name.Name, nil, d.current)
+		if n.Super != nil {
+			d.usedAt[n.Super] = d.seq
+			d.defineTyped(n.Name, c, n.Super)
+		} else {
+			d.define(n.Name, c)
+		}
+		d.open(n, c)
+	case *cymbol.NamespaceDecl:
+		ns := NewNamespaceSymbol(n.Name.Name, d.current)
+		d.define(n.Name, ns)
+		d.open(n, ns)
+	case *cymbol.ImportDecl:
+		d.usedAt[n.Name] = d.seq
+		d.imports = append(d.imports, typing{name: n.Name, scope: d.current})
+	case *cymbol.Block:
+		d.open(n, NewLocalScope(d.current))
+	case *cymbol.Ident:
+		d.usedAt[n] = d.seq
+	}
+}
+
+func (d *DefPhase) Exit(n cymbol.Node) {
+	d.seq++
+	switch n := n.(type) {
+	case *cymbol.VarDecl:
+		v := NewVariableSymbol(n.Name.Name, nil)
+		d.defineTyped(n.Name, v, n.Type)
+		if _, ok := d.current.(aggregate); !ok {
+			// visible from here on, after its initializer
+			d.visibleAt[v] = d.seq
+		}
+	case *cymbol.FuncDecl, *cymbol.StructDecl, *cymbol.ClassDecl, *cymbol.NamespaceDecl, *cymbol.Block:
+		d.current = d.current.EnclosingScope()
+	}
+}
+
+// open pushes the scope n opens, remembering it for RefPhase.
+func (d *DefPhase) open(n cymbol.Node, s Scope) {
+	d.scopes.Set(n, s)
+	d.push(s)
+}
+
+// defineTyped defines sym, leaving the name of its type for RefPhase.
+func (d *DefPhase) defineTyped(id *cymbol.Ident, sym Symbol, typ *cymbol.Ident) {
+	d.define(id, sym)
+	d.typings = append(d.typings, typing{sym: sym, name: typ, scope: d.current})
+}
+
+// RefPhase is the listener of the second pass, it resolves the names used in
+// the program to the symbols DefPhase defined. It has to walk the same tree,
+// and adds its diagnostics to the ones of DefPhase.
+type RefPhase struct {
+	resolver
+	defs     *DefPhase
+	resolved bool // the types of the declarations
+}
+
+func NewRefPhase(defs *DefPhase) *RefPhase {
+	r := &RefPhase{resolver: newResolver(defs.Table.Globals), defs: defs}
+	r.Diagnostics = defs.Diagnostics // one report for both passes
+	r.hidden = func(sym Symbol, id *cymbol.Ident) bool {
+		at, ok := defs.visibleAt[sym]
+		return ok && at > defs.usedAt[id]
+	}
+	return r
+}
+
+func (r *RefPhase) Enter(n cymbol.Node) {
+	if !r.resolved {
+		r.resolved = true
+		r.resolveTypes()
+	}
+	switch n := n.(type) {
+	case *cymbol.FuncDecl, *cymbol.StructDecl, *cymbol.ClassDecl, *cymbol.NamespaceDecl, *cymbol.Block:
+		r.push(r.defs.scopes.Get(n))
+	case *cymbol.Ident:
+		r.ref(n)
+	case *cymbol.ThisExpr:
+		r.self(n, n.Token, false)
+	case *cymbol.SuperExpr:
+		r.self(n, n.Token, true)
+	}
+}
+
+func (r *RefPhase) Exit(n cymbol.Node) {
+	switch n := n.(type) {
+	case *cymbol.FuncDecl, *cymbol.StructDecl, *cymbol.ClassDecl, *cymbol.NamespaceDecl, *cymbol.Block:
+		r.pop()
+	case *cymbol.MemberExpr:
+		r.member(n)
+	case *cymbol.File:
+		r.Events = append(r.Events, ScopeString(r.defs.Table.Globals))
+	}
+}
+
+// resolveTypes imports the namespaces, then sets the superclasses of the
+// classes, since names resolved inside a class look in them, and then the
+// types of the other symbols.
+func (r *RefPhase) resolveTypes() {
+	for _, t := range r.defs.imports {
+		r.current = t.scope
+		r.use(t.name, t.scope)
+	}
+	for _, t := range r.defs.typings {
+		if c, ok := t.sym.(*ClassSymbol); ok {
+			r.current = t.scope
+			c.Superclass = r.superclass(c, t.name)
+		}
+	}
+	for _, t := range r.defs.typings {
+		if _, ok := t.sym.(*ClassSymbol); !ok {
+			r.current = t.scope
+			t.sym.setType(r.refType(t.name))
+		}
+	}
+	r.current = r.defs.Table.Globals
+}
+
+// TwoPass defines and resolves the symbols of f in table, running both passes
+// one after the other.
+func TwoPass(table *SymbolTable, f *cymbol.File) (defs *DefPhase, refs *RefPhase) {
+	defs = NewDefPhase(table)
+	Walk(defs, f)
+	refs = NewRefPhase(defs)
+	Walk(refs, f)
+	return defs, refs
+}
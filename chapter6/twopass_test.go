@@ -0,0 +1,115 @@
+package symtab
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTwoPass(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		defs []string
+		refs []string
+	}{
+		{
+			name: "forward references",
+			src:  "void f() { g(); } void g() { } struct A { B b; }; struct B { int x; }; A a; int y = a.b.x;",
+			defs: []string{"1:6: def f", "1:24: def g", "1:39: def A", "1:45: def b", "1:58: def B", "1:66: def x", "1:74: def a", "1:81: def y"},
+			refs: []string{
+				"1:1: ref void", "1:19: ref void", "1:43: ref B", "1:62: ref int", "1:72: ref A", "1:77: ref int",
+				"1:12: ref <g:void>",
+				"local: []",
+				"f: []",
+				"local: []",
+				"g: []",
+				"A: [<b:B>]",
+				"B: [<x:int>]",
+				"1:85: ref <a:A>", "1:87: ref <b:B>", "1:89: ref <x:int>",
+				globals("<f:void>, <g:void>, A, B, <a:A>, <y:int>"),
+			},
+		},
+		{
+			name: "variables are used after their declaration",
+			src:  "int x; void f() { x = 1; int x; x = 2; } int z = z + w;",
+			defs: []string{"1:5: def x", "1:13: def f", "1:30: def x", "1:46: def z"},
+			refs: []string{
+				"1:1: ref int", "1:8: ref void", "1:26: ref int", "1:42: ref int",
+				"1:19: ref <x:int>", // the global x
+				"1:33: ref <x:int>", // the local x
+				"local: [<x:int>]",
+				"f: []",
+				"1:50: ref z: used before its declaration",
+				"1:54: ref w: undefined",
+				globals("<x:int>, <f:void>, <z:int>"),
+			},
+		},
+		{
+			name: "fields are used before their declaration",
+			src:  "class A { void f() { x = 1; } int x; }; void g() { y = 1; } int y;",
+			defs: []string{"1:7: def A", "1:16: def f", "1:35: def x", "1:46: def g", "1:65: def y"},
+			refs: []string{
+				"1:11: ref void", "1:31: ref int", "1:41: ref void", "1:61: ref int",
+				"1:22: ref <x:int>",
+				"local: []",
+				"f: []",
+				"A: [<f:void>, <x:int>]",
+				"1:52: ref y: used before its declaration",
+				"local: []",
+				"g: []",
+				globals("A, <g:void>, <y:int>"),
+			},
+		},
+		{
+			name: "superclasses",
+			src:  "class A : B { }; class B : A { }; class C : D { void f() { super.g(); } }; class D { void g() { } };",
+			defs: []string{"1:7: def A", "1:24: def B", "1:41: def C", "1:54: def f", "1:82: def D", "1:91: def g"},
+			refs: []string{
+				"1:11: ref B", "1:28: ref A", "1:28: class B inherits from itself", "1:45: ref D",
+				"1:49: ref void", "1:86: ref void",
+				"A: []",
+				"B: []",
+				"1:60: ref <super:D>", "1:66: ref <g:void>",
+				"local: []",
+				"f: []",
+				"C: [<f:void>]",
+				"local: []",
+				"g: []",
+				"D: [<g:void>]",
+				globals("A, B, C, D"),
+			},
+		},
+		{
+			name: "imports before their namespace",
+			src:  "import geo; int y = f(); namespace geo { int f() { return 1; } }",
+			defs: []string{"1:17: def y", "1:36: def geo", "1:46: def f"},
+			refs: []string{
+				"1:8: ref geo",
+				"1:13: ref int", "1:42: ref int",
+				"1:21: ref <f:int>",
+				"local: []",
+				"f: []",
+				"geo: [<f:int>]",
+				globals("<y:int>, geo"),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := cymbol.ParseFile(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defs, refs := TwoPass(NewSymbolTable(), f)
+			if !cmp.Equal(defs.Events, tc.defs) {
+				t.Error(cmp.Diff(defs.Events, tc.defs))
+			}
+			if !cmp.Equal(refs.Events, tc.refs) {
+				t.Error(cmp.Diff(refs.Events, tc.refs))
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package symtab
+
+import "example.com/cymbol"
+
+// Listener
+//
+// ANTLR generates a listener interface for each grammar, with methods called
+// as a tree walker enters and leaves each node. That keeps the walk in one
+// place and lets the symbol table code only react to the nodes it cares
+// about. Here a Listener gets every node and picks what it needs with a type
+// switch, the same way chapter3's Visit dispatches on node types.
+
+// Listener is called by Walk before and after the children of each node.
+type Listener interface {
+	Enter(n cymbol.Node)
+	Exit(n cymbol.Node)
+}
+
+// Walk walks the tree rooted at n depth-first, calling l.Enter for a node,
+// walking its children in source order and then calling l.Exit.
+//
+// The names in declarations, both the type and the name being declared, are
+// part of the declaration rather than its children: the listener handles them
+// when entering the declaration, and every Ident it gets on its own is a
+// reference in an expression.
+func Walk(l Listener, n cymbol.Node) {
+	l.Enter(n)
+	for _, c := range children(n) {
+		Walk(l, c)
+	}
+	l.Exit(n)
+}
+
+// children returns the child nodes of n that are walked, in source order.
+func children(n cymbol.Node) []cymbol.Node {
+	var kids []cymbol.Node
+	add := func(c cymbol.Node) {
+		// optional children are nil when missing
+		if c != nil {
+			kids = append(kids, c)
+		}
+	}
+
+	switch n := n.(type) {
+	case *cymbol.File:
+		for _, d := range n.Decls {
+			add(d)
+		}
+	case *cymbol.VarDecl:
+		add(n.Value)
+	case *cymbol.FuncDecl:
+		for _, p := range n.Params {
+			add(p)
+		}
+		add(n.Body)
+	case *cymbol.StructDecl:
+		for _, f := range n.Fields {
+			add(f)
+		}
+	case *cymbol.ClassDecl:
+		for _, m := range n.Members {
+			add(m)
+		}
+	case *cymbol.NamespaceDecl:
+		for _, d := range n.Decls {
+			add(d)
+		}
+	case *cymbol.Block:
+		for _, s := range n.Stmts {
+			add(s)
+		}
+	case *cymbol.IfStmt:
+		add(n.Cond)
+		add(n.Then)
+		add(n.Else)
+	case *cymbol.WhileStmt:
+		add(n.Cond)
+		add(n.Body)
+	case *cymbol.ReturnStmt:
+		add(n.Value)
+	case *cymbol.AssignStmt:
+		add(n.Target)
+		add(n.Value)
+	case *cymbol.ExprStmt:
+		add(n.X)
+	case *cymbol.BinaryExpr:
+		add(n.X)
+		add(n.Y)
+	case *cymbol.UnaryExpr:
+		add(n.X)
+	case *cymbol.CallExpr:
+		add(n.Fun)
+		for _, a := range n.Args {
+			add(a)
+		}
+	case *cymbol.MemberExpr:
+		// the member is resolved in the struct or class, not as a name of its own
+		add(n.X)
+	}
+	return kids
+}
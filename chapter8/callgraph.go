@@ -0,0 +1,222 @@
+package types
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Call graph
+//
+// The call graph has a node for each function and method, and an edge from
+// each one to every function it calls:
+//
+//	int fact(int n) { if (n < 2) return 1; return n * fact(n - 1); }
+//	int twice(int n) { return 2 * n; }
+//	void main() { fact(twice(3)); }
+//
+//	fact -> fact
+//	twice
+//	main -> fact, twice
+//
+// The callee of a call is the function its name resolves to, so a method
+// called through a variable is the one of the variable's class even if an
+// object of a subclass may override it at run time. Calls in the initializers
+// of global variables have no caller and aren't in the graph.
+//
+// A function is recursive if it's on a cycle of the graph, it may end up
+// calling itself. The functions that aren't have a bounded call depth, the
+// longest chain of calls they can start, which is how deep the stack of an
+// interpreter running them can get.
+
+// CallGraph is the graph of the calls between the functions of a program.
+type CallGraph struct {
+	Funcs []*symtab.FunctionSymbol // in the order they're declared
+	Calls map[*symtab.FunctionSymbol][]*symtab.FunctionSymbol
+}
+
+// NewCallGraph builds the call graph of the functions f declares. defs and
+// refs are the symbols the names in f define and refer to.
+func NewCallGraph(f *cymbol.File, defs map[*cymbol.Ident]symtab.Symbol, refs map[cymbol.Expr]symtab.Symbol) *CallGraph {
+	b := &callGraphBuilder{
+		g:    &CallGraph{Calls: map[*symtab.FunctionSymbol][]*symtab.FunctionSymbol{}},
+		defs: defs,
+		refs: refs,
+	}
+	symtab.Walk(b, f)
+	return b.g
+}
+
+type callGraphBuilder struct {
+	g      *CallGraph
+	defs   map[*cymbol.Ident]symtab.Symbol
+	refs   map[cymbol.Expr]symtab.Symbol
+	caller *symtab.FunctionSymbol
+}
+
+func (b *callGraphBuilder) Enter(n cymbol.Node) {
+	switch n := n.(type) {
+	case *cymbol.FuncDecl:
+		if f, ok := b.defs[n.Name].(*symtab.FunctionSymbol); ok {
+			b.caller = f
+			b.g.Funcs = append(b.g.Funcs, f)
+		}
+	case *cymbol.CallExpr:
+		callee, ok := b.refs[n.Fun].(*symtab.FunctionSymbol)
+		if b.caller != nil && ok && !slices.Contains(b.g.Calls[b.caller], callee) {
+			b.g.Calls[b.caller] = append(b.g.Calls[b.caller], callee)
+		}
+	}
+}
+
+func (b *callGraphBuilder) Exit(n cymbol.Node) {
+	if _, ok := n.(*cymbol.FuncDecl); ok {
+		b.caller = nil
+	}
+}
+
+// Cycles returns the cycles of calls, each one as the functions on it in the
+// order they call each other, starting and ending with the same one. They're
+// found with a depth-first search, one for each call that closes a cycle, so
+// cycles sharing functions may not all be reported.
+func (g *CallGraph) Cycles() [][]*symtab.FunctionSymbol {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := map[*symtab.FunctionSymbol]int{}
+	var path []*symtab.FunctionSymbol
+	var cycles [][]*symtab.FunctionSymbol
+
+	var visit func(f *symtab.FunctionSymbol)
+	visit = func(f *symtab.FunctionSymbol) {
+		state[f] = onPath
+		path = append(path, f)
+		for _, callee := range g.Calls[f] {
+			switch state[callee] {
+			case onPath:
+				// back edge, the cycle is the path from callee on
+				i := slices.Index(path, callee)
+				cycles = append(cycles, append(slices.Clone(path[i:]), callee))
+			case unvisited:
+				visit(callee)
+			}
+		}
+		path = path[:len(path)-1]
+		state[f] = done
+	}
+	for _, f := range g.Funcs {
+		if state[f] == unvisited {
+			visit(f)
+		}
+	}
+	return cycles
+}
+
+// Recursive reports whether f may call itself, directly or through other
+// functions.
+func (g *CallGraph) Recursive(f *symtab.FunctionSymbol) bool {
+	seen := map[*symtab.FunctionSymbol]bool{}
+	var calls func(from *symtab.FunctionSymbol) bool
+	calls = func(from *symtab.FunctionSymbol) bool {
+		for _, callee := range g.Calls[from] {
+			if callee == f {
+				return true
+			}
+			if !seen[callee] {
+				seen[callee] = true
+				if calls(callee) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return calls(f)
+}
+
+// Depth returns the number of frames on the stack when the longest chain of
+// calls f can start is deepest, f's own included. It's false if the chain has
+// no bound, because f may end up calling a recursive function.
+func (g *CallGraph) Depth(f *symtab.FunctionSymbol) (int, bool) {
+	depths := map[*symtab.FunctionSymbol]int{} // 0 while being computed
+	var depth func(f *symtab.FunctionSymbol) (int, bool)
+	depth = func(f *symtab.FunctionSymbol) (int, bool) {
+		if d, ok := depths[f]; ok {
+			return d, d > 0
+		}
+		depths[f] = 0
+		deepest := 0
+		for _, callee := range g.Calls[f] {
+			d, ok := depth(callee)
+			if !ok {
+				return 0, false
+			}
+			deepest = max(deepest, d)
+		}
+		depths[f] = deepest + 1
+		return deepest + 1, true
+	}
+	return depth(f)
+}
+
+// String renders the graph one function per line with the functions it
+// calls, in the order they're first called.
+func (g *CallGraph) String() string {
+	var s strings.Builder
+	for _, f := range g.Funcs {
+		s.WriteString(funcName(f))
+		for i, callee := range g.Calls[f] {
+			if i == 0 {
+				s.WriteString(" ->")
+			} else {
+				s.WriteString(",")
+			}
+			s.WriteString(" " + funcName(callee))
+		}
+		s.WriteString("\n")
+	}
+	return s.String()
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz, with the
+// recursive functions drawn in bold.
+func (g *CallGraph) WriteDOT(w io.Writer) error {
+	ids := map[*symtab.FunctionSymbol]int{}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph calls {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=\"monospace\"];")
+	for i, f := range g.Funcs {
+		ids[f] = i
+		style := ""
+		if g.Recursive(f) {
+			style = ", style=bold"
+		}
+		fmt.Fprintf(bw, "\tf%d [label=\"%s\"%s];\n", i, funcName(f), style)
+	}
+	for _, f := range g.Funcs {
+		for _, callee := range g.Calls[f] {
+			fmt.Fprintf(bw, "\tf%d -> f%d;\n", ids[f], ids[callee])
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// funcName returns the name of f, prefixed by its class if it's a method or
+// by its namespace if it's in one.
+func funcName(f *symtab.FunctionSymbol) string {
+	switch s := f.Scope().(type) {
+	case *symtab.ClassSymbol:
+		return s.Name() + "." + f.Name()
+	case *symtab.NamespaceSymbol:
+		return s.Name() + "." + f.Name()
+	}
+	return f.Name()
+}
@@ -0,0 +1,124 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"github.com/google/go-cmp/cmp"
+)
+
+const calls = `
+int fact(int n) { if (n < 2) return 1; return n * fact(n - 1); }
+int twice(int n) { return 2 * n; }
+boolean even(int n) { if (n == 0) return true; return odd(n - 1); }
+boolean odd(int n) { if (n == 0) return false; return even(n - 1); }
+class A { int m() { return twice(1); } int r() { return this.r(); } };
+A a;
+int x = twice(2);
+void main() { fact(twice(3)); even(a.m()); twice(4); }
+`
+
+func callGraph(t *testing.T, src string) *CallGraph {
+	t.Helper()
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, diags := Check(f)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return NewCallGraph(f, c.Defs, c.Refs)
+}
+
+func names(funcs []*symtab.FunctionSymbol) []string {
+	var s []string
+	for _, f := range funcs {
+		s = append(s, funcName(f))
+	}
+	return s
+}
+
+func TestCallGraph(t *testing.T) {
+	g := callGraph(t, calls)
+	want := `fact -> fact
+twice
+even -> odd
+odd -> even
+A.m -> twice
+A.r -> A.r
+main -> fact, twice, even, A.m
+`
+	if got := g.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	var cycles [][]string
+	for _, c := range g.Cycles() {
+		cycles = append(cycles, names(c))
+	}
+	wantCycles := [][]string{{"fact", "fact"}, {"even", "odd", "even"}, {"A.r", "A.r"}}
+	if !cmp.Equal(cycles, wantCycles) {
+		t.Error(cmp.Diff(cycles, wantCycles))
+	}
+
+	type depth struct {
+		recursive bool
+		depth     int
+		bounded   bool
+	}
+	wantDepths := map[string]depth{
+		"fact":  {recursive: true},
+		"twice": {depth: 1, bounded: true},
+		"even":  {recursive: true},
+		"odd":   {recursive: true},
+		"A.m":   {depth: 2, bounded: true},
+		"A.r":   {recursive: true},
+		"main":  {},
+	}
+	for _, f := range g.Funcs {
+		d, ok := g.Depth(f)
+		got := depth{recursive: g.Recursive(f), depth: d, bounded: ok}
+		if want := wantDepths[funcName(f)]; got != want {
+			t.Errorf("%s: want %+v, got %+v", funcName(f), want, got)
+		}
+	}
+}
+
+func TestCallGraphNamespaces(t *testing.T) {
+	g := callGraph(t, "namespace m { int sq(int n) { return n * n; } } import m; int f() { return m.sq(2) + sq(3); }")
+	if got, want := g.String(), "m.sq\nf -> m.sq\n"; got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestCallGraphDepth(t *testing.T) {
+	g := callGraph(t, "void a() { b(); c(); } void b() { c(); } void c() { d(); } void d() { }")
+	want := []int{4, 3, 2, 1}
+	for i, f := range g.Funcs {
+		if d, ok := g.Depth(f); !ok || d != want[i] {
+			t.Errorf("%s: want %d, got %d, %v", f.Name(), want[i], d, ok)
+		}
+	}
+}
+
+func TestCallGraphDOT(t *testing.T) {
+	g := callGraph(t, "int f(int n) { return f(n) + g(); } int g() { return 1; }")
+	var s strings.Builder
+	if err := g.WriteDOT(&s); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph calls {
+	node [shape=box, fontname="monospace"];
+	f0 [label="f", style=bold];
+	f1 [label="g"];
+	f0 -> f0;
+	f0 -> f1;
+}
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+}
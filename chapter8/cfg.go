@@ -0,0 +1,167 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/cymbol"
+)
+
+// Control-flow graph
+//
+// The analyses that follow values through a function, such as reaching
+// definitions in flow.go, need to know in which order its statements can
+// run. A control-flow graph has that: the statements are grouped in basic
+// blocks, runs of statements always executed one after the other, with an
+// edge from a block to each one that can run right after it:
+//
+//	int f(int x) {         b0: entry -> b1
+//	    int y = 0;         b1: int x | int y = 0; | (x > 0) -> b2 b3
+//	    if (x > 0)         b2: y = x; -> b3
+//	        y = x;         b3: return y; -> b4
+//	    return y;          b4: exit
+//	}
+//
+// Besides statements, blocks hold the parameters, which are defined when the
+// function is entered, and the conditions of ifs and whiles, which are
+// evaluated at the end of their block. A while's condition starts a block of
+// its own, since it's jumped back to from the end of the body. The code after
+// a return is put in a block no edge goes to.
+
+// BasicBlock is a sequence of nodes always run one after the other: params,
+// statements and conditions.
+type BasicBlock struct {
+	Index int
+	Nodes []cymbol.Node
+	Succs []*BasicBlock
+	Preds []*BasicBlock
+}
+
+// CFG is the control-flow graph of a function. Entry and Exit are empty
+// blocks where every path starts and ends.
+type CFG struct {
+	Blocks      []*BasicBlock
+	Entry, Exit *BasicBlock
+}
+
+// NewCFG builds the control-flow graph of the function f declares.
+func NewCFG(f *cymbol.FuncDecl) *CFG {
+	b := &cfgBuilder{cfg: &CFG{}}
+	b.cfg.Entry = b.newBlock()
+	b.current = b.newBlock()
+	jump(b.cfg.Entry, b.current)
+	for _, p := range f.Params {
+		b.add(p)
+	}
+	b.cfg.Exit = &BasicBlock{}
+	b.stmt(f.Body)
+	jump(b.current, b.cfg.Exit)
+	b.cfg.Exit.Index = len(b.cfg.Blocks)
+	b.cfg.Blocks = append(b.cfg.Blocks, b.cfg.Exit)
+	return b.cfg
+}
+
+// jump adds an edge from a block to another, if there's a block to jump
+// from.
+func jump(from, to *BasicBlock) {
+	if from == nil {
+		return
+	}
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+type cfgBuilder struct {
+	cfg     *CFG
+	current *BasicBlock // nil after a return, until there's code after it
+}
+
+func (b *cfgBuilder) newBlock() *BasicBlock {
+	block := &BasicBlock{Index: len(b.cfg.Blocks)}
+	b.cfg.Blocks = append(b.cfg.Blocks, block)
+	return block
+}
+
+func (b *cfgBuilder) add(n cymbol.Node) {
+	if b.current == nil {
+		b.current = b.newBlock() // unreachable
+	}
+	b.current.Nodes = append(b.current.Nodes, n)
+}
+
+func (b *cfgBuilder) stmt(s cymbol.Stmt) {
+	switch s := s.(type) {
+	case *cymbol.Block:
+		for _, s := range s.Stmts {
+			b.stmt(s)
+		}
+	case *cymbol.IfStmt:
+		b.add(s.Cond)
+		cond := b.current
+		then := b.branch(cond, s.Then)
+		var els *BasicBlock
+		if s.Else != nil {
+			els = b.branch(cond, s.Else)
+		}
+		b.current = b.newBlock()
+		jump(then, b.current)
+		if s.Else != nil {
+			jump(els, b.current)
+		} else {
+			jump(cond, b.current)
+		}
+	case *cymbol.WhileStmt:
+		head := b.newBlock()
+		jump(b.current, head)
+		head.Nodes = append(head.Nodes, s.Cond)
+		jump(b.branch(head, s.Body), head)
+		b.current = b.newBlock()
+		jump(head, b.current)
+	case *cymbol.ReturnStmt:
+		b.add(s)
+		jump(b.current, b.cfg.Exit)
+		b.current = nil
+	case *cymbol.StructDecl:
+		// a type, nothing runs
+	default:
+		b.add(s)
+	}
+}
+
+// branch builds a new block for s, jumped to from from, and returns the
+// block s ends in. It's nil if s doesn't end, it returns.
+func (b *cfgBuilder) branch(from *BasicBlock, s cymbol.Stmt) *BasicBlock {
+	b.current = b.newBlock()
+	jump(from, b.current)
+	b.stmt(s)
+	return b.current
+}
+
+// String renders the graph one block per line, in the order they were
+// created, with its nodes separated by bars and the blocks it jumps to.
+func (g *CFG) String() string {
+	var s strings.Builder
+	for _, block := range g.Blocks {
+		fmt.Fprintf(&s, "b%d:", block.Index)
+		switch block {
+		case g.Entry:
+			s.WriteString(" entry")
+		case g.Exit:
+			s.WriteString(" exit")
+		}
+		for i, n := range block.Nodes {
+			if i > 0 {
+				s.WriteString(" |")
+			}
+			s.WriteString(" " + n.String())
+		}
+		if len(block.Succs) > 0 {
+			s.WriteString(" ->")
+		}
+		for _, succ := range block.Succs {
+			fmt.Fprintf(&s, " b%d", succ.Index)
+		}
+		s.WriteString("\n")
+	}
+	return s.String()
+}
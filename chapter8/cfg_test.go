@@ -0,0 +1,106 @@
+package types
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCFG(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "straight",
+			src:  "void f() { int x = 1; g(x); }",
+			want: `b0: entry -> b1
+b1: int x = 1; | g(x); -> b2
+b2: exit
+`,
+		},
+		{
+			name: "if",
+			src:  "int f(int x) { int y = 0; if (x > 0) y = x; return y; }",
+			want: `b0: entry -> b1
+b1: int x | int y = 0; | (x > 0) -> b2 b3
+b2: y = x; -> b3
+b3: return y; -> b4
+b4: exit
+`,
+		},
+		{
+			name: "if else",
+			src:  "void f(boolean b) { if (b) { g(1); } else { g(2); g(3); } g(4); }",
+			want: `b0: entry -> b1
+b1: boolean b | b -> b2 b3
+b2: g(1); -> b4
+b3: g(2); | g(3); -> b4
+b4: g(4); -> b5
+b5: exit
+`,
+		},
+		{
+			name: "while",
+			src:  "void f() { int i = 0; while (i < 10) { i = i + 1; } g(i); }",
+			want: `b0: entry -> b1
+b1: int i = 0; -> b2
+b2: (i < 10) -> b3 b4
+b3: i = (i + 1); -> b2
+b4: g(i); -> b5
+b5: exit
+`,
+		},
+		{
+			name: "nested",
+			src:  "void f(int i) { while (i > 0) { if (i == 5) i = 0; else i = i - 1; } }",
+			want: `b0: entry -> b1
+b1: int i -> b2
+b2: (i > 0) -> b3 b7
+b3: (i == 5) -> b4 b5
+b4: i = 0; -> b6
+b5: i = (i - 1); -> b6
+b6: -> b2
+b7: -> b8
+b8: exit
+`,
+		},
+		{
+			name: "returns",
+			src:  "int f(boolean b) { if (b) return 1; else return 2; g(); }",
+			want: `b0: entry -> b1
+b1: boolean b | b -> b2 b3
+b2: return 1; -> b5
+b3: return 2; -> b5
+b4: g(); -> b5
+b5: exit
+`,
+		},
+		{
+			name: "unreachable",
+			src:  "void f() { return; g(1); while (true) { return; } }",
+			want: `b0: entry -> b1
+b1: return; -> b6
+b2: g(1); -> b3
+b3: true -> b4 b5
+b4: return; -> b6
+b5: -> b6
+b6: exit
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := cymbol.ParseFile(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := NewCFG(f.Decls[0].(*cymbol.FuncDecl)).String(); got != tc.want {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
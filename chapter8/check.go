@@ -0,0 +1,211 @@
+package types
+
+import (
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Pattern 22:
+// Enforcing Static Type Safety
+
+// With the type of every expression computed, checking a program is making
+// sure each one is used where its type fits:
+//
+//	int i = "a";            cannot use "a" (string) as int value in initialization
+//	b + b;                  operator + not defined on boolean
+//	g(1, 2);                too many arguments in call to g
+//	if (i) { }              non-boolean condition in if statement
+//
+// A value fits where a type is expected if it has that type or can be
+// promoted to it, so a char can be passed to an int parameter but a float
+// can't be assigned to an int variable. An instance of a class also fits
+// where one of its superclasses is expected, see polymorphic.go. Operators
+// have to be defined on the types of their operands, which is when
+// ComputeTypes gives them a type other than void, and conditions have to be
+// booleans.
+//
+// An invalid expression is reported once: the operators and calls it's part
+// of aren't checked, so `(b + b) * 2` isn't reported twice. Names that didn't
+// resolve have no type and aren't checked either, they were reported when
+// resolving them.
+
+// CheckTypes is the listener that checks the types of a program, adding what
+// it finds to Diagnostics. It needs the types computed by a previous walk.
+type CheckTypes struct {
+	*ComputeTypes
+	Diagnostics *cymbol.Diagnostics
+
+	invalid map[cymbol.Expr]bool // reported, or part of one that was
+}
+
+func NewCheckTypes(c *ComputeTypes, diags *cymbol.Diagnostics) *CheckTypes {
+	return &CheckTypes{ComputeTypes: c, Diagnostics: diags, invalid: map[cymbol.Expr]bool{}}
+}
+
+func (c *CheckTypes) Enter(n cymbol.Node) {
+	if f, ok := n.(*cymbol.FuncDecl); ok {
+		c.returns, _ = c.Refs[f.Type].(symtab.Type)
+	}
+}
+
+func (c *CheckTypes) Exit(n cymbol.Node) {
+	switch n := n.(type) {
+	case *cymbol.BinaryExpr:
+		c.checkBinary(n)
+	case *cymbol.UnaryExpr:
+		c.checkUnary(n)
+	case *cymbol.CallExpr:
+		c.checkCall(n)
+	case *cymbol.ClassDecl:
+		c.checkOverrides(n)
+	case *cymbol.VarDecl:
+		if typ, ok := c.Refs[n.Type].(symtab.Type); ok && n.Value != nil {
+			c.use(n.Value, typ, "initialization")
+		}
+	case *cymbol.AssignStmt:
+		c.checkAssign(n)
+	case *cymbol.ReturnStmt:
+		c.checkReturn(n)
+	case *cymbol.FuncDecl:
+		c.returns = nil
+	case *cymbol.IfStmt:
+		c.condition(n.Cond, "if statement")
+	case *cymbol.WhileStmt:
+		c.condition(n.Cond, "while statement")
+	}
+}
+
+func (c *CheckTypes) checkBinary(x *cymbol.BinaryExpr) {
+	if c.invalid[x.X] || c.invalid[x.Y] {
+		c.invalid[x] = true
+		return
+	}
+	tx, ty := c.Types.Get(x.X), c.Types.Get(x.Y)
+	if !isVoid(c.Types.Get(x)) {
+		return
+	}
+	if tx == ty {
+		c.report(x, "invalid operation: operator %s not defined on %v (%s)", x.Op.Text, x.X, tx.Name())
+		return
+	}
+	c.report(x, "invalid operation: %v (mismatched types %s and %s)", x, tx.Name(), ty.Name())
+}
+
+func (c *CheckTypes) checkUnary(x *cymbol.UnaryExpr) {
+	if c.invalid[x.X] {
+		c.invalid[x] = true
+		return
+	}
+	if isVoid(c.Types.Get(x)) {
+		c.report(x, "invalid operation: operator %s not defined on %v (%s)", x.Op.Text, x.X, c.Types.Get(x.X).Name())
+	}
+}
+
+// checkCall checks that what's called is a function and that the arguments
+// fit its parameters.
+func (c *CheckTypes) checkCall(x *cymbol.CallExpr) {
+	sym := c.Refs[x.Fun]
+	if sym == nil {
+		return
+	}
+	f, ok := sym.(*symtab.FunctionSymbol)
+	if !ok {
+		c.report(x, "invalid operation: cannot call non-function %v", x.Fun)
+		return
+	}
+	params := f.Params()
+	switch {
+	case len(x.Args) < len(params):
+		c.report(x, "not enough arguments in call to %v", x.Fun)
+	case len(x.Args) > len(params):
+		c.report(x.Args[len(params)], "too many arguments in call to %v", x.Fun)
+	}
+	for i, a := range x.Args {
+		if i < len(params) {
+			c.use(a, params[i].Type(), "argument to "+x.Fun.String())
+		}
+	}
+}
+
+func (c *CheckTypes) checkAssign(s *cymbol.AssignStmt) {
+	if sym := c.Refs[s.Target]; sym != nil {
+		if _, ok := sym.(*symtab.VariableSymbol); !ok {
+			c.report(s.Target, "cannot assign to %v", s.Target)
+			return
+		}
+	}
+	c.use(s.Value, c.Types.Get(s.Target), "assignment")
+}
+
+func (c *CheckTypes) checkReturn(s *cymbol.ReturnStmt) {
+	switch {
+	case c.returns == nil:
+		// unknown return type, reported already
+	case isVoid(c.returns) && s.Value != nil:
+		c.report(s.Value, "too many return values")
+	case !isVoid(c.returns) && s.Value == nil:
+		c.Diagnostics.Add(cymbol.Span{From: s.Return, To: s.Return}, "not enough return values")
+	case s.Value != nil:
+		c.use(s.Value, c.returns, "return statement")
+	}
+}
+
+// condition checks that x, the condition of a statement, is a boolean.
+func (c *CheckTypes) condition(x cymbol.Expr, stmt string) {
+	if c.value(x) && c.Types.Get(x).Name() != "boolean" {
+		c.report(x, "non-boolean condition in %s", stmt)
+	}
+}
+
+// use checks that x fits where a value of type typ is expected, the context
+// saying where that is.
+func (c *CheckTypes) use(x cymbol.Expr, typ symtab.Type, context string) {
+	if typ == nil || !c.value(x) {
+		return
+	}
+	if from := c.Types.Get(x); !assignable(from, typ) {
+		c.report(x, "cannot use %v (%s) as %s value in %s", x, from.Name(), typ.Name(), context)
+	}
+}
+
+// value reports whether x is a value that can be checked, reporting it if
+// it's the call of a function returning void.
+func (c *CheckTypes) value(x cymbol.Expr) bool {
+	if c.invalid[x] || c.Types.Get(x) == nil {
+		return false
+	}
+	if isVoid(c.Types.Get(x)) {
+		c.report(x, "%v (no value) used as value", x)
+		return false
+	}
+	return true
+}
+
+// Any is the type of a parameter taking a value of any type. Cymbol code has
+// no name for it, it's for the functions a host program defines in Go, such
+// as the print of package interp.
+var Any symtab.Type = symtab.NewBuiltInTypeSymbol("any")
+
+// assignable reports whether a value of type from can be used as a value of
+// type to.
+func assignable(from, to symtab.Type) bool {
+	return to == Any || from == to || Promotes(from.Name(), to.Name()) || subclass(from, to)
+}
+
+func isVoid(t symtab.Type) bool { return t != nil && t.Name() == "void" }
+
+// report records a diagnostic at x, which is invalid from then on.
+func (c *CheckTypes) report(x cymbol.Expr, format string, args ...any) {
+	c.invalid[x] = true
+	pos := x.Pos()
+	c.Diagnostics.Add(cymbol.Span{From: pos, To: pos}, format, args...)
+}
+
+// Check computes the types of the expressions of f, as Compute does, and then
+// checks them. The diagnostics are the ones of resolving the symbols and of
+// checking the types, together.
+func Check(f *cymbol.File) (*ComputeTypes, *cymbol.Diagnostics) {
+	c, diags := Compute(f)
+	symtab.Walk(NewCheckTypes(c, diags), f)
+	return c, diags
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		stmt string
+		errs []string
+	}{
+		{stmt: "int x = i + c; float y = x * f; string z = s + s;"},
+		{stmt: `int x = "a";`, errs: []string{`cannot use "a" (string) as int value in initialization`}},
+		{stmt: "int x = f;", errs: []string{"cannot use f (float) as int value in initialization"}},
+		{stmt: "float x = c;"},
+		{stmt: "char x = i;", errs: []string{"cannot use i (int) as char value in initialization"}},
+		{stmt: "point x = p; x = p;"},
+		{stmt: "point x = o;", errs: []string{"cannot use o (B) as point value in initialization"}},
+		{stmt: "i = b;", errs: []string{"cannot use b (boolean) as int value in assignment"}},
+		{stmt: "p.x = p.y;", errs: []string{"cannot use p.y (float) as int value in assignment"}},
+		{stmt: "g = i;", errs: []string{"cannot assign to g"}},
+		{stmt: "b + b;", errs: []string{"invalid operation: operator + not defined on b (boolean)"}},
+		{stmt: "i - s;", errs: []string{"invalid operation: (i - s) (mismatched types int and string)"}},
+		{stmt: "p < p;", errs: []string{"invalid operation: operator < not defined on p (point)"}},
+		{stmt: "-s;", errs: []string{"invalid operation: operator - not defined on s (string)"}},
+		{stmt: "!i;", errs: []string{"invalid operation: operator ! not defined on i (int)"}},
+		{stmt: "int x = (b + b) * 2 + i;", errs: []string{"invalid operation: operator + not defined on b (boolean)"}},
+		{stmt: "g(c); g(i);"},
+		{stmt: "g();", errs: []string{"not enough arguments in call to g"}},
+		{stmt: "g(1, 2);", errs: []string{"too many arguments in call to g"}},
+		{stmt: "g(f);", errs: []string{"cannot use f (float) as int value in argument to g"}},
+		{stmt: "i();", errs: []string{"invalid operation: cannot call non-function i"}},
+		{stmt: "o.m(1);", errs: []string{"too many arguments in call to o.m"}},
+		{stmt: "int x = v();", errs: []string{"v() (no value) used as value"}},
+		{stmt: "v();"},
+		{stmt: "if (b) { } while (i < 3) { }"},
+		{stmt: "if (i) { }", errs: []string{"non-boolean condition in if statement"}},
+		{stmt: "while (s) { }", errs: []string{"non-boolean condition in while statement"}},
+		{stmt: "if (v()) { }", errs: []string{"v() (no value) used as value"}},
+		{stmt: "return i;"},
+		{stmt: "return b;", errs: []string{"cannot use b (boolean) as float value in return statement"}},
+		{stmt: "return;", errs: []string{"not enough return values"}},
+		{stmt: "int x = q;", errs: []string{"undefined: q"}},
+		{stmt: "int x = q + 1; g(q);", errs: []string{"undefined: q", "undefined: q"}},
+		{
+			stmt: `int x = "a"; i = b; if (i) { }`,
+			errs: []string{
+				`cannot use "a" (string) as int value in initialization`,
+				"cannot use b (boolean) as int value in assignment",
+				"non-boolean condition in if statement",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.stmt, func(t *testing.T) {
+			f, err := cymbol.ParseFile(decls + "void v() { }\nfloat test() { " + tc.stmt + " }")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, diags := Check(f)
+			var errs []string
+			for _, d := range diags.List() {
+				errs = append(errs, d.Message)
+			}
+			if !cmp.Equal(errs, tc.errs) {
+				t.Error(cmp.Diff(errs, tc.errs))
+			}
+		})
+	}
+}
+
+func TestCheckVoid(t *testing.T) {
+	f, err := cymbol.ParseFile("void f() { return 1; }\nint g() { return; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, diags := Check(f)
+	want := "1:19: too many return values\n2:11: not enough return values"
+	if err := diags.Err(); err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"example.com/types"
+)
+
+// example has expressions of all the types, and operators mixing them
+const example = `struct point { int x; float y; };
+point p;
+char c = 'a';
+float f(int i) { return p.x * i + p.y; }
+boolean b = c + 1 < f(2) * 2.5;
+string s = "a" + "b";
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+// run computes the types of the expressions in the Cymbol program given as
+// arguments, or in the example, and prints each expression with its type in
+// the order they're computed:
+//
+//	types [-conversions] [-flow] [-calls text|dot] 'int i; float j = i * 2.5;'
+//
+// Names that don't resolve and type errors are returned as a single error,
+// with one diagnostic per line. With -flow so are the local variables given
+// values never used, or used before they're given one.
+//
+// With -conversions the expressions promoted to another type are followed by
+// the type they're converted to, as in `1:18: i int -> float`. With -calls
+// the call graph is printed instead, as text or as a Graphviz DOT graph.
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("types", flag.ContinueOnError)
+	conversions := fs.Bool("conversions", false, "show the implicit conversions of promoted expressions")
+	flow := fs.Bool("flow", false, "report unused and uninitialized variables")
+	calls := fs.String("calls", "", "print the call graph as `text or dot`")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *calls != "" && *calls != "text" && *calls != "dot" {
+		return fmt.Errorf("-calls must be text or dot, not %q", *calls)
+	}
+	src := example
+	if fs.NArg() > 0 {
+		src = strings.Join(fs.Args(), " ")
+	}
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	check := types.Check
+	if *flow {
+		check = types.Analyze
+	}
+	c, diags := check(f)
+	if err := diags.Err(); err != nil {
+		return cymbol.WithSource(src, err)
+	}
+
+	switch g := types.NewCallGraph(f, c.Defs, c.Refs); *calls {
+	case "text":
+		_, err := io.WriteString(out, g.String())
+		return err
+	case "dot":
+		return g.WriteDOT(out)
+	}
+
+	p := &printer{out: out, types: c, conversions: *conversions}
+	symtab.Walk(p, f)
+	return p.err
+}
+
+// printer prints the expressions as a walk leaves them, which is when their
+// types are computed.
+type printer struct {
+	out         io.Writer
+	types       *types.ComputeTypes
+	conversions bool // show the promotions
+	err         error
+}
+
+func (p *printer) Enter(n cymbol.Node) {}
+
+func (p *printer) Exit(n cymbol.Node) {
+	x, ok := n.(cymbol.Expr)
+	if !ok || p.err != nil {
+		return
+	}
+	typ := "?"
+	if t := p.types.Types.Get(x); t != nil {
+		typ = t.Name()
+	}
+	if to := p.types.Promotions.Get(x); p.conversions && to != nil {
+		typ += " -> " + to.Name()
+	}
+	_, p.err = fmt.Fprintf(p.out, "%v: %v %s\n", x.Pos(), x, typ)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	var s strings.Builder
+	if err := run([]string{"int i; float j = i * 2.5;"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := "1:18: i int\n1:22: 2.5 float\n1:18: (i * 2.5) float\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	if err := run([]string{"-conversions", "int i; float j = i * 2.5;"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want = "1:18: i int -> float\n1:22: 2.5 float\n1:18: (i * 2.5) float\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	if err := run([]string{"int i = j;"}, &s); err == nil || err.Error() != "1:9: undefined: j" {
+		t.Errorf("want: undefined j, got: %v", err)
+	}
+	want = "1:9: cannot use \"a\" (string) as int value in initialization\n1:29: non-boolean condition in if statement"
+	if err := run([]string{`int i = "a"; void f() { if (i) { } }`}, &s); err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+
+	s.Reset()
+	if err := run([]string{"-calls", "text", "int f(int n) { return f(n - 1); } void g() { f(1); }"}, &s); err != nil {
+		t.Fatal(err)
+	}
+	want = "f -> f\ng -> f\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+	if err := run([]string{"-calls", "svg"}, &s); err == nil {
+		t.Error("want an error for -calls svg")
+	}
+
+	src := "int f() { int x; int y = 1; return x; }"
+	if err := run([]string{src}, &s); err != nil {
+		t.Errorf("want no flow analysis, got: %v", err)
+	}
+	want = "1:22: declared and not used: y\n1:36: x is used uninitialized"
+	if err := run([]string{"-flow", src}, &s); err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+}
@@ -0,0 +1,280 @@
+package types
+
+import (
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Reaching definitions
+//
+// A definition of a variable is a place where it gets a value: a parameter,
+// a declaration or an assignment. A definition reaches a point of a function
+// if there's a path in the control-flow graph from it to the point along
+// which the variable isn't defined again. The definitions reaching a use of a
+// variable are the ones its value may come from:
+//
+//	int f(int x) {
+//	    int y;              // y declared without a value
+//	    int z = 1;          // value assigned to z is never used
+//	    if (x > 0) y = x;
+//	    z = 2;
+//	    return y + z;       // y may be used uninitialized
+//	}
+//
+// Both y's declaration and the assignment reach the return, one path gives y
+// a value and the other doesn't. The first value of z doesn't reach any use,
+// so it's useless. The definitions reaching each block are found the usual
+// way, iterating over the blocks until they don't change: the ones reaching a
+// block are the ones leaving its predecessors, and those leaving it are the
+// ones reaching it, minus the ones of the variables it defines, plus the
+// definitions it makes.
+//
+// Only the parameters and local variables are analyzed, anything else can be
+// used or assigned by other functions. Variables of struct and class types
+// are always defined, a declaration without a value gives them an instance.
+
+// CheckFlow reports in diags the local variables of the function f declares
+// that are assigned values never used, or used when they may not have a value.
+// defs and refs are the symbols the names in f define and refer to.
+func CheckFlow(f *cymbol.FuncDecl, defs map[*cymbol.Ident]symtab.Symbol, refs map[cymbol.Expr]symtab.Symbol, diags *cymbol.Diagnostics) {
+	fl := &flow{
+		cfg:     NewCFG(f),
+		defs:    defs,
+		refs:    refs,
+		diags:   diags,
+		vars:    map[symtab.Symbol]bool{},
+		used:    map[symtab.Symbol]bool{},
+		defined: map[cymbol.Node][]int{},
+	}
+	fl.collect()
+	fl.solve()
+	fl.check()
+}
+
+// definition is where a variable gets a value, or where it's declared without
+// one.
+type definition struct {
+	v     symtab.Symbol
+	id    *cymbol.Ident // the name declared or assigned
+	value bool          // false for a declaration without a value
+	param bool
+	used  bool // reaches a use of the variable
+}
+
+type flow struct {
+	cfg   *CFG
+	defs  map[*cymbol.Ident]symtab.Symbol
+	refs  map[cymbol.Expr]symtab.Symbol
+	diags *cymbol.Diagnostics
+
+	vars        map[symtab.Symbol]bool // the variables analyzed
+	used        map[symtab.Symbol]bool // used anywhere, reachable or not
+	definitions []*definition          // in the order of the graph's nodes
+	defined     map[cymbol.Node][]int  // the definitions each node makes
+	in          [][]bool               // the definitions reaching each block
+}
+
+// collect finds the variables and their definitions.
+func (fl *flow) collect() {
+	for _, b := range fl.cfg.Blocks {
+		for _, n := range b.Nodes {
+			switch n := n.(type) {
+			case *cymbol.Param:
+				fl.declare(n, n.Name, true, true)
+			case *cymbol.VarDecl:
+				fl.declare(n, n.Name, n.Value != nil, false)
+			}
+		}
+	}
+	for _, b := range fl.cfg.Blocks {
+		for _, n := range b.Nodes {
+			if a, ok := n.(*cymbol.AssignStmt); ok {
+				if id, ok := a.Target.(*cymbol.Ident); ok && fl.vars[fl.refs[id]] {
+					fl.define(n, &definition{v: fl.refs[id], id: id, value: true})
+				}
+			}
+			for _, id := range fl.uses(n) {
+				fl.used[fl.refs[id]] = true
+			}
+		}
+	}
+}
+
+func (fl *flow) declare(n cymbol.Node, id *cymbol.Ident, value, param bool) {
+	v := fl.defs[id]
+	if v == nil {
+		return
+	}
+	fl.vars[v] = true
+	if _, builtin := v.Type().(*symtab.BuiltInTypeSymbol); !builtin {
+		value = true
+	}
+	fl.define(n, &definition{v: v, id: id, value: value, param: param})
+}
+
+func (fl *flow) define(n cymbol.Node, d *definition) {
+	fl.defined[n] = append(fl.defined[n], len(fl.definitions))
+	fl.definitions = append(fl.definitions, d)
+}
+
+// uses returns the names of variables analyzed that n reads, in order.
+func (fl *flow) uses(n cymbol.Node) []*cymbol.Ident {
+	var read cymbol.Node = n
+	switch n := n.(type) {
+	case *cymbol.Param:
+		return nil
+	case *cymbol.VarDecl:
+		read = n.Value
+	case *cymbol.AssignStmt:
+		if _, ok := n.Target.(*cymbol.Ident); ok {
+			read = n.Value
+		}
+	}
+	if read == nil {
+		return nil
+	}
+	l := &identLister{}
+	symtab.Walk(l, read)
+	var ids []*cymbol.Ident
+	for _, id := range l.ids {
+		if fl.vars[fl.refs[id]] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+type identLister struct{ ids []*cymbol.Ident }
+
+func (l *identLister) Enter(n cymbol.Node) {
+	if id, ok := n.(*cymbol.Ident); ok {
+		l.ids = append(l.ids, id)
+	}
+}
+
+func (l *identLister) Exit(n cymbol.Node) {}
+
+// solve computes the definitions reaching each block, until they don't
+// change.
+func (fl *flow) solve() {
+	fl.in = make([][]bool, len(fl.cfg.Blocks))
+	out := make([][]bool, len(fl.cfg.Blocks))
+	for i := range fl.cfg.Blocks {
+		fl.in[i] = make([]bool, len(fl.definitions))
+		out[i] = make([]bool, len(fl.definitions))
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fl.cfg.Blocks {
+			in := fl.in[b.Index]
+			for _, p := range b.Preds {
+				for d, ok := range out[p.Index] {
+					in[d] = in[d] || ok
+				}
+			}
+			reach := append([]bool(nil), in...)
+			for _, n := range b.Nodes {
+				fl.transfer(reach, n)
+			}
+			for d := range reach {
+				if reach[d] != out[b.Index][d] {
+					changed = true
+				}
+			}
+			out[b.Index] = reach
+		}
+	}
+}
+
+// transfer updates the definitions reaching a point with the ones n makes.
+func (fl *flow) transfer(reach []bool, n cymbol.Node) {
+	for _, d := range fl.defined[n] {
+		for i, other := range fl.definitions {
+			if other.v == fl.definitions[d].v {
+				reach[i] = false
+			}
+		}
+		reach[d] = true
+	}
+}
+
+// check goes through the nodes of each block with the definitions reaching
+// them, marking the ones used and reporting the uses of variables that may
+// not have a value. Then it reports the values never used.
+func (fl *flow) check() {
+	for _, b := range fl.cfg.Blocks {
+		reach := append([]bool(nil), fl.in[b.Index]...)
+		for _, n := range b.Nodes {
+			for _, id := range fl.uses(n) {
+				fl.use(id, reach)
+			}
+			fl.transfer(reach, n)
+		}
+	}
+	for _, d := range fl.definitions {
+		switch {
+		case d.param || d.used:
+		case !fl.used[d.v]:
+			if fl.defs[d.id] == d.v { // the declaration, not an assignment
+				fl.diags.Add(d.id.Token.Span(), "declared and not used: %s", d.id.Name)
+			}
+		case d.value:
+			fl.diags.Add(d.id.Token.Span(), "value assigned to %s is never used", d.id.Name)
+		}
+	}
+}
+
+// use marks the definitions of the variable id refers to that reach it as
+// used, reporting it if any of them doesn't give it a value.
+func (fl *flow) use(id *cymbol.Ident, reach []bool) {
+	v := fl.refs[id]
+	var reaching, unset int
+	for i, d := range fl.definitions {
+		if reach[i] && d.v == v {
+			d.used = true
+			reaching++
+			if !d.value {
+				unset++
+			}
+		}
+	}
+	switch {
+	case unset == 0:
+	case unset == reaching:
+		fl.diags.Add(id.Token.Span(), "%s is used uninitialized", id.Name)
+	default:
+		fl.diags.Add(id.Token.Span(), "%s may be used uninitialized", id.Name)
+	}
+}
+
+// Analyze checks the types of f, as Check does, and then the flow of values
+// through its functions and methods. The diagnostics of all of them are
+// together.
+func Analyze(f *cymbol.File) (*ComputeTypes, *cymbol.Diagnostics) {
+	c, diags := Check(f)
+	for _, fn := range funcDecls(f.Decls) {
+		CheckFlow(fn, c.Defs, c.Refs, diags)
+	}
+	return c, diags
+}
+
+// funcDecls returns the functions and methods among decls, and in the
+// namespaces among them.
+func funcDecls(decls []cymbol.Decl) []*cymbol.FuncDecl {
+	var funcs []*cymbol.FuncDecl
+	for _, d := range decls {
+		switch d := d.(type) {
+		case *cymbol.FuncDecl:
+			funcs = append(funcs, d)
+		case *cymbol.ClassDecl:
+			for _, m := range d.Members {
+				if m, ok := m.(*cymbol.FuncDecl); ok {
+					funcs = append(funcs, m)
+				}
+			}
+		case *cymbol.NamespaceDecl:
+			funcs = append(funcs, funcDecls(d.Decls)...)
+		}
+	}
+	return funcs
+}
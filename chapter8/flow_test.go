@@ -0,0 +1,114 @@
+package types
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		errs []string
+	}{
+		{
+			name: "all used",
+			src:  "int f(int x) { int y = x; int z; z = y + 1; return z; }",
+		},
+		{
+			name: "declared and not used",
+			src:  "void f() { int x = 1; int y; y = 2; }",
+			errs: []string{"1:16: declared and not used: x", "1:27: declared and not used: y"},
+		},
+		{
+			name: "unused parameters are fine",
+			src:  "void f(int x) { }",
+		},
+		{
+			name: "value never used",
+			src:  "int f() { int x = 1; x = 2; return x; }",
+			errs: []string{"1:15: value assigned to x is never used"},
+		},
+		{
+			name: "parameter overwritten",
+			src:  "int f(int x) { x = 2; x = 3; return x; }",
+			errs: []string{"1:16: value assigned to x is never used"},
+		},
+		{
+			name: "used uninitialized",
+			src:  "int f() { int x; return x + 1; }",
+			errs: []string{"1:25: x is used uninitialized"},
+		},
+		{
+			name: "maybe uninitialized",
+			src: `int f(int x) {
+    int y;
+    int z = 1;
+    if (x > 0) y = x;
+    z = 2;
+    return y + z;
+}`,
+			errs: []string{"3:9: value assigned to z is never used", "6:12: y may be used uninitialized"},
+		},
+		{
+			name: "initialized in both branches",
+			src:  "int f(boolean b) { int y; if (b) y = 1; else y = 2; return y; }",
+		},
+		{
+			name: "loops",
+			src:  "int f() { int i = 0; int sum; while (i < 10) { sum = sum + i; i = i + 1; } return sum; }",
+			errs: []string{"1:54: sum may be used uninitialized", "1:83: sum may be used uninitialized"},
+		},
+		{
+			name: "value used on the next iteration",
+			src:  "void f() { int i = 0; int last = 0; while (i < 10) { g(last); last = i; i = i + 1; } } void g(int x) { }",
+		},
+		{
+			name: "unreachable",
+			src:  "int f() { int x = 1; return x; x = 2; }",
+			errs: []string{"1:32: value assigned to x is never used"},
+		},
+		{
+			name: "structs are always defined",
+			src:  "struct point { int x; }; int f() { point p; p.x = 1; return p.x; }",
+		},
+		{
+			name: "globals and fields aren't analyzed",
+			src:  "int g; class A { int n; void set() { n = 1; g = 2; } };",
+		},
+		{
+			name: "methods",
+			src:  "class A { int m() { int x; return x; } };",
+			errs: []string{"1:35: x is used uninitialized"},
+		},
+		{
+			name: "namespaces",
+			src:  "namespace n { int f() { int x; return x; } } boolean b = n.f();",
+			errs: []string{"1:39: x is used uninitialized", "1:58: cannot use n.f() (int) as boolean value in initialization"},
+		},
+		{
+			name: "with type errors",
+			src:  `void f() { int x = "a"; g(x); } void g(int x) { }`,
+			errs: []string{`1:20: cannot use "a" (string) as int value in initialization`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := cymbol.ParseFile(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, diags := Analyze(f)
+			var errs []string
+			for _, d := range diags.List() {
+				errs = append(errs, d.Error())
+			}
+			if !cmp.Equal(errs, tc.errs) {
+				t.Error(cmp.Diff(errs, tc.errs))
+			}
+		})
+	}
+}
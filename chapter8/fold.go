@@ -0,0 +1,292 @@
+package types
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"example.com/cymbol"
+)
+
+// Constant folding
+//
+// An operator whose operands are literals always gives the same value, so it
+// can be computed once by the compiler instead of every time the program
+// runs. Fold rewrites the tree with cymbol.Apply, bottom-up, replacing each
+// such operator by the literal of its value, which can make the operator it's
+// an operand of foldable too:
+//
+//	int x = 2 * 3 + 1;          int x = 7;
+//	float y = 1 + 0.5;          float y = 1.5;
+//	boolean b = 1 < 2 == true;  boolean b = true;
+//	string s = "a" + "b" + c;   string s = ("ab" + c);
+//
+// The values are the ones computing the operators at run time would give,
+// following the type tables of types.go: ints and chars mixed with floats are
+// promoted, comparisons give booleans and + on strings concatenates them.
+// Operators that would fail or whose value has no literal are left alone:
+// divisions by zero, arithmetic on two chars and anything that isn't valid
+// for the types, which is for type checking to report. Negative numbers are
+// folded into literals such as `-3`, which the parser never builds but print
+// the same as the expressions they replace.
+
+// Fold folds the constant expressions in the tree rooted at n, changing it in
+// place, and returns its root, a new one if n itself was folded.
+func Fold(n cymbol.Node) cymbol.Node {
+	return cymbol.Apply(n, nil, func(c *cymbol.Cursor) bool {
+		var v any
+		var ok bool
+		switch x := c.Node().(type) {
+		case *cymbol.BinaryExpr:
+			v, ok = foldBinary(x)
+		case *cymbol.UnaryExpr:
+			v, ok = foldUnary(x)
+		}
+		if ok {
+			c.Replace(literal(v, c.Node().Pos()))
+		}
+		return true
+	})
+}
+
+// constant returns the value of x if it's a literal, as an int64, float64,
+// rune, string or bool.
+func constant(x cymbol.Expr) (any, bool) {
+	switch x := x.(type) {
+	case *cymbol.IntLit:
+		return x.Value, true
+	case *cymbol.FloatLit:
+		return x.Value, true
+	case *cymbol.CharLit:
+		return x.Value, true
+	case *cymbol.StringLit:
+		return x.Value, true
+	case *cymbol.BoolLit:
+		return x.Value, true
+	}
+	return nil, false
+}
+
+func foldBinary(x *cymbol.BinaryExpr) (any, bool) {
+	a, ok := constant(x.X)
+	if !ok {
+		return nil, false
+	}
+	b, ok := constant(x.Y)
+	if !ok {
+		return nil, false
+	}
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return foldStrings(x.Op.Type, a, b)
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return foldBools(x.Op.Type, a, b)
+		}
+	default:
+		return foldNumbers(x.Op.Type, a, b)
+	}
+	return nil, false
+}
+
+func foldStrings(op cymbol.TokenType, a, b string) (any, bool) {
+	switch op {
+	case cymbol.Plus:
+		return a + b, true
+	case cymbol.Eq:
+		return a == b, true
+	case cymbol.Ne:
+		return a != b, true
+	}
+	return nil, false
+}
+
+func foldBools(op cymbol.TokenType, a, b bool) (any, bool) {
+	switch op {
+	case cymbol.Eq:
+		return a == b, true
+	case cymbol.Ne:
+		return a != b, true
+	}
+	return nil, false
+}
+
+// foldNumbers folds an operator on ints, floats and chars, promoting them to
+// float if either is one.
+func foldNumbers(op cymbol.TokenType, a, b any) (any, bool) {
+	_, aChar := a.(rune)
+	_, bChar := b.(rune)
+	if isFloatValue(a) || isFloatValue(b) {
+		x, okx := toFloat(a)
+		y, oky := toFloat(b)
+		if !okx || !oky {
+			return nil, false
+		}
+		return foldFloats(op, x, y)
+	}
+	x, okx := toInt(a)
+	y, oky := toInt(b)
+	if !okx || !oky {
+		return nil, false
+	}
+	if aChar && bChar && isArithmetic(op) {
+		// a char, which may not have a literal
+		return nil, false
+	}
+	return foldInts(op, x, y)
+}
+
+func foldInts(op cymbol.TokenType, x, y int64) (any, bool) {
+	switch op {
+	case cymbol.Plus:
+		return x + y, true
+	case cymbol.Minus:
+		return x - y, true
+	case cymbol.Star:
+		return x * y, true
+	case cymbol.Slash:
+		if y == 0 {
+			return nil, false
+		}
+		return x / y, true
+	}
+	return compare(op, x, y)
+}
+
+func foldFloats(op cymbol.TokenType, x, y float64) (any, bool) {
+	var v float64
+	switch op {
+	case cymbol.Plus:
+		v = x + y
+	case cymbol.Minus:
+		v = x - y
+	case cymbol.Star:
+		v = x * y
+	case cymbol.Slash:
+		if y == 0 {
+			return nil, false
+		}
+		v = x / y
+	default:
+		return compare(op, x, y)
+	}
+	if math.IsInf(v, 0) {
+		return nil, false
+	}
+	return v, true
+}
+
+func compare[T int64 | float64](op cymbol.TokenType, x, y T) (any, bool) {
+	switch op {
+	case cymbol.Lt:
+		return x < y, true
+	case cymbol.Le:
+		return x <= y, true
+	case cymbol.Gt:
+		return x > y, true
+	case cymbol.Ge:
+		return x >= y, true
+	case cymbol.Eq:
+		return x == y, true
+	case cymbol.Ne:
+		return x != y, true
+	}
+	return nil, false
+}
+
+func isArithmetic(op cymbol.TokenType) bool {
+	switch op {
+	case cymbol.Plus, cymbol.Minus, cymbol.Star, cymbol.Slash:
+		return true
+	}
+	return false
+}
+
+func isFloatValue(v any) bool {
+	_, ok := v.(float64)
+	return ok
+}
+
+func toInt(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case rune:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case rune:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func foldUnary(x *cymbol.UnaryExpr) (any, bool) {
+	v, ok := constant(x.X)
+	if !ok {
+		return nil, false
+	}
+	switch v := v.(type) {
+	case int64:
+		if x.Op.Type == cymbol.Minus {
+			return -v, true
+		}
+	case rune:
+		// -c is an int
+		if x.Op.Type == cymbol.Minus {
+			return -int64(v), true
+		}
+	case float64:
+		if x.Op.Type == cymbol.Minus {
+			return -v, true
+		}
+	case bool:
+		if x.Op.Type == cymbol.Not {
+			return !v, true
+		}
+	}
+	return nil, false
+}
+
+// literal returns the literal for the value v, at pos.
+func literal(v any, pos cymbol.Pos) cymbol.Expr {
+	switch v := v.(type) {
+	case int64:
+		tok := cymbol.Token{Type: cymbol.Int, Text: strconv.FormatInt(v, 10), Pos: pos}
+		return &cymbol.IntLit{Token: tok, Value: v}
+	case float64:
+		text := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(text, ".") {
+			text += ".0"
+		}
+		tok := cymbol.Token{Type: cymbol.Float, Text: text, Pos: pos}
+		return &cymbol.FloatLit{Token: tok, Value: v}
+	case string:
+		tok := cymbol.Token{Type: cymbol.String, Text: quote(v), Pos: pos}
+		return &cymbol.StringLit{Token: tok, Value: v}
+	case bool:
+		tok := cymbol.Token{Type: cymbol.False, Text: "false", Pos: pos}
+		if v {
+			tok.Type, tok.Text = cymbol.True, "true"
+		}
+		return &cymbol.BoolLit{Token: tok, Value: v}
+	}
+	panic("literal: not a constant value")
+}
+
+// quote writes s as a Cymbol string literal, escaping what the lexer expects
+// escaped.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
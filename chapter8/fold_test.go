@@ -0,0 +1,93 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+func TestFold(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/fold.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folded := map[string]string{}
+	for _, file := range ar.Files {
+		if name, ok := strings.CutSuffix(file.Name, ".folded"); ok {
+			folded[name] = strings.TrimSpace(string(file.Data))
+		}
+	}
+
+	for _, file := range ar.Files {
+		want, ok := folded[file.Name]
+		if !ok {
+			continue
+		}
+		t.Run(file.Name, func(t *testing.T) {
+			f, err := cymbol.ParseFile(string(file.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Fold(f).String(); got != want {
+				t.Error(cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestFoldExpr(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{expr: "1 + 2", want: "3"},
+		{expr: "x", want: "x"},
+		{expr: "-(1 + 2)", want: "-3"},
+		{expr: "f(1 + 2).y", want: "f(3).y"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			x, err := cymbol.ParseExpr(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Fold(x).String(); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFoldTypes(t *testing.T) {
+	// folding doesn't change the types of expressions
+	src := `void f() { int i = 2 * 3 + 'a'; float x = 1 + 0.5; string s = "a" + "b"; boolean b = 1 < 2.5; }`
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, diags := Check(f)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, d := range f.Decls[0].(*cymbol.FuncDecl).Body.Stmts {
+		want = append(want, before.Types.Get(d.(*cymbol.VarDecl).Value).Name())
+	}
+
+	Fold(f)
+	after, diags := Check(f)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range f.Decls[0].(*cymbol.FuncDecl).Body.Stmts {
+		got = append(got, after.Types.Get(d.(*cymbol.VarDecl).Value).Name())
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,15 @@
+module example.com/types
+
+go 1.23.4
+
+require (
+	example.com/cymbol v0.0.0
+	example.com/symtab v0.0.0
+	github.com/google/go-cmp v0.6.0
+	golang.org/x/tools v0.28.0
+)
+
+replace (
+	example.com/cymbol => ../cymbol
+	example.com/symtab => ../chapter6
+)
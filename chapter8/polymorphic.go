@@ -0,0 +1,125 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Pattern 23:
+// Enforcing Polymorphic Type Safety
+
+// An instance of a class is also an instance of its superclass, and of the
+// superclass of that one: it has all their members. So a B can be used where
+// an A is expected, if B inherits from A, but not the other way around:
+//
+//	class A { int n; };
+//	class B : A { int k; };
+//	A a; B b;
+//	a = b;     // fine, b is an A
+//	b = a;     // cannot use a (A) as B value in assignment, a may not have k
+//
+// That holds wherever a value is used, assignments, initializations,
+// arguments and returns, since they all check types with assignable.
+//
+// A method with the name of an inherited one overrides it, and calls through
+// a variable of the superclass may end up in the subclass' method. For that to
+// be safe it has to take the same parameters and return a value that can be
+// used as the inherited method's one: the same type, or a subclass of it if
+// it's a class. Redeclaring an inherited field as a method isn't allowed.
+
+// subclass reports whether from is to or a class inheriting from it, directly
+// or not.
+func subclass(from, to symtab.Type) bool {
+	c, ok := from.(*symtab.ClassSymbol)
+	if !ok {
+		return false
+	}
+	for ; c != nil; c = c.Superclass {
+		if symtab.Type(c) == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOverrides checks that the methods of the class d declares that
+// override inherited ones are compatible with them.
+func (c *CheckTypes) checkOverrides(d *cymbol.ClassDecl) {
+	if d.Super == nil {
+		return
+	}
+	super, ok := c.Refs[d.Super].(*symtab.ClassSymbol)
+	if !ok {
+		return
+	}
+	for _, m := range d.Members {
+		f, ok := m.(*cymbol.FuncDecl)
+		if !ok {
+			continue
+		}
+		inherited := super.ResolveMember(f.Name.Name)
+		if inherited == nil {
+			continue
+		}
+		owner := inherited.Scope().(*symtab.ClassSymbol).Name()
+		g, ok := inherited.(*symtab.FunctionSymbol)
+		if !ok {
+			c.report(f.Name, "method %s.%s redeclares field %s.%s", d.Name, f.Name, owner, f.Name)
+			continue
+		}
+		if !c.overrides(f, g) {
+			c.report(f.Name, "method %s.%s overrides %s.%s with a different signature: %s, want %s",
+				d.Name, f.Name, owner, f.Name, signature(f), funcSignature(g))
+		}
+	}
+}
+
+// overrides reports whether the method f can override g.
+func (c *CheckTypes) overrides(f *cymbol.FuncDecl, g *symtab.FunctionSymbol) bool {
+	ret, ok := c.Refs[f.Type].(symtab.Type)
+	if !ok || g.Type() == nil {
+		// unknown types, reported already
+		return true
+	}
+	if ret != g.Type() && !subclass(ret, g.Type()) {
+		return false
+	}
+	params := g.Params()
+	if len(f.Params) != len(params) {
+		return false
+	}
+	for i, p := range f.Params {
+		if typ, _ := c.Refs[p.Type].(symtab.Type); typ != params[i].Type() {
+			return false
+		}
+	}
+	return true
+}
+
+// signature returns the signature of the method f declares, like
+// `int(float, A)`.
+func signature(f *cymbol.FuncDecl) string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.Type.Name
+	}
+	return fmt.Sprintf("%s(%s)", f.Type.Name, strings.Join(params, ", "))
+}
+
+func funcSignature(f *symtab.FunctionSymbol) string {
+	var params []string
+	for _, p := range f.Params() {
+		params = append(params, typeName(p.Type()))
+	}
+	return fmt.Sprintf("%s(%s)", typeName(f.Type()), strings.Join(params, ", "))
+}
+
+func typeName(t symtab.Type) string {
+	if t == nil {
+		return "?"
+	}
+	return t.Name()
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPolymorphicCheck(t *testing.T) {
+	const classes = `
+class A { int n; A self() { return this; } void set(int x) { n = x; } };
+class B : A { int k; };
+class C : B { };
+class D { };
+A a; B b; C c; D d;
+void take(A x) { }
+`
+	cases := []struct {
+		src  string
+		errs []string
+	}{
+		{src: "void test() { a = b; a = c; b = c; a = a; }"},
+		{src: "void test() { b = a; }", errs: []string{"cannot use a (A) as B value in assignment"}},
+		{src: "void test() { c = b; }", errs: []string{"cannot use b (B) as C value in assignment"}},
+		{src: "void test() { a = d; }", errs: []string{"cannot use d (D) as A value in assignment"}},
+		{src: "A x = c;"},
+		{src: "C x = a;", errs: []string{"cannot use a (A) as C value in initialization"}},
+		{src: "void test() { take(b); take(c); }"},
+		{src: "void test() { take(d); }", errs: []string{"cannot use d (D) as A value in argument to take"}},
+		{src: "A test() { return c; }"},
+		{src: "B test() { return a; }", errs: []string{"cannot use a (A) as B value in return statement"}},
+		{src: "void test() { b = b.self(); }", errs: []string{"cannot use b.self() (A) as B value in assignment"}},
+		{src: "class E : B { void f() { A x = this; B y = super; } };"},
+		{src: "class E : A { void f() { B x = this; } };", errs: []string{"cannot use this (E) as B value in initialization"}},
+
+		// overrides
+		{src: "class E : A { void set(int x) { } };"},
+		{src: "class E : A { B self() { return this; } };", errs: []string{"cannot use this (E) as B value in return statement"}},
+		{src: "class E : C { C self() { return this; } void set(int y) { } };"},
+		{src: "class E : C { D self() { return d; } };", errs: []string{"method E.self overrides A.self with a different signature: D(), want A()"}},
+		{src: "class E : A { void set(char x) { } };", errs: []string{"method E.set overrides A.set with a different signature: void(char), want void(int)"}},
+		{src: "class E : A { void set() { } };", errs: []string{"method E.set overrides A.set with a different signature: void(), want void(int)"}},
+		{src: "class E : A { int set(int x) { return x; } };", errs: []string{"method E.set overrides A.set with a different signature: int(int), want void(int)"}},
+		{src: "class E : B { int n() { return 1; } };", errs: []string{"method E.n redeclares field A.n"}},
+		{src: "class E : A { float k; void f(float x) { } }; class F : E { void f(int x) { } };", errs: []string{"method F.f overrides E.f with a different signature: void(int), want void(float)"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.src, func(t *testing.T) {
+			f, err := cymbol.ParseFile(classes + tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, diags := Check(f)
+			var errs []string
+			for _, d := range diags.List() {
+				errs = append(errs, d.Message)
+			}
+			if !cmp.Equal(errs, tc.errs) {
+				t.Error(cmp.Diff(errs, tc.errs))
+			}
+		})
+	}
+}
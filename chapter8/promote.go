@@ -0,0 +1,133 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Pattern 21:
+// Automatic Type Promotion
+
+// C and Cymbol let values of a narrower type be used where a wider one is
+// expected: in `i * 2.5` the int i is converted to float before multiplying,
+// and `float f = 1;` stores 1.0. The compiler inserts those conversions, so it
+// has to know where they go. Along with the type of each expression, its
+// evalType, ComputeTypes records the type it's promoted to, its promoteToType,
+// for the expressions that need converting:
+//
+//	the operands of an operator, to the wider of the two types
+//	the value of an initializer or assignment, to the type of the variable
+//	the arguments of a call, to the types of the parameters
+//	the value returned, to the return type of the function
+//
+// Only the promotions in the table below happen implicitly: char to int, and
+// char or int to float. Anything else isn't promoted, whether it's fine or
+// not is for type checking to say.
+
+// promotions are the implicit conversions, from a type to another
+var promotions = map[[2]string]bool{
+	{"char", "int"}:   true,
+	{"char", "float"}: true,
+	{"int", "float"}:  true,
+}
+
+// Promotes reports whether a value of the type named from is promoted to the
+// type named to where a value of that type is expected.
+func Promotes(from, to string) bool {
+	return promotions[[2]string{from, to}]
+}
+
+// Wider returns the name of the type the operands of an operator are
+// promoted to, for operands of the types named x and y: the wider of both if
+// they're numbers, "" otherwise.
+func Wider(x, y string) string {
+	return arithmetic[[2]string{x, y}]
+}
+
+// promote records that x is converted to the type to, if that's a promotion.
+func (c *ComputeTypes) promote(x cymbol.Expr, to symtab.Type) {
+	from := c.Types.Get(x)
+	if from == nil || to == nil || !Promotes(from.Name(), to.Name()) {
+		return
+	}
+	c.Promotions.Set(x, to)
+}
+
+// promoteOperands promotes the operands of an operator to the type they're
+// operated on: the wider of both types, which is the type of the result for
+// arithmetic operators but not for comparisons.
+func (c *ComputeTypes) promoteOperands(x cymbol.Expr) {
+	switch x := x.(type) {
+	case *cymbol.BinaryExpr:
+		tx, ty := c.Types.Get(x.X), c.Types.Get(x.Y)
+		if tx == nil || ty == nil {
+			return
+		}
+		if wider := Wider(tx.Name(), ty.Name()); wider != "" {
+			c.promote(x.X, c.builtin(wider))
+			c.promote(x.Y, c.builtin(wider))
+		}
+	case *cymbol.UnaryExpr:
+		// -c is an int
+		if x.Op.Type == cymbol.Minus {
+			c.promote(x.X, c.Types.Get(x))
+		}
+	case *cymbol.CallExpr:
+		f, ok := c.Refs[x.Fun].(*symtab.FunctionSymbol)
+		if !ok {
+			return
+		}
+		for i, p := range f.Params() {
+			if i < len(x.Args) {
+				c.promote(x.Args[i], p.Type())
+			}
+		}
+	}
+}
+
+// promoteValue promotes the values of statements and declarations to the
+// type of where they go.
+func (c *ComputeTypes) promoteValue(n cymbol.Node) {
+	switch n := n.(type) {
+	case *cymbol.VarDecl:
+		if n.Value != nil {
+			typ, _ := c.Refs[n.Type].(symtab.Type)
+			c.promote(n.Value, typ)
+		}
+	case *cymbol.AssignStmt:
+		c.promote(n.Value, c.Types.Get(n.Target))
+	case *cymbol.ReturnStmt:
+		if n.Value != nil {
+			c.promote(n.Value, c.returns)
+		}
+	}
+}
+
+// Explicit renders x with the conversions the promotions insert written out,
+// such as `(float(i) * 2.5)`.
+func (c *ComputeTypes) Explicit(x cymbol.Expr) string {
+	var s string
+	switch x := x.(type) {
+	case *cymbol.BinaryExpr:
+		s = fmt.Sprintf("(%s %s %s)", c.Explicit(x.X), x.Op.Text, c.Explicit(x.Y))
+	case *cymbol.UnaryExpr:
+		s = fmt.Sprintf("(%s%s)", x.Op.Text, c.Explicit(x.X))
+	case *cymbol.CallExpr:
+		args := make([]string, len(x.Args))
+		for i, a := range x.Args {
+			args[i] = c.Explicit(a)
+		}
+		s = fmt.Sprintf("%s(%s)", c.Explicit(x.Fun), strings.Join(args, ", "))
+	case *cymbol.MemberExpr:
+		s = fmt.Sprintf("%s.%v", c.Explicit(x.X), x.Member)
+	default:
+		s = x.String()
+	}
+	if to := c.Promotions.Get(x); to != nil {
+		return to.Name() + "(" + s + ")"
+	}
+	return s
+}
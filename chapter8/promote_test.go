@@ -0,0 +1,86 @@
+package types
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"github.com/google/go-cmp/cmp"
+)
+
+// promotionsOf returns the expressions promoted in the tree rooted at n and
+// the type each one is promoted to, in the order of the tree
+func promotionsOf(c *ComputeTypes, n cymbol.Node) []string {
+	var got []string
+	for _, x := range exprsOf(n) {
+		if to := c.Promotions.Get(x); to != nil {
+			got = append(got, x.String()+" "+to.Name())
+		}
+	}
+	return got
+}
+
+// exprsOf returns the expressions in the tree rooted at n, in postorder
+func exprsOf(n cymbol.Node) []cymbol.Expr {
+	l := &exprs{}
+	symtab.Walk(l, n)
+	return l.list
+}
+
+type exprs struct{ list []cymbol.Expr }
+
+func (l *exprs) Enter(n cymbol.Node) {}
+
+func (l *exprs) Exit(n cymbol.Node) {
+	if x, ok := n.(cymbol.Expr); ok {
+		l.list = append(l.list, x)
+	}
+}
+
+func TestPromotions(t *testing.T) {
+	cases := []struct {
+		stmt       string
+		promotions []string
+		explicit   string // the last expression with its conversions
+	}{
+		{stmt: "i * f;", promotions: []string{"i float"}, explicit: "(float(i) * f)"},
+		{stmt: "f * i;", promotions: []string{"i float"}, explicit: "(f * float(i))"},
+		{stmt: "i + c;", promotions: []string{"c int"}, explicit: "(i + int(c))"},
+		{stmt: "c + c;", explicit: "(c + c)"},
+		{stmt: "c + f;", promotions: []string{"c float"}, explicit: "(float(c) + f)"},
+		{stmt: "i + 'a' * f;", promotions: []string{"i float", "'a' float"}, explicit: "(float(i) + (float('a') * f))"},
+		{stmt: "c < i;", promotions: []string{"c int"}, explicit: "(int(c) < i)"},
+		{stmt: "i == f;", promotions: []string{"i float"}, explicit: "(float(i) == f)"},
+		{stmt: "-c;", promotions: []string{"c int"}, explicit: "(-int(c))"},
+		{stmt: "-i;", explicit: "(-i)"},
+		{stmt: "s + i;", explicit: "(s + i)"},
+		{stmt: "f = i;", promotions: []string{"i float"}, explicit: "float(i)"},
+		{stmt: "i = f;", explicit: "f"},
+		{stmt: "p.y = c;", promotions: []string{"c float"}, explicit: "float(c)"},
+		{stmt: "float x = 1;", promotions: []string{"1 float"}, explicit: "float(1)"},
+		{stmt: "int x = c + c;", promotions: []string{"(c + c) int"}, explicit: "int((c + c))"},
+		{stmt: "g(c);", promotions: []string{"c int"}, explicit: "g(int(c))"},
+		{stmt: "g(i * f);", promotions: []string{"i float"}, explicit: "g((float(i) * f))"},
+		{stmt: "return i;", promotions: []string{"i float"}, explicit: "float(i)"},
+		{stmt: "return g(c);", promotions: []string{"c int"}, explicit: "g(int(c))"},
+		{stmt: "q = i;", explicit: "i"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.stmt, func(t *testing.T) {
+			f, err := cymbol.ParseFile(decls + "float test() { " + tc.stmt + " }")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, _ := Compute(f)
+			test := f.Decls[len(f.Decls)-1].(*cymbol.FuncDecl)
+			if got := promotionsOf(c, test.Body); !cmp.Equal(got, tc.promotions) {
+				t.Error(cmp.Diff(got, tc.promotions))
+			}
+			xs := exprsOf(test.Body)
+			if got := c.Explicit(xs[len(xs)-1]); got != tc.explicit {
+				t.Errorf("got %s, want %s", got, tc.explicit)
+			}
+		})
+	}
+}
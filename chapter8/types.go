@@ -0,0 +1,216 @@
+// Package types computes and checks the static types of Cymbol expressions,
+// the patterns of the book's chapter 8. It also folds the constant ones and
+// follows the flow of values through functions and the calls between them.
+// It works on the symbol tables of package symtab.
+package types
+
+import (
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Pattern 20:
+// Computing Static Expression Types
+
+// Once every name is resolved to a symbol, the type of each expression can be
+// computed bottom-up: literals have the type of their kind, a name has the
+// type of the symbol it resolves to, a call has the return type of the
+// function and a member access the type of the field. The type of an operator
+// expression comes from the types of its operands, which are known by the
+// time the operator is left in a walk:
+//
+//	int i; float f;
+//	i + 'a'   int
+//	i * f     float
+//	i < f     boolean
+//	"a" + "b" string
+//
+// Like the book, the result of an operator is looked up in a table indexed by
+// the types of its operands. Combinations that make no sense, such as adding
+// booleans, give void: this pattern only computes types, checking them is
+// Pattern 22's job.
+
+// ComputeTypes is the listener that computes the type of each expression,
+// its evalType, and the type it's promoted to if any, see promote.go. It
+// needs the symbols the names were resolved to by a previous walk, as a
+// DefRef's or RefPhase's Refs. Defs, the symbols declarations define, isn't
+// used to compute types but Compute keeps them for the passes after it.
+type ComputeTypes struct {
+	Table      *symtab.SymbolTable
+	Refs       map[cymbol.Expr]symtab.Symbol
+	Defs       map[*cymbol.Ident]symtab.Symbol
+	Types      cymbol.Attr[symtab.Type] // nil for names that didn't resolve
+	Promotions cymbol.Attr[symtab.Type] // only for the expressions promoted
+
+	returns symtab.Type // the return type of the function being walked, if any
+}
+
+func NewComputeTypes(table *symtab.SymbolTable, refs map[cymbol.Expr]symtab.Symbol) *ComputeTypes {
+	return &ComputeTypes{Table: table, Refs: refs}
+}
+
+func (c *ComputeTypes) Enter(n cymbol.Node) {
+	if f, ok := n.(*cymbol.FuncDecl); ok {
+		c.returns, _ = c.Refs[f.Type].(symtab.Type)
+	}
+}
+
+// Exit computes the type of an expression, the ones of its subexpressions
+// have been computed already.
+func (c *ComputeTypes) Exit(n cymbol.Node) {
+	x, ok := n.(cymbol.Expr)
+	if !ok {
+		c.promoteValue(n)
+		if _, ok := n.(*cymbol.FuncDecl); ok {
+			c.returns = nil
+		}
+		return
+	}
+	c.Types.Set(x, c.evalType(x))
+	c.promoteOperands(x)
+}
+
+func (c *ComputeTypes) evalType(x cymbol.Expr) symtab.Type {
+	switch x := x.(type) {
+	case *cymbol.IntLit:
+		return c.builtin("int")
+	case *cymbol.FloatLit:
+		return c.builtin("float")
+	case *cymbol.CharLit:
+		return c.builtin("char")
+	case *cymbol.StringLit:
+		return c.builtin("string")
+	case *cymbol.BoolLit:
+		return c.builtin("boolean")
+	case *cymbol.BinaryExpr:
+		return c.resultType(x.Op.Type, c.Types.Get(x.X), c.Types.Get(x.Y))
+	case *cymbol.UnaryExpr:
+		return c.unaryType(x.Op.Type, c.Types.Get(x.X))
+	case *cymbol.CallExpr:
+		// the type of the function's name is its return type already
+		return c.Types.Get(x.Fun)
+	default:
+		// names, member accesses, this and super
+		if sym := c.Refs[x]; sym != nil {
+			return sym.Type()
+		}
+		return nil
+	}
+}
+
+func (c *ComputeTypes) builtin(name string) symtab.Type {
+	return c.Table.Globals.Resolve(name).(symtab.Type)
+}
+
+// result types of the operators for each pair of operand types, the pairs
+// missing give void
+var (
+	arithmetic = map[[2]string]string{
+		{"char", "char"}:   "char",
+		{"char", "int"}:    "int",
+		{"char", "float"}:  "float",
+		{"int", "char"}:    "int",
+		{"int", "int"}:     "int",
+		{"int", "float"}:   "float",
+		{"float", "char"}:  "float",
+		{"float", "int"}:   "float",
+		{"float", "float"}: "float",
+	}
+	relational = map[[2]string]string{
+		{"char", "char"}:   "boolean",
+		{"char", "int"}:    "boolean",
+		{"char", "float"}:  "boolean",
+		{"int", "char"}:    "boolean",
+		{"int", "int"}:     "boolean",
+		{"int", "float"}:   "boolean",
+		{"float", "char"}:  "boolean",
+		{"float", "int"}:   "boolean",
+		{"float", "float"}: "boolean",
+	}
+	equality = map[[2]string]string{
+		{"boolean", "boolean"}: "boolean",
+		{"string", "string"}:   "boolean",
+	}
+	concatenation = map[[2]string]string{
+		{"string", "string"}: "string",
+	}
+)
+
+func init() {
+	// anything that can be ordered can be compared for equality
+	for pair := range relational {
+		equality[pair] = "boolean"
+	}
+}
+
+// resultType returns the type of a binary operator applied to operands of
+// types x and y. It's nil if either is unknown.
+func (c *ComputeTypes) resultType(op cymbol.TokenType, x, y symtab.Type) symtab.Type {
+	if x == nil || y == nil {
+		return nil
+	}
+	if result := ResultType(op, x.Name(), y.Name()); result != "" {
+		return c.builtin(result)
+	}
+	return c.builtin("void")
+}
+
+// unaryType returns the type of a unary operator applied to an operand of
+// type x, nil if it's unknown.
+func (c *ComputeTypes) unaryType(op cymbol.TokenType, x symtab.Type) symtab.Type {
+	if x == nil {
+		return nil
+	}
+	if result := UnaryType(op, x.Name()); result != "" {
+		return c.builtin(result)
+	}
+	return c.builtin("void")
+}
+
+// ResultType returns the name of the type of a binary operator applied to
+// operands of the types named x and y, "" if it isn't defined on them. It's
+// exported, with UnaryType, Wider and Promotes, for the values of an
+// interpreter to follow the same rules.
+func ResultType(op cymbol.TokenType, x, y string) string {
+	pair := [2]string{x, y}
+	switch op {
+	case cymbol.Plus:
+		if result := arithmetic[pair]; result != "" {
+			return result
+		}
+		return concatenation[pair]
+	case cymbol.Minus, cymbol.Star, cymbol.Slash:
+		return arithmetic[pair]
+	case cymbol.Lt, cymbol.Le, cymbol.Gt, cymbol.Ge:
+		return relational[pair]
+	case cymbol.Eq, cymbol.Ne:
+		return equality[pair]
+	}
+	return ""
+}
+
+// UnaryType returns the name of the type of a unary operator applied to an
+// operand of the type named x, "" if it isn't defined on it.
+func UnaryType(op cymbol.TokenType, x string) string {
+	switch {
+	case op == cymbol.Minus && (x == "int" || x == "float"):
+		return x
+	case op == cymbol.Minus && x == "char":
+		return "int"
+	case op == cymbol.Not && x == "boolean":
+		return x
+	}
+	return ""
+}
+
+// Compute defines and resolves the symbols of f in two passes and then
+// computes the type of each expression. The diagnostics are the ones of
+// resolving the symbols.
+func Compute(f *cymbol.File) (*ComputeTypes, *cymbol.Diagnostics) {
+	table := symtab.NewSymbolTable()
+	defs, refs := symtab.TwoPass(table, f)
+	c := NewComputeTypes(table, refs.Refs)
+	c.Defs = defs.Defs
+	symtab.Walk(c, f)
+	return c, defs.Diagnostics
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"github.com/google/go-cmp/cmp"
+)
+
+// decls declares a name of each type for the expressions in the tests
+const decls = `
+int i; float f; char c; boolean b; string s;
+struct point { int x; float y; };
+point p;
+float g(int a) { return a; }
+class A { int n; int m() { return this.n; } };
+class B : A { float k() { return super.m(); } int m() { return n + 1; } };
+B o;
+`
+
+// typesOf returns the type of each expression in the tree rooted at n, in
+// the order they're computed
+func typesOf(c *ComputeTypes, n cymbol.Node) []string {
+	l := &collector{c: c}
+	symtab.Walk(l, n)
+	return l.types
+}
+
+type collector struct {
+	c     *ComputeTypes
+	types []string
+}
+
+func (l *collector) Enter(n cymbol.Node) {}
+
+func (l *collector) Exit(n cymbol.Node) {
+	if x, ok := n.(cymbol.Expr); ok {
+		typ := "<nil>"
+		if t := l.c.Types.Get(x); t != nil {
+			typ = t.Name()
+		}
+		l.types = append(l.types, x.String()+" "+typ)
+	}
+}
+
+func TestComputeTypes(t *testing.T) {
+	cases := []struct {
+		expr  string
+		types []string
+	}{
+		{expr: "1", types: []string{"1 int"}},
+		{expr: "1.5", types: []string{"1.5 float"}},
+		{expr: "'a'", types: []string{"'a' char"}},
+		{expr: `"a"`, types: []string{`"a" string`}},
+		{expr: "true", types: []string{"true boolean"}},
+		{expr: "i + c", types: []string{"i int", "c char", "(i + c) int"}},
+		{expr: "c + c", types: []string{"c char", "c char", "(c + c) char"}},
+		{expr: "i * f", types: []string{"i int", "f float", "(i * f) float"}},
+		{expr: "s + s", types: []string{"s string", "s string", "(s + s) string"}},
+		{expr: "i - s", types: []string{"i int", "s string", "(i - s) void"}},
+		{expr: "b + b", types: []string{"b boolean", "b boolean", "(b + b) void"}},
+		{expr: "c < f", types: []string{"c char", "f float", "(c < f) boolean"}},
+		{expr: "b == b", types: []string{"b boolean", "b boolean", "(b == b) boolean"}},
+		{expr: "s != i", types: []string{"s string", "i int", "(s != i) void"}},
+		{expr: "-c", types: []string{"c char", "(-c) int"}},
+		{expr: "-f", types: []string{"f float", "(-f) float"}},
+		{expr: "!b", types: []string{"b boolean", "(!b) boolean"}},
+		{expr: "!i", types: []string{"i int", "(!i) void"}},
+		{expr: "g(i)", types: []string{"g float", "i int", "g(i) float"}},
+		{expr: "p.x + p.y", types: []string{"p point", "p.x int", "p point", "p.y float", "(p.x + p.y) float"}},
+		{expr: "o.n", types: []string{"o B", "o.n int"}},
+		{expr: "o.k()", types: []string{"o B", "o.k float", "o.k() float"}},
+		{expr: "q + 1", types: []string{"q <nil>", "1 int", "(q + 1) <nil>"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			f, err := cymbol.ParseFile(decls + "void test() { " + tc.expr + "; }")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, _ := Compute(f)
+			test := f.Decls[len(f.Decls)-1].(*cymbol.FuncDecl)
+			if got := typesOf(c, test.Body); !cmp.Equal(got, tc.types) {
+				t.Error(cmp.Diff(got, tc.types))
+			}
+		})
+	}
+}
+
+func TestComputeTypesMethods(t *testing.T) {
+	f, err := cymbol.ParseFile(decls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, diags := Compute(f)
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+	b := f.Decls[len(f.Decls)-2].(*cymbol.ClassDecl)
+	want := []string{"super A", "super.m int", "super.m() int"}
+	if got := typesOf(c, b.Members[0]); !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,180 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"example.com/types"
+)
+
+// Builtins
+//
+// Cymbol code calls the functions of the host program, written in Go, as it
+// calls its own. They're global functions defined before any input, so they
+// resolve and are checked like the others. New defines these:
+//
+//	void print(any v)        prints v on a line of its own, a string as it is
+//	int len(string s)        the number of characters of s
+//	string type(any v)       the name of the type of v, null for a null object
+//	void assert(boolean b)   stops the program if b is false
+//
+// A program embedding the interpreter adds its own with Register:
+//
+//	in.Register("sqrt", math.Sqrt)
+//	in.Run("sqrt(2.0)")     // 1.4142135623730951
+//
+// A builtin is a Go function whose parameters and results have a Cymbol type:
+// int or int64 for int, float64 for float, rune for char, bool for boolean,
+// and string. A parameter of type Value, or any, takes a Value of any type,
+// which Cymbol code can't declare. The function returns a value or none, and
+// may return an error last, which stops the program with a RuntimeError at
+// the call. The Values of the arguments are converted to the Go types of the
+// parameters, and the value returned to a Value.
+
+// goTypes are the Cymbol types of the Go types builtins take and return.
+var goTypes = map[reflect.Type]string{
+	reflect.TypeFor[int]():     "int",
+	reflect.TypeFor[int64]():   "int",
+	reflect.TypeFor[float64](): "float",
+	reflect.TypeFor[rune]():    "char",
+	reflect.TypeFor[bool]():    "boolean",
+	reflect.TypeFor[string]():  "string",
+}
+
+var (
+	anyType   = reflect.TypeFor[any]()
+	valueType = reflect.TypeFor[Value]()
+	errorType = reflect.TypeFor[error]()
+)
+
+// Register defines the builtin name, calling the Go function fn. It's an error
+// if name is defined already, or if the parameters or results of fn have no
+// Cymbol type.
+func (in *Interpreter) Register(name string, fn any) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Errorf("builtin %s: %T is not a function", name, fn)
+	}
+	if in.table.Globals.Resolve(name) != nil {
+		return fmt.Errorf("builtin %s: %s is already defined", name, name)
+	}
+	t := v.Type()
+	if t.IsVariadic() {
+		return fmt.Errorf("builtin %s: variadic functions aren't supported", name)
+	}
+
+	results := t.NumOut()
+	if results > 0 && t.Out(results-1) == errorType {
+		results--
+	}
+	ret := in.table.Globals.Resolve("void").(symtab.Type)
+	switch results {
+	case 0:
+	case 1:
+		typ, ok := in.cymbolType(t.Out(0))
+		if !ok || typ == types.Any {
+			return fmt.Errorf("builtin %s: unsupported result type %v", name, t.Out(0))
+		}
+		ret = typ
+	default:
+		return fmt.Errorf("builtin %s: too many results", name)
+	}
+
+	f := symtab.NewFunctionSymbol(name, ret, in.table.Globals)
+	for i := range t.NumIn() {
+		typ, ok := in.cymbolType(t.In(i))
+		if !ok {
+			return fmt.Errorf("builtin %s: unsupported parameter type %v", name, t.In(i))
+		}
+		f.Define(symtab.NewVariableSymbol(fmt.Sprintf("p%d", i), typ))
+	}
+	in.table.Globals.Define(f)
+	in.builtins[f] = v
+	return nil
+}
+
+// cymbolType returns the Cymbol type of the Go type t, false if it has none.
+func (in *Interpreter) cymbolType(t reflect.Type) (symtab.Type, bool) {
+	if t == anyType || t == valueType {
+		return types.Any, true
+	}
+	name, ok := goTypes[t]
+	if !ok {
+		return nil, false
+	}
+	return in.table.Globals.Resolve(name).(symtab.Type), true
+}
+
+// callBuiltin calls fn, the builtin x calls, with the values of the
+// arguments.
+func (in *Interpreter) callBuiltin(x *cymbol.CallExpr, fn reflect.Value, args []Value) Value {
+	t := fn.Type()
+	params := make([]reflect.Value, len(args))
+	for i := range args {
+		if t.In(i) == anyType || t.In(i) == valueType {
+			params[i] = reflect.ValueOf(&args[i]).Elem().Convert(t.In(i))
+			continue
+		}
+		params[i] = reflect.ValueOf(args[i]).Convert(t.In(i))
+	}
+	results := fn.Call(params)
+	if n := len(results); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := results[n-1].Interface().(error); err != nil {
+			in.fail(spanOf(x.Fun), "%w", err)
+		}
+		results = results[:n-1]
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return fromGo(results[0])
+}
+
+// fromGo converts v, of one of the Go types in goTypes, to the Value of its
+// Cymbol type.
+func fromGo(v reflect.Value) Value {
+	switch goTypes[v.Type()] {
+	case "int":
+		return Int(v.Int())
+	case "char":
+		return Char(v.Int())
+	case "float":
+		return Float(v.Float())
+	case "boolean":
+		return Bool(v.Bool())
+	}
+	return String(v.String())
+}
+
+// defineBuiltins defines the builtins every interpreter has.
+func (in *Interpreter) defineBuiltins() {
+	builtins := []struct {
+		name string
+		fn   any
+	}{
+		{"print", func(v Value) {
+			if s, ok := v.(String); ok {
+				fmt.Fprintln(in.Stdout, string(s))
+				return
+			}
+			fmt.Fprintln(in.Stdout, v)
+		}},
+		{"len", utf8.RuneCountInString},
+		{"type", Value.Type},
+		{"assert", func(b bool) error {
+			if !b {
+				return errors.New("assertion failed")
+			}
+			return nil
+		}},
+	}
+	for _, b := range builtins {
+		if err := in.Register(b.name, b.fn); err != nil {
+			panic(err)
+		}
+	}
+}
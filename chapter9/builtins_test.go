@@ -0,0 +1,124 @@
+package interp
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuiltins(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		out  string
+		want []string
+	}{
+		{
+			name: "print",
+			src:  `struct P { int x; }; P p; print("a b"); print(1 + 1); print('c'); print(p)`,
+			out:  "a b\n2\n'c'\nP{x: 0}\n",
+		},
+		{
+			name: "len",
+			src:  `len("héllo"); len("")`,
+			want: []string{"5", "0"},
+		},
+		{
+			name: "type",
+			src:  `class N { N next; }; N n; type(1); type(2.5); type('a'); type(true); type("s"); type(n); type(n.next)`,
+			want: []string{`"int"`, `"float"`, `"char"`, `"boolean"`, `"string"`, `"N"`, `"null"`},
+		},
+		{
+			name: "assert",
+			src:  "void check(int n) { assert(n > 0); } check(1); check(0)",
+			want: []string{"error: 1:21: assertion failed\n\tin check called at 1:48"},
+		},
+		{
+			name: "checked arguments",
+			src:  `len(1); print(); void v() { } print(v())`,
+			want: []string{"error: 1:5: cannot use 1 (int) as string value in argument to len\n" +
+				"1:9: not enough arguments in call to print\n" +
+				"1:37: v() (no value) used as value"},
+		},
+		{
+			name: "redeclared",
+			src:  "int len(string s) { return 0; }",
+			want: []string{"error: 1:5: len redeclared in this scope"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			in := New()
+			var out strings.Builder
+			in.Stdout = &out
+			var got []string
+			values, err := in.Run(tc.src)
+			for _, v := range values {
+				got = append(got, v.String())
+			}
+			if err != nil {
+				got = append(got, "error: "+err.Error())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+			if out.String() != tc.out {
+				t.Errorf("got output %q, want %q", out.String(), tc.out)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := New()
+	errNegative := errors.New("negative")
+	funcs := map[string]any{
+		"sqrt": func(x float64) (float64, error) {
+			if x < 0 {
+				return 0, errNegative
+			}
+			return math.Sqrt(x), nil
+		},
+		"upper": func(c rune) rune { return c - 'a' + 'A' },
+		"even":  func(n int) bool { return n%2 == 0 },
+	}
+	for name, fn := range funcs {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, err := in.Run("sqrt(4); upper('q'); even('b'); sqrt(-1.0)")
+	var got []string
+	for _, v := range values {
+		got = append(got, v.String())
+	}
+	if diff := cmp.Diff([]string{"2.0", "'Q'", "true"}, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+	if !errors.Is(err, errNegative) || err.Error() != "1:33: negative" {
+		t.Errorf("got %v, want 1:33: negative", err)
+	}
+
+	invalid := []struct {
+		name string
+		fn   any
+		err  string
+	}{
+		{"print", func() {}, "builtin print: print is already defined"},
+		{"f", 1, "builtin f: int is not a function"},
+		{"f", func(b []byte) {}, "builtin f: unsupported parameter type []uint8"},
+		{"f", func() any { return nil }, "builtin f: unsupported result type interface {}"},
+		{"f", func() (int, int) { return 0, 0 }, "builtin f: too many results"},
+		{"f", func(s ...string) {}, "builtin f: variadic functions aren't supported"},
+	}
+	for _, tc := range invalid {
+		if err := in.Register(tc.name, tc.fn); err == nil || err.Error() != tc.err {
+			t.Errorf("Register(%q, %T) = %v, want %s", tc.name, tc.fn, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/interp"
+)
+
+// example calls a recursive function, to step in and out of its calls
+const example = `int fact(int n) {
+    if (n < 2) return 1;
+    int r = n * fact(n - 1);
+    return r;
+}
+int x = 3;
+fact(x)
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+// run runs the Cymbol program given as arguments, or the example, pausing at
+// its first statement. Each time it pauses the statement about to run is
+// printed and the commands are read from in, until one goes on:
+//
+//	step, s          go to the next statement, into the calls it makes
+//	next, n          go to the next statement, over the calls it makes
+//	finish, f        go on until the current call returns
+//	continue, c      go on until a breakpoint
+//	break, b L|F     pause at the statements on line L, or in calls of F
+//	clear L|F        remove a breakpoint
+//	stack, bt        print the calls running
+//	locals, l        print the variables of the current call
+//	globals, g       print the global variables
+//
+// The values of the program's expression statements are printed once it
+// ends. If in ends the program runs to its end without pausing again.
+func run(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src := example
+	if fs.NArg() > 0 {
+		src = strings.Join(fs.Args(), " ")
+	}
+
+	it := interp.New()
+	it.Stdout = out
+	c := &console{it: it, lines: strings.Split(src, "\n"), in: bufio.NewScanner(in), out: out}
+	it.Debug(c, interp.Step)
+	values, err := it.Run(src)
+	for _, v := range values {
+		fmt.Fprintln(out, v)
+	}
+	return cymbol.WithSource(src, err)
+}
+
+// console is the debugger reading its commands from a reader.
+type console struct {
+	it    *interp.Interpreter
+	lines []string // the lines of the source, to print the one paused at
+	in    *bufio.Scanner
+	out   io.Writer
+	done  bool // in ended
+}
+
+func (c *console) Paused(p *interp.Pause) interp.Command {
+	pos := p.Stmt.Pos()
+	if p.Breakpoint != nil {
+		fmt.Fprintf(c.out, "breakpoint %s\n", breakpoint(*p.Breakpoint))
+	}
+	fmt.Fprintf(c.out, "%v: %s\n", pos, strings.TrimSpace(c.lines[pos.Line-1]))
+	for !c.done {
+		fmt.Fprint(c.out, "(debug) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			c.done = true
+			break
+		}
+		fields := strings.Fields(c.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch cmd := fields[0]; cmd {
+		case "step", "s":
+			return interp.Step
+		case "next", "n":
+			return interp.Next
+		case "finish", "f":
+			return interp.Finish
+		case "continue", "c":
+			return interp.Continue
+		case "break", "b", "clear":
+			if len(fields) != 2 {
+				fmt.Fprintf(c.out, "usage: %s line|function\n", cmd)
+				continue
+			}
+			b := parseBreakpoint(fields[1])
+			if cmd == "clear" {
+				if !c.it.Clear(b) {
+					fmt.Fprintf(c.out, "no breakpoint %s\n", breakpoint(b))
+				}
+				continue
+			}
+			c.it.Break(b)
+		case "stack", "bt":
+			for _, call := range p.Stack {
+				fmt.Fprintf(c.out, "in %s called at %v\n", call.Func, call.Pos)
+			}
+		case "locals", "l":
+			fmt.Fprintln(c.out, p.Frames[0])
+		case "globals", "g":
+			fmt.Fprintln(c.out, p.Globals)
+		default:
+			fmt.Fprintf(c.out, "unknown command %q\n", cmd)
+		}
+	}
+	// no more commands, run to the end
+	c.it.Debug(nil, interp.Continue)
+	return interp.Continue
+}
+
+// parseBreakpoint returns the breakpoint s names, a line number or a
+// function.
+func parseBreakpoint(s string) interp.Breakpoint {
+	if line, err := strconv.Atoi(s); err == nil {
+		return interp.Breakpoint{Line: line}
+	}
+	return interp.Breakpoint{Func: s}
+}
+
+func breakpoint(b interp.Breakpoint) string {
+	if b.Func != "" {
+		return b.Func
+	}
+	return "line " + strconv.Itoa(b.Line)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	commands := `b fact
+c
+locals
+globals
+b 4
+clear fact
+c
+stack
+hop
+clear 5
+finish
+next
+`
+	var s strings.Builder
+	if err := run(nil, strings.NewReader(commands), &s); err != nil {
+		t.Fatal(err)
+	}
+	want := `6:1: int x = 3;
+(debug) (debug) breakpoint fact
+2:5: if (n < 2) return 1;
+(debug) fact: n=3
+(debug) global: x=3
+(debug) (debug) (debug) breakpoint line 4
+4:5: return r;
+(debug) in fact called at 3:17
+in fact called at 7:1
+(debug) unknown command "hop"
+(debug) no breakpoint line 5
+(debug) breakpoint line 4
+4:5: return r;
+(debug) 6
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	err := run([]string{"1 / 0"}, strings.NewReader("c\n"), &s)
+	if want := "1:3: division by zero"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+}
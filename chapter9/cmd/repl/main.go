@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/interp"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+// run reads Cymbol declarations and statements from in, runs them as soon as
+// they're complete and prints the value of each expression:
+//
+//	> int sq(int n) {
+//	.     return n * n;
+//	. }
+//	> int x = 3;
+//	> sq(x) + 1
+//	10
+//
+// The prompt is "> " for a new input, and ". " while a declaration or a
+// statement isn't complete, until the lines read so far parse. An expression
+// at the end of the input can go without its semicolon. The errors in an
+// input are printed, and the input is forgotten: anything it declares can be
+// entered again. Every input sees what the ones before declared, and the
+// values they gave the globals.
+//
+// With -trace each node is printed as it runs, see interp.Interpreter's
+// Trace. With -profile the functions and nodes that took the most time are
+// printed at the end, see interp.Profile.
+func run(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	trace := fs.Bool("trace", false, "print the nodes as they run")
+	profile := fs.Int("profile", 0, "print the `n` functions and nodes taking the most time at the end")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	it := interp.New()
+	it.Stdout = out
+	if *trace {
+		it.Trace = out
+	}
+	if *profile > 0 {
+		it.Profile = interp.NewProfile()
+	}
+	lines := bufio.NewScanner(in)
+	var src strings.Builder
+	for {
+		prompt := "> "
+		if src.Len() > 0 {
+			prompt = ". "
+		}
+		fmt.Fprint(out, prompt)
+		if !lines.Scan() {
+			break
+		}
+		src.WriteString(lines.Text() + "\n")
+		values, err := it.Run(src.String())
+		if cymbol.Incomplete(err) {
+			continue
+		}
+		src.Reset()
+		for _, v := range values {
+			fmt.Fprintln(out, v)
+		}
+		if err != nil {
+			fmt.Fprintln(out, err)
+		}
+	}
+	fmt.Fprintln(out)
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	if it.Profile != nil {
+		if err := it.Profile.Write(out, *profile); err != nil {
+			return err
+		}
+	}
+	if src.Len() > 0 {
+		// the input ended in the middle of a declaration or statement
+		_, err := it.Run(src.String())
+		return cymbol.WithSource(src.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	input := `int sq(int n) {
+    return n * n;
+}
+int x = 3;
+sq(x) + 1
+x = y;
+x = 4; sq(
+x)
+"done"
+`
+	var s strings.Builder
+	if err := run(nil, strings.NewReader(input), &s); err != nil {
+		t.Fatal(err)
+	}
+	want := `> . . > > 10
+> 1:5: undefined: y
+> . 16
+> "done"
+> 
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	err := run(nil, strings.NewReader("int f() {\n"), &s)
+	if want := "2:1: syntax error: expecting RBrace, found EOF"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+
+	s.Reset()
+	if err := run([]string{"-trace"}, strings.NewReader("int x = 1;\nx + 1\n"), &s); err != nil {
+		t.Fatal(err)
+	}
+	want = `> 1:1 int x = 1;
+  1:9 1 => 1
+>   1:1 x => 1
+  1:5 1 => 1
+1:1 (x + 1) => 2
+2
+> 
+`
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	s.Reset()
+	if err := run([]string{"-profile", "1"}, strings.NewReader("1 + 1\n"), &s); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.String(); !strings.Contains(got, "count  function\n") || !strings.Contains(got, "1  1:1 (1 + 1)\n") {
+		t.Errorf("got profile %q", got)
+	}
+
+	err = run([]string{"x"}, strings.NewReader(""), &s)
+	if want := "unexpected arguments: x"; err == nil || err.Error() != want {
+		t.Errorf("want: %s, got: %v", want, err)
+	}
+}
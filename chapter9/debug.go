@@ -0,0 +1,151 @@
+package interp
+
+import (
+	"slices"
+
+	"example.com/cymbol"
+)
+
+// Debugging
+//
+// A debugger attached to the interpreter is told each time it pauses,
+// before running a statement, and answers how to go on:
+//
+//	Step      pause at the next statement, in a function it calls too
+//	Next      pause at the next statement of this call or of its caller
+//	Finish    pause when this call returns, at the next statement of the caller
+//	Continue  pause at the next breakpoint
+//
+// A breakpoint is a line, to pause at each statement starting on it, or the
+// name of a function, to pause at the first statement of each of its calls.
+// While paused the debugger sees the statement about to run, the memory
+// spaces of the calls running and of the globals, and the call stack, and
+// can add and clear breakpoints. The Debugger is an interface, so a command
+// line debugger and a test can both drive it, see cmd/debug.
+
+// Debugger decides where a program paused goes on to.
+type Debugger interface {
+	Paused(p *Pause) Command
+}
+
+// Command tells the interpreter where to pause next.
+type Command int
+
+const (
+	Step Command = iota
+	Next
+	Finish
+	Continue
+)
+
+// Breakpoint is a place to pause at, a line or a function.
+type Breakpoint struct {
+	Line int    // the line of the statements to pause at, 0 if none
+	Func string // the function to pause in, Class.method for a method
+}
+
+// Pause is where a program is paused.
+type Pause struct {
+	Stmt       cymbol.Stmt      // the statement about to run
+	Breakpoint *Breakpoint      // the breakpoint paused at, nil if none
+	Frames     []*FunctionSpace // the memory spaces of the calls, innermost first
+	Stack      []Call           // the calls, innermost first
+	Globals    *MemorySpace
+}
+
+// debugger is the state of a debugging session.
+type debugger struct {
+	Debugger
+	breakpoints []Breakpoint
+	command     Command
+	depth       int  // the depth of the stack when the command was given
+	entered     bool // a function was called, its first statement is next
+}
+
+// Debug attaches d to the interpreter, which pauses at its breakpoints, and
+// starts the programs it runs with c: Step to pause at their first statement,
+// Continue to run them until a breakpoint. A nil d detaches the debugger.
+func (in *Interpreter) Debug(d Debugger, c Command) {
+	if d == nil {
+		in.debugger = nil
+		return
+	}
+	in.debugger = &debugger{Debugger: d, command: c}
+}
+
+// Break adds the breakpoint b.
+func (in *Interpreter) Break(b Breakpoint) {
+	if in.debugger != nil && !slices.Contains(in.debugger.breakpoints, b) {
+		in.debugger.breakpoints = append(in.debugger.breakpoints, b)
+	}
+}
+
+// Clear removes the breakpoint b, it's false if there's no such breakpoint.
+func (in *Interpreter) Clear(b Breakpoint) bool {
+	if in.debugger == nil {
+		return false
+	}
+	i := slices.Index(in.debugger.breakpoints, b)
+	if i < 0 {
+		return false
+	}
+	in.debugger.breakpoints = slices.Delete(in.debugger.breakpoints, i, i+1)
+	return true
+}
+
+// Breakpoints returns the breakpoints, in the order they were added.
+func (in *Interpreter) Breakpoints() []Breakpoint {
+	if in.debugger == nil {
+		return nil
+	}
+	return slices.Clone(in.debugger.breakpoints)
+}
+
+// pause tells the debugger the statement s is about to run if the program
+// pauses there, and waits for its command.
+func (in *Interpreter) pause(s cymbol.Stmt) {
+	d := in.debugger
+	switch s.(type) {
+	case *cymbol.Block, *cymbol.StructDecl:
+		// nothing runs by itself
+		return
+	}
+	entered := d.entered
+	d.entered = false
+
+	depth := len(in.stack)
+	var stop bool
+	switch d.command {
+	case Step:
+		stop = true
+	case Next:
+		stop = depth <= d.depth
+	case Finish:
+		stop = depth < d.depth
+	}
+	b := in.breakpoint(s, entered)
+	if !stop && b == nil {
+		return
+	}
+
+	frames := slices.Clone(in.stack)
+	slices.Reverse(frames)
+	p := &Pause{Stmt: s, Breakpoint: b, Frames: frames, Stack: in.calls(), Globals: in.globals}
+	d.command = d.Paused(p)
+	d.depth = depth
+}
+
+// breakpoint returns the breakpoint at the statement s, nil if there's none.
+// entered tells whether s is the first statement of a call.
+func (in *Interpreter) breakpoint(s cymbol.Stmt, entered bool) *Breakpoint {
+	for _, b := range in.debugger.breakpoints {
+		switch {
+		case b.Line != 0 && b.Line == s.Pos().Line:
+		case b.Func != "" && entered && b.Func == funcName(in.stack[len(in.stack)-1].Func):
+		default:
+			continue
+		}
+		return &b
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// script is a Debugger giving its commands in order, and continuing once it
+// runs out of them. It records where the program paused.
+type script struct {
+	commands []Command
+	pauses   []string
+}
+
+func (s *script) Paused(p *Pause) Command {
+	var calls []string
+	for _, c := range p.Stack {
+		calls = append(calls, c.Func)
+	}
+	pause := fmt.Sprintf("%v [%s] %v", p.Stmt.Pos(), strings.Join(calls, " "), p.Frames[0])
+	if p.Breakpoint != nil {
+		pause += fmt.Sprintf(" (break %+v)", *p.Breakpoint)
+	}
+	s.pauses = append(s.pauses, pause)
+	if len(s.commands) == 0 {
+		return Continue
+	}
+	c := s.commands[0]
+	s.commands = s.commands[1:]
+	return c
+}
+
+const debugged = `int sq(int n) {
+    int r = n * n;
+    return r;
+}
+int x = 2;
+x = sq(x);
+x = sq(x) + 1;
+x`
+
+func TestDebug(t *testing.T) {
+	cases := []struct {
+		name        string
+		start       Command
+		commands    []Command
+		breakpoints []Breakpoint
+		want        []string
+	}{
+		{
+			name:     "step",
+			start:    Step,
+			commands: []Command{Step, Step, Step, Step, Continue},
+			want: []string{
+				"5:1 [] input:",
+				"6:1 [] input:",
+				"2:5 [sq] sq: n=2",
+				"3:5 [sq] sq: n=2, r=4",
+				"7:1 [] input:",
+			},
+		},
+		{
+			name:     "next",
+			start:    Step,
+			commands: []Command{Next, Next, Next, Next},
+			want: []string{
+				"5:1 [] input:",
+				"6:1 [] input:",
+				"7:1 [] input:",
+				"8:1 [] input:",
+			},
+		},
+		{
+			name:        "finish",
+			start:       Continue,
+			commands:    []Command{Finish, Continue},
+			breakpoints: []Breakpoint{{Line: 2}},
+			want: []string{
+				"2:5 [sq] sq: n=2 (break {Line:2 Func:})",
+				"7:1 [] input:",
+				"2:5 [sq] sq: n=4 (break {Line:2 Func:})",
+			},
+		},
+		{
+			name:        "function breakpoint",
+			start:       Continue,
+			commands:    []Command{Next, Continue},
+			breakpoints: []Breakpoint{{Func: "sq"}},
+			want: []string{
+				"2:5 [sq] sq: n=2 (break {Line:0 Func:sq})",
+				"3:5 [sq] sq: n=2, r=4",
+				"2:5 [sq] sq: n=4 (break {Line:0 Func:sq})",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			in := New()
+			s := &script{commands: tc.commands}
+			in.Debug(s, tc.start)
+			for _, b := range tc.breakpoints {
+				in.Break(b)
+			}
+			values, err := in.Run(debugged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := values[0].String(); got != "17" {
+				t.Errorf("got %s, want 17", got)
+			}
+			if diff := cmp.Diff(tc.want, s.pauses); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	in := New()
+	in.Debug(&script{}, Continue)
+	in.Break(Breakpoint{Line: 3})
+	in.Break(Breakpoint{Func: "f"})
+	in.Break(Breakpoint{Line: 3})
+	if !in.Clear(Breakpoint{Line: 3}) {
+		t.Error("Clear(line 3) found no breakpoint")
+	}
+	if in.Clear(Breakpoint{Line: 4}) {
+		t.Error("Clear(line 4) found a breakpoint")
+	}
+	if diff := cmp.Diff([]Breakpoint{{Func: "f"}}, in.Breakpoints()); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
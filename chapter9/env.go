@@ -0,0 +1,96 @@
+package interp
+
+import (
+	"fmt"
+	"reflect"
+
+	"example.com/symtab"
+)
+
+// Embedding
+//
+// A Go program can use Cymbol as its scripting or expression language: it
+// sets the variables a script sees in an Env, evaluates the script there and
+// reads back the value of its last expression, or the variables it set:
+//
+//	env := interp.NewEnv()
+//	env.Set("price", 12.5)
+//	env.Set("quantity", 3)
+//	total, err := interp.Eval("price * quantity", env)   // 37.5
+//
+// An Env is an Interpreter, so what a script declares stays for the next
+// ones evaluated in the same Env, and the program can register builtins in
+// it before evaluating anything.
+
+// Env holds the variables, functions and types scripts see.
+type Env struct {
+	*Interpreter
+}
+
+func NewEnv() *Env {
+	return &Env{Interpreter: New()}
+}
+
+// Eval runs src in env, as Run does, and returns the value of its last
+// expression statement, nil if it has none. A nil env is a new one.
+func Eval(src string, env *Env) (Value, error) {
+	if env == nil {
+		env = NewEnv()
+	}
+	values, err := env.Run(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values[len(values)-1], nil
+}
+
+// Get returns the value of the global variable name, false if there's no
+// such variable.
+func (e *Env) Get(name string) (Value, bool) {
+	v, ok := e.table.Globals.Resolve(name).(*symtab.VariableSymbol)
+	if !ok {
+		return nil, false
+	}
+	return e.load(e.globals, v), true
+}
+
+// Set sets the global variable name to value, declaring it with the type of
+// value if it isn't declared yet. value is a Value or a Go value of the types
+// a builtin takes, such as an int. A variable declared already keeps its
+// type, value must be assignable to it.
+func (e *Env) Set(name string, value any) error {
+	if r := reflect.ValueOf(value); r.IsValid() {
+		if _, ok := goTypes[r.Type()]; ok {
+			value = fromGo(r)
+		}
+	}
+	v, ok := value.(Value)
+	if !ok {
+		return fmt.Errorf("cannot set %s to %v (%T)", name, value, value)
+	}
+	t, ok := e.table.Globals.Resolve(v.Type()).(symtab.Type)
+	if !ok {
+		return fmt.Errorf("cannot set %s to %v", name, v)
+	}
+
+	switch sym := e.table.Globals.Resolve(name).(type) {
+	case nil:
+		variable := symtab.NewVariableSymbol(name, t)
+		e.table.Globals.Define(variable)
+		e.globals.Put(variable, v)
+		return nil
+	case *symtab.VariableSymbol:
+		to := sym.Type().Name()
+		converted, err := Convert(v, to)
+		if err != nil {
+			return fmt.Errorf("cannot set %s (%s) to %v (%s)", name, to, v, v.Type())
+		}
+		e.globals.Put(sym, converted)
+		return nil
+	default:
+		return fmt.Errorf("cannot set %s, it's not a variable", name)
+	}
+}
@@ -0,0 +1,81 @@
+package interp
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEval(t *testing.T) {
+	env := NewEnv()
+	for name, v := range map[string]any{"price": 12.5, "quantity": 3, "unit": 'u', "name": "pen"} {
+		if err := env.Set(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		src  string
+		want Value
+	}{
+		{"price * quantity", Float(37.5)},
+		{`name + "s"`, String("pens")},
+		{"int total = quantity * 2;", nil},
+		{"total; unit", Char('u')},
+		{"int twice(int n) { return 2 * n; } twice(total)", Int(12)},
+	}
+	for _, tc := range cases {
+		got, err := Eval(tc.src, env)
+		if err != nil {
+			t.Fatalf("Eval(%q): %v", tc.src, err)
+		}
+		if got != tc.want {
+			t.Errorf("Eval(%q) = %v, want %v", tc.src, got, tc.want)
+		}
+	}
+
+	if got, ok := env.Get("total"); !ok || got != Int(6) {
+		t.Errorf("Get(total) = %v, %v, want 6", got, ok)
+	}
+	if _, ok := env.Get("twice"); ok {
+		t.Error("Get(twice) found a function")
+	}
+	if err := env.Set("price", 10); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := env.Get("price"); got != Float(10) {
+		t.Errorf("Get(price) = %v after setting it to 10, want 10.0", got)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	if _, err := Eval("1 / 0", nil); err == nil || err.Error() != "1:3: division by zero" {
+		t.Errorf("got %v, want 1:3: division by zero", err)
+	}
+
+	env := NewEnv()
+	if err := env.Set("n", 1); err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	for _, set := range []struct {
+		name  string
+		value any
+	}{
+		{"n", "one"},
+		{"len", 1},
+		{"x", []int{1}},
+	} {
+		if err := env.Set(set.name, set.value); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	want := []string{
+		`cannot set n (int) to "one" (string)`,
+		"cannot set len, it's not a variable",
+		"cannot set x to [1] ([]int)",
+	}
+	if diff := cmp.Diff(want, errs); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
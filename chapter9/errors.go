@@ -0,0 +1,89 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Runtime errors
+//
+// Checking the types of a program before it runs rules out most errors, but
+// not dividing by zero or using a field of a null object. Those stop the
+// program with a RuntimeError, which tells where in the source it stopped and
+// the calls that led there, the innermost first, the way Go prints the stack
+// of a panic:
+//
+//	2:25: division by zero
+//		in half called at 3:27
+//		in twice called at 4:1
+//
+// The stack is the one of the interpreter: the memory spaces of the calls
+// running, each knowing the call that pushed it.
+
+// RuntimeError is an error that stopped a program running.
+type RuntimeError struct {
+	Span  cymbol.Span // the code that failed
+	Err   error
+	Stack []Call // the calls running when it failed, the innermost first
+}
+
+// Call is a call of a function or method being run.
+type Call struct {
+	Func string     // the name of the function, Class.method for a method
+	Pos  cymbol.Pos // where it's called
+}
+
+func (e *RuntimeError) Error() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%v: %v", e.Span.From, e.Err)
+	for _, c := range e.Stack {
+		fmt.Fprintf(&s, "\n\tin %s called at %v", c.Func, c.Pos)
+	}
+	return s.String()
+}
+
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+// ErrorSpan returns the span of the code that failed, for cymbol.Render.
+func (e *RuntimeError) ErrorSpan() cymbol.Span { return e.Span }
+
+// fail stops the input being run with a runtime error at span.
+func (in *Interpreter) fail(span cymbol.Span, format string, args ...any) {
+	panic(&RuntimeError{Span: span, Err: fmt.Errorf(format, args...), Stack: in.calls()})
+}
+
+// calls returns the calls on the stack, the innermost first. The frame of the
+// input itself isn't a call.
+func (in *Interpreter) calls() []Call {
+	var calls []Call
+	for i := len(in.stack) - 1; i >= 0; i-- {
+		frame := in.stack[i]
+		if frame.Func == nil {
+			continue
+		}
+		calls = append(calls, Call{Func: funcName(frame.Func), Pos: frame.Call.Pos()})
+	}
+	return calls
+}
+
+func funcName(f *symtab.FunctionSymbol) string {
+	switch s := f.Scope().(type) {
+	case *symtab.ClassSymbol:
+		return s.Name() + "." + f.Name()
+	case *symtab.NamespaceSymbol:
+		return s.Name() + "." + f.Name()
+	}
+	return f.Name()
+}
+
+// spanOf returns the part of the source n takes, as far as a node without an
+// end tells: the whole name for an identifier, its position otherwise.
+func spanOf(n cymbol.Node) cymbol.Span {
+	if id, ok := n.(*cymbol.Ident); ok {
+		return id.Token.Span()
+	}
+	return cymbol.Span{From: n.Pos(), To: n.Pos()}
+}
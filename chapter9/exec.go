@@ -0,0 +1,286 @@
+package interp
+
+import (
+	"time"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// exec runs the statement s. It returns true if s ran a return statement,
+// with the value returned.
+func (in *Interpreter) exec(s cymbol.Stmt) (ret Value, returned bool) {
+	in.step(s)
+	if in.debugger != nil {
+		in.pause(s)
+	}
+	if _, block := s.(*cymbol.Block); !block && in.observing() {
+		start := in.enter(s)
+		ret, returned = in.execStmt(s)
+		in.leave(s, nil, start)
+		return ret, returned
+	}
+	return in.execStmt(s)
+}
+
+func (in *Interpreter) execStmt(s cymbol.Stmt) (ret Value, returned bool) {
+	switch s := s.(type) {
+	case *cymbol.Block:
+		for _, s := range s.Stmts {
+			if ret, returned := in.exec(s); returned {
+				return ret, true
+			}
+		}
+	case *cymbol.VarDecl:
+		v := in.types.Defs[s.Name]
+		space := in.space(v)
+		var value Value
+		if s.Value != nil {
+			value = in.eval(s.Value)
+		} else {
+			value = zero(v.Type(), nil)
+			in.allocate(s, size(value))
+		}
+		if _, ok := space.Get(v); !ok {
+			in.allocate(s, 1)
+		}
+		space.Put(v, value)
+	case *cymbol.StructDecl:
+		// a type, nothing runs
+	case *cymbol.IfStmt:
+		if in.eval(s.Cond).(Bool) {
+			return in.exec(s.Then)
+		}
+		if s.Else != nil {
+			return in.exec(s.Else)
+		}
+	case *cymbol.WhileStmt:
+		for in.eval(s.Cond).(Bool) {
+			if ret, returned := in.exec(s.Body); returned {
+				return ret, true
+			}
+		}
+	case *cymbol.ReturnStmt:
+		if s.Value != nil {
+			return in.eval(s.Value), true
+		}
+		return nil, true
+	case *cymbol.AssignStmt:
+		in.assign(s.Target, s.Value)
+	case *cymbol.ExprStmt:
+		in.eval(s.X)
+	}
+	return nil, false
+}
+
+// assign evaluates value and stores it in the variable or field target is.
+func (in *Interpreter) assign(target, value cymbol.Expr) {
+	v := in.types.Refs[target]
+	switch target := target.(type) {
+	case *cymbol.Ident:
+		in.space(v).Put(v, in.eval(value))
+	case *cymbol.MemberExpr:
+		if _, ok := in.types.Refs[target.X].(*symtab.NamespaceSymbol); ok {
+			in.globals.Put(v, in.eval(value))
+			return
+		}
+		o := in.object(target.X)
+		o.Put(v, in.eval(value))
+	default:
+		in.fail(spanOf(target), "cannot assign to %v", target)
+	}
+}
+
+// space returns the memory space the value of the variable v is in.
+func (in *Interpreter) space(v symtab.Symbol) *MemorySpace {
+	frame := in.stack[len(in.stack)-1]
+	switch v.Scope().(type) {
+	case *symtab.GlobalScope, *symtab.NamespaceSymbol:
+		return in.globals
+	case *symtab.ClassSymbol:
+		// a field used by its name in a method
+		return frame.This.MemorySpace
+	}
+	return frame.MemorySpace
+}
+
+// eval evaluates x, converting its value to the type it's promoted to if
+// it is.
+func (in *Interpreter) eval(x cymbol.Expr) Value {
+	in.step(x)
+	var start time.Time
+	if in.observing() {
+		start = in.enter(x)
+	}
+	v := in.value(x)
+	if to, ok := in.types.Promotions.Lookup(x); ok {
+		var err error
+		if v, err = Convert(v, to.Name()); err != nil {
+			in.fail(spanOf(x), "%v", err)
+		}
+	}
+	if in.observing() {
+		in.leave(x, v, start)
+	}
+	return v
+}
+
+func (in *Interpreter) value(x cymbol.Expr) Value {
+	switch x := x.(type) {
+	case *cymbol.IntLit:
+		return Int(x.Value)
+	case *cymbol.FloatLit:
+		return Float(x.Value)
+	case *cymbol.CharLit:
+		return Char(x.Value)
+	case *cymbol.StringLit:
+		return String(x.Value)
+	case *cymbol.BoolLit:
+		return Bool(x.Value)
+	case *cymbol.Ident:
+		v, ok := in.types.Refs[x].(*symtab.VariableSymbol)
+		if !ok {
+			in.fail(spanOf(x), "%s is not a variable", x.Name)
+		}
+		return in.load(in.space(v), v)
+	case *cymbol.ThisExpr, *cymbol.SuperExpr:
+		return in.stack[len(in.stack)-1].This
+	case *cymbol.MemberExpr:
+		v, ok := in.types.Refs[x].(*symtab.VariableSymbol)
+		if !ok {
+			in.fail(spanOf(x.Member), "%v is not a field", x)
+		}
+		if _, ok := in.types.Refs[x.X].(*symtab.NamespaceSymbol); ok {
+			return in.load(in.globals, v)
+		}
+		return in.load(in.object(x.X).MemorySpace, v)
+	case *cymbol.UnaryExpr:
+		return in.unary(x)
+	case *cymbol.BinaryExpr:
+		return in.binary(x)
+	case *cymbol.CallExpr:
+		return in.call(x)
+	}
+	in.fail(spanOf(x), "cannot evaluate %v", x)
+	return nil
+}
+
+// load returns the value of the variable v in the memory space m, its zero
+// value if it has none yet: a global whose initializer stopped with an error
+// has none.
+func (in *Interpreter) load(m *MemorySpace, v symtab.Symbol) Value {
+	value, ok := m.Get(v)
+	if !ok {
+		value = zero(v.Type(), nil)
+		m.Put(v, value)
+		in.cells += 1 + size(value)
+	}
+	return value
+}
+
+// object evaluates x, the struct or object of a member access or method call.
+func (in *Interpreter) object(x cymbol.Expr) *StructInstance {
+	o, _ := in.eval(x).(*StructInstance)
+	if o == nil {
+		in.fail(spanOf(x), "%v is null", x)
+	}
+	return o
+}
+
+func (in *Interpreter) unary(x *cymbol.UnaryExpr) Value {
+	v, err := Unary(x.Op, in.eval(x.X))
+	if err != nil {
+		in.fail(x.Op.Span(), "%v", err)
+	}
+	return v
+}
+
+func (in *Interpreter) binary(x *cymbol.BinaryExpr) Value {
+	l, r := in.eval(x.X), in.eval(x.Y)
+	if x.Op.Type == cymbol.Plus && (isString(l) || isString(r)) {
+		// the string made is counted before it is, see limits.go
+		in.allocate(x, stringCells(textLen(l)+textLen(r)))
+	}
+	v, err := Binary(x.Op, l, r)
+	if err != nil {
+		in.fail(x.Op.Span(), "%v", err)
+	}
+	return v
+}
+
+// call calls the function or method x names, with the values of its
+// arguments.
+func (in *Interpreter) call(x *cymbol.CallExpr) Value {
+	f, ok := in.types.Refs[x.Fun].(*symtab.FunctionSymbol)
+	if !ok {
+		in.fail(spanOf(x.Fun), "cannot call %v", x.Fun)
+	}
+	var this *StructInstance
+	if _, ok := f.Scope().(*symtab.ClassSymbol); ok {
+		var super bool
+		this, super = in.receiver(x.Fun)
+		if !super {
+			// the method of the object's class, which may override f
+			f = this.Struct.(*symtab.ClassSymbol).ResolveMember(f.Name()).(*symtab.FunctionSymbol)
+		}
+	}
+	args := make([]Value, len(x.Args))
+	for i, arg := range x.Args {
+		args[i] = in.eval(arg)
+	}
+	if in.Profile != nil {
+		start := in.Profile.now()
+		v := in.invoke(x, f, this, args)
+		record(in.Profile, in.Profile.Funcs, funcName(f), start)
+		return v
+	}
+	return in.invoke(x, f, this, args)
+}
+
+// receiver returns the object the method fun names is called on, and
+// whether it's called through super.
+func (in *Interpreter) receiver(fun cymbol.Expr) (this *StructInstance, super bool) {
+	if m, ok := fun.(*cymbol.MemberExpr); ok {
+		_, super = m.X.(*cymbol.SuperExpr)
+		return in.object(m.X), super
+	}
+	// a method of the class called by its name in another one
+	return in.stack[len(in.stack)-1].This, false
+}
+
+// invoke runs the body of f in a new memory space, with its parameters set to
+// args, and returns the value it returns. this is the object of a method.
+func (in *Interpreter) invoke(x *cymbol.CallExpr, f *symtab.FunctionSymbol, this *StructInstance, args []Value) Value {
+	if fn, ok := in.builtins[f]; ok {
+		return in.callBuiltin(x, fn, args)
+	}
+	if max := in.Limits.Depth; max > 0 && len(in.stack) > max {
+		in.fail(spanOf(x), "%w", ErrDepth)
+	}
+	decl := in.funcs[f]
+	space := &FunctionSpace{MemorySpace: NewMemorySpace(f.Name()), Func: f, Call: x, This: this}
+	for i, p := range decl.Params {
+		space.Put(in.types.Defs[p.Name], args[i])
+	}
+	in.allocate(x, len(args))
+	in.stack = append(in.stack, space)
+	if in.debugger != nil {
+		in.debugger.entered = true
+	}
+	ret, returned := in.exec(decl.Body)
+	if in.debugger != nil {
+		in.debugger.entered = false
+	}
+	if !returned && f.Type().Name() != "void" {
+		rbrace := decl.Body.Rbrace
+		in.fail(cymbol.Span{From: rbrace, To: rbrace}, "missing return at the end of %s", f.Name())
+	}
+	in.pop()
+	return ret
+}
+
+// pop returns from the innermost call, freeing the cells of its variables.
+func (in *Interpreter) pop() {
+	in.cells -= len(in.stack[len(in.stack)-1].order)
+	in.stack = in.stack[:len(in.stack)-1]
+}
@@ -0,0 +1,16 @@
+module example.com/interp
+
+go 1.23.4
+
+require (
+	example.com/cymbol v0.0.0
+	example.com/symtab v0.0.0
+	example.com/types v0.0.0
+	github.com/google/go-cmp v0.6.0
+)
+
+replace (
+	example.com/cymbol => ../cymbol
+	example.com/symtab => ../chapter6
+	example.com/types => ../chapter8
+)
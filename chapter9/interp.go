@@ -0,0 +1,237 @@
+// Package interp runs Cymbol programs by walking their trees, the pattern of
+// the book's chapter 9. The code is checked by package types before it runs,
+// which resolves every name to its symbol and finds where values are
+// promoted, so the interpreter never looks anything up by name.
+package interp
+
+import (
+	"context"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"example.com/types"
+)
+
+// Pattern 25:
+// Tree-Based Interpreter
+
+// A tree-based interpreter runs a program straight from its tree: executing
+// a statement or evaluating an expression switches on the kind of node, and
+// executes or evaluates its children as it needs them. The value of a name is
+// in a memory space, see memory.go, picked by the scope of its symbol: the
+// global space for a global variable or one of a namespace, the space of the
+// function call running for a parameter or local, and the object the method
+// runs on for a field:
+//
+//	int x = 1;                        global: x=1
+//	int f(int n) { return n + x; }
+//	f(2)                              f: n=2, returns 3
+//
+// Values are the ones of value.go: Int, Float, Char, Bool, String and
+// *StructInstance for structs and classes. Instances are references,
+// assigning one or passing it to a function doesn't copy it. The promotions
+// computed by package types are done as the values are computed, so in
+// `i * 2.5` i is converted to a Float before multiplying.
+//
+// A method is looked up at run time in the class of the object it's called
+// on, which runs the method of a subclass that overrides it. The calls
+// through super are the exception, they run the superclass's method.
+//
+// The Interpreter runs the inputs of a REPL, declarations and statements in
+// any order, keeping the symbols and the values of the globals of one input
+// for the next ones. Each input is checked as a whole before anything in it
+// runs, and if it has errors it's as if it was never entered.
+
+// Interpreter runs Cymbol code, one input after the other.
+type Interpreter struct {
+	Stdout  io.Writer // where print writes, os.Stdout by default
+	Trace   io.Writer // if not nil, where the nodes run are traced
+	Profile *Profile  // if not nil, samples the nodes and calls run
+	Limits  Limits
+
+	table    *symtab.SymbolTable
+	types    *types.ComputeTypes // the symbols and types of every input so far
+	funcs    map[*symtab.FunctionSymbol]*cymbol.FuncDecl
+	builtins map[*symtab.FunctionSymbol]reflect.Value
+	globals  *MemorySpace
+	stack    []*FunctionSpace // the calls running, the innermost last
+	debugger *debugger        // nil if there's none attached
+	depth    int              // how deep the node running is, for the trace
+	ctx      context.Context  // of the input running
+	steps    int              // run by the input so far
+	cells    int              // holding values
+}
+
+func New() *Interpreter {
+	table := symtab.NewSymbolTable()
+	c := types.NewComputeTypes(table, map[cymbol.Expr]symtab.Symbol{})
+	c.Defs = map[*cymbol.Ident]symtab.Symbol{}
+	in := &Interpreter{
+		Stdout:   os.Stdout,
+		table:    table,
+		types:    c,
+		funcs:    map[*symtab.FunctionSymbol]*cymbol.FuncDecl{},
+		builtins: map[*symtab.FunctionSymbol]reflect.Value{},
+		globals:  NewMemorySpace("global"),
+	}
+	in.defineBuiltins()
+	return in
+}
+
+// Run parses src, as cymbol.ParseInput does, checks it and runs its
+// declarations and statements in order. It returns the values of the
+// expression statements, except the calls of functions returning void.
+//
+// The syntax error, or the semantic errors all together, are returned before
+// anything runs. A runtime error stops the input, after the values of the
+// statements before it.
+func (in *Interpreter) Run(src string) (values []Value, err error) {
+	return in.RunContext(context.Background(), src)
+}
+
+// RunContext runs src as Run does, stopping it with a RuntimeError once ctx
+// is done.
+func (in *Interpreter) RunContext(ctx context.Context, src string) (values []Value, err error) {
+	nodes, err := cymbol.ParseInput(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := in.check(nodes); err != nil {
+		return nil, err
+	}
+	in.declare(nodes)
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	// the calls a runtime error stopped are over
+	for len(in.stack) > 1 {
+		in.pop()
+	}
+	in.stack = []*FunctionSpace{{MemorySpace: NewMemorySpace("input")}}
+	in.depth = 0
+	in.ctx, in.steps = ctx, 0
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *cymbol.ExprStmt:
+			if in.debugger != nil {
+				in.pause(n)
+			}
+			v := in.eval(n.X)
+			if t := in.types.Types.Get(n.X); t == nil || t.Name() != "void" {
+				values = append(values, v)
+			}
+		case cymbol.Stmt:
+			in.exec(n)
+		case *cymbol.NamespaceDecl:
+			in.initialize(n)
+		}
+	}
+	return values, nil
+}
+
+// initialize runs the declarations of the variables of the namespace n, and
+// of the ones nested in it, in order.
+func (in *Interpreter) initialize(n *cymbol.NamespaceDecl) {
+	for _, d := range n.Decls {
+		switch d := d.(type) {
+		case *cymbol.VarDecl:
+			in.exec(d)
+		case *cymbol.NamespaceDecl:
+			in.initialize(d)
+		}
+	}
+}
+
+// check defines and resolves the symbols of nodes in two passes, in the
+// global scope, and checks their types. If there are errors the symbols
+// defined are undone.
+func (in *Interpreter) check(nodes []cymbol.Node) error {
+	restore := in.table.Globals.Snapshot()
+	defs := symtab.NewDefPhase(in.table)
+	for _, n := range nodes {
+		symtab.Walk(defs, n)
+	}
+	refs := symtab.NewRefPhase(defs)
+	for _, n := range nodes {
+		symtab.Walk(refs, n)
+	}
+	maps.Copy(in.types.Refs, refs.Refs)
+	maps.Copy(in.types.Defs, defs.Defs)
+
+	diags := defs.Diagnostics
+	for _, n := range nodes {
+		symtab.Walk(in.types, n)
+	}
+	check := types.NewCheckTypes(in.types, diags)
+	for _, n := range nodes {
+		symtab.Walk(check, n)
+		symtab.Walk(&topLevel{diags: diags}, n)
+	}
+	if err := diags.Err(); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
+
+// topLevel is the listener reporting the return statements outside of
+// functions, which ParseInput accepts as any other statement.
+type topLevel struct {
+	diags *cymbol.Diagnostics
+	funcs int // how many functions the walk is in
+}
+
+func (t *topLevel) Enter(n cymbol.Node) {
+	switch n := n.(type) {
+	case *cymbol.FuncDecl:
+		t.funcs++
+	case *cymbol.ReturnStmt:
+		if t.funcs == 0 {
+			t.diags.Add(cymbol.Span{From: n.Return, To: n.Return}, "return outside of a function")
+		}
+	}
+}
+
+func (t *topLevel) Exit(n cymbol.Node) {
+	if _, ok := n.(*cymbol.FuncDecl); ok {
+		t.funcs--
+	}
+}
+
+// declare keeps the declarations of the functions and methods among nodes, to
+// run them when they're called.
+func (in *Interpreter) declare(nodes []cymbol.Node) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *cymbol.FuncDecl:
+			in.declareFunc(n)
+		case *cymbol.ClassDecl:
+			for _, m := range n.Members {
+				if f, ok := m.(*cymbol.FuncDecl); ok {
+					in.declareFunc(f)
+				}
+			}
+		case *cymbol.NamespaceDecl:
+			decls := make([]cymbol.Node, len(n.Decls))
+			for i, d := range n.Decls {
+				decls[i] = d
+			}
+			in.declare(decls)
+		}
+	}
+}
+
+func (in *Interpreter) declareFunc(f *cymbol.FuncDecl) {
+	in.funcs[in.types.Defs[f.Name].(*symtab.FunctionSymbol)] = f
+}
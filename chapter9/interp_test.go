@@ -0,0 +1,210 @@
+package interp
+
+import (
+	"errors"
+	"testing"
+
+	"example.com/cymbol"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// run runs the inputs one after the other in the same interpreter, returning
+// the values of each formatted, or its error.
+func run(t *testing.T, inputs ...string) []string {
+	t.Helper()
+	in := New()
+	var got []string
+	for _, src := range inputs {
+		values, err := in.Run(src)
+		for _, v := range values {
+			got = append(got, v.String())
+		}
+		if err != nil {
+			got = append(got, "error: "+err.Error())
+		}
+	}
+	return got
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "arithmetic",
+			src:  `1 + 2 * 3; 7 / 2; 7 - 2.5; 'a' + 1; 'a' + 'b' - 'a'; -'a'; "a" + "b"`,
+			want: []string{"7", "3", "4.5", "98", "'b'", "-97", `"ab"`},
+		},
+		{
+			name: "comparisons",
+			src:  `1 < 2.5; 'a' == 97; "a" != "b"; !(1 >= 2) == true`,
+			want: []string{"true", "true", "true", "true"},
+		},
+		{
+			name: "promotions",
+			src:  "float f = 2; int i = 'a'; f; i; f * 3",
+			want: []string{"2.0", "97", "6.0"},
+		},
+		{
+			name: "zero values",
+			src:  `int i; float f; char c; boolean b; string s; i; f; c; b; s`,
+			want: []string{"0", "0.0", `'\x00'`, "false", `""`},
+		},
+		{
+			name: "recursion",
+			src:  "int fact(int n) { if (n < 2) return 1; return n * fact(n - 1); } fact(10)",
+			want: []string{"3628800"},
+		},
+		{
+			name: "functions declared after their callers",
+			src:  "int f() { return g() + 1; } int g() { return 1; } f()",
+			want: []string{"2"},
+		},
+		{
+			name: "loops and blocks",
+			src:  "int s = 0; int i = 0; while (i < 5) { int i2 = i * i; i = i + 1; s = s + i2; } s",
+			want: []string{"30"},
+		},
+		{
+			name: "locals shadowing",
+			src:  "int x = 1; int f(int x) { { int x = 3; } return x; } f(2); x",
+			want: []string{"2", "1"},
+		},
+		{
+			name: "structs",
+			src:  "struct P { int x; float y; }; P p; p.x = 1; p.y = p.x + 1; p; p.y",
+			want: []string{"P{x: 1, y: 2.0}", "2.0"},
+		},
+		{
+			name: "structs are references",
+			src:  "struct P { int x; }; void set(P p) { p.x = 7; } P p; set(p); p.x",
+			want: []string{"7"},
+		},
+		{
+			name: "methods",
+			src: `class A { int n; int get() { return n; } int twice() { return 2 * get(); } };
+class B : A { int get() { return n + 1; } int base() { return super.get(); } void set(int v) { this.n = v; } };
+B b; b.set(5); b.twice(); b.base(); b`,
+			want: []string{"12", "5", "B{n: 5}"},
+		},
+		{
+			name: "namespaces",
+			src: `namespace counter { int n = 10; int next() { n = n + 1; return n; } namespace reset { int to = 0; } }
+import counter;
+counter.next(); next(); counter.n = counter.reset.to; n`,
+			want: []string{"11", "12", "0"},
+		},
+		{
+			name: "recursive types",
+			src:  "class N { int v; N next; }; N a; N b; a.next = b; b.next = a; a",
+			want: []string{"N{v: 0, next: N{v: 0, next: ...}}"},
+		},
+		{
+			name: "void calls have no value",
+			src:  "int x; void set() { x = 1; } set(); x",
+			want: []string{"1"},
+		},
+		{
+			name: "division by zero",
+			src:  "1 + 1; 1 / 0; 2",
+			want: []string{"2", "error: 1:10: division by zero"},
+		},
+		{
+			name: "float division by zero",
+			src:  "1 / 0.0",
+			want: []string{"+Inf"},
+		},
+		{
+			name: "missing return",
+			src:  "int f(boolean b) { if (b) return 1; } f(true); f(false)",
+			want: []string{"1", "error: 1:37: missing return at the end of f\n\tin f called at 1:48"},
+		},
+		{
+			name: "error in a namespace",
+			src:  "namespace m { int inv(int n) { return 1 / n; } } m.inv(0)",
+			want: []string{"error: 1:41: division by zero\n\tin m.inv called at 1:50"},
+		},
+		{
+			name: "null field",
+			src:  "class N { N next; }; N n; n.next.next",
+			want: []string{"error: 1:27: n.next is null"},
+		},
+		{
+			name: "return outside of a function",
+			src:  "int x; if (true) return;",
+			want: []string{"error: 1:18: return outside of a function"},
+		},
+		{
+			name: "type errors",
+			src:  `int x = "a"; y`,
+			want: []string{"error: 1:9: cannot use \"a\" (string) as int value in initialization\n1:14: undefined: y"},
+		},
+		{
+			name: "syntax error",
+			src:  "int x = ;",
+			want: []string{"error: 1:9: syntax error: expecting expression, found Semicolon"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, run(t, tc.src)); diff != "" {
+				t.Errorf("(-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunKeepsState(t *testing.T) {
+	got := run(t,
+		"int x = 1;",
+		"int inc() { x = x + 1; return x; }",
+		"inc(); inc()",
+		"x",
+		"int y = z;", // undefined, y isn't defined either
+		"int y = 10; y + x",
+		"int x;", // redeclared
+		"x",
+	)
+	want := []string{
+		"2", "3",
+		"3",
+		"error: 1:9: undefined: z",
+		"13",
+		"error: 1:5: x redeclared in this scope",
+		"3",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	src := `int half(int n, int d) { return n / d; }
+class A { int twice(int d) { return 2 * half(1, d); } };
+A a;
+a.twice(0)`
+	_, err := New().Run(src)
+	var e *RuntimeError
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, want a *RuntimeError", err)
+	}
+	wantSpan := cymbol.Span{From: cymbol.Pos{Line: 1, Col: 35}, To: cymbol.Pos{Line: 1, Col: 36}}
+	if e.Span != wantSpan {
+		t.Errorf("got span %v, want %v", e.Span, wantSpan)
+	}
+	wantStack := []Call{
+		{Func: "half", Pos: cymbol.Pos{Line: 2, Col: 41}},
+		{Func: "A.twice", Pos: cymbol.Pos{Line: 4, Col: 1}},
+	}
+	if diff := cmp.Diff(wantStack, e.Stack); diff != "" {
+		t.Errorf("stack (-want +got)\n%s", diff)
+	}
+	wantMsg := "1:35: division by zero\n\tin half called at 2:41\n\tin A.twice called at 4:1"
+	if err.Error() != wantMsg {
+		t.Errorf("got %q, want %q", err.Error(), wantMsg)
+	}
+}
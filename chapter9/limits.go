@@ -0,0 +1,108 @@
+package interp
+
+import (
+	"errors"
+
+	"example.com/cymbol"
+)
+
+// Limits
+//
+// A server running programs it doesn't trust has to bound what they use: a
+// loop may never end, a recursion never stop, and either can fill the
+// memory. The Limits of an interpreter stop a program going over one of them
+// with a RuntimeError wrapping one of the errors below, and RunContext stops
+// it once its context is done, with the context's error:
+//
+//	in.Limits = interp.Limits{Steps: 1_000_000, Depth: 1000, Cells: 100_000}
+//	ctx, cancel := context.WithTimeout(ctx, time.Second)
+//	defer cancel()
+//	_, err := in.RunContext(ctx, src)
+//	errors.Is(err, interp.ErrSteps)
+//
+// The steps are the statements run and the expressions evaluated by one
+// input. The depth is the number of calls running at once: without a limit,
+// a recursion that never stops runs until the Go stack overflows and the
+// whole process crashes. The cells are the values held at once by the
+// globals, the parameters and locals of the calls running, and the fields of
+// the instances made, along with the strings + makes, a cell for every
+// cellBytes bytes. Instances and strings stay counted: the interpreter can't
+// tell when one isn't used anymore. A string is counted before it's made, so
+// that one doubling in a loop stops at the limit rather than fill the
+// memory.
+
+// Limits bounds the resources a program uses, zero means no limit.
+type Limits struct {
+	Steps int // statements and expressions run by an input
+	Depth int // calls running at once
+	Cells int // values held at once
+}
+
+var (
+	ErrSteps = errors.New("too many steps")
+	ErrDepth = errors.New("too many nested calls")
+	ErrCells = errors.New("out of memory")
+)
+
+// step counts a step running n, and checks the program can go on.
+func (in *Interpreter) step(n cymbol.Node) {
+	in.steps++
+	if max := in.Limits.Steps; max > 0 && in.steps > max {
+		in.fail(spanOf(n), "%w", ErrSteps)
+	}
+	if in.ctx.Done() != nil {
+		select {
+		case <-in.ctx.Done():
+			in.fail(spanOf(n), "%w", in.ctx.Err())
+		default:
+		}
+	}
+}
+
+// allocate counts the cells n makes.
+func (in *Interpreter) allocate(n cymbol.Node, cells int) {
+	in.cells += cells
+	if max := in.Limits.Cells; max > 0 && in.cells > max {
+		in.fail(spanOf(n), "%w", ErrCells)
+	}
+}
+
+// cellBytes is the number of bytes of a string a cell holds.
+const cellBytes = 8
+
+// stringCells returns the number of cells of a string of n bytes.
+func stringCells(n int) int {
+	return (n + cellBytes - 1) / cellBytes
+}
+
+// isString tells whether v is a string.
+func isString(v Value) bool {
+	_, ok := v.(String)
+	return ok
+}
+
+// textLen returns the bytes of v in a string, at most those of how it's
+// written if it isn't one.
+func textLen(v Value) int {
+	if s, ok := v.(String); ok {
+		return len(s)
+	}
+	return len(v.String())
+}
+
+// size returns the number of cells of a new value v: the fields of an
+// instance, and of the instances they hold, or the bytes of a string.
+func size(v Value) int {
+	if s, ok := v.(String); ok {
+		return stringCells(len(s))
+	}
+	o, ok := v.(*StructInstance)
+	if !ok || o == nil {
+		return 0
+	}
+	cells := 0
+	for _, f := range o.order {
+		cells += 1 + size(o.values[f])
+	}
+	return cells
+}
@@ -0,0 +1,103 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		name   string
+		limits Limits
+		src    string
+		want   error
+		msg    string
+	}{
+		{
+			name:   "steps",
+			limits: Limits{Steps: 100},
+			src:    "int i = 0; while (true) { i = i + 1; }",
+			want:   ErrSteps,
+			msg:    "1:25: too many steps",
+		},
+		{
+			name:   "steps within the limit",
+			limits: Limits{Steps: 100},
+			src:    "int i = 0; while (i < 10) { i = i + 1; }",
+		},
+		{
+			name:   "depth",
+			limits: Limits{Depth: 3},
+			src:    "int f(int n) { return f(n + 1); } f(0)",
+			want:   ErrDepth,
+			msg: "1:23: too many nested calls\n" +
+				"\tin f called at 1:23\n\tin f called at 1:23\n\tin f called at 1:35",
+		},
+		{
+			name:   "depth within the limit",
+			limits: Limits{Depth: 3},
+			src:    "int f(int n) { if (n == 3) return n; return f(n + 1); } f(1)",
+		},
+		{
+			name:   "cells",
+			limits: Limits{Cells: 20},
+			src:    "struct P { int x; int y; }; void f() { P p; } while (true) f();",
+			want:   ErrCells,
+			msg:    "1:40: out of memory\n\tin f called at 1:60",
+		},
+		{
+			name:   "strings",
+			limits: Limits{Steps: 2000, Cells: 100},
+			src:    `string s = "xxxxxxxxxxxxxxxx"; while (true) { s = s + s; }`,
+			want:   ErrCells,
+			msg:    "1:51: out of memory",
+		},
+		{
+			name:   "cells freed by returning",
+			limits: Limits{Cells: 20},
+			src:    "void f(int a, int b) { int c = a + b; } int i = 0; while (i < 100) { f(i, i); i = i + 1; }",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			in := New()
+			in.Limits = tc.limits
+			_, err := in.Run(tc.src)
+			if !errors.Is(err, tc.want) || err != nil && err.Error() != tc.msg {
+				t.Errorf("got %v, want %q", err, tc.msg)
+			}
+		})
+	}
+}
+
+func TestLimitsKeepState(t *testing.T) {
+	in := New()
+	in.Limits = Limits{Steps: 50, Depth: 10, Cells: 10}
+	inputs := []string{
+		"int n = 0;",
+		"void loop() { while (true) n = n + 1; } loop();",
+		"int f() { return f(); } f();",
+		"n + 1", // the steps are counted for each input, and the calls stopped are over
+	}
+	var err error
+	var values []Value
+	for _, src := range inputs {
+		values, err = in.Run(src)
+	}
+	if err != nil || len(values) != 1 || values[0].String() == "0" {
+		t.Errorf("got %v, %v after hitting the limits", values, err)
+	}
+	if in.cells != 1 {
+		t.Errorf("got %d cells, want 1 for n", in.cells)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := New().RunContext(ctx, "while (true) { }")
+	if !errors.Is(err, context.Canceled) || err.Error() != "1:1: context canceled" {
+		t.Errorf("got %v, want 1:1: context canceled", err)
+	}
+}
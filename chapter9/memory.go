@@ -0,0 +1,181 @@
+package interp
+
+import (
+	"slices"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+)
+
+// Memory spaces
+//
+// The book keeps the values of variables in memory spaces: one for the
+// globals, and one for each function call, pushed on a stack when the call
+// starts and popped when it returns, so that each call of a recursive
+// function has its parameters and locals of its own. Structs and objects are
+// memory spaces too, holding their fields.
+//
+// The spaces here map the symbols of the variables to their values, rather
+// than their names. The blocks of a function can declare variables with the
+// same name, which are different symbols kept in the same space.
+
+// MemorySpace holds the values of variables.
+type MemorySpace struct {
+	Name   string
+	values map[symtab.Symbol]Value
+	order  []symtab.Symbol
+}
+
+func NewMemorySpace(name string) *MemorySpace {
+	return &MemorySpace{Name: name, values: map[symtab.Symbol]Value{}}
+}
+
+// Get returns the value of the variable v, false if it has none.
+func (m *MemorySpace) Get(v symtab.Symbol) (Value, bool) {
+	value, ok := m.values[v]
+	return value, ok
+}
+
+// Put sets the value of the variable v.
+func (m *MemorySpace) Put(v symtab.Symbol, value Value) {
+	if _, ok := m.values[v]; !ok {
+		m.order = append(m.order, v)
+	}
+	m.values[v] = value
+}
+
+// Symbols returns the variables that have a value, in the order they got it.
+func (m *MemorySpace) Symbols() []symtab.Symbol { return m.order }
+
+// String renders the space as `f: n=2, x=3`.
+func (m *MemorySpace) String() string {
+	var s strings.Builder
+	s.WriteString(m.Name + ":")
+	for i, v := range m.order {
+		if i > 0 {
+			s.WriteString(",")
+		}
+		s.WriteString(" " + v.Name() + "=" + m.values[v].String())
+	}
+	return s.String()
+}
+
+// FunctionSpace is the memory space of a function call, holding its
+// parameters and local variables.
+type FunctionSpace struct {
+	*MemorySpace
+	Func *symtab.FunctionSymbol // nil for the code entered at the top level
+	Call *cymbol.CallExpr       // the call of Func
+	This *StructInstance        // the object a method is called on
+}
+
+// StructInstance is a struct or an object, its memory space holds the values
+// of its fields. For an object, the ones it inherits too.
+type StructInstance struct {
+	*MemorySpace
+	Struct symtab.Type // a *symtab.StructSymbol or *symtab.ClassSymbol
+}
+
+// newInstance returns an instance of the struct or class t, with every field
+// set to its zero value. The fields of a type that's already being made,
+// making holds them, are nil: an instance of `class node { node next; };`
+// would otherwise need another one forever.
+func newInstance(t symtab.Type, making []symtab.Type) *StructInstance {
+	o := &StructInstance{MemorySpace: NewMemorySpace(t.Name()), Struct: t}
+	making = append(making, t)
+	for _, f := range fields(t) {
+		if slices.Contains(making, f.Type()) {
+			o.Put(f, (*StructInstance)(nil))
+			continue
+		}
+		o.Put(f, zero(f.Type(), making))
+	}
+	return o
+}
+
+// zero returns the value of a variable of type t declared without one. The
+// variables of struct and class types get a new instance.
+func zero(t symtab.Type, making []symtab.Type) Value {
+	switch t.(type) {
+	case *symtab.StructSymbol, *symtab.ClassSymbol:
+		return newInstance(t, making)
+	}
+	switch t.Name() {
+	case "int":
+		return Int(0)
+	case "float":
+		return Float(0)
+	case "char":
+		return Char(0)
+	case "boolean":
+		return Bool(false)
+	case "string":
+		return String("")
+	}
+	return nil
+}
+
+// fields returns the fields of the struct or class t in the order they're
+// declared, the inherited ones first.
+func fields(t symtab.Type) []symtab.Symbol {
+	var scopes []symtab.Scope
+	switch t := t.(type) {
+	case *symtab.StructSymbol:
+		scopes = append(scopes, t)
+	case *symtab.ClassSymbol:
+		for c := t; c != nil; c = c.Superclass {
+			scopes = slices.Insert(scopes, 0, symtab.Scope(c))
+		}
+	}
+	var fs []symtab.Symbol
+	for _, s := range scopes {
+		for _, sym := range s.Symbols() {
+			if v, ok := sym.(*symtab.VariableSymbol); ok {
+				fs = append(fs, v)
+			}
+		}
+	}
+	return fs
+}
+
+// Type returns the name of the struct or class of o, null if o is nil.
+func (o *StructInstance) Type() string {
+	if o == nil {
+		return "null"
+	}
+	return o.Struct.Name()
+}
+
+// String writes o as the name of its type followed by its fields,
+// `point{x: 1, y: 2.0}`. A field that's an instance seen already is written
+// as `...`, and one that's nil as `null`.
+func (o *StructInstance) String() string {
+	var s strings.Builder
+	o.format(&s, nil)
+	return s.String()
+}
+
+func (o *StructInstance) format(s *strings.Builder, seen []*StructInstance) {
+	switch {
+	case o == nil:
+		s.WriteString("null")
+	case slices.Contains(seen, o):
+		s.WriteString("...")
+	default:
+		s.WriteString(o.Struct.Name() + "{")
+		for i, f := range fields(o.Struct) {
+			if i > 0 {
+				s.WriteString(", ")
+			}
+			s.WriteString(f.Name() + ": ")
+			switch v, _ := o.Get(f); v := v.(type) {
+			case *StructInstance:
+				v.format(s, append(seen, o))
+			default:
+				s.WriteString(v.String())
+			}
+		}
+		s.WriteString("}")
+	}
+}
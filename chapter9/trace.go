@@ -0,0 +1,182 @@
+package interp
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"example.com/cymbol"
+)
+
+// Tracing and profiling
+//
+// With a Trace writer set, the interpreter writes each node it runs: a
+// statement before running it, and an expression once it's evaluated,
+// followed by its value. Lines are indented by how deep the node is in the
+// nodes running, calls included, blocks left out:
+//
+//	6:1 x = sq(x);
+//	    6:8 x => 2
+//	    2:5 int r = (n * n);
+//	        2:13 n => 2
+//	        2:17 n => 2
+//	      2:13 (n * n) => 4
+//	    3:5 return r;
+//	      3:12 r => 4
+//	  6:5 sq(x) => 4
+//
+// With a Profile set, it counts how many times each node, blocks left out
+// again, and each function runs and how long they take, the time of the nodes
+// and calls they run included. A recursive function's time is counted in each
+// of its calls, so the time of its outermost call is the one to look at. The
+// nodes and functions taking the longest tell where a program spends its time,
+// and the counts how much work it does, to compare with the same program
+// compiled.
+
+// Sample is the number of times something ran and the time it took.
+type Sample struct {
+	Count int
+	Time  time.Duration
+}
+
+// Profile has the samples of the nodes and functions an interpreter runs.
+type Profile struct {
+	Nodes map[cymbol.Node]*Sample
+	Funcs map[string]*Sample // by name, Class.method for a method
+
+	now func() time.Time
+}
+
+func NewProfile() *Profile {
+	return &Profile{Nodes: map[cymbol.Node]*Sample{}, Funcs: map[string]*Sample{}, now: time.Now}
+}
+
+// record adds a run of k that took the time since start to samples.
+func record[K comparable](p *Profile, samples map[K]*Sample, k K, start time.Time) {
+	s := samples[k]
+	if s == nil {
+		s = &Sample{}
+		samples[k] = s
+	}
+	s.Count++
+	s.Time += p.now().Sub(start)
+}
+
+// Write writes the functions and the nodes that took the most time, at most
+// top of each, with how many times they ran:
+//
+//	    time  count  function
+//	23.958µs      2  sq
+//
+//	    time  count  node
+//	20.292µs      1  6:1 x = sq(x);
+func (p *Profile) Write(w io.Writer, top int) error {
+	type row struct {
+		Sample
+		name string
+		pos  cymbol.Pos
+	}
+	var funcs, nodes []row
+	for name, s := range p.Funcs {
+		funcs = append(funcs, row{Sample: *s, name: name})
+	}
+	for n, s := range p.Nodes {
+		nodes = append(nodes, row{Sample: *s, name: n.Pos().String() + " " + traceText(n), pos: n.Pos()})
+	}
+	for _, rows := range [][]row{funcs, nodes} {
+		slices.SortFunc(rows, func(a, b row) int {
+			if c := cmp.Compare(b.Time, a.Time); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(b.Count, a.Count); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(a.pos.Line, b.pos.Line); c != 0 {
+				return c
+			}
+			if c := cmp.Compare(a.pos.Col, b.pos.Col); c != 0 {
+				return c
+			}
+			return strings.Compare(a.name, b.name)
+		})
+	}
+
+	var s strings.Builder
+	for i, section := range []struct {
+		title string
+		rows  []row
+	}{{"function", funcs}, {"node", nodes}} {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		fmt.Fprintf(&s, "%10s %6s  %s\n", "time", "count", section.title)
+		for _, r := range section.rows[:min(top, len(section.rows))] {
+			fmt.Fprintf(&s, "%10v %6d  %s\n", r.Time, r.Count, r.name)
+		}
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// observing tells whether the nodes run are traced or profiled.
+func (in *Interpreter) observing() bool {
+	return in.Trace != nil || in.Profile != nil
+}
+
+// enter writes the statement n to the trace and goes one level deeper. It
+// returns the time it started.
+func (in *Interpreter) enter(n cymbol.Node) time.Time {
+	if _, ok := n.(cymbol.Stmt); ok && in.Trace != nil {
+		in.trace(n.Pos(), traceText(n))
+	}
+	in.depth++
+	if in.Profile == nil {
+		return time.Time{}
+	}
+	return in.Profile.now()
+}
+
+// leave goes back one level and writes the expression n to the trace, with
+// its value v. The node took the time since start.
+func (in *Interpreter) leave(n cymbol.Node, v Value, start time.Time) {
+	in.depth--
+	if x, ok := n.(cymbol.Expr); ok && in.Trace != nil {
+		if v == nil {
+			// a call of a function returning void
+			in.trace(x.Pos(), x.String())
+		} else {
+			in.trace(x.Pos(), fmt.Sprintf("%v => %v", x, v))
+		}
+	}
+	if in.Profile != nil {
+		record(in.Profile, in.Profile.Nodes, n, start)
+	}
+}
+
+func (in *Interpreter) trace(pos cymbol.Pos, text string) {
+	fmt.Fprintf(in.Trace, "%s%v %s\n", strings.Repeat("  ", in.depth), pos, text)
+}
+
+// traceText returns how the node n is written in a trace or a profile: the
+// header of a statement that has others in it, all of it otherwise.
+func traceText(n cymbol.Node) string {
+	switch n := n.(type) {
+	case *cymbol.IfStmt:
+		return "if " + condition(n.Cond)
+	case *cymbol.WhileStmt:
+		return "while " + condition(n.Cond)
+	}
+	return n.String()
+}
+
+// condition writes the condition x between parentheses, the ones of a binary
+// expression are there already.
+func condition(x cymbol.Expr) string {
+	if _, ok := x.(*cymbol.BinaryExpr); ok {
+		return x.String()
+	}
+	return "(" + x.String() + ")"
+}
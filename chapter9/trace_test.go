@@ -0,0 +1,75 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTrace(t *testing.T) {
+	in := New()
+	var trace strings.Builder
+	in.Trace = &trace
+	src := `void p(int n) { if (n > 0) print(n); }
+int i = 0;
+while (i < 1) { p(i); i = i + 1; }`
+	if _, err := in.Run(src); err != nil {
+		t.Fatal(err)
+	}
+	want := `2:1 int i = 0;
+  2:9 0 => 0
+3:1 while (i < 1)
+    3:8 i => 0
+    3:12 1 => 1
+  3:8 (i < 1) => true
+  3:17 p(i);
+      3:19 i => 0
+      1:17 if (n > 0)
+          1:21 n => 0
+          1:25 0 => 0
+        1:21 (n > 0) => false
+    3:17 p(i)
+  3:23 i = (i + 1);
+      3:27 i => 0
+      3:31 1 => 1
+    3:27 (i + 1) => 1
+    3:8 i => 1
+    3:12 1 => 1
+  3:8 (i < 1) => false
+`
+	if diff := cmp.Diff(want, trace.String()); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	in := New()
+	p := NewProfile()
+	var clock time.Time
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	in.Profile = p
+	src := "int sq(int n) { return n * n; } sq(2) + sq(3)"
+	if _, err := in.Run(src); err != nil {
+		t.Fatal(err)
+	}
+	var s strings.Builder
+	if err := p.Write(&s, 3); err != nil {
+		t.Fatal(err)
+	}
+	want := `      time  count  function
+      18ms      2  sq
+
+      time  count  node
+      29ms      1  1:33 (sq(2) + sq(3))
+      14ms      2  1:17 return (n * n);
+      13ms      1  1:33 sq(2)
+`
+	if diff := cmp.Diff(want, s.String()); diff != "" {
+		t.Errorf("(-want +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,212 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"example.com/cymbol"
+	"example.com/types"
+)
+
+// Values
+//
+// Every value the interpreter computes is a Value of the Cymbol type it has:
+// Int, Float, Char, Bool and String for the built-in types, and
+// *StructInstance for structs and objects, nil for null. A value knows the
+// name of its type and how it's written in Cymbol.
+//
+// The operators and the conversions follow the rules of package types, the
+// ones the type checker uses, rather than rules of their own: an operator is
+// defined on the operand types the checker accepts, with the result type it
+// computes, and operands of different types are promoted to the wider one as
+// the checker says they are:
+//
+//	Binary(+, Char('a'), Int(1))     Int(98)
+//	Binary(<, Int(1), Float(2.5))    Bool(true)
+//	Binary(+, Bool(true), Int(1))    operator + not defined on boolean and int
+//
+// The interpreter runs checked code, which converts its values where the
+// checker found they're promoted, so it never gets the errors. A program
+// using the values directly may.
+
+// Value is the value of a Cymbol expression or variable.
+type Value interface {
+	Type() string   // the name of its type
+	String() string // as it's written in Cymbol
+}
+
+type (
+	Int    int64
+	Float  float64
+	Char   rune
+	Bool   bool
+	String string
+)
+
+func (Int) Type() string    { return "int" }
+func (Float) Type() string  { return "float" }
+func (Char) Type() string   { return "char" }
+func (Bool) Type() string   { return "boolean" }
+func (String) Type() string { return "string" }
+
+func (v Int) String() string { return strconv.FormatInt(int64(v), 10) }
+
+// String writes v with a decimal point, 2.0 rather than 2, the way a float
+// literal is written.
+func (v Float) String() string {
+	f := float64(v)
+	text := strconv.FormatFloat(f, 'f', -1, 64)
+	if !math.IsInf(f, 0) && !math.IsNaN(f) && !strings.Contains(text, ".") {
+		text += ".0"
+	}
+	return text
+}
+
+func (v Char) String() string   { return quote(string(v), '\'') }
+func (v Bool) String() string   { return strconv.FormatBool(bool(v)) }
+func (v String) String() string { return quote(string(v), '"') }
+
+// quote writes s as a Cymbol literal between the quotes q, escaping what the
+// lexer expects escaped. Other characters that can't be printed, which
+// Cymbol has no literal for, are escaped as in Go.
+func quote(s string, q rune) string {
+	var b strings.Builder
+	b.WriteRune(q)
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == q:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case !unicode.IsPrint(r):
+			quoted := strconv.QuoteRune(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune(q)
+	return b.String()
+}
+
+// Convert returns v converted to the type named to, which is v itself if it
+// has that type already. It's an error unless the type of v is promoted to
+// to.
+func Convert(v Value, to string) (Value, error) {
+	if v.Type() == to {
+		return v, nil
+	}
+	if types.Promotes(v.Type(), to) {
+		switch v := v.(type) {
+		case Char:
+			if to == "int" {
+				return Int(v), nil
+			}
+			return Float(v), nil
+		case Int:
+			return Float(v), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %v (%s) to %s", v, v.Type(), to)
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+// Binary applies the binary operator op to x and y.
+func Binary(op cymbol.Token, x, y Value) (Value, error) {
+	if types.ResultType(op.Type, x.Type(), y.Type()) == "" {
+		return nil, fmt.Errorf("operator %s not defined on %s and %s", op.Text, x.Type(), y.Type())
+	}
+	if wider := types.Wider(x.Type(), y.Type()); wider != "" {
+		x, _ = Convert(x, wider)
+		y, _ = Convert(y, wider)
+	}
+	switch x := x.(type) {
+	case Int:
+		return arithmetic(op, x, y.(Int))
+	case Float:
+		return arithmetic(op, x, y.(Float))
+	case Char:
+		return arithmetic(op, x, y.(Char))
+	case String:
+		y := y.(String)
+		switch op.Type {
+		case cymbol.Plus:
+			return x + y, nil
+		case cymbol.Eq:
+			return Bool(x == y), nil
+		case cymbol.Ne:
+			return Bool(x != y), nil
+		}
+	case Bool:
+		y := y.(Bool)
+		switch op.Type {
+		case cymbol.Eq:
+			return Bool(x == y), nil
+		case cymbol.Ne:
+			return Bool(x != y), nil
+		}
+	}
+	return nil, fmt.Errorf("operator %s not defined on %s and %s", op.Text, x.Type(), y.Type())
+}
+
+type number interface {
+	Int | Float | Char
+	Value
+}
+
+// arithmetic applies the operator op to numbers of the same type. Dividing
+// an Int or a Char by zero is an error, a Float gives an infinity.
+func arithmetic[T number](op cymbol.Token, a, b T) (Value, error) {
+	switch op.Type {
+	case cymbol.Plus:
+		return a + b, nil
+	case cymbol.Minus:
+		return a - b, nil
+	case cymbol.Star:
+		return a * b, nil
+	case cymbol.Slash:
+		if _, float := Value(b).(Float); b == 0 && !float {
+			return nil, errDivisionByZero
+		}
+		return a / b, nil
+	case cymbol.Lt:
+		return Bool(a < b), nil
+	case cymbol.Le:
+		return Bool(a <= b), nil
+	case cymbol.Gt:
+		return Bool(a > b), nil
+	case cymbol.Ge:
+		return Bool(a >= b), nil
+	case cymbol.Eq:
+		return Bool(a == b), nil
+	case cymbol.Ne:
+		return Bool(a != b), nil
+	}
+	return nil, fmt.Errorf("operator %s not defined on %s", op.Text, a.Type())
+}
+
+// Unary applies the unary operator op to x.
+func Unary(op cymbol.Token, x Value) (Value, error) {
+	result := types.UnaryType(op.Type, x.Type())
+	if result == "" {
+		return nil, fmt.Errorf("operator %s not defined on %s", op.Text, x.Type())
+	}
+	x, _ = Convert(x, result) // -c is an int
+	switch x := x.(type) {
+	case Int:
+		return -x, nil
+	case Float:
+		return -x, nil
+	case Bool:
+		return !x, nil
+	}
+	return nil, fmt.Errorf("operator %s not defined on %s", op.Text, x.Type())
+}
@@ -0,0 +1,100 @@
+package interp
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+)
+
+func TestBinary(t *testing.T) {
+	op := func(typ cymbol.TokenType, text string) cymbol.Token {
+		return cymbol.Token{Type: typ, Text: text}
+	}
+	cases := []struct {
+		op   cymbol.Token
+		x, y Value
+		want string // the result's type and value, or the error
+	}{
+		{op(cymbol.Plus, "+"), Char('a'), Int(1), "int 98"},
+		{op(cymbol.Plus, "+"), Char('a'), Char(1), "char 'b'"},
+		{op(cymbol.Star, "*"), Int(2), Float(1.5), "float 3.0"},
+		{op(cymbol.Slash, "/"), Int(7), Int(2), "int 3"},
+		{op(cymbol.Slash, "/"), Float(1), Int(0), "float +Inf"},
+		{op(cymbol.Slash, "/"), Char('a'), Char(0), "division by zero"},
+		{op(cymbol.Lt, "<"), Int(1), Float(2.5), "boolean true"},
+		{op(cymbol.Eq, "=="), Char('a'), Int(97), "boolean true"},
+		{op(cymbol.Plus, "+"), String("a"), String("b"), `string "ab"`},
+		{op(cymbol.Ne, "!="), Bool(true), Bool(false), "boolean true"},
+		{op(cymbol.Plus, "+"), Bool(true), Int(1), "operator + not defined on boolean and int"},
+		{op(cymbol.Lt, "<"), String("a"), String("b"), "operator < not defined on string and string"},
+	}
+	for _, tc := range cases {
+		v, err := Binary(tc.op, tc.x, tc.y)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = v.Type() + " " + v.String()
+		}
+		if got != tc.want {
+			t.Errorf("%v %s %v = %s, want %s", tc.x, tc.op.Text, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestUnary(t *testing.T) {
+	minus := cymbol.Token{Type: cymbol.Minus, Text: "-"}
+	not := cymbol.Token{Type: cymbol.Not, Text: "!"}
+	cases := []struct {
+		op   cymbol.Token
+		x    Value
+		want string
+	}{
+		{minus, Int(1), "int -1"},
+		{minus, Float(1.5), "float -1.5"},
+		{minus, Char('a'), "int -97"},
+		{not, Bool(true), "boolean false"},
+		{not, Int(1), "operator ! not defined on int"},
+		{minus, String("a"), "operator - not defined on string"},
+	}
+	for _, tc := range cases {
+		v, err := Unary(tc.op, tc.x)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = v.Type() + " " + v.String()
+		}
+		if got != tc.want {
+			t.Errorf("%s%v = %s, want %s", tc.op.Text, tc.x, got, tc.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		v    Value
+		to   string
+		want string
+	}{
+		{Char('a'), "int", "97"},
+		{Char('a'), "float", "97.0"},
+		{Int(2), "float", "2.0"},
+		{Int(2), "int", "2"},
+		{Float(2), "int", "cannot convert 2.0 (float) to int"},
+		{Int(97), "char", "cannot convert 97 (int) to char"},
+		{String("1"), "int", `cannot convert "1" (string) to int`},
+	}
+	for _, tc := range cases {
+		v, err := Convert(tc.v, tc.to)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = v.String()
+		}
+		if got != tc.want {
+			t.Errorf("Convert(%v, %s) = %s, want %s", tc.v, tc.to, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package cymbol
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Rewriting trees
+//
+// Apply is the Cymbol version of chapter3's Apply, itself modeled after
+// astutil.Apply for Go's ASTs: it walks a tree and hands out a Cursor for each
+// node that knows where the node is held, so that a pass can replace it,
+// delete it or insert nodes next to it without knowing its parent.
+//
+// It walks the same children as symtab's Walk: the names and types in
+// declarations are part of them rather than nodes of their own, and so is the
+// member of a member access. Changes are checked against what the parser
+// builds, an expression can only be replaced by an expression, a statement by
+// a statement and so on, and only the elements of lists can be deleted or have
+// nodes inserted next to them: declarations, statements, fields, members,
+// parameters and arguments. Anything else panics, just like setting a field
+// to the wrong type would.
+
+// ApplyFunc is called for each node with a Cursor pointing at it.
+type ApplyFunc func(c *Cursor) bool
+
+// Cursor describes a node found during Apply and lets it be changed.
+type Cursor struct {
+	node    Node
+	parent  Node
+	index   int             // index in the parent's list, -1 if not in one
+	fits    func(Node) bool // can a node take this one's place
+	replace func(Node)      // puts a node in this one's place
+	list    *listEdit       // edits to the parent's list, nil if not in one
+	deleted bool
+}
+
+// listEdit holds the operations on the list the cursor's node is in.
+type listEdit struct {
+	delete       func()
+	insertBefore func(Node)
+	insertAfter  func(Node)
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node, nil for the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Index returns the index of the current node in its parent's list, or -1 if
+// it's not in one.
+func (c *Cursor) Index() int { return c.index }
+
+// CanReplace tells whether n can take the current node's place.
+func (c *Cursor) CanReplace(n Node) bool {
+	return n != nil && c.fits(n)
+}
+
+// Replace puts n in place of the current node. The children of n aren't
+// visited, Apply already went past them or will visit the ones of the old
+// node.
+func (c *Cursor) Replace(n Node) {
+	if c.deleted {
+		panic("replace: node was deleted")
+	}
+	if !c.CanReplace(n) {
+		panic(fmt.Sprintf("replace: %s can't take the place of %s", describe(n), describe(c.node)))
+	}
+	c.replace(n)
+	c.node = n
+}
+
+// Delete removes the current node from its parent's list. Its children
+// aren't visited.
+func (c *Cursor) Delete() {
+	if c.list == nil {
+		panic(fmt.Sprintf("delete: %s isn't in a list", describe(c.node)))
+	}
+	if c.deleted {
+		panic("delete: node was already deleted")
+	}
+	c.list.delete()
+	c.deleted = true
+}
+
+// InsertBefore inserts n before the current node in its parent's list. It
+// isn't visited by Apply.
+func (c *Cursor) InsertBefore(n Node) {
+	c.checkInsert(n)
+	c.list.insertBefore(n)
+	c.index++
+}
+
+// InsertAfter inserts n after the current node in its parent's list. It
+// isn't visited by Apply.
+func (c *Cursor) InsertAfter(n Node) {
+	c.checkInsert(n)
+	c.list.insertAfter(n)
+}
+
+func (c *Cursor) checkInsert(n Node) {
+	if c.list == nil {
+		panic(fmt.Sprintf("insert: %s isn't in a list", describe(c.node)))
+	}
+	if !c.CanReplace(n) {
+		panic(fmt.Sprintf("insert: %s can't go next to %s", describe(n), describe(c.node)))
+	}
+}
+
+func describe(n Node) string {
+	if n == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%T %s", n, n)
+}
+
+// abort is panicked by Apply when post returns false
+type abort struct{}
+
+// Apply walks the tree rooted at root calling pre before the children of a
+// node are visited and post after, either can be nil. If pre returns false the
+// children and post are skipped for that node, if post returns false the walk
+// stops. The tree is changed in place, Apply returns the root which is only a
+// new node if the root itself was replaced.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(abort); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	result = root
+	a := &application{pre: pre, post: post}
+	a.apply(&Cursor{
+		node:    root,
+		index:   -1,
+		fits:    func(Node) bool { return true },
+		replace: func(n Node) { result = n },
+	})
+	return result
+}
+
+type application struct {
+	pre, post ApplyFunc
+}
+
+func (a *application) apply(c *Cursor) {
+	if a.pre != nil && !a.pre(c) {
+		return
+	}
+	if c.deleted {
+		return
+	}
+	a.applyChildren(c.node)
+	if a.post != nil && !a.post(c) {
+		panic(abort{})
+	}
+}
+
+func (a *application) applyChildren(n Node) {
+	switch n := n.(type) {
+	case *File:
+		applyList(a, n, &n.Decls, isDecl)
+	case *VarDecl:
+		a.applyField(n, n.Value, isExpr, func(c Node) { n.Value = c.(Expr) })
+	case *FuncDecl:
+		applyList(a, n, &n.Params, isParam)
+		a.applyField(n, n.Body, isBlock, func(c Node) { n.Body = c.(*Block) })
+	case *StructDecl:
+		applyList(a, n, &n.Fields, isField)
+	case *ClassDecl:
+		applyList(a, n, &n.Members, isMember)
+	case *NamespaceDecl:
+		applyList(a, n, &n.Decls, isDecl)
+	case *Block:
+		applyList(a, n, &n.Stmts, isStmt)
+	case *IfStmt:
+		a.applyField(n, n.Cond, isExpr, func(c Node) { n.Cond = c.(Expr) })
+		a.applyField(n, n.Then, isStmt, func(c Node) { n.Then = c.(Stmt) })
+		a.applyField(n, n.Else, isStmt, func(c Node) { n.Else = c.(Stmt) })
+	case *WhileStmt:
+		a.applyField(n, n.Cond, isExpr, func(c Node) { n.Cond = c.(Expr) })
+		a.applyField(n, n.Body, isStmt, func(c Node) { n.Body = c.(Stmt) })
+	case *ReturnStmt:
+		a.applyField(n, n.Value, isExpr, func(c Node) { n.Value = c.(Expr) })
+	case *AssignStmt:
+		a.applyField(n, n.Target, isExpr, func(c Node) { n.Target = c.(Expr) })
+		a.applyField(n, n.Value, isExpr, func(c Node) { n.Value = c.(Expr) })
+	case *ExprStmt:
+		a.applyField(n, n.X, isExpr, func(c Node) { n.X = c.(Expr) })
+	case *BinaryExpr:
+		a.applyField(n, n.X, isExpr, func(c Node) { n.X = c.(Expr) })
+		a.applyField(n, n.Y, isExpr, func(c Node) { n.Y = c.(Expr) })
+	case *UnaryExpr:
+		a.applyField(n, n.X, isExpr, func(c Node) { n.X = c.(Expr) })
+	case *CallExpr:
+		a.applyField(n, n.Fun, isExpr, func(c Node) { n.Fun = c.(Expr) })
+		applyList(a, n, &n.Args, isExpr)
+	case *MemberExpr:
+		a.applyField(n, n.X, isExpr, func(c Node) { n.X = c.(Expr) })
+	}
+}
+
+// applyField visits the node in a field of parent, if the field isn't one of
+// the optional ones left empty.
+func (a *application) applyField(parent Node, n Node, fits func(Node) bool, set func(Node)) {
+	if n == nil {
+		return
+	}
+	a.apply(&Cursor{node: n, parent: parent, index: -1, fits: fits, replace: set})
+}
+
+// applyList visits the nodes of a list that can change while it's visited.
+// It's a function because Go methods can't have type parameters, and the
+// lists have elements of different types.
+func applyList[T Node](a *application, parent Node, list *[]T, fits func(Node) bool) {
+	for i := 0; i < len(*list); {
+		next := i + 1
+		c := &Cursor{node: (*list)[i], parent: parent, index: i, fits: fits}
+		c.replace = func(n Node) { (*list)[c.index] = n.(T) }
+		c.list = &listEdit{
+			delete: func() {
+				*list = slices.Delete(*list, c.index, c.index+1)
+				next--
+			},
+			insertBefore: func(n Node) {
+				*list = slices.Insert(*list, c.index, n.(T))
+				next++
+			},
+			insertAfter: func(n Node) {
+				*list = slices.Insert(*list, c.index+1, n.(T))
+				next++
+			},
+		}
+		a.apply(c)
+		i = next
+	}
+}
+
+// what can go in each place of the tree
+
+func isDecl(n Node) bool {
+	_, ok := n.(Decl)
+	return ok
+}
+
+func isStmt(n Node) bool {
+	_, ok := n.(Stmt)
+	return ok
+}
+
+func isExpr(n Node) bool {
+	_, ok := n.(Expr)
+	return ok
+}
+
+func isParam(n Node) bool {
+	_, ok := n.(*Param)
+	return ok
+}
+
+func isBlock(n Node) bool {
+	_, ok := n.(*Block)
+	return ok
+}
+
+// isField tells whether n can be the field of a struct, a variable without a
+// value or a nested struct.
+func isField(n Node) bool {
+	switch n := n.(type) {
+	case *VarDecl:
+		return n.Value == nil
+	case *StructDecl:
+		return true
+	}
+	return false
+}
+
+// isMember tells whether n can be a member of a class, a variable without a
+// value or a method.
+func isMember(n Node) bool {
+	switch n := n.(type) {
+	case *VarDecl:
+		return n.Value == nil
+	case *FuncDecl:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package cymbol
+
+import (
+	"testing"
+)
+
+func ident(name string) *Ident {
+	return &Ident{Token: Token{Type: ID, Text: name}, Name: name}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		pre   ApplyFunc
+		post  ApplyFunc
+		want  string
+	}{
+		{
+			name:  "replace names",
+			input: "int x = a + f(a, b); void g() { a = a.y; }",
+			pre: func(c *Cursor) bool {
+				if n, ok := c.Node().(*Ident); ok && n.Name == "a" {
+					c.Replace(ident("z"))
+				}
+				return true
+			},
+			want: "int x = (z + f(z, b));\nvoid g() { z = z.y; }",
+		},
+		{
+			name:  "delete statements",
+			input: "void f() { int x; x = 1; g(x); return; }",
+			pre: func(c *Cursor) bool {
+				switch c.Node().(type) {
+				case *AssignStmt, *ExprStmt:
+					c.Delete()
+				}
+				return true
+			},
+			want: "void f() { int x; return; }",
+		},
+		{
+			name:  "insert around arguments",
+			input: "int x = f(a, b);",
+			pre: func(c *Cursor) bool {
+				if n, ok := c.Node().(*Ident); ok && c.Index() >= 0 {
+					c.InsertBefore(ident("before" + n.Name))
+					c.InsertAfter(ident("after" + n.Name))
+				}
+				return true
+			},
+			want: "int x = f(beforea, a, aftera, beforeb, b, afterb);",
+		},
+		{
+			name:  "delete declarations",
+			input: "int x; struct s { int y; float z; }; void f() { }",
+			pre: func(c *Cursor) bool {
+				if d, ok := c.Node().(*VarDecl); ok && d.Type.Name == "int" {
+					c.Delete()
+				}
+				return true
+			},
+			want: "struct s { float z; };\nvoid f() { }",
+		},
+		{
+			name:  "negate in post",
+			input: "boolean b = x < y;",
+			post: func(c *Cursor) bool {
+				if x, ok := c.Node().(*BinaryExpr); ok {
+					c.Replace(&UnaryExpr{Op: Token{Type: Not, Text: "!"}, X: x})
+				}
+				return true
+			},
+			want: "boolean b = (!(x < y));",
+		},
+		{
+			name:  "skip children",
+			input: "int x = a + f(a);",
+			pre: func(c *Cursor) bool {
+				if n, ok := c.Node().(*Ident); ok {
+					c.Replace(ident(n.Name + "x"))
+				}
+				_, isCall := c.Node().(*CallExpr)
+				return !isCall
+			},
+			want: "int x = (ax + f(a));",
+		},
+		{
+			name:  "stop",
+			input: "int x = a + b + c;",
+			post: func(c *Cursor) bool {
+				if n, ok := c.Node().(*Ident); ok {
+					c.Replace(ident(n.Name + "x"))
+					return n.Name != "b"
+				}
+				return true
+			},
+			want: "int x = ((ax + bx) + c);",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ParseFile(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Apply(f, tc.pre, tc.post).String(); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	x, err := ParseExpr("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Apply(x, func(c *Cursor) bool {
+		c.Replace(ident("b"))
+		return true
+	}, nil)
+	if got.String() != "b" {
+		t.Errorf("want: b, got: %v", got)
+	}
+}
+
+func TestApplyPanics(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		pre   ApplyFunc
+	}{
+		{
+			name:  "statement for an expression",
+			input: "int x = a;",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Node().(*Ident); ok {
+					c.Replace(&ExprStmt{X: ident("b")})
+				}
+				return true
+			},
+		},
+		{
+			name:  "initialized field",
+			input: "struct s { int x; };",
+			pre: func(c *Cursor) bool {
+				if d, ok := c.Node().(*VarDecl); ok {
+					c.Replace(&VarDecl{Type: d.Type, Name: d.Name, Value: ident("a")})
+				}
+				return true
+			},
+		},
+		{
+			name:  "delete an operand",
+			input: "int x = a + b;",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Node().(*Ident); ok {
+					c.Delete()
+				}
+				return true
+			},
+		},
+		{
+			name:  "insert next to the condition",
+			input: "void f() { if (a) { } }",
+			pre: func(c *Cursor) bool {
+				if _, ok := c.Node().(*Ident); ok {
+					c.InsertAfter(ident("b"))
+				}
+				return true
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ParseFile(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("want panic")
+				} else {
+					t.Log(r)
+				}
+			}()
+			Apply(f, tc.pre, nil)
+		})
+	}
+}
@@ -0,0 +1,418 @@
+package cymbol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Abstract Syntax Tree
+//
+// The tree is heterogeneous, one type per construct, split in three families
+// the same way go/ast does it: declarations, statements and expressions.
+// Variable and struct declarations are both declarations (at the top level)
+// and statements (inside a block).
+//
+// Types are written as identifiers, `int x;` is a VarDecl whose Type is the
+// Ident `int`. Figuring out what a type name means is the symbol table's job.
+//
+// Each node's String renders it back to source text. Expressions are fully
+// parenthesized so the shape of the tree is visible: `1 + 2 * 3` is rendered
+// as `(1 + (2 * 3))`.
+
+// Node is implemented by every node in the tree. Pos is the position of the
+// node's first token.
+type Node interface {
+	Pos() Pos
+	String() string
+}
+
+// Decl is a top level declaration.
+type Decl interface {
+	Node
+	declNode()
+}
+
+// Stmt is a statement inside a function body.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an expression, something that has a value.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// File is the root of the tree, holding every declaration in the input in
+// order.
+type File struct {
+	Decls []Decl
+}
+
+type (
+	// VarDecl declares a variable, optionally initializing it: `int x = 1;`
+	VarDecl struct {
+		Type  *Ident
+		Name  *Ident
+		Value Expr // nil if there's no initializer
+	}
+
+	// FuncDecl declares a function: `int f(int a) { ... }`
+	FuncDecl struct {
+		Type   *Ident // return type
+		Name   *Ident
+		Params []*Param
+		Body   *Block
+	}
+
+	// Param is a single function parameter: `int a`
+	Param struct {
+		Type *Ident
+		Name *Ident
+	}
+
+	// StructDecl declares a struct type: `struct point { int x; int y; };`
+	// Fields are VarDecls without a value, or nested StructDecls.
+	StructDecl struct {
+		Struct Pos // position of "struct"
+		Name   *Ident
+		Fields []Decl
+		Rbrace Pos
+	}
+
+	// ClassDecl declares a class: `class B : A { int y; void f() { } };`
+	// Members are VarDecls without a value and FuncDecls, the methods.
+	ClassDecl struct {
+		Class   Pos // position of "class"
+		Name    *Ident
+		Super   *Ident // nil if there's no superclass
+		Members []Decl
+		Rbrace  Pos
+	}
+
+	// NamespaceDecl declares a namespace: `namespace geo { int dist(...) { } }`
+	// Its declarations are in its own scope, used from outside as geo.dist.
+	NamespaceDecl struct {
+		Namespace Pos // position of "namespace"
+		Name      *Ident
+		Decls     []Decl
+		Rbrace    Pos
+	}
+
+	// ImportDecl imports the declarations of a namespace into the scope it's
+	// in, where they can be used without the namespace's name: `import geo;`
+	ImportDecl struct {
+		Import Pos // position of "import"
+		Name   *Ident
+	}
+)
+
+type (
+	// Block is a sequence of statements between braces.
+	Block struct {
+		Lbrace Pos
+		Stmts  []Stmt
+		Rbrace Pos
+	}
+
+	// IfStmt is `if (Cond) Then else Else`.
+	IfStmt struct {
+		If   Pos
+		Cond Expr
+		Then Stmt
+		Else Stmt // nil if there's no else branch
+	}
+
+	// WhileStmt is `while (Cond) Body`.
+	WhileStmt struct {
+		While Pos
+		Cond  Expr
+		Body  Stmt
+	}
+
+	// ReturnStmt is `return Value;`
+	ReturnStmt struct {
+		Return Pos
+		Value  Expr // nil in functions returning void
+	}
+
+	// AssignStmt is `Target = Value;`
+	AssignStmt struct {
+		Target Expr
+		Value  Expr
+	}
+
+	// ExprStmt is an expression used as a statement, usually a call: `f(x);`
+	ExprStmt struct {
+		X Expr
+	}
+)
+
+type (
+	// Ident is a name: a variable, function or type.
+	Ident struct {
+		Token Token
+		Name  string
+	}
+
+	// IntLit is an integer literal such as `42`.
+	IntLit struct {
+		Token Token
+		Value int64
+	}
+
+	// FloatLit is a floating point literal such as `4.2`.
+	FloatLit struct {
+		Token Token
+		Value float64
+	}
+
+	// CharLit is a character literal such as `'a'`.
+	CharLit struct {
+		Token Token
+		Value rune
+	}
+
+	// StringLit is a string literal such as `"hi"`. Value has the escape
+	// sequences already interpreted.
+	StringLit struct {
+		Token Token
+		Value string
+	}
+
+	// BoolLit is one of the keywords `true` or `false`.
+	BoolLit struct {
+		Token Token
+		Value bool
+	}
+
+	// BinaryExpr is `X Op Y` such as `a + 1` or `a < b`.
+	BinaryExpr struct {
+		X  Expr
+		Op Token
+		Y  Expr
+	}
+
+	// UnaryExpr is `Op X` such as `-a` or `!b`.
+	UnaryExpr struct {
+		Op Token
+		X  Expr
+	}
+
+	// CallExpr is a function call `Fun(Args)`.
+	CallExpr struct {
+		Fun  Expr
+		Args []Expr
+	}
+
+	// ThisExpr is the keyword `this`, the object a method is called on.
+	ThisExpr struct {
+		Token Token
+	}
+
+	// SuperExpr is the keyword `super`, the object a method is called on
+	// seen as an instance of its superclass.
+	SuperExpr struct {
+		Token Token
+	}
+
+	// MemberExpr is the access to a member of a struct or class: `X.Member`.
+	MemberExpr struct {
+		X      Expr
+		Member *Ident
+	}
+)
+
+func (f *File) Pos() Pos {
+	if len(f.Decls) == 0 {
+		return Pos{Line: 1, Col: 1}
+	}
+	return f.Decls[0].Pos()
+}
+
+func (d *VarDecl) Pos() Pos       { return d.Type.Pos() }
+func (d *FuncDecl) Pos() Pos      { return d.Type.Pos() }
+func (p *Param) Pos() Pos         { return p.Type.Pos() }
+func (d *StructDecl) Pos() Pos    { return d.Struct }
+func (d *ClassDecl) Pos() Pos     { return d.Class }
+func (d *NamespaceDecl) Pos() Pos { return d.Namespace }
+func (d *ImportDecl) Pos() Pos    { return d.Import }
+func (s *Block) Pos() Pos         { return s.Lbrace }
+func (s *IfStmt) Pos() Pos        { return s.If }
+func (s *WhileStmt) Pos() Pos     { return s.While }
+func (s *ReturnStmt) Pos() Pos    { return s.Return }
+func (s *AssignStmt) Pos() Pos    { return s.Target.Pos() }
+func (s *ExprStmt) Pos() Pos      { return s.X.Pos() }
+func (x *Ident) Pos() Pos         { return x.Token.Pos }
+func (x *IntLit) Pos() Pos        { return x.Token.Pos }
+func (x *FloatLit) Pos() Pos      { return x.Token.Pos }
+func (x *CharLit) Pos() Pos       { return x.Token.Pos }
+func (x *StringLit) Pos() Pos     { return x.Token.Pos }
+func (x *BoolLit) Pos() Pos       { return x.Token.Pos }
+func (x *BinaryExpr) Pos() Pos    { return x.X.Pos() }
+func (x *UnaryExpr) Pos() Pos     { return x.Op.Pos }
+func (x *CallExpr) Pos() Pos      { return x.Fun.Pos() }
+func (x *ThisExpr) Pos() Pos      { return x.Token.Pos }
+func (x *SuperExpr) Pos() Pos     { return x.Token.Pos }
+func (x *MemberExpr) Pos() Pos    { return x.X.Pos() }
+
+func (*VarDecl) declNode()       {}
+func (*FuncDecl) declNode()      {}
+func (*StructDecl) declNode()    {}
+func (*ClassDecl) declNode()     {}
+func (*NamespaceDecl) declNode() {}
+func (*ImportDecl) declNode()    {}
+
+func (*VarDecl) stmtNode()    {}
+func (*StructDecl) stmtNode() {}
+func (*Block) stmtNode()      {}
+func (*IfStmt) stmtNode()     {}
+func (*WhileStmt) stmtNode()  {}
+func (*ReturnStmt) stmtNode() {}
+func (*AssignStmt) stmtNode() {}
+func (*ExprStmt) stmtNode()   {}
+
+func (*Ident) exprNode()      {}
+func (*IntLit) exprNode()     {}
+func (*FloatLit) exprNode()   {}
+func (*CharLit) exprNode()    {}
+func (*StringLit) exprNode()  {}
+func (*BoolLit) exprNode()    {}
+func (*BinaryExpr) exprNode() {}
+func (*UnaryExpr) exprNode()  {}
+func (*CallExpr) exprNode()   {}
+func (*ThisExpr) exprNode()   {}
+func (*SuperExpr) exprNode()  {}
+func (*MemberExpr) exprNode() {}
+
+func (f *File) String() string {
+	decls := make([]string, len(f.Decls))
+	for i, d := range f.Decls {
+		decls[i] = d.String()
+	}
+	return strings.Join(decls, "\n")
+}
+
+func (d *VarDecl) String() string {
+	if d.Value == nil {
+		return fmt.Sprintf("%v %v;", d.Type, d.Name)
+	}
+	return fmt.Sprintf("%v %v = %v;", d.Type, d.Name, d.Value)
+}
+
+func (d *FuncDecl) String() string {
+	params := make([]string, len(d.Params))
+	for i, p := range d.Params {
+		params[i] = p.String()
+	}
+	return fmt.Sprintf("%v %v(%s) %v", d.Type, d.Name, strings.Join(params, ", "), d.Body)
+}
+
+func (p *Param) String() string {
+	return fmt.Sprintf("%v %v", p.Type, p.Name)
+}
+
+func (d *StructDecl) String() string {
+	fields := make([]string, len(d.Fields))
+	for i, f := range d.Fields {
+		fields[i] = f.String()
+	}
+	return fmt.Sprintf("struct %v { %s };", d.Name, strings.Join(fields, " "))
+}
+
+func (d *ClassDecl) String() string {
+	members := make([]string, len(d.Members))
+	for i, m := range d.Members {
+		members[i] = m.String()
+	}
+	super := ""
+	if d.Super != nil {
+		super = " : " + d.Super.String()
+	}
+	if len(members) == 0 {
+		return fmt.Sprintf("class %v%s { };", d.Name, super)
+	}
+	return fmt.Sprintf("class %v%s { %s };", d.Name, super, strings.Join(members, " "))
+}
+
+func (d *NamespaceDecl) String() string {
+	if len(d.Decls) == 0 {
+		return fmt.Sprintf("namespace %v { }", d.Name)
+	}
+	decls := make([]string, len(d.Decls))
+	for i, decl := range d.Decls {
+		decls[i] = decl.String()
+	}
+	return fmt.Sprintf("namespace %v { %s }", d.Name, strings.Join(decls, " "))
+}
+
+func (d *ImportDecl) String() string {
+	return fmt.Sprintf("import %v;", d.Name)
+}
+
+func (s *Block) String() string {
+	if len(s.Stmts) == 0 {
+		return "{ }"
+	}
+	stmts := make([]string, len(s.Stmts))
+	for i, stmt := range s.Stmts {
+		stmts[i] = stmt.String()
+	}
+	return "{ " + strings.Join(stmts, " ") + " }"
+}
+
+func (s *IfStmt) String() string {
+	if s.Else == nil {
+		return fmt.Sprintf("if (%v) %v", s.Cond, s.Then)
+	}
+	return fmt.Sprintf("if (%v) %v else %v", s.Cond, s.Then, s.Else)
+}
+
+func (s *WhileStmt) String() string {
+	return fmt.Sprintf("while (%v) %v", s.Cond, s.Body)
+}
+
+func (s *ReturnStmt) String() string {
+	if s.Value == nil {
+		return "return;"
+	}
+	return fmt.Sprintf("return %v;", s.Value)
+}
+
+func (s *AssignStmt) String() string {
+	return fmt.Sprintf("%v = %v;", s.Target, s.Value)
+}
+
+func (s *ExprStmt) String() string {
+	return s.X.String() + ";"
+}
+
+func (x *Ident) String() string     { return x.Name }
+func (x *IntLit) String() string    { return x.Token.Text }
+func (x *FloatLit) String() string  { return x.Token.Text }
+func (x *CharLit) String() string   { return x.Token.Text }
+func (x *StringLit) String() string { return x.Token.Text }
+func (x *BoolLit) String() string   { return x.Token.Text }
+func (x *ThisExpr) String() string  { return x.Token.Text }
+func (x *SuperExpr) String() string { return x.Token.Text }
+
+func (x *BinaryExpr) String() string {
+	return fmt.Sprintf("(%v %s %v)", x.X, x.Op.Text, x.Y)
+}
+
+func (x *UnaryExpr) String() string {
+	return fmt.Sprintf("(%s%v)", x.Op.Text, x.X)
+}
+
+func (x *CallExpr) String() string {
+	args := make([]string, len(x.Args))
+	for i, a := range x.Args {
+		args[i] = a.String()
+	}
+	return fmt.Sprintf("%v(%s)", x.Fun, strings.Join(args, ", "))
+}
+
+func (x *MemberExpr) String() string {
+	return fmt.Sprintf("%v.%v", x.X, x.Member)
+}
@@ -0,0 +1,62 @@
+package cymbol
+
+import "iter"
+
+// Attributes
+//
+// The passes that run on a tree compute things about its nodes: the scope a
+// block opens, the type of an expression, its value if it's a constant. Rather
+// than adding a field to the nodes for each of them, which would tie the tree
+// to every pass that uses it, each pass keeps what it computes in side tables
+// keyed by node, the way the book's ANTLR trees are decorated through maps or
+// generic fields. An Attr is one such table:
+//
+//	var types Attr[symtab.Type]
+//	types.Set(x, intType)
+//	types.Get(x) // intType, or nil if x hasn't one
+
+// Attr is an attribute of type T of the nodes of a tree, set for some of
+// them. The zero value is an empty attribute ready to use.
+type Attr[T any] struct {
+	values map[Node]T
+}
+
+// Get returns the value of the attribute for n, the zero value of T if it
+// hasn't one.
+func (a *Attr[T]) Get(n Node) T {
+	return a.values[n]
+}
+
+// Lookup returns the value of the attribute for n and whether it has one.
+func (a *Attr[T]) Lookup(n Node) (T, bool) {
+	v, ok := a.values[n]
+	return v, ok
+}
+
+// Set sets the value of the attribute for n.
+func (a *Attr[T]) Set(n Node, v T) {
+	if a.values == nil {
+		a.values = map[Node]T{}
+	}
+	a.values[n] = v
+}
+
+// Delete removes the value of the attribute for n, if it has one.
+func (a *Attr[T]) Delete(n Node) {
+	delete(a.values, n)
+}
+
+// Len returns the number of nodes the attribute is set for.
+func (a *Attr[T]) Len() int { return len(a.values) }
+
+// All returns the nodes the attribute is set for with their values, in no
+// particular order.
+func (a *Attr[T]) All() iter.Seq2[Node, T] {
+	return func(yield func(Node, T) bool) {
+		for n, v := range a.values {
+			if !yield(n, v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package cymbol
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestAttr(t *testing.T) {
+	x, err := ParseExpr("a + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := x.(*BinaryExpr)
+
+	var values Attr[int64]
+	if got := values.Get(b); got != 0 {
+		t.Errorf("want: 0, got: %d", got)
+	}
+	if _, ok := values.Lookup(b); ok {
+		t.Error("want no value before setting one")
+	}
+
+	values.Set(b.Y, 1)
+	values.Set(b, 0)
+	if got := values.Get(b.Y); got != 1 {
+		t.Errorf("want: 1, got: %d", got)
+	}
+	if got, ok := values.Lookup(b); !ok || got != 0 {
+		t.Errorf("want: 0 set, got: %d, %v", got, ok)
+	}
+	if _, ok := values.Lookup(b.X); ok {
+		t.Error("want no value for a")
+	}
+	if got := maps.Collect(values.All()); len(got) != 2 || got[b.Y] != 1 || values.Len() != 2 {
+		t.Errorf("want the values of 1 and a + 1, got: %v", got)
+	}
+
+	values.Delete(b)
+	if _, ok := values.Lookup(b); ok || values.Len() != 1 {
+		t.Error("want the value deleted")
+	}
+}
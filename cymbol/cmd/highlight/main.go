@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"example.com/cymbol"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+// run highlights the Cymbol source files given as arguments, or the standard
+// input if there are none, with terminal colors:
+//
+//	highlight [-html] prog.cym
+//
+// With -html each file is written as HTML instead, a pre element whose
+// spans have the class of their style, to color with a style sheet.
+func run(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("highlight", flag.ContinueOnError)
+	asHTML := fs.Bool("html", false, "write HTML spans instead of terminal colors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	write := cymbol.WriteANSI
+	if *asHTML {
+		write = cymbol.WriteHTML
+	}
+	if fs.NArg() == 0 {
+		src, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		return write(out, cymbol.Highlight(string(src)))
+	}
+	for _, name := range fs.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := write(out, cymbol.Highlight(string(src))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	var s strings.Builder
+	if err := run(nil, strings.NewReader("int x; // x\n"), &s); err != nil {
+		t.Fatal(err)
+	}
+	want := "\x1b[36mint\x1b[0m x; \x1b[90m// x\x1b[0m\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	name := filepath.Join(t.TempDir(), "prog.cym")
+	if err := os.WriteFile(name, []byte("return 1;"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	s.Reset()
+	if err := run([]string{"-html", name}, nil, &s); err != nil {
+		t.Fatal(err)
+	}
+	want = `<pre class="cymbol"><span class="keyword">return</span> <span class="number">1</span>;</pre>` + "\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	if err := run([]string{"missing.cym"}, nil, &s); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
@@ -0,0 +1,117 @@
+package cymbol
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+// Diagnostics
+//
+// The parser stops at the first syntax error, but the passes that run on the
+// tree afterwards, resolving names or checking types, can find any number of
+// problems and should report them all rather than give up on the first one.
+// They add them to a Diagnostics collector, which is also where a parse error
+// goes, so a program gets one report sorted by position whatever found the
+// problems:
+//
+//	1:9: undefined: y
+//	2:5: x redeclared in this scope
+
+// Error is an error found at a position of the source. Syntax errors and
+// lexer errors are Errors, errors.Is(err, SyntaxError) tells them apart.
+type Error struct {
+	Pos Pos
+	Err error
+	eof bool // found at the end of the input, see Incomplete
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("%v: %v", e.Pos, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+func errorAt(pos Pos, format string, args ...any) error {
+	return &Error{Pos: pos, Err: fmt.Errorf(format, args...)}
+}
+
+// Span is the part of the source from From up to, but not including, To.
+// An empty span is a single position.
+type Span struct {
+	From Pos `json:"from"`
+	To   Pos `json:"to"`
+}
+
+// Contains tells whether p is in the span.
+func (s Span) Contains(p Pos) bool {
+	return comparePos(s.From, p) <= 0 && comparePos(p, s.To) < 0
+}
+
+// Span returns the part of the source taken by the token.
+func (t Token) Span() Span {
+	to := t.Pos
+	to.Col += utf8.RuneCountInString(t.Text)
+	return Span{From: t.Pos, To: to}
+}
+
+// Diagnostic is a problem found in the source.
+type Diagnostic struct {
+	Span    Span
+	Message string
+	err     error // the error the diagnostic was made from, if any
+}
+
+func (d *Diagnostic) Error() string { return fmt.Sprintf("%v: %s", d.Span.From, d.Message) }
+func (d *Diagnostic) Unwrap() error { return d.err }
+
+// Diagnostics collects the diagnostics of all the phases. The zero value is
+// ready to use.
+type Diagnostics struct {
+	list []Diagnostic
+}
+
+// Add records a diagnostic for span.
+func (ds *Diagnostics) Add(span Span, format string, args ...any) {
+	ds.list = append(ds.list, Diagnostic{Span: span, Message: fmt.Sprintf(format, args...)})
+}
+
+// AddError records err, as returned by ParseFile or ParseExpr. An error
+// without a position is recorded at the zero Pos.
+func (ds *Diagnostics) AddError(err error) {
+	d := Diagnostic{Message: err.Error(), err: err}
+	var e *Error
+	if errors.As(err, &e) {
+		d.Span = Span{From: e.Pos, To: e.Pos}
+		d.Message = e.Err.Error()
+	}
+	ds.list = append(ds.list, d)
+}
+
+// Len returns how many diagnostics there are.
+func (ds *Diagnostics) Len() int { return len(ds.list) }
+
+// List returns the diagnostics sorted by position, the ones at the same
+// position in the order they were added.
+func (ds *Diagnostics) List() []Diagnostic {
+	list := slices.Clone(ds.list)
+	slices.SortStableFunc(list, func(a, b Diagnostic) int {
+		return comparePos(a.Span.From, b.Span.From)
+	})
+	return list
+}
+
+// Err returns the diagnostics as a single error, one per line, or nil if
+// there are none.
+func (ds *Diagnostics) Err() error {
+	var errs []error
+	for _, d := range ds.List() {
+		errs = append(errs, &d)
+	}
+	return errors.Join(errs...)
+}
+
+func comparePos(a, b Pos) int {
+	if a.Line != b.Line {
+		return a.Line - b.Line
+	}
+	return a.Col - b.Col
+}
@@ -0,0 +1,50 @@
+package cymbol
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	var ds Diagnostics
+	if err := ds.Err(); err != nil {
+		t.Errorf("want: nil, got: %v", err)
+	}
+
+	_, err := ParseFile("int x;\nint y = 2 3;")
+	ds.AddError(err)
+	ds.Add(Token{Text: "z", Pos: Pos{Line: 1, Col: 5}}.Span(), "undefined: %s", "z")
+	ds.Add(Span{From: Pos{Line: 2, Col: 11}}, "second at the same position")
+
+	want := "1:5: undefined: z\n2:11: syntax error: expecting Semicolon, found Int\n2:11: second at the same position"
+	if got := ds.Err(); got == nil || got.Error() != want {
+		t.Errorf("want: %q, got: %v", want, got)
+	}
+	if !errors.Is(ds.Err(), SyntaxError) {
+		t.Error("want the syntax error to be kept")
+	}
+	first := ds.List()[0]
+	if want := (Span{From: Pos{Line: 1, Col: 5}, To: Pos{Line: 1, Col: 6}}); first.Span != want {
+		t.Errorf("want: %v, got: %v", want, first.Span)
+	}
+}
+
+func TestSpanContains(t *testing.T) {
+	s := Token{Text: "xy", Pos: Pos{Line: 2, Col: 3}}.Span()
+	tests := []struct {
+		pos  Pos
+		want bool
+	}{
+		{Pos{Line: 2, Col: 2}, false},
+		{Pos{Line: 2, Col: 3}, true},
+		{Pos{Line: 2, Col: 4}, true},
+		{Pos{Line: 2, Col: 5}, false},
+		{Pos{Line: 1, Col: 4}, false},
+		{Pos{Line: 3, Col: 1}, false},
+	}
+	for _, tt := range tests {
+		if got := s.Contains(tt.pos); got != tt.want {
+			t.Errorf("%v contains %v: want: %v, got: %v", s, tt.pos, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package cymbol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Token dumps
+//
+// A dump is a token stream as text made to be diffed: one token per line,
+// with its position, type and text, in columns of fixed width so that a
+// token that changes doesn't realign the lines of the others:
+//
+//	1:1     ID         "int"
+//	1:5     ID         "x"
+//	1:7     Assign     "="
+//	1:9     String     "\"a\tb\""
+//	1:14    Semicolon  ";"
+//	1:15    EOF        ""
+//
+// The text, quotes of strings and chars included, is quoted as in Go so that
+// tabs and the like show, and each line says all there is about its token. A
+// change to the lexer then shows in the diff of the dumps of golden files as
+// the lines of the tokens that changed, and nothing else. ParseDump reads a
+// dump back.
+
+// WriteDump writes the dump of toks.
+func WriteDump(w io.Writer, toks []Token) error {
+	bw := bufio.NewWriter(w)
+	for _, tok := range toks {
+		fmt.Fprintf(bw, "%-7v %-10v %s\n", tok.Pos, tok.Type, strconv.Quote(tok.Text))
+	}
+	return bw.Flush()
+}
+
+// Dump lexes src and returns the dump of its tokens, up to EOF or to the
+// lexer error, which is returned too.
+func Dump(src string) (string, error) {
+	var toks []Token
+	var err error
+	lex := NewLexer(src)
+	for {
+		var tok Token
+		if tok, err = lex.Next(); err != nil {
+			break
+		}
+		toks = append(toks, tok)
+		if tok.Type == EOF {
+			break
+		}
+	}
+	var s strings.Builder
+	WriteDump(&s, toks)
+	return s.String(), err
+}
+
+// tokenTypes are the token types by name, for ParseDump
+var tokenTypes = func() map[string]TokenType {
+	types := map[string]TokenType{}
+	for typ, name := range tokenNames {
+		types[name] = typ
+	}
+	return types
+}()
+
+// ParseDump reads the tokens of a dump. Blank lines are skipped.
+func ParseDump(dump string) ([]Token, error) {
+	var toks []Token
+	for i, line := range strings.Split(dump, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		tok, err := parseDumpLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("dump line %d: %w", i+1, err)
+		}
+		toks = append(toks, tok)
+	}
+	return toks, nil
+}
+
+func parseDumpLine(line string) (Token, error) {
+	pos, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	typ, text, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	var tok Token
+	if _, err := fmt.Sscanf(pos, "%d:%d", &tok.Pos.Line, &tok.Pos.Col); err != nil {
+		return Token{}, fmt.Errorf("invalid position %q", pos)
+	}
+	t, ok := tokenTypes[typ]
+	if !ok {
+		return Token{}, fmt.Errorf("invalid token type %q", typ)
+	}
+	tok.Type = t
+	s, err := strconv.Unquote(strings.TrimSpace(text))
+	if err != nil {
+		return Token{}, fmt.Errorf("invalid text %s", text)
+	}
+	tok.Text = s
+	return tok, nil
+}
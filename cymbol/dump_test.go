@@ -0,0 +1,83 @@
+package cymbol
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+func TestDump(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/tokens.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, file := range ar.Files {
+		files[file.Name] = string(file.Data)
+	}
+
+	for _, file := range ar.Files {
+		name, ok := strings.CutSuffix(file.Name, ".cym")
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			got, err := Dump(string(file.Data))
+			if diff := cmp.Diff(files[name+".tokens"], got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error() + "\n"
+			}
+			if want := files[name+".err"]; gotErr != want {
+				t.Errorf("got error %q, want %q", gotErr, want)
+			}
+
+			// and the dump reads back as the tokens
+			toks, err := ParseDump(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s strings.Builder
+			if err := WriteDump(&s, toks); err != nil {
+				t.Fatal(err)
+			}
+			if s.String() != got {
+				t.Errorf("dump of the dump read back:\n%s", s.String())
+			}
+		})
+	}
+}
+
+func TestParseDump(t *testing.T) {
+	toks, err := ParseDump("1:1     ID         \"int\"\n\n1:4 String \"\\\"a b\\\"\"\n1:11 EOF \"\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Token{
+		{Type: ID, Text: "int", Pos: Pos{1, 1}},
+		{Type: String, Text: `"a b"`, Pos: Pos{1, 4}},
+		{Type: EOF, Pos: Pos{1, 11}},
+	}
+	if diff := cmp.Diff(want, toks); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	cases := []struct {
+		dump string
+		want string
+	}{
+		{dump: "1 ID \"x\"", want: `dump line 1: invalid position "1"`},
+		{dump: "1:1 ID \"x\"\n1:2 Id \"y\"", want: `dump line 2: invalid token type "Id"`},
+		{dump: "1:1 ID x", want: "dump line 1: invalid text x"},
+		{dump: "1:1 ID", want: "dump line 1: invalid text "},
+	}
+	for _, tc := range cases {
+		if _, err := ParseDump(tc.dump); err == nil || err.Error() != tc.want {
+			t.Errorf("%q: got error %v, want %s", tc.dump, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package cymbol
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Formatting
+//
+// Format lays a program out the one way, the way the book writes Cymbol:
+//
+//	int fact(int n) {              int fact(int n){if(n<2)return 1;
+//	    if (n < 2) return 1;       return n*fact(n-1);}
+//	    return n * fact(n - 1);
+//	}
+//
+// Printing the tree would do most of it, but the tree has no comments, and
+// no parentheses but the ones it puts around every expression. So Format
+// works on the tokens, with the comments in the trivia between them: it
+// puts a line break after each statement, declaration and opening brace,
+// indents by four spaces for each brace the line is in, and spaces the
+// tokens of a line the same way everywhere. The comments stay where they
+// were, at the end of a line or on lines of their own, and so do blank
+// lines, at most one in a row. The parentheses stay as they were written.
+
+const indentation = "    "
+
+// Format returns the formatted source of the Cymbol program src, or its
+// syntax error.
+func Format(src string) (string, error) {
+	if _, err := ParseFile(src); err != nil {
+		return "", err
+	}
+	ts, err := NewTokenStream(src)
+	if err != nil {
+		return "", err
+	}
+	f := &formatter{start: true}
+	toks := ts.Tokens
+	for i, tok := range toks {
+		f.trivia(ts.Trivia(i), tok.Type == EOF)
+		if tok.Type == EOF {
+			break
+		}
+		var prev TokenType = EOF
+		if i > 0 {
+			prev = toks[i-1].Type
+		}
+		f.token(prev, tok, toks[i+1].Type)
+	}
+	return f.out.String(), nil
+}
+
+type formatter struct {
+	out    bytes.Buffer
+	depth  int  // braces the line is in
+	parens int  // parentheses open
+	start  bool // at the start of a line
+	broken bool // the line was ended after a token, not a comment
+	blank  bool // a blank line is due before the next line
+	unary  bool // the last token is a unary minus
+}
+
+// newline ends the line, if it has anything on it.
+func (f *formatter) newline() {
+	if !f.start {
+		f.out.WriteString("\n")
+		f.start = true
+	}
+}
+
+// begin starts a line, with a blank one before it if one's due.
+func (f *formatter) begin() {
+	if f.blank && f.out.Len() > 0 {
+		f.out.WriteString("\n")
+	}
+	f.blank = false
+	f.out.WriteString(strings.Repeat(indentation, f.depth))
+	f.start = false
+}
+
+// trivia writes the comments of the trivia before a token, and remembers the
+// blank lines in it.
+func (f *formatter) trivia(s string, eof bool) {
+	for {
+		i := strings.Index(s, "//")
+		if i < 0 {
+			break
+		}
+		lines := strings.Count(s[:i], "\n")
+		if lines > 1 {
+			f.blank = true
+		}
+		switch {
+		case lines == 0 && f.broken:
+			// back to the end of the line of the token before
+			f.out.Truncate(f.out.Len() - 1)
+			f.out.WriteString(" ")
+			f.start = false
+		case lines == 0 && !f.start:
+			f.out.WriteString(" ")
+		default:
+			f.newline()
+			f.begin()
+		}
+		f.broken = false
+		end := strings.IndexByte(s[i:], '\n')
+		if end < 0 {
+			end = len(s) - i
+		}
+		f.out.WriteString(strings.TrimRight(s[i:i+end], " \t\r"))
+		f.newline()
+		s = s[i+end:]
+	}
+	if strings.Count(s, "\n") > 1 && !eof {
+		f.blank = true
+	}
+	if eof {
+		f.newline()
+	}
+}
+
+// token writes tok, which comes after a token of type prev and before one of
+// type next.
+func (f *formatter) token(prev TokenType, tok Token, next TokenType) {
+	switch tok.Type {
+	case LBrace:
+		f.depth++
+	case RBrace:
+		f.depth--
+	}
+	switch {
+	case f.start:
+		if tok.Type == LBrace {
+			f.depth--
+			f.begin()
+			f.depth++
+		} else {
+			f.begin()
+		}
+	case spaced(prev, f.unary, tok.Type):
+		f.out.WriteString(" ")
+	}
+	f.out.WriteString(tok.Text)
+	f.unary = tok.Type == Minus && !operand(prev)
+	f.broken = false
+
+	switch tok.Type {
+	case LParen:
+		f.parens++
+	case RParen:
+		f.parens--
+	case Semicolon:
+		f.broken = f.parens == 0
+	case LBrace:
+		f.broken = next != RBrace
+	case RBrace:
+		f.broken = next != Semicolon && next != Else
+	}
+	if f.broken {
+		f.newline()
+	}
+}
+
+// spaced tells whether there's a space between two tokens on a line, unary
+// telling whether prev is a unary minus.
+func spaced(prev TokenType, unary bool, cur TokenType) bool {
+	switch prev {
+	case LParen, Dot, Not:
+		return false
+	}
+	switch cur {
+	case Comma, Semicolon, RParen, Dot:
+		return false
+	case LParen:
+		// calls and declarations of functions, but if (, while ( and the
+		// operands of operators
+		return prev != ID && prev != RParen
+	}
+	return !unary
+}
+
+// operand tells whether a token of type typ ends an operand, so that a minus
+// after it is binary.
+func operand(typ TokenType) bool {
+	switch typ {
+	case ID, Int, Float, Char, String, True, False, This, Super, RParen:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package cymbol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  "",
+		},
+		{
+			name:  "function",
+			input: "int fact(int n){if(n<2)return 1;\nreturn n*fact(n-1);}",
+			want:  "int fact(int n) {\n    if (n < 2) return 1;\n    return n * fact(n - 1);\n}\n",
+		},
+		{
+			name:  "comments and blank lines",
+			input: "// x\nint x; // one\n\n\n\n// y\n  int y;   \n// end",
+			want:  "// x\nint x; // one\n\n// y\nint y;\n// end\n",
+		},
+		{
+			name:  "comments after braces",
+			input: "void f() { // body\n  { } // empty\n} // f\n",
+			want:  "void f() { // body\n    { } // empty\n} // f\n",
+		},
+		{
+			name:  "structs and classes",
+			input: "struct p { int x; struct q { float y; }; }; class B : A { void f() { } };",
+			want:  "struct p {\n    int x;\n    struct q {\n        float y;\n    };\n};\nclass B : A {\n    void f() { }\n};\n",
+		},
+		{
+			name:  "operators",
+			input: "void f() { x = -1 - -a * (b+c); y = !(a<b) == true; z = g(a,-b)(c).d; }",
+			want:  "void f() {\n    x = -1 - -a * (b + c);\n    y = !(a < b) == true;\n    z = g(a, -b)(c).d;\n}\n",
+		},
+		{
+			name:  "if and else",
+			input: "void f() { if (a) { g(); } else if (b) g(); else { } while (c) { } }",
+			want:  "void f() {\n    if (a) {\n        g();\n    } else if (b) g();\n    else { }\n    while (c) { }\n}\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Format(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Error(cmp.Diff(tc.want, got))
+			}
+			again, err := Format(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != got {
+				t.Errorf("formatting again changes it:\n%s", cmp.Diff(got, again))
+			}
+		})
+	}
+
+	if _, err := Format("int x"); err == nil || err.Error() != "1:6: syntax error: expecting Semicolon, found EOF" {
+		t.Errorf("got error %v", err)
+	}
+}
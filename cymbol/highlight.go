@@ -0,0 +1,191 @@
+package cymbol
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Syntax highlighting
+//
+// A highlighter only needs the lexer: each token gets a style after its type,
+// and so do the comments, which are in the trivia between the tokens.
+// Highlight splits the source into pieces of text with a style each, all of
+// it, whitespace included, so that the pieces put back together are the
+// source. WriteANSI and WriteHTML then render them, with terminal colors or
+// as HTML spans to style with CSS:
+//
+//	int x = 1; // one    <span class="type">int</span> x <span class="operator">=</span> ...
+//
+// The built-in type names are identifiers to the lexer, they're highlighted
+// as types all the same, as editors do. The lexer stops at the first error,
+// the rest of the source is then a single Invalid piece: a highlighter sees
+// programs being typed, with strings not closed yet.
+
+// Style is how a piece of the source is shown.
+type Style int
+
+const (
+	Plain Style = iota // identifiers, punctuation and whitespace
+	Keyword
+	TypeName
+	Number
+	Literal // chars and strings
+	Operator
+	Comment
+	Invalid
+)
+
+var styleNames = map[Style]string{
+	Plain:    "plain",
+	Keyword:  "keyword",
+	TypeName: "type",
+	Number:   "number",
+	Literal:  "literal",
+	Operator: "operator",
+	Comment:  "comment",
+	Invalid:  "invalid",
+}
+
+func (s Style) String() string { return styleNames[s] }
+
+// Piece is a piece of the source and its style.
+type Piece struct {
+	Style Style
+	Text  string
+}
+
+// builtinTypes are the names of the built-in types.
+var builtinTypes = map[string]bool{
+	"int": true, "float": true, "char": true, "boolean": true, "string": true, "void": true,
+}
+
+// Highlight splits src into styled pieces, in order. Consecutive pieces have
+// different styles.
+func Highlight(src string) []Piece {
+	var h highlighter
+	ts, err := scan(src)
+	end := 0
+	for i, tok := range ts.Tokens {
+		h.trivia(ts.Trivia(i))
+		h.add(tokenStyle(tok), tok.Text)
+		end = ts.end(i)
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		// from the start of the token the lexer failed on
+		at := ts.offset(e.Pos)
+		h.trivia(src[end:at])
+		h.add(Invalid, src[at:])
+	}
+	return h.pieces
+}
+
+func tokenStyle(tok Token) Style {
+	switch tok.Type {
+	case ID:
+		if builtinTypes[tok.Text] {
+			return TypeName
+		}
+	case Int, Float:
+		return Number
+	case Char, String:
+		return Literal
+	case Assign, Plus, Minus, Star, Slash, Not, Eq, Ne, Lt, Le, Gt, Ge:
+		return Operator
+	default:
+		if _, ok := keywords[tok.Text]; ok {
+			return Keyword
+		}
+	}
+	return Plain
+}
+
+// highlighter gathers the pieces, merging the ones of the same style.
+type highlighter struct {
+	pieces []Piece
+}
+
+func (h *highlighter) add(style Style, text string) {
+	if text == "" {
+		return
+	}
+	if n := len(h.pieces); n > 0 && h.pieces[n-1].Style == style {
+		h.pieces[n-1].Text += text
+		return
+	}
+	h.pieces = append(h.pieces, Piece{style, text})
+}
+
+// trivia adds whitespace and the comments in it, which go to the end of the
+// line.
+func (h *highlighter) trivia(s string) {
+	for s != "" {
+		i := strings.Index(s, "//")
+		if i < 0 {
+			h.add(Plain, s)
+			return
+		}
+		h.add(Plain, s[:i])
+		s = s[i:]
+		j := strings.IndexByte(s, '\n')
+		if j < 0 {
+			j = len(s)
+		}
+		h.add(Comment, s[:j])
+		s = s[j:]
+	}
+}
+
+// ansiColors are the SGR parameters of the styles, Plain has none.
+var ansiColors = map[Style]string{
+	Keyword:  "1;34", // bold blue
+	TypeName: "36",   // cyan
+	Number:   "35",   // magenta
+	Literal:  "32",   // green
+	Operator: "33",   // yellow
+	Comment:  "90",   // gray
+	Invalid:  "4;31", // underlined red
+}
+
+// WriteANSI writes the pieces with the escape sequences of terminal colors.
+// A style doesn't go past the end of a line, so that the output can be paged.
+func WriteANSI(w io.Writer, pieces []Piece) error {
+	var s strings.Builder
+	for _, p := range pieces {
+		color, ok := ansiColors[p.Style]
+		if !ok {
+			s.WriteString(p.Text)
+			continue
+		}
+		for i, line := range strings.Split(p.Text, "\n") {
+			if i > 0 {
+				s.WriteString("\n")
+			}
+			if line != "" {
+				fmt.Fprintf(&s, "\x1b[%sm%s\x1b[0m", color, line)
+			}
+		}
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// WriteHTML writes the pieces as a pre element of class cymbol, each piece
+// but the plain ones in a span whose class is its style.
+func WriteHTML(w io.Writer, pieces []Piece) error {
+	var s strings.Builder
+	s.WriteString(`<pre class="cymbol">`)
+	for _, p := range pieces {
+		if p.Style == Plain {
+			s.WriteString(html.EscapeString(p.Text))
+			continue
+		}
+		fmt.Fprintf(&s, `<span class="%s">%s</span>`, p.Style, html.EscapeString(p.Text))
+	}
+	s.WriteString("</pre>\n")
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -0,0 +1,96 @@
+package cymbol
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []Piece
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "declaration",
+			input: "int x = 1; // one\n",
+			want: []Piece{
+				{TypeName, "int"}, {Plain, " x "}, {Operator, "="}, {Plain, " "}, {Number, "1"},
+				{Plain, "; "}, {Comment, "// one"}, {Plain, "\n"},
+			},
+		},
+		{
+			name:  "keywords and literals",
+			input: "if (!b) return \"a\" + 'c';",
+			want: []Piece{
+				{Keyword, "if"}, {Plain, " ("}, {Operator, "!"}, {Plain, "b) "}, {Keyword, "return"},
+				{Plain, " "}, {Literal, `"a"`}, {Plain, " "}, {Operator, "+"}, {Plain, " "}, {Literal, "'c'"},
+				{Plain, ";"},
+			},
+		},
+		{
+			name:  "comments",
+			input: "// a\n// b\nx/y",
+			want: []Piece{
+				{Comment, "// a"}, {Plain, "\n"}, {Comment, "// b"}, {Plain, "\nx"}, {Operator, "/"}, {Plain, "y"},
+			},
+		},
+		{
+			name:  "unterminated string",
+			input: "x = 2; // two\n s = \"abc\nint y;",
+			want: []Piece{
+				{Plain, "x "}, {Operator, "="}, {Plain, " "}, {Number, "2"}, {Plain, "; "}, {Comment, "// two"},
+				{Plain, "\n s "}, {Operator, "="}, {Plain, " "}, {Invalid, "\"abc\nint y;"},
+			},
+		},
+		{
+			name:  "invalid character",
+			input: "x # y",
+			want:  []Piece{{Plain, "x "}, {Invalid, "# y"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Highlight(tc.input)
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(tc.want, got))
+			}
+			var s strings.Builder
+			for _, p := range got {
+				s.WriteString(p.Text)
+			}
+			if s.String() != tc.input {
+				t.Errorf("pieces are %q, want %q", s.String(), tc.input)
+			}
+		})
+	}
+}
+
+func TestWriteHighlight(t *testing.T) {
+	pieces := Highlight("// <x>\nbool b = x < \"&\";")
+	var s strings.Builder
+	if err := WriteHTML(&s, pieces); err != nil {
+		t.Fatal(err)
+	}
+	want := `<pre class="cymbol"><span class="comment">// &lt;x&gt;</span>` + "\n" +
+		`bool b <span class="operator">=</span> x <span class="operator">&lt;</span> <span class="literal">&#34;&amp;&#34;</span>;</pre>` + "\n"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	s.Reset()
+	if err := WriteANSI(&s, Highlight("while (x) y = \"a\nb\";")); err != nil {
+		t.Fatal(err)
+	}
+	want = "\x1b[1;34mwhile\x1b[0m (x) y \x1b[33m=\x1b[0m \x1b[4;31m\"a\x1b[0m\n\x1b[4;31mb\";\x1b[0m"
+	if got := s.String(); got != want {
+		t.Error(cmp.Diff(want, got))
+	}
+}
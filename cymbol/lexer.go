@@ -0,0 +1,425 @@
+package cymbol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cymbol is the small C-like language the book uses from chapter 6 onwards to
+// illustrate symbol tables, type checking and interpretation. It has functions,
+// variables, structs, classes, if/while statements and expressions on ints,
+// floats, chars, booleans and strings:
+//
+//	int fact(int n) {
+//	    if (n < 2) return 1;
+//	    return n * fact(n - 1);
+//	}
+//
+// The lexer works the same way as the list language ones in chapter2 and
+// chapter3: it looks at the current rune and calls the lexical rule that
+// matches it. Unlike those, every token records its position in the input so
+// errors can point at the offending line.
+//
+// Built-in type names such as int or float are not keywords, they're lexed as
+// identifiers and resolved like any other name. That's how the book does it:
+// built-in types are symbols defined in the global scope.
+
+type Token struct {
+	Type TokenType
+	Text string
+	Pos  Pos
+}
+
+// Pos is a position in the input, both line and column start at 1.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// MarshalText makes the position line:col in JSON, as it's printed.
+func (p Pos) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// Before tells whether p comes before q in the input.
+func (p Pos) Before(q Pos) bool { return comparePos(p, q) < 0 }
+
+type TokenType int
+
+// Token types
+const (
+	EOF TokenType = iota
+	ID
+	Int
+	Float
+	Char
+	String
+
+	// keywords
+	If
+	Else
+	While
+	Return
+	True
+	False
+	Struct
+	Class
+	This
+	Super
+	Namespace
+	Import
+
+	// punctuation and operators
+	LParen
+	RParen
+	LBrace
+	RBrace
+	Comma
+	Semicolon
+	Colon
+	Dot
+	Assign
+	Plus
+	Minus
+	Star
+	Slash
+	Not
+	Eq
+	Ne
+	Lt
+	Le
+	Gt
+	Ge
+)
+
+var tokenNames = map[TokenType]string{
+	EOF:       "EOF",
+	ID:        "ID",
+	Int:       "Int",
+	Float:     "Float",
+	Char:      "Char",
+	String:    "String",
+	If:        "If",
+	Else:      "Else",
+	While:     "While",
+	Return:    "Return",
+	True:      "True",
+	False:     "False",
+	Struct:    "Struct",
+	Class:     "Class",
+	This:      "This",
+	Super:     "Super",
+	Namespace: "Namespace",
+	Import:    "Import",
+	LParen:    "LParen",
+	RParen:    "RParen",
+	LBrace:    "LBrace",
+	RBrace:    "RBrace",
+	Comma:     "Comma",
+	Semicolon: "Semicolon",
+	Colon:     "Colon",
+	Dot:       "Dot",
+	Assign:    "Assign",
+	Plus:      "Plus",
+	Minus:     "Minus",
+	Star:      "Star",
+	Slash:     "Slash",
+	Not:       "Not",
+	Eq:        "Eq",
+	Ne:        "Ne",
+	Lt:        "Lt",
+	Le:        "Le",
+	Gt:        "Gt",
+	Ge:        "Ge",
+}
+
+func (t TokenType) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+var keywords = map[string]TokenType{
+	"if":        If,
+	"else":      Else,
+	"while":     While,
+	"return":    Return,
+	"true":      True,
+	"false":     False,
+	"struct":    Struct,
+	"class":     Class,
+	"this":      This,
+	"super":     Super,
+	"namespace": Namespace,
+	"import":    Import,
+}
+
+// Lexer goes through the input rune by rune and produces Tokens.
+type Lexer struct {
+	input   []rune // entire input
+	pos     int    // current position index in the input
+	current rune   // current rune
+	line    int    // line of the current rune
+	col     int    // column of the current rune
+}
+
+// marks the end of input
+var eof = rune(-1)
+
+func NewLexer(input string) *Lexer {
+	lex := &Lexer{input: []rune(input), line: 1, col: 1, current: eof}
+	if len(lex.input) > 0 {
+		lex.current = lex.input[0]
+	}
+	return lex
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// Next returns the next Token at each invocation or an error if the input
+// cannot be recognized. Once the input is over it keeps returning EOF.
+func (lex *Lexer) Next() (Token, error) {
+	for lex.current != eof {
+		start := Pos{Line: lex.line, Col: lex.col}
+		switch lex.current {
+		case ' ', '\t', '\n', '\r':
+			lex.consume()
+			continue
+		case '/':
+			lex.consume()
+			if lex.current == '/' {
+				lex.comment()
+				continue
+			}
+			return Token{Type: Slash, Text: "/", Pos: start}, nil
+		case '(':
+			return lex.single(LParen), nil
+		case ')':
+			return lex.single(RParen), nil
+		case '{':
+			return lex.single(LBrace), nil
+		case '}':
+			return lex.single(RBrace), nil
+		case ',':
+			return lex.single(Comma), nil
+		case ';':
+			return lex.single(Semicolon), nil
+		case ':':
+			return lex.single(Colon), nil
+		case '.':
+			return lex.single(Dot), nil
+		case '+':
+			return lex.single(Plus), nil
+		case '-':
+			return lex.single(Minus), nil
+		case '*':
+			return lex.single(Star), nil
+		case '=':
+			return lex.oneOrTwo(Assign, '=', Eq), nil
+		case '!':
+			return lex.oneOrTwo(Not, '=', Ne), nil
+		case '<':
+			return lex.oneOrTwo(Lt, '=', Le), nil
+		case '>':
+			return lex.oneOrTwo(Gt, '=', Ge), nil
+		case '\'':
+			return lex.char()
+		case '"':
+			return lex.str()
+		default:
+			if isLetter(lex.current) {
+				return lex.ident(), nil
+			}
+			if isDigit(lex.current) {
+				return lex.number()
+			}
+			return Token{}, errorAt(start, "invalid character: %q", lex.current)
+		}
+	}
+	return Token{Type: EOF, Pos: Pos{Line: lex.line, Col: lex.col}}, nil
+}
+
+// single matches a token made of only the current rune
+func (lex *Lexer) single(typ TokenType) Token {
+	tok := Token{Type: typ, Text: string(lex.current), Pos: Pos{Line: lex.line, Col: lex.col}}
+	lex.consume()
+	return tok
+}
+
+// oneOrTwo matches operators such as `<` and `<=` that share their first rune,
+// producing the longest one.
+func (lex *Lexer) oneOrTwo(one TokenType, next rune, two TokenType) Token {
+	tok := lex.single(one)
+	if lex.current == next {
+		tok.Type = two
+		tok.Text += string(next)
+		lex.consume()
+	}
+	return tok
+}
+
+// comment skips everything up to the end of the line, the first '/' has
+// already been consumed.
+func (lex *Lexer) comment() {
+	for lex.current != '\n' && lex.current != eof {
+		lex.consume()
+	}
+}
+
+// Lexical rule ID, also matches keywords.
+//
+// ID : ('a'..'z'|'A'..'Z'|'_') ('a'..'z'|'A'..'Z'|'_'|'0'..'9')* ;
+func (lex *Lexer) ident() Token {
+	start := Pos{Line: lex.line, Col: lex.col}
+	var s strings.Builder
+	for isLetter(lex.current) || isDigit(lex.current) {
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+	text := s.String()
+	if typ, ok := keywords[text]; ok {
+		return Token{Type: typ, Text: text, Pos: start}
+	}
+	return Token{Type: ID, Text: text, Pos: start}
+}
+
+// Lexical rules INT and FLOAT.
+//
+// INT   : ('0'..'9')+ ;
+// FLOAT : ('0'..'9')+ '.' ('0'..'9')+ ;
+func (lex *Lexer) number() (Token, error) {
+	start := Pos{Line: lex.line, Col: lex.col}
+	var s strings.Builder
+	lex.digits(&s)
+	if lex.current != '.' {
+		return Token{Type: Int, Text: s.String(), Pos: start}, nil
+	}
+	s.WriteRune(lex.current)
+	lex.consume()
+	if !isDigit(lex.current) {
+		return Token{}, errorAt(start, "malformed number: %s", s.String())
+	}
+	lex.digits(&s)
+	return Token{Type: Float, Text: s.String(), Pos: start}, nil
+}
+
+func (lex *Lexer) digits(s *strings.Builder) {
+	for isDigit(lex.current) {
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+}
+
+// Lexical rule CHAR. As with strings the token text is the literal as written,
+// quotes and escapes included.
+//
+//	CHAR : '\'' (ESC | ~('\''|'\\'|'\n')) '\'' ;
+func (lex *Lexer) char() (Token, error) {
+	start := Pos{Line: lex.line, Col: lex.col}
+	var s strings.Builder
+	s.WriteRune(lex.current) // opening quote
+	lex.consume()
+	if err := lex.quoted(&s, '\''); err != nil {
+		return Token{}, errorAt(start, "%w", err)
+	}
+	if lex.current != '\'' {
+		return Token{}, errorAt(start, "char literal must be a single character")
+	}
+	s.WriteRune(lex.current) // closing quote
+	lex.consume()
+	return Token{Type: Char, Text: s.String(), Pos: start}, nil
+}
+
+// Lexical rule STRING.
+//
+//	STRING : '"' (ESC | ~('"'|'\\'|'\n'))* '"' ;
+func (lex *Lexer) str() (Token, error) {
+	start := Pos{Line: lex.line, Col: lex.col}
+	var s strings.Builder
+	s.WriteRune(lex.current) // opening quote
+	lex.consume()
+	for lex.current != '"' {
+		if err := lex.quoted(&s, '"'); err != nil {
+			return Token{}, errorAt(start, "%w", err)
+		}
+	}
+	s.WriteRune(lex.current) // closing quote
+	lex.consume()
+	return Token{Type: String, Text: s.String(), Pos: start}, nil
+}
+
+// escapes are the characters of the escape sequences, after the backslash,
+// and what they stand for. Both kinds of quotes can be escaped in both kinds
+// of literals, which Go doesn't allow, so literals are decoded with unquote.
+var escapes = map[rune]rune{'\'': '\'', '"': '"', '\\': '\\', 'n': '\n', 't': '\t'}
+
+// quoted matches a single, possibly escaped, character inside quotes.
+//
+//	ESC : '\\' ('\''|'"'|'\\'|'n'|'t') ;
+func (lex *Lexer) quoted(s *strings.Builder, quote rune) error {
+	switch lex.current {
+	case eof, '\n':
+		return fmt.Errorf("unterminated %c literal", quote)
+	case quote:
+		return fmt.Errorf("empty %c literal", quote)
+	case '\\':
+		s.WriteRune(lex.current)
+		lex.consume()
+		if _, ok := escapes[lex.current]; !ok {
+			return fmt.Errorf("invalid escape sequence: \\%c", lex.current)
+		}
+	}
+	s.WriteRune(lex.current)
+	lex.consume()
+	return nil
+}
+
+// unquote returns the characters of the text of a CHAR or STRING token, with
+// the quotes removed and the escape sequences replaced.
+func unquote(text string) string {
+	var s strings.Builder
+	escaped := false
+	for _, r := range text[1 : len(text)-1] {
+		switch {
+		case escaped:
+			s.WriteRune(escapes[r])
+			escaped = false
+		case r == '\\':
+			escaped = true
+		default:
+			s.WriteRune(r)
+		}
+	}
+	return s.String()
+}
+
+// consume moves the current position forward by one, keeping track of lines
+// and columns, and saves the next current rune.
+func (lex *Lexer) consume() {
+	if lex.current == '\n' {
+		lex.line++
+		lex.col = 1
+	} else {
+		lex.col++
+	}
+
+	lex.pos++
+	if lex.pos >= len(lex.input) {
+		// signals end of input
+		lex.current = eof
+	} else {
+		// saves the next rune
+		lex.current = lex.input[lex.pos]
+	}
+}
@@ -0,0 +1,178 @@
+package cymbol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLexerGoodInput(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []Token
+	}{
+		{
+			name:  "empty input",
+			input: "",
+			want:  []Token{{Type: EOF, Pos: Pos{1, 1}}},
+		},
+		{
+			name:  "declaration",
+			input: "int x = 1;",
+			want: []Token{
+				{Type: ID, Text: "int", Pos: Pos{1, 1}},
+				{Type: ID, Text: "x", Pos: Pos{1, 5}},
+				{Type: Assign, Text: "=", Pos: Pos{1, 7}},
+				{Type: Int, Text: "1", Pos: Pos{1, 9}},
+				{Type: Semicolon, Text: ";", Pos: Pos{1, 10}},
+				{Type: EOF, Pos: Pos{1, 11}},
+			},
+		},
+		{
+			name:  "keywords and operators",
+			input: "if (a <= b) return !c; else while (d != e) f = g == h;",
+			want: []Token{
+				{Type: If, Text: "if", Pos: Pos{1, 1}},
+				{Type: LParen, Text: "(", Pos: Pos{1, 4}},
+				{Type: ID, Text: "a", Pos: Pos{1, 5}},
+				{Type: Le, Text: "<=", Pos: Pos{1, 7}},
+				{Type: ID, Text: "b", Pos: Pos{1, 10}},
+				{Type: RParen, Text: ")", Pos: Pos{1, 11}},
+				{Type: Return, Text: "return", Pos: Pos{1, 13}},
+				{Type: Not, Text: "!", Pos: Pos{1, 20}},
+				{Type: ID, Text: "c", Pos: Pos{1, 21}},
+				{Type: Semicolon, Text: ";", Pos: Pos{1, 22}},
+				{Type: Else, Text: "else", Pos: Pos{1, 24}},
+				{Type: While, Text: "while", Pos: Pos{1, 29}},
+				{Type: LParen, Text: "(", Pos: Pos{1, 35}},
+				{Type: ID, Text: "d", Pos: Pos{1, 36}},
+				{Type: Ne, Text: "!=", Pos: Pos{1, 38}},
+				{Type: ID, Text: "e", Pos: Pos{1, 41}},
+				{Type: RParen, Text: ")", Pos: Pos{1, 42}},
+				{Type: ID, Text: "f", Pos: Pos{1, 44}},
+				{Type: Assign, Text: "=", Pos: Pos{1, 46}},
+				{Type: ID, Text: "g", Pos: Pos{1, 48}},
+				{Type: Eq, Text: "==", Pos: Pos{1, 50}},
+				{Type: ID, Text: "h", Pos: Pos{1, 53}},
+				{Type: Semicolon, Text: ";", Pos: Pos{1, 54}},
+				{Type: EOF, Pos: Pos{1, 55}},
+			},
+		},
+		{
+			name:  "literals",
+			input: `1 2.5 'a' '\n' "hi \"there\"" true false`,
+			want: []Token{
+				{Type: Int, Text: "1", Pos: Pos{1, 1}},
+				{Type: Float, Text: "2.5", Pos: Pos{1, 3}},
+				{Type: Char, Text: "'a'", Pos: Pos{1, 7}},
+				{Type: Char, Text: `'\n'`, Pos: Pos{1, 11}},
+				{Type: String, Text: `"hi \"there\""`, Pos: Pos{1, 16}},
+				{Type: True, Text: "true", Pos: Pos{1, 31}},
+				{Type: False, Text: "false", Pos: Pos{1, 36}},
+				{Type: EOF, Pos: Pos{1, 41}},
+			},
+		},
+		{
+			name:  "structs",
+			input: "struct s { int x; }; s.x",
+			want: []Token{
+				{Type: Struct, Text: "struct", Pos: Pos{1, 1}},
+				{Type: ID, Text: "s", Pos: Pos{1, 8}},
+				{Type: LBrace, Text: "{", Pos: Pos{1, 10}},
+				{Type: ID, Text: "int", Pos: Pos{1, 12}},
+				{Type: ID, Text: "x", Pos: Pos{1, 16}},
+				{Type: Semicolon, Text: ";", Pos: Pos{1, 17}},
+				{Type: RBrace, Text: "}", Pos: Pos{1, 19}},
+				{Type: Semicolon, Text: ";", Pos: Pos{1, 20}},
+				{Type: ID, Text: "s", Pos: Pos{1, 22}},
+				{Type: Dot, Text: ".", Pos: Pos{1, 23}},
+				{Type: ID, Text: "x", Pos: Pos{1, 24}},
+				{Type: EOF, Pos: Pos{1, 25}},
+			},
+		},
+		{
+			name:  "classes",
+			input: "class B : A { }; this.x; super.f",
+			want: []Token{
+				{Type: Class, Text: "class", Pos: Pos{1, 1}},
+				{Type: ID, Text: "B", Pos: Pos{1, 7}},
+				{Type: Colon, Text: ":", Pos: Pos{1, 9}},
+				{Type: ID, Text: "A", Pos: Pos{1, 11}},
+				{Type: LBrace, Text: "{", Pos: Pos{1, 13}},
+				{Type: RBrace, Text: "}", Pos: Pos{1, 15}},
+				{Type: Semicolon, Text: ";", Pos: Pos{1, 16}},
+				{Type: This, Text: "this", Pos: Pos{1, 18}},
+				{Type: Dot, Text: ".", Pos: Pos{1, 22}},
+				{Type: ID, Text: "x", Pos: Pos{1, 23}},
+				{Type: Semicolon, Text: ";", Pos: Pos{1, 24}},
+				{Type: Super, Text: "super", Pos: Pos{1, 26}},
+				{Type: Dot, Text: ".", Pos: Pos{1, 31}},
+				{Type: ID, Text: "f", Pos: Pos{1, 32}},
+				{Type: EOF, Pos: Pos{1, 33}},
+			},
+		},
+		{
+			name:  "lines and comments",
+			input: "a // comment / here\n  b / c\n",
+			want: []Token{
+				{Type: ID, Text: "a", Pos: Pos{1, 1}},
+				{Type: ID, Text: "b", Pos: Pos{2, 3}},
+				{Type: Slash, Text: "/", Pos: Pos{2, 5}},
+				{Type: ID, Text: "c", Pos: Pos{2, 7}},
+				{Type: EOF, Pos: Pos{3, 1}},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer(tc.input)
+			var tokens []Token
+			for {
+				tok, err := l.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tokens = append(tokens, tok)
+				if tok.Type == EOF {
+					break
+				}
+			}
+			if !cmp.Equal(tokens, tc.want) {
+				t.Error(cmp.Diff(tokens, tc.want))
+			}
+		})
+	}
+}
+
+func TestLexerBadInput(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{name: "invalid character", input: "int x = 1 # 2;"},
+		{name: "malformed float", input: "1."},
+		{name: "unterminated string", input: `"abc`},
+		{name: "string across lines", input: "\"a\nb\""},
+		{name: "empty char", input: "''"},
+		{name: "long char", input: "'ab'"},
+		{name: "invalid escape", input: `'\x'`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer(tc.input)
+			for {
+				tok, err := l.Next()
+				if err != nil {
+					t.Log(err)
+					return
+				}
+				if tok.Type == EOF {
+					t.Fatal("want: error, got: nil")
+				}
+			}
+		})
+	}
+}
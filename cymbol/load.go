@@ -0,0 +1,117 @@
+package cymbol
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Loading programs
+//
+// A program too big for one file is split in several, which all declare
+// their globals in the same global scope: a function of one file calls the
+// ones of the others, whatever file they're in. Load links the files into one
+// program by putting their text one after the other, in the order of their
+// names, so that the same files always make the same program: the symbol
+// table, the type checks and the interpreter work on it as on any other, and
+// the SourceMap of the program takes their errors back to the files.
+//
+// Each file is parsed on its own first, so that a syntax error is the one of
+// its file, not of the text of the next one read as the rest of it. A global
+// declared in two files is an error of its own, with both locations, rather
+// than the redeclaration the symbol table would find in the text:
+//
+//	b.cym:1:5: x redeclared, first declared at a.cym:1:5
+//
+// A name declared twice in the same file is left to the symbol table.
+
+// Program is a program loaded from several files.
+type Program struct {
+	Files []string // their names, sorted
+	Text  string   // theirs, in that order
+	File  *File    // the tree of Text
+	SourceMap
+}
+
+// Load parses and links the files of a program, their texts by name. The
+// errors, one per line, are LocatedErrors: the syntax errors of the files,
+// and the globals declared in more than one, in a SourceError with the files.
+func Load(files map[string]string) (*Program, error) {
+	if len(files) == 0 {
+		return nil, errors.New("load: no files")
+	}
+	p := &Program{Files: slices.Sorted(maps.Keys(files))}
+	var text strings.Builder
+	var errs []error
+	line := 1
+	for _, name := range p.Files {
+		src := files[name]
+		if _, err := ParseFile(src); err != nil {
+			errs = append(errs, located(name, err))
+		}
+		p.anchors = append(p.anchors, anchor{at: Pos{Line: line, Col: 1}, loc: Location{File: name, Pos: Pos{Line: 1, Col: 1}}})
+		if !strings.HasSuffix(src, "\n") {
+			src += "\n"
+		}
+		text.WriteString(src)
+		line += strings.Count(src, "\n")
+	}
+	if len(errs) > 0 {
+		return nil, &SourceError{Sources: files, Err: errors.Join(errs...)}
+	}
+	p.Text = text.String()
+	f, err := ParseFile(p.Text)
+	if err != nil {
+		// the files parse on their own and end with a newline, their
+		// declarations one after the other parse too
+		return nil, &SourceError{Sources: files, Err: p.Error(err)}
+	}
+	p.File = f
+	if err := p.redeclared(); err != nil {
+		return nil, &SourceError{Sources: files, Err: err}
+	}
+	return p, nil
+}
+
+// located returns err, of ParseFile, at its location in the file name.
+func located(name string, err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return &LocatedError{Location{File: name, Pos: e.Pos}, e.Err}
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// redeclared returns the globals declared in more than one file.
+func (p *Program) redeclared() error {
+	first := map[string]Location{}
+	var errs []error
+	for _, d := range p.File.Decls {
+		var name *Ident
+		switch d := d.(type) {
+		case *VarDecl:
+			name = d.Name
+		case *FuncDecl:
+			name = d.Name
+		case *StructDecl:
+			name = d.Name
+		case *ClassDecl:
+			name = d.Name
+		case *NamespaceDecl:
+			name = d.Name
+		default:
+			continue // imports declare nothing
+		}
+		loc := p.Locate(name.Pos())
+		prev, ok := first[name.Name]
+		switch {
+		case !ok:
+			first[name.Name] = loc
+		case prev.File != loc.File:
+			errs = append(errs, &LocatedError{loc, fmt.Errorf("%s redeclared, first declared at %v", name.Name, prev)})
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package cymbol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad(t *testing.T) {
+	p, err := Load(map[string]string{
+		"main.cym":  "void main() { print(area(square(2))); }",
+		"shape.cym": "struct rect { float w; float h; };\n\nrect square(float s) {\n    rect r; r.w = s; r.h = s;\n    return r;\n} // the last line\n",
+		"area.cym":  "float area(rect r) { return r.w * r.h; }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"area.cym", "main.cym", "shape.cym"}; !cmp.Equal(p.Files, want) {
+		t.Errorf("files: %s", cmp.Diff(want, p.Files))
+	}
+	var got []string
+	for _, d := range p.File.Decls {
+		got = append(got, p.Locate(d.Pos()).String())
+	}
+	if want := []string{"area.cym:1:1", "main.cym:1:1", "shape.cym:1:1", "shape.cym:3:1"}; !cmp.Equal(got, want) {
+		t.Errorf("declarations: %s", cmp.Diff(want, got))
+	}
+	if got, want := p.Locate(Pos{Line: 6, Col: 16}).String(), "shape.cym:4:16"; got != want {
+		t.Errorf("r.w: got %s, want %s", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "syntax errors",
+			files: map[string]string{
+				"a.cym": "int x",
+				"b.cym": "int y;\nvoid f() { y = ; }",
+				"c.cym": "int z;",
+			},
+			want: "a.cym:1:6: syntax error: expecting Semicolon, found EOF\nb.cym:2:16: syntax error: expecting expression, found Semicolon",
+		},
+		{
+			name: "redeclared",
+			files: map[string]string{
+				"a.cym": "int x;\nvoid f() { }\nstruct s { int x; };",
+				"b.cym": "float x;\nint y;",
+				"c.cym": "class s { };\nint y;\nvoid f() { }",
+			},
+			want: "b.cym:1:7: x redeclared, first declared at a.cym:1:5\nc.cym:1:7: s redeclared, first declared at a.cym:3:8\n" +
+				"c.cym:2:5: y redeclared, first declared at b.cym:2:5\nc.cym:3:6: f redeclared, first declared at a.cym:2:6",
+		},
+		{
+			name:  "redeclared in a file",
+			files: map[string]string{"a.cym": "int x; int x;", "b.cym": ""},
+		},
+		{
+			name: "no files",
+			want: "load: no files",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.files)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("got error %v, want %s", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package cymbol
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Source maps
+//
+// A program can be made of more than one file, and its text then isn't any
+// of them: the preprocessor includes files and expands macros, and a program
+// loaded from several files is the text of all of them one after the other.
+// The lexer and the parser only see that text, so the positions of the
+// tokens, the nodes and the errors are in it. A SourceMap takes them back to
+// the files: each part of the text is anchored at the location in a file it
+// comes from, and the columns after an anchor, or the lines, follow the ones
+// of that file up to the next anchor.
+
+// Location is a position in a named file.
+type Location struct {
+	File string
+	Pos  Pos
+}
+
+func (l Location) String() string { return fmt.Sprintf("%s:%v", l.File, l.Pos) }
+
+// LocatedError is an error at a location of one of the files of a program.
+type LocatedError struct {
+	Loc Location
+	Err error
+}
+
+func (e *LocatedError) Error() string { return fmt.Sprintf("%v: %v", e.Loc, e.Err) }
+func (e *LocatedError) Unwrap() error { return e.Err }
+
+// SourceMap maps the positions of a text to the locations in the files it
+// comes from. The zero value maps nothing, Locate returns the positions with
+// no file.
+type SourceMap struct {
+	anchors []anchor // by position in the text
+}
+
+// anchor maps the text from at on to loc, column by column on its line,
+// until the next anchor. A macro expansion all maps to the use of the macro.
+type anchor struct {
+	at        Pos
+	loc       Location
+	expansion bool
+}
+
+// Locate returns where pos, a position in the text, comes from.
+func (m *SourceMap) Locate(pos Pos) Location {
+	// the last anchor at pos or before it, an empty expansion is followed
+	// by an anchor at the same position
+	i, _ := slices.BinarySearchFunc(m.anchors, pos, func(a anchor, p Pos) int {
+		if comparePos(a.at, p) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	if i == 0 {
+		return Location{Pos: pos}
+	}
+	a := m.anchors[i-1]
+	loc := a.loc
+	switch {
+	case a.at.Line != pos.Line:
+		// the anchor of a whole file, or the end of the text
+		loc.Pos = Pos{Line: loc.Pos.Line + pos.Line - a.at.Line, Col: pos.Col}
+	case !a.expansion:
+		loc.Pos.Col += pos.Col - a.at.Col
+	}
+	return loc
+}
+
+// Error takes err, an error of ParseFile or the Err of Diagnostics, back to
+// the files it comes from: the errors at a position are LocatedErrors, one
+// per line as in Diagnostics.Err.
+func (m *SourceMap) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, m.Error(e))
+		}
+		return errors.Join(errs...)
+	}
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return &LocatedError{m.Locate(d.Span.From), errors.New(d.Message)}
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return &LocatedError{m.Locate(e.Pos), e.Err}
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package cymbol
+
+import "strings"
+
+// Outline
+//
+// The outline of a program is its declarations as a tree, the way an editor
+// shows them beside the source to jump around it: the functions, variables,
+// structs, classes and namespaces at the top level, and under each the
+// declarations inside it, the fields and methods of a class, the variables
+// of a function, in the blocks at any depth, and all the declarations of a
+// namespace. Imports declare nothing, they aren't in the outline:
+//
+//	int x = 1;                  var x int
+//	class A {                   class A
+//	    int y;                      field y int
+//	    void f() { int z; }         method f void ()
+//	};                                  var z int
+//
+// Each symbol has two spans, all of the declaration and its name, for the
+// editor to select the name when jumping to the declaration. The spans come
+// from the tokens, the tree only has where a node starts.
+
+// SymbolKind is what a symbol of the outline declares.
+type SymbolKind int
+
+const (
+	SymbolVariable SymbolKind = iota
+	SymbolFunction
+	SymbolStruct
+	SymbolClass
+	SymbolField
+	SymbolMethod
+	SymbolNamespace
+)
+
+var symbolKindNames = map[SymbolKind]string{
+	SymbolVariable:  "var",
+	SymbolFunction:  "func",
+	SymbolStruct:    "struct",
+	SymbolClass:     "class",
+	SymbolField:     "field",
+	SymbolMethod:    "method",
+	SymbolNamespace: "namespace",
+}
+
+func (k SymbolKind) String() string { return symbolKindNames[k] }
+
+// Symbol is a declaration of the outline, with the ones inside it as
+// children. Detail is what's declared besides the name: the type of a
+// variable, the signature of a function, the superclass of a class.
+type Symbol struct {
+	Name     string
+	Detail   string
+	Kind     SymbolKind
+	Span     Span // the whole declaration
+	NameSpan Span
+	Children []Symbol
+}
+
+// Outline returns the outline of f, which ts is the tokens of.
+func Outline(ts *TokenStream, f *File) []Symbol {
+	return decls(ts, f.Decls, []Symbol{})
+}
+
+// decls appends to syms the symbols of the declarations ds, but imports.
+func decls(ts *TokenStream, ds []Decl, syms []Symbol) []Symbol {
+	for _, d := range ds {
+		if _, ok := d.(*ImportDecl); !ok {
+			syms = append(syms, outline(ts, d, false))
+		}
+	}
+	return syms
+}
+
+// outline returns the symbol of d, a member of a struct or class if member
+// is set.
+func outline(ts *TokenStream, d Decl, member bool) Symbol {
+	first, last := ts.Extent(d)
+	s := Symbol{Span: Span{From: ts.Tokens[first].Pos, To: ts.Tokens[last].Span().To}}
+	var name *Ident
+	switch d := d.(type) {
+	case *VarDecl:
+		name, s.Detail, s.Kind = d.Name, d.Type.Name, SymbolVariable
+		if member {
+			s.Kind = SymbolField
+		}
+	case *FuncDecl:
+		params := make([]string, len(d.Params))
+		for i, p := range d.Params {
+			params[i] = p.String()
+		}
+		name, s.Kind = d.Name, SymbolFunction
+		s.Detail = d.Type.Name + " (" + strings.Join(params, ", ") + ")"
+		if member {
+			s.Kind = SymbolMethod
+		}
+		s.Children = locals(ts, d.Body.Stmts, nil)
+	case *StructDecl:
+		name, s.Kind = d.Name, SymbolStruct
+		for _, f := range d.Fields {
+			s.Children = append(s.Children, outline(ts, f, true))
+		}
+	case *ClassDecl:
+		name, s.Kind = d.Name, SymbolClass
+		if d.Super != nil {
+			s.Detail = ": " + d.Super.Name
+		}
+		for _, m := range d.Members {
+			s.Children = append(s.Children, outline(ts, m, true))
+		}
+	case *NamespaceDecl:
+		name, s.Kind = d.Name, SymbolNamespace
+		s.Children = decls(ts, d.Decls, nil)
+	}
+	s.Name = name.Name
+	s.NameSpan = name.Token.Span()
+	return s
+}
+
+// locals appends to syms the symbols of the declarations in stmts and in the
+// blocks nested in them.
+func locals(ts *TokenStream, stmts []Stmt, syms []Symbol) []Symbol {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *VarDecl:
+			syms = append(syms, outline(ts, stmt, false))
+		case *StructDecl:
+			syms = append(syms, outline(ts, stmt, false))
+		case *Block:
+			syms = locals(ts, stmt.Stmts, syms)
+		case *IfStmt:
+			syms = locals(ts, []Stmt{stmt.Then}, syms)
+			if stmt.Else != nil {
+				syms = locals(ts, []Stmt{stmt.Else}, syms)
+			}
+		case *WhileStmt:
+			syms = locals(ts, []Stmt{stmt.Body}, syms)
+		}
+	}
+	return syms
+}
@@ -0,0 +1,100 @@
+package cymbol
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// lines renders an outline one symbol per line, the children indented.
+func lines(syms []Symbol, indent string) []string {
+	var ls []string
+	for _, s := range syms {
+		ls = append(ls, fmt.Sprintf("%s%v-%v %v %s %s %v", indent, s.Span.From, s.Span.To, s.Kind, s.Name, s.Detail, s.NameSpan.From))
+		ls = append(ls, lines(s.Children, indent+"  ")...)
+	}
+	return ls
+}
+
+func TestOutline(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "empty",
+			input: "// nothing\n",
+		},
+		{
+			name:  "globals",
+			input: "int x = 1;\nfloat f(float a, int b) { return a; }\n",
+			want: []string{
+				"1:1-1:11 var x int 1:5",
+				"2:1-2:38 func f float (float a, int b) 2:7",
+			},
+		},
+		{
+			name:  "members",
+			input: "struct p { int x; struct q { int y; }; };\nclass B : A {\n    int z;\n    void g() { }\n};",
+			want: []string{
+				"1:1-1:42 struct p  1:8",
+				"  1:12-1:18 field x int 1:16",
+				"  1:19-1:39 struct q  1:26",
+				"    1:30-1:36 field y int 1:34",
+				"2:1-5:3 class B : A 2:7",
+				"  3:5-3:11 field z int 3:9",
+				"  4:5-4:17 method g void () 4:10",
+			},
+		},
+		{
+			name:  "locals",
+			input: "void f() {\n    int a;\n    if (a) { int b; } else { while (a) { float c = 1.0; } }\n    { struct s { int d; }; }\n}",
+			want: []string{
+				"1:1-5:2 func f void () 1:6",
+				"  2:5-2:11 var a int 2:9",
+				"  3:14-3:20 var b int 3:18",
+				"  3:42-3:56 var c float 3:48",
+				"  4:7-4:27 struct s  4:14",
+				"    4:18-4:24 field d int 4:22",
+			},
+		},
+		{
+			name:  "spans in runes",
+			input: "string s = \"😀\"; int t;",
+			want: []string{
+				"1:1-1:16 var s string 1:8",
+				"1:17-1:23 var t int 1:21",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ParseFile(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts, err := NewTokenStream(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := lines(Outline(ts, f), "")
+			if !cmp.Equal(got, tc.want) {
+				t.Error(cmp.Diff(got, tc.want))
+			}
+		})
+	}
+}
+
+func TestSymbolKindString(t *testing.T) {
+	var names []string
+	for k := SymbolVariable; k <= SymbolNamespace; k++ {
+		names = append(names, k.String())
+	}
+	if got, want := strings.Join(names, " "), "var func struct class field method namespace"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,520 @@
+package cymbol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// LL(k) Recursive-Descent Parser for Cymbol, the same pattern as chapter2's
+// LLkParser with a bigger grammar.
+
+// Grammar to be parsed (ANTLR syntax):
+//
+//	grammar Cymbol;
+//	file       : decl* EOF ;
+//	decl       : namespaceDecl | importDecl | classDecl | structDecl | funcDecl | varDecl ;
+//	namespaceDecl : 'namespace' ID '{' decl* '}' ;
+//	importDecl : 'import' ID ';' ;
+//	classDecl  : 'class' ID (':' ID)? '{' member* '}' ';' ;
+//	member     : funcDecl | type ID ';' ;
+//	structDecl : 'struct' ID '{' field+ '}' ';' ;
+//	field      : structDecl | type ID ';' ;
+//	funcDecl   : type ID '(' params? ')' block ;
+//	params     : param (',' param)* ;
+//	param      : type ID ;
+//	varDecl    : type ID ('=' expr)? ';' ;
+//	type       : ID ;                            // int, float, ... are identifiers
+//	block      : '{' stmt* '}' ;
+//	stmt       : block
+//	           | structDecl
+//	           | varDecl
+//	           | 'if' '(' expr ')' stmt ('else' stmt)?
+//	           | 'while' '(' expr ')' stmt
+//	           | 'return' expr? ';'
+//	           | expr ('=' expr)? ';'            // assignment or call
+//	           ;
+//	expr       : equality ;
+//	equality   : relational (('=='|'!=') relational)* ;
+//	relational : additive (('<'|'<='|'>'|'>=') additive)* ;
+//	additive   : mult (('+'|'-') mult)* ;
+//	mult       : unary (('*'|'/') unary)* ;
+//	unary      : ('-'|'!') unary | postfix ;
+//	postfix    : primary ('(' args? ')' | '.' ID)* ;
+//	args       : expr (',' expr)* ;
+//	primary    : ID | INT | FLOAT | CHAR | STRING | 'true' | 'false'
+//	           | 'this' | 'super'
+//	           | '(' expr ')'
+//	           ;
+//
+// ParseInput parses the input of a REPL, declarations and statements in any
+// order, the last of which can be an expression without a semicolon:
+//
+//	input      : (namespaceDecl | importDecl | classDecl | funcDecl | stmt)* expr? EOF ;
+//
+// The expression rules have one rule per precedence level, lowest first, so
+// that `1 + 2 * 3` parses as `1 + (2 * 3)`.
+//
+// How much lookahead do we need? Declarations and statements need the most:
+//
+// * `int x;` and `int f() {}` both start with `ID ID`, only the third token
+//   tells a variable from a function declaration
+// * `int x;` and `x = 1;` both start with ID, the second token tells a
+//   declaration from an assignment
+//
+// so k = 3.
+
+const k = 3 // lookahead depth
+
+var SyntaxError = errors.New("syntax error")
+
+// Parser is an LL(k) parser. On the first error it panics, ParseFile and
+// ParseExpr recover and return the error.
+type Parser struct {
+	input *Lexer
+	buf   []Token // circular lookahead buffer
+	k     int     // how many lookahead symbols (length of the buffer)
+	pos   int     // circular index of next token position to fill
+}
+
+func NewParser(l *Lexer) *Parser {
+	p := &Parser{input: l, buf: make([]Token, k), k: k}
+
+	// initialize the buffer with first k tokens
+	for range p.k {
+		p.consume()
+	}
+	return p
+}
+
+// ParseFile parses a whole Cymbol program.
+func ParseFile(src string) (f *File, err error) {
+	defer bailout(&err)
+	p := NewParser(NewLexer(src))
+	return p.file(), nil
+}
+
+// ParseExpr parses a single expression.
+func ParseExpr(src string) (x Expr, err error) {
+	defer bailout(&err)
+	p := NewParser(NewLexer(src))
+	x = p.expr()
+	p.match(EOF)
+	return x, nil
+}
+
+// ParseInput parses what's entered at a REPL prompt: any number of
+// declarations and statements. The nodes are Decls and Stmts, VarDecls and
+// StructDecls being both.
+func ParseInput(src string) (nodes []Node, err error) {
+	defer bailout(&err)
+	p := NewParser(NewLexer(src))
+	for p.lookahead(1).Type != EOF {
+		nodes = append(nodes, p.item())
+	}
+	return nodes, nil
+}
+
+// Incomplete tells whether err is a syntax error found at the end of the
+// input, which more input could fix: a REPL keeps reading lines until a
+// declaration or statement spanning several of them is complete.
+func Incomplete(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.eof
+}
+
+// bailout recovers from the panic raised on the first error and returns it in
+// err instead. Panics that aren't errors are bugs and keep going.
+func bailout(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(error)
+		if !ok {
+			panic(r)
+		}
+		*err = e
+	}
+}
+
+func (p *Parser) file() *File {
+	f := &File{}
+	for p.lookahead(1).Type != EOF {
+		f.Decls = append(f.Decls, p.decl())
+	}
+	p.match(EOF)
+	return f
+}
+
+func (p *Parser) decl() Decl {
+	switch p.lookahead(1).Type {
+	case Namespace:
+		return p.namespaceDecl()
+	case Import:
+		return p.importDecl()
+	case Class:
+		return p.classDecl()
+	case Struct:
+		return p.structDecl()
+	}
+	if p.lookahead(3).Type == LParen {
+		return p.funcDecl()
+	}
+	return p.varDecl()
+}
+
+// item is a declaration or a statement of a REPL's input.
+func (p *Parser) item() Node {
+	first, second := p.lookahead(1), p.lookahead(2)
+	switch {
+	case first.Type == Namespace:
+		return p.namespaceDecl()
+	case first.Type == Import:
+		return p.importDecl()
+	case first.Type == Class:
+		return p.classDecl()
+	case first.Type == ID && second.Type == ID && p.lookahead(3).Type == LParen:
+		return p.funcDecl()
+	case first.Type == ID && second.Type == ID:
+		return p.stmt()
+	}
+	switch first.Type {
+	case LBrace, Struct, If, While, Return:
+		return p.stmt()
+	}
+	return p.simpleStmt(true)
+}
+
+func (p *Parser) funcDecl() *FuncDecl {
+	d := &FuncDecl{Type: p.ident(), Name: p.ident()}
+	p.match(LParen)
+	if p.lookahead(1).Type != RParen {
+		d.Params = p.params()
+	}
+	p.match(RParen)
+	d.Body = p.block()
+	return d
+}
+
+func (p *Parser) params() []*Param {
+	params := []*Param{p.param()}
+	for p.lookahead(1).Type == Comma {
+		p.match(Comma)
+		params = append(params, p.param())
+	}
+	return params
+}
+
+func (p *Parser) param() *Param {
+	return &Param{Type: p.ident(), Name: p.ident()}
+}
+
+func (p *Parser) namespaceDecl() *NamespaceDecl {
+	d := &NamespaceDecl{Namespace: p.match(Namespace).Pos, Name: p.ident()}
+	p.match(LBrace)
+	for p.lookahead(1).Type != RBrace && p.lookahead(1).Type != EOF {
+		d.Decls = append(d.Decls, p.decl())
+	}
+	d.Rbrace = p.match(RBrace).Pos
+	return d
+}
+
+func (p *Parser) importDecl() *ImportDecl {
+	d := &ImportDecl{Import: p.match(Import).Pos, Name: p.ident()}
+	p.match(Semicolon)
+	return d
+}
+
+func (p *Parser) classDecl() *ClassDecl {
+	d := &ClassDecl{Class: p.match(Class).Pos, Name: p.ident()}
+	if p.lookahead(1).Type == Colon {
+		p.match(Colon)
+		d.Super = p.ident()
+	}
+	p.match(LBrace)
+	for p.lookahead(1).Type != RBrace && p.lookahead(1).Type != EOF {
+		d.Members = append(d.Members, p.member())
+	}
+	d.Rbrace = p.match(RBrace).Pos
+	p.match(Semicolon)
+	return d
+}
+
+// member is a field or a method of a class, fields can't have an
+// initializer.
+func (p *Parser) member() Decl {
+	if p.lookahead(3).Type == LParen {
+		return p.funcDecl()
+	}
+	d := &VarDecl{Type: p.ident(), Name: p.ident()}
+	p.match(Semicolon)
+	return d
+}
+
+func (p *Parser) structDecl() *StructDecl {
+	d := &StructDecl{Struct: p.match(Struct).Pos, Name: p.ident()}
+	p.match(LBrace)
+	for {
+		d.Fields = append(d.Fields, p.field())
+		if p.lookahead(1).Type == RBrace {
+			break
+		}
+	}
+	d.Rbrace = p.match(RBrace).Pos
+	p.match(Semicolon)
+	return d
+}
+
+// field is a member of a struct, fields can't have an initializer.
+func (p *Parser) field() Decl {
+	if p.lookahead(1).Type == Struct {
+		return p.structDecl()
+	}
+	d := &VarDecl{Type: p.ident(), Name: p.ident()}
+	p.match(Semicolon)
+	return d
+}
+
+func (p *Parser) varDecl() *VarDecl {
+	d := &VarDecl{Type: p.ident(), Name: p.ident()}
+	if p.lookahead(1).Type == Assign {
+		p.match(Assign)
+		d.Value = p.expr()
+	}
+	p.match(Semicolon)
+	return d
+}
+
+func (p *Parser) block() *Block {
+	b := &Block{Lbrace: p.match(LBrace).Pos}
+	for p.lookahead(1).Type != RBrace && p.lookahead(1).Type != EOF {
+		b.Stmts = append(b.Stmts, p.stmt())
+	}
+	b.Rbrace = p.match(RBrace).Pos
+	return b
+}
+
+func (p *Parser) stmt() Stmt {
+	first, second := p.lookahead(1), p.lookahead(2)
+
+	switch {
+	case first.Type == LBrace:
+		return p.block()
+	case first.Type == Struct:
+		return p.structDecl()
+	case first.Type == ID && second.Type == ID:
+		return p.varDecl()
+	case first.Type == If:
+		s := &IfStmt{If: p.match(If).Pos}
+		p.match(LParen)
+		s.Cond = p.expr()
+		p.match(RParen)
+		s.Then = p.stmt()
+		// the dangling else goes with the closest if, since this is the
+		// innermost call that could match it
+		if p.lookahead(1).Type == Else {
+			p.match(Else)
+			s.Else = p.stmt()
+		}
+		return s
+	case first.Type == While:
+		s := &WhileStmt{While: p.match(While).Pos}
+		p.match(LParen)
+		s.Cond = p.expr()
+		p.match(RParen)
+		s.Body = p.stmt()
+		return s
+	case first.Type == Return:
+		s := &ReturnStmt{Return: p.match(Return).Pos}
+		if p.lookahead(1).Type != Semicolon {
+			s.Value = p.expr()
+		}
+		p.match(Semicolon)
+		return s
+	default:
+		return p.simpleStmt(false)
+	}
+}
+
+// simpleStmt is an assignment or an expression used as a statement. If last,
+// an expression at the end of the input can go without its semicolon.
+func (p *Parser) simpleStmt(last bool) Stmt {
+	x := p.expr()
+	if p.lookahead(1).Type == Assign {
+		p.match(Assign)
+		s := &AssignStmt{Target: x, Value: p.expr()}
+		p.match(Semicolon)
+		return s
+	}
+	if last && p.lookahead(1).Type == EOF {
+		return &ExprStmt{X: x}
+	}
+	p.match(Semicolon)
+	return &ExprStmt{X: x}
+}
+
+func (p *Parser) expr() Expr {
+	return p.equality()
+}
+
+func (p *Parser) equality() Expr {
+	x := p.relational()
+	for p.lookahead(1).Type == Eq || p.lookahead(1).Type == Ne {
+		op := p.match(p.lookahead(1).Type)
+		x = &BinaryExpr{X: x, Op: op, Y: p.relational()}
+	}
+	return x
+}
+
+func (p *Parser) relational() Expr {
+	x := p.additive()
+	for {
+		switch p.lookahead(1).Type {
+		case Lt, Le, Gt, Ge:
+			op := p.match(p.lookahead(1).Type)
+			x = &BinaryExpr{X: x, Op: op, Y: p.additive()}
+		default:
+			return x
+		}
+	}
+}
+
+func (p *Parser) additive() Expr {
+	x := p.mult()
+	for p.lookahead(1).Type == Plus || p.lookahead(1).Type == Minus {
+		op := p.match(p.lookahead(1).Type)
+		x = &BinaryExpr{X: x, Op: op, Y: p.mult()}
+	}
+	return x
+}
+
+func (p *Parser) mult() Expr {
+	x := p.unary()
+	for p.lookahead(1).Type == Star || p.lookahead(1).Type == Slash {
+		op := p.match(p.lookahead(1).Type)
+		x = &BinaryExpr{X: x, Op: op, Y: p.unary()}
+	}
+	return x
+}
+
+func (p *Parser) unary() Expr {
+	if p.lookahead(1).Type == Minus || p.lookahead(1).Type == Not {
+		op := p.match(p.lookahead(1).Type)
+		return &UnaryExpr{Op: op, X: p.unary()}
+	}
+	return p.postfix()
+}
+
+func (p *Parser) postfix() Expr {
+	x := p.primary()
+	for {
+		switch p.lookahead(1).Type {
+		case LParen:
+			p.match(LParen)
+			call := &CallExpr{Fun: x}
+			if p.lookahead(1).Type != RParen {
+				call.Args = p.args()
+			}
+			p.match(RParen)
+			x = call
+		case Dot:
+			p.match(Dot)
+			x = &MemberExpr{X: x, Member: p.ident()}
+		default:
+			return x
+		}
+	}
+}
+
+func (p *Parser) args() []Expr {
+	args := []Expr{p.expr()}
+	for p.lookahead(1).Type == Comma {
+		p.match(Comma)
+		args = append(args, p.expr())
+	}
+	return args
+}
+
+func (p *Parser) primary() Expr {
+	tok := p.lookahead(1)
+	switch tok.Type {
+	case ID:
+		return p.ident()
+	case Int:
+		p.match(Int)
+		v, err := strconv.ParseInt(tok.Text, 10, 64)
+		if err != nil {
+			panic(errorAt(tok.Pos, "%w: invalid integer %s: %w", SyntaxError, tok.Text, err))
+		}
+		return &IntLit{Token: tok, Value: v}
+	case Float:
+		p.match(Float)
+		v, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			panic(errorAt(tok.Pos, "%w: invalid float %s: %w", SyntaxError, tok.Text, err))
+		}
+		return &FloatLit{Token: tok, Value: v}
+	case Char:
+		p.match(Char)
+		// the lexer matched a single character
+		v, _ := utf8.DecodeRuneInString(unquote(tok.Text))
+		return &CharLit{Token: tok, Value: v}
+	case String:
+		p.match(String)
+		return &StringLit{Token: tok, Value: unquote(tok.Text)}
+	case True, False:
+		p.match(tok.Type)
+		return &BoolLit{Token: tok, Value: tok.Type == True}
+	case This:
+		return &ThisExpr{Token: p.match(This)}
+	case Super:
+		return &SuperExpr{Token: p.match(Super)}
+	case LParen:
+		p.match(LParen)
+		x := p.expr()
+		p.match(RParen)
+		return x
+	default:
+		panic(unexpected(tok, "expression"))
+	}
+}
+
+func (p *Parser) ident() *Ident {
+	tok := p.match(ID)
+	return &Ident{Token: tok, Name: tok.Text}
+}
+
+// lookahead returns the nth next Token in the buffer.
+func (p *Parser) lookahead(n int) Token {
+	index := (p.pos + n - 1) % p.k
+	return p.buf[index]
+}
+
+// match checks if the current lookahead token is of the type we're looking
+// for. Goes to the next token and returns the matched one if it is, panics
+// with a SyntaxError if it isn't.
+func (p *Parser) match(typ TokenType) Token {
+	tok := p.lookahead(1)
+	if tok.Type != typ {
+		panic(unexpected(tok, typ.String()))
+	}
+	p.consume()
+	return tok
+}
+
+// unexpected returns the syntax error for finding tok instead of what was
+// expected, noting if the input ended too soon.
+func unexpected(tok Token, expecting string) error {
+	err := fmt.Errorf("%w: expecting %s, found %v", SyntaxError, expecting, tok.Type)
+	return &Error{Pos: tok.Pos, Err: err, eof: tok.Type == EOF}
+}
+
+func (p *Parser) consume() {
+	tok, err := p.input.Next()
+	if err != nil {
+		panic(err)
+	}
+
+	p.buf[p.pos] = tok
+	// add 1 until we reach k, then wraps around to 0
+	p.pos = (p.pos + 1) % p.k
+}
@@ -0,0 +1,285 @@
+package cymbol
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"golang.org/x/tools/txtar"
+)
+
+func TestParserGoodInput(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/good.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range ar.Files {
+		t.Run(file.Name, func(t *testing.T) {
+			_, err := ParseFile(string(file.Data))
+			if err != nil {
+				t.Errorf("got error on parse program:\n%s\nerror: %v", file.Data, err)
+			}
+		})
+	}
+}
+
+func TestParserBadInput(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/bad.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range ar.Files {
+		t.Run(file.Name, func(t *testing.T) {
+			_, err := ParseFile(string(file.Data))
+			if err == nil {
+				t.Errorf("want error on parse program:\n%s\ngot none", file.Data)
+			}
+			t.Log(err)
+		})
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: "1 + 2 * 3", want: "(1 + (2 * 3))"},
+		{input: "(1 + 2) * 3", want: "((1 + 2) * 3)"},
+		{input: "a - b - c", want: "((a - b) - c)"},
+		{input: "a < b == c >= d", want: "((a < b) == (c >= d))"},
+		{input: "-a * !b", want: "((-a) * (!b))"},
+		{input: "--a", want: "(-(-a))"},
+		{input: "f(a, b + 1)(c)", want: "f(a, (b + 1))(c)"},
+		{input: `'x' != "y"`, want: `('x' != "y")`},
+		{input: "a.b.c + f(x).y", want: "(a.b.c + f(x).y)"},
+		{input: "this.x + super.f()", want: "(this.x + super.f())"},
+		{input: "-p.x", want: "(-p.x)"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			x, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := x.String(); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestLiteralValues checks the escape sequences the lexer allows in literals
+// are decoded, Go's or not.
+func TestLiteralValues(t *testing.T) {
+	cases := []struct {
+		input string
+		want  any
+	}{
+		{input: `"it\'s"`, want: "it's"},
+		{input: `"say \"hi\"\n"`, want: "say \"hi\"\n"},
+		{input: `"a\tb\\c"`, want: "a\tb\\c"},
+		{input: `'\"'`, want: '"'},
+		{input: `'\''`, want: '\''},
+		{input: `'\t'`, want: '\t'},
+		{input: `'é'`, want: 'é'},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			x, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got any
+			switch x := x.(type) {
+			case *StringLit:
+				got = x.Value
+			case *CharLit:
+				got = x.Value
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	src := `int x = 1;
+int f(int a) {
+    if (a > x) return a; else { a = x; }
+    return a;
+}`
+	f, err := ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "int x = 1;\nint f(int a) { if ((a > x)) return a; else { a = x; } return a; }"
+	if got := f.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+
+	fn := f.Decls[1].(*FuncDecl)
+	ifStmt := fn.Body.Stmts[0].(*IfStmt)
+	if got, want := ifStmt.Pos(), (Pos{Line: 3, Col: 5}); got != want {
+		t.Errorf("want if at %v, got: %v", want, got)
+	}
+	if got, want := ifStmt.Else.Pos(), (Pos{Line: 3, Col: 31}); got != want {
+		t.Errorf("want else block at %v, got: %v", want, got)
+	}
+	if got, want := fn.Body.Rbrace, (Pos{Line: 5, Col: 1}); got != want {
+		t.Errorf("want the body to end at %v, got: %v", want, got)
+	}
+}
+
+func TestParseStruct(t *testing.T) {
+	f, err := ParseFile("struct A { int x; struct B { int y; }; B b; };\nvoid f() { struct C { A a; }; C c; c.a.b.y = 1; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "struct A { int x; struct B { int y; }; B b; };\nvoid f() { struct C { A a; }; C c; c.a.b.y = 1; }"
+	if got := f.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+
+	a := f.Decls[0].(*StructDecl)
+	if got := a.Fields[1].(*StructDecl).Name.Name; got != "B" {
+		t.Errorf("want nested struct B, got: %s", got)
+	}
+	assign := f.Decls[1].(*FuncDecl).Body.Stmts[2].(*AssignStmt)
+	member := assign.Target.(*MemberExpr)
+	if got, want := member.Member.Pos(), (Pos{Line: 2, Col: 42}); got != want {
+		t.Errorf("want member y at %v, got: %v", want, got)
+	}
+	if got, want := member.Pos(), (Pos{Line: 2, Col: 36}); got != want {
+		t.Errorf("want member access at %v, got: %v", want, got)
+	}
+}
+
+func TestParseClass(t *testing.T) {
+	f, err := ParseFile("class A { int x; void f() { } };\nclass B : A { void f() { super.f(); this.x = x; } };")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "class A { int x; void f() { } };\nclass B : A { void f() { super.f(); this.x = x; } };"
+	if got := f.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+
+	b := f.Decls[1].(*ClassDecl)
+	if got := b.Super.Name; got != "A" {
+		t.Errorf("want superclass A, got: %s", got)
+	}
+	if a := f.Decls[0].(*ClassDecl); a.Super != nil {
+		t.Errorf("want no superclass, got: %v", a.Super)
+	}
+	if got, want := f.Decls[0].(*ClassDecl).Rbrace, (Pos{Line: 1, Col: 31}); got != want {
+		t.Errorf("want class A to end at %v, got: %v", want, got)
+	}
+	call := b.Members[0].(*FuncDecl).Body.Stmts[0].(*ExprStmt).X.(*CallExpr)
+	super := call.Fun.(*MemberExpr).X.(*SuperExpr)
+	if got, want := super.Pos(), (Pos{Line: 2, Col: 26}); got != want {
+		t.Errorf("want super at %v, got: %v", want, got)
+	}
+}
+
+func TestParseNamespace(t *testing.T) {
+	f, err := ParseFile("namespace geo {\n    import unit;\n    int x;\n    namespace unit { }\n}\nimport geo;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "namespace geo { import unit; int x; namespace unit { } }\nimport geo;"
+	if got := f.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+
+	geo := f.Decls[0].(*NamespaceDecl)
+	if got, want := geo.Rbrace, (Pos{Line: 5, Col: 1}); got != want {
+		t.Errorf("want namespace geo to end at %v, got: %v", want, got)
+	}
+	if got, want := geo.Decls[2].(*NamespaceDecl).Name.Pos(), (Pos{Line: 4, Col: 15}); got != want {
+		t.Errorf("want namespace unit at %v, got: %v", want, got)
+	}
+	if got, want := f.Decls[1].(*ImportDecl).Name.Pos(), (Pos{Line: 6, Col: 8}); got != want {
+		t.Errorf("want the import of geo at %v, got: %v", want, got)
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	_, err := ParseFile("int x = 1;\nint y = 2 3;")
+	if !errors.Is(err, SyntaxError) {
+		t.Fatalf("want syntax error, got: %v", err)
+	}
+	want := "2:11: syntax error: expecting Semicolon, found Int"
+	if err.Error() != want {
+		t.Errorf("want: %q, got: %q", want, err.Error())
+	}
+}
+
+func TestParseInput(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{input: "", want: nil},
+		{input: "1 + 2", want: []string{"(1 + 2);"}},
+		{input: "int x = 1; x = x + 1; x", want: []string{"int x = 1;", "x = (x + 1);", "x;"}},
+		{
+			input: "int f(int n) { return n; } class A { int n; }; struct P { int x; }; f(1);",
+			want:  []string{"int f(int n) { return n; }", "class A { int n; };", "struct P { int x; };", "f(1);"},
+		},
+		{input: "namespace n { int x; } import n; x", want: []string{"namespace n { int x; }", "import n;", "x;"}},
+		{input: "if (x) { y = 1; } while (y) y = y - 1;", want: []string{"if (x) { y = 1; }", "while (y) y = (y - 1);"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			nodes, err := ParseInput(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, n := range nodes {
+				got = append(got, n.String())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	cases := []struct {
+		input      string
+		incomplete bool
+	}{
+		{input: "int f(int n) {", incomplete: true},
+		{input: "int f(int n) { return n", incomplete: true},
+		{input: "int x", incomplete: true},
+		{input: "1 +", incomplete: true},
+		{input: "f(1,", incomplete: true},
+		{input: "x = 1", incomplete: true}, // only expressions can go without a semicolon
+		{input: "class A {", incomplete: true},
+		{input: "int x = 1 2;", incomplete: false},
+		{input: "1 + 2 }", incomplete: false},
+		{input: `"abc`, incomplete: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := ParseInput(tc.input)
+			if err == nil {
+				t.Fatal("want an error, got none")
+			}
+			if got := Incomplete(err); got != tc.incomplete {
+				t.Errorf("want incomplete %v, got %v for: %v", tc.incomplete, got, err)
+			}
+		})
+	}
+}
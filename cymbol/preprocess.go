@@ -0,0 +1,239 @@
+package cymbol
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Preprocessing
+//
+// Cymbol's imports don't bring in files, but like C it can have a
+// preprocessor that does: a pass over the text before the lexer sees it,
+// that knows lines and words and nothing of the language. Its directives are
+// lines starting with #:
+//
+//	#include "point.cym"     the text of point.cym in place of the line
+//	#define N 10             N replaced by 10 from then on
+//
+// Included paths are relative to the file that includes them, and a file
+// can't include itself, directly or not. Macros are textual and without
+// parameters: a name is replaced wherever it's a whole word outside of
+// strings, chars and comments. A comment after the body of a macro isn't
+// part of it. Their body is expanded when they're defined, so a macro used
+// in another one must be defined first, and can't refer to itself.
+//
+// The lexer, the parser and the passes after them only see the text that
+// comes out, so their positions are in it. Preprocessed has the SourceMap of
+// its text, so that errors point at what the programmer wrote: the use of a
+// macro for anything in its expansion.
+
+// Preprocessed is the text of a preprocessed program, and where it comes
+// from.
+type Preprocessed struct {
+	Text  string
+	Files map[string]string // the texts of the files read, by name
+	SourceMap
+}
+
+// macro is a #define.
+type macro struct {
+	body string
+	loc  Location
+}
+
+// preprocessor preprocesses the files of fsys into out, including holds the
+// files being included, outermost first.
+type preprocessor struct {
+	fsys      fs.FS
+	files     map[string]string
+	macros    map[string]macro
+	including []string
+	out       strings.Builder
+	line      int // of out, from 1
+	anchors   []anchor
+}
+
+// Preprocess preprocesses the program src, the text of the file name, and
+// the files it includes from fsys. Names are slash-separated paths, as in
+// fs.FS, which name doesn't need to be in. The errors are LocatedErrors,
+// wrapping a SyntaxError for malformed directives, in a SourceError with the
+// files read.
+func Preprocess(fsys fs.FS, name, src string) (*Preprocessed, error) {
+	p := &preprocessor{fsys: fsys, files: map[string]string{}, macros: map[string]macro{}, line: 1}
+	if err := p.file(name, src); err != nil {
+		return nil, &SourceError{Sources: p.files, Err: err}
+	}
+	return &Preprocessed{Text: p.out.String(), Files: p.files, SourceMap: SourceMap{p.anchors}}, nil
+}
+
+func (p *preprocessor) file(name, src string) error {
+	p.files[name] = src
+	p.including = append(p.including, name)
+	defer func() { p.including = p.including[:len(p.including)-1] }()
+	lines := strings.SplitAfter(src, "\n")
+	for i, line := range lines {
+		if line == "" {
+			continue // after the last newline
+		}
+		loc := Location{File: name, Pos: Pos{Line: i + 1, Col: 1}}
+		if d, ok := strings.CutPrefix(strings.TrimLeft(line, " \t"), "#"); ok {
+			loc.Pos.Col += len([]rune(line)) - len([]rune(d)) - 1
+			if err := p.directive(loc, strings.TrimRight(d, "\r\n")); err != nil {
+				return err
+			}
+			continue
+		}
+		p.expand(loc, line)
+	}
+	return nil
+}
+
+// directive runs the directive d, the text after the # at loc.
+func (p *preprocessor) directive(loc Location, d string) error {
+	errorf := func(format string, args ...any) error {
+		return &LocatedError{loc, fmt.Errorf(format, args...)}
+	}
+	name, arg := cutSpace(d)
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "include":
+		file, ok := strings.CutPrefix(arg, `"`)
+		file, ok2 := strings.CutSuffix(file, `"`)
+		if !ok || !ok2 || file == "" {
+			return errorf(`%w: want #include "file"`, SyntaxError)
+		}
+		file = path.Join(path.Dir(loc.File), file)
+		if slices.Contains(p.including, file) {
+			return errorf("%s includes itself: %s", file, strings.Join(append(p.including[slices.Index(p.including, file):], file), " → "))
+		}
+		b, err := fs.ReadFile(p.fsys, file)
+		if err != nil {
+			var perr *fs.PathError
+			if errors.As(err, &perr) {
+				err = perr.Err
+			}
+			return errorf("include %s: %w", file, err)
+		}
+		return p.file(file, string(b))
+	case "define":
+		m, body := cutSpace(arg)
+		if m == "" || !isLetter(rune(m[0])) || strings.IndexFunc(m, func(r rune) bool { return !isLetter(r) && !isDigit(r) }) >= 0 {
+			return errorf("%w: want #define NAME text", SyntaxError)
+		}
+		if _, ok := keywords[m]; ok {
+			return errorf("%s is a keyword, it can't be a macro", m)
+		}
+		if prev, ok := p.macros[m]; ok {
+			return errorf("%s redefined, first defined at %v", m, prev.loc)
+		}
+		body = p.replace(strings.TrimSpace(uncomment(body)), nil)
+		p.macros[m] = macro{body: body, loc: loc}
+		return nil
+	}
+	return errorf("%w: unknown directive #%s", SyntaxError, name)
+}
+
+// cutSpace slices s around its first run of white space, which separates
+// the words of a directive.
+func cutSpace(s string) (before, after string) {
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimLeftFunc(s[i:], unicode.IsSpace)
+}
+
+// expand writes line, the one at loc, with the macros expanded. A line that
+// doesn't end with a newline, the last of an included file, gets one.
+func (p *preprocessor) expand(loc Location, line string) {
+	p.anchors = append(p.anchors, anchor{at: Pos{Line: p.line, Col: 1}, loc: loc})
+	shift := 0 // how many more columns there are in out than in line
+	p.out.WriteString(p.replace(line, func(from, to int, body string) {
+		use, after := loc, loc
+		use.Pos.Col, after.Pos.Col = from, to
+		at := Pos{Line: p.line, Col: from + shift}
+		shift += len([]rune(body)) - (to - from)
+		p.anchors = append(p.anchors,
+			anchor{at: at, loc: use, expansion: true},
+			anchor{at: Pos{Line: p.line, Col: to + shift}, loc: after},
+		)
+	}))
+	if !strings.HasSuffix(line, "\n") {
+		p.out.WriteByte('\n')
+	}
+	p.line++
+}
+
+// replace returns s with the macros replaced by their bodies, calling
+// replaced, if it isn't nil, for each one in order, with the columns the name
+// is between in s.
+func (p *preprocessor) replace(s string, replaced func(from, to int, body string)) string {
+	var out strings.Builder
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		switch r := rs[i]; {
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			out.WriteString(string(rs[i:]))
+			return out.String()
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(rs) && rs[j] != r && rs[j] != '\n' {
+				if rs[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(rs))
+			out.WriteString(string(rs[i:j]))
+			i = j
+		case isLetter(r) || isDigit(r):
+			// a word starting with a digit is a number, never a macro
+			j := i
+			for j < len(rs) && (isLetter(rs[j]) || isDigit(rs[j])) {
+				j++
+			}
+			word := string(rs[i:j])
+			if m, ok := p.macros[word]; ok && isLetter(r) {
+				out.WriteString(m.body)
+				if replaced != nil {
+					replaced(i+1, j+1, m.body)
+				}
+			} else {
+				out.WriteString(word)
+			}
+			i = j
+		default:
+			out.WriteRune(r)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// uncomment returns s without the comment at its end.
+func uncomment(s string) string {
+	var quote rune
+	escaped := false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case strings.HasPrefix(s[i:], "//"):
+			return s[:i]
+		}
+	}
+	return s
+}
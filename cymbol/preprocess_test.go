@@ -0,0 +1,118 @@
+package cymbol
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPreprocess(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lib/point.cym": {Data: []byte("#include \"const.cym\"\nstruct point { int x; int y; };\npoint ORIGIN;")},
+		"lib/const.cym": {Data: []byte("#define N 10\n#define TWICE_N N * 2 // comment\n")},
+	}
+	src := `#include "lib/point.cym"
+int f() {
+    #define MSG "N is N"
+    print(MSG); // N stays
+    return TWICE_N + N2 + x;
+}
+`
+	want := `struct point { int x; int y; };
+point ORIGIN;
+int f() {
+    print("N is N"); // N stays
+    return 10 * 2 + N2 + x;
+}
+`
+	pp, err := Preprocess(fsys, "main.cym", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, pp.Text); diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	cases := []struct {
+		pos  Pos
+		want string
+	}{
+		{Pos{Line: 1, Col: 8}, "lib/point.cym:2:8"},
+		{Pos{Line: 2, Col: 7}, "lib/point.cym:3:7"},
+		{Pos{Line: 3, Col: 5}, "main.cym:2:5"},
+		{Pos{Line: 4, Col: 11}, "main.cym:4:11"}, // the string of MSG
+		{Pos{Line: 4, Col: 20}, "main.cym:4:15"}, // after it
+		{Pos{Line: 5, Col: 12}, "main.cym:5:12"}, // 10
+		{Pos{Line: 5, Col: 17}, "main.cym:5:12"}, // 2
+		{Pos{Line: 5, Col: 21}, "main.cym:5:22"}, // N2 isn't N
+		{Pos{Line: 5, Col: 26}, "main.cym:5:27"},
+		{Pos{Line: 7, Col: 1}, "main.cym:7:1"}, // the end
+	}
+	for _, c := range cases {
+		if got := pp.Locate(c.pos).String(); got != c.want {
+			t.Errorf("Locate(%v): got %s, want %s", c.pos, got, c.want)
+		}
+	}
+}
+
+func TestPreprocessTabs(t *testing.T) {
+	fsys := fstest.MapFS{"lib.cym": {Data: []byte("int y;\n")}}
+	src := "#define\tN 10\n#define M\t20\n#include\t\"lib.cym\"\nint x = N + M;\n"
+	pp, err := Preprocess(fsys, "main.cym", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("int y;\nint x = 10 + 20;\n", pp.Text); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestPreprocessErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.cym":     {Data: []byte("int a;\n#include \"dir/b.cym\"\n")},
+		"dir/b.cym": {Data: []byte("  #include \"../a.cym\"\n")},
+	}
+	cases := []struct {
+		src, want string
+	}{
+		{`#include "c.cym"`, "main.cym:1:1: include c.cym: file does not exist"},
+		{"#include c.cym", `main.cym:1:1: syntax error: want #include "file"`},
+		{"#include \"a.cym\"", "dir/b.cym:1:3: a.cym includes itself: a.cym → dir/b.cym → a.cym"},
+		{"#define N 1\n#define N 2", "main.cym:2:1: N redefined, first defined at main.cym:1:1"},
+		{"#define 1N 2", "main.cym:1:1: syntax error: want #define NAME text"},
+		{"#define while for", "main.cym:1:1: while is a keyword, it can't be a macro"},
+		{"int x;\n #pragma once", "main.cym:2:2: syntax error: unknown directive #pragma"},
+	}
+	for _, c := range cases {
+		_, err := Preprocess(fsys, "main.cym", c.src)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%q: got error %v, want %s", c.src, err, c.want)
+		}
+	}
+}
+
+func TestPreprocessedError(t *testing.T) {
+	fsys := fstest.MapFS{"lib.cym": {Data: []byte("#define ZERO 0\nint f() { return ZERO; }\n")}}
+	pp, err := Preprocess(fsys, "main.cym", "#include \"lib.cym\"\nint x = ZERO +;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseFile(pp.Text)
+	err = pp.Error(err)
+	if want := "main.cym:2:15: syntax error: expecting expression, found Semicolon"; err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+	var le *LocatedError
+	if !errors.As(err, &le) || !errors.Is(err, SyntaxError) {
+		t.Errorf("got %#v, want a LocatedError wrapping a SyntaxError", err)
+	}
+
+	var ds Diagnostics
+	ds.Add(Span{From: Pos{Line: 1, Col: 18}}, "one")
+	ds.Add(Span{From: Pos{Line: 2, Col: 9}}, "two")
+	if got, want := pp.Error(ds.Err()).Error(), "lib.cym:2:18: one\nmain.cym:2:9: two"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
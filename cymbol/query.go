@@ -0,0 +1,389 @@
+package cymbol
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Queries
+//
+// Finding nodes in a tree takes a visitor: a walk with a switch on the node
+// types and the bookkeeping of where it is. A query says which nodes it wants
+// instead, as a path in the tree the way XPath does it for XML:
+//
+//	//assign/name[1]        the first name assigned to directly, in each
+//	                        assignment: x in `x = y;`, y in `p.x = y;`
+//	/file/func[@name='f']   the function f
+//	//func//call/name[1]    the names of the functions called in functions
+//	//binary[@op='+']/int   the integers added
+//
+// A query is a list of steps, each one going from the nodes selected so far
+// to their children of a kind, * for any. A step after // goes from the nodes
+// or any of their descendants instead. A leading / starts from above the
+// root, so the first step selects the root itself if it's of that kind, a
+// query starting with a step goes from the root to its children. Predicates
+// in brackets keep some of the nodes of a step: [n] the nth of the ones of
+// each parent, from 1, [@attr='value'] the ones with an attribute, [@attr]
+// the ones that have it at all. They apply one after the other, so
+// name[@name='x'][2] is the second x of a parent.
+//
+//	query     : ('/' | '//')? step (('/' | '//') step)* ;
+//	step      : (kind | '*') predicate* ;
+//	predicate : '[' (INT | '@' attr ('=' STRING)?) ']' ;
+//
+// The tree is the one Apply walks, so the names and types of declarations
+// and the member of a member access aren't nodes but attributes. The kinds
+// are the node types, lowercase and without their family:
+//
+//	file namespace import var func param struct class block if while
+//	return assign expr name int float char string bool binary unary call
+//	this super member
+//
+// and the attributes are name (of declarations, imports, names and member
+// accesses), type (of variables, functions and parameters), super (of
+// classes), op (of operators) and value (of literals, as written).
+
+// Query is a compiled query, see CompileQuery.
+type Query struct {
+	src   string
+	abs   bool // starting with a /, from above the root
+	steps []step
+}
+
+// step is a step of a query: the nodes of kind, "*" for any, among the
+// children or with deep the descendants, kept by the predicates.
+type step struct {
+	deep  bool
+	kind  string
+	preds []predicate
+}
+
+// predicate is [index] if index isn't 0, [@attr='value'] otherwise, or
+// [@attr] with any.
+type predicate struct {
+	index       int
+	attr, value string
+	any         bool
+}
+
+var (
+	queryKinds = []string{
+		"file", "namespace", "import", "var", "func", "param", "struct", "class", "block", "if", "while", "return",
+		"assign", "expr", "name", "int", "float", "char", "string", "bool", "binary", "unary", "call", "this", "super", "member",
+	}
+	queryAttrs = []string{"name", "type", "super", "op", "value"}
+)
+
+// queryKind is the kind of n, as it's named in queries.
+func queryKind(n Node) string {
+	switch n.(type) {
+	case *File:
+		return "file"
+	case *NamespaceDecl:
+		return "namespace"
+	case *ImportDecl:
+		return "import"
+	case *VarDecl:
+		return "var"
+	case *FuncDecl:
+		return "func"
+	case *Param:
+		return "param"
+	case *StructDecl:
+		return "struct"
+	case *ClassDecl:
+		return "class"
+	case *Block:
+		return "block"
+	case *IfStmt:
+		return "if"
+	case *WhileStmt:
+		return "while"
+	case *ReturnStmt:
+		return "return"
+	case *AssignStmt:
+		return "assign"
+	case *ExprStmt:
+		return "expr"
+	case *Ident:
+		return "name"
+	case *IntLit:
+		return "int"
+	case *FloatLit:
+		return "float"
+	case *CharLit:
+		return "char"
+	case *StringLit:
+		return "string"
+	case *BoolLit:
+		return "bool"
+	case *BinaryExpr:
+		return "binary"
+	case *UnaryExpr:
+		return "unary"
+	case *CallExpr:
+		return "call"
+	case *ThisExpr:
+		return "this"
+	case *SuperExpr:
+		return "super"
+	case *MemberExpr:
+		return "member"
+	}
+	return ""
+}
+
+// CompileQuery compiles a query. The error is an *Error at the column of the
+// query where it's wrong, a SyntaxError if the query doesn't follow the
+// grammar.
+func CompileQuery(src string) (*Query, error) {
+	c := &queryCompiler{src: src}
+	q := &Query{src: src}
+	if c.i == len(src) {
+		return nil, c.errorf("%w: empty query", SyntaxError)
+	}
+	deep := false
+	if c.next("//") {
+		q.abs, deep = true, true
+	} else if c.next("/") {
+		q.abs = true
+	}
+	for {
+		s, err := c.step(deep)
+		if err != nil {
+			return nil, err
+		}
+		q.steps = append(q.steps, s)
+		if c.i == len(src) {
+			return q, nil
+		}
+		switch {
+		case c.next("//"):
+			deep = true
+		case c.next("/"):
+			deep = false
+		default:
+			return nil, c.errorf("%w: expecting / or [, found %q", SyntaxError, src[c.i:c.i+1])
+		}
+	}
+}
+
+// String returns the query as it was written.
+func (q *Query) String() string { return q.src }
+
+// queryCompiler reads a query from src, i is the byte offset it's at.
+type queryCompiler struct {
+	src string
+	i   int
+}
+
+func (c *queryCompiler) errorf(format string, args ...any) error {
+	return errorAt(Pos{Line: 1, Col: len([]rune(c.src[:c.i])) + 1}, format, args...)
+}
+
+// next consumes s if the query goes on with it.
+func (c *queryCompiler) next(s string) bool {
+	if strings.HasPrefix(c.src[c.i:], s) {
+		c.i += len(s)
+		return true
+	}
+	return false
+}
+
+// word consumes the letters at i.
+func (c *queryCompiler) word() string {
+	from := c.i
+	for c.i < len(c.src) && isLetter(rune(c.src[c.i])) {
+		c.i++
+	}
+	return c.src[from:c.i]
+}
+
+func (c *queryCompiler) step(deep bool) (step, error) {
+	s := step{deep: deep, kind: "*"}
+	if !c.next("*") {
+		at := c.i
+		s.kind = c.word()
+		if s.kind == "" {
+			return s, c.errorf("%w: expecting a kind of node or *", SyntaxError)
+		}
+		if !slices.Contains(queryKinds, s.kind) {
+			c.i = at
+			return s, c.errorf("unknown kind of node %s", s.kind)
+		}
+	}
+	for c.next("[") {
+		p, err := c.predicate()
+		if err != nil {
+			return s, err
+		}
+		if !c.next("]") {
+			return s, c.errorf("%w: expecting ]", SyntaxError)
+		}
+		s.preds = append(s.preds, p)
+	}
+	return s, nil
+}
+
+func (c *queryCompiler) predicate() (predicate, error) {
+	var p predicate
+	if c.next("@") {
+		at := c.i
+		p.attr = c.word()
+		if !slices.Contains(queryAttrs, p.attr) {
+			c.i = at
+			return p, c.errorf("unknown attribute %q, want one of %s", p.attr, strings.Join(queryAttrs, ", "))
+		}
+		if !c.next("=") {
+			p.any = true
+			return p, nil
+		}
+		if c.i == len(c.src) || (c.src[c.i] != '\'' && c.src[c.i] != '"') {
+			return p, c.errorf("%w: expecting a quoted value", SyntaxError)
+		}
+		quote := c.src[c.i : c.i+1]
+		end := strings.Index(c.src[c.i+1:], quote)
+		if end < 0 {
+			return p, c.errorf("%w: value not terminated", SyntaxError)
+		}
+		p.value = c.src[c.i+1 : c.i+1+end]
+		c.i += end + 2
+		return p, nil
+	}
+	from := c.i
+	for c.i < len(c.src) && isDigit(rune(c.src[c.i])) {
+		c.i++
+	}
+	n, err := strconv.Atoi(c.src[from:c.i])
+	if err != nil || n < 1 {
+		c.i = from
+		return p, c.errorf("%w: expecting @attribute or an index from 1", SyntaxError)
+	}
+	p.index = n
+	return p, nil
+}
+
+// Select returns the nodes of the tree rooted at root the query selects, in
+// the order of the tree, each once.
+func (q *Query) Select(root Node) []Node {
+	t := newQueryTree(root)
+	nodes := []Node{root}
+	if q.abs {
+		nodes = []Node{nil} // above the root
+	}
+	for _, s := range q.steps {
+		var next []Node
+		for _, n := range nodes {
+			from := []Node{n}
+			if s.deep {
+				from = t.descendants(n)
+			}
+			for _, parent := range from {
+				next = append(next, s.filter(t.children(parent))...)
+			}
+		}
+		nodes = t.sorted(next)
+	}
+	return nodes
+}
+
+// filter returns the nodes of the kind of s among the children of a node,
+// kept by its predicates.
+func (s step) filter(children []Node) []Node {
+	var nodes []Node
+	for _, n := range children {
+		if s.kind == "*" || queryKind(n) == s.kind {
+			nodes = append(nodes, n)
+		}
+	}
+	for _, p := range s.preds {
+		var kept []Node
+		for i, n := range nodes {
+			if p.keeps(i+1, n) {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return nodes
+}
+
+// keeps tells whether the predicate keeps n, the ith node of its parent.
+func (p predicate) keeps(i int, n Node) bool {
+	if p.index != 0 {
+		return i == p.index
+	}
+	v, ok := queryAttr(n, p.attr)
+	return ok && (p.any || v == p.value)
+}
+
+// queryAttr returns the attribute of n named attr, if n has one.
+func queryAttr(n Node, attr string) (string, bool) {
+	var v *Ident
+	switch n := n.(type) {
+	case *NamespaceDecl:
+		v = map[string]*Ident{"name": n.Name}[attr]
+	case *ImportDecl:
+		v = map[string]*Ident{"name": n.Name}[attr]
+	case *VarDecl:
+		v = map[string]*Ident{"name": n.Name, "type": n.Type}[attr]
+	case *FuncDecl:
+		v = map[string]*Ident{"name": n.Name, "type": n.Type}[attr]
+	case *Param:
+		v = map[string]*Ident{"name": n.Name, "type": n.Type}[attr]
+	case *StructDecl:
+		v = map[string]*Ident{"name": n.Name}[attr]
+	case *ClassDecl:
+		v = map[string]*Ident{"name": n.Name, "super": n.Super}[attr]
+	case *Ident:
+		v = map[string]*Ident{"name": n}[attr]
+	case *MemberExpr:
+		v = map[string]*Ident{"name": n.Member}[attr]
+	case *BinaryExpr:
+		return n.Op.Text, attr == "op"
+	case *UnaryExpr:
+		return n.Op.Text, attr == "op"
+	case *IntLit, *FloatLit, *CharLit, *StringLit, *BoolLit:
+		return n.String(), attr == "value"
+	}
+	if v == nil {
+		return "", false
+	}
+	return v.Name, true
+}
+
+// queryTree has the children of the nodes of a tree, and their order.
+type queryTree struct {
+	kids  map[Node][]Node // nil is above the root
+	order map[Node]int
+}
+
+func newQueryTree(root Node) *queryTree {
+	t := &queryTree{kids: map[Node][]Node{nil: {root}}, order: map[Node]int{}}
+	Apply(root, func(c *Cursor) bool {
+		t.order[c.Node()] = len(t.order)
+		if c.Parent() != nil {
+			t.kids[c.Parent()] = append(t.kids[c.Parent()], c.Node())
+		}
+		return true
+	}, nil)
+	return t
+}
+
+func (t *queryTree) children(n Node) []Node { return t.kids[n] }
+
+// descendants returns n and all the nodes under it.
+func (t *queryTree) descendants(n Node) []Node {
+	nodes := []Node{n}
+	for _, c := range t.kids[n] {
+		nodes = append(nodes, t.descendants(c)...)
+	}
+	return nodes
+}
+
+// sorted returns the nodes in the order of the tree, without duplicates.
+func (t *queryTree) sorted(nodes []Node) []Node {
+	slices.SortFunc(nodes, func(a, b Node) int { return t.order[a] - t.order[b] })
+	return slices.Compact(nodes)
+}
@@ -0,0 +1,82 @@
+package cymbol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQuery(t *testing.T) {
+	src := `struct point { int x; };
+point p;
+int f(int a) { int b = a + 1; b = f(b) + 2; p.x = b; return b; }
+class A { int n; void g() { this.n = f(n); { n = -n; } } };
+`
+	f, err := ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		query string
+		want  []string // the nodes selected, as position and source
+	}{
+		{"//assign/name[1]", []string{"3:31 b", "3:51 b", "4:46 n"}},
+		{"/file", []string{"1:1 " + f.String()}},
+		{"/var", nil},
+		{"func", []string{"3:1 " + f.Decls[2].String()}},
+		{"/file/func[@name='f']/param", []string{"3:7 int a"}},
+		{"/file/func[@name='g']", nil},
+		{"//func[@name='g']//call/name[1]", []string{"4:38 f"}},
+		{"//func//call/name", []string{"3:35 f", "3:37 b", "4:38 f", "4:40 n"}},
+		{"//func//call/name[1]", []string{"3:35 f", "4:38 f"}},
+		{"//binary[@op='+']/int", []string{"3:28 1", "3:42 2"}},
+		{"//var[@type='point']", []string{"2:1 point p;"}},
+		{`//class[@name="A"]/*[2]`, []string{"4:18 " + f.Decls[3].(*ClassDecl).Members[1].String()}},
+		{"//class[@super]", nil},
+		{"//member[@name]", []string{"3:45 p.x", "4:29 this.n"}},
+		{"//block//unary", []string{"4:50 (-n)"}},
+		{"//block/block/*", []string{"4:46 n = (-n);"}},
+		{"//binary/*[@name][1]", []string{"3:24 a"}},
+		{"//*[@value='1']", []string{"3:28 1"}},
+		{"//return/*", []string{"3:61 b"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := CompileQuery(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, n := range q.Select(f) {
+				got = append(got, n.Pos().String()+" "+n.String())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompileQueryErrors(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"", "1:1: syntax error: empty query"},
+		{"//", "1:3: syntax error: expecting a kind of node or *"},
+		{"//assign/", "1:10: syntax error: expecting a kind of node or *"},
+		{"//assignment", "1:3: unknown kind of node assignment"},
+		{"//name[0]", "1:8: syntax error: expecting @attribute or an index from 1"},
+		{"//name[1", "1:9: syntax error: expecting ]"},
+		{"//name[@size]", "1:9: unknown attribute \"size\", want one of name, type, super, op, value"},
+		{"//name[@name=x]", "1:14: syntax error: expecting a quoted value"},
+		{"//name[@name='x]", "1:14: syntax error: value not terminated"},
+		{"//name x", "1:7: syntax error: expecting / or [, found \" \""},
+	}
+	for _, tc := range cases {
+		_, err := CompileQuery(tc.query)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%q: got error %v, want %s", tc.query, err, tc.want)
+		}
+	}
+}
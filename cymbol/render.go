@@ -0,0 +1,190 @@
+package cymbol
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rendering errors
+//
+// An error message has the position of the problem, but the programmer still
+// has to find the line to see it. Render shows each error with its line, the
+// lines before and after it, and the span of the error underlined, the way
+// compilers print errors to a terminal:
+//
+//	2:9: undefined: y
+//	1 | int x = 1;
+//	2 | int z = y + x;
+//	  |         ^
+//	3 | print(z);
+//
+// Errors only have positions, so Render needs the text they're in: a
+// SourceError is an error with the source of the program, by file for the
+// LocatedErrors of programs made of several. A position with no span, as the
+// one of a syntax error, gets a caret, and a span going past its line is
+// underlined to its end. The tabs before a span are kept in the underline, so
+// that it lines up whatever a tab is wide.
+//
+// The output only depends on the errors and the sources, so that it can be
+// tested and diffed. Colors are for terminals, UseColor tells whether to use
+// them: not if the NO_COLOR environment variable is set, see no-color.org.
+
+// SourceError is an error of a program, along with its source.
+type SourceError struct {
+	Sources map[string]string // the texts by file name, "" for positions without a file
+	Err     error
+}
+
+func (e *SourceError) Error() string { return e.Err.Error() }
+func (e *SourceError) Unwrap() error { return e.Err }
+
+// WithSource returns err, an error of the program src, with its source. It
+// returns nil if err is nil.
+func WithSource(src string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &SourceError{Sources: map[string]string{"": src}, Err: err}
+}
+
+// spanError is an error at a span of the source that isn't a Diagnostic, as
+// the runtime errors of the interpreter are.
+type spanError interface {
+	error
+	ErrorSpan() Span
+}
+
+// SGR parameters of the parts of a rendered error
+const (
+	messageColor = "1"    // bold
+	gutterColor  = "90"   // gray
+	markColor    = "1;31" // bold red
+)
+
+// Render writes err, one error per line as Diagnostics.Err returns them,
+// each with the lines of the source it's at if err is a SourceError, with
+// terminal colors if color is set.
+func Render(w io.Writer, err error, color bool) error {
+	var se *SourceError
+	if !errors.As(err, &se) {
+		_, err := io.WriteString(w, err.Error()+"\n")
+		return err
+	}
+	var s strings.Builder
+	for _, e := range splitErrors(se.Err) {
+		lines := strings.Split(e.Error(), "\n")
+		s.WriteString(paint(lines[0], messageColor, color) + "\n")
+		if file, span, ok := errorSpan(e); ok {
+			if src, ok := se.Sources[file]; ok {
+				writeWindow(&s, src, span, color)
+			}
+		}
+		for _, line := range lines[1:] {
+			s.WriteString(line + "\n")
+		}
+	}
+	_, err = io.WriteString(w, s.String())
+	return err
+}
+
+// splitErrors returns the errors joined in err, or err alone.
+func splitErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, splitErrors(e)...)
+	}
+	return errs
+}
+
+// errorSpan returns where err is: the name of its file, "" if it has none,
+// and its span, if it has one.
+func errorSpan(err error) (string, Span, bool) {
+	var le *LocatedError
+	var se spanError
+	var d *Diagnostic
+	var e *Error
+	switch {
+	case errors.As(err, &le):
+		return le.Loc.File, Span{From: le.Loc.Pos, To: le.Loc.Pos}, true
+	case errors.As(err, &se):
+		return "", se.ErrorSpan(), true
+	case errors.As(err, &d):
+		return "", d.Span, true
+	case errors.As(err, &e):
+		return "", Span{From: e.Pos, To: e.Pos}, true
+	}
+	return "", Span{}, false
+}
+
+// writeWindow writes the line of src span starts on, between the ones
+// around it, with the span underlined.
+func writeWindow(s *strings.Builder, src string, span Span, color bool) {
+	lines := strings.Split(src, "\n")
+	at := span.From.Line
+	if at < 1 || at > len(lines) {
+		return
+	}
+	first, last := max(at-1, 1), min(at+1, len(lines))
+	if last > at && last == len(lines) && lines[last-1] == "" {
+		last-- // after the newline ending the source
+	}
+	width := len(strconv.Itoa(last))
+	gutter := func(label, text string) {
+		s.WriteString(paint(fmt.Sprintf("%*s |", width, label), gutterColor, color))
+		if text != "" {
+			s.WriteString(" " + text)
+		}
+		s.WriteString("\n")
+	}
+	for i := first; i <= last; i++ {
+		line := strings.TrimRight(lines[i-1], "\r")
+		gutter(strconv.Itoa(i), line)
+		if i != at {
+			continue
+		}
+		rs := []rune(line)
+		from := min(max(span.From.Col-1, 0), len(rs))
+		to := from + 1
+		switch {
+		case span.To.Line == at && span.To.Col-1 > from:
+			to = span.To.Col - 1
+		case span.To.Line > at:
+			to = max(len(rs), to)
+		}
+		var pad strings.Builder
+		for _, r := range rs[:from] {
+			if r == '\t' {
+				pad.WriteRune('\t')
+			} else {
+				pad.WriteRune(' ')
+			}
+		}
+		gutter("", pad.String()+paint(strings.Repeat("^", to-from), markColor, color))
+	}
+}
+
+// paint returns s in the color of the SGR parameters sgr, if color is set.
+func paint(s, sgr string, color bool) string {
+	if !color || s == "" {
+		return s
+	}
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", sgr, s)
+}
+
+// UseColor tells whether to write colors to f: only if it's a terminal, and
+// neither NO_COLOR is set nor TERM is dumb.
+func UseColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
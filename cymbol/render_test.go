@@ -0,0 +1,100 @@
+package cymbol
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRender(t *testing.T) {
+	src := "int x = 1;\nint z = y + x;\nprint(z);\n"
+	var ds Diagnostics
+	ds.Add(Token{Text: "y", Pos: Pos{Line: 2, Col: 9}}.Span(), "undefined: y")
+	ds.Add(Span{From: Pos{Line: 1, Col: 9}, To: Pos{Line: 2, Col: 1}}, "to the next line")
+	_, syntax := ParseFile("void f() {\n\tint x = 1 2;\n}")
+	_, eof := ParseFile("int x = 1;\nint y")
+
+	tests := []struct {
+		name  string
+		err   error
+		color bool
+		want  string
+	}{
+		{
+			name: "diagnostics",
+			err:  WithSource(src, ds.Err()),
+			want: `1:9: to the next line
+1 | int x = 1;
+  |         ^^
+2 | int z = y + x;
+2:9: undefined: y
+1 | int x = 1;
+2 | int z = y + x;
+  |         ^
+3 | print(z);
+`,
+		},
+		{
+			name: "tabs",
+			err:  WithSource("void f() {\n\tint x = 1 2;\n}", syntax),
+			want: "2:12: syntax error: expecting Semicolon, found Int\n1 | void f() {\n2 | \tint x = 1 2;\n  | \t          ^\n3 | }\n",
+		},
+		{
+			name: "end of the input",
+			err:  WithSource("int x = 1;\nint y", eof),
+			want: "2:6: syntax error: expecting Semicolon, found EOF\n1 | int x = 1;\n2 | int y\n  |      ^\n",
+		},
+		{
+			name: "files",
+			err: &SourceError{
+				Sources: map[string]string{"a.cym": "int x;\n", "b.cym": strings.Repeat("\n", 9) + "int x;\n"},
+				Err: errors.Join(
+					&LocatedError{Location{File: "b.cym", Pos: Pos{Line: 10, Col: 5}}, errors.New("x redeclared, first declared at a.cym:1:5\n\tin main")},
+					&LocatedError{Location{File: "c.cym", Pos: Pos{Line: 1, Col: 1}}, errors.New("not in the sources")},
+				),
+			},
+			want: "b.cym:10:5: x redeclared, first declared at a.cym:1:5\n 9 |\n10 | int x;\n   |     ^\n\tin main\nc.cym:1:1: not in the sources\n",
+		},
+		{
+			name:  "colors",
+			err:   WithSource("int x = y;", errorAt(Pos{Line: 1, Col: 9}, "undefined: y")),
+			color: true,
+			want:  "\x1b[1m1:9: undefined: y\x1b[0m\n\x1b[90m1 |\x1b[0m int x = y;\n\x1b[90m  |\x1b[0m         \x1b[1;31m^\x1b[0m\n",
+		},
+		{
+			name: "no source",
+			err:  errors.New("no such file"),
+			want: "no such file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s strings.Builder
+			if err := Render(&s, tt.err, tt.color); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, s.String()); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if UseColor(f) {
+		t.Error("colors for a file")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if UseColor(os.Stdout) {
+		t.Error("colors with NO_COLOR set")
+	}
+}
@@ -0,0 +1,363 @@
+package cymbol
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rewriting source
+//
+// Apply changes the tree, and printing the tree back loses everything the
+// parser threw away: comments, blank lines, the layout and the parentheses
+// nobody needed. A refactoring tool has to change a program the way its
+// author would, editing a few places and leaving the rest of the text alone.
+// That's ANTLR's TokenStreamRewriter: the edits are recorded against the
+// tokens of the source and only applied when the text is asked for, every
+// byte not in an edit coming out as it went in.
+//
+// TokenStream keeps the tokens along with the text between them, the trivia:
+// whitespace and comments. Rewriter records edits to the nodes of a tree
+// parsed from the same source, renaming an identifier or wrapping a node in
+// new text:
+//
+//	int x = a + b; // sum     r.Rename(a, "first")
+//	                          r.Wrap(a + b, "abs(", ")")
+//	int x = abs(first + b); // sum
+//
+// A node is its tokens from its first to its last one, with the parentheses
+// around parts of it that the tree doesn't have: `(a) + b` is all of the
+// BinaryExpr, but the parentheses around all of `(a + b)` aren't.
+
+// TokenStream is the tokens of a source, and where they are in it.
+type TokenStream struct {
+	src    string
+	Tokens []Token // ending with EOF
+	starts []int   // byte offset of each token in src
+	lines  []int   // byte offset of each line
+}
+
+// NewTokenStream lexes the whole of src. The error is the lexer's.
+func NewTokenStream(src string) (*TokenStream, error) {
+	ts, err := scan(src)
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// scan lexes src up to its end or the first error, the stream holding the
+// tokens before the error then, and no EOF.
+func scan(src string) (*TokenStream, error) {
+	ts := &TokenStream{src: src, lines: []int{0}}
+	for i, c := range src {
+		if c == '\n' {
+			ts.lines = append(ts.lines, i+1)
+		}
+	}
+	lex := NewLexer(src)
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return ts, err
+		}
+		ts.Tokens = append(ts.Tokens, tok)
+		ts.starts = append(ts.starts, ts.offset(tok.Pos))
+		if tok.Type == EOF {
+			return ts, nil
+		}
+	}
+}
+
+// offset returns the byte offset of pos in the source.
+func (ts *TokenStream) offset(pos Pos) int {
+	// columns count runes from the start of the line
+	off := ts.lines[pos.Line-1]
+	for range pos.Col - 1 {
+		_, size := utf8.DecodeRuneInString(ts.src[off:])
+		off += size
+	}
+	return off
+}
+
+// Source returns the text the tokens were lexed from.
+func (ts *TokenStream) Source() string { return ts.src }
+
+// Trivia returns the text between token i and the one before it.
+func (ts *TokenStream) Trivia(i int) string {
+	from := 0
+	if i > 0 {
+		from = ts.end(i - 1)
+	}
+	return ts.src[from:ts.starts[i]]
+}
+
+// end returns the byte offset right after token i.
+func (ts *TokenStream) end(i int) int { return ts.starts[i] + len(ts.Tokens[i].Text) }
+
+// index returns the index of the token at pos, panicking if no token starts
+// there: the node isn't from this source.
+func (ts *TokenStream) index(pos Pos) int {
+	i, ok := slices.BinarySearchFunc(ts.Tokens, pos, func(t Token, p Pos) int {
+		return comparePos(t.Pos, p)
+	})
+	if !ok {
+		panic(fmt.Sprintf("no token at %v", pos))
+	}
+	return i
+}
+
+// Extent returns the indexes of the first and last tokens of n.
+func (ts *TokenStream) Extent(n Node) (first, last int) {
+	if f, ok := n.(*File); ok {
+		if len(f.Decls) == 0 {
+			eof := len(ts.Tokens) - 1
+			return eof, eof - 1
+		}
+		first, _ = ts.Extent(f.Decls[0])
+		_, last = ts.Extent(f.Decls[len(f.Decls)-1])
+		return first, last
+	}
+	return ts.balance(ts.index(n.Pos()), ts.last(n))
+}
+
+// last returns the index of the last token of n as the tree has it, which
+// can be missing parentheses.
+func (ts *TokenStream) last(n Node) int {
+	switch n := n.(type) {
+	case *VarDecl, *ReturnStmt, *AssignStmt, *ExprStmt:
+		return ts.semicolon(ts.index(n.Pos()))
+	case *FuncDecl:
+		return ts.index(n.Body.Rbrace)
+	case *Param:
+		return ts.index(n.Name.Pos())
+	case *StructDecl:
+		return ts.index(n.Rbrace) + 1
+	case *ClassDecl:
+		return ts.index(n.Rbrace) + 1
+	case *NamespaceDecl:
+		return ts.index(n.Rbrace)
+	case *ImportDecl:
+		return ts.index(n.Name.Pos()) + 1
+	case *Block:
+		return ts.index(n.Rbrace)
+	case *IfStmt:
+		if n.Else != nil {
+			return ts.last(n.Else)
+		}
+		return ts.last(n.Then)
+	case *WhileStmt:
+		return ts.last(n.Body)
+	case *BinaryExpr:
+		return ts.last(n.Y)
+	case *UnaryExpr:
+		return ts.last(n.X)
+	case *CallExpr:
+		// the parenthesis after the function is closed by the call's own
+		_, fun := ts.balance(ts.index(n.Fun.Pos()), ts.last(n.Fun))
+		_, rparen := ts.balance(fun+1, fun+1)
+		return rparen
+	case *MemberExpr:
+		return ts.index(n.Member.Pos())
+	}
+	return ts.index(n.Pos()) // a single token
+}
+
+// semicolon returns the index of the semicolon ending the statement starting
+// at token i, or of its last token if the input ends without one.
+func (ts *TokenStream) semicolon(i int) int {
+	for ; ts.Tokens[i].Type != EOF; i++ {
+		if ts.Tokens[i].Type == Semicolon {
+			return i
+		}
+	}
+	return i - 1
+}
+
+// balance widens the tokens from first to last to the parentheses closing
+// the ones opened in them, and opening the ones closed in them.
+func (ts *TokenStream) balance(first, last int) (int, int) {
+	depth, lowest := 0, 0
+	for _, tok := range ts.Tokens[first : last+1] {
+		switch tok.Type {
+		case LParen:
+			depth++
+		case RParen:
+			depth--
+			lowest = min(lowest, depth)
+		}
+	}
+	for open := -lowest; open > 0; {
+		first--
+		switch ts.Tokens[first].Type {
+		case LParen:
+			open--
+		case RParen:
+			open++
+		}
+	}
+	for unclosed := depth - lowest; unclosed > 0; {
+		last++
+		switch ts.Tokens[last].Type {
+		case LParen:
+			unclosed++
+		case RParen:
+			unclosed--
+		}
+	}
+	return first, last
+}
+
+// Rewriter records edits to the source of a TokenStream.
+type Rewriter struct {
+	ts       *TokenStream
+	inserts  []insert
+	replaces []replace
+}
+
+// insert is text added at a byte offset. Text inserted after a node goes
+// before the text inserted after it earlier, so that the wrappings of a node
+// and of another one inside it nest.
+type insert struct {
+	at    int
+	text  string
+	after bool
+	seq   int // order of the insertion
+}
+
+// replace is text in place of the bytes from, up to but not including, to.
+type replace struct {
+	from, to int
+	text     string
+}
+
+// NewRewriter returns a Rewriter for src, the source the nodes it edits are
+// parsed from. The error is the lexer's.
+func NewRewriter(src string) (*Rewriter, error) {
+	ts, err := NewTokenStream(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Rewriter{ts: ts}, nil
+}
+
+// Tokens returns the token stream of the source.
+func (r *Rewriter) Tokens() *TokenStream { return r.ts }
+
+// span returns the bytes taken by n.
+func (r *Rewriter) span(n Node) (from, to int) {
+	first, last := r.ts.Extent(n)
+	if last < first {
+		return r.ts.starts[first], r.ts.starts[first]
+	}
+	return r.ts.starts[first], r.ts.end(last)
+}
+
+// InsertBefore adds text right before n.
+func (r *Rewriter) InsertBefore(n Node, text string) {
+	from, _ := r.span(n)
+	r.insert(insert{at: from, text: text, seq: len(r.inserts)}, n)
+}
+
+// InsertAfter adds text right after n.
+func (r *Rewriter) InsertAfter(n Node, text string) {
+	_, to := r.span(n)
+	r.insert(insert{at: to, text: text, after: true, seq: len(r.inserts)}, n)
+}
+
+// Wrap puts n between before and after.
+func (r *Rewriter) Wrap(n Node, before, after string) {
+	r.InsertBefore(n, before)
+	r.InsertAfter(n, after)
+}
+
+// Replace puts text in place of n.
+func (r *Rewriter) Replace(n Node, text string) {
+	from, to := r.span(n)
+	r.replace(replace{from, to, text}, n)
+}
+
+// Rename puts name in place of the identifier id.
+func (r *Rewriter) Rename(id *Ident, name string) {
+	r.Replace(id, name)
+}
+
+// Delete removes n. If n is all there is on its lines, the lines go as well.
+func (r *Rewriter) Delete(n Node) {
+	from, to := r.span(n)
+	src := r.ts.src
+	start := strings.LastIndexByte(src[:from], '\n') + 1
+	end := len(src)
+	if i := strings.IndexByte(src[to:], '\n'); i >= 0 {
+		end = to + i + 1
+	}
+	if strings.TrimSpace(src[start:from]) == "" && strings.TrimSpace(src[to:end]) == "" {
+		from, to = start, end
+	}
+	r.replace(replace{from, to, ""}, n)
+}
+
+// insert records ins, panicking if it's inside a replaced node.
+func (r *Rewriter) insert(ins insert, n Node) {
+	for _, rep := range r.replaces {
+		if rep.from < ins.at && ins.at < rep.to {
+			panic(fmt.Sprintf("insert: %s is in an edited node", describe(n)))
+		}
+	}
+	r.inserts = append(r.inserts, ins)
+}
+
+// replace records rep, panicking if it overlaps another edit.
+func (r *Rewriter) replace(rep replace, n Node) {
+	for _, other := range r.replaces {
+		if rep.from < other.to && other.from < rep.to {
+			panic(fmt.Sprintf("replace: %s overlaps an edited node", describe(n)))
+		}
+	}
+	for _, ins := range r.inserts {
+		if rep.from < ins.at && ins.at < rep.to {
+			panic(fmt.Sprintf("replace: %s has text inserted in it", describe(n)))
+		}
+	}
+	r.replaces = append(r.replaces, rep)
+}
+
+// String returns the source with the edits applied.
+func (r *Rewriter) String() string {
+	// at the same offset the text inserted after a node goes before the text
+	// inserted before one, the latest first
+	inserts := slices.Clone(r.inserts)
+	slices.SortFunc(inserts, func(a, b insert) int {
+		switch {
+		case a.at != b.at:
+			return a.at - b.at
+		case a.after != b.after:
+			if a.after {
+				return -1
+			}
+			return 1
+		case a.after:
+			return b.seq - a.seq
+		}
+		return a.seq - b.seq
+	})
+	replaces := slices.Clone(r.replaces)
+	slices.SortFunc(replaces, func(a, b replace) int { return a.from - b.from })
+
+	var s strings.Builder
+	src, p := r.ts.src, 0
+	for len(inserts) > 0 || len(replaces) > 0 {
+		if len(replaces) == 0 || len(inserts) > 0 && inserts[0].at <= replaces[0].from {
+			s.WriteString(src[p:inserts[0].at])
+			s.WriteString(inserts[0].text)
+			p, inserts = inserts[0].at, inserts[1:]
+			continue
+		}
+		s.WriteString(src[p:replaces[0].from])
+		s.WriteString(replaces[0].text)
+		p, replaces = replaces[0].to, replaces[1:]
+	}
+	s.WriteString(src[p:])
+	return s.String()
+}
@@ -0,0 +1,195 @@
+package cymbol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// find returns the first node of the tree, in preorder, rendered as s.
+func find(t *testing.T, root Node, s string) Node {
+	t.Helper()
+	var found Node
+	Apply(root, func(c *Cursor) bool {
+		if found == nil && c.Node().String() == s {
+			found = c.Node()
+		}
+		return found == nil
+	}, nil)
+	if found == nil {
+		t.Fatalf("no node %s", s)
+	}
+	return found
+}
+
+func TestRewriter(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		edit  func(t *testing.T, r *Rewriter, f *File)
+		want  string
+	}{
+		{
+			name:  "no edits",
+			input: "// fact\nint fact(int n) {\n\tif (n < 2) return 1; // base\n\treturn n * fact(n - 1);\n}\n",
+			edit:  func(t *testing.T, r *Rewriter, f *File) {},
+			want:  "// fact\nint fact(int n) {\n\tif (n < 2) return 1; // base\n\treturn n * fact(n - 1);\n}\n",
+		},
+		{
+			name:  "rename",
+			input: "int x = a  +  b; // sum\n",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				r.Rename(find(t, f, "a").(*Ident), "first")
+			},
+			want: "int x = first  +  b; // sum\n",
+		},
+		{
+			name:  "wrap",
+			input: "int x = a + b; // sum\n",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				r.Rename(find(t, f, "a").(*Ident), "first")
+				r.Wrap(find(t, f, "(a + b)"), "abs(", ")")
+			},
+			want: "int x = abs(first + b); // sum\n",
+		},
+		{
+			name:  "nested wraps",
+			input: "int x = a + b;",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				r.Wrap(find(t, f, "(a + b)"), "f(", ")")
+				r.Wrap(find(t, f, "b"), "g(", ")")
+				r.Wrap(find(t, f, "(a + b)"), "h(", ")")
+			},
+			want: "int x = f(h(a + g(b)));",
+		},
+		{
+			name:  "parentheses inside",
+			input: "int x = (a) * (b + c);",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				r.Wrap(find(t, f, "(a * (b + c))"), "[", "]")
+				r.Wrap(find(t, f, "(b + c)"), "<", ">")
+			},
+			want: "int x = [(a) * (<b + c>)];",
+		},
+		{
+			name:  "calls",
+			input: "int x = f ( ) + g(a)(b);",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				r.Wrap(find(t, f, "f()"), "[", "]")
+				r.Replace(find(t, f, "g(a)(b)"), "h()")
+			},
+			want: "int x = [f ( )] + h();",
+		},
+		{
+			name:  "statements",
+			input: "void f() {\n  int x = 1;\n  x = x + 1; // twice\n  (x) = 2;\n  print(x);\n}",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				body := f.Decls[0].(*FuncDecl).Body
+				r.Delete(body.Stmts[0])
+				r.Wrap(body.Stmts[1], "/* ", " */")
+				r.Replace(body.Stmts[2], "y = 2;")
+				r.InsertAfter(body.Stmts[2], "\n  z = 3;")
+			},
+			want: "void f() {\n  /* x = x + 1; */ // twice\n  y = 2;\n  z = 3;\n  print(x);\n}",
+		},
+		{
+			name:  "declarations",
+			input: "int x;\n\nstruct s { int y; };\nvoid g() { if (x) { } else g(); }\n",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				r.Delete(f.Decls[0])
+				r.Wrap(f.Decls[1], "<", ">")
+				r.Wrap(find(t, f, "if (x) { } else g();"), "[", "]")
+				r.InsertBefore(f, "// top\n")
+			},
+			want: "// top\n\n<struct s { int y; };>\nvoid g() { [if (x) { } else g();] }\n",
+		},
+		{
+			name:  "unicode",
+			input: "string s = \"é\"; int x = a;",
+			edit: func(t *testing.T, r *Rewriter, f *File) {
+				r.Rename(find(t, f, "a").(*Ident), "b")
+			},
+			want: "string s = \"é\"; int x = b;",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ParseFile(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := NewRewriter(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.edit(t, r, f)
+			if got := r.String(); got != tc.want {
+				t.Error(cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestRewriterOverlap(t *testing.T) {
+	src := "int x = a + b;"
+	f, err := ParseFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		edit func(r *Rewriter)
+	}{
+		{
+			name: "replace in replaced",
+			edit: func(r *Rewriter) {
+				r.Replace(find(t, f, "(a + b)"), "c")
+				r.Rename(find(t, f, "a").(*Ident), "d")
+			},
+		},
+		{
+			name: "insert in replaced",
+			edit: func(r *Rewriter) {
+				r.Replace(find(t, f, "(a + b)"), "c")
+				r.InsertAfter(find(t, f, "a"), "d")
+			},
+		},
+		{
+			name: "replace around insert",
+			edit: func(r *Rewriter) {
+				r.InsertBefore(find(t, f, "b"), "d")
+				r.Delete(find(t, f, "(a + b)"))
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRewriter(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			tc.edit(r)
+		})
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	ts, err := NewTokenStream("int x; // x\n\n  x = 1;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for i := range ts.Tokens {
+		got = append(got, ts.Trivia(i))
+	}
+	want := []string{"", " ", "", " // x\n\n  ", " ", " ", "", ""}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,128 @@
+package cymbol
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"path/filepath"
+)
+
+// SARIF
+//
+// Code scanning services and editors read the results of analyzers in SARIF,
+// the Static Analysis Results Interchange Format: a JSON log of runs of
+// tools, each with the rules it checks and the results it found, located by
+// the URI of a file and a region in it. WriteSARIF writes the diagnostics as
+// a log of one run, for the checks of a program to show up where its code
+// is reviewed:
+//
+//	{"ruleId": "semantic", "level": "error", "message": {"text": "undefined: y"},
+//	 "locations": [{"physicalLocation": {"artifactLocation": {"uri": "prog.cym"},
+//	   "region": {"startLine": 1, "startColumn": 9, "endLine": 1, "endColumn": 10}}}]}
+//
+// The diagnostics have no codes, so there are two rules: syntax, for the
+// errors of the lexer and the parser, and semantic for the problems found in
+// the tree. All the results are errors. A column in SARIF counts UTF-16 code
+// units unless the run says otherwise, the run of a Cymbol tool says they're
+// code points, as its columns are. A diagnostic at a position, without a
+// span, takes the character there.
+
+// the version of SARIF written, and the schema of its logs
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// sarifRules are the rules of the results, by ID.
+var sarifRules = []sarifRule{
+	{ID: "syntax", ShortDescription: sarifMessage{"The program doesn't follow the grammar of Cymbol."}},
+	{ID: "semantic", ShortDescription: sarifMessage{"The program doesn't mean anything: an undefined name, a type error."}},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region sarifRegion `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// WriteSARIF writes the diagnostics as the SARIF log of a run of the tool
+// named, indented. Locate returns where a position of the source is, the
+// file of a Location being a path, as SourceMap.Locate returns them for a
+// program made of several files.
+func (ds *Diagnostics) WriteSARIF(w io.Writer, tool string, locate func(Pos) Location) error {
+	run := sarifRun{ColumnKind: "unicodeCodePoints", Results: []sarifResult{}}
+	run.Tool.Driver.Name = tool
+	run.Tool.Driver.Rules = sarifRules
+	for _, d := range ds.List() {
+		r := sarifResult{RuleID: "semantic", RuleIndex: 1, Level: "error", Message: sarifMessage{d.Message}}
+		var e *Error
+		if errors.As(d.err, &e) {
+			r.RuleID, r.RuleIndex = "syntax", 0
+		}
+		to := d.Span.To
+		if comparePos(to, d.Span.From) <= 0 {
+			to = Pos{Line: d.Span.From.Line, Col: d.Span.From.Col + 1}
+		}
+		from, end := locate(d.Span.From), locate(to)
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = (&url.URL{Path: filepath.ToSlash(from.File)}).String()
+		loc.PhysicalLocation.Region = sarifRegion{
+			StartLine:   from.Pos.Line,
+			StartColumn: from.Pos.Col,
+			EndLine:     end.Pos.Line,
+			EndColumn:   end.Pos.Col,
+		}
+		r.Locations = []sarifLocation{loc}
+		run.Results = append(run.Results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
@@ -0,0 +1,112 @@
+package cymbol
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	var ds Diagnostics
+	_, err := ParseFile("int x;\nint y = 2 3;")
+	ds.AddError(err)
+	ds.Add(Token{Text: "z", Pos: Pos{Line: 1, Col: 5}}.Span(), "undefined: %s", "z")
+	var s strings.Builder
+	locate := func(p Pos) Location { return Location{File: "src/my prog.cym", Pos: p} }
+	if err := ds.WriteSARIF(&s, "lip", locate); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "lip",
+          "rules": [
+            {
+              "id": "syntax",
+              "shortDescription": {
+                "text": "The program doesn't follow the grammar of Cymbol."
+              }
+            },
+            {
+              "id": "semantic",
+              "shortDescription": {
+                "text": "The program doesn't mean anything: an undefined name, a type error."
+              }
+            }
+          ]
+        }
+      },
+      "columnKind": "unicodeCodePoints",
+      "results": [
+        {
+          "ruleId": "semantic",
+          "ruleIndex": 1,
+          "level": "error",
+          "message": {
+            "text": "undefined: z"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "src/my%20prog.cym"
+                },
+                "region": {
+                  "startLine": 1,
+                  "startColumn": 5,
+                  "endLine": 1,
+                  "endColumn": 6
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "syntax",
+          "ruleIndex": 0,
+          "level": "error",
+          "message": {
+            "text": "syntax error: expecting Semicolon, found Int"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "src/my%20prog.cym"
+                },
+                "region": {
+                  "startLine": 2,
+                  "startColumn": 11,
+                  "endLine": 2,
+                  "endColumn": 12
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, s.String()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	s.Reset()
+	if err := new(Diagnostics).WriteSARIF(&s, "lip", locate); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct{ Results []any }
+	}
+	if err := json.Unmarshal([]byte(s.String()), &log); err != nil || len(log.Runs) != 1 || log.Runs[0].Results == nil {
+		t.Errorf("no diagnostics: got %s, want a run with no results", s.String())
+	}
+}
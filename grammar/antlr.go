@@ -0,0 +1,49 @@
+package grammar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// ANTLR
+//
+// ANTLR 4 reads the notation as it is, so WriteANTLR only lays the grammar
+// out the way ANTLR grammars usually are, one alternative per line:
+//
+//	grammar NestedNameList;
+//
+//	list
+//	    : '[' elements? ']'
+//	    ;
+//
+// A grammar that uses tokens without rules for them needs them added before
+// ANTLR can generate a lexer, and so does the whitespace the lexer should
+// skip, say with `WS : [ \t\r\n]+ -> skip ;`.
+
+// WriteANTLR writes g as an ANTLR 4 grammar. A grammar without a name is
+// named G, ANTLR needs one.
+func WriteANTLR(w io.Writer, g *Grammar) error {
+	bw := bufio.NewWriter(w)
+	name := g.Name
+	if name == "" {
+		name = "G"
+	}
+	fmt.Fprintf(bw, "grammar %s;\n", name)
+	for _, r := range g.Rules {
+		fmt.Fprintf(bw, "\n%s\n", r.Name)
+		for i, a := range r.Body.Alts {
+			sep := "|"
+			if i == 0 {
+				sep = ":"
+			}
+			if len(a.Items) == 0 {
+				fmt.Fprintf(bw, "    %s\n", sep)
+				continue
+			}
+			fmt.Fprintf(bw, "    %s %v\n", sep, a)
+		}
+		fmt.Fprintln(bw, "    ;")
+	}
+	return bw.Flush()
+}
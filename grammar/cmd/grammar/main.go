@@ -0,0 +1,61 @@
+// Command grammar reads a grammar in the notation of the book and writes it
+// for a parser generator:
+//
+//	grammar [-to antlr|yacc] [file]
+//
+// ANTLR 4 is the default. The grammar is read from the file named, or the
+// standard input if there's none or it's "-".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"example.com/grammar"
+)
+
+// writers write a grammar in each format, by the name -to takes
+var writers = map[string]func(io.Writer, *grammar.Grammar) error{
+	"antlr": grammar.WriteANTLR,
+	"yacc":  grammar.WriteYacc,
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("grammar", flag.ContinueOnError)
+	to := fs.String("to", "antlr", "the format, antlr or yacc")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	write, ok := writers[*to]
+	if !ok {
+		return fmt.Errorf("grammar: unknown format %q, expecting antlr or yacc", *to)
+	}
+	var b []byte
+	var err error
+	switch {
+	case fs.NArg() == 0 || fs.NArg() == 1 && fs.Arg(0) == "-":
+		b, err = io.ReadAll(in)
+	case fs.NArg() == 1:
+		b, err = os.ReadFile(fs.Arg(0))
+	default:
+		return fmt.Errorf("grammar: one file at most, got %s", strings.Join(fs.Args(), " "))
+	}
+	if err != nil {
+		return err
+	}
+	g, err := grammar.Parse(string(b))
+	if err != nil {
+		return err
+	}
+	return write(out, g)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	const lists = "grammar Lists;\nlist : '[' NAME* ']' ;\n"
+	cases := []struct {
+		name    string
+		args    []string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "antlr",
+			in:   lists,
+			want: "grammar Lists;\n\nlist\n    : '[' NAME* ']'\n    ;\n",
+		},
+		{
+			name: "yacc",
+			args: []string{"-to", "yacc", "-"},
+			in:   lists,
+			want: "/* Lists */\n\n%token NAME\n%start list\n\n%%\n\nlist\n    : '[' list_1 ']'\n    ;\n\nlist_1\n    : /* empty */\n    | list_1 NAME\n    ;\n\n%%\n",
+		},
+		{
+			name:    "format",
+			args:    []string{"-to", "bison"},
+			wantErr: `grammar: unknown format "bison", expecting antlr or yacc`,
+		},
+		{
+			name:    "syntax error",
+			in:      "list : '[' NAME* ']'",
+			wantErr: "1:21: syntax error: expecting ';', found EOF",
+		},
+		{
+			name:    "files",
+			args:    []string{"a.g", "b.g"},
+			wantErr: "grammar: one file at most, got a.g b.g",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			err := run(tc.args, strings.NewReader(tc.in), &out)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
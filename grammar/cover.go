@@ -0,0 +1,304 @@
+package grammar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Coverage
+//
+// A test corpus exercises a grammar: each program in it takes some of the
+// alternatives of the rules and leaves out others, and the ones no program
+// takes are the tests missing. The parse trees tell which were taken. The
+// children of a rule node are what the rule matched, and matching them
+// against the rule again says which of its alternatives did, which
+// alternative of each group inside it and how many times each ?, * and +
+// went round. Any parser that records parse trees can be audited that way,
+// without knowing how it decides: the chapter 3 parser records them with its
+// enter method.
+//
+// The decisions of a rule, and their branches, are:
+//
+//	r : a | b ;   the rule itself, a branch per alternative, even if it's
+//	              the only one: the rule has been used or not
+//	(a | b)       a group of alternatives, a branch per alternative
+//	x? x* x+      a repetition, two branches: none and one, none and some,
+//	              one and more
+//
+// Coverage adds up the branches the trees of a corpus take, and Report lists
+// the ones none of them did. Lexer rules are left out, the tokens being the
+// leaves of the parse trees.
+
+// Node is a node of a parse tree, either a parser rule and what it matched
+// or a token: its type, as named in the grammar, and text. Literals match
+// tokens by text, references to tokens by type, EOF being the end of the
+// input.
+type Node struct {
+	Rule     string
+	Children []*Node
+
+	Token, Text string
+}
+
+// Branch is a branch of a decision of a rule: Alt is the alternative of a
+// group of alternatives, or for a repetition 0 for the fewer times, none or
+// one, and 1 for the more. In is the decision it's nested in, if it's not
+// the rule's. Count is how many times it was taken.
+type Branch struct {
+	Rule     *Rule
+	Decision Expr // *Alt or *Repeat
+	Alt      int
+	In       Expr
+	Count    int
+}
+
+// repetitions names the branches of each repetition
+var repetitions = map[byte][2]string{'?': {"none", "one"}, '*': {"none", "some"}, '+': {"one", "more"}}
+
+// String describes b as a part of its rule:
+//
+//	'[' elements? ']'        the alternative of a rule
+//	'!=' of ('==' | '!=')    the alternative of a group
+//	elements? matching none  the repetition matching none, one, some or more
+//
+// followed by the decision it's nested in, if any, for the same decision can
+// be in a rule twice: `stat? matching none in (sep stat?)*`.
+func (b Branch) String() string {
+	var s string
+	switch x := b.Decision.(type) {
+	case *Alt:
+		s = x.Alts[b.Alt].String()
+		if s == "" {
+			s = "nothing"
+		}
+		if x != b.Rule.Body {
+			s = fmt.Sprintf("%s of (%v)", s, x)
+		}
+	case *Repeat:
+		s = fmt.Sprintf("%v matching %s", x, repetitions[x.Op][b.Alt])
+	}
+	if b.In != nil {
+		s += " in " + atom(b.In)
+	}
+	return s
+}
+
+type branch struct {
+	decision Expr
+	alt      int
+}
+
+// Coverage is how many times each branch of the parser rules of a grammar
+// was taken by the parse trees added.
+type Coverage struct {
+	g     *Grammar
+	taken map[branch]int
+}
+
+func NewCoverage(g *Grammar) *Coverage {
+	return &Coverage{g: g, taken: map[branch]int{}}
+}
+
+// Add adds the branches taken by the parse tree t. It fails if t doesn't
+// match the grammar: then the parser and the grammar disagree, and one of
+// them is wrong.
+func (c *Coverage) Add(t *Node) error {
+	if t.Rule == "" {
+		return nil
+	}
+	r := c.g.Rule(t.Rule)
+	if r == nil || r.Lexer() {
+		return fmt.Errorf("no parser rule %s in the grammar", t.Rule)
+	}
+	m := &matcher{root: r.Body, nodes: t.Children}
+	if !m.match(r.Body, 0, func(i int) bool { return i == len(t.Children) }) {
+		return fmt.Errorf("%s doesn't match rule %s : %v ;", treeString(t), r.Name, r.Body)
+	}
+	for _, b := range m.taken {
+		c.taken[b]++
+	}
+	for _, child := range t.Children {
+		if err := c.Add(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Branches returns the branches of all parser rules, in the order they're
+// written.
+func (c *Coverage) Branches() []Branch {
+	var branches []Branch
+	for _, r := range c.g.Rules {
+		if r.Lexer() {
+			continue
+		}
+		// the decisions each decision is in, innermost last
+		var in []Expr
+		var visit func(x Expr)
+		visit = func(x Expr) {
+			n := 0
+			switch x := x.(type) {
+			case *Alt:
+				if x == r.Body || len(x.Alts) > 1 {
+					n = len(x.Alts)
+				}
+			case *Repeat:
+				n = 2
+			}
+			for alt := range n {
+				b := Branch{Rule: r, Decision: x, Alt: alt, Count: c.taken[branch{x, alt}]}
+				if len(in) > 1 {
+					b.In = in[len(in)-1]
+				}
+				branches = append(branches, b)
+			}
+			if n > 0 {
+				in = append(in, x)
+				defer func() { in = in[:len(in)-1] }()
+			}
+			switch x := x.(type) {
+			case *Alt:
+				for _, s := range x.Alts {
+					visit(s)
+				}
+			case *Seq:
+				for _, item := range x.Items {
+					visit(item)
+				}
+			case *Repeat:
+				visit(x.X)
+			}
+		}
+		visit(r.Body)
+	}
+	return branches
+}
+
+// Uncovered returns the branches never taken.
+func (c *Coverage) Uncovered() []Branch {
+	var uncovered []Branch
+	for _, b := range c.Branches() {
+		if b.Count == 0 {
+			uncovered = append(uncovered, b)
+		}
+	}
+	return uncovered
+}
+
+// Report writes the branches never taken, one per line with the position of
+// their rule, and how many of all the branches were:
+//
+//	5:1: elements: ','? matching one
+//	21 of 24 branches covered
+func (c *Coverage) Report(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	branches := c.Branches()
+	covered := 0
+	for _, b := range branches {
+		if b.Count > 0 {
+			covered++
+			continue
+		}
+		fmt.Fprintf(bw, "%v: %s: %v\n", b.Rule.Pos, b.Rule.Name, b)
+	}
+	fmt.Fprintf(bw, "%d of %d branches covered\n", covered, len(branches))
+	return bw.Flush()
+}
+
+// matcher matches the children of a rule node against the rule, by
+// backtracking: each expression calls k with each place in nodes where it
+// could end, until k accepts one. taken is the branches on the way to the
+// current place, and is left with the ones of the match found.
+type matcher struct {
+	root  *Alt
+	nodes []*Node
+	taken []branch
+}
+
+func (m *matcher) match(x Expr, i int, k func(int) bool) bool {
+	switch x := x.(type) {
+	case *Alt:
+		decision := x == m.root || len(x.Alts) > 1
+		for alt, s := range x.Alts {
+			if decision {
+				m.taken = append(m.taken, branch{x, alt})
+			}
+			if m.match(s, i, k) {
+				return true
+			}
+			if decision {
+				m.taken = m.taken[:len(m.taken)-1]
+			}
+		}
+		return false
+	case *Seq:
+		return m.seq(x.Items, i, k)
+	case *Ref:
+		if i == len(m.nodes) {
+			return false
+		}
+		n := m.nodes[i]
+		if n.Rule == x.Name || n.Rule == "" && n.Token == x.Name {
+			return k(i + 1)
+		}
+		return false
+	case *Lit:
+		if i < len(m.nodes) && m.nodes[i].Rule == "" && m.nodes[i].Text == x.Text {
+			return k(i + 1)
+		}
+		return false
+	case *Repeat:
+		return m.repeat(x, i, 0, k)
+	}
+	// character sets are in lexer rules only
+	return false
+}
+
+func (m *matcher) seq(items []Expr, i int, k func(int) bool) bool {
+	if len(items) == 0 {
+		return k(i)
+	}
+	return m.match(items[0], i, func(j int) bool { return m.seq(items[1:], j, k) })
+}
+
+// repeat matches x.X once more, as many times as it can, having matched it n
+// times up to i. Each time has to match something, or x* would go round
+// forever on x.X matching nothing.
+func (m *matcher) repeat(x *Repeat, i, n int, k func(int) bool) bool {
+	if x.Op != '?' || n == 0 {
+		more := func(j int) bool { return j > i && m.repeat(x, j, n+1, k) }
+		if m.match(x.X, i, more) {
+			return true
+		}
+	}
+	alt := 0
+	switch {
+	case x.Op == '+' && n == 0:
+		return false
+	case x.Op == '+' && n > 1, x.Op != '+' && n > 0:
+		alt = 1
+	}
+	m.taken = append(m.taken, branch{x, alt})
+	if k(i) {
+		return true
+	}
+	m.taken = m.taken[:len(m.taken)-1]
+	return false
+}
+
+// treeString renders t as an S-expression, the way chapter 3 does.
+func treeString(t *Node) string {
+	if t.Rule == "" && t.Text == "" {
+		return "<" + t.Token + ">"
+	}
+	if t.Rule == "" {
+		return t.Text
+	}
+	s := "(" + t.Rule
+	for _, c := range t.Children {
+		s += " " + treeString(c)
+	}
+	return s + ")"
+}
@@ -0,0 +1,182 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// tree reads a parse tree written as an S-expression, the leaves being
+// tokens: TYPE:text, or punctuation and keywords as they are.
+func tree(t *testing.T, s string) *Node {
+	fields := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s))
+	var read func() *Node
+	read = func() *Node {
+		f := fields[0]
+		fields = fields[1:]
+		if f != "(" {
+			typ, text, ok := strings.Cut(f, ":")
+			if !ok || typ == "" || text == "" {
+				typ, text = "T", f
+			}
+			return &Node{Token: typ, Text: text}
+		}
+		n := &Node{Rule: fields[0]}
+		fields = fields[1:]
+		for fields[0] != ")" {
+			n.Children = append(n.Children, read())
+		}
+		fields = fields[1:]
+		return n
+	}
+	n := read()
+	if len(fields) > 0 {
+		t.Fatalf("%s: more than a tree", s)
+	}
+	return n
+}
+
+const lists = `
+list     : '[' elements? ']' ;
+elements : element (',' element)* ','? ;
+element  : NAME ('=' | ':') element | list | 'true' ;
+`
+
+func TestCoverage(t *testing.T) {
+	cases := []struct {
+		name  string
+		trees []string
+		want  []string
+	}{
+		{
+			name: "none",
+			want: []string{
+				"2:1: list: '[' elements? ']'",
+				"2:1: list: elements? matching none",
+				"2:1: list: elements? matching one",
+				"3:1: elements: element (',' element)* ','?",
+				"3:1: elements: (',' element)* matching none",
+				"3:1: elements: (',' element)* matching some",
+				"3:1: elements: ','? matching none",
+				"3:1: elements: ','? matching one",
+				"4:1: element: NAME ('=' | ':') element",
+				"4:1: element: list",
+				"4:1: element: 'true'",
+				"4:1: element: '=' of ('=' | ':')",
+				"4:1: element: ':' of ('=' | ':')",
+				"0 of 13 branches covered",
+			},
+		},
+		{
+			name:  "empty list",
+			trees: []string{"(list [ ])"},
+			want: []string{
+				"2:1: list: elements? matching one",
+				"3:1: elements: element (',' element)* ','?",
+				"3:1: elements: (',' element)* matching none",
+				"3:1: elements: (',' element)* matching some",
+				"3:1: elements: ','? matching none",
+				"3:1: elements: ','? matching one",
+				"4:1: element: NAME ('=' | ':') element",
+				"4:1: element: list",
+				"4:1: element: 'true'",
+				"4:1: element: '=' of ('=' | ':')",
+				"4:1: element: ':' of ('=' | ':')",
+				"2 of 13 branches covered",
+			},
+		},
+		{
+			name: "all",
+			trees: []string{
+				"(list [ (elements (element NAME:a = (element true)) , (element (list [ ])) ,) ])",
+				"(list [ (elements (element NAME:b : (element true))) ])",
+			},
+			want: []string{"13 of 13 branches covered"},
+		},
+		{
+			// the trailing comma could be a second ',' element, backtracking
+			// tells it isn't
+			name:  "trailing comma",
+			trees: []string{"(list [ (elements (element true) , (element true) ,) ])"},
+			want: []string{
+				"2:1: list: elements? matching none",
+				"3:1: elements: (',' element)* matching none",
+				"3:1: elements: ','? matching none",
+				"4:1: element: NAME ('=' | ':') element",
+				"4:1: element: list",
+				"4:1: element: '=' of ('=' | ':')",
+				"4:1: element: ':' of ('=' | ':')",
+				"6 of 13 branches covered",
+			},
+		},
+	}
+
+	g, err := Parse(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCoverage(g)
+			for _, s := range tc.trees {
+				if err := c.Add(tree(t, s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var b strings.Builder
+			if err := c.Report(&b); err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCoverageCounts(t *testing.T) {
+	g, err := Parse("s : (A | B)+ ;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCoverage(g)
+	for _, s := range []string{"(s A:a)", "(s A:a B:b A:a)"} {
+		if err := c.Add(tree(t, s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, b := range c.Branches() {
+		got = append(got, b.String()+" "+strings.Repeat("x", b.Count))
+	}
+	want := []string{"(A | B)+ xx", "(A | B)+ matching one x", "(A | B)+ matching more x", "A of (A | B) in (A | B)+ xxx", "B of (A | B) in (A | B)+ x"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestCoverageMismatch(t *testing.T) {
+	g, err := Parse(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		tree string
+		want string
+	}{
+		{tree: "(list [ (elements) ])", want: "(elements) doesn't match rule elements : element (',' element)* ','? ;"},
+		{tree: "(list [ (elements (element false)) ])", want: "(element false) doesn't match rule element : NAME ('=' | ':') element | list | 'true' ;"},
+		{tree: "(NAME)", want: "no parser rule NAME in the grammar"},
+		{tree: "(lists [ ])", want: "no parser rule lists in the grammar"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.tree, func(t *testing.T) {
+			err := NewCoverage(g).Add(tree(t, tc.tree))
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("got error %v, want %s", err, tc.want)
+			}
+		})
+	}
+}
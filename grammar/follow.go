@@ -0,0 +1,175 @@
+package grammar
+
+import "fmt"
+
+// What comes next
+//
+// An editor completing a program needs to know what can come after the text
+// before the cursor: the tokens an LL parser would accept as its next
+// lookahead there. The textbooks get them from FIRST and FOLLOW sets: the
+// FIRST set of what's left of the rule being matched, and if all of it can
+// match nothing, the FOLLOW set of the rule, the tokens that can come after
+// it. A FOLLOW set is for anywhere the rule is used though, and the
+// completions of `[a` shouldn't include '}' because an element can end a
+// pair of a map elsewhere.
+//
+// Follow gets the FOLLOW set of the cursor instead, narrowed to the rules on
+// the way to it: it runs the grammar on the tokens, keeping every way they
+// can be matched as a stack of what's left to match, innermost first. Once
+// the tokens run out, the terminals on top of the stacks are what can come
+// next. A rule on top that can match nothing gives way to what's under it,
+// the part of the rule's FOLLOW set that's right there.
+//
+// Every way the tokens can be matched is kept, a grammar doesn't have to be
+// LL(1), or LL(k) for any k: both alternatives of `stat : list | assign ;`
+// are tried for as long as the tokens match them. It mustn't be left
+// recursive, the expansion of `e : e '+' t ;` doesn't end, which no parser
+// of the book handles either.
+
+// stack is what's left to match, x first: a rule, a part of one, or a
+// terminal. The empty stack, nil, is the end of the start rule.
+type stack struct {
+	x    Expr
+	next *stack
+}
+
+// follower expands the stacks for one token: seen has the stacks expanded
+// already, the same stack being reached by different ways.
+type follower struct {
+	g     *Grammar
+	seen  map[stack]bool
+	plus  map[*Repeat]*Repeat // x* for each x+
+	tops  []*stack
+	ended bool
+}
+
+// FollowError is the error of Follow for tokens no program of Rule starts
+// with: the one at Index, from zero, can't come after the ones before it.
+type FollowError struct {
+	Rule  string
+	Index int
+	Token *Node
+}
+
+func (e *FollowError) Error() string {
+	return fmt.Sprintf("no program of %s starts with the tokens up to %s %q, token %d", e.Rule, e.Token.Token, e.Token.Text, e.Index+1)
+}
+
+// Follow returns the terminals that can come after the tokens given in a
+// program of the start rule, the first parser rule: references to tokens and
+// literals, in the order of the grammar, with EOF if the program can end
+// there. The tokens are Nodes without a Rule, as in a parse tree. It fails
+// with a *FollowError if no program starts with the tokens.
+func (g *Grammar) Follow(tokens []*Node) ([]Expr, error) {
+	var start *Rule
+	for _, r := range g.Rules {
+		if !r.Lexer() {
+			start = r
+			break
+		}
+	}
+	if start == nil {
+		return nil, fmt.Errorf("no parser rule in grammar %s", g.Name)
+	}
+	f := &follower{g: g, plus: map[*Repeat]*Repeat{}}
+	f.expandAll([]*stack{{x: &Ref{Name: start.Name}}})
+	for i, tok := range tokens {
+		var next []*stack
+		for _, s := range f.tops {
+			if terminalMatches(s.x, tok) {
+				next = append(next, s.next)
+			}
+		}
+		if len(next) == 0 {
+			return nil, &FollowError{Rule: start.Name, Index: i, Token: tok}
+		}
+		f.expandAll(next)
+	}
+
+	var terms []Expr
+	seen := map[string]bool{}
+	add := func(x Expr) {
+		if !seen[x.String()] {
+			seen[x.String()] = true
+			terms = append(terms, x)
+		}
+	}
+	for _, s := range f.tops {
+		add(s.x)
+	}
+	if f.ended {
+		add(&Ref{Name: "EOF"})
+	}
+	return terms, nil
+}
+
+// expandAll expands each stack until a terminal is on top, replacing the
+// tops of f with them.
+func (f *follower) expandAll(stacks []*stack) {
+	f.seen, f.tops, f.ended = map[stack]bool{}, nil, false
+	for _, s := range stacks {
+		f.expand(s)
+	}
+}
+
+// expand expands the rule, or the part of one, on top of s, in every way it
+// can be, until there's a terminal on top.
+func (f *follower) expand(s *stack) {
+	if s == nil {
+		f.ended = true
+		return
+	}
+	if f.seen[*s] {
+		return
+	}
+	f.seen[*s] = true
+	switch x := s.x.(type) {
+	case *Ref:
+		if r := f.g.Rule(x.Name); r != nil && !r.Lexer() {
+			f.expand(&stack{r.Body, s.next})
+			return
+		}
+		f.tops = append(f.tops, s)
+	case *Lit:
+		f.tops = append(f.tops, s)
+	case *Alt:
+		for _, a := range x.Alts {
+			f.expand(&stack{a, s.next})
+		}
+	case *Seq:
+		next := s.next
+		for i := len(x.Items) - 1; i >= 0; i-- {
+			next = &stack{x.Items[i], next}
+		}
+		f.expand(next)
+	case *Repeat:
+		switch x.Op {
+		case '?':
+			f.expand(&stack{x.X, s.next})
+			f.expand(s.next)
+		case '*':
+			f.expand(&stack{x.X, s})
+			f.expand(s.next)
+		case '+':
+			star, ok := f.plus[x]
+			if !ok {
+				star = &Repeat{X: x.X, Op: '*'}
+				f.plus[x] = star
+			}
+			f.expand(&stack{x.X, &stack{star, s.next}})
+		}
+	}
+	// character sets are in lexer rules only, which aren't expanded
+}
+
+// terminalMatches tells whether tok is the terminal x: literals match tokens
+// by text, references by type.
+func terminalMatches(x Expr, tok *Node) bool {
+	switch x := x.(type) {
+	case *Ref:
+		return tok.Token == x.Name
+	case *Lit:
+		return tok.Text == x.Text
+	}
+	return false
+}
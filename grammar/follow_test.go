@@ -0,0 +1,117 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFollow(t *testing.T) {
+	cases := []struct {
+		name    string
+		grammar string
+		tokens  string // TYPE:text, or punctuation and keywords as they are
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "start",
+			grammar: lists,
+			want:    "'['",
+		},
+		{
+			name:    "first of a rule",
+			grammar: lists,
+			tokens:  "[",
+			want:    "NAME '[' 'true' ']'",
+		},
+		{
+			name:    "follow of the rule in its place",
+			grammar: lists,
+			tokens:  "[ true",
+			want:    "',' ']'",
+		},
+		{
+			name:    "more than one token ahead",
+			grammar: lists,
+			tokens:  "[ NAME:a",
+			want:    "'=' ':'",
+		},
+		{
+			name:    "nested",
+			grammar: lists,
+			tokens:  "[ NAME:a = [ true ] ,",
+			want:    "NAME '[' 'true' ']'",
+		},
+		{
+			name:    "end",
+			grammar: lists,
+			tokens:  "[ ]",
+			want:    "EOF",
+		},
+		{
+			name:    "end of the start rule",
+			grammar: "prog : stat+ EOF ; stat : 'x' ';'? ;",
+			tokens:  "x",
+			want:    "';' 'x' EOF",
+		},
+		{
+			name:    "alternatives with the same start",
+			grammar: "stat : list | list '=' list ; list : '[' ']' ;",
+			tokens:  "[ ]",
+			want:    "'=' EOF",
+		},
+		{
+			name:    "repetition of what can match nothing",
+			grammar: "r : ('a'? 'b'?)* 'c' ;",
+			tokens:  "a",
+			want:    "'b' 'a' 'c'",
+		},
+		{
+			name:    "not a program",
+			grammar: lists,
+			tokens:  "[ true true",
+			wantErr: `no program of list starts with the tokens up to T "true", token 3`,
+		},
+		{
+			name:    "no parser rule",
+			grammar: "grammar Tokens; NAME : 'a'..'z'+ ;",
+			wantErr: "no parser rule in grammar Tokens",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := Parse(tc.grammar)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var toks []*Node
+			for _, f := range strings.Fields(tc.tokens) {
+				typ, text, ok := strings.Cut(f, ":")
+				if !ok {
+					typ, text = "T", f
+				}
+				toks = append(toks, &Node{Token: typ, Text: text})
+			}
+			terms, err := g.Follow(toks)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, x := range terms {
+				got = append(got, x.String())
+			}
+			if diff := cmp.Diff(tc.want, strings.Join(got, " ")); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+module example.com/grammar
+
+go 1.23.4
+
+require (
+	github.com/google/go-cmp v0.6.0
+	golang.org/x/tools v0.28.0
+)
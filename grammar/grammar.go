@@ -0,0 +1,205 @@
+// Package grammar reads grammars written in the notation the parsers of the
+// book are documented with, a subset of ANTLR's, and writes them out for
+// parser generators: ANTLR 4 and yacc, as bison takes it. It also tells how
+// much of a grammar the parse trees of a test corpus cover, and which tokens
+// can come next in a program, for completion.
+package grammar
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Grammars
+//
+// Each parser of the book starts from a grammar, in a comment above it:
+//
+//	grammar NestedNameList;
+//	list     : '[' elements? ']' ;
+//	elements : element (',' element)* ;
+//	element  : NAME | list ;
+//	NAME     : ('a'..'z'|'A'..'Z')+ ;
+//
+// A Grammar holds one as data: its rules, each a choice between alternatives
+// that are sequences of references to rules, literals, groups and their
+// repetitions. Rules whose name starts with an uppercase letter are lexer
+// rules, as in ANTLR: they describe tokens, and are the only ones where
+// character ranges, ~ and . make sense.
+//
+// This is EBNF, with ?, * and + and nested alternatives, which ANTLR reads as
+// is. yacc only reads BNF, each rule a list of alternatives of symbols, so
+// WriteYacc makes up a rule for each repetition and group.
+
+// Grammar is a grammar, its rules in the order they're written. The first
+// parser rule is the start rule.
+type Grammar struct {
+	Name  string
+	Rules []*Rule
+}
+
+// Rule is a rule, Body the alternatives it chooses from.
+type Rule struct {
+	Pos  Pos
+	Name string
+	Body *Alt
+}
+
+// Lexer tells whether r is a lexer rule.
+func (r *Rule) Lexer() bool { return isToken(r.Name) }
+
+// Rule returns the rule named name, nil if there's none.
+func (g *Grammar) Rule(name string) *Rule {
+	for _, r := range g.Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Pos is a position in the text of a grammar.
+type Pos struct {
+	Line, Col int
+}
+
+func (p Pos) String() string { return fmt.Sprintf("%d:%d", p.Line, p.Col) }
+
+// Expr is the body of a rule or a part of it.
+type Expr interface {
+	String() string
+	expr()
+}
+
+type (
+	// Alt is a choice between alternatives: `a | b c | d`. An empty Seq is
+	// an alternative matching nothing.
+	Alt struct {
+		Alts []*Seq
+	}
+
+	// Seq is a sequence: `a b c`.
+	Seq struct {
+		Items []Expr
+	}
+
+	// Ref is a reference to a rule or a token: `expr`, `ID`. EOF is the end
+	// of the input.
+	Ref struct {
+		Name string
+	}
+
+	// Lit is a literal, a token or a character: `'while'`, `'a'`. Text is
+	// without the quotes, escapes replaced.
+	Lit struct {
+		Text string
+	}
+
+	// Range is a character range, in lexer rules: `'a'..'z'`.
+	Range struct {
+		From, To string
+	}
+
+	// Not is any character but the ones of X, in lexer rules: `~('"'|'\n')`.
+	Not struct {
+		X Expr
+	}
+
+	// Any is any character, in lexer rules: `.`.
+	Any struct{}
+
+	// Repeat is X optional, repeated or repeated at least once: `x?`, `x*`,
+	// `x+`, Op telling which.
+	Repeat struct {
+		X  Expr
+		Op byte
+	}
+)
+
+func (*Alt) expr()    {}
+func (*Seq) expr()    {}
+func (*Ref) expr()    {}
+func (*Lit) expr()    {}
+func (*Range) expr()  {}
+func (*Not) expr()    {}
+func (*Any) expr()    {}
+func (*Repeat) expr() {}
+
+// String renders the expressions in the notation they're read in, with
+// parentheses where the notation needs them.
+func (x *Alt) String() string {
+	alts := make([]string, len(x.Alts))
+	for i, a := range x.Alts {
+		alts[i] = a.String()
+	}
+	return strings.Join(alts, " | ")
+}
+
+func (x *Seq) String() string {
+	items := make([]string, len(x.Items))
+	for i, item := range x.Items {
+		items[i] = item.String()
+		if a, ok := item.(*Alt); ok && len(a.Alts) > 1 {
+			items[i] = "(" + items[i] + ")"
+		}
+	}
+	return strings.Join(items, " ")
+}
+
+func (x *Ref) String() string   { return x.Name }
+func (x *Lit) String() string   { return quote(x.Text) }
+func (x *Range) String() string { return quote(x.From) + ".." + quote(x.To) }
+func (x *Not) String() string   { return "~" + atom(x.X) }
+func (x *Any) String() string   { return "." }
+
+func (x *Repeat) String() string { return atom(x.X) + string(x.Op) }
+
+// atom renders x as an operand of ~ or of a repetition.
+func atom(x Expr) string {
+	switch x := x.(type) {
+	case *Alt:
+		if len(x.Alts) == 1 && len(x.Alts[0].Items) == 1 {
+			return atom(x.Alts[0].Items[0])
+		}
+	case *Seq:
+		if len(x.Items) == 1 {
+			return atom(x.Items[0])
+		}
+	default:
+		return x.String()
+	}
+	return "(" + x.String() + ")"
+}
+
+// quote quotes a literal, escaping what needs to be.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// isToken tells whether name is the name of a token, which starts with an
+// uppercase letter.
+func isToken(name string) bool {
+	for _, r := range name {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package grammar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/txtar"
+)
+
+func TestExport(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/export.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, file := range ar.Files {
+		files[file.Name] = string(file.Data)
+	}
+
+	writers := map[string]func(*strings.Builder, *Grammar) error{
+		".g4": func(b *strings.Builder, g *Grammar) error { return WriteANTLR(b, g) },
+		".y":  func(b *strings.Builder, g *Grammar) error { return WriteYacc(b, g) },
+	}
+	for _, file := range ar.Files {
+		name, ok := strings.CutSuffix(file.Name, ".g")
+		if !ok {
+			continue
+		}
+		g, err := Parse(string(file.Data))
+		if err != nil {
+			t.Fatalf("%s: %v", file.Name, err)
+		}
+		for ext, write := range writers {
+			want, ok := files[name+ext]
+			if !ok {
+				continue
+			}
+			t.Run(name+ext, func(t *testing.T) {
+				var b strings.Builder
+				if err := write(&b, g); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(want, b.String()); diff != "" {
+					t.Errorf("(-want +got):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{src: "r : A B | C ;", want: "A B | C"},
+		{src: "r : (A | B) C ;", want: "(A | B) C"},
+		{src: "r : (A) (B C) ;", want: "A B C"},
+		{src: "r : (A B)* C? (D | E)+ ;", want: "(A B)* C? (D | E)+"},
+		{src: "r : A | ;", want: "A | "},
+		{src: `R : ~('"' | '\\' | '\n')* ;`, want: `~('"' | '\\' | '\n')*`},
+		{src: "R : 'a'..'z' . '\\'' ;", want: `'a'..'z' . '\''`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.src, func(t *testing.T) {
+			g, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := g.Rules[0].Body.String(); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		want   string
+		syntax bool
+	}{
+		{name: "no colon", src: "r a ;", want: "1:3: syntax error: expecting ':', found a", syntax: true},
+		{name: "no semicolon", src: "r : a", want: "1:6: syntax error: expecting ';', found EOF", syntax: true},
+		{name: "unclosed group", src: "r : (a ;", want: "1:8: syntax error: expecting ')', found ';'", syntax: true},
+		{name: "no atom", src: "r : a | * ;", want: "1:9: syntax error: expecting name, literal or '(', found '*'", syntax: true},
+		{name: "literal", src: "r : 'a\n;", want: "1:5: syntax error: literal not terminated", syntax: true},
+		{name: "empty literal", src: "r : '' ;", want: "1:5: syntax error: empty literal", syntax: true},
+		{name: "escape", src: `r : '\q' ;`, want: "1:7: syntax error: invalid escape 'q'", syntax: true},
+		{name: "comment", src: "r : a ; /* r", want: "1:9: syntax error: comment not terminated", syntax: true},
+		{name: "character", src: "r : a # ;", want: "1:7: syntax error: invalid character '#'", syntax: true},
+		{name: "redefined", src: "r : a ;\na : 'a' ;\nr : a a ;", want: "3:1: r redefined, first defined at 1:1"},
+		{name: "undefined", src: "r : a b ;\na : 'a' ;", want: "1:1: undefined rule b in r"},
+		{name: "range in parser rule", src: "r : 'a'..'z' ;", want: "1:1: 'a'..'z' in parser rule r, only lexer rules match characters"},
+		{name: "not in parser rule", src: "s : a ;\nr : ~a ;\na : 'a' ;", want: "2:1: ~a in parser rule r, only lexer rules match characters"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.src)
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if got := err.Error(); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+			if got := errors.Is(err, SyntaxError); got != tc.syntax {
+				t.Errorf("errors.Is(err, SyntaxError) = %v, want %v", got, tc.syntax)
+			}
+		})
+	}
+}
+
+func TestANTLR(t *testing.T) {
+	// no name, and an empty alternative
+	g, err := Parse("r : a | ;\na : 'a' ;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := WriteANTLR(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	want := "grammar G;\n\nr\n    : a\n    |\n    ;\n\na\n    : 'a'\n    ;\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestYacc(t *testing.T) {
+	cases := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "tokens",
+			src:  `s : 'if' IF '->' '\'' '\\' '"' 'x' '::=' '::=' ; IF : 'IF' ;`,
+			want: `%token IF
+%token IF_1 "if"
+%token ARROW "->"
+%token TOKEN "::="
+%start s
+
+%%
+
+s
+    : IF_1 IF ARROW '\'' '\\' '"' 'x' TOKEN TOKEN
+    ;
+
+%%
+`,
+		},
+		{
+			name: "repetitions",
+			src:  "s : a? a* a+ (a | b)? (a b)* ; a : 'a' ; b : 'b' ;",
+			want: `%start s
+
+%%
+
+s
+    : s_1 s_2 s_3 s_4 s_6
+    ;
+
+s_1
+    : /* empty */
+    | a
+    ;
+
+s_2
+    : /* empty */
+    | s_2 a
+    ;
+
+s_3
+    : a
+    | s_3 a
+    ;
+
+s_4
+    : /* empty */
+    | s_5
+    ;
+
+s_5
+    : a
+    | b
+    ;
+
+s_6
+    : /* empty */
+    | s_6 a b
+    ;
+
+a
+    : 'a'
+    ;
+
+b
+    : 'b'
+    ;
+
+%%
+`,
+		},
+		{
+			name: "name taken",
+			src:  "s : a? ; s_1 : 'x' ; a : 'a' ;",
+			want: `%start s
+
+%%
+
+s
+    : s_2
+    ;
+
+s_2
+    : /* empty */
+    | a
+    ;
+
+s_1
+    : 'x'
+    ;
+
+a
+    : 'a'
+    ;
+
+%%
+`,
+		},
+		{
+			name:    "lexer rules only",
+			src:     "A : 'a' ;",
+			wantErr: "yacc: the grammar has no parser rules",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := Parse(tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			err = WriteYacc(&b, g)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
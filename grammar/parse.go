@@ -0,0 +1,382 @@
+package grammar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Reading grammars
+//
+// The notation is read the way chapter 2 reads lists: a lexer turns the text
+// into tokens and an LL(1) recursive-descent parser matches them, with a
+// method per rule of the notation's own grammar:
+//
+//	grammar : ('grammar' ID ';')? rule* EOF ;
+//	rule    : ID ':' alts ';' ;
+//	alts    : seq ('|' seq)* ;
+//	seq     : item* ;
+//	item    : '~'? atom ('?' | '*' | '+')? ;
+//	atom    : ID | LITERAL ('..' LITERAL)? | '.' | '(' alts ')' ;
+//
+// Comments go from // to the end of the line, or between /* and */.
+
+var SyntaxError = errors.New("syntax error")
+
+// Error is an error found at a position of the text of a grammar.
+type Error struct {
+	Pos Pos
+	Err error
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("%v: %v", e.Pos, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+type tokenType int
+
+const (
+	tEOF tokenType = iota
+	tID
+	tLiteral
+	tColon
+	tSemi
+	tOr
+	tLParen
+	tRParen
+	tOpt
+	tStar
+	tPlus
+	tNot
+	tDot
+	tRange
+)
+
+var tokenNames = [...]string{
+	tEOF: "EOF", tID: "name", tLiteral: "literal", tColon: "':'", tSemi: "';'",
+	tOr: "'|'", tLParen: "'('", tRParen: "')'", tOpt: "'?'", tStar: "'*'",
+	tPlus: "'+'", tNot: "'~'", tDot: "'.'", tRange: "'..'",
+}
+
+func (t tokenType) String() string { return tokenNames[t] }
+
+type token struct {
+	typ  tokenType
+	text string // the name, or the literal without quotes
+	pos  Pos
+}
+
+// lexer splits the text of a grammar into tokens.
+type lexer struct {
+	src       []rune
+	i         int
+	line, col int
+}
+
+func (l *lexer) peek(n int) rune {
+	if l.i+n < len(l.src) {
+		return l.src[l.i+n]
+	}
+	return -1
+}
+
+func (l *lexer) consume() {
+	if l.src[l.i] == '\n' {
+		l.line++
+		l.col = 0
+	}
+	l.i++
+	l.col++
+}
+
+func (l *lexer) errorf(pos Pos, format string, args ...any) error {
+	return &Error{Pos: pos, Err: fmt.Errorf("%w: "+format, append([]any{SyntaxError}, args...)...)}
+}
+
+// skip skips whitespace and comments.
+func (l *lexer) skip() error {
+	for {
+		switch c := l.peek(0); {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.consume()
+		case c == '/' && l.peek(1) == '/':
+			for l.peek(0) != '\n' && l.peek(0) != -1 {
+				l.consume()
+			}
+		case c == '/' && l.peek(1) == '*':
+			pos := Pos{l.line, l.col}
+			l.consume()
+			l.consume()
+			for !(l.peek(0) == '*' && l.peek(1) == '/') {
+				if l.peek(0) == -1 {
+					return l.errorf(pos, "comment not terminated")
+				}
+				l.consume()
+			}
+			l.consume()
+			l.consume()
+		default:
+			return nil
+		}
+	}
+}
+
+var punctuation = map[rune]tokenType{
+	':': tColon, ';': tSemi, '|': tOr, '(': tLParen, ')': tRParen,
+	'?': tOpt, '*': tStar, '+': tPlus, '~': tNot,
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skip(); err != nil {
+		return token{}, err
+	}
+	pos := Pos{l.line, l.col}
+	c := l.peek(0)
+	switch {
+	case c == -1:
+		return token{typ: tEOF, pos: pos}, nil
+	case c == '.':
+		l.consume()
+		if l.peek(0) == '.' {
+			l.consume()
+			return token{typ: tRange, text: "..", pos: pos}, nil
+		}
+		return token{typ: tDot, text: ".", pos: pos}, nil
+	case c == '\'':
+		return l.literal(pos)
+	case c == '_' || unicode.IsLetter(c):
+		var b strings.Builder
+		for c := l.peek(0); c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c); c = l.peek(0) {
+			b.WriteRune(c)
+			l.consume()
+		}
+		return token{typ: tID, text: b.String(), pos: pos}, nil
+	}
+	if typ, ok := punctuation[c]; ok {
+		l.consume()
+		return token{typ: typ, text: string(c), pos: pos}, nil
+	}
+	return token{}, l.errorf(pos, "invalid character %q", c)
+}
+
+var escapes = map[rune]rune{'n': '\n', 'r': '\r', 't': '\t', '\'': '\'', '\\': '\\'}
+
+// literal reads a quoted literal, its escapes those of ANTLR but \u.
+func (l *lexer) literal(pos Pos) (token, error) {
+	l.consume()
+	var b strings.Builder
+	for {
+		c := l.peek(0)
+		switch c {
+		case -1, '\n':
+			return token{}, l.errorf(pos, "literal not terminated")
+		case '\'':
+			l.consume()
+			if b.Len() == 0 {
+				return token{}, l.errorf(pos, "empty literal")
+			}
+			return token{typ: tLiteral, text: b.String(), pos: pos}, nil
+		case '\\':
+			l.consume()
+			e, ok := escapes[l.peek(0)]
+			if !ok {
+				return token{}, l.errorf(Pos{l.line, l.col}, "invalid escape %q", l.peek(0))
+			}
+			b.WriteRune(e)
+			l.consume()
+		default:
+			b.WriteRune(c)
+			l.consume()
+		}
+	}
+}
+
+// parser is an LL(1) parser of grammars. It panics on the first error,
+// Parse recovers and returns it.
+type parser struct {
+	lex       *lexer
+	lookahead token
+}
+
+// Parse reads a grammar. The rules referred to must be defined, but for the
+// tokens: a grammar can leave them to the lexer, only using their names.
+func Parse(src string) (g *Grammar, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			g, err = nil, e
+		}
+	}()
+	p := &parser{lex: &lexer{src: []rune(src), line: 1, col: 1}}
+	p.consume()
+	g = p.grammar()
+	if err := check(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (p *parser) consume() {
+	tok, err := p.lex.next()
+	if err != nil {
+		panic(err)
+	}
+	p.lookahead = tok
+}
+
+func (p *parser) match(typ tokenType) token {
+	tok := p.lookahead
+	if tok.typ != typ {
+		panic(p.lex.errorf(tok.pos, "expecting %v, found %v", typ, describe(tok)))
+	}
+	p.consume()
+	return tok
+}
+
+func describe(tok token) string {
+	switch tok.typ {
+	case tID:
+		return tok.text
+	case tLiteral:
+		return quote(tok.text)
+	}
+	return tok.typ.String()
+}
+
+func (p *parser) grammar() *Grammar {
+	g := &Grammar{}
+	if p.lookahead.typ == tID && p.lookahead.text == "grammar" {
+		p.consume()
+		g.Name = p.match(tID).text
+		p.match(tSemi)
+	}
+	for p.lookahead.typ != tEOF {
+		g.Rules = append(g.Rules, p.rule())
+	}
+	return g
+}
+
+func (p *parser) rule() *Rule {
+	name := p.match(tID)
+	p.match(tColon)
+	body := p.alts()
+	p.match(tSemi)
+	return &Rule{Pos: name.pos, Name: name.text, Body: body}
+}
+
+func (p *parser) alts() *Alt {
+	a := &Alt{Alts: []*Seq{p.seq()}}
+	for p.lookahead.typ == tOr {
+		p.consume()
+		a.Alts = append(a.Alts, p.seq())
+	}
+	return a
+}
+
+// seq matches items up to what ends a sequence: '|', ')' or ';'.
+func (p *parser) seq() *Seq {
+	s := &Seq{}
+	for {
+		switch p.lookahead.typ {
+		case tOr, tRParen, tSemi, tEOF:
+			return s
+		}
+		s.Items = append(s.Items, p.item())
+	}
+}
+
+func (p *parser) item() Expr {
+	not := p.lookahead.typ == tNot
+	if not {
+		p.consume()
+	}
+	x := p.atom()
+	if not {
+		x = &Not{X: x}
+	}
+	switch p.lookahead.typ {
+	case tOpt, tStar, tPlus:
+		x = &Repeat{X: x, Op: p.lookahead.text[0]}
+		p.consume()
+	}
+	return x
+}
+
+func (p *parser) atom() Expr {
+	switch p.lookahead.typ {
+	case tID:
+		return &Ref{Name: p.match(tID).text}
+	case tLiteral:
+		from := p.match(tLiteral).text
+		if p.lookahead.typ != tRange {
+			return &Lit{Text: from}
+		}
+		p.consume()
+		return &Range{From: from, To: p.match(tLiteral).text}
+	case tDot:
+		p.consume()
+		return &Any{}
+	case tLParen:
+		p.consume()
+		x := p.alts()
+		p.match(tRParen)
+		return x
+	}
+	panic(p.lex.errorf(p.lookahead.pos, "expecting name, literal or '(', found %v", describe(p.lookahead)))
+}
+
+// check reports the first rule defined twice, reference to an undefined
+// parser rule or character set in a parser rule.
+func check(g *Grammar) error {
+	defined := map[string]*Rule{}
+	for _, r := range g.Rules {
+		if prev, ok := defined[r.Name]; ok {
+			return &Error{r.Pos, fmt.Errorf("%s redefined, first defined at %v", r.Name, prev.Pos)}
+		}
+		defined[r.Name] = r
+	}
+	for _, r := range g.Rules {
+		var err error
+		walk(r.Body, func(x Expr) {
+			if err != nil {
+				return
+			}
+			switch x := x.(type) {
+			case *Ref:
+				if _, ok := defined[x.Name]; !ok && !isToken(x.Name) {
+					err = &Error{r.Pos, fmt.Errorf("undefined rule %s in %s", x.Name, r.Name)}
+				}
+			case *Range, *Not, *Any:
+				if !r.Lexer() {
+					err = &Error{r.Pos, fmt.Errorf("%v in parser rule %s, only lexer rules match characters", x, r.Name)}
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk calls f for x and each expression in it, in order.
+func walk(x Expr, f func(Expr)) {
+	f(x)
+	switch x := x.(type) {
+	case *Alt:
+		for _, a := range x.Alts {
+			walk(a, f)
+		}
+	case *Seq:
+		for _, item := range x.Items {
+			walk(item, f)
+		}
+	case *Not:
+		walk(x.X, f)
+	case *Repeat:
+		walk(x.X, f)
+	}
+}
@@ -0,0 +1,231 @@
+package grammar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// yacc
+//
+// yacc reads BNF: each rule is a choice between sequences of symbols, and
+// that's it. WriteYacc makes up a rule for each repetition and each group of
+// alternatives of the EBNF, the same one for all of them that are alike, and
+// named after the rule it's found in. Repetitions become left-recursive
+// rules, which is what yacc's LR parsers prefer:
+//
+//	params : param (',' param)* ;     params   : param params_1 ;
+//	                                   params_1 : /* empty */
+//	                                            | params_1 ',' param
+//	                                            ;
+//
+// The tokens are declared with %token, the lexer rules being the job of the
+// scanner, lex or a hand-written one. Characters can be used as they are,
+// 'x', but longer literals need a token: keywords get their name in
+// uppercase, operators a name of their own, 'while' is WHILE and '==' is EQ,
+// declared with the literal as an alias the way bison allows. EOF is left
+// out, a yacc parser always ends with the input.
+
+// WriteYacc writes the parser rules of g for yacc, or rather bison, which
+// takes the aliases of the tokens. The first parser rule is the start rule.
+func WriteYacc(w io.Writer, g *Grammar) error {
+	y := &yacc{g: g, literals: map[string]string{}, helpers: map[string]string{}, count: map[string]int{}, used: map[string]bool{}}
+	var start string
+	for _, r := range g.Rules {
+		if r.Lexer() {
+			continue
+		}
+		if start == "" {
+			start = r.Name
+		}
+		y.rules = append(y.rules, bnf{name: r.Name})
+		y.rules[len(y.rules)-1].alts = y.alts(r.Name, r.Body)
+	}
+	if start == "" {
+		return errors.New("yacc: the grammar has no parser rules")
+	}
+
+	bw := bufio.NewWriter(w)
+	if g.Name != "" {
+		fmt.Fprintf(bw, "/* %s */\n\n", g.Name)
+	}
+	if len(y.tokens) > 0 {
+		fmt.Fprintf(bw, "%%token %s\n", strings.Join(y.tokens, " "))
+	}
+	for _, lit := range y.literalOrder {
+		fmt.Fprintf(bw, "%%token %s %s\n", y.literals[lit], quoteC(lit, '"'))
+	}
+	fmt.Fprintf(bw, "%%start %s\n\n%%%%\n", start)
+	for _, r := range y.rules {
+		fmt.Fprintf(bw, "\n%s\n", r.name)
+		for i, alt := range r.alts {
+			sep := "|"
+			if i == 0 {
+				sep = ":"
+			}
+			if len(alt) == 0 {
+				alt = []string{"/* empty */"}
+			}
+			fmt.Fprintf(bw, "    %s %s\n", sep, strings.Join(alt, " "))
+		}
+		fmt.Fprintln(bw, "    ;")
+	}
+	fmt.Fprintln(bw, "\n%%")
+	return bw.Flush()
+}
+
+// bnf is a rule of yacc, alternatives of symbols.
+type bnf struct {
+	name string
+	alts [][]string
+}
+
+type yacc struct {
+	g            *Grammar
+	rules        []bnf
+	tokens       []string          // named, in the order they're used
+	literals     map[string]string // token names of the literals
+	literalOrder []string
+	helpers      map[string]string // rules made up, by the EBNF they stand for
+	count        map[string]int    // rules made up for each rule
+	used         map[string]bool   // names taken by tokens and rules made up
+}
+
+func (y *yacc) alts(rule string, x *Alt) [][]string {
+	alts := make([][]string, len(x.Alts))
+	for i, a := range x.Alts {
+		alts[i] = y.seq(rule, a)
+	}
+	return alts
+}
+
+func (y *yacc) seq(rule string, x *Seq) []string {
+	var syms []string
+	for _, item := range x.Items {
+		syms = append(syms, y.symbols(rule, item)...)
+	}
+	return syms
+}
+
+// symbols returns the symbols x stands for in rule.
+func (y *yacc) symbols(rule string, x Expr) []string {
+	switch x := x.(type) {
+	case *Ref:
+		if x.Name == "EOF" {
+			return nil
+		}
+		if isToken(x.Name) && !y.used[x.Name] {
+			y.used[x.Name] = true
+			y.tokens = append(y.tokens, x.Name)
+		}
+		return []string{x.Name}
+	case *Lit:
+		return []string{y.literal(x.Text)}
+	case *Alt:
+		if len(x.Alts) == 1 {
+			return y.seq(rule, x.Alts[0])
+		}
+		return []string{y.helper(rule, x, func(name string) [][]string { return y.alts(rule, x) })}
+	case *Repeat:
+		return []string{y.helper(rule, x, func(name string) [][]string {
+			syms := y.symbols(rule, x.X)
+			switch x.Op {
+			case '?':
+				return [][]string{nil, syms}
+			case '*':
+				return [][]string{nil, append([]string{name}, syms...)}
+			}
+			return [][]string{syms, append([]string{name}, syms...)}
+		})}
+	}
+	// Parse doesn't let the others be in parser rules
+	panic(fmt.Sprintf("yacc: %v in a parser rule", x))
+}
+
+// helper returns the rule made up for x in rule, making it up with the
+// alternatives returned by alts if it's the first one like it.
+func (y *yacc) helper(rule string, x Expr, alts func(name string) [][]string) string {
+	key := x.String()
+	if name, ok := y.helpers[key]; ok {
+		return name
+	}
+	var name string
+	for name == "" || y.used[name] || y.g.Rule(name) != nil {
+		y.count[rule]++
+		name = fmt.Sprintf("%s_%d", rule, y.count[rule])
+	}
+	y.used[name] = true
+	y.helpers[key] = name
+	i := len(y.rules)
+	y.rules = append(y.rules, bnf{name: name})
+	y.rules[i].alts = alts(name)
+	return name
+}
+
+// operators are the token names of the literals that aren't words.
+var operators = map[string]string{
+	"==": "EQ", "!=": "NE", "<=": "LE", ">=": "GE", "&&": "AND", "||": "OR",
+	"->": "ARROW", "..": "DOTDOT", "::": "SCOPE", "++": "INC", "--": "DEC",
+	"<<": "SHL", ">>": "SHR", "+=": "ADD_ASSIGN", "-=": "SUB_ASSIGN",
+	"*=": "MUL_ASSIGN", "/=": "DIV_ASSIGN",
+}
+
+// literal returns the symbol of a literal: the character itself, or the
+// token named after it.
+func (y *yacc) literal(text string) string {
+	if len([]rune(text)) == 1 {
+		return quoteC(text, '\'')
+	}
+	if name, ok := y.literals[text]; ok {
+		return name
+	}
+	name, ok := operators[text]
+	if !ok && isWord(text) {
+		name = strings.ToUpper(text)
+	}
+	if name == "" {
+		name = "TOKEN"
+	}
+	for base, n := name, 1; y.used[name] || y.g.Rule(name) != nil; n++ {
+		name = fmt.Sprintf("%s_%d", base, n)
+	}
+	y.used[name] = true
+	y.literals[text] = name
+	y.literalOrder = append(y.literalOrder, text)
+	return name
+}
+
+func isWord(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteC quotes s the way C does, with the quote q.
+func quoteC(s string, q rune) string {
+	var b strings.Builder
+	b.WriteRune(q)
+	for _, r := range s {
+		switch r {
+		case q, '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune(q)
+	return b.String()
+}
@@ -0,0 +1,233 @@
+// Package analysis runs the steps of lip on a Cymbol program, from its tokens
+// to its semantic errors, and gives the results as plain data that encodes to
+// JSON: for the playground of lip serve, and for the JavaScript of the wasm
+// build. It also encodes to protocol buffers, described by analysis.proto, for
+// services.
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"example.com/types"
+)
+
+// Analysis is what the steps found in a program, as JSON:
+//
+//	{
+//	  "tokens": [{"pos": "1:1", "type": "ID", "text": "int"}, ...],
+//	  "ast": {"kind": "File", "label": "File", "pos": "1:1", "children": [...]},
+//	  "dot": "digraph ast {...}",
+//	  "diagnostics": [{"pos": "1:9", "message": "undefined: y"}]
+//	}
+//
+// The tokens go up to a lexer error, and the tree is null if there's a syntax
+// error, which is then the diagnostic. DOT is the tree as a Graphviz graph.
+type Analysis struct {
+	Tokens      []Token      `json:"tokens"`
+	AST         *Tree        `json:"ast"`
+	DOT         string       `json:"dot"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type Token struct {
+	Pos  string `json:"pos"`
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Tree is a node of the tree: its type, its description and its position.
+type Tree struct {
+	Kind     string  `json:"kind"`
+	Label    string  `json:"label"`
+	Pos      string  `json:"pos"`
+	Children []*Tree `json:"children,omitempty"`
+}
+
+type Diagnostic struct {
+	Pos     string `json:"pos"`
+	Message string `json:"message"`
+}
+
+// Analyze runs all the steps on src, up to checking types.
+func Analyze(src string) *Analysis {
+	a := &Analysis{Diagnostics: []Diagnostic{}}
+	a.Tokens, _ = Tokenize(src)
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		a.Diagnostics = append(a.Diagnostics, diagnostic(err))
+		return a
+	}
+	a.AST = SyntaxTree(f)
+	a.DOT = DOT(a.AST)
+	for _, d := range Check(f, "types").List() {
+		a.Diagnostics = append(a.Diagnostics, Diagnostic{d.Span.From.String(), d.Message})
+	}
+	return a
+}
+
+// Tokenize returns the tokens of src, without the EOF, up to the lexer error
+// if there's one.
+func Tokenize(src string) ([]Token, error) {
+	toks := []Token{}
+	lex := cymbol.NewLexer(src)
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return toks, err
+		}
+		if tok.Type == cymbol.EOF {
+			return toks, nil
+		}
+		toks = append(toks, Token{tok.Pos.String(), tok.Type.String(), tok.Text})
+	}
+}
+
+// Parse returns the tree of src, or its syntax error.
+func Parse(src string) (*Tree, error) {
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return nil, err
+	}
+	return SyntaxTree(f), nil
+}
+
+func diagnostic(err error) Diagnostic {
+	var e *cymbol.Error
+	if errors.As(err, &e) {
+		return Diagnostic{e.Pos.String(), e.Err.Error()}
+	}
+	return Diagnostic{Message: err.Error()}
+}
+
+// SyntaxTree returns the tree of f as lip ast prints it, each node described
+// by Describe.
+func SyntaxTree(f *cymbol.File) *Tree {
+	var stack []*Tree
+	var root *Tree
+	cymbol.Apply(f, func(c *cymbol.Cursor) bool {
+		n := c.Node()
+		t := &Tree{Kind: reflect.TypeOf(n).Elem().Name(), Label: Describe(n), Pos: n.Pos().String()}
+		if len(stack) == 0 {
+			root = t
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, t)
+		}
+		stack = append(stack, t)
+		return true
+	}, func(c *cymbol.Cursor) bool {
+		stack = stack[:len(stack)-1]
+		return true
+	})
+	return root
+}
+
+// DOT returns the tree as a DOT graph, nodes numbered in preorder.
+func DOT(root *Tree) string {
+	var s strings.Builder
+	s.WriteString("digraph ast {\n\tnode [shape=box, fontname=\"monospace\"];\n")
+	n := 0
+	var add func(t *Tree) int
+	add = func(t *Tree) int {
+		id := n
+		n++
+		fmt.Fprintf(&s, "\tn%d [label=\"%s\"];\n", id, dotEscape.Replace(t.Label))
+		for _, c := range t.Children {
+			fmt.Fprintf(&s, "\tn%d -> n%d;\n", id, add(c))
+		}
+		return id
+	}
+	add(root)
+	s.WriteString("}\n")
+	return s.String()
+}
+
+// dotEscape escapes a label for a DOT string.
+var dotEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Describe returns the type of n, followed by what tells it apart from the
+// other nodes of its type.
+func Describe(n cymbol.Node) string {
+	kind := reflect.TypeOf(n).Elem().Name()
+	switch n := n.(type) {
+	case *cymbol.VarDecl:
+		return fmt.Sprintf("%s %v %v", kind, n.Type, n.Name)
+	case *cymbol.FuncDecl:
+		return fmt.Sprintf("%s %v %v", kind, n.Type, n.Name)
+	case *cymbol.Param:
+		return fmt.Sprintf("%s %v %v", kind, n.Type, n.Name)
+	case *cymbol.StructDecl:
+		return fmt.Sprintf("%s %v", kind, n.Name)
+	case *cymbol.NamespaceDecl:
+		return fmt.Sprintf("%s %v", kind, n.Name)
+	case *cymbol.ImportDecl:
+		return fmt.Sprintf("%s %v", kind, n.Name)
+	case *cymbol.ClassDecl:
+		if n.Super != nil {
+			return fmt.Sprintf("%s %v : %v", kind, n.Name, n.Super)
+		}
+		return fmt.Sprintf("%s %v", kind, n.Name)
+	case *cymbol.BinaryExpr:
+		return kind + " " + n.Op.Text
+	case *cymbol.UnaryExpr:
+		return kind + " " + n.Op.Text
+	case *cymbol.MemberExpr:
+		return fmt.Sprintf("%s .%v", kind, n.Member)
+	case *cymbol.Ident, *cymbol.IntLit, *cymbol.FloatLit, *cymbol.CharLit, *cymbol.StringLit, *cymbol.BoolLit:
+		return kind + " " + n.String()
+	}
+	return kind
+}
+
+// Checks are the semantic checks of Check, each one doing the ones before it.
+var Checks = []string{"symbols", "types", "flow"}
+
+// Check resolves the symbols of f, then checks the types of its expressions
+// and then the flow of values through its functions, up to the check named
+// with. print is defined, as both the interpreter and the stack machine have
+// it.
+func Check(f *cymbol.File, with string) *cymbol.Diagnostics {
+	level := slices.Index(Checks, with)
+	table := newTable()
+	defs, refs := symtab.TwoPass(table, f)
+	if level < 1 {
+		return defs.Diagnostics
+	}
+	c := types.NewComputeTypes(table, refs.Refs)
+	c.Defs = defs.Defs
+	symtab.Walk(c, f)
+	symtab.Walk(types.NewCheckTypes(c, defs.Diagnostics), f)
+	if level < 2 {
+		return defs.Diagnostics
+	}
+	for _, d := range f.Decls {
+		var funcs []cymbol.Decl
+		switch d := d.(type) {
+		case *cymbol.FuncDecl:
+			funcs = []cymbol.Decl{d}
+		case *cymbol.ClassDecl:
+			funcs = d.Members
+		}
+		for _, fn := range funcs {
+			if fn, ok := fn.(*cymbol.FuncDecl); ok {
+				types.CheckFlow(fn, c.Defs, c.Refs, defs.Diagnostics)
+			}
+		}
+	}
+	return defs.Diagnostics
+}
+
+// newTable returns a symbol table with print defined.
+func newTable() *symtab.SymbolTable {
+	table := symtab.NewSymbolTable()
+	print := symtab.NewFunctionSymbol("print", table.Globals.Resolve("void").(symtab.Type), table.Globals)
+	print.Define(symtab.NewVariableSymbol("v", types.Any))
+	table.Globals.Define(print)
+	return table
+}
@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want *Analysis
+	}{
+		{
+			name: "program",
+			src:  "int x = -y;",
+			want: &Analysis{
+				Tokens: []Token{
+					{"1:1", "ID", "int"}, {"1:5", "ID", "x"}, {"1:7", "Assign", "="},
+					{"1:9", "Minus", "-"}, {"1:10", "ID", "y"}, {"1:11", "Semicolon", ";"},
+				},
+				AST: &Tree{Kind: "File", Label: "File", Pos: "1:1", Children: []*Tree{
+					{Kind: "VarDecl", Label: "VarDecl int x", Pos: "1:1", Children: []*Tree{
+						{Kind: "UnaryExpr", Label: "UnaryExpr -", Pos: "1:9", Children: []*Tree{
+							{Kind: "Ident", Label: "Ident y", Pos: "1:10"},
+						}},
+					}},
+				}},
+				DOT: `digraph ast {
+	node [shape=box, fontname="monospace"];
+	n0 [label="File"];
+	n1 [label="VarDecl int x"];
+	n2 [label="UnaryExpr -"];
+	n3 [label="Ident y"];
+	n2 -> n3;
+	n1 -> n2;
+	n0 -> n1;
+}
+`,
+				Diagnostics: []Diagnostic{{"1:10", "undefined: y"}},
+			},
+		},
+		{
+			name: "syntax error",
+			src:  `string s = "a\"" int`,
+			want: &Analysis{
+				Tokens: []Token{
+					{"1:1", "ID", "string"}, {"1:8", "ID", "s"}, {"1:10", "Assign", "="},
+					{"1:12", "String", `"a\""`}, {"1:18", "ID", "int"},
+				},
+				Diagnostics: []Diagnostic{{"1:18", "syntax error: expecting Semicolon, found ID"}},
+			},
+		},
+		{
+			name: "lexer error",
+			src:  "int #",
+			want: &Analysis{
+				Tokens:      []Token{{"1:1", "ID", "int"}},
+				Diagnostics: []Diagnostic{{"1:5", "invalid character: '#'"}},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Analyze(tc.src)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
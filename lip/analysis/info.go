@@ -0,0 +1,194 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/symtab"
+	"example.com/types"
+)
+
+// Tool info
+//
+// Editors and the playground ask about the name under the cursor: what it
+// is, its type and where it's declared. A Program is analyzed once, up to its
+// types, and then answers for any position with ToolInfo:
+//
+//	{
+//	  "name": "x", "kind": "variable", "type": "int", "declaration": "int x",
+//	  "span": {"from": "2:12", "to": "2:13"},
+//	  "definition": {"from": "1:5", "to": "1:6"}
+//	}
+//
+// The type is the one the type computation gave the name where it's used,
+// the one it's declared with where it's declared. The definition is null for
+// the built-in types and functions, and for this and super.
+
+// Program is a program analyzed up to checking its types, the way lip check
+// does it with -with types.
+type Program struct {
+	File        *cymbol.File
+	Index       *symtab.Index
+	Types       *types.ComputeTypes
+	Diagnostics *cymbol.Diagnostics
+}
+
+// Load parses src and analyzes it. The error is the syntax error, the
+// semantic ones are the diagnostics of the program.
+func Load(src string) (*Program, error) {
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return nil, err
+	}
+	table := newTable()
+	defs, refs := symtab.TwoPass(table, f)
+	c := types.NewComputeTypes(table, refs.Refs)
+	c.Defs = defs.Defs
+	symtab.Walk(c, f)
+	symtab.Walk(types.NewCheckTypes(c, defs.Diagnostics), f)
+	return &Program{
+		File:        f,
+		Index:       symtab.NewIndex(f, defs, refs),
+		Types:       c,
+		Diagnostics: defs.Diagnostics,
+	}, nil
+}
+
+// Info is what's known about a name of a program, see ToolInfo.
+type Info struct {
+	Name        string       `json:"name"`
+	Kind        string       `json:"kind"`
+	Type        string       `json:"type"`
+	Declaration string       `json:"declaration"` // as it's declared, `float f(float a)`
+	Span        cymbol.Span  `json:"span"`        // of the name
+	Definition  *cymbol.Span `json:"definition"`  // of the name it's declared with
+}
+
+// ToolInfo returns what's known about the name at pos, nil if there's no
+// name there or it doesn't resolve to a symbol.
+func (p *Program) ToolInfo(pos cymbol.Pos) *Info {
+	sym, span := p.Index.SymbolAt(pos)
+	if sym == nil {
+		return nil
+	}
+	info := &Info{
+		Name:        sym.Name(),
+		Kind:        Kind(sym),
+		Type:        symbolType(sym),
+		Declaration: declaration(sym),
+		Span:        span,
+	}
+	if t, ok := p.typeAt(span); ok {
+		info.Type = typeName(t)
+	}
+	if id := p.Index.Definition(sym); id != nil {
+		s := id.Token.Span()
+		info.Definition = &s
+	}
+	return info
+}
+
+// ToolInfo loads src and returns what's known about the name at pos, see
+// Program.ToolInfo. The error is the syntax error.
+func ToolInfo(src string, pos cymbol.Pos) (*Info, error) {
+	p, err := Load(src)
+	if err != nil {
+		return nil, err
+	}
+	return p.ToolInfo(pos), nil
+}
+
+// typeAt returns the type computed for the expression whose name is at
+// span, if the name is used in one rather than declared.
+func (p *Program) typeAt(span cymbol.Span) (symtab.Type, bool) {
+	for n, t := range p.Types.Types.All() {
+		var tok cymbol.Token
+		switch x := n.(type) {
+		case *cymbol.Ident:
+			tok = x.Token
+		case *cymbol.MemberExpr:
+			tok = x.Member.Token
+		case *cymbol.ThisExpr:
+			tok = x.Token
+		case *cymbol.SuperExpr:
+			tok = x.Token
+		default:
+			continue
+		}
+		if tok.Span() == span && t != nil {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// symbolType is the type sym is declared with, or sym itself if it's a type.
+// A namespace has none.
+func symbolType(sym symtab.Symbol) string {
+	switch sym := sym.(type) {
+	case symtab.Type:
+		return sym.Name()
+	case *symtab.NamespaceSymbol:
+		return ""
+	}
+	return typeName(sym.Type())
+}
+
+// Kind names what sym is: a field, parameter, variable, method, function,
+// struct, class, namespace or other type.
+func Kind(sym symtab.Symbol) string {
+	switch sym := sym.(type) {
+	case *symtab.VariableSymbol:
+		switch sym.Scope().(type) {
+		case *symtab.StructSymbol, *symtab.ClassSymbol:
+			return "field"
+		case *symtab.FunctionSymbol:
+			return "parameter"
+		}
+		return "variable"
+	case *symtab.FunctionSymbol:
+		if _, ok := sym.Scope().(*symtab.ClassSymbol); ok {
+			return "method"
+		}
+		return "function"
+	case *symtab.StructSymbol:
+		return "struct"
+	case *symtab.ClassSymbol:
+		return "class"
+	case *symtab.NamespaceSymbol:
+		return "namespace"
+	}
+	return "type"
+}
+
+// declaration renders a symbol as it's declared.
+func declaration(sym symtab.Symbol) string {
+	switch sym := sym.(type) {
+	case *symtab.FunctionSymbol:
+		var params []string
+		for _, p := range sym.Params() {
+			params = append(params, declaration(p))
+		}
+		return fmt.Sprintf("%s %s(%s)", typeName(sym.Type()), sym.Name(), strings.Join(params, ", "))
+	case *symtab.StructSymbol:
+		return "struct " + sym.Name()
+	case *symtab.ClassSymbol:
+		if sym.Superclass != nil {
+			return "class " + sym.Name() + " : " + sym.Superclass.Name()
+		}
+		return "class " + sym.Name()
+	case *symtab.NamespaceSymbol:
+		return "namespace " + sym.Name()
+	case *symtab.BuiltInTypeSymbol:
+		return sym.Name() + " (built-in type)"
+	}
+	return typeName(sym.Type()) + " " + sym.Name()
+}
+
+func typeName(t symtab.Type) string {
+	if t == nil {
+		return "void"
+	}
+	return t.Name()
+}
@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestToolInfo(t *testing.T) {
+	src := `struct point { int x; };
+class A { float f(int n) { return n; } };
+class B : A { float g() { return this.f(1) + 0.5; } };
+point p;
+void main() { float r = p.x; print(r); }
+namespace geo { int d() { return 1; } } int q = geo.d();
+`
+	span := func(line, from, to int) *cymbol.Span {
+		return &cymbol.Span{From: cymbol.Pos{Line: line, Col: from}, To: cymbol.Pos{Line: line, Col: to}}
+	}
+	cases := []struct {
+		name string
+		pos  cymbol.Pos
+		want *Info
+	}{
+		{
+			name: "struct",
+			pos:  cymbol.Pos{Line: 4, Col: 1},
+			want: &Info{Name: "point", Kind: "struct", Type: "point", Declaration: "struct point", Span: *span(4, 1, 6), Definition: span(1, 8, 13)},
+		},
+		{
+			name: "field declared",
+			pos:  cymbol.Pos{Line: 1, Col: 20},
+			want: &Info{Name: "x", Kind: "field", Type: "int", Declaration: "int x", Span: *span(1, 20, 21), Definition: span(1, 20, 21)},
+		},
+		{
+			name: "field used",
+			pos:  cymbol.Pos{Line: 5, Col: 27},
+			want: &Info{Name: "x", Kind: "field", Type: "int", Declaration: "int x", Span: *span(5, 27, 28), Definition: span(1, 20, 21)},
+		},
+		{
+			name: "parameter",
+			pos:  cymbol.Pos{Line: 2, Col: 35},
+			want: &Info{Name: "n", Kind: "parameter", Type: "int", Declaration: "int n", Span: *span(2, 35, 36), Definition: span(2, 23, 24)},
+		},
+		{
+			name: "method",
+			pos:  cymbol.Pos{Line: 3, Col: 39},
+			want: &Info{Name: "f", Kind: "method", Type: "float", Declaration: "float f(int n)", Span: *span(3, 39, 40), Definition: span(2, 17, 18)},
+		},
+		{
+			name: "class",
+			pos:  cymbol.Pos{Line: 3, Col: 11},
+			want: &Info{Name: "A", Kind: "class", Type: "A", Declaration: "class A", Span: *span(3, 11, 12), Definition: span(2, 7, 8)},
+		},
+		{
+			name: "this",
+			pos:  cymbol.Pos{Line: 3, Col: 34},
+			want: &Info{Name: "this", Kind: "variable", Type: "B", Declaration: "B this", Span: *span(3, 34, 38)},
+		},
+		{
+			name: "built-in function",
+			pos:  cymbol.Pos{Line: 5, Col: 31},
+			want: &Info{Name: "print", Kind: "function", Type: "void", Declaration: "void print(any v)", Span: *span(5, 30, 35)},
+		},
+		{
+			name: "namespace",
+			pos:  cymbol.Pos{Line: 6, Col: 49},
+			want: &Info{Name: "geo", Kind: "namespace", Declaration: "namespace geo", Span: *span(6, 49, 52), Definition: span(6, 11, 14)},
+		},
+		{
+			name: "qualified function",
+			pos:  cymbol.Pos{Line: 6, Col: 53},
+			want: &Info{Name: "d", Kind: "function", Type: "int", Declaration: "int d()", Span: *span(6, 53, 54), Definition: span(6, 21, 22)},
+		},
+		{
+			name: "no name",
+			pos:  cymbol.Pos{Line: 5, Col: 12},
+		},
+	}
+
+	p, err := Load(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds := p.Diagnostics.List(); len(ds) > 0 {
+		t.Fatal(ds)
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := p.ToolInfo(tc.pos)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := ToolInfo("int x", cymbol.Pos{Line: 1, Col: 5}); err == nil {
+		t.Error("syntax error: got no error")
+	}
+}
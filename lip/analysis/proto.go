@@ -0,0 +1,326 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protocol buffers
+//
+// JSON is handy for the browser, but a service passing parse results to
+// another one pays for it: the field names of each token and node over and
+// over, and numbers as text. analysis.proto describes the same results as
+// protocol buffers, and the MarshalProto and UnmarshalProto methods encode
+// and decode them. Any language's protoc-generated code reads and writes
+// them as well.
+//
+// There's no generated code here, the wire format of the five messages is
+// small enough to write by hand: each field is a tag, its number and wire
+// type as a varint, followed by a varint for numbers or by a length and the
+// bytes for strings and messages. Fields with the zero value are left out,
+// as proto3 does, and unknown fields are skipped when decoding, so that the
+// schema can grow.
+
+// wire types
+const (
+	wireVarint = 0
+	wire64     = 1
+	wireBytes  = 2
+	wire32     = 5
+)
+
+var errTruncated = errors.New("proto: truncated message")
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendUint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendVarint(appendTag(b, field, wireVarint), v)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendPos appends a position, "line:col", as a Pos message. Anything else,
+// such as the empty position of a diagnostic without one, is left out.
+func appendPos(b []byte, field int, pos string) []byte {
+	var line, col uint32
+	if _, err := fmt.Sscanf(pos, "%d:%d", &line, &col); err != nil {
+		return b
+	}
+	return appendBytes(b, field, appendUint(appendUint(nil, 1, uint64(line)), 2, uint64(col)))
+}
+
+// MarshalProto encodes a as an Analysis message.
+func (a *Analysis) MarshalProto() []byte {
+	var b []byte
+	for _, tok := range a.Tokens {
+		b = appendBytes(b, 1, tok.MarshalProto())
+	}
+	if a.AST != nil {
+		b = appendBytes(b, 2, a.AST.MarshalProto())
+	}
+	b = appendString(b, 3, a.DOT)
+	for _, d := range a.Diagnostics {
+		b = appendBytes(b, 4, appendString(appendPos(nil, 1, d.Pos), 2, d.Message))
+	}
+	return b
+}
+
+// MarshalProto encodes tok as a Token message.
+func (tok Token) MarshalProto() []byte {
+	b := appendPos(nil, 1, tok.Pos)
+	b = appendString(b, 2, tok.Type)
+	return appendString(b, 3, tok.Text)
+}
+
+// MarshalProto encodes t as a Tree message, its children with it.
+func (t *Tree) MarshalProto() []byte {
+	b := appendString(nil, 1, t.Kind)
+	b = appendString(b, 2, t.Label)
+	b = appendPos(b, 3, t.Pos)
+	for _, c := range t.Children {
+		b = appendBytes(b, 4, c.MarshalProto())
+	}
+	return b
+}
+
+// decoder reads the fields of a message.
+type decoder struct {
+	b []byte
+}
+
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for i := 0; i < len(d.b) && i < 10; i++ {
+		v |= uint64(d.b[i]&0x7f) << (7 * i)
+		if d.b[i] < 0x80 {
+			d.b = d.b[i+1:]
+			return v, nil
+		}
+	}
+	return 0, errTruncated
+}
+
+// fields calls f with the number and wire type of each field, f reading its
+// value, or skipping it if it doesn't know the field.
+func (d *decoder) fields(f func(field, wire int) error) error {
+	for len(d.b) > 0 {
+		tag, err := d.varint()
+		if err != nil {
+			return err
+		}
+		if tag>>3 == 0 || tag>>3 > math.MaxInt32 {
+			return fmt.Errorf("proto: invalid field number %d", tag>>3)
+		}
+		if err := f(int(tag>>3), int(tag&7)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) bytes(wire int) ([]byte, error) {
+	if wire != wireBytes {
+		return nil, fmt.Errorf("proto: wire type %d, expecting %d", wire, wireBytes)
+	}
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, errTruncated
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *decoder) string(wire int) (string, error) {
+	b, err := d.bytes(wire)
+	return string(b), err
+}
+
+func (d *decoder) uint(wire int) (uint64, error) {
+	if wire != wireVarint {
+		return 0, fmt.Errorf("proto: wire type %d, expecting %d", wire, wireVarint)
+	}
+	return d.varint()
+}
+
+// skip skips the value of a field of an unknown number.
+func (d *decoder) skip(wire int) error {
+	var n int
+	switch wire {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes(wire)
+		return err
+	case wire64:
+		n = 8
+	case wire32:
+		n = 4
+	default:
+		return fmt.Errorf("proto: unsupported wire type %d", wire)
+	}
+	if len(d.b) < n {
+		return errTruncated
+	}
+	d.b = d.b[n:]
+	return nil
+}
+
+// pos decodes a Pos message as "line:col".
+func (d *decoder) pos(wire int) (string, error) {
+	b, err := d.bytes(wire)
+	if err != nil {
+		return "", err
+	}
+	var line, col uint64
+	m := &decoder{b}
+	err = m.fields(func(field, wire int) error {
+		var err error
+		switch field {
+		case 1:
+			line, err = m.uint(wire)
+		case 2:
+			col, err = m.uint(wire)
+		default:
+			err = m.skip(wire)
+		}
+		return err
+	})
+	return fmt.Sprintf("%d:%d", line, col), err
+}
+
+// UnmarshalProto decodes an Analysis message into a. The tokens and
+// diagnostics are empty rather than nil if there are none, as they are for
+// Analyze.
+func (a *Analysis) UnmarshalProto(b []byte) error {
+	*a = Analysis{Tokens: []Token{}, Diagnostics: []Diagnostic{}}
+	d := &decoder{b}
+	return d.fields(func(field, wire int) error {
+		switch field {
+		case 1:
+			m, err := d.bytes(wire)
+			if err != nil {
+				return err
+			}
+			var tok Token
+			if err := tok.UnmarshalProto(m); err != nil {
+				return err
+			}
+			a.Tokens = append(a.Tokens, tok)
+		case 2:
+			m, err := d.bytes(wire)
+			if err != nil {
+				return err
+			}
+			a.AST = &Tree{}
+			return a.AST.UnmarshalProto(m)
+		case 3:
+			var err error
+			a.DOT, err = d.string(wire)
+			return err
+		case 4:
+			m, err := d.bytes(wire)
+			if err != nil {
+				return err
+			}
+			var diag Diagnostic
+			md := &decoder{m}
+			err = md.fields(func(field, wire int) error {
+				var err error
+				switch field {
+				case 1:
+					diag.Pos, err = md.pos(wire)
+				case 2:
+					diag.Message, err = md.string(wire)
+				default:
+					err = md.skip(wire)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			a.Diagnostics = append(a.Diagnostics, diag)
+		default:
+			return d.skip(wire)
+		}
+		return nil
+	})
+}
+
+// UnmarshalProto decodes a Token message into tok.
+func (tok *Token) UnmarshalProto(b []byte) error {
+	*tok = Token{}
+	d := &decoder{b}
+	return d.fields(func(field, wire int) error {
+		var err error
+		switch field {
+		case 1:
+			tok.Pos, err = d.pos(wire)
+		case 2:
+			tok.Type, err = d.string(wire)
+		case 3:
+			tok.Text, err = d.string(wire)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+// UnmarshalProto decodes a Tree message into t, its children with it.
+func (t *Tree) UnmarshalProto(b []byte) error {
+	*t = Tree{}
+	d := &decoder{b}
+	return d.fields(func(field, wire int) error {
+		var err error
+		switch field {
+		case 1:
+			t.Kind, err = d.string(wire)
+		case 2:
+			t.Label, err = d.string(wire)
+		case 3:
+			t.Pos, err = d.pos(wire)
+		case 4:
+			var m []byte
+			if m, err = d.bytes(wire); err != nil {
+				return err
+			}
+			c := &Tree{}
+			t.Children = append(t.Children, c)
+			err = c.UnmarshalProto(m)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
@@ -0,0 +1,131 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		"int x = -y;",
+		`string s = "a\"" int`,
+		"int #",
+		"",
+		"void f(int a) { if (a > 1000000) { print(a); } }",
+	} {
+		want := Analyze(src)
+		var got Analysis
+		if err := got.UnmarshalProto(want.MarshalProto()); err != nil {
+			t.Errorf("%q: %v", src, err)
+			continue
+		}
+		if diff := cmp.Diff(want, &got); diff != "" {
+			t.Errorf("%q: analysis mismatch (-want +got):\n%s", src, diff)
+		}
+	}
+}
+
+func TestMarshalProto(t *testing.T) {
+	cases := []struct {
+		name string
+		msg  interface{ MarshalProto() []byte }
+		want []byte
+	}{
+		{
+			name: "token",
+			msg:  Token{"1:300", "ID", "x"},
+			// pos {line 1, col 300}, type "ID", text "x"
+			want: []byte{0x0a, 5, 0x08, 1, 0x10, 0xac, 0x02, 0x12, 2, 'I', 'D', 0x1a, 1, 'x'},
+		},
+		{
+			name: "tree",
+			msg:  &Tree{Kind: "File", Children: []*Tree{{Kind: "A"}, {}}},
+			// kind "File", children {kind "A"} and {}
+			want: []byte{0x0a, 4, 'F', 'i', 'l', 'e', 0x22, 3, 0x0a, 1, 'A', 0x22, 0},
+		},
+		{
+			name: "diagnostic without position",
+			msg:  &Analysis{Diagnostics: []Diagnostic{{"", "e"}}},
+			want: []byte{0x22, 3, 0x12, 1, 'e'},
+		},
+		{
+			name: "empty",
+			msg:  &Analysis{Tokens: []Token{}},
+			want: nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.msg.MarshalProto()); diff != "" {
+				t.Errorf("encoding mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshalProto(t *testing.T) {
+	cases := []struct {
+		name    string
+		b       []byte
+		want    *Analysis
+		wantErr string
+	}{
+		{
+			name: "unknown fields",
+			// field 9 varint, field 10 fixed64, field 11 bytes, dot "d",
+			// field 12 fixed32
+			b: []byte{0x48, 0x96, 0x01, 0x51, 1, 2, 3, 4, 5, 6, 7, 8, 0x5a, 1, 'x',
+				0x1a, 1, 'd', 0x65, 1, 2, 3, 4},
+			want: &Analysis{Tokens: []Token{}, DOT: "d", Diagnostics: []Diagnostic{}},
+		},
+		{
+			name:    "truncated string",
+			b:       []byte{0x1a, 5, 'd'},
+			wantErr: "proto: truncated message",
+		},
+		{
+			name:    "truncated varint",
+			b:       []byte{0x48, 0x96},
+			wantErr: "proto: truncated message",
+		},
+		{
+			name:    "truncated token",
+			b:       []byte{0x0a, 2, 0x12, 5},
+			wantErr: "proto: truncated message",
+		},
+		{
+			name:    "wrong wire type",
+			b:       []byte{0x18, 1},
+			wantErr: "proto: wire type 0, expecting 2",
+		},
+		{
+			name:    "field 0",
+			b:       []byte{0x02, 0},
+			wantErr: "proto: invalid field number 0",
+		},
+		{
+			name:    "group",
+			b:       []byte{0x4b},
+			wantErr: "proto: unsupported wire type 3",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got Analysis
+			err := got.UnmarshalProto(tc.b)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, &got); diff != "" {
+				t.Errorf("analysis mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
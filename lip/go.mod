@@ -0,0 +1,20 @@
+module example.com/lip
+
+go 1.23.4
+
+require (
+	example.com/bytecode v0.0.0
+	example.com/cymbol v0.0.0
+	example.com/interp v0.0.0
+	example.com/symtab v0.0.0
+	example.com/types v0.0.0
+	github.com/google/go-cmp v0.6.0
+)
+
+replace (
+	example.com/bytecode => ../chapter10
+	example.com/cymbol => ../cymbol
+	example.com/interp => ../chapter9
+	example.com/symtab => ../chapter6
+	example.com/types => ../chapter8
+)
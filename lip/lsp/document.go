@@ -0,0 +1,271 @@
+package lsp
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"example.com/cymbol"
+	"example.com/lip/analysis"
+	"example.com/symtab"
+)
+
+// Documents
+//
+// A document is analyzed whole each time it changes, the way the lip check
+// command does it: parsed, its names resolved by the two passes of the symbol
+// table and its types checked, see analysis.Load. Programs are small enough
+// for that to take no time, and the passes of the book aren't incremental
+// anyway. Everything the editor asks about a document is then answered from
+// the results: the diagnostics are the syntax error or the semantic errors,
+// hover and definition are the ToolInfo of the name at the cursor, its
+// references are the names the index resolves to the same symbol, the symbols
+// are the outline, see cymbol.Outline, and the semantic tokens are the tokens
+// the index classifies, see symtab.Index.Classify.
+//
+// Editors count positions in UTF-16 code units from zero, cymbol in runes
+// from one, so positions are converted with the text of the line they're on.
+
+// document is an open document and what's known about it.
+type document struct {
+	uri   string
+	lines []string
+	ts    *cymbol.TokenStream
+	prog  *analysis.Program // nil if there's a syntax error
+	diags []Diagnostic
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, lines: strings.Split(text, "\n")}
+	d.analyze(text)
+	return d
+}
+
+func (d *document) analyze(text string) {
+	var err error
+	d.prog, err = analysis.Load(text)
+	if err != nil {
+		d.diags = []Diagnostic{d.diagnostic(errorSpan(err), err)}
+		return
+	}
+	d.ts, _ = cymbol.NewTokenStream(text)
+	d.diags = []Diagnostic{}
+	for _, diag := range d.prog.Diagnostics.List() {
+		d.diags = append(d.diags, d.diagnostic(diag.Span, errors.New(diag.Message)))
+	}
+}
+
+// errorSpan returns the span of a syntax error, an empty one at its position.
+func errorSpan(err error) cymbol.Span {
+	var e *cymbol.Error
+	if errors.As(err, &e) {
+		return cymbol.Span{From: e.Pos, To: e.Pos}
+	}
+	return cymbol.Span{From: cymbol.Pos{Line: 1, Col: 1}, To: cymbol.Pos{Line: 1, Col: 1}}
+}
+
+// diagnostic makes a diagnostic of err for span. Editors don't show an empty
+// range, so the one of an error found at a position is the character there.
+func (d *document) diagnostic(span cymbol.Span, err error) Diagnostic {
+	var e *cymbol.Error
+	msg := err.Error()
+	if errors.As(err, &e) {
+		msg = e.Err.Error()
+	}
+	r := d.lspRange(span)
+	if r.Start == r.End && span.From.Line >= 1 && span.From.Line <= len(d.lines) {
+		if line := d.lines[span.From.Line-1]; span.From.Col <= utf8.RuneCountInString(line) {
+			r.End = d.position(cymbol.Pos{Line: span.From.Line, Col: span.From.Col + 1})
+		}
+	}
+	return Diagnostic{Range: r, Severity: SeverityError, Source: "lip", Message: msg}
+}
+
+// position converts a position of cymbol to the editor's.
+func (d *document) position(p cymbol.Pos) Position {
+	if p.Line < 1 || p.Line > len(d.lines) {
+		return Position{Line: max(p.Line-1, 0)}
+	}
+	units, col := 0, 1
+	for _, r := range d.lines[p.Line-1] {
+		if col >= p.Col {
+			break
+		}
+		units += utf16.RuneLen(r)
+		col++
+	}
+	return Position{Line: p.Line - 1, Character: units}
+}
+
+// pos converts a position of the editor to cymbol's.
+func (d *document) pos(p Position) cymbol.Pos {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return cymbol.Pos{Line: p.Line + 1, Col: 1}
+	}
+	col, units := 1, 0
+	for _, r := range d.lines[p.Line] {
+		if units >= p.Character {
+			break
+		}
+		units += utf16.RuneLen(r)
+		col++
+	}
+	return cymbol.Pos{Line: p.Line + 1, Col: col}
+}
+
+func (d *document) lspRange(s cymbol.Span) Range {
+	return Range{Start: d.position(s.From), End: d.position(s.To)}
+}
+
+// hover describes the symbol of the name at p, nil if there's none.
+func (d *document) hover(p Position) *Hover {
+	if d.prog == nil {
+		return nil
+	}
+	info := d.prog.ToolInfo(d.pos(p))
+	if info == nil {
+		return nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```cymbol\n" + info.Declaration + "\n```"},
+		Range:    d.lspRange(info.Span),
+	}
+}
+
+// definition returns where the symbol of the name at p is declared, nil if
+// there's no such symbol or it's built in.
+func (d *document) definition(p Position) *Location {
+	if d.prog == nil {
+		return nil
+	}
+	info := d.prog.ToolInfo(d.pos(p))
+	if info == nil || info.Definition == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.lspRange(*info.Definition)}
+}
+
+// references returns where the symbol of the name at p is used, and declared
+// if decl, in the order of the document. It's an empty list if there's no
+// such symbol.
+func (d *document) references(p Position, decl bool) []Location {
+	locs := []Location{}
+	if d.prog == nil {
+		return locs
+	}
+	sym, _ := d.prog.Index.SymbolAt(d.pos(p))
+	var def cymbol.Span
+	if id := d.prog.Index.Definition(sym); id != nil {
+		def = id.Token.Span()
+	}
+	for _, span := range d.prog.Index.References(sym) {
+		if span == def && !decl {
+			continue
+		}
+		locs = append(locs, Location{URI: d.uri, Range: d.lspRange(span)})
+	}
+	return locs
+}
+
+// symbols returns the outline of the document, see cymbol.Outline.
+func (d *document) symbols() []DocumentSymbol {
+	syms := []DocumentSymbol{}
+	if d.prog == nil {
+		return syms
+	}
+	for _, sym := range cymbol.Outline(d.ts, d.prog.File) {
+		syms = append(syms, d.symbol(sym))
+	}
+	return syms
+}
+
+// symbolKinds are the kinds of the editor for the kinds of the outline.
+var symbolKinds = map[cymbol.SymbolKind]SymbolKind{
+	cymbol.SymbolVariable:  KindVariable,
+	cymbol.SymbolFunction:  KindFunction,
+	cymbol.SymbolStruct:    KindStruct,
+	cymbol.SymbolClass:     KindClass,
+	cymbol.SymbolField:     KindField,
+	cymbol.SymbolMethod:    KindMethod,
+	cymbol.SymbolNamespace: KindNamespace,
+}
+
+func (d *document) symbol(sym cymbol.Symbol) DocumentSymbol {
+	s := DocumentSymbol{
+		Name:           sym.Name,
+		Detail:         sym.Detail,
+		Kind:           symbolKinds[sym.Kind],
+		Range:          d.lspRange(sym.Span),
+		SelectionRange: d.lspRange(sym.NameSpan),
+	}
+	for _, c := range sym.Children {
+		s.Children = append(s.Children, d.symbol(c))
+	}
+	return s
+}
+
+// the legend of the semantic tokens: the types and the modifiers, as a bit
+// set, are indexes in these
+var (
+	tokenTypes     = []string{"keyword", "type", "struct", "class", "variable", "parameter", "property", "function", "method", "namespace", "number", "string"}
+	tokenModifiers = []string{"declaration"}
+)
+
+// kindTokenTypes are the token types of the names, by their analysis.Kind.
+var kindTokenTypes = map[string]string{
+	"field":     "property",
+	"parameter": "parameter",
+	"variable":  "variable",
+	"method":    "method",
+	"function":  "function",
+	"struct":    "struct",
+	"class":     "class",
+	"type":      "type",
+	"namespace": "namespace",
+}
+
+// semanticTokens returns the semantic tokens of the document, none if
+// there's a syntax error.
+func (d *document) semanticTokens() SemanticTokens {
+	st := SemanticTokens{Data: []int{}}
+	if d.prog == nil {
+		return st
+	}
+	var last Position
+	for _, t := range d.prog.Index.Classify(d.ts.Tokens) {
+		typ, mods := tokenType(t), 0
+		if t.Category == symtab.CategoryDefinition {
+			mods = 1
+		}
+		r := d.lspRange(t.Token.Span())
+		char := r.Start.Character
+		if r.Start.Line == last.Line {
+			char -= last.Character
+		}
+		st.Data = append(st.Data, r.Start.Line-last.Line, char, r.End.Character-r.Start.Character, slices.Index(tokenTypes, typ), mods)
+		last = r.Start
+	}
+	return st
+}
+
+// tokenType is the type in the legend of a classified token.
+func tokenType(t symtab.SemanticToken) string {
+	switch t.Category {
+	case symtab.CategoryKeyword:
+		return "keyword"
+	case symtab.CategoryLiteral:
+		switch t.Token.Type {
+		case cymbol.Int, cymbol.Float:
+			return "number"
+		case cymbol.Char, cymbol.String:
+			return "string"
+		}
+		return "keyword" // true and false
+	}
+	if t.Symbol == nil {
+		return "variable"
+	}
+	return kindTokenTypes[analysis.Kind(t.Symbol)]
+}
@@ -0,0 +1,225 @@
+// Package lsp is a language server for Cymbol: it speaks the Language Server
+// Protocol with an editor over the standard input and output, and gives it
+// the diagnostics of the programs being edited, the types of the names under
+// the cursor, where they're declared and used, the outline of the
+// declarations and what each name is, to color it.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// The server
+//
+// An editor starts the server and sends it an initialize request, to which the
+// server answers with what it can do, then the documents opened and every
+// change made to them, and requests about them: hover, go to definition,
+// references, document symbols, semantic tokens. The server sends the
+// diagnostics of a document each time it changes. A shutdown request then an
+// exit notification end the session:
+//
+//	→ initialize                        ← capabilities
+//	→ textDocument/didOpen              ← textDocument/publishDiagnostics
+//	→ textDocument/hover                ← int x
+//	→ shutdown, exit
+//
+// Messages are handled one at a time, in the order they come.
+
+// Server is a language server. The zero value isn't usable, see NewServer.
+type Server struct {
+	out         io.Writer
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer() *Server {
+	return &Server{docs: map[string]*document{}}
+}
+
+// Serve handles the messages read from in, writing the responses and
+// notifications to out, until the exit notification or the end of in.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+	r := bufio.NewReader(in)
+	for {
+		m, err := readMessage(r)
+		var rerr *responseError
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case errors.As(err, &rerr):
+			// the id is in the message that couldn't be read
+			if err := s.respond(json.RawMessage("null"), nil, rerr); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		}
+		if m.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(m)
+		rerr = nil
+		if err != nil && !errors.As(err, &rerr) {
+			return err
+		}
+		if m.ID == nil {
+			// notifications get no response, even if they fail
+			continue
+		}
+		if err := s.respond(*m.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+// respond sends the response to the request with the given id.
+func (s *Server) respond(id json.RawMessage, result any, rerr *responseError) error {
+	m := &message{ID: &id, Error: rerr}
+	if rerr == nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		m.Result = b
+	}
+	return writeMessage(s.out, m)
+}
+
+// notify sends a notification to the editor.
+func (s *Server) notify(method string, params any) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: b})
+}
+
+// handle handles a request or a notification, returning the result of a
+// request. The error is a *responseError if the request failed, any other is
+// the server's own.
+func (s *Server) handle(m *message) (any, error) {
+	if !s.initialized && m.Method != "initialize" {
+		return nil, &responseError{serverNotInitialized, "the server isn't initialized"}
+	}
+	switch m.Method {
+	case "initialize":
+		s.initialized = true
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // the whole document at each change
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"documentSymbolProvider": true,
+				"semanticTokensProvider": map[string]any{
+					"legend": map[string]any{"tokenTypes": tokenTypes, "tokenModifiers": tokenModifiers},
+					"full":   true,
+				},
+			},
+			"serverInfo": map[string]string{"name": "lip"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := decode(m.Params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := decode(m.Params, &p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			return nil, s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := decode(m.Params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, s.publish(PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		d, err := s.document(m.Params, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.hover(p.Position), nil
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		d, err := s.document(m.Params, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.definition(p.Position), nil
+	case "textDocument/references":
+		var p ReferenceParams
+		d, err := s.document(m.Params, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.references(p.Position, p.Context.IncludeDeclaration), nil
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		d, err := s.document(m.Params, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.symbols(), nil
+	case "textDocument/semanticTokens/full":
+		var p SemanticTokensParams
+		d, err := s.document(m.Params, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.semanticTokens(), nil
+	}
+	return nil, &responseError{methodNotFound, "method not found: " + m.Method}
+}
+
+// update analyzes the new text of a document and publishes its diagnostics.
+func (s *Server) update(uri, text string) error {
+	d := newDocument(uri, text)
+	s.docs[uri] = d
+	return s.publish(PublishDiagnosticsParams{URI: uri, Diagnostics: d.diags})
+}
+
+func (s *Server) publish(p PublishDiagnosticsParams) error {
+	return s.notify("textDocument/publishDiagnostics", p)
+}
+
+// document decodes the params of a request about a document into p, and
+// returns the document id identifies once decoded.
+func (s *Server) document(params json.RawMessage, p any, id *TextDocumentIdentifier) (*document, error) {
+	if err := decode(params, p); err != nil {
+		return nil, err
+	}
+	d, ok := s.docs[id.URI]
+	if !ok {
+		return nil, &responseError{invalidParams, "unknown document: " + id.URI}
+	}
+	return d, nil
+}
+
+func decode(params json.RawMessage, p any) error {
+	if err := json.Unmarshal(params, p); err != nil {
+		return &responseError{invalidParams, err.Error()}
+	}
+	return nil
+}
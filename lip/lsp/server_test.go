@@ -0,0 +1,201 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// session sends the messages to a new server and returns the ones it sent
+// back, as JSON.
+func session(t *testing.T, msgs ...string) []string {
+	t.Helper()
+	var in strings.Builder
+	for _, m := range msgs {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	var out strings.Builder
+	if err := NewServer().Serve(strings.NewReader(in.String()), &out); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	r := bufio.NewReader(strings.NewReader(out.String()))
+	for {
+		m, err := readMessage(r)
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(b))
+	}
+}
+
+const initialize = `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`
+
+func open(text string) string {
+	b, _ := json.Marshal(text)
+	return `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///p.cym","version":1,"text":` + string(b) + `}}}`
+}
+
+func at(method string, line, char int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"textDocument/%s","params":{"textDocument":{"uri":"file:///p.cym"},"position":{"line":%d,"character":%d}}}`, method, line, char)
+}
+
+func refs(line, char int, decl bool) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"textDocument/references","params":{"textDocument":{"uri":"file:///p.cym"},"position":{"line":%d,"character":%d},"context":{"includeDeclaration":%t}}}`, line, char, decl)
+}
+
+const initialized = `{"jsonrpc":"2.0","id":0,"result":{"capabilities":{"definitionProvider":true,"documentSymbolProvider":true,"hoverProvider":true,"referencesProvider":true,"semanticTokensProvider":{"full":true,"legend":{"tokenModifiers":["declaration"],"tokenTypes":["keyword","type","struct","class","variable","parameter","property","function","method","namespace","number","string"]}},"textDocumentSync":1},"serverInfo":{"name":"lip"}}}`
+
+func TestServer(t *testing.T) {
+	src := "int x = 1;\nfloat f(float a) {\n    string s = \"😀\"; return a + x;\n}\nclass A { int y; }; class B : A { void g() { y = 2; } };\n"
+	cases := []struct {
+		name string
+		msgs []string
+		want []string
+	}{
+		{
+			name: "diagnostics",
+			msgs: []string{initialize, open("int x = y;\nvoid f() { boolean b = 1; }")},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[` +
+					`{"range":{"start":{"line":0,"character":8},"end":{"line":0,"character":9}},"severity":1,"source":"lip","message":"undefined: y"},` +
+					`{"range":{"start":{"line":1,"character":23},"end":{"line":1,"character":24}},"severity":1,"source":"lip","message":"cannot use 1 (int) as boolean value in initialization"}]}}`,
+			},
+		},
+		{
+			name: "syntax error",
+			msgs: []string{initialize, open("int x\n"), `{"jsonrpc":"2.0","id":1,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"file:///p.cym"}}}`},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[` +
+					`{"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":0}},"severity":1,"source":"lip","message":"syntax error: expecting Semicolon, found EOF"}]}}`,
+				`{"jsonrpc":"2.0","id":1,"result":[]}`,
+			},
+		},
+		{
+			name: "hover",
+			msgs: []string{initialize, open(src), at("hover", 2, 32), at("hover", 1, 6), at("hover", 4, 26), at("hover", 0, 3)},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[]}}`,
+				`{"jsonrpc":"2.0","id":1,"result":{"contents":{"kind":"markdown","value":"` + "```cymbol\\nint x\\n```" + `"},"range":{"start":{"line":2,"character":32},"end":{"line":2,"character":33}}}}`,
+				`{"jsonrpc":"2.0","id":1,"result":{"contents":{"kind":"markdown","value":"` + "```cymbol\\nfloat f(float a)\\n```" + `"},"range":{"start":{"line":1,"character":6},"end":{"line":1,"character":7}}}}`,
+				`{"jsonrpc":"2.0","id":1,"result":{"contents":{"kind":"markdown","value":"` + "```cymbol\\nclass B : A\\n```" + `"},"range":{"start":{"line":4,"character":26},"end":{"line":4,"character":27}}}}`,
+				`{"jsonrpc":"2.0","id":1,"result":null}`,
+			},
+		},
+		{
+			name: "definition",
+			msgs: []string{initialize, open(src), at("definition", 2, 32), at("definition", 4, 45), at("definition", 0, 1)},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[]}}`,
+				`{"jsonrpc":"2.0","id":1,"result":{"uri":"file:///p.cym","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}}}}`,
+				`{"jsonrpc":"2.0","id":1,"result":{"uri":"file:///p.cym","range":{"start":{"line":4,"character":14},"end":{"line":4,"character":15}}}}`,
+				`{"jsonrpc":"2.0","id":1,"result":null}`,
+			},
+		},
+		{
+			name: "references",
+			msgs: []string{initialize, open(src), refs(0, 4, true), refs(4, 45, false), refs(2, 20, true)},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[]}}`,
+				`{"jsonrpc":"2.0","id":1,"result":[` +
+					`{"uri":"file:///p.cym","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}}},` +
+					`{"uri":"file:///p.cym","range":{"start":{"line":2,"character":32},"end":{"line":2,"character":33}}}]}`,
+				`{"jsonrpc":"2.0","id":1,"result":[{"uri":"file:///p.cym","range":{"start":{"line":4,"character":45},"end":{"line":4,"character":46}}}]}`,
+				`{"jsonrpc":"2.0","id":1,"result":[]}`,
+			},
+		},
+		{
+			name: "semantic tokens",
+			msgs: []string{initialize, open("int x = 1;\nvoid f(int a) { x = a; }"), `{"jsonrpc":"2.0","id":1,"method":"textDocument/semanticTokens/full","params":{"textDocument":{"uri":"file:///p.cym"}}}`},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[]}}`,
+				`{"jsonrpc":"2.0","id":1,"result":{"data":[0,0,3,1,0,0,4,1,4,1,0,4,1,10,0,1,0,4,1,0,0,5,1,7,1,0,2,3,1,0,0,4,1,5,1,0,5,1,4,0,0,4,1,5,0]}}`,
+			},
+		},
+		{
+			name: "document symbols",
+			msgs: []string{initialize, open(src), `{"jsonrpc":"2.0","id":1,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"file:///p.cym"}}}`},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[]}}`,
+				`{"jsonrpc":"2.0","id":1,"result":[` +
+					`{"name":"x","detail":"int","kind":13,"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":10}},"selectionRange":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}}},` +
+					`{"name":"f","detail":"float (float a)","kind":12,"range":{"start":{"line":1,"character":0},"end":{"line":3,"character":1}},"selectionRange":{"start":{"line":1,"character":6},"end":{"line":1,"character":7}},"children":[` +
+					`{"name":"s","detail":"string","kind":13,"range":{"start":{"line":2,"character":4},"end":{"line":2,"character":20}},"selectionRange":{"start":{"line":2,"character":11},"end":{"line":2,"character":12}}}]},` +
+					`{"name":"A","kind":5,"range":{"start":{"line":4,"character":0},"end":{"line":4,"character":19}},"selectionRange":{"start":{"line":4,"character":6},"end":{"line":4,"character":7}},"children":[` +
+					`{"name":"y","detail":"int","kind":8,"range":{"start":{"line":4,"character":10},"end":{"line":4,"character":16}},"selectionRange":{"start":{"line":4,"character":14},"end":{"line":4,"character":15}}}]},` +
+					`{"name":"B","detail":": A","kind":5,"range":{"start":{"line":4,"character":20},"end":{"line":4,"character":56}},"selectionRange":{"start":{"line":4,"character":26},"end":{"line":4,"character":27}},"children":[` +
+					`{"name":"g","detail":"void ()","kind":6,"range":{"start":{"line":4,"character":34},"end":{"line":4,"character":53}},"selectionRange":{"start":{"line":4,"character":39},"end":{"line":4,"character":40}}}]}]}`,
+			},
+		},
+		{
+			name: "changes and close",
+			msgs: []string{
+				initialize, open("int x;"),
+				`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///p.cym","version":2},"contentChanges":[{"text":"int x = y;"}]}}`,
+				`{"jsonrpc":"2.0","method":"textDocument/didClose","params":{"textDocument":{"uri":"file:///p.cym"}}}`,
+				at("hover", 0, 4),
+			},
+			want: []string{
+				initialized,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[]}}`,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[` +
+					`{"range":{"start":{"line":0,"character":8},"end":{"line":0,"character":9}},"severity":1,"source":"lip","message":"undefined: y"}]}}`,
+				`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///p.cym","diagnostics":[]}}`,
+				`{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"unknown document: file:///p.cym"}}`,
+			},
+		},
+		{
+			name: "errors",
+			msgs: []string{
+				at("hover", 0, 0),
+				initialize,
+				`{"jsonrpc":"2.0","id":2,"method":"textDocument/rename","params":{}}`,
+				`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+				`{"jsonrpc":"2.0","method":"exit"}`,
+				`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+			},
+			want: []string{
+				`{"jsonrpc":"2.0","id":1,"error":{"code":-32002,"message":"the server isn't initialized"}}`,
+				initialized,
+				`{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"method not found: textDocument/rename"}}`,
+				`{"jsonrpc":"2.0","id":3,"result":null}`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := session(t, tc.msgs...)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	in := `{"jsonrpc":"2.0","method":"exit"}`
+	err := NewServer().Serve(strings.NewReader(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(in), in)), io.Discard)
+	if err == nil {
+		t.Error("got no error")
+	}
+}
@@ -0,0 +1,564 @@
+// Command lip runs the patterns of the book on Cymbol programs and on
+// bytecode, one subcommand for each step from source to running code:
+//
+//	lip lex [-dump] [file]                                 the tokens
+//	lip parse [file]                                       the tree, as source
+//	lip ast [file]                                         the tree, node by node
+//	lip outline [file]                                     the declarations, nested
+//	lip query path [file]                                  the nodes a query selects
+//	lip fmt [-w] [file...]                                 the formatted source
+//	lip pre [file]                                         the source preprocessed
+//	lip check [-with symbols|types|flow] [-sarif] [file]   the semantic errors
+//	lip run [-with tree|stack] [file]                      the program run
+//	lip asm [-register] [-o object] [file]                 bytecode assembled
+//	lip disasm [file]                                      bytecode disassembled
+//	lip lsp                                                a language server
+//	lip serve [-addr localhost:8080]                       a playground in the browser
+//
+// Each reads the file named, or the standard input if there's none or it's
+// "-". The flags pick the pattern where there's more than one for a step.
+// The errors in a program are printed with the lines they're at, see
+// cymbol.Render, in colors on a terminal unless NO_COLOR is set.
+// Check and run also take a program of several files, loaded into one, see
+// cymbol.Load, or with -pre preprocess the program first, as lip pre prints
+// it. The language server speaks LSP over the standard input and output, see
+// package lsp, and the playground is a web page, see serve.go.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"example.com/bytecode"
+	"example.com/cymbol"
+	"example.com/interp"
+	"example.com/lip/analysis"
+	"example.com/lip/lsp"
+)
+
+// commands run by name as the first argument
+var commands = map[string]func(args []string, in io.Reader, out io.Writer) error{
+	"lex":     lexCmd,
+	"parse":   parseCmd,
+	"ast":     astCmd,
+	"outline": outlineCmd,
+	"query":   queryCmd,
+	"fmt":     fmtCmd,
+	"check":   checkCmd,
+	"pre":     preCmd,
+	"run":     runCmd,
+	"asm":     asmCmd,
+	"disasm":  disasmCmd,
+	"lsp":     lspCmd,
+	"serve":   serveCmd,
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		cymbol.Render(os.Stderr, err, cymbol.UseColor(os.Stderr))
+		os.Exit(1)
+	}
+}
+
+func run(args []string, in io.Reader, out io.Writer) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd(args[1:], in, out)
+		}
+	}
+	names := slices.Sorted(maps.Keys(commands))
+	return fmt.Errorf("usage: lip %s [flags] [file]", strings.Join(names, "|"))
+}
+
+// read returns the contents of the only file in args, or of in if there's
+// none or it's "-".
+func read(fs *flag.FlagSet, in io.Reader) (string, error) {
+	var b []byte
+	var err error
+	switch fs.NArg() {
+	case 0:
+		b, err = io.ReadAll(in)
+	case 1:
+		if fs.Arg(0) == "-" {
+			b, err = io.ReadAll(in)
+		} else {
+			b, err = os.ReadFile(fs.Arg(0))
+		}
+	default:
+		return "", fmt.Errorf("%s: one file at most, got %s", fs.Name(), strings.Join(fs.Args(), " "))
+	}
+	return string(b), err
+}
+
+// lexCmd prints the tokens of a Cymbol program, one per line with its
+// position and type. With -dump they're printed as a dump, the text quoted
+// and in columns, see cymbol.Dump: diff the dumps of the same program to
+// tell what a change to the lexer does.
+func lexCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("lex", flag.ContinueOnError)
+	dump := fs.Bool("dump", false, "print the tokens as a dump, to diff")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	if *dump {
+		d, err := cymbol.Dump(src)
+		if _, werr := io.WriteString(out, d); werr != nil {
+			return werr
+		}
+		return cymbol.WithSource(src, err)
+	}
+	lex := cymbol.NewLexer(src)
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return cymbol.WithSource(src, err)
+		}
+		fmt.Fprintf(out, "%v %v %s\n", tok.Pos, tok.Type, tok.Text)
+		if tok.Type == cymbol.EOF {
+			return nil
+		}
+	}
+}
+
+// parseCmd prints the declarations of a Cymbol program as the parser sees
+// them, one per line with the expressions fully parenthesized.
+func parseCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	for _, d := range f.Decls {
+		fmt.Fprintln(out, d)
+	}
+	return nil
+}
+
+// astCmd prints the tree of a Cymbol program, a node per line indented
+// under its parent, with its position.
+func astCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("ast", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	depth := 0
+	cymbol.Apply(f, func(c *cymbol.Cursor) bool {
+		n := c.Node()
+		fmt.Fprintf(out, "%s%v %s\n", strings.Repeat("  ", depth), n.Pos(), analysis.Describe(n))
+		depth++
+		return true
+	}, func(c *cymbol.Cursor) bool {
+		depth--
+		return true
+	})
+	return nil
+}
+
+// outlineCmd prints the outline of a Cymbol program, a declaration per line
+// indented under the one it's in, with its span, its kind, its name and what
+// else it declares:
+//
+//	1:1-4:2 func fact int (int n)
+//	  2:5-2:11 var r int
+func outlineCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("outline", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	ts, err := cymbol.NewTokenStream(src)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	var write func(syms []cymbol.Symbol, depth int)
+	write = func(syms []cymbol.Symbol, depth int) {
+		for _, s := range syms {
+			line := fmt.Sprintf("%s%v-%v %v %s %s", strings.Repeat("  ", depth), s.Span.From, s.Span.To, s.Kind, s.Name, s.Detail)
+			fmt.Fprintln(out, strings.TrimRight(line, " "))
+			write(s.Children, depth+1)
+		}
+	}
+	write(cymbol.Outline(ts, f), 0)
+	return nil
+}
+
+// queryCmd prints the nodes of the tree of a Cymbol program that a query
+// selects, see cymbol.CompileQuery, one per line as lip ast prints them:
+//
+//	lip query '//assign/name[1]' prog.cym
+//	3:5 Ident r
+func queryCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("query: no query")
+	}
+	q, err := cymbol.CompileQuery(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	f, err := cymbol.ParseFile(src)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	for _, n := range q.Select(f) {
+		fmt.Fprintf(out, "%v %s\n", n.Pos(), analysis.Describe(n))
+	}
+	return nil
+}
+
+// fmtCmd prints the formatted source of Cymbol programs, or with -w writes
+// it back to their files.
+func fmtCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := fs.Bool("w", false, "write the result to the files instead of printing it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() <= 1 && !*write {
+		src, err := read(fs, in)
+		if err != nil {
+			return err
+		}
+		formatted, err := cymbol.Format(src)
+		if err != nil {
+			return cymbol.WithSource(src, err)
+		}
+		_, err = io.WriteString(out, formatted)
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("fmt: -w needs files")
+	}
+	for _, name := range fs.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		formatted, err := cymbol.Format(string(src))
+		if err != nil {
+			return cymbol.WithSource(string(src), fmt.Errorf("%s:%w", name, err))
+		}
+		if !*write {
+			_, err = io.WriteString(out, formatted)
+		} else if formatted != string(src) {
+			err = os.WriteFile(name, []byte(formatted), 0o666)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCmd reports the errors of a Cymbol program, one per line, found by
+// the checker named with -with. Nothing is printed if there are none. With
+// -sarif they're printed as a SARIF log instead, see
+// cymbol.Diagnostics.WriteSARIF, for code scanning: the log is the report, so
+// the check succeeds whatever it finds, the syntax error included.
+func checkCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	with := fs.String("with", "types", "what to check: `symbols, types or flow`")
+	pre := fs.Bool("pre", false, "preprocess the program first, the errors located in its files")
+	sarif := fs.Bool("sarif", false, "print the errors as a SARIF log")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !slices.Contains(analysis.Checks, *with) {
+		return fmt.Errorf("check: -with must be symbols, types or flow, not %q", *with)
+	}
+	p, err := source(fs, in, *pre)
+	if err != nil {
+		return err
+	}
+	var diags *cymbol.Diagnostics
+	if f, err := cymbol.ParseFile(p.text); err != nil {
+		diags = new(cymbol.Diagnostics)
+		diags.AddError(err)
+	} else {
+		diags = analysis.Check(f, *with)
+	}
+	if *sarif {
+		return diags.WriteSARIF(out, "lip", p.locate)
+	}
+	return p.error(diags.Err())
+}
+
+// preCmd prints a Cymbol program preprocessed, its files included and its
+// macros expanded, see cymbol.Preprocess.
+func preCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("pre", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	pp, err := preprocess(fs, src)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, pp.Text)
+	return err
+}
+
+// program is a program read by a command: its text, and if it's made of
+// more than one file the SourceMap of the text and the texts of the files,
+// by name, or else the name of its file.
+type program struct {
+	text  string
+	m     *cymbol.SourceMap
+	files map[string]string
+	name  string // "stdin" for the standard input
+}
+
+// locate returns the location of pos, a position of the text, in the files
+// of the program.
+func (p *program) locate(pos cymbol.Pos) cymbol.Location {
+	if p.m == nil {
+		return cymbol.Location{File: p.name, Pos: pos}
+	}
+	return p.m.Locate(pos)
+}
+
+// error returns err, an error of the program, located in its files as
+// locate does, with its source for cymbol.Render.
+func (p *program) error(err error) error {
+	if err == nil || p.m == nil {
+		return cymbol.WithSource(p.text, err)
+	}
+	return &cymbol.SourceError{Sources: p.files, Err: locate(p.m, err)}
+}
+
+// source reads the program the arguments of fs name, as read does, with the
+// SourceMap of its text if it's made of more than one file: the files named
+// loaded into one program, see cymbol.Load, or with pre the file named
+// preprocessed. The map is nil for a single file as it is.
+func source(fs *flag.FlagSet, in io.Reader, pre bool) (*program, error) {
+	if fs.NArg() > 1 {
+		if pre {
+			return nil, fmt.Errorf("%s: -pre takes one file, got %s", fs.Name(), strings.Join(fs.Args(), " "))
+		}
+		files := map[string]string{}
+		for _, name := range fs.Args() {
+			b, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = string(b)
+		}
+		p, err := cymbol.Load(files)
+		if err != nil {
+			return nil, err
+		}
+		return &program{text: p.Text, m: &p.SourceMap, files: files}, nil
+	}
+	src, err := read(fs, in)
+	if err != nil || !pre {
+		name := fs.Arg(0)
+		if name == "" || name == "-" {
+			name = "stdin"
+		}
+		return &program{text: src, name: name}, err
+	}
+	pp, err := preprocess(fs, src)
+	if err != nil {
+		return nil, err
+	}
+	return &program{text: pp.Text, m: &pp.SourceMap, files: pp.Files}, nil
+}
+
+// locate takes the errors of a program back to its files with m, as
+// SourceMap.Error does, the runtime errors of the interpreter included. It
+// returns err if m is nil.
+func locate(m *cymbol.SourceMap, err error) error {
+	if m == nil {
+		return err
+	}
+	var re *interp.RuntimeError
+	if errors.As(err, &re) {
+		var stack strings.Builder
+		for _, c := range re.Stack {
+			fmt.Fprintf(&stack, "\n\tin %s called at %v", c.Func, m.Locate(c.Pos))
+		}
+		return &cymbol.LocatedError{Loc: m.Locate(re.Span.From), Err: fmt.Errorf("%w%s", re.Err, stack.String())}
+	}
+	return m.Error(err)
+}
+
+// preprocess preprocesses src, read from the file named in fs, including
+// files from its directory, or from the current one for the standard input.
+func preprocess(fs *flag.FlagSet, src string) (*cymbol.Preprocessed, error) {
+	name := fs.Arg(0)
+	if name == "" || name == "-" {
+		return cymbol.Preprocess(os.DirFS("."), "stdin", src)
+	}
+	return cymbol.Preprocess(os.DirFS(filepath.Dir(name)), filepath.Base(name), src)
+}
+
+// runCmd runs a Cymbol program with the tree-based interpreter, or compiled
+// for the stack machine. The program runs its main function: the interpreter
+// runs the statements at the top level, if any, and then main if the program
+// declares it.
+func runCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	with := fs.String("with", "tree", "what runs the program: `tree or stack`")
+	pre := fs.Bool("pre", false, "preprocess the program first, the errors located in its files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *with != "tree" && *with != "stack" {
+		return fmt.Errorf("run: -with must be tree or stack, not %q", *with)
+	}
+	p, err := source(fs, in, *pre)
+	if err != nil {
+		return err
+	}
+	if *with == "stack" {
+		prog, err := bytecode.Compile(p.text)
+		if err != nil {
+			return p.error(err)
+		}
+		vm := bytecode.NewStackVM(prog)
+		vm.Stdout = out
+		return vm.Run()
+	}
+	it := interp.New()
+	it.Stdout = out
+	if _, err := it.Run(p.text); err != nil {
+		return p.error(err)
+	}
+	nodes, _ := cymbol.ParseInput(p.text) // parsed by Run already
+	for _, n := range nodes {
+		if d, ok := n.(*cymbol.FuncDecl); ok && d.Name.Name == "main" {
+			_, err := it.Run("main();")
+			var re *interp.RuntimeError
+			if p.m != nil && errors.As(err, &re) && len(re.Stack) > 0 {
+				// the call of main is in none of the files
+				re.Stack = re.Stack[:len(re.Stack)-1]
+			}
+			return p.error(err)
+		}
+	}
+	return nil
+}
+
+// asmCmd assembles a program for the stack machine, or the register machine
+// with -register, and writes it as an object file.
+func asmCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("asm", flag.ContinueOnError)
+	register := fs.Bool("register", false, "assemble for the register machine")
+	object := fs.String("o", "", "write the object to `file` rather than the standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	machine := bytecode.Stack
+	if *register {
+		machine = bytecode.Register
+	}
+	prog, err := bytecode.Assemble(src, machine)
+	if err != nil {
+		return cymbol.WithSource(src, err)
+	}
+	if *object == "" {
+		return bytecode.WriteObject(out, prog)
+	}
+	f, err := os.Create(*object)
+	if err != nil {
+		return err
+	}
+	if err := bytecode.WriteObject(f, prog); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// disasmCmd prints the disassembly of an object file, or of a stack machine
+// program assembled, or compiled from Cymbol with -cymbol.
+func disasmCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	compile := fs.Bool("cymbol", false, "compile the program from Cymbol")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	src, err := read(fs, in)
+	if err != nil {
+		return err
+	}
+	var prog *bytecode.Program
+	switch {
+	case bytecode.IsObject([]byte(src)):
+		prog, err = bytecode.ReadObject(strings.NewReader(src))
+	case *compile:
+		prog, err = bytecode.Compile(src)
+		err = cymbol.WithSource(src, err)
+	default:
+		prog, err = bytecode.Assemble(src, bytecode.Stack)
+		err = cymbol.WithSource(src, err)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, bytecode.Disassemble(prog))
+	return err
+}
+
+// lspCmd runs the language server on in and out until the editor exits it.
+func lspCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("lsp: no arguments expected, got %s", strings.Join(fs.Args(), " "))
+	}
+	return lsp.NewServer().Serve(in, out)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/cymbol"
+	"github.com/google/go-cmp/cmp"
+)
+
+const fact = `int fact(int n) {
+    if (n < 2) return 1;
+    return n * fact(n - 1);
+}
+void main() { print(fact(5)); }
+`
+
+func TestCommands(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "lex",
+			args: []string{"lex"},
+			in:   "int x = 1;",
+			want: "1:1 ID int\n1:5 ID x\n1:7 Assign =\n1:9 Int 1\n1:10 Semicolon ;\n1:11 EOF \n",
+		},
+		{
+			name: "lex dump",
+			args: []string{"lex", "-dump"},
+			in:   "int x = 1;",
+			want: "1:1     ID         \"int\"\n1:5     ID         \"x\"\n1:7     Assign     \"=\"\n1:9     Int        \"1\"\n1:10    Semicolon  \";\"\n1:11    EOF        \"\"\n",
+		},
+		{
+			name:    "lex dump error",
+			args:    []string{"lex", "--dump"},
+			in:      "int #",
+			want:    "1:1     ID         \"int\"\n",
+			wantErr: "1:5: invalid character: '#'",
+		},
+		{
+			name:    "lex error",
+			args:    []string{"lex", "-"},
+			in:      "int #",
+			want:    "1:1 ID int\n",
+			wantErr: "1:5: invalid character: '#'",
+		},
+		{
+			name: "parse",
+			args: []string{"parse"},
+			in:   "int x = 1 + 2 * 3; void f() { }",
+			want: "int x = (1 + (2 * 3));\nvoid f() { }\n",
+		},
+		{
+			name:    "syntax error",
+			args:    []string{"parse"},
+			in:      "int x",
+			wantErr: "1:6: syntax error: expecting Semicolon, found EOF",
+		},
+		{
+			name: "ast",
+			args: []string{"ast"},
+			in:   "struct p { int x; };\nint f(p a) { return -a.x; }",
+			want: `1:1 File
+  1:1 StructDecl p
+    1:12 VarDecl int x
+  2:1 FuncDecl int f
+    2:7 Param p a
+    2:12 Block
+      2:14 ReturnStmt
+        2:21 UnaryExpr -
+          2:22 MemberExpr .x
+            2:22 Ident a
+`,
+		},
+		{
+			name: "outline",
+			args: []string{"outline"},
+			in:   "struct p { int x; };\nint f(p a) {\n    int y = a.x;\n    return y;\n}",
+			want: `1:1-1:21 struct p
+  1:12-1:18 field x int
+2:1-5:2 func f int (p a)
+  3:5-3:17 var y int
+`,
+		},
+		{
+			name:    "outline syntax error",
+			args:    []string{"outline"},
+			in:      "int f(",
+			wantErr: "1:7: syntax error: expecting ID, found EOF",
+		},
+		{
+			name: "query",
+			args: []string{"query", "//func[@name='f']//binary/*[1]"},
+			in:   "int f(int n) { return n * f(n - 1); } int g() { return 1 + 2; }",
+			want: "1:23 Ident n\n1:29 Ident n\n",
+		},
+		{
+			name:    "query error",
+			args:    []string{"query", "//func["},
+			in:      "int x;",
+			wantErr: "query: 1:8: syntax error: expecting @attribute or an index from 1",
+		},
+		{
+			name: "fmt",
+			args: []string{"fmt"},
+			in:   "int f(){return 1;} // one",
+			want: "int f() {\n    return 1;\n} // one\n",
+		},
+		{
+			name: "check",
+			args: []string{"check"},
+			in:   fact,
+		},
+		{
+			name:    "check types",
+			args:    []string{"check"},
+			in:      "void f() { int x; boolean b = x; y = 1; }",
+			wantErr: "1:31: cannot use x (int) as boolean value in initialization\n1:34: undefined: y",
+		},
+		{
+			name:    "check symbols",
+			args:    []string{"check", "-with", "symbols"},
+			in:      "void f() { int x; boolean b = x; y = 1; }",
+			wantErr: "1:34: undefined: y",
+		},
+		{
+			name:    "check flow",
+			args:    []string{"check", "-with", "flow"},
+			in:      "void f() { int x; int y = x; print(y); }",
+			wantErr: "1:27: x is used uninitialized",
+		},
+		{
+			name:    "unknown check",
+			args:    []string{"check", "-with", "all"},
+			wantErr: `check: -with must be symbols, types or flow, not "all"`,
+		},
+		{
+			name: "run",
+			args: []string{"run"},
+			in:   fact,
+			want: "120\n",
+		},
+		{
+			name: "run statements",
+			args: []string{"run"},
+			in:   "int x = 2; print(x * 3);",
+			want: "6\n",
+		},
+		{
+			name: "run on the stack machine",
+			args: []string{"run", "-with", "stack"},
+			in:   fact,
+			want: "120\n",
+		},
+		{
+			name: "disasm",
+			args: []string{"disasm", "-cymbol"},
+			in:   "void main() { print(1); }",
+			want: ".def main: args=0, locals=0\n    iconst 1\n    print\n    ret\n",
+		},
+		{
+			name:    "too many files",
+			args:    []string{"lex", "a", "b"},
+			wantErr: "lex: one file at most, got a b",
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"compile"},
+			wantErr: "usage: lip asm|ast|check|disasm|fmt|lex|lsp|outline|parse|pre|query|run|serve [flags] [file]",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var s strings.Builder
+			err := run(tc.args, strings.NewReader(tc.in), &s)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("got error %v, want %s", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != tc.want {
+				t.Error(cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "prog.cym")
+	if err := os.WriteFile(src, []byte("int  x;"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"fmt", "-w", src}, nil, &strings.Builder{}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(src); string(b) != "int x;\n" {
+		t.Errorf("formatted file is %q", b)
+	}
+
+	asm := filepath.Join(dir, "prog.asm")
+	obj := filepath.Join(dir, "prog.o")
+	if err := os.WriteFile(asm, []byte(".def main: args=0, locals=0\n    iconst 7\n    print\n    halt\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"asm", "-o", obj, asm}, nil, &strings.Builder{}); err != nil {
+		t.Fatal(err)
+	}
+	var s strings.Builder
+	if err := run([]string{"disasm", obj}, nil, &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := ".def main: args=0, locals=0\n    iconst 7\n    print\n    halt\n"; s.String() != want {
+		t.Error(cmp.Diff(want, s.String()))
+	}
+}
+
+func TestPreprocessedFiles(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.cym")
+	files := map[string]string{
+		main:                                  "#include \"lib/size.cym\"\nint n = SIZE;\nboolean b = SIZE;\n",
+		filepath.Join(dir, "lib", "size.cym"): "#define SIZE 3\nint f() { return y; }\n",
+	}
+	for name, src := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var s strings.Builder
+	if err := run([]string{"pre", main}, nil, &s); err != nil {
+		t.Fatal(err)
+	}
+	if want := "int f() { return y; }\nint n = 3;\nboolean b = 3;\n"; s.String() != want {
+		t.Error(cmp.Diff(want, s.String()))
+	}
+
+	err := run([]string{"check", "-pre", main}, nil, &strings.Builder{})
+	want := "lib/size.cym:2:18: undefined: y\nmain.cym:3:13: cannot use 3 (int) as boolean value in initialization"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestProgramFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.cym": "void main() { print(half(8)); print(half(0)); }\n",
+		"half.cym": "int half(int n) { return 8 / n; }\n",
+		"dup.cym":  "int half;\n",
+	}
+	var names []string
+	for name, src := range files {
+		names = append(names, filepath.Join(dir, name))
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	half, main := filepath.Join(dir, "half.cym"), filepath.Join(dir, "main.cym")
+
+	var s strings.Builder
+	err := run([]string{"run", half, main}, nil, &s)
+	want := half + ":1:28: division by zero\n\tin half called at " + main + ":1:37"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+	if s.String() != "1\n" {
+		t.Errorf("got output %q", s.String())
+	}
+
+	err = run([]string{"check", half, main, filepath.Join(dir, "dup.cym")}, nil, &s)
+	want = half + ":1:5: half redeclared, first declared at " + filepath.Join(dir, "dup.cym") + ":1:5"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestRenderedErrors(t *testing.T) {
+	err := run([]string{"check"}, strings.NewReader("int x;\nboolean b = x;\nint y = z;\n"), &strings.Builder{})
+	var s strings.Builder
+	if err := cymbol.Render(&s, err, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `2:13: cannot use x (int) as boolean value in initialization
+1 | int x;
+2 | boolean b = x;
+  |             ^
+3 | int y = z;
+3:9: undefined: z
+2 | boolean b = x;
+3 | int y = z;
+  |         ^
+`
+	if s.String() != want {
+		t.Error(cmp.Diff(want, s.String()))
+	}
+}
+
+func TestCheckSARIF(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.cym"), filepath.Join(dir, "b.cym")
+	for name, src := range map[string]string{a: "int f() { return 1; }\n", b: "int g() { return y; }\n"} {
+		if err := os.WriteFile(name, []byte(src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	type result struct {
+		RuleID    string
+		Message   struct{ Text string }
+		Locations []struct {
+			PhysicalLocation struct {
+				ArtifactLocation struct{ URI string }
+				Region           struct{ StartLine, StartColumn, EndLine, EndColumn int }
+			}
+		}
+	}
+	cases := []struct {
+		name string
+		args []string
+		in   string
+		want string // rule, message, uri:line:col-line:col of each result
+	}{
+		{"stdin", []string{"check", "-sarif"}, "int x = y;", "semantic undefined: y stdin:1:9-1:10\n"},
+		{"syntax error", []string{"check", "-sarif", "-"}, "int x", "syntax syntax error: expecting Semicolon, found EOF stdin:1:6-1:7\n"},
+		{"files", []string{"check", "-sarif", a, b}, "", "semantic undefined: y " + filepath.ToSlash(b) + ":1:18-1:19\n"},
+		{"no errors", []string{"check", "-sarif", a}, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var s strings.Builder
+			if err := run(tc.args, strings.NewReader(tc.in), &s); err != nil {
+				t.Fatal(err)
+			}
+			var log struct {
+				Version string
+				Runs    []struct{ Results []result }
+			}
+			if err := json.Unmarshal([]byte(s.String()), &log); err != nil {
+				t.Fatal(err)
+			}
+			if log.Version != "2.1.0" || len(log.Runs) != 1 {
+				t.Fatalf("got version %s and %d runs, want 2.1.0 and 1", log.Version, len(log.Runs))
+			}
+			var got strings.Builder
+			for _, r := range log.Runs[0].Results {
+				l := r.Locations[0].PhysicalLocation
+				fmt.Fprintf(&got, "%s %s %s:%d:%d-%d:%d\n", r.RuleID, r.Message.Text, l.ArtifactLocation.URI,
+					l.Region.StartLine, l.Region.StartColumn, l.Region.EndLine, l.Region.EndColumn)
+			}
+			if got.String() != tc.want {
+				t.Error(cmp.Diff(tc.want, got.String()))
+			}
+		})
+	}
+}
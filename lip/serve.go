@@ -0,0 +1,120 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/cymbol"
+	"example.com/lip/analysis"
+)
+
+// The playground
+//
+// lip serve is a web server for trying the patterns in a browser: its page
+// sends the program being typed to /api/analyze, which answers with all the
+// steps up to checking it at once, as the JSON of an analysis.Analysis. The
+// page draws the tree, the DOT graph is there to render it with Graphviz.
+// As the cursor moves, the page sends the program to /api/info?pos=line:col
+// too, which answers with the analysis.Info of the name at the cursor, or
+// null.
+// A client that accepts application/x-protobuf gets the analysis as the
+// protocol buffer of analysis/analysis.proto instead.
+//
+// With -wasm the directory given is served under /wasm/, and if it has the
+// lip.wasm built from ./wasm and the wasm_exec.js that goes with it, the page
+// loads them and analyzes programs in the browser instead.
+
+//go:embed playground.html
+var playgroundPage []byte
+
+// maxProgram is the size of the largest program the playground analyzes.
+const maxProgram = 1 << 20
+
+// protobufType is the media type of analyses as protocol buffers.
+const protobufType = "application/x-protobuf"
+
+// playground returns the handler of the page and of the analysis, and of the
+// files in wasmDir if it isn't empty.
+func playground(wasmDir string) http.Handler {
+	mux := http.NewServeMux()
+	if wasmDir != "" {
+		mux.Handle("GET /wasm/", http.StripPrefix("/wasm/", http.FileServer(http.Dir(wasmDir))))
+	}
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(playgroundPage)
+	})
+	mux.HandleFunc("POST /api/analyze", func(w http.ResponseWriter, r *http.Request) {
+		src, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProgram))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		a := analysis.Analyze(string(src))
+		if strings.Contains(r.Header.Get("Accept"), protobufType) {
+			w.Header().Set("Content-Type", protobufType)
+			w.Write(a.MarshalProto())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a)
+	})
+	mux.HandleFunc("POST /api/info", func(w http.ResponseWriter, r *http.Request) {
+		pos, err := parsePos(r.URL.Query().Get("pos"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		src, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProgram))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		info, err := analysis.ToolInfo(string(src), pos)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+	return mux
+}
+
+// parsePos parses a position written line:col.
+func parsePos(s string) (cymbol.Pos, error) {
+	var p cymbol.Pos
+	line, col, ok := strings.Cut(s, ":")
+	var err error
+	if ok {
+		p.Line, err = strconv.Atoi(line)
+		if err == nil {
+			p.Col, err = strconv.Atoi(col)
+		}
+	}
+	if !ok || err != nil || p.Line < 1 || p.Col < 1 {
+		return p, fmt.Errorf("invalid position %q, want line:col", s)
+	}
+	return p, nil
+}
+
+// serveCmd serves the playground until it's interrupted.
+func serveCmd(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:8080", "the address to listen on")
+	wasmDir := fs.String("wasm", "", "the `directory` of lip.wasm and wasm_exec.js, to analyze in the browser")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("serve: no arguments expected, got %s", strings.Join(fs.Args(), " "))
+	}
+	fmt.Fprintf(out, "serving the playground on http://%s\n", *addr)
+	return http.ListenAndServe(*addr, playground(*wasmDir))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/lip/analysis"
+)
+
+func TestPlayground(t *testing.T) {
+	srv := httptest.NewServer(playground(""))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("page: got %s, %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Post(srv.URL+"/api/analyze", "text/plain", strings.NewReader("int x;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var a analysis.Analysis
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Tokens) != 3 || a.AST == nil || len(a.Diagnostics) != 0 {
+		t.Errorf("analysis: got %+v", a)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/api/analyze", strings.NewReader("int x;"))
+	req.Header.Set("Accept", "application/x-protobuf")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Errorf("protobuf analysis: got Content-Type %q", ct)
+	}
+	if err := a.UnmarshalProto(b); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Tokens) != 3 || a.AST == nil || len(a.Diagnostics) != 0 {
+		t.Errorf("protobuf analysis: got %+v", a)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/analyze")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET analysis: got %s", resp.Status)
+	}
+}
+
+func TestPlaygroundInfo(t *testing.T) {
+	srv := httptest.NewServer(playground(""))
+	defer srv.Close()
+
+	cases := []struct {
+		pos, src string
+		status   int
+		want     string
+	}{
+		{
+			pos:    "2:13",
+			src:    "int x;\nint y = 1 + x;",
+			status: http.StatusOK,
+			want:   `{"name":"x","kind":"variable","type":"int","declaration":"int x","span":{"from":"2:13","to":"2:14"},"definition":{"from":"1:5","to":"1:6"}}`,
+		},
+		{pos: "1:1", src: "int x;", status: http.StatusOK, want: `{"name":"int","kind":"type","type":"int","declaration":"int (built-in type)","span":{"from":"1:1","to":"1:4"},"definition":null}`},
+		{pos: "1:4", src: "int x;", status: http.StatusOK, want: "null"},
+		{pos: "1:1", src: "int x", status: http.StatusUnprocessableEntity, want: "1:6: syntax error: expecting Semicolon, found EOF"},
+		{pos: "1", src: "int x;", status: http.StatusBadRequest, want: `invalid position "1", want line:col`},
+		{pos: "0:1", src: "int x;", status: http.StatusBadRequest, want: `invalid position "0:1", want line:col`},
+	}
+	for _, tc := range cases {
+		resp, err := http.Post(srv.URL+"/api/info?pos="+tc.pos, "text/plain", strings.NewReader(tc.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status || strings.TrimSpace(string(b)) != tc.want {
+			t.Errorf("%s at %s: got %s %s, want %d %s", tc.src, tc.pos, resp.Status, b, tc.status, tc.want)
+		}
+	}
+}
+
+func TestPlaygroundWasm(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "wasm_exec.js"), []byte("// Go"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(playground(dir))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/wasm/wasm_exec.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "// Go" {
+		t.Errorf("got %s %q", resp.Status, b)
+	}
+}
@@ -0,0 +1,88 @@
+//go:build js && wasm
+
+// Command wasm is the lexer, parser and formatter of Cymbol compiled to
+// WebAssembly, for JavaScript to call: the playground runs them in the
+// browser with it, and pages can embed them. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o lip.wasm ./wasm
+//
+// and load it with the wasm_exec.js of the Go distribution, from
+// $(go env GOROOT)/lib/wasm. It defines a global object cymbol whose
+// functions take the source of a program and return plain objects, shaped
+// as the JSON of package analysis:
+//
+//	cymbol.tokenize(src)  {tokens: [{pos, type, text}, ...], error}
+//	cymbol.parse(src)     {ast: {kind, label, pos, children}, error}
+//	cymbol.format(src)    {source, error}
+//	cymbol.analyze(src)   {tokens, ast, dot, diagnostics}
+//	cymbol.info(src, line, col)
+//	                      {info: {name, kind, type, declaration, span, definition}, error}
+//
+// error is only there if there's one, the lexer or syntax error, and info is
+// null if there's no name at the position. The program
+// then keeps running, for the functions to be called.
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"example.com/cymbol"
+	"example.com/lip/analysis"
+)
+
+func main() {
+	js.Global().Set("cymbol", js.ValueOf(map[string]any{
+		"tokenize": export(func(src string) any {
+			toks, err := analysis.Tokenize(src)
+			return withError(map[string]any{"tokens": toks}, err)
+		}),
+		"parse": export(func(src string) any {
+			tree, err := analysis.Parse(src)
+			return withError(map[string]any{"ast": tree}, err)
+		}),
+		"format": export(func(src string) any {
+			formatted, err := cymbol.Format(src)
+			return withError(map[string]any{"source": formatted}, err)
+		}),
+		"analyze": export(func(src string) any {
+			return analysis.Analyze(src)
+		}),
+		"info": js.FuncOf(func(this js.Value, args []js.Value) any {
+			if len(args) != 3 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeNumber || args[2].Type() != js.TypeNumber {
+				return toJS(map[string]any{"error": "expecting the source of a program, a line and a column"})
+			}
+			info, err := analysis.ToolInfo(args[0].String(), cymbol.Pos{Line: args[1].Int(), Col: args[2].Int()})
+			return toJS(withError(map[string]any{"info": info}, err))
+		}),
+	}))
+	select {}
+}
+
+// export makes f a function for JavaScript, taking the source as its only
+// argument. What f returns is converted to JavaScript through JSON.
+func export(f func(src string) any) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) != 1 || args[0].Type() != js.TypeString {
+			return toJS(map[string]any{"error": "expecting the source of a program"})
+		}
+		return toJS(f(args[0].String()))
+	})
+}
+
+// withError adds err to result, if there's one.
+func withError(result map[string]any, err error) map[string]any {
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	return result
+}
+
+// toJS converts v to a JavaScript value the way JSON.parse would.
+func toJS(v any) js.Value {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return js.ValueOf(map[string]any{"error": err.Error()})
+	}
+	return js.Global().Get("JSON").Call("parse", string(b))
+}
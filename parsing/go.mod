@@ -0,0 +1,5 @@
+module example.com/parsing
+
+go 1.23.4
+
+require github.com/google/go-cmp v0.6.0
@@ -0,0 +1,238 @@
+// Package parsing is the machinery the recursive-descent parsers of the
+// chapters share: a lookahead buffer of tokens, marking and releasing
+// positions in it to speculate, and matching tokens by type. A chapter's
+// parser embeds a Parser and writes its rules, one method per rule, on top.
+package parsing
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// The lookahead buffer
+//
+// An LL(1) parser decides by looking at the next token, an LL(k) one at the
+// next k, and a backtracking parser at as many as it takes to speculate an
+// alternative. All three are a buffer of the tokens read from the lexer and
+// not consumed yet, which Peek fills as far as it's asked to look: with a
+// lookahead of k it holds at most k tokens, and while speculating it holds
+// all the tokens since the first mark, so that Release can go back to it.
+//
+// Once the parser isn't speculating, there's nothing to go back to: Consume
+// drops the tokens consumed from the buffer, which only holds the ones looked
+// at ahead. Index numbers the tokens from the start of the input rather than
+// from the start of the buffer, for a parser to remember them by, as a
+// memoizing parser does.
+//
+// The end of the input is sticky. Once the lexer returns a token of the zero
+// type, EOF, the parser doesn't read from it again: the EOF token is the last
+// in the buffer, Peek returns it for any token past it, and Consume leaves
+// it where it is, so it's the next token for good. A lexer error that comes
+// with the zero token, as the chapters' lexers return, ends the input too,
+// whether Fail returns or panics.
+//
+// These are the invariants of the buffer, which Peek, Consume, Release and
+// Seek keep, and TestInvariants checks:
+//
+//   - buf[i] is the token at index offset+i of the input, and the next token
+//     is buf[pos], 0 <= pos <= len(buf)
+//   - the marks are positions in buf, in order, none past pos
+//   - not speculating, pos is 0: buf only has tokens not consumed yet, at
+//     most k of them with a lookahead of k
+//   - once eof, buf ends with the EOF token, the only one of its type, and
+//     pos is before it
+//
+// So Peek(n) is the token n-1 after the current position, never one before
+// it nor one the parser already went past.
+
+// Goroutines
+//
+// A Parser is the state of a single parse, in a single goroutine: its buffer,
+// its marks, and whatever the parser embedding it keeps. Parsing two inputs
+// at once takes two parsers, and the entry points of the parsers built on
+// this one, such as chapter 3's Parse(src), make one for each call. A parser
+// calls Begin before parsing, which fails with ErrReused if the parser
+// already parsed or is parsing in another goroutine, rather than mixing up
+// the state of two parses.
+
+// SyntaxError is what the errors of Match wrap.
+var SyntaxError = errors.New("syntax error")
+
+// ErrReused is the error of Begin for a parser that already began.
+var ErrReused = errors.New("parsing: the parser already parsed, it's good for a single parse")
+
+// Lexer is where a parser gets its tokens from.
+type Lexer[T any] interface {
+	Next() (T, error)
+}
+
+// Token is what a parser needs of its tokens: their type, K, to match them.
+// The zero K is the type of the token at the end of the input, EOF.
+type Token[K comparable] interface {
+	Kind() K
+}
+
+// Parser is the state of a parser, its tokens T being of types K.
+type Parser[T Token[K], K comparable] struct {
+	input   Lexer[T]
+	k       int   // how far Peek can look, 0 for as far as it takes
+	buf     []T   // tokens read and not consumed, or marked
+	offset  int   // index of buf[0] in the input
+	pos     int   // of the next token in buf
+	markers []int // positions in buf to release, the last one first
+	eof     bool  // the lexer returned EOF, the last token of buf
+	begun   atomic.Bool
+
+	// Mismatch returns the error of Match when the next token isn't of the
+	// type expected. It's nil for "syntax error: expecting want, got type".
+	Mismatch func(want K, got T) error
+
+	// Fail is called with the errors of the lexer, and of Match. It panics
+	// to stop the parse, which is what it does if it's nil, or records the
+	// error and returns to go on. The parser then goes on with the token the
+	// lexer returned along with its error, and Match with the mismatched
+	// token unconsumed.
+	Fail func(err error)
+
+	// Consumed is called with each token consumed, but not while
+	// speculating: the tokens consumed speculating are consumed again.
+	Consumed func(tok T)
+}
+
+// New returns a parser reading input, looking at most k tokens ahead, or as
+// far as it takes if k is 0.
+func New[T Token[K], K comparable](input Lexer[T], k int) *Parser[T, K] {
+	return &Parser[T, K]{input: input, k: k}
+}
+
+// Begin claims the parser for a parse, failing with ErrReused if it already
+// began one.
+func (p *Parser[T, K]) Begin() error {
+	if !p.begun.CompareAndSwap(false, true) {
+		return ErrReused
+	}
+	return nil
+}
+
+// Peek returns the nth next token, the next one being 1. Past the lookahead
+// of the parser it's the zero token: the parser can't see that far. Past
+// the end of the input it's the EOF token.
+func (p *Parser[T, K]) Peek(n int) T {
+	if n < 1 {
+		panic(fmt.Sprintf("parsing: peek %d, the next token is 1", n))
+	}
+	if p.k > 0 && n > p.k {
+		var zero T
+		return zero
+	}
+	for p.pos+n > len(p.buf) {
+		if p.eof {
+			return p.buf[len(p.buf)-1]
+		}
+		tok, err := p.input.Next()
+		p.buf = append(p.buf, tok)
+		var zero K
+		p.eof = tok.Kind() == zero
+		if err != nil {
+			// the token is buffered first, for the input to have ended
+			// even if Fail panics and the caller recovers
+			p.fail(err)
+		}
+	}
+	return p.buf[p.pos+n-1]
+}
+
+// Consume goes past the next token, unless it's EOF, which stays the next
+// token.
+func (p *Parser[T, K]) Consume() {
+	tok := p.Peek(1)
+	if p.eof && p.pos == len(p.buf)-1 {
+		return
+	}
+	p.pos++
+	if p.Speculating() {
+		return
+	}
+	if p.Consumed != nil {
+		p.Consumed(tok)
+	}
+	p.drop()
+}
+
+// drop drops the tokens consumed from the buffer, there being nothing to go
+// back to them once the parser isn't speculating.
+func (p *Parser[T, K]) drop() {
+	n := copy(p.buf, p.buf[p.pos:])
+	clear(p.buf[n:])
+	p.buf = p.buf[:n]
+	p.offset += p.pos
+	p.pos = 0
+}
+
+// Match consumes the next token and returns it if it's of type want, and
+// fails with a syntax error if it isn't.
+func (p *Parser[T, K]) Match(want K) T {
+	tok := p.Peek(1)
+	if tok.Kind() != want {
+		if p.Mismatch != nil {
+			p.fail(p.Mismatch(want, tok))
+		} else {
+			p.fail(fmt.Errorf("%w: expecting %v, got %v", SyntaxError, want, tok.Kind()))
+		}
+		return tok
+	}
+	p.Consume()
+	return tok
+}
+
+func (p *Parser[T, K]) fail(err error) {
+	if p.Fail == nil {
+		panic(err)
+	}
+	p.Fail(err)
+}
+
+// Mark remembers the current position, to speculate from it.
+func (p *Parser[T, K]) Mark() {
+	p.markers = append(p.markers, p.pos)
+}
+
+// Release goes back to the position of the last Mark, and forgets it.
+func (p *Parser[T, K]) Release() {
+	p.pos = p.markers[len(p.markers)-1]
+	p.markers = p.markers[:len(p.markers)-1]
+}
+
+// Speculating tells whether there's a Mark to release.
+func (p *Parser[T, K]) Speculating() bool {
+	return len(p.markers) > 0
+}
+
+// Index returns the index of the next token in the input, the first token
+// being 0.
+func (p *Parser[T, K]) Index() int {
+	return p.offset + p.pos
+}
+
+// Seek goes to the token at index i of the input, which has to be one of the
+// tokens in the buffer, or the one after them if they don't end with EOF.
+// Speculating, it can't go back before the last mark, Release does. Not
+// speculating, the tokens it goes past are dropped as if consumed, without
+// calling Consumed.
+func (p *Parser[T, K]) Seek(i int) {
+	first, last := p.offset, p.offset+len(p.buf)
+	if p.Speculating() {
+		first += p.markers[len(p.markers)-1]
+	}
+	if p.eof {
+		last--
+	}
+	if i < first || i > last {
+		panic(fmt.Sprintf("parsing: seek to %d, outside the tokens of %d to %d", i, first, last))
+	}
+	p.pos = i - p.offset
+	if !p.Speculating() {
+		p.drop()
+	}
+}
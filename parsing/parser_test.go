@@ -0,0 +1,368 @@
+package parsing
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// token is a letter of the input, 0 at the end.
+type token byte
+
+func (t token) Kind() byte { return byte(t) }
+
+// letters is a lexer of one token per letter of s, and an error for '#'.
+type letters struct {
+	s string
+	i int
+}
+
+func (l *letters) Next() (token, error) {
+	if l.i == len(l.s) {
+		return 0, nil
+	}
+	c := l.s[l.i]
+	l.i++
+	if c == '#' {
+		return 0, fmt.Errorf("invalid character at %d", l.i-1)
+	}
+	return token(c), nil
+}
+
+func newParser(s string, k int) *Parser[token, byte] {
+	return New[token, byte](&letters{s: s}, k)
+}
+
+// peeks returns the next n tokens as a string, 0 as '$'.
+func peeks(p *Parser[token, byte], n int) string {
+	var s []byte
+	for i := 1; i <= n; i++ {
+		if c := p.Peek(i); c != 0 {
+			s = append(s, byte(c))
+		} else {
+			s = append(s, '$')
+		}
+	}
+	return string(s)
+}
+
+func TestPeek(t *testing.T) {
+	cases := []struct {
+		name    string
+		k       int
+		consume int
+		want    string
+		index   int
+	}{
+		{name: "as far as it takes", want: "abcd$$"},
+		{name: "k", k: 2, want: "ab$$$$"},
+		{name: "after consuming", k: 3, consume: 2, want: "cd$$$$", index: 2},
+		{name: "up to the end", consume: 4, want: "$$$$$$", index: 4},
+		// EOF stays the next token
+		{name: "past the end", consume: 6, want: "$$$$$$", index: 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newParser("abcd", tc.k)
+			for range tc.consume {
+				p.Consume()
+			}
+			if got := peeks(p, 6); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+			if p.Index() != tc.index {
+				t.Errorf("index: want: %d, got: %d", tc.index, p.Index())
+			}
+		})
+	}
+}
+
+func TestSpeculate(t *testing.T) {
+	p := newParser("abcde", 1)
+	var consumed []byte
+	p.Consumed = func(tok token) { consumed = append(consumed, byte(tok)) }
+
+	p.Consume()
+	p.Mark()
+	p.Match('b')
+	p.Mark()
+	p.Match('c')
+	p.Match('d')
+	if !p.Speculating() || p.Index() != 4 {
+		t.Fatalf("speculating %v at %d", p.Speculating(), p.Index())
+	}
+	p.Release()
+	if got := peeks(p, 1); got != "c" {
+		t.Errorf("after the inner release: want: c, got: %s", got)
+	}
+	p.Release()
+	if p.Speculating() || p.Index() != 1 {
+		t.Fatalf("speculating %v at %d", p.Speculating(), p.Index())
+	}
+	// the tokens speculated are still there to seek to
+	p.Seek(4)
+	if got := peeks(p, 1); got != "e" {
+		t.Errorf("after seeking: want: e, got: %s", got)
+	}
+	p.Consume()
+	p.Consume() // EOF, which isn't consumed
+	if string(consumed) != "ae" {
+		t.Errorf("consumed: want: %q, got: %q", "ae", consumed)
+	}
+	if p.Index() != 5 {
+		t.Errorf("index: want: 5, got: %d", p.Index())
+	}
+}
+
+func TestOutOfRange(t *testing.T) {
+	cases := []struct {
+		name string
+		do   func(p *Parser[token, byte])
+	}{
+		{name: "seek before the buffer", do: func(p *Parser[token, byte]) {
+			p.Consume()
+			p.Consume()
+			p.Seek(1)
+		}},
+		{name: "seek before the mark", do: func(p *Parser[token, byte]) {
+			p.Mark()
+			p.Consume()
+			p.Mark()
+			p.Seek(0)
+		}},
+		{name: "seek past EOF", do: func(p *Parser[token, byte]) {
+			p.Mark()
+			p.Peek(5)
+			p.Seek(4)
+		}},
+		{name: "peek behind", do: func(p *Parser[token, byte]) {
+			p.Consume()
+			p.Peek(0)
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("want: panic, got: none")
+				}
+			}()
+			tc.do(newParser("abc", 0))
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("mismatch", func(t *testing.T) {
+		p := newParser("ab", 0)
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, SyntaxError) || err.Error() != "syntax error: expecting 98, got 97" {
+				t.Errorf("want: syntax error, got: %v", err)
+			}
+		}()
+		p.Match('b')
+	})
+
+	t.Run("going on", func(t *testing.T) {
+		p := newParser("a#b", 0)
+		var errs []string
+		p.Fail = func(err error) { errs = append(errs, err.Error()) }
+		p.Mismatch = func(want byte, got token) error {
+			return fmt.Errorf("want %c, got %c", want, got)
+		}
+		var got []byte
+		for _, want := range []byte("aab") {
+			got = append(got, byte(p.Match(want)))
+		}
+		want := []string{"invalid character at 1", "want a, got \x00", "want b, got \x00"}
+		if diff := cmp.Diff(want, errs); diff != "" {
+			t.Errorf("errors mismatch (-want +got):\n%s", diff)
+		}
+		// the token of the lexer error is there to match, and left there
+		if string(got) != "a\x00\x00" || p.Index() != 1 {
+			t.Errorf("matched %q, at %d", got, p.Index())
+		}
+	})
+}
+
+func TestBegin(t *testing.T) {
+	p := newParser("a", 0)
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = p.Begin()
+		}()
+	}
+	wg.Wait()
+	began := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			began++
+		case !errors.Is(err, ErrReused):
+			t.Errorf("want: ErrReused, got: %v", err)
+		}
+	}
+	if began != 1 {
+		t.Errorf("want: a single parse, got: %d", began)
+	}
+}
+
+// ended is a lexer of letters that fails the test if it's read from after
+// the end of the input, or after an error.
+type ended struct {
+	letters
+	t   *testing.T
+	eof bool
+}
+
+func (l *ended) Next() (token, error) {
+	if l.eof {
+		l.t.Fatal("lexer read from after the end")
+	}
+	tok, err := l.letters.Next()
+	l.eof = tok == 0
+	return tok, err
+}
+
+// invariants checks the invariants of the buffer, see parser.go.
+func invariants(p *Parser[token, byte]) error {
+	if p.pos < 0 || p.pos > len(p.buf) {
+		return fmt.Errorf("position %d outside the buffer of %d", p.pos, len(p.buf))
+	}
+	for i, m := range p.markers {
+		if m > p.pos || i > 0 && m < p.markers[i-1] {
+			return fmt.Errorf("marks %v at %d", p.markers, p.pos)
+		}
+	}
+	if !p.Speculating() {
+		if p.pos != 0 {
+			return fmt.Errorf("not speculating at %d", p.pos)
+		}
+		if p.k > 0 && len(p.buf) > p.k {
+			return fmt.Errorf("%d tokens buffered with a lookahead of %d", len(p.buf), p.k)
+		}
+	}
+	for i, tok := range p.buf {
+		if tok == 0 && (!p.eof || i != len(p.buf)-1) {
+			return fmt.Errorf("EOF at %d of %d, eof %v", i, len(p.buf), p.eof)
+		}
+	}
+	if p.eof && p.pos == len(p.buf) {
+		return fmt.Errorf("past EOF at %d", p.pos)
+	}
+	return nil
+}
+
+// TestInvariants has parsers peek, consume, match, speculate and seek at
+// random, checking the invariants after each step, and that Peek(n) is the
+// token n-1 after the current position: never one before it, nor past EOF.
+func TestInvariants(t *testing.T) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz"
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for run := range 500 {
+		// distinct letters tell where each token is, and a '#' is a lexer
+		// error which ends the input
+		input := []byte(alphabet[:rnd.IntN(len(alphabet))])
+		if len(input) > 0 && rnd.IntN(4) == 0 {
+			input[rnd.IntN(len(input))] = '#'
+		}
+		want := []token{}
+		for _, c := range input {
+			if c == '#' {
+				break
+			}
+			want = append(want, token(c))
+		}
+		want = append(want, 0)
+		at := func(i int) token { return want[min(i, len(want)-1)] }
+
+		k := []int{0, 1, 2, 3}[rnd.IntN(4)]
+		p := New[token, byte](&ended{letters: letters{s: string(input)}, t: t}, k)
+		// the lexer error either goes on, or panics for the caller to
+		// recover from and go on, as chapter 3's speculations do
+		panics := rnd.IntN(2) == 0
+		if !panics {
+			p.Fail = func(error) {}
+		}
+		var steps []string
+		fail := func(format string, args ...any) {
+			t.Helper()
+			t.Fatalf("run %d, %q, k %d, panics %v, after %v: %s", run, input, k, panics, steps, fmt.Sprintf(format, args...))
+		}
+		step := func(f func()) {
+			defer func() {
+				if r := recover(); r != nil && !panics {
+					fail("panicked: %v", r)
+				}
+			}()
+			f()
+		}
+
+		i := 0 // the index of the next token
+		var marks []int
+		for range 100 {
+			step(func() {
+				switch op := rnd.IntN(6); {
+				case op == 0:
+					n := 1 + rnd.IntN(6)
+					steps = append(steps, fmt.Sprintf("peek %d", n))
+					w := at(i + n - 1)
+					if k > 0 && n > k {
+						w = 0
+					}
+					if got := p.Peek(n); got != w {
+						fail("peek %d: want: %q, got: %q", n, w, got)
+					}
+				case op == 1:
+					steps = append(steps, "consume")
+					p.Consume()
+					if at(i) != 0 {
+						i++
+					}
+				case op == 2:
+					steps = append(steps, "match")
+					if got := p.Match(byte(at(i))); got != at(i) {
+						fail("match: want: %q, got: %q", at(i), got)
+					}
+					if at(i) != 0 {
+						i++
+					}
+				case op == 3 && k == 0:
+					steps = append(steps, "mark")
+					p.Mark()
+					marks = append(marks, i)
+				case op == 4 && len(marks) > 0:
+					steps = append(steps, "release")
+					p.Release()
+					i, marks = marks[len(marks)-1], marks[:len(marks)-1]
+				case op == 5 && len(marks) > 0:
+					// back to the last mark, or ahead as far as the buffer goes
+					last := p.offset + len(p.buf)
+					if p.eof {
+						last--
+					}
+					from := marks[len(marks)-1]
+					to := from + rnd.IntN(last-from+1)
+					steps = append(steps, fmt.Sprintf("seek %d", to))
+					p.Seek(to)
+					i = to
+				}
+			})
+			if err := invariants(p); err != nil {
+				fail("%v", err)
+			}
+			if p.Index() != i {
+				fail("index: want: %d, got: %d", i, p.Index())
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package vecmath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Abstract Syntax Tree
+//
+// One type per construct. Operators are all binary and share BinaryExpr, the
+// operator token tells them apart. Each node's String renders it back to
+// source text, with expressions fully parenthesized so the shape of the tree
+// is visible: `1 + 2 * v` is rendered as `(1 + (2 * v))`.
+
+// Node is implemented by every node in the tree. Pos is the position of the
+// node's first token.
+type Node interface {
+	Pos() Pos
+	String() string
+}
+
+// Stmt is a statement: an assignment or a print.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an expression, something that has a scalar or vector value.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Program is the root of the tree, holding every statement in order.
+type Program struct {
+	Stats []Stmt
+}
+
+type (
+	// AssignStmt is `Name = Value`.
+	AssignStmt struct {
+		Name  *Ident
+		Value Expr
+	}
+
+	// PrintStmt is `print Value`.
+	PrintStmt struct {
+		Print Pos
+		Value Expr
+	}
+)
+
+type (
+	// Ident is a variable name.
+	Ident struct {
+		Token Token
+		Name  string
+	}
+
+	// IntLit is an integer scalar such as `3`.
+	IntLit struct {
+		Token Token
+		Value int64
+	}
+
+	// FloatLit is a floating point scalar such as `3.5`.
+	FloatLit struct {
+		Token Token
+		Value float64
+	}
+
+	// VectorLit is a vector such as `[1, x, 2 * y]`.
+	VectorLit struct {
+		Lbrack Pos
+		Elems  []Expr
+	}
+
+	// BinaryExpr is `X Op Y` where Op is one of `+`, `*` or `.`.
+	BinaryExpr struct {
+		X  Expr
+		Op Token
+		Y  Expr
+	}
+)
+
+func (p *Program) Pos() Pos {
+	if len(p.Stats) == 0 {
+		return Pos{Line: 1, Col: 1}
+	}
+	return p.Stats[0].Pos()
+}
+
+func (s *AssignStmt) Pos() Pos { return s.Name.Pos() }
+func (s *PrintStmt) Pos() Pos  { return s.Print }
+func (x *Ident) Pos() Pos      { return x.Token.Pos }
+func (x *IntLit) Pos() Pos     { return x.Token.Pos }
+func (x *FloatLit) Pos() Pos   { return x.Token.Pos }
+func (x *VectorLit) Pos() Pos  { return x.Lbrack }
+func (x *BinaryExpr) Pos() Pos { return x.X.Pos() }
+
+func (*AssignStmt) stmtNode() {}
+func (*PrintStmt) stmtNode()  {}
+
+func (*Ident) exprNode()      {}
+func (*IntLit) exprNode()     {}
+func (*FloatLit) exprNode()   {}
+func (*VectorLit) exprNode()  {}
+func (*BinaryExpr) exprNode() {}
+
+func (p *Program) String() string {
+	stats := make([]string, len(p.Stats))
+	for i, s := range p.Stats {
+		stats[i] = s.String()
+	}
+	return strings.Join(stats, "\n")
+}
+
+func (s *AssignStmt) String() string {
+	return fmt.Sprintf("%v = %v", s.Name, s.Value)
+}
+
+func (s *PrintStmt) String() string {
+	return fmt.Sprintf("print %v", s.Value)
+}
+
+func (x *Ident) String() string    { return x.Name }
+func (x *IntLit) String() string   { return x.Token.Text }
+func (x *FloatLit) String() string { return x.Token.Text }
+
+func (x *VectorLit) String() string {
+	elems := make([]string, len(x.Elems))
+	for i, e := range x.Elems {
+		elems[i] = e.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+func (x *BinaryExpr) String() string {
+	return fmt.Sprintf("(%v %s %v)", x.X, x.Op.Text, x.Y)
+}
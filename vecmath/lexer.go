@@ -0,0 +1,235 @@
+package vecmath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VecMath is the book's small language of vector math, the example used for
+// building ASTs and later for rewriting trees and promoting types:
+//
+//	x = 3
+//	v = [1, 2, x * 4]
+//	print v * 2 + [0.5, 0, 0]
+//	print v . v
+//
+// Scalars are ints or floats and vectors are lists of scalars. `*` multiplies
+// (scalar*scalar, scalar*vector or vector*scalar) and `.` is the dot product of
+// two vectors.
+
+type Token struct {
+	Type TokenType
+	Text string
+	Pos  Pos
+}
+
+// Pos is a position in the input, both line and column start at 1.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+type TokenType int
+
+// Token types
+const (
+	EOF TokenType = iota
+	ID
+	Int
+	Float
+	Print
+	Assign
+	Plus
+	Star
+	Dot
+	Comma
+	LBrack
+	RBrack
+	LParen
+	RParen
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case EOF:
+		return "EOF"
+	case ID:
+		return "ID"
+	case Int:
+		return "Int"
+	case Float:
+		return "Float"
+	case Print:
+		return "Print"
+	case Assign:
+		return "Assign"
+	case Plus:
+		return "Plus"
+	case Star:
+		return "Star"
+	case Dot:
+		return "Dot"
+	case Comma:
+		return "Comma"
+	case LBrack:
+		return "LBrack"
+	case RBrack:
+		return "RBrack"
+	case LParen:
+		return "LParen"
+	case RParen:
+		return "RParen"
+	default:
+		return "Unknown"
+	}
+}
+
+// Lexer goes through the input rune by rune and produces Tokens.
+type Lexer struct {
+	input   []rune // entire input
+	pos     int    // current position index in the input
+	current rune   // current rune
+	line    int    // line of the current rune
+	col     int    // column of the current rune
+}
+
+// marks the end of input
+var eof = rune(-1)
+
+func NewLexer(input string) *Lexer {
+	lex := &Lexer{input: []rune(input), line: 1, col: 1, current: eof}
+	if len(lex.input) > 0 {
+		lex.current = lex.input[0]
+	}
+	return lex
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// Next returns the next Token at each invocation or an error if the input
+// cannot be recognized. Once the input is over it keeps returning EOF.
+func (lex *Lexer) Next() (Token, error) {
+	for lex.current != eof {
+		start := Pos{Line: lex.line, Col: lex.col}
+		switch lex.current {
+		case ' ', '\t', '\n', '\r':
+			lex.consume()
+			continue
+		case '=':
+			return lex.single(Assign), nil
+		case '+':
+			return lex.single(Plus), nil
+		case '*':
+			return lex.single(Star), nil
+		case '.':
+			return lex.single(Dot), nil
+		case ',':
+			return lex.single(Comma), nil
+		case '[':
+			return lex.single(LBrack), nil
+		case ']':
+			return lex.single(RBrack), nil
+		case '(':
+			return lex.single(LParen), nil
+		case ')':
+			return lex.single(RParen), nil
+		default:
+			if isLetter(lex.current) {
+				return lex.ident(), nil
+			}
+			if isDigit(lex.current) {
+				return lex.number(), nil
+			}
+			return Token{}, fmt.Errorf("%v: invalid character: %q", start, lex.current)
+		}
+	}
+	return Token{Type: EOF, Pos: Pos{Line: lex.line, Col: lex.col}}, nil
+}
+
+// single matches a token made of only the current rune
+func (lex *Lexer) single(typ TokenType) Token {
+	tok := Token{Type: typ, Text: string(lex.current), Pos: Pos{Line: lex.line, Col: lex.col}}
+	lex.consume()
+	return tok
+}
+
+// Lexical rule ID, `print` is the only keyword.
+//
+// ID : ('a'..'z'|'A'..'Z')+ ;
+func (lex *Lexer) ident() Token {
+	start := Pos{Line: lex.line, Col: lex.col}
+	var s strings.Builder
+	for isLetter(lex.current) {
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+	if s.String() == "print" {
+		return Token{Type: Print, Text: s.String(), Pos: start}
+	}
+	return Token{Type: ID, Text: s.String(), Pos: start}
+}
+
+// Lexical rules INT and FLOAT. A '.' is also the dot product operator, so it's
+// only part of a number when a digit follows it: `1.5` is a Float but `v.w` and
+// `2.v` are dot products. That's the one place where the lexer needs two runes
+// of lookahead.
+//
+// INT   : ('0'..'9')+ ;
+// FLOAT : ('0'..'9')+ '.' ('0'..'9')+ ;
+func (lex *Lexer) number() Token {
+	start := Pos{Line: lex.line, Col: lex.col}
+	var s strings.Builder
+	lex.digits(&s)
+	if lex.current != '.' || !isDigit(lex.peek()) {
+		return Token{Type: Int, Text: s.String(), Pos: start}
+	}
+	s.WriteRune(lex.current)
+	lex.consume()
+	lex.digits(&s)
+	return Token{Type: Float, Text: s.String(), Pos: start}
+}
+
+func (lex *Lexer) digits(s *strings.Builder) {
+	for isDigit(lex.current) {
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+}
+
+// peek returns the rune after the current one without consuming anything.
+func (lex *Lexer) peek() rune {
+	if lex.pos+1 >= len(lex.input) {
+		return eof
+	}
+	return lex.input[lex.pos+1]
+}
+
+// consume moves the current position forward by one, keeping track of lines
+// and columns, and saves the next current rune.
+func (lex *Lexer) consume() {
+	if lex.current == '\n' {
+		lex.line++
+		lex.col = 1
+	} else {
+		lex.col++
+	}
+
+	lex.pos++
+	if lex.pos >= len(lex.input) {
+		// signals end of input
+		lex.current = eof
+	} else {
+		// saves the next rune
+		lex.current = lex.input[lex.pos]
+	}
+}
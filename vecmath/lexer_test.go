@@ -0,0 +1,86 @@
+package vecmath
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLexer(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []Token
+	}{
+		{
+			name:  "empty input",
+			input: "",
+			want:  []Token{{Type: EOF, Pos: Pos{1, 1}}},
+		},
+		{
+			name:  "assignment",
+			input: "x = [1, 2.5]",
+			want: []Token{
+				{Type: ID, Text: "x", Pos: Pos{1, 1}},
+				{Type: Assign, Text: "=", Pos: Pos{1, 3}},
+				{Type: LBrack, Text: "[", Pos: Pos{1, 5}},
+				{Type: Int, Text: "1", Pos: Pos{1, 6}},
+				{Type: Comma, Text: ",", Pos: Pos{1, 7}},
+				{Type: Float, Text: "2.5", Pos: Pos{1, 9}},
+				{Type: RBrack, Text: "]", Pos: Pos{1, 12}},
+				{Type: EOF, Pos: Pos{1, 13}},
+			},
+		},
+		{
+			name:  "dot product is not a float",
+			input: "print 2.v+(w.u)\n",
+			want: []Token{
+				{Type: Print, Text: "print", Pos: Pos{1, 1}},
+				{Type: Int, Text: "2", Pos: Pos{1, 7}},
+				{Type: Dot, Text: ".", Pos: Pos{1, 8}},
+				{Type: ID, Text: "v", Pos: Pos{1, 9}},
+				{Type: Plus, Text: "+", Pos: Pos{1, 10}},
+				{Type: LParen, Text: "(", Pos: Pos{1, 11}},
+				{Type: ID, Text: "w", Pos: Pos{1, 12}},
+				{Type: Dot, Text: ".", Pos: Pos{1, 13}},
+				{Type: ID, Text: "u", Pos: Pos{1, 14}},
+				{Type: RParen, Text: ")", Pos: Pos{1, 15}},
+				{Type: EOF, Pos: Pos{2, 1}},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer(tc.input)
+			var tokens []Token
+			for {
+				tok, err := l.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tokens = append(tokens, tok)
+				if tok.Type == EOF {
+					break
+				}
+			}
+			if !cmp.Equal(tokens, tc.want) {
+				t.Error(cmp.Diff(tokens, tc.want))
+			}
+		})
+	}
+}
+
+func TestLexerBadInput(t *testing.T) {
+	l := NewLexer("x = 1 - 2")
+	for {
+		tok, err := l.Next()
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		if tok.Type == EOF {
+			t.Fatal("want: error, got: nil")
+		}
+	}
+}
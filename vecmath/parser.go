@@ -0,0 +1,186 @@
+package vecmath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// LL(1) Recursive-Descent Parser for VecMath.
+
+// Grammar to be parsed (ANTLR syntax):
+//
+// grammar VecMath;
+// program : stat* EOF ;
+// stat    : ID '=' expr            // assignment such as x = [1, 2]
+//         | 'print' expr           // print the value of an expression
+//         ;
+// expr    : mult ('+' mult)* ;
+// mult    : primary (('*'|'.') primary)* ;
+// primary : INT | FLOAT | ID
+//         | '[' expr (',' expr)* ']'
+//         | '(' expr ')'
+//         ;
+//
+// There are no statement separators: an expression ends as soon as the next
+// token can't continue it, so in `x = 1 y = 2` the ID `y` starts a new
+// statement. `*` and `.` have the same precedence, higher than `+`.
+
+var SyntaxError = errors.New("syntax error")
+
+// Parser is an LL(1) parser. On the first error it panics, Parse recovers and
+// returns the error.
+type Parser struct {
+	input     *Lexer
+	lookahead Token
+}
+
+func NewParser(l *Lexer) *Parser {
+	p := &Parser{input: l}
+	p.consume()
+	return p
+}
+
+// Parse parses a whole VecMath program.
+func Parse(src string) (prog *Program, err error) {
+	defer bailout(&err)
+	p := NewParser(NewLexer(src))
+	return p.program(), nil
+}
+
+// ParseExpr parses a single expression.
+func ParseExpr(src string) (x Expr, err error) {
+	defer bailout(&err)
+	p := NewParser(NewLexer(src))
+	x = p.expr()
+	p.match(EOF)
+	return x, nil
+}
+
+// bailout recovers from the panic raised on the first error and returns it in
+// err instead. Panics that aren't errors are bugs and keep going.
+func bailout(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(error)
+		if !ok {
+			panic(r)
+		}
+		*err = e
+	}
+}
+
+func (p *Parser) program() *Program {
+	prog := &Program{}
+	for p.lookahead.Type != EOF {
+		prog.Stats = append(prog.Stats, p.stat())
+	}
+	p.match(EOF)
+	return prog
+}
+
+func (p *Parser) stat() Stmt {
+	switch p.lookahead.Type {
+	case ID:
+		s := &AssignStmt{Name: p.ident()}
+		p.match(Assign)
+		s.Value = p.expr()
+		return s
+	case Print:
+		s := &PrintStmt{Print: p.match(Print).Pos}
+		s.Value = p.expr()
+		return s
+	default:
+		panic(p.errorf("expecting assignment or print, found %v", p.lookahead.Type))
+	}
+}
+
+func (p *Parser) expr() Expr {
+	x := p.mult()
+	for p.lookahead.Type == Plus {
+		op := p.match(Plus)
+		x = &BinaryExpr{X: x, Op: op, Y: p.mult()}
+	}
+	return x
+}
+
+func (p *Parser) mult() Expr {
+	x := p.primary()
+	for p.lookahead.Type == Star || p.lookahead.Type == Dot {
+		op := p.match(p.lookahead.Type)
+		x = &BinaryExpr{X: x, Op: op, Y: p.primary()}
+	}
+	return x
+}
+
+func (p *Parser) primary() Expr {
+	tok := p.lookahead
+	switch tok.Type {
+	case ID:
+		return p.ident()
+	case Int:
+		p.match(Int)
+		v, err := strconv.ParseInt(tok.Text, 10, 64)
+		if err != nil {
+			panic(errorAt(tok.Pos, "invalid integer %s: %w", tok.Text, err))
+		}
+		return &IntLit{Token: tok, Value: v}
+	case Float:
+		p.match(Float)
+		v, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			panic(errorAt(tok.Pos, "invalid float %s: %w", tok.Text, err))
+		}
+		return &FloatLit{Token: tok, Value: v}
+	case LBrack:
+		v := &VectorLit{Lbrack: p.match(LBrack).Pos}
+		v.Elems = append(v.Elems, p.expr())
+		for p.lookahead.Type == Comma {
+			p.match(Comma)
+			v.Elems = append(v.Elems, p.expr())
+		}
+		p.match(RBrack)
+		return v
+	case LParen:
+		p.match(LParen)
+		x := p.expr()
+		p.match(RParen)
+		return x
+	default:
+		panic(p.errorf("expecting expression, found %v", tok.Type))
+	}
+}
+
+func (p *Parser) ident() *Ident {
+	tok := p.match(ID)
+	return &Ident{Token: tok, Name: tok.Text}
+}
+
+// match checks if the current lookahead token is of the type we're looking
+// for. Goes to the next token and returns the matched one if it is, panics
+// with a SyntaxError if it isn't.
+func (p *Parser) match(typ TokenType) Token {
+	tok := p.lookahead
+	if tok.Type != typ {
+		panic(p.errorf("expecting %v, found %v", typ, tok.Type))
+	}
+	p.consume()
+	return tok
+}
+
+// errorf returns a SyntaxError at the position of the lookahead token
+func (p *Parser) errorf(format string, args ...any) error {
+	return errorAt(p.lookahead.Pos, format, args...)
+}
+
+// errorAt returns a SyntaxError at pos, wrapping the errors in args.
+func errorAt(pos Pos, format string, args ...any) error {
+	return fmt.Errorf("%v: %w: %w", pos, SyntaxError, fmt.Errorf(format, args...))
+}
+
+func (p *Parser) consume() {
+	tok, err := p.input.Next()
+	if err != nil {
+		panic(err)
+	}
+	p.lookahead = tok
+}
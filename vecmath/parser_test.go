@@ -0,0 +1,92 @@
+package vecmath
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: "", want: ""},
+		{name: "assignment", input: "x = 3", want: "x = 3"},
+		{name: "print", input: "print x", want: "print x"},
+		{name: "no separators", input: "x = 1 y = x print y", want: "x = 1\ny = x\nprint y"},
+		{name: "vector", input: "v = [1, 2.5, x*4]", want: "v = [1, 2.5, (x * 4)]"},
+		{name: "precedence", input: "print 1 + 2 * v . w", want: "print (1 + ((2 * v) . w))"},
+		{name: "left associative", input: "print a + b + c", want: "print ((a + b) + c)"},
+		{name: "parentheses", input: "print (a + b) * c", want: "print ((a + b) * c)"},
+		{name: "nested vectors", input: "print [[1], [2]]", want: "print [[1], [2]]"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prog, err := Parse(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := prog.String(); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseBadInput(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{name: "missing value", input: "x ="},
+		{name: "missing assignment", input: "x 3"},
+		{name: "empty vector", input: "v = []"},
+		{name: "trailing comma", input: "v = [1,]"},
+		{name: "unclosed vector", input: "v = [1, 2"},
+		{name: "unclosed parenthesis", input: "print (1 + 2"},
+		{name: "dangling operator", input: "print 1 +"},
+		{name: "expression statement", input: "1 + 2"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.input)
+			if !errors.Is(err, SyntaxError) {
+				t.Errorf("want syntax error, got: %v", err)
+			}
+			t.Log(err)
+		})
+	}
+}
+
+func TestParseNumberError(t *testing.T) {
+	_, err := Parse("x = 99999999999999999999 + 1")
+	if !errors.Is(err, SyntaxError) || !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("want syntax error wrapping strconv.ErrRange, got: %v", err)
+	}
+	want := "1:5: syntax error: invalid integer 99999999999999999999: " +
+		`strconv.ParseInt: parsing "99999999999999999999": value out of range`
+	if err == nil || err.Error() != want {
+		t.Errorf("want: %q, got: %v", want, err)
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	x, err := ParseExpr("[1, 2] * 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, ok := x.(*BinaryExpr)
+	if !ok {
+		t.Fatalf("want *BinaryExpr, got: %T", x)
+	}
+	if _, ok := bin.X.(*VectorLit); !ok {
+		t.Errorf("want vector on the left, got: %T", bin.X)
+	}
+	if got, want := bin.Y.Pos(), (Pos{Line: 1, Col: 10}); got != want {
+		t.Errorf("want scalar at %v, got: %v", want, got)
+	}
+}
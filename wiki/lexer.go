@@ -0,0 +1,176 @@
+package wiki
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A small wiki markup language:
+//
+//	Paragraphs are lines of text, *bold* and _italic_ can be
+//	nested like *this _one_*, links are [text|http://example.com]
+//	or just [http://example.com].
+//
+//	* a blank line ends a paragraph
+//	* lines starting with "* " are list items
+//
+// Special characters can be escaped with a backslash: \* \_ \[ \] \\
+//
+// The lexer splits the input into runs of plain text and the markup
+// characters around them. Whether a '*' is a bullet or the start of bold text
+// depends on where it is, so that's decided here: a "* " at the start of a line
+// is a Bullet, any other '*' is a Star.
+
+type Token struct {
+	Type TokenType
+	Text string
+	Line int
+}
+
+type TokenType int
+
+// Token types
+const (
+	EOF TokenType = iota
+	Text
+	Newline
+	Bullet
+	Star
+	Underscore
+	LBrack
+	RBrack
+	Pipe
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case EOF:
+		return "EOF"
+	case Text:
+		return "Text"
+	case Newline:
+		return "Newline"
+	case Bullet:
+		return "Bullet"
+	case Star:
+		return "Star"
+	case Underscore:
+		return "Underscore"
+	case LBrack:
+		return "LBrack"
+	case RBrack:
+		return "RBrack"
+	case Pipe:
+		return "Pipe"
+	default:
+		return "Unknown"
+	}
+}
+
+// Lexer goes through the input rune by rune and produces Tokens.
+type Lexer struct {
+	input   []rune // entire input
+	pos     int    // current position index in the input
+	current rune   // current rune
+	line    int    // line of the current rune
+	bol     bool   // are we at the beginning of a line
+}
+
+// marks the end of input
+var eof = rune(-1)
+
+func NewLexer(input string) *Lexer {
+	lex := &Lexer{input: []rune(input), line: 1, bol: true, current: eof}
+	if len(lex.input) > 0 {
+		lex.current = lex.input[0]
+	}
+	return lex
+}
+
+// isMarkup tells which runes can't be part of a Text token
+func isMarkup(r rune) bool {
+	switch r {
+	case '*', '_', '[', ']', '|', '\n', '\\', eof:
+		return true
+	}
+	return false
+}
+
+// Next returns the next Token at each invocation or an error if the input
+// cannot be recognized.
+func (lex *Lexer) Next() (Token, error) {
+	line := lex.line
+	if lex.current == eof {
+		return Token{Type: EOF, Line: line}, nil
+	}
+
+	bol := lex.bol
+	lex.bol = false
+
+	switch lex.current {
+	case '\n':
+		lex.consume()
+		lex.bol = true
+		return Token{Type: Newline, Text: "\n", Line: line}, nil
+	case '*':
+		lex.consume()
+		if bol && lex.current == ' ' {
+			lex.consume()
+			return Token{Type: Bullet, Text: "* ", Line: line}, nil
+		}
+		return Token{Type: Star, Text: "*", Line: line}, nil
+	case '_':
+		lex.consume()
+		return Token{Type: Underscore, Text: "_", Line: line}, nil
+	case '[':
+		lex.consume()
+		return Token{Type: LBrack, Text: "[", Line: line}, nil
+	case ']':
+		lex.consume()
+		return Token{Type: RBrack, Text: "]", Line: line}, nil
+	case '|':
+		lex.consume()
+		return Token{Type: Pipe, Text: "|", Line: line}, nil
+	default:
+		return lex.text()
+	}
+}
+
+// Lexical rule TEXT, a run of anything that isn't markup. An escaped markup
+// character is taken literally.
+//
+// TEXT : (ESC | ~('*'|'_'|'['|']'|'|'|'\n'|'\\'))+ ;
+// ESC  : '\\' ('*'|'_'|'['|']'|'|'|'\\') ;
+func (lex *Lexer) text() (Token, error) {
+	line := lex.line
+	var s strings.Builder
+	for {
+		if lex.current == '\\' {
+			lex.consume()
+			if lex.current == '\n' || lex.current == eof || !isMarkup(lex.current) {
+				return Token{}, fmt.Errorf("line %d: invalid escape sequence: \\%c", line, lex.current)
+			}
+		} else if isMarkup(lex.current) {
+			break
+		}
+		s.WriteRune(lex.current)
+		lex.consume()
+	}
+	return Token{Type: Text, Text: s.String(), Line: line}, nil
+}
+
+// consume moves the current position forward by one and saves the next
+// current rune.
+func (lex *Lexer) consume() {
+	if lex.current == '\n' {
+		lex.line++
+	}
+	lex.pos++
+	if lex.pos >= len(lex.input) {
+		// signals end of input
+		lex.current = eof
+	} else {
+		// saves the next rune
+		lex.current = lex.input[lex.pos]
+	}
+}
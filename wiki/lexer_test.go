@@ -0,0 +1,97 @@
+package wiki
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLexer(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []Token
+	}{
+		{name: "empty input", input: "", want: nil},
+		{
+			name:  "formatting",
+			input: "a *b* _c_",
+			want: []Token{
+				{Type: Text, Text: "a ", Line: 1},
+				{Type: Star, Text: "*", Line: 1},
+				{Type: Text, Text: "b", Line: 1},
+				{Type: Star, Text: "*", Line: 1},
+				{Type: Text, Text: " ", Line: 1},
+				{Type: Underscore, Text: "_", Line: 1},
+				{Type: Text, Text: "c", Line: 1},
+				{Type: Underscore, Text: "_", Line: 1},
+			},
+		},
+		{
+			name:  "bullets only at start of line",
+			input: "* a * b\n*c*",
+			want: []Token{
+				{Type: Bullet, Text: "* ", Line: 1},
+				{Type: Text, Text: "a ", Line: 1},
+				{Type: Star, Text: "*", Line: 1},
+				{Type: Text, Text: " b", Line: 1},
+				{Type: Newline, Text: "\n", Line: 1},
+				{Type: Star, Text: "*", Line: 2},
+				{Type: Text, Text: "c", Line: 2},
+				{Type: Star, Text: "*", Line: 2},
+			},
+		},
+		{
+			name:  "link",
+			input: "[a|b]",
+			want: []Token{
+				{Type: LBrack, Text: "[", Line: 1},
+				{Type: Text, Text: "a", Line: 1},
+				{Type: Pipe, Text: "|", Line: 1},
+				{Type: Text, Text: "b", Line: 1},
+				{Type: RBrack, Text: "]", Line: 1},
+			},
+		},
+		{
+			name:  "escapes",
+			input: `\*a\_b\\`,
+			want: []Token{
+				{Type: Text, Text: `*a_b\`, Line: 1},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer(tc.input)
+			var tokens []Token
+			for tok, err := l.Next(); tok.Type != EOF; tok, err = l.Next() {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tokens = append(tokens, tok)
+			}
+			if !cmp.Equal(tokens, tc.want) {
+				t.Error(cmp.Diff(tokens, tc.want))
+			}
+		})
+	}
+}
+
+func TestLexerBadInput(t *testing.T) {
+	for _, input := range []string{`\a`, "a\\\nb", `a\`} {
+		t.Run(input, func(t *testing.T) {
+			l := NewLexer(input)
+			for {
+				tok, err := l.Next()
+				if err != nil {
+					t.Log(err)
+					return
+				}
+				if tok.Type == EOF {
+					t.Fatal("want: error, got: nil")
+				}
+			}
+		})
+	}
+}
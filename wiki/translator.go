@@ -0,0 +1,254 @@
+package wiki
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Pattern 29:
+// Syntax-Directed Translator
+
+// A syntax-directed translator is a parser with actions: each rule emits the
+// output for the construct it matches as it goes, so there's no tree in
+// between the input and the output. It works well when the output follows the
+// structure of the input closely, as HTML does for wiki markup. Here the
+// actions are the emit calls, everything else is an LL(1) recursive-descent
+// parser just like chapter2's LL1Parser.
+
+// Grammar to be translated (ANTLR syntax):
+//
+// grammar Wiki;
+// wiki      : NEWLINE* (block NEWLINE*)* EOF ;
+// block     : list | paragraph ;
+// list      : item+ ;                          // <ul>...</ul>
+// item      : BULLET inline* end ;             // <li>...</li>
+// paragraph : line+ ;                          // <p>...</p>, ends on a blank line
+// line      : inline+ end ;
+// end       : NEWLINE | EOF ;
+// inline    : TEXT | bold | italic | link
+//           | ']' | '|'                        // markup only inside links
+//           ;
+// bold      : '*' inline+ '*' ;                // <b>...</b>
+// italic    : '_' inline+ '_' ;                // <i>...</i>
+// link      : '[' raw ('|' raw)? ']' ;         // <a href="...">...</a>
+// raw       : ~(NEWLINE | ']' | '|')+ ;        // markup characters taken as is
+//
+// A link is only emitted for an http, https or mailto URL, or a relative one,
+// so that markup can't run script with a javascript: URL or the like; other
+// links are emitted as their text.
+
+var SyntaxError = errors.New("syntax error")
+
+// Translator parses wiki markup and translates it to HTML. On the first error
+// it panics, ToHTML recovers and returns the error.
+type Translator struct {
+	input     *Lexer
+	lookahead Token
+	out       strings.Builder
+}
+
+func NewTranslator(l *Lexer) *Translator {
+	t := &Translator{input: l}
+	t.consume()
+	return t
+}
+
+// ToHTML translates wiki markup into HTML.
+func ToHTML(src string) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	t := NewTranslator(NewLexer(src))
+	t.wiki()
+	return t.out.String(), nil
+}
+
+func (t *Translator) emit(s string) {
+	t.out.WriteString(s)
+}
+
+func (t *Translator) wiki() {
+	t.blankLines()
+	for t.lookahead.Type != EOF {
+		t.block()
+		t.blankLines()
+	}
+	t.match(EOF)
+}
+
+func (t *Translator) blankLines() {
+	for t.lookahead.Type == Newline {
+		t.match(Newline)
+	}
+}
+
+func (t *Translator) block() {
+	if t.lookahead.Type == Bullet {
+		t.list()
+	} else {
+		t.paragraph()
+	}
+}
+
+func (t *Translator) list() {
+	t.emit("<ul>\n")
+	for t.lookahead.Type == Bullet {
+		t.item()
+	}
+	t.emit("</ul>\n")
+}
+
+func (t *Translator) item() {
+	t.match(Bullet)
+	t.emit("<li>")
+	for !t.atEnd() {
+		t.inline()
+	}
+	t.end()
+	t.emit("</li>\n")
+}
+
+// paragraph keeps adding lines until a blank line, a list or the end of input.
+func (t *Translator) paragraph() {
+	t.emit("<p>")
+	t.line()
+	for !t.atEnd() && t.lookahead.Type != Bullet {
+		t.emit("\n")
+		t.line()
+	}
+	t.emit("</p>\n")
+}
+
+func (t *Translator) line() {
+	t.inline()
+	for !t.atEnd() {
+		t.inline()
+	}
+	t.end()
+}
+
+// atEnd tells whether the current line is over
+func (t *Translator) atEnd() bool {
+	return t.lookahead.Type == Newline || t.lookahead.Type == EOF
+}
+
+func (t *Translator) end() {
+	if t.lookahead.Type == Newline {
+		t.match(Newline)
+	} else {
+		t.match(EOF)
+	}
+}
+
+func (t *Translator) inline() {
+	switch t.lookahead.Type {
+	case Text, RBrack, Pipe:
+		t.emit(html.EscapeString(t.lookahead.Text))
+		t.consume()
+	case Star:
+		t.enclosed(Star, "b", "bold")
+	case Underscore:
+		t.enclosed(Underscore, "i", "italic")
+	case LBrack:
+		t.link()
+	default:
+		t.fail("expecting text", t.lookahead)
+	}
+}
+
+// enclosed handles bold and italic, which only differ in the delimiter and the
+// HTML tag.
+func (t *Translator) enclosed(delim TokenType, tag, name string) {
+	open := t.match(delim)
+	t.emit("<" + tag + ">")
+	if t.lookahead.Type == delim {
+		t.fail("empty "+name, open)
+	}
+	for t.lookahead.Type != delim {
+		if t.atEnd() {
+			t.fail("unclosed "+name, open)
+		}
+		t.inline()
+	}
+	t.match(delim)
+	t.emit("</" + tag + ">")
+}
+
+func (t *Translator) link() {
+	open := t.match(LBrack)
+	text := t.raw(open)
+	url := text
+	if t.lookahead.Type == Pipe {
+		t.match(Pipe)
+		url = t.raw(open)
+	}
+	t.match(RBrack)
+	if !safeURL(url) {
+		t.emit(html.EscapeString(text))
+		return
+	}
+	t.emit(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(text) + "</a>")
+}
+
+// safeURL tells whether s is a URL that can be linked to.
+func safeURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// raw collects the text of every token up to the end of a link part, so that
+// a URL such as http://a.com/b_c isn't taken as the start of italics.
+func (t *Translator) raw(open Token) string {
+	var s strings.Builder
+	for t.lookahead.Type != RBrack && t.lookahead.Type != Pipe {
+		if t.atEnd() {
+			t.fail("unclosed link", open)
+		}
+		s.WriteString(t.lookahead.Text)
+		t.consume()
+	}
+	if s.Len() == 0 {
+		t.fail("empty link", open)
+	}
+	return s.String()
+}
+
+// match checks if the current lookahead token is of the type we're looking
+// for. Goes to the next token and returns the matched one if it is, panics
+// with a SyntaxError if it isn't.
+func (t *Translator) match(typ TokenType) Token {
+	tok := t.lookahead
+	if tok.Type != typ {
+		t.fail(fmt.Sprintf("expecting %v", typ), tok)
+	}
+	t.consume()
+	return tok
+}
+
+func (t *Translator) fail(msg string, tok Token) {
+	panic(fmt.Errorf("line %d: %w: %s, found %v", tok.Line, SyntaxError, msg, tok.Type))
+}
+
+func (t *Translator) consume() {
+	tok, err := t.input.Next()
+	if err != nil {
+		panic(err)
+	}
+	t.lookahead = tok
+}
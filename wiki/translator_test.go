@@ -0,0 +1,78 @@
+package wiki
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: "", want: ""},
+		{name: "paragraph", input: "hello world", want: "<p>hello world</p>\n"},
+		{
+			name:  "paragraphs",
+			input: "\none\nstill one\n\n\ntwo\n",
+			want:  "<p>one\nstill one</p>\n<p>two</p>\n",
+		},
+		{name: "bold", input: "a *b* c", want: "<p>a <b>b</b> c</p>\n"},
+		{name: "italic", input: "_a b_", want: "<p><i>a b</i></p>\n"},
+		{name: "nested", input: "*a _b_*", want: "<p><b>a <i>b</i></b></p>\n"},
+		{
+			name:  "list",
+			input: "items:\n* one\n* *two*\n\nafter",
+			want:  "<p>items:</p>\n<ul>\n<li>one</li>\n<li><b>two</b></li>\n</ul>\n<p>after</p>\n",
+		},
+		{
+			name:  "links",
+			input: "see [http://a.com/x_y] or [the *docs*|http://b.com?a=1&b=2]",
+			want:  `<p>see <a href="http://a.com/x_y">http://a.com/x_y</a> or <a href="http://b.com?a=1&amp;b=2">the *docs*</a></p>` + "\n",
+		},
+		{name: "relative link", input: "[docs/a.html]", want: `<p><a href="docs/a.html">docs/a.html</a></p>` + "\n"},
+		{name: "mail link", input: "[me|mailto:a@b.com]", want: `<p><a href="mailto:a@b.com">me</a></p>` + "\n"},
+		{name: "script link", input: "[x|javascript:alert(1)]", want: "<p>x</p>\n"},
+		{name: "script link text", input: "[JavaScript:alert(1)]", want: "<p>JavaScript:alert(1)</p>\n"},
+		{name: "html is escaped", input: "<b> & \"q\"", want: "<p>&lt;b&gt; &amp; &#34;q&#34;</p>\n"},
+		{name: "stray markup", input: "a ] b | c", want: "<p>a ] b | c</p>\n"},
+		{name: "escaped markup", input: `\*not bold\*`, want: "<p>*not bold*</p>\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ToHTML(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestToHTMLBadInput(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{name: "unclosed bold", input: "a *b\nc*"},
+		{name: "empty bold", input: "**"},
+		{name: "unclosed italic", input: "_a"},
+		{name: "unclosed link", input: "[a|b"},
+		{name: "empty link", input: "[]"},
+		{name: "link across lines", input: "[a\nb]"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ToHTML(tc.input)
+			if !errors.Is(err, SyntaxError) {
+				t.Errorf("want syntax error, got: %v", err)
+			}
+			t.Log(err)
+		})
+	}
+}